@@ -1,35 +1,109 @@
 package dependencies
 
 import (
+	"context"
+
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/followCode/djjs-event-reporting-backend/migrations"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // Dependencies holds all application dependencies
 type Dependencies struct {
-	DB *gorm.DB
+	Config *config.Config
+	DB     *gorm.DB
+	Logger *zap.Logger
 	// Add more dependencies as needed
 }
 
-// InitializeDependencies initializes all application dependencies
+// InitializeDependencies loads the configuration (config.Load) and initializes all application
+// dependencies in order (see startupDependencies), failing fast when the configuration is
+// invalid or a required dependency (database, auth) is unavailable.
+// Peripheral dependencies (redis, mail, storage) that are down or lazy leave the API
+// running degraded; Statuses reports their state.
 func InitializeDependencies() (*Dependencies, error) {
 	deps := &Dependencies{}
 
-	// Initialize database connection
-	config.ConnectDB()
-	deps.DB = config.DB
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	deps.Config = cfg
 
-	// Initialize S3 service
-	if err := services.InitializeS3(); err != nil {
+	// Initialize structured logger next so everything below logs through it
+	logger, err := InitializeLogger(cfg.Log)
+	if err != nil {
 		return nil, err
 	}
+	deps.Logger = logger
+	config.LoadServiceConfig(cfg)
+
+	if err := initializeInOrder(cfg, logger); err != nil {
+		return nil, err
+	}
+	deps.DB = config.DB
 
 	return deps, nil
 }
 
+// InitializeLogger builds the structured logger (LOG_LEVEL, LOG_FORMAT) and makes it the
+// process-wide default used by utils.Logger and the standard library log package
+func InitializeLogger(cfg config.LogConfig) (*zap.Logger, error) {
+	logger, err := utils.NewLogger(cfg.Level, cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	utils.SetLogger(logger)
+	return logger, nil
+}
+
+// InitializeStorage builds the file storage backend selected by STORAGE_DRIVER and makes it
+// the one used by services for uploads, downloads and presigned URLs
+func InitializeStorage(ctx context.Context, cfg config.StorageConfig) (services.Storage, error) {
+	storage, err := services.NewStorageFromConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	services.SetStorage(storage)
+	return storage, nil
+}
+
+// RunMigrations applies the pending versioned migrations (see package migrations) to the
+// database opened by config.OpenDB
+func RunMigrations(ctx context.Context) error {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	results, err := migrations.Up(ctx, sqlDB)
+	for _, result := range results {
+		if result.Error == nil {
+			utils.Logger(ctx).Info("Migration applied", zap.String("migration", result.Source.Path), zap.Duration("took", result.Duration))
+		}
+	}
+	return err
+}
+
+// GetConfig returns the configuration loaded at startup
+func (d *Dependencies) GetConfig() *config.Config {
+	return d.Config
+}
+
 // GetDB returns the database connection
 func (d *Dependencies) GetDB() *gorm.DB {
 	return d.DB
 }
 
+// GetLogger returns the structured logger
+func (d *Dependencies) GetLogger() *zap.Logger {
+	return d.Logger
+}
+
+// GetStorage returns the file storage backend, nil while it is unavailable
+func (d *Dependencies) GetStorage() services.Storage {
+	storage, _ := services.GetStorage()
+	return storage
+}
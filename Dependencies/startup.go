@@ -0,0 +1,257 @@
+package dependencies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/mail"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/messaging"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// Dependency states reported by Statuses
+const (
+	StateReady    = "ready"
+	StatePending  = "pending" // lazy, not initialized yet
+	StateDegraded = "degraded"
+)
+
+// Retry delays of peripheral dependencies that are down
+const (
+	retryInitialDelay = 5 * time.Second
+	retryMaxDelay     = 5 * time.Minute
+)
+
+// Dependency is one step of the ordered startup. Required dependencies stop the startup
+// when they fail; peripheral ones leave the API running degraded and are retried in the
+// background until they come up.
+type Dependency struct {
+	Name     string
+	Required bool
+	// Timeout bounds one initialization attempt (<NAME>_INIT_TIMEOUT overrides it, see
+	// config.StartupConfig)
+	Timeout time.Duration
+	Init    func(ctx context.Context) error
+}
+
+// Status is the state of a dependency, see Statuses
+type Status struct {
+	Name     string    `json:"name"`
+	Required bool      `json:"required"`
+	State    string    `json:"state"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+	Attempts int       `json:"attempts"`
+}
+
+var (
+	statusMu sync.RWMutex
+	statuses = map[string]*Status{}
+)
+
+// startupDependencies lists the dependencies in initialization order
+func startupDependencies(cfg *config.Config) []Dependency {
+	return []Dependency{
+		{Name: "database", Required: true, Timeout: 15 * time.Second, Init: func(ctx context.Context) error {
+			if err := config.OpenDB(ctx, cfg.Database); err != nil {
+				return err
+			}
+			// Report writes to archived financial years as services.ErrArchivedYear, and drop
+			// cached data built from the tables written to
+			return errors.Join(
+				config.DB.Use(services.ArchivedYearPlugin{}),
+				config.DB.Use(services.CacheInvalidationPlugin{}),
+			)
+		}},
+		// Versioned migrations, before anything reads the schema (MIGRATE_ON_STARTUP=false skips)
+		{Name: "migrations", Required: true, Timeout: 30 * time.Minute, Init: func(ctx context.Context) error {
+			if !cfg.Database.Migrate {
+				return nil
+			}
+			return RunMigrations(ctx)
+		}},
+		{Name: "auth", Required: true, Timeout: 15 * time.Second, Init: func(ctx context.Context) error {
+			return config.LoadAuthConfig(ctx, cfg)
+		}},
+		// Refuses to start (or starts read-only) when migrations are missing, see SCHEMA_CHECK
+		{Name: "schema", Required: true, Timeout: 15 * time.Second, Init: func(ctx context.Context) error {
+			return services.VerifySchema(ctx, cfg.Database.SchemaCheck)
+		}},
+		{Name: "redis", Timeout: 5 * time.Second, Init: func(ctx context.Context) error {
+			if err := config.ConnectRedis(ctx, cfg.Redis); err != nil {
+				return err
+			}
+			// Master data, branch lookups and dashboard aggregates are cached once Redis is up
+			if config.RedisClient != nil {
+				services.SetCache(services.NewRedisCache(config.RedisClient))
+			}
+			return nil
+		}},
+		{Name: "mail", Timeout: 10 * time.Second, Init: func(ctx context.Context) error {
+			if err := config.LoadMailConfig(cfg.Mail); err != nil {
+				return err
+			}
+			return mail.Init()
+		}},
+		{Name: "messaging", Timeout: 10 * time.Second, Init: func(ctx context.Context) error {
+			if err := config.LoadMessagingConfig(cfg.Messaging); err != nil {
+				return err
+			}
+			return messaging.Init()
+		}},
+		{Name: "storage", Timeout: 30 * time.Second, Init: func(ctx context.Context) error {
+			_, err := InitializeStorage(ctx, cfg.Storage)
+			return err
+		}},
+	}
+}
+
+// initializeInOrder runs the startup dependencies one after the other. Peripheral
+// dependencies named in LAZY_DEPENDENCIES (comma separated, e.g. "storage,mail,redis") are
+// not waited for: they are initialized in the background, so the API boots in degraded mode
+// (reads work, uploads, emails or rate limiting do not) until they are up. Those named in
+// REQUIRED_DEPENDENCIES (e.g. "storage") are made required, so a deployment that cannot work
+// without them fails fast instead of running degraded.
+func initializeInOrder(cfg *config.Config, logger *zap.Logger) error {
+	lazy := dependencyNames(cfg.Startup.LazyDependencies)
+	required := dependencyNames(cfg.Startup.RequiredDependencies)
+
+	for _, dep := range startupDependencies(cfg) {
+		if timeout, ok := cfg.Startup.InitTimeouts[dep.Name]; ok {
+			dep.Timeout = timeout
+		}
+		if required[dep.Name] {
+			dep.Required = true
+		}
+		if lazy[dep.Name] {
+			if dep.Required {
+				logger.Warn("Required dependency cannot be lazy, initializing it now", zap.String("dependency", dep.Name))
+			} else {
+				setStatus(dep, StatePending, nil)
+				go retryInBackground(dep, logger, 0)
+				continue
+			}
+		}
+
+		start := time.Now()
+		if err := runDependency(dep); err != nil {
+			if dep.Required {
+				return fmt.Errorf("%s: %w", dep.Name, err)
+			}
+			setStatus(dep, StateDegraded, err)
+			logger.Error("Dependency unavailable, API running degraded until it is up",
+				zap.String("dependency", dep.Name), zap.Error(err))
+			go retryInBackground(dep, logger, retryInitialDelay)
+			continue
+		}
+		setStatus(dep, StateReady, nil)
+		logger.Info("Dependency initialized", zap.String("dependency", dep.Name), zap.Duration("took", time.Since(start)))
+	}
+	return nil
+}
+
+// dependencyNames turns a list of dependency names into a set
+func dependencyNames(list []string) map[string]bool {
+	names := make(map[string]bool, len(list))
+	for _, name := range list {
+		names[name] = true
+	}
+	return names
+}
+
+// runDependency makes one initialization attempt, giving up once the timeout passes even
+// when Init does not honour its context
+func runDependency(dep Dependency) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dep.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- dep.Init(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", dep.Timeout)
+	}
+}
+
+// retryInBackground initializes a peripheral dependency after delay, retrying with
+// exponential backoff until it succeeds
+func retryInBackground(dep Dependency, logger *zap.Logger, delay time.Duration) {
+	for {
+		time.Sleep(delay)
+		err := runDependency(dep)
+		if err == nil {
+			setStatus(dep, StateReady, nil)
+			logger.Info("Dependency initialized", zap.String("dependency", dep.Name))
+			return
+		}
+		setStatus(dep, StateDegraded, err)
+		logger.Warn("Dependency still unavailable", zap.String("dependency", dep.Name), zap.Error(err))
+
+		if delay < retryInitialDelay {
+			delay = retryInitialDelay
+		} else if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+func setStatus(dep Dependency, state string, err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status, ok := statuses[dep.Name]
+	if !ok {
+		status = &Status{Name: dep.Name, Required: dep.Required}
+		statuses[dep.Name] = status
+	}
+	if status.State != state {
+		status.Since = time.Now()
+	}
+	status.State = state
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if state != StatePending {
+		status.Attempts++
+	}
+}
+
+// Statuses returns the state of every startup dependency, by name
+func Statuses() []Status {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	list := make([]Status, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Degraded reports whether a peripheral dependency is not up (yet)
+func Degraded() bool {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	for _, status := range statuses {
+		if status.State != StateReady {
+			return true
+		}
+	}
+	return false
+}
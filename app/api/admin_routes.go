@@ -0,0 +1,224 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAdminRoutes configures admin-only maintenance routes
+func SetupAdminRoutes(r *gin.RouterGroup) {
+	adminOnly := []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireRoles(models.RoleAdmin)}
+
+	registerRoutes(r,
+		// Data fixes run as dry-run previews unless the payload sets "dry_run": false
+		RouteGroup{
+			Prefix:     "/admin/data-fixes",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("/reassign-event-branch", handlers.ReassignEventBranchHandler),
+				POST("/swap-media-owner", handlers.SwapMediaOwnerHandler),
+				POST("/event-dates", handlers.FixEventDatesHandler),
+				POST("/reassign-attribution", handlers.ReassignAttributionHandler),
+			},
+		},
+		// Account management (deactivation, roles, branches, forced logout, 2FA reset), audited per user
+		RouteGroup{
+			Prefix:     "/admin/users",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.ListUsersHandler),
+				POST("/:id/deactivate", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.DeactivateUserHandler),
+				POST("/:id/reactivate", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.ReactivateUserHandler),
+				PUT("/:id/role", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.AssignUserRoleHandler),
+				DELETE("/:id/role", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.RemoveUserRoleHandler),
+				PUT("/:id/branch", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.AssignUserBranchHandler),
+				POST("/:id/logout", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.ForceLogoutUserHandler),
+				GET("/:id/sessions", handlers.GetUserSessionsHandler),
+				DELETE("/:id/sessions/:session_id", handlers.RevokeUserSessionHandler),
+				DELETE("/:id/2fa", middleware.AuditTrail(services.AuditEntityUser, "id"), handlers.ResetUserTwoFactorHandler),
+			},
+		},
+		RouteGroup{
+			Prefix:     "/admin/feature-flags",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetFeatureFlagsHandler),
+				PUT("/:key", handlers.SetFeatureFlagHandler),
+			},
+		},
+		// Expand/contract schema migrations, see services/schema_migration_service.go
+		RouteGroup{
+			Prefix:     "/admin/migrations",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetSchemaMigrationsHandler),
+				GET("/:name", handlers.GetSchemaMigrationHandler),
+				POST("/:name/:step", handlers.RunSchemaMigrationStepHandler),
+			},
+		},
+		// Long running statements on the shared database and their cancellation
+		RouteGroup{
+			Prefix:     "/admin/db-queries",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetRunningQueriesHandler),
+				POST("/:pid/cancel", handlers.CancelQueryHandler),
+			},
+		},
+		// Background job queue, see services/job_service.go
+		RouteGroup{
+			Prefix:     "/admin/jobs",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetJobsHandler),
+				POST("/:id/retry", handlers.RetryJobHandler),
+			},
+		},
+		// Purge of expired sessions, tokens, stale drafts and old jobs
+		RouteGroup{
+			Prefix:     "/admin/gc",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("", handlers.StartGarbageCollectionHandler),
+			},
+		},
+		// Orphaned objects and rows whose object is missing (services/storage_reconcile_service.go),
+		// storage usage and quotas per branch (services/storage_usage_service.go)
+		RouteGroup{
+			Prefix:     "/admin/storage",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("/reconcile", handlers.StartStorageReconcileHandler),
+				GET("/usage", handlers.GetStorageUsageHandler),
+				POST("/usage/refresh", handlers.StartStorageUsageRefreshHandler),
+				PUT("/quotas/:branch_id", handlers.SetBranchStorageQuotaHandler),
+			},
+		},
+		// Antivirus/moderation scan of existing media, see services/media_scan_service.go
+		RouteGroup{
+			Prefix:     "/admin/media-scan",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetMediaScanSummaryHandler),
+				POST("/backfill", handlers.StartMediaScanBackfillHandler),
+			},
+		},
+		// Legal holds block deletion of events/media; deleted media leave tombstones
+		RouteGroup{
+			Prefix:     "/admin/legal-holds",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetLegalHoldsHandler),
+				POST("", handlers.PlaceLegalHoldHandler),
+				POST("/:id/release", handlers.ReleaseLegalHoldHandler),
+			},
+		},
+		RouteGroup{
+			Prefix:     "/admin/media-tombstones",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetMediaTombstonesHandler),
+			},
+		},
+		// Signed manifests of uploaded documents, see services/media_manifest_service.go
+		RouteGroup{
+			Prefix:     "/admin/media-manifests",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetMediaManifestsHandler),
+				POST("", handlers.GenerateMediaManifestHandler),
+				GET("/:id/verify", handlers.VerifyMediaManifestHandler),
+			},
+		},
+		// Export/import of all media metadata for migrating deployments, see services/media_export_service.go
+		RouteGroup{
+			Prefix:     "/admin/media-exports",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("", handlers.StartMediaExportHandler),
+				POST("/import", handlers.StartMediaImportHandler),
+			},
+		},
+		// Outgoing email log, see services/mail
+		RouteGroup{
+			Prefix:     "/admin/notifications",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetNotificationLogsHandler),
+			},
+		},
+		// Training sandbox: branches whose data is excluded from stats and exports, see services/sandbox_service.go
+		RouteGroup{
+			Prefix:     "/admin/sandbox",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetSandboxHandler),
+				PUT("/branches/:id", handlers.SetBranchSandboxHandler),
+				POST("/wipe", handlers.WipeSandboxHandler),
+			},
+		},
+		// Daily request counts per user, client and route, see middleware.APIUsage
+		RouteGroup{
+			Prefix:     "/admin/usage",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetAPIUsageHandler),
+			},
+		},
+		// Archived financial years: read-only data, stats from rollups, see services/financial_year_service.go
+		RouteGroup{
+			Prefix:     "/admin/financial-years",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetArchivedFinancialYearsHandler),
+				POST("/:year/archive", handlers.ArchiveFinancialYearHandler),
+				POST("/:year/unarchive", handlers.UnarchiveFinancialYearHandler),
+			},
+		},
+		// Daily snapshots of branch statistics for as-of reporting, see services/stats_snapshot_service.go
+		RouteGroup{
+			Prefix:     "/admin/stats-snapshots",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetStatsSnapshotsHandler),
+				POST("", handlers.TakeStatsSnapshotHandler),
+			},
+		},
+		// Official location datasets (countries, states, districts, cities), see services/geo_service.go
+		RouteGroup{
+			Prefix:     "/admin/locations",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("/import", handlers.ImportLocationsHandler),
+			},
+		},
+		// Cache priming after a deploy (also run on startup), see services/warmup_service.go
+		RouteGroup{
+			Prefix:     "/admin/warmup",
+			Middleware: adminOnly,
+			Routes: []Route{
+				POST("", handlers.WarmUpHandler),
+			},
+		},
+		// Outgoing webhook subscriptions (signed JSON payloads to integrators)
+		RouteGroup{
+			Prefix:     "/admin/webhooks",
+			Middleware: adminOnly,
+			Routes: []Route{
+				GET("", handlers.GetWebhookSubscriptionsHandler),
+				POST("", handlers.CreateWebhookSubscriptionHandler),
+				GET("/event-types", handlers.GetWebhookEventTypesHandler),
+				GET("/:id", handlers.GetWebhookSubscriptionHandler),
+				PUT("/:id", handlers.UpdateWebhookSubscriptionHandler),
+				DELETE("/:id", handlers.DeleteWebhookSubscriptionHandler),
+				POST("/:id/test", handlers.TestWebhookSubscriptionHandler),
+				GET("/:id/deliveries", handlers.GetWebhookDeliveriesHandler),
+				GET("/:id/deliveries/:delivery_id", handlers.GetWebhookDeliveryHandler),
+				POST("/:id/deliveries/:delivery_id/redeliver", handlers.RedeliverWebhookHandler),
+			},
+		},
+	)
+}
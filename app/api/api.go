@@ -2,45 +2,112 @@ package api
 
 import (
 	"context"
+	"net/http"
 	"time"
 
+	dependencies "github.com/followCode/djjs-event-reporting-backend/Dependencies"
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures all API routes and groups them together
-func SetupRoutes(r *gin.Engine) {
+// SetupRoutes configures all API routes and groups them together. server supplies the
+// settings of the /metrics token and the legacy API's sunset date.
+func SetupRoutes(r *gin.Engine, server config.ServerConfig) {
+	registeredRoutes = nil
+
+	// Known paths called with an unsupported method get 405 with an Allow header,
+	// and plain OPTIONS requests get 204 with the same header
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(MethodNotAllowedHandler)
+
 	// Health check endpoint (public, no auth required)
-	r.GET("/health", HealthCheckHandler)
-	r.GET("/api/health", HealthCheckHandler)
-
-	// Main API group
-	api := r.Group("/api")
-	{
-		// Authentication routes
-		SetupAuthRoutes(api)
-
-		// CRUD routes
-		SetupAreaRoutes(api)
-		SetupUserRoutes(api)
-		SetupBranchRoutes(api)
-		SetupChildBranchRoutes(api)
-		SetupEventRoutes(api)
-		SetupPromotionRoutes(api)
-		SetupMediaRoutes(api)
-		SetupSpecialGuestRoutes(api)
-		SetupVolunteerRoutes(api)
-		SetupDonationRoutes(api)
-		SetupMasterRoutes(api)
-		SetupFileRoutes(api)
-		SetupBranchMediaRoutes(api)
-		SetupChildBranchMediaRoutes(api)
+	registerRoutes(&r.RouterGroup, RouteGroup{
+		Routes: []Route{
+			GET("/health", HealthCheckHandler),
+			GET("/api/health", HealthCheckHandler),
+			GET("/ready", ReadinessHandler),
+			GET("/api/ready", ReadinessHandler),
+			// Prometheus scrape endpoint (METRICS_TOKEN protects it when set)
+			GET("/metrics", middleware.MetricsAuth(server.MetricsToken), gin.WrapH(metrics.Handler())),
+		},
+	})
+
+	// Public website API (no auth, rate limited)
+	SetupPublicRoutes(r)
+
+	// Versioned API. The unversioned /api paths serve the same v1 routes for clients that
+	// predate versioning and announce their deprecation in response headers.
+	v1 := r.Group(middleware.APIV1Prefix, middleware.MaskSensitiveFields())
+	setupV1Routes(v1)
+	legacy := r.Group(middleware.LegacyAPIPrefix, middleware.LegacyAPI(server.LegacyAPISunset), middleware.MaskSensitiveFields())
+	setupV1Routes(legacy)
+}
+
+// setupV1Routes mounts the v1 API on api. A v2 gets its own setup function and can reuse
+// the Setup*Routes whose payloads did not change.
+func setupV1Routes(api *gin.RouterGroup) {
+	// Authentication routes
+	SetupAuthRoutes(api)
+
+	// CRUD routes
+	SetupAreaRoutes(api)
+	SetupUserRoutes(api)
+	SetupMeRoutes(api)
+	SetupBranchRoutes(api)
+	SetupChildBranchRoutes(api)
+	SetupEventRoutes(api)
+	SetupPromotionRoutes(api)
+	SetupMediaRoutes(api)
+	SetupSpecialGuestRoutes(api)
+	SetupGuestRoutes(api)
+	SetupVolunteerRoutes(api)
+	SetupDonationRoutes(api)
+	SetupPersonRoutes(api)
+	SetupMasterRoutes(api)
+	SetupFileRoutes(api)
+	SetupBranchMediaRoutes(api)
+	SetupChildBranchMediaRoutes(api)
+	SetupAuditRoutes(api)
+	SetupDashboardRoutes(api)
+	SetupSearchRoutes(api)
+	SetupJobRoutes(api)
+	SetupAdminRoutes(api)
+
+	// Route table introspection (admin only, for debugging)
+	registerRoutes(api, RouteGroup{
+		Prefix:     "/routes",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireRoles(models.RoleAdmin)},
+		Routes: []Route{
+			GET("", ListRoutesHandler),
+		},
+	})
+}
+
+// ReadinessHandler tells load balancers whether to route traffic to this instance
+// @Summary Readiness probe
+// @Description Answers 200 once startup has finished (dependencies initialized, schema checked, caches warmed) and 503 while the server is starting or draining for a shutdown. Use /health for liveness.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Ready"
+// @Failure 503 {object} map[string]interface{} "Starting or draining"
+// @Router /ready [get]
+// @Router /api/ready [get]
+func ReadinessHandler(c *gin.Context) {
+	state := middleware.Readiness()
+	status := http.StatusOK
+	if state != middleware.ReadinessReady {
+		status = http.StatusServiceUnavailable
 	}
+	c.JSON(status, gin.H{"status": state})
 }
 
-// HealthCheckHandler returns the health status of the API including S3 connectivity
+// HealthCheckHandler returns the health status of the API including its dependencies
 // @Summary Health check endpoint
-// @Description Returns the health status of the API and S3 bucket connectivity
+// @Description Returns the health status of the API, the state of each startup dependency (database, auth, redis, mail, storage) and S3 bucket connectivity. A "degraded" API still answers 200: it serves reads while a peripheral dependency is down. brown_out is true while low-priority routes are shed under load.
 // @Tags health
 // @Produce json
 // @Success 200 {object} map[string]interface{} "Health status"
@@ -55,28 +122,47 @@ func HealthCheckHandler(c *gin.Context) {
 
 	servicesMap := health["services"].(map[string]interface{})
 
-	// Check S3 connectivity
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := services.VerifyS3Connection(ctx); err != nil {
-		servicesMap["s3"] = gin.H{
-			"status":  "error",
-			"message": err.Error(),
+	storageReady := true
+	for _, status := range dependencies.Statuses() {
+		entry := gin.H{"status": status.State, "since": status.Since.Format(time.RFC3339)}
+		if status.Error != "" {
+			entry["message"] = status.Error
 		}
-		health["status"] = "degraded"
-	} else {
-		servicesMap["s3"] = gin.H{
-			"status":  "ok",
-			"message": "S3 bucket is accessible and has correct permissions",
+		servicesMap[status.Name] = entry
+		if status.Name == "storage" && status.State != dependencies.StateReady {
+			storageReady = false
 		}
 	}
+	if dependencies.Degraded() {
+		health["status"] = "degraded"
+	}
+	// Writes are rejected until missing migrations are applied (SCHEMA_CHECK=read-only)
+	if reason := services.SchemaReadOnly(); reason != "" {
+		health["status"] = "degraded"
+		health["read_only"] = gin.H{"reason": reason}
+	}
+	// Low-priority routes (exports, statistics, public API) are answering 503 under load
+	health["brown_out"] = middleware.BrownOut()
 
-	statusCode := 200
-	if health["status"] == "degraded" {
-		statusCode = 503 // Service Unavailable
+	// Check S3 connectivity
+	if storageReady {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := services.VerifyStorage(ctx); err != nil {
+			servicesMap["s3"] = gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			}
+			health["status"] = "degraded"
+		} else {
+			servicesMap["s3"] = gin.H{
+				"status":  "ok",
+				"message": "S3 bucket is accessible and has correct permissions",
+			}
+		}
 	}
 
-	c.JSON(statusCode, health)
+	c.JSON(http.StatusOK, health)
 }
 
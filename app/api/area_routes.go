@@ -8,15 +8,17 @@ import (
 
 // SetupAreaRoutes configures area CRUD routes
 func SetupAreaRoutes(r *gin.RouterGroup) {
-	areas := r.Group("/areas")
-	areas.Use(middleware.AuthMiddleware())
-	{
-		areas.POST("", handlers.CreateAreaHandler)
-		areas.GET("", handlers.GetAllAreasHandler)
-		areas.GET("/:id", handlers.GetAreaSearchHandler)
-		areas.PUT("/:id", handlers.UpdateAreaHandler)
-		areas.DELETE("/:id", handlers.DeleteAreaHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/areas",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			POST("", handlers.CreateAreaHandler),
+			GET("", handlers.GetAllAreasHandler),
+			GET("/:id", handlers.GetAreaSearchHandler),
+			PUT("/:id", handlers.UpdateAreaHandler),
+			DELETE("/:id", handlers.DeleteAreaHandler),
+		},
+	})
 }
 
 
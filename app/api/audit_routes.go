@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAuditRoutes configures audit log routes (admin only)
+func SetupAuditRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/audit-logs",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireRoles(models.RoleAdmin)},
+		Routes: []Route{
+			GET("", handlers.GetAuditLogsHandler),
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/mail"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
@@ -11,85 +12,132 @@ import (
 // SetupAuthRoutes sets up authentication routes with proper middleware
 func SetupAuthRoutes(r *gin.RouterGroup) {
 	// Initialize auth service
-	mailer := auth.NewStubMailer()
+	mailer := mail.NewAuthMailer()
 	authService := auth.NewAuthService(mailer)
 	authHandler := handlers.NewAuthHandler(authService)
 
 	// Public routes
-	authGroup := r.Group("/auth")
-	{
-		// Registration
-		authGroup.POST("/register",
-			middleware.StrictJSONBinding(),
-			middleware.RateLimiter(middleware.RateLimitConfig{
-				MaxRequests:   5,
-				Window:        config.RateLimitWindow,
-				IdentifierKey: "ip",
-			}),
-			authHandler.Register,
-		)
-
-		// Email verification
-		authGroup.POST("/verify-email",
-			middleware.StrictJSONBinding(),
-			authHandler.VerifyEmail,
-		)
-
-		// Login (rate limited by IP)
-		authGroup.POST("/login",
-			middleware.StrictJSONBinding(),
-			middleware.RateLimiter(middleware.RateLimitConfig{
-				MaxRequests:   config.RateLimitLoginPerIP,
-				Window:        config.RateLimitWindow,
-				IdentifierKey: "ip",
-			}),
-			authHandler.Login,
-		)
-
-		// Refresh token (CSRF optional - uses HttpOnly cookie for security)
-		// CSRF is checked but refresh can proceed if cookie is valid even without header
-		authGroup.POST("/refresh",
-			middleware.OptionalCSRFProtection(),
-			authHandler.Refresh,
-		)
-
-		// Logout
-		authGroup.POST("/logout", authHandler.Logout)
-
-		// Forgot password (rate limited by IP)
-		authGroup.POST("/forgot-password",
-			middleware.StrictJSONBinding(),
-			middleware.RateLimiter(middleware.RateLimitConfig{
-				MaxRequests:   config.RateLimitForgotPasswordPerIP,
-				Window:        config.RateLimitWindow,
-				IdentifierKey: "ip",
-			}),
-			authHandler.ForgotPassword,
-		)
-
-		// Reset password
-		authGroup.POST("/reset-password",
-			middleware.StrictJSONBinding(),
-			authHandler.ResetPassword,
-		)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix: "/auth",
+		Routes: []Route{
+			// Registration
+			POST("/register",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   5,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.Register,
+			),
+
+			// Email verification
+			POST("/verify-email",
+				middleware.StrictJSONBinding(),
+				authHandler.VerifyEmail,
+			),
+
+			// Login (rate limited by IP)
+			POST("/login",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   config.RateLimitLoginPerIP,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.Login,
+			),
+
+			// Second login step for accounts with two-factor authentication (rate limited by IP)
+			POST("/login/2fa",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   config.RateLimitLoginPerIP,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.LoginTwoFactor,
+			),
+
+			// Refresh token (CSRF optional - uses HttpOnly cookie for security)
+			// CSRF is checked but refresh can proceed if cookie is valid even without header
+			POST("/refresh",
+				middleware.OptionalCSRFProtection(),
+				authHandler.Refresh,
+			),
+
+			// Logout
+			POST("/logout", authHandler.Logout),
+
+			// Forgot password (rate limited by IP)
+			POST("/forgot-password",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   config.RateLimitForgotPasswordPerIP,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.ForgotPassword,
+			),
+
+			// Reset password
+			POST("/reset-password",
+				middleware.StrictJSONBinding(),
+				authHandler.ResetPassword,
+			),
+		},
+	})
+
+	// Self-service password recovery at the top level (same flow as /auth/forgot-password,
+	// with the emailed token in the reset URL)
+	registerRoutes(r, RouteGroup{
+		Routes: []Route{
+			POST("/forgot-password",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   config.RateLimitForgotPasswordPerIP,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.ForgotPassword,
+			),
+			POST("/reset-password/:token",
+				middleware.StrictJSONBinding(),
+				middleware.RateLimiter(middleware.RateLimitConfig{
+					MaxRequests:   config.RateLimitLoginPerIP,
+					Window:        config.RateLimitWindow,
+					IdentifierKey: "ip",
+				}),
+				authHandler.ResetPasswordWithToken,
+			),
+		},
+	})
 
 	// Protected routes
-	protected := r.Group("/auth")
-	protected.Use(middleware.AuthRequired())
-	{
-		// Get current user
-		protected.GET("/me", authHandler.Me)
-
-		// Change password
-		protected.POST("/change-password",
-			middleware.StrictJSONBinding(),
-			authHandler.ChangePassword,
-		)
-
-		// Session management
-		protected.GET("/sessions", authHandler.GetSessions)
-		protected.DELETE("/sessions/:id", authHandler.RevokeSession)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/auth",
+		Middleware: []gin.HandlerFunc{middleware.AuthRequired()},
+		Routes: []Route{
+			// Get current user
+			GET("/me", authHandler.Me),
+
+			// Change password
+			POST("/change-password",
+				middleware.StrictJSONBinding(),
+				authHandler.ChangePassword,
+			),
+
+			// Session management
+			GET("/sessions", authHandler.GetSessions),
+			DELETE("/sessions/:id", authHandler.RevokeSession),
+
+			// Two-factor authentication (reachable while AuthMiddleware demands enrollment)
+			GET("/2fa", authHandler.GetTwoFactorStatus),
+			POST("/2fa/setup", authHandler.SetupTwoFactor),
+			POST("/2fa/enable", middleware.StrictJSONBinding(), authHandler.EnableTwoFactor),
+			POST("/2fa/disable", middleware.StrictJSONBinding(), authHandler.DisableTwoFactor),
+			POST("/2fa/recovery-codes", middleware.StrictJSONBinding(), authHandler.RegenerateRecoveryCodes),
+		},
+	})
 }
 
@@ -3,27 +3,34 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupBranchMediaRoutes configures branch media CRUD routes
 func SetupBranchMediaRoutes(r *gin.RouterGroup) {
-	media := r.Group("/branch-media")
-	media.Use(middleware.AuthMiddleware())
-	{
-		media.GET("", handlers.GetAllBranchMediaHandler)
-		media.GET("/branch/:branch_id", handlers.GetBranchMediaByBranchIDHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/branch-media",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranchMedia, "id"), middleware.RequireBranchScope(services.ScopeBranch, "branch_id")},
+		Routes: []Route{
+			GET("", middleware.LatencySLO(sloGallery), handlers.GetAllBranchMediaHandler),
+			GET("/branch/:branch_id", middleware.LatencySLO(sloGallery), handlers.GetBranchMediaByBranchIDHandler),
+			POST("/:id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityBranchMedia, "id"), handlers.RestoreBranchMediaHandler),
+		},
+	})
 }
 
 // SetupChildBranchMediaRoutes configures child branch media CRUD routes
 func SetupChildBranchMediaRoutes(r *gin.RouterGroup) {
-	media := r.Group("/child-branch-media")
-	media.Use(middleware.AuthMiddleware())
-	{
-		media.GET("", handlers.GetAllBranchMediaHandler)
-		media.GET("/branch/:branch_id", handlers.GetBranchMediaByBranchIDHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/child-branch-media",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranch, "branch_id")},
+		Routes: []Route{
+			GET("", middleware.LatencySLO(sloGallery), handlers.GetAllBranchMediaHandler),
+			GET("/branch/:branch_id", middleware.LatencySLO(sloGallery), handlers.GetBranchMediaByBranchIDHandler),
+		},
+	})
 }
 
 
@@ -3,44 +3,81 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupBranchRoutes configures branch CRUD routes
 func SetupBranchRoutes(r *gin.RouterGroup) {
-	branches := r.Group("/branches")
-	branches.Use(middleware.AuthMiddleware())
-	{
-		branches.POST("", handlers.CreateBranchHandler)
-		branches.GET("", handlers.GetAllBranchesHandler)
-		branches.GET("/:id", handlers.GetBranchHandler)
-		branches.GET("/search", handlers.GetBranchSearchHandler)
-		branches.GET("/parent/:parent_id/children", handlers.GetChildBranchesHandler)
-		branches.PUT("/:id", handlers.UpdateBranchHandler)
-		branches.DELETE("/:id", handlers.DeleteBranchHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/branches",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranch, "id"), middleware.RequireBranchScope(services.ScopeBranch, "parent_id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityBranch, ""), handlers.CreateBranchHandler),
+			POST("/import", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), handlers.ImportBranchesHandler),
+			GET("", middleware.LatencySLO(sloList), handlers.GetAllBranchesHandler),
+			GET("/:id", handlers.GetBranchHandler),
+			GET("/:id/stats", middleware.Sheddable(), handlers.GetBranchStatsHandler),
+			// Reported (snapshotted) figures against current ones, see services/stats_snapshot_service.go
+			GET("/:id/stats/restatements", middleware.Sheddable(), handlers.GetBranchStatsRestatementsHandler),
+			// Nested JSON snapshot for archival before decommissioning
+			GET("/:id/export", middleware.RequireRoles(models.RoleAdmin), middleware.Sheddable(), handlers.ExportBranchArchiveHandler),
+			// Audited by the service
+			PUT("/:id/cover-image", handlers.SetBranchCoverImageHandler),
+			PUT("/:id/coordinator-photo", handlers.SetBranchCoordinatorPhotoHandler),
+			GET("/search", handlers.GetBranchSearchHandler),
+			GET("/nearby", handlers.GetNearbyBranchesHandler),
+			GET("/parent/:parent_id/children", handlers.GetChildBranchesHandler),
+			PUT("/:id", middleware.AuditTrail(services.AuditEntityBranch, "id"), handlers.UpdateBranchHandler),
+			DELETE("/:id", middleware.AuditTrail(services.AuditEntityBranch, "id"), handlers.DeleteBranchHandler),
+			POST("/:id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityBranch, "id"), handlers.RestoreBranchHandler),
+			// Coordinator self-service edits, applied once reviewed
+			POST("/:id/change-requests", handlers.SubmitBranchChangeRequestHandler),
+			// Coordinator changes; audited by the service
+			POST("/:id/handover", handlers.HandOverBranchHandler),
+			GET("/:id/handovers", handlers.GetCoordinatorHandoversHandler),
+		},
+	})
+
+	// Review of coordinator edits; approvals are audited by the service
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/branch-change-requests",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("", handlers.GetBranchChangeRequestsHandler),
+			GET("/:id", handlers.GetBranchChangeRequestHandler),
+			POST("/:id/approve", handlers.ApproveBranchChangeRequestHandler),
+			POST("/:id/reject", handlers.RejectBranchChangeRequestHandler),
+			DELETE("/:id", handlers.WithdrawBranchChangeRequestHandler),
+		},
+	})
 
 	// Branch Infrastructure routes
-	branchInfra := r.Group("/branch-infra")
-	branchInfra.Use(middleware.AuthMiddleware())
-	{
-		branchInfra.POST("", handlers.CreateBranchInfrastructureHandler)
-		branchInfra.GET("", handlers.GetAllBranchInfrastructureHandler)
-		branchInfra.GET("/branch/:branch_id", handlers.GetInfrastructureByBranchHandler)
-		branchInfra.PUT("/:id", handlers.UpdateBranchInfrastructureHandler)
-		branchInfra.DELETE("/:id", handlers.DeleteBranchInfrastructureHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/branch-infra",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranchInfra, "id"), middleware.RequireBranchScope(services.ScopeBranch, "branch_id")},
+		Routes: []Route{
+			POST("", handlers.CreateBranchInfrastructureHandler),
+			GET("", handlers.GetAllBranchInfrastructureHandler),
+			GET("/branch/:branch_id", handlers.GetInfrastructureByBranchHandler),
+			PUT("/:id", handlers.UpdateBranchInfrastructureHandler),
+			DELETE("/:id", handlers.DeleteBranchInfrastructureHandler),
+		},
+	})
 
 	// Branch Member routes
-	branchMember := r.Group("/branch-member")
-	branchMember.Use(middleware.AuthMiddleware())
-	{
-		branchMember.POST("", handlers.CreateBranchMemberHandler)
-		branchMember.GET("", handlers.GetAllBranchMembersHandler)
-		branchMember.GET("/branch/:branch_id", handlers.GetMembersByBranchHandler)
-		branchMember.PUT("/:id", handlers.UpdateBranchMemberHandler)
-		branchMember.DELETE("/:id", handlers.DeleteBranchMemberHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/branch-member",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranchMember, "id"), middleware.RequireBranchScope(services.ScopeBranch, "branch_id")},
+		Routes: []Route{
+			POST("", handlers.CreateBranchMemberHandler),
+			GET("", handlers.GetAllBranchMembersHandler),
+			GET("/branch/:branch_id", handlers.GetMembersByBranchHandler),
+			PUT("/:id", handlers.UpdateBranchMemberHandler),
+			DELETE("/:id", handlers.DeleteBranchMemberHandler),
+		},
+	})
 }
 
 
@@ -3,29 +3,40 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupChildBranchRoutes configures child branch CRUD routes
 func SetupChildBranchRoutes(r *gin.RouterGroup) {
-	childBranches := r.Group("/child-branches")
-	childBranches.Use(middleware.AuthMiddleware())
-	{
-		childBranches.POST("", handlers.CreateChildBranchHandler)
-		childBranches.GET("", handlers.GetAllChildBranchesHandler)
-		childBranches.GET("/:id", handlers.GetChildBranchHandler)
-		childBranches.GET("/parent/:parent_id", handlers.GetChildBranchesByParentHandler)
-		childBranches.PUT("/:id", handlers.UpdateChildBranchHandler)
-		childBranches.DELETE("/:id", handlers.DeleteChildBranchHandler)
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/child-branches",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeBranch, "id"), middleware.RequireBranchScope(services.ScopeBranch, "parent_id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityChildBranch, ""), handlers.CreateChildBranchHandler),
+			POST("/import", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), handlers.ImportChildBranchesHandler),
+			GET("", middleware.LatencySLO(sloList), handlers.GetAllChildBranchesHandler),
+			GET("/:id", handlers.GetChildBranchHandler),
+			GET("/parent/:parent_id", middleware.LatencySLO(sloList), handlers.GetChildBranchesByParentHandler),
+			PUT("/:id", middleware.AuditTrail(services.AuditEntityChildBranch, "id"), handlers.UpdateChildBranchHandler),
+			DELETE("/:id", middleware.AuditTrail(services.AuditEntityChildBranch, "id"), handlers.DeleteChildBranchHandler),
+			POST("/:id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityChildBranch, "id"), handlers.RestoreChildBranchHandler),
+			// Audited by the service (the transfer may also reassign members)
+			POST("/:id/transfer", middleware.RequireRoles(models.RoleAdmin), handlers.TransferChildBranchHandler),
+			// Audited by the service
+			PUT("/:id/cover-image", handlers.SetBranchCoverImageHandler),
+			PUT("/:id/coordinator-photo", handlers.SetBranchCoordinatorPhotoHandler),
 
-		// Child Branch Infrastructure
-		childBranches.POST("/:id/infrastructure", handlers.CreateChildBranchInfrastructureHandler)
-		childBranches.GET("/:id/infrastructure", handlers.GetChildBranchInfrastructureHandler)
+			// Child Branch Infrastructure
+			POST("/:id/infrastructure", handlers.CreateChildBranchInfrastructureHandler),
+			GET("/:id/infrastructure", handlers.GetChildBranchInfrastructureHandler),
 
-		// Child Branch Members
-		childBranches.POST("/:id/members", handlers.CreateChildBranchMemberHandler)
-		childBranches.GET("/:id/members", handlers.GetChildBranchMembersHandler)
-	}
+			// Child Branch Members
+			POST("/:id/members", handlers.CreateChildBranchMemberHandler),
+			GET("/:id/members", handlers.GetChildBranchMembersHandler),
+		},
+	})
 }
 
 
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDashboardRoutes configures the aggregated statistics shown on the dashboard
+func SetupDashboardRoutes(r *gin.RouterGroup) {
+	// Statistics are shed in brown-out so data entry stays responsive
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/dashboard",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.Sheddable()},
+		Routes: []Route{
+			GET("", middleware.LatencySLO(sloReport), handlers.GetDashboardHandler),
+			GET("/events-by-month", handlers.GetDashboardEventsByMonthHandler),
+			GET("/trends", handlers.GetDashboardTrendsHandler),
+			GET("/top-branches", handlers.GetDashboardTopBranchesHandler),
+			GET("/donations", handlers.GetDashboardDonationsHandler),
+			GET("/media", handlers.GetDashboardMediaHandler),
+			GET("/year-over-year", handlers.GetYearOverYearHandler),
+			GET("/branch-rollup", handlers.GetBranchRollupHandler),
+			GET("/location-rollup", handlers.GetLocationRollupHandler),
+		},
+	})
+}
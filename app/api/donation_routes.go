@@ -3,18 +3,30 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupDonationRoutes configures donation CRUD routes
 func SetupDonationRoutes(r *gin.RouterGroup) {
-	donations := r.Group("/donations")
-	donations.Use(middleware.AuthMiddleware())
-	{
-		donations.POST("", handlers.CreateDonation)
-		donations.GET("", handlers.GetAllDonations)
-		donations.PUT("/:id", handlers.UpdateDonation)
-		donations.DELETE("/:id", handlers.DeleteDonation)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/donations",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeDonation, "id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityDonation, ""), handlers.CreateDonation),
+			GET("", handlers.GetAllDonations),
+			GET("/search", handlers.SearchDonations),
+			GET("/summary", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Sheddable(), handlers.GetDonationSummaryHandler),
+			GET("/reconciliation", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.Sheddable(), handlers.GetDonationReconciliationHandler),
+			POST("/:id/receipt", middleware.AuditTrail(services.AuditEntityDonation, "id"), handlers.UploadDonationReceipt),
+			GET("/:id/receipt.pdf", handlers.GetDonationReceiptPDF),
+			// Audited by the service
+			POST("/:id/void", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), handlers.VoidDonationReceipt),
+			PUT("/:id", middleware.AuditTrail(services.AuditEntityDonation, "id"), handlers.UpdateDonation),
+			DELETE("/:id", middleware.AuditTrail(services.AuditEntityDonation, "id"), handlers.DeleteDonation),
+			POST("/:id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityDonation, "id"), handlers.RestoreDonation),
+		},
+	})
 }
 
@@ -3,34 +3,69 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupEventRoutes configures event CRUD routes
 func SetupEventRoutes(r *gin.RouterGroup) {
-	events := r.Group("/events")
-	events.Use(middleware.AuthMiddleware())
-	{
-		events.POST("", handlers.CreateEventHandler)
-		events.GET("", handlers.GetAllEventsHandler)
-		events.GET("/search", handlers.SearchEventsHandler)
-
-		// Event-specific routes (must be before /:event_id to avoid conflicts)
-		events.GET("/:event_id/specialguests", handlers.GetSpecialGuestByEventID)
-		events.GET("/:event_id/volunteers", handlers.GetVolunteerByEventID)
-		events.GET("/:event_id/donations", handlers.GetDonationsByEvent)
-		events.GET("/:event_id/promotion-materials", handlers.GetPromotionMaterialDetailsByEventIDHandler)
-
-		events.GET("/:event_id", handlers.GetEventByIdHandler)
-		events.GET("/:event_id/download", handlers.DownloadEventHandler)
-		events.PUT("/:event_id", handlers.UpdateEventHandler)
-		events.DELETE("/:event_id", handlers.DeleteEventHandler)
-		events.PATCH("/:event_id/status", handlers.UpdateEventStatusHandler)
-
-		// Draft routes
-		events.POST("/draft", handlers.SaveDraftHandler)
-		events.GET("/draft/latest", handlers.GetLatestDraftByUserHandler)
-		events.GET("/draft/:draftId", handlers.GetDraftHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/events",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeEvent, "event_id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityEvent, ""), handlers.CreateEventHandler),
+			POST("/full", middleware.AuditTrail(services.AuditEntityEvent, ""), handlers.CreateFullEventHandler),
+			GET("", middleware.LatencySLO(sloList), handlers.GetAllEventsHandler),
+			GET("/search", handlers.SearchEventsHandler),
+			GET("/pending-approval", handlers.GetPendingApprovalEventsHandler),
+			GET("/export", middleware.Sheddable(), handlers.ExportEventsHandler),
+			GET("/calendar", handlers.GetEventCalendarHandler),
+
+			// Event-specific routes (must be before /:event_id to avoid conflicts)
+			GET("/:event_id/specialguests", handlers.GetSpecialGuestByEventID),
+			GET("/:event_id/volunteers", handlers.GetVolunteerByEventID),
+			GET("/:event_id/donations", handlers.GetDonationsByEvent),
+			GET("/:event_id/promotion-materials", handlers.GetPromotionMaterialDetailsByEventIDHandler),
+
+			// Event media gallery
+			GET("/:event_id/media", middleware.LatencySLO(sloGallery), handlers.ListEventMediaHandler),
+			POST("/:event_id/media", middleware.AuditTrail(services.AuditEntityEventMedia, ""), handlers.CreateEventMediaItemHandler),
+			POST("/:event_id/media/reorder", handlers.ReorderEventMediaHandler),
+			GET("/:event_id/media/:media_id", handlers.GetEventMediaItemHandler),
+			PUT("/:event_id/media/:media_id", middleware.AuditTrail(services.AuditEntityEventMedia, "media_id"), handlers.UpdateEventMediaItemHandler),
+			DELETE("/:event_id/media/:media_id", middleware.AuditTrail(services.AuditEntityEventMedia, "media_id"), handlers.DeleteEventMediaItemHandler),
+
+			GET("/:event_id", handlers.GetEventByIdHandler),
+			GET("/:event_id/download", handlers.DownloadEventHandler),
+			GET("/:event_id/export", middleware.RequireRoles(models.RoleAdmin), middleware.Sheddable(), handlers.ExportEventArchiveHandler),
+			GET("/:event_id/report.pdf", middleware.Sheddable(), handlers.GetEventReportPDFHandler),
+			POST("/:event_id/report-jobs", handlers.QueueEventReportPDFHandler),
+			GET("/:event_id/share-text", handlers.GetEventShareTextHandler),
+			PUT("/:event_id", middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.UpdateEventHandler),
+			DELETE("/:event_id", middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.DeleteEventHandler),
+			POST("/:event_id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.RestoreEventHandler),
+			PATCH("/:event_id/status", middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.UpdateEventStatusHandler),
+			POST("/:event_id/transitions", middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.TransitionEventStatusHandler),
+			GET("/:event_id/status-history", handlers.GetEventStatusHistoryHandler),
+
+			// Recurring series (e.g. weekly satsangs), expanded on the calendar
+			GET("/:event_id/recurrence", handlers.GetEventRecurrenceHandler),
+			PUT("/:event_id/recurrence", handlers.SetEventRecurrenceHandler),
+			DELETE("/:event_id/recurrence", handlers.DeleteEventRecurrenceHandler),
+
+			// Daily attendance by age band, totaled into the event's beneficiary figures
+			GET("/:event_id/attendance", handlers.GetEventAttendanceHandler),
+			PUT("/:event_id/attendance/:day", middleware.AuditTrail(services.AuditEntityEvent, "event_id"), handlers.SetEventAttendanceDayHandler),
+			DELETE("/:event_id/attendance/:day", handlers.DeleteEventAttendanceDayHandler),
+
+			// Draft routes
+			GET("/draft", handlers.ListDraftsHandler),
+			POST("/draft", handlers.SaveDraftHandler),
+			GET("/draft/latest", handlers.GetLatestDraftByUserHandler),
+			GET("/draft/:draftId", handlers.GetDraftHandler),
+			POST("/draft/:draftId/convert", handlers.ConvertDraftHandler),
+		},
+	})
 }
 
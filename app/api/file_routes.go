@@ -8,14 +8,33 @@ import (
 
 // SetupFileRoutes configures file upload/download routes
 func SetupFileRoutes(r *gin.RouterGroup) {
-	files := r.Group("/files")
-	files.Use(middleware.AuthMiddleware())
-	{
-		files.POST("/upload", handlers.UploadFileHandler)
-		files.POST("/upload-multiple", handlers.UploadMultipleFilesHandler)
-		files.POST("/upload-branch", handlers.UploadBranchFilesHandler)
-		files.GET("/:media_id/download", handlers.DownloadFileHandler)
-		files.DELETE("/:media_id", handlers.DeleteFileHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/files",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			POST("/upload", handlers.UploadFileHandler),
+			POST("/upload-multiple", handlers.UploadMultipleFilesHandler),
+			POST("/upload-branch", handlers.UploadBranchFilesHandler),
+			GET("/:media_id/download", handlers.DownloadFileHandler),
+			DELETE("/:media_id", handlers.DeleteFileHandler),
+		},
+	}, RouteGroup{
+		// Resumable (chunked) uploads of large files
+		Prefix:     "/files/uploads",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			POST("", handlers.CreateUploadSessionHandler),
+			GET("/:upload_id", handlers.GetUploadSessionHandler),
+			PATCH("/:upload_id", handlers.UploadChunkHandler),
+			POST("/:upload_id/complete", handlers.CompleteUploadSessionHandler),
+			DELETE("/:upload_id", handlers.AbortUploadSessionHandler),
+		},
+	}, RouteGroup{
+		// Signed links of the local storage backend (no login, the link is the credential)
+		Prefix: "/files/local",
+		Routes: []Route{
+			GET("/*key", handlers.ServeLocalFileHandler),
+		},
+	})
 }
 
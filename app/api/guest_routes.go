@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupGuestRoutes configures special guest master record routes
+func SetupGuestRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/guests",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("", handlers.SearchGuestsHandler),
+			GET("/follow-ups", handlers.GetGuestFollowUpsHandler),
+			GET("/:id", handlers.GetGuestHandler),
+			PUT("/:id", middleware.RequireRoles(models.RoleAdmin, models.RoleManager), middleware.AuditTrail(services.AuditEntityGuest, "id"), handlers.UpdateGuestHandler),
+		},
+	})
+}
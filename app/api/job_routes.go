@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupJobRoutes configures background job status routes, polled by the frontend
+func SetupJobRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/jobs",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("", handlers.GetMyJobsHandler),
+			GET("/:id", handlers.GetJobHandler),
+		},
+	})
+}
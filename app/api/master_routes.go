@@ -8,28 +8,32 @@ import (
 
 // SetupMasterRoutes configures master data routes for dropdowns
 func SetupMasterRoutes(r *gin.RouterGroup) {
-	master := r.Group("")
-	master.Use(middleware.AuthMiddleware())
-	{
-		master.GET("/event-types", handlers.GetAllEventTypesHandler)
-		master.GET("/event-categories", handlers.GetAllEventCategoriesHandler)
-		master.GET("/countries", handlers.GetAllCountriesHandler)
-		master.GET("/states", handlers.GetAllStatesHandler)
-		master.GET("/countries/:country_id/states", handlers.GetStatesByCountryHandler)
-		master.GET("/cities", handlers.GetAllCitiesHandler)
-		master.GET("/cities/by-state", handlers.GetCitiesByStateHandler)
-		master.GET("/districts", handlers.GetDistrictsHandler)
-		master.GET("/districts/all", handlers.GetAllDistrictsHandler)
-		master.GET("/promotion-material-types", handlers.GetAllPromotionMaterialTypesHandler)
-		master.GET("/coordinators", handlers.GetCoordinatorDropdownHandler)
-		master.GET("/orators", handlers.GetOratorDropdownHandler)
-		master.GET("/languages", handlers.GetAllLanguagesHandler)
-		master.GET("/seva-types", handlers.GetAllSevaTypesHandler)
-		master.GET("/event-sub-categories", handlers.GetAllEventSubCategoriesHandler)
-		master.GET("/event-sub-categories/by-category", handlers.GetEventSubCategoriesByCategoryHandler)
-		master.GET("/roles", handlers.GetAllRolesHandler)
-		master.GET("/themes", handlers.GetAllThemesHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("/event-types", handlers.GetAllEventTypesHandler),
+			GET("/event-categories", handlers.GetAllEventCategoriesHandler),
+			GET("/countries", handlers.GetAllCountriesHandler),
+			GET("/states", handlers.GetAllStatesHandler),
+			GET("/countries/:country_id/states", handlers.GetStatesByCountryHandler),
+			GET("/states/:state_id/districts", handlers.GetDistrictsByStateHandler),
+			GET("/cities", handlers.GetAllCitiesHandler),
+			GET("/cities/by-state", handlers.GetCitiesByStateHandler),
+			GET("/districts", handlers.GetDistrictsHandler),
+			GET("/districts/all", handlers.GetAllDistrictsHandler),
+			GET("/districts/:district_id/cities", handlers.GetCitiesByDistrictHandler),
+			GET("/promotion-material-types", handlers.GetAllPromotionMaterialTypesHandler),
+			GET("/coordinators", handlers.GetCoordinatorDropdownHandler),
+			GET("/orators", handlers.GetOratorDropdownHandler),
+			GET("/languages", handlers.GetAllLanguagesHandler),
+			GET("/seva-types", handlers.GetAllSevaTypesHandler),
+			GET("/event-sub-categories", handlers.GetAllEventSubCategoriesHandler),
+			GET("/event-sub-categories/by-category", handlers.GetEventSubCategoriesByCategoryHandler),
+			GET("/roles", handlers.GetAllRolesHandler),
+			GET("/themes", handlers.GetAllThemesHandler),
+		},
+	})
 }
 
 
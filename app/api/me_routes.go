@@ -0,0 +1,27 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMeRoutes configures routes acting on the signed-in user
+func SetupMeRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/me",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("/preferences", handlers.GetMyPreferencesHandler),
+			PUT("/preferences", handlers.UpdateMyPreferencesHandler),
+			GET("/approval-digest", handlers.GetMyApprovalDigestHandler),
+			GET("/token", handlers.GetMyTokenHandler),
+
+			// In-app notification center
+			GET("/notifications", handlers.GetMyNotificationsHandler),
+			GET("/notifications/unread-count", handlers.GetMyUnreadNotificationCountHandler),
+			POST("/notifications/read-all", handlers.MarkAllMyNotificationsReadHandler),
+			POST("/notifications/:id/read", handlers.MarkMyNotificationReadHandler),
+		},
+	})
+}
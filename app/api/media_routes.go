@@ -3,20 +3,24 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupMediaRoutes configures media CRUD routes
 func SetupMediaRoutes(r *gin.RouterGroup) {
-	media := r.Group("/event-media")
-	media.Use(middleware.AuthMiddleware())
-	{
-		media.POST("", handlers.CreateEventMediaHandler)
-		media.GET("", handlers.GetAllEventMediaHandler)
-		media.GET("/event/:event_id", handlers.GetEventMediaByEventIDHandler)
-		media.PUT("/:id", handlers.UpdateEventMediaHandler)
-		media.DELETE("/:id", handlers.DeleteEventMediaHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/event-media",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeEventMedia, "id"), middleware.RequireBranchScope(services.ScopeEvent, "event_id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityEventMedia, ""), handlers.CreateEventMediaHandler),
+			GET("", middleware.LatencySLO(sloGallery), handlers.GetAllEventMediaHandler),
+			GET("/search", handlers.SearchEventMediaHandler),
+			GET("/event/:event_id", middleware.LatencySLO(sloGallery), handlers.GetEventMediaByEventIDHandler),
+			PUT("/:id", middleware.AuditTrail(services.AuditEntityEventMedia, "id"), handlers.UpdateEventMediaHandler),
+			DELETE("/:id", middleware.AuditTrail(services.AuditEntityEventMedia, "id"), handlers.DeleteEventMediaHandler),
+		},
+	})
 }
 
 
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPersonRoutes configures person (unified people model) routes
+func SetupPersonRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/persons",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("", handlers.SearchPersonsHandler),
+			GET("/duplicates", middleware.RequireRoles(models.RoleAdmin), handlers.GetPersonDuplicatesHandler),
+			GET("/:id", handlers.GetPersonProfileHandler),
+			POST("/:id/merge", middleware.RequireRoles(models.RoleAdmin), handlers.MergePersonsHandler),
+		},
+	})
+}
@@ -3,20 +3,23 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupPromotionRoutes configures promotion material routes
 func SetupPromotionRoutes(r *gin.RouterGroup) {
-	promotion := r.Group("/promotion-material-details")
-	promotion.Use(middleware.AuthMiddleware())
-	{
-		promotion.POST("", handlers.CreatePromotionMaterialDetailsHandler)
-		promotion.GET("", handlers.GetAllPromotionMaterialDetailsHandler)
-		promotion.GET("/event/:event_id", handlers.GetPromotionMaterialDetailsByEventIDHandler)
-		promotion.PUT("/:id", handlers.UpdatePromotionMaterialDetailsHandler)
-		promotion.DELETE("/:id", handlers.DeletePromotionMaterialDetailsHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/promotion-material-details",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopePromotion, "id")},
+		Routes: []Route{
+			POST("", handlers.CreatePromotionMaterialDetailsHandler),
+			GET("", handlers.GetAllPromotionMaterialDetailsHandler),
+			GET("/event/:event_id", middleware.RequireBranchScope(services.ScopeEvent, "event_id"), handlers.GetPromotionMaterialDetailsByEventIDHandler),
+			PUT("/:id", handlers.UpdatePromotionMaterialDetailsHandler),
+			DELETE("/:id", handlers.DeletePromotionMaterialDetailsHandler),
+		},
+	})
 }
 
 
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPublicRoutes configures the unauthenticated website API under /public/api
+func SetupPublicRoutes(r *gin.Engine) {
+	registerRoutes(&r.RouterGroup, RouteGroup{
+		Prefix: "/public/api",
+		// The website API is shed in brown-out, see middleware.LoadShedding
+		Middleware: []gin.HandlerFunc{
+			middleware.Sheddable(),
+			middleware.RateLimiter(middleware.RateLimitConfig{
+				MaxRequests:   config.RateLimitPublicPerMinute,
+				Window:        time.Minute,
+				IdentifierKey: "ip",
+			}),
+		},
+		Routes: []Route{
+			GET("/branches/map", handlers.GetBranchMapHandler),
+			GET("/branches/:public_id", handlers.GetPublicBranchHandler),
+		},
+	})
+
+	// Content-addressed public assets; not rate limited, as they are immutable and meant to be
+	// served from browser and CDN caches
+	registerRoutes(&r.RouterGroup, RouteGroup{
+		Prefix: services.PublicAssetsRoute,
+		Routes: []Route{
+			GET("/:file", handlers.ServePublicAssetHandler),
+		},
+	})
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Latency budgets for routes annotated with middleware.LatencySLO
+const (
+	sloList    = 500 * time.Millisecond
+	sloGallery = time.Second // presigns a page of thumbnails
+	sloReport  = 2 * time.Second
+)
+
+// Route is a single endpoint in a route table
+type Route struct {
+	Method     string
+	Path       string
+	Middleware []gin.HandlerFunc // runs after the group's middleware
+	Handler    gin.HandlerFunc
+}
+
+// RouteGroup is a block of routes sharing a path prefix and middleware
+type RouteGroup struct {
+	Prefix     string
+	Middleware []gin.HandlerFunc
+	Routes     []Route
+}
+
+// RouteInfo describes a registered route, as listed by GET /api/routes
+type RouteInfo struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Handler    string   `json:"handler"`
+	Middleware []string `json:"middleware"`
+}
+
+// registeredRoutes is filled by registerRoutes and served by ListRoutesHandler
+var registeredRoutes []RouteInfo
+
+// GET, POST, PUT, PATCH and DELETE build table entries. The last function is the
+// handler; any functions before it are route-specific middleware.
+func GET(path string, handlers ...gin.HandlerFunc) Route {
+	return newRoute(http.MethodGet, path, handlers)
+}
+
+func POST(path string, handlers ...gin.HandlerFunc) Route {
+	return newRoute(http.MethodPost, path, handlers)
+}
+
+func PUT(path string, handlers ...gin.HandlerFunc) Route {
+	return newRoute(http.MethodPut, path, handlers)
+}
+
+func PATCH(path string, handlers ...gin.HandlerFunc) Route {
+	return newRoute(http.MethodPatch, path, handlers)
+}
+
+func DELETE(path string, handlers ...gin.HandlerFunc) Route {
+	return newRoute(http.MethodDelete, path, handlers)
+}
+
+func newRoute(method, path string, handlers []gin.HandlerFunc) Route {
+	if len(handlers) == 0 {
+		panic("route " + method + " " + path + " has no handler")
+	}
+	last := len(handlers) - 1
+	return Route{Method: method, Path: path, Middleware: handlers[:last], Handler: handlers[last]}
+}
+
+// registerRoutes mounts route groups on r and records them for introspection
+func registerRoutes(r *gin.RouterGroup, groups ...RouteGroup) {
+	for _, g := range groups {
+		group := r.Group(g.Prefix, g.Middleware...)
+		for _, route := range g.Routes {
+			chain := make([]gin.HandlerFunc, 0, len(route.Middleware)+1)
+			chain = append(append(chain, route.Middleware...), route.Handler)
+			group.Handle(route.Method, route.Path, chain...)
+
+			info := RouteInfo{
+				Method:     route.Method,
+				Path:       joinRoutePath(group.BasePath(), route.Path),
+				Handler:    handlerName(route.Handler),
+				Middleware: []string{},
+			}
+			for _, m := range append(append([]gin.HandlerFunc{}, g.Middleware...), route.Middleware...) {
+				info.Middleware = append(info.Middleware, handlerName(m))
+			}
+			registeredRoutes = append(registeredRoutes, info)
+		}
+	}
+}
+
+// MethodNotAllowedHandler answers requests for a known path with an unsupported method.
+// gin has already set the Allow header; OPTIONS requests get 204 with that header
+// (CORS preflights never get here, the CORS middleware answers them first).
+func MethodNotAllowedHandler(c *gin.Context) {
+	if c.Request.Method == http.MethodOptions {
+		allow := c.Writer.Header().Get("Allow")
+		c.Header("Allow", strings.TrimPrefix(allow+", "+http.MethodOptions, ", "))
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	utils.ErrorResponse(c, http.StatusMethodNotAllowed, "method "+c.Request.Method+" not allowed, allowed: "+c.Writer.Header().Get("Allow"))
+	c.Abort()
+}
+
+// ListRoutesHandler godoc
+// @Summary List API routes
+// @Description Returns every route registered through the route tables with its handler and middleware chain. Admin only, for debugging.
+// @Tags Routes
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Router /api/v1/routes [get]
+func ListRoutesHandler(c *gin.Context) {
+	routes := append([]RouteInfo(nil), registeredRoutes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	utils.OK(c, "", gin.H{"routes": routes, "total": len(routes)})
+}
+
+func joinRoutePath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	return path.Join(base, relative)
+}
+
+// handlerName turns a function into a short readable name, e.g.
+// "middleware.AuditTrail" or "handlers.GetAllEventsHandler"
+func handlerName(h gin.HandlerFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// Closures returned by middleware constructors are named "pkg.Constructor.func1"
+	if i := strings.Index(name, ".func"); i >= 0 {
+		name = name[:i]
+	}
+	// Method values are named "pkg.(*Type).Method-fm"
+	return strings.TrimSuffix(name, "-fm")
+}
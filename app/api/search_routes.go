@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSearchRoutes configures the unified search across events, branches, guests and volunteers
+func SetupSearchRoutes(r *gin.RouterGroup) {
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/search",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware()},
+		Routes: []Route{
+			GET("", middleware.LatencySLO(sloList), handlers.SearchHandler),
+		},
+	})
+}
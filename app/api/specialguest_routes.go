@@ -3,18 +3,24 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupSpecialGuestRoutes configures special guest routes
 func SetupSpecialGuestRoutes(r *gin.RouterGroup) {
-	specialguests := r.Group("/specialguests")
-	specialguests.Use(middleware.AuthMiddleware())
-	{
-		specialguests.POST("", handlers.CreateSpecialGuestHandler)
-		specialguests.GET("", handlers.GetAllSpecialGuestsHandler)
-		specialguests.PUT("/:id", middleware.ValidateSpecialGuestMiddleware(), handlers.UpdateSpecialGuestHandler)
-		specialguests.DELETE("/:id", middleware.ValidateSpecialGuestMiddleware(), handlers.DeleteSpecialGuestHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/specialguests",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeSpecialGuest, "id")},
+		Routes: []Route{
+			POST("", handlers.CreateSpecialGuestHandler),
+			GET("", handlers.GetAllSpecialGuestsHandler),
+			GET("/search", handlers.SearchSpecialGuestsHandler),
+			GET("/duplicates", handlers.GetSpecialGuestDuplicatesHandler),
+			PUT("/:id", middleware.ValidateSpecialGuestMiddleware(), handlers.UpdateSpecialGuestHandler),
+			DELETE("/:id", middleware.ValidateSpecialGuestMiddleware(), handlers.DeleteSpecialGuestHandler),
+			PATCH("/:id/follow-up", handlers.UpdateGuestFollowUpHandler),
+		},
+	})
 }
 
@@ -3,19 +3,28 @@ package api
 import (
 	"github.com/followCode/djjs-event-reporting-backend/app/handlers"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupVolunteerRoutes configures volunteer routes
 func SetupVolunteerRoutes(r *gin.RouterGroup) {
-	volunteers := r.Group("/volunteers")
-	volunteers.Use(middleware.AuthMiddleware())
-	{
-		volunteers.POST("", handlers.CreateVolunteerHandler)
-		volunteers.GET("", handlers.GetAllVolunteersHandler)
-		volunteers.GET("/search", handlers.SearchVolunteersHandler)
-		volunteers.PUT("/:id", middleware.ValidateVolunteerMiddleware(), handlers.UpdateVolunteerHandler)
-		volunteers.DELETE("/:id", middleware.ValidateVolunteerMiddleware(), handlers.DeleteVolunteerHandler)
-	}
+	registerRoutes(r, RouteGroup{
+		Prefix:     "/volunteers",
+		Middleware: []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequireBranchScope(services.ScopeVolunteer, "id")},
+		Routes: []Route{
+			POST("", middleware.AuditTrail(services.AuditEntityVolunteer, ""), handlers.CreateVolunteerHandler),
+			GET("", handlers.GetAllVolunteersHandler),
+			GET("/search", handlers.SearchVolunteersHandler),
+			GET("/duplicates", handlers.GetVolunteerDuplicatesHandler),
+			GET("/:id/history", handlers.GetVolunteerHistoryHandler),
+			// Audited by the service
+			POST("/:id/merge", middleware.RequireRoles(models.RoleAdmin), handlers.MergeVolunteersHandler),
+			PUT("/:id", middleware.ValidateVolunteerMiddleware(), middleware.AuditTrail(services.AuditEntityVolunteer, "id"), handlers.UpdateVolunteerHandler),
+			DELETE("/:id", middleware.ValidateVolunteerMiddleware(), middleware.AuditTrail(services.AuditEntityVolunteer, "id"), handlers.DeleteVolunteerHandler),
+			POST("/:id/restore", middleware.RequireRoles(models.RoleAdmin), middleware.AuditTrail(services.AuditEntityVolunteer, "id"), handlers.RestoreVolunteerHandler),
+		},
+	})
 }
 
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// profile is one backend the CLI talks to and the session signed in to it
+type profile struct {
+	URL          string `json:"url"`
+	Email        string `json:"email,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// profileStore is the profiles file. It holds session tokens, so it is only readable by
+// the operator.
+type profileStore struct {
+	Profiles map[string]*profile `json:"profiles"`
+	path     string
+}
+
+func profilesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "djjsctl", "profiles.json"), nil
+}
+
+func loadProfiles() (*profileStore, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return nil, err
+	}
+	store := &profileStore{Profiles: map[string]*profile{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("invalid profiles file %s: %w", path, err)
+	}
+	if store.Profiles == nil {
+		store.Profiles = map[string]*profile{}
+	}
+	return store, nil
+}
+
+func (s *profileStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// profileFlag adds -profile to the flags of a command
+func profileFlag(fs *flag.FlagSet) *string {
+	name := os.Getenv("DJJSCTL_PROFILE")
+	if name == "" {
+		name = "default"
+	}
+	return fs.String("profile", name, "profile to use (default $DJJSCTL_PROFILE or \"default\")")
+}
+
+// apiResponse is the envelope of every JSON response, see app/utils/response.go
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Code    string          `json:"code"`
+}
+
+// apiError is a failed API call
+type apiError struct {
+	Status int
+	Code   string
+	Msg    string
+}
+
+func (e *apiError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%d: %s", e.Status, e.Msg)
+}
+
+// client calls the API of a profile with its access token
+type client struct {
+	name    string
+	profile *profile
+	store   *profileStore
+	http    *http.Client
+}
+
+// newClient returns the client of a signed-in profile
+func newClient(name string) (*client, error) {
+	store, err := loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := store.Profiles[name]
+	if !ok || p.AccessToken == "" {
+		return nil, fmt.Errorf("not signed in to profile %q, run 'djjsctl login -profile %s'", name, name)
+	}
+	return &client{name: name, profile: p, store: store, http: &http.Client{Timeout: time.Minute}}, nil
+}
+
+// call sends a request to path (below /api/v1) and decodes the data of the response into
+// out, if given. An expired access token is refreshed once with the stored refresh token.
+func (c *client) call(method, path string, body, out interface{}) error {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.profile.RefreshToken != "" {
+		resp.Body.Close()
+		if err := c.refresh(); err != nil {
+			return err
+		}
+		if resp, err = c.send(method, path, body); err != nil {
+			return err
+		}
+	}
+	return decodeResponse(resp, out)
+}
+
+func (c *client) send(method, path string, body interface{}) (*http.Response, error) {
+	req, err := newRequest(c.profile.URL, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.profile.AccessToken)
+	return c.http.Do(req)
+}
+
+// refresh exchanges the refresh token (the refresh_token cookie of the web app) for a new
+// access token. Refresh tokens rotate, so the new one is stored as well.
+func (c *client) refresh() error {
+	req, err := newRequest(c.profile.URL, http.MethodPost, "/auth/refresh", nil)
+	if err != nil {
+		return err
+	}
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: c.profile.RefreshToken})
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	var refreshed struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := decodeResponse(resp, &refreshed); err != nil {
+		return fmt.Errorf("session expired, run 'djjsctl login -profile %s' (%v)", c.name, err)
+	}
+	c.profile.AccessToken = refreshed.AccessToken
+	if token := refreshTokenCookie(resp); token != "" {
+		c.profile.RefreshToken = token
+	}
+	return c.store.save()
+}
+
+func newRequest(baseURL, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(baseURL, "/")+"/api/v1"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "djjsctl")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	var envelope apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return &apiError{Status: resp.StatusCode, Msg: "unexpected response: " + err.Error()}
+	}
+	if resp.StatusCode >= 300 || !envelope.Success {
+		return &apiError{Status: resp.StatusCode, Code: envelope.Code, Msg: envelope.Error}
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+func refreshTokenCookie(resp *http.Response) string {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "refresh_token" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// stdin is shared by the prompts so input buffered by one is not lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt reads a line from stdin; secret input is not echoed where stty is available
+func prompt(label string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	if secret {
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = os.Stdin
+		if stty.Run() == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = os.Stdin
+				_ = restore.Run()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	name := profileFlag(fs)
+	url := fs.String("url", "", "base URL of the backend, e.g. https://api.example.org (default: the profile's)")
+	email := fs.String("email", "", "email of an admin account (default: the profile's)")
+	fs.Parse(args)
+
+	store, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	p := store.Profiles[*name]
+	if p == nil {
+		p = &profile{}
+	}
+	if *url != "" {
+		p.URL = *url
+	}
+	if *email != "" {
+		p.Email = *email
+	}
+	if p.URL == "" || p.Email == "" {
+		return errors.New("-url and -email are required for a new profile")
+	}
+
+	password := os.Getenv("DJJSCTL_PASSWORD")
+	if password == "" {
+		if password, err = prompt("Password for "+p.Email+": ", true); err != nil {
+			return err
+		}
+	}
+
+	httpClient := &http.Client{Timeout: time.Minute}
+	var login struct {
+		AccessToken       string `json:"accessToken"`
+		TwoFactorRequired bool   `json:"twoFactorRequired"`
+		TwoFactorToken    string `json:"twoFactorToken"`
+	}
+	resp, err := postJSON(httpClient, p.URL, "/auth/login", map[string]string{"email": p.Email, "password": password})
+	if err != nil {
+		return err
+	}
+	refreshToken := refreshTokenCookie(resp)
+	if err := decodeResponse(resp, &login); err != nil {
+		return err
+	}
+
+	if login.TwoFactorRequired {
+		code, err := prompt("Two-factor code (or recovery code): ", false)
+		if err != nil {
+			return err
+		}
+		resp, err := postJSON(httpClient, p.URL, "/auth/login/2fa", map[string]string{"twoFactorToken": login.TwoFactorToken, "code": code})
+		if err != nil {
+			return err
+		}
+		refreshToken = refreshTokenCookie(resp)
+		if err := decodeResponse(resp, &login); err != nil {
+			return err
+		}
+	}
+
+	p.AccessToken = login.AccessToken
+	p.RefreshToken = refreshToken
+	store.Profiles[*name] = p
+	if err := store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Signed in to %s as %s (profile %q)\n", p.URL, p.Email, *name)
+	return nil
+}
+
+func postJSON(httpClient *http.Client, baseURL, path string, body interface{}) (*http.Response, error) {
+	req, err := newRequest(baseURL, http.MethodPost, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+func runLogout(args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	name := profileFlag(fs)
+	fs.Parse(args)
+
+	c, err := newClient(*name)
+	if err != nil {
+		return err
+	}
+	// Revokes the session on the server; the stored tokens are dropped either way
+	req, err := newRequest(c.profile.URL, http.MethodPost, "/auth/logout", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.profile.AccessToken)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: c.profile.RefreshToken})
+	if resp, err := c.http.Do(req); err == nil {
+		resp.Body.Close()
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: could not revoke the session: %v\n", err)
+	}
+
+	c.profile.AccessToken = ""
+	c.profile.RefreshToken = ""
+	if err := c.store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Signed out of profile %q\n", *name)
+	return nil
+}
+
+func runProfiles(args []string) error {
+	fs := flag.NewFlagSet("profiles", flag.ExitOnError)
+	fs.Parse(args)
+
+	store, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(store.Profiles))
+	for name := range store.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tURL\tEMAIL\tSIGNED IN")
+	for _, name := range names {
+		p := store.Profiles[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", name, p.URL, p.Email, p.AccessToken != "")
+	}
+	return w.Flush()
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/followCode/djjs-event-reporting-backend/migrations"
+)
+
+// jobsPageSize is the largest page GET /admin/jobs returns
+const jobsPageSize = 200
+
+func runCreateAdminUser(args []string) error {
+	fs := flag.NewFlagSet("create-admin-user", flag.ExitOnError)
+	name := profileFlag(fs)
+	userName := fs.String("name", "", "full name of the new admin")
+	email := fs.String("email", "", "email of the new admin")
+	contact := fs.String("contact", "", "contact number (optional)")
+	fs.Parse(args)
+	if *userName == "" || *email == "" {
+		return errors.New("-name and -email are required")
+	}
+
+	c, err := newClient(*name)
+	if err != nil {
+		return err
+	}
+	var created models.CreateUserResponse
+	err = c.call(http.MethodPost, "/users", map[string]interface{}{
+		"name":           *userName,
+		"email":          *email,
+		"contact_number": *contact,
+		"role_id":        models.RoleAdmin,
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created admin user %d (%s)\n", created.User.ID, created.User.Email)
+	if created.EmailSent {
+		fmt.Println("The temporary password was emailed to the user.")
+	} else {
+		fmt.Println(created.Message)
+	}
+	return nil
+}
+
+// runRotateJWTSecret prepares a rotation of JWT_SECRET, which lives in the deployment's
+// environment rather than behind the API. During the rotation JWT_SECRET_PREVIOUS keeps
+// access tokens signed with the old secret valid; sessions (refresh tokens) do not depend on
+// the secret, so nobody is signed out.
+func runRotateJWTSecret(args []string) error {
+	fs := flag.NewFlagSet("rotate-jwt-secret", flag.ExitOnError)
+	size := fs.Int("bytes", 48, "random bytes in the new secret")
+	fs.Parse(args)
+	if *size < 32 {
+		return errors.New("-bytes must be at least 32")
+	}
+
+	secret := make([]byte, *size)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	// The secret alone goes to stdout, so it can be piped into a secret store
+	fmt.Println(base64.RawURLEncoding.EncodeToString(secret))
+
+	fmt.Fprintln(os.Stderr, `
+To rotate without signing anyone out:
+  1. Set JWT_SECRET_PREVIOUS to the current JWT_SECRET, and JWT_SECRET to the new secret above.
+  2. Restart every API instance. Access tokens signed with either secret are accepted and
+     clients pick up tokens signed with the new one at their next refresh.
+  3. After the access token lifetime (JWT_TTL, default 10m) remove JWT_SECRET_PREVIOUS and
+     restart again.
+Links signed for local storage use JWT_SECRET unless STORAGE_SIGNING_KEY is set; links
+issued before step 1 stop working.`)
+	return nil
+}
+
+// runDBMigrate applies the versioned migrations (see package migrations) straight to the
+// database, for deploys that run them before rolling out instances started with
+// MIGRATE_ON_STARTUP=false. It reads the database settings the API reads (environment and
+// CONFIG_FILE).
+func runDBMigrate(args []string) error {
+	fs := flag.NewFlagSet("db-migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "list the migrations and whether they were applied, without applying any")
+	timeout := fs.Duration("timeout", 30*time.Minute, "give up after this long")
+	fs.Parse(args)
+
+	cfg, err := config.Read()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := config.OpenDB(ctx, cfg.Database); err != nil {
+		return err
+	}
+	defer config.Close()
+	db, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	if !*status {
+		results, err := migrations.Up(ctx, db)
+		for _, result := range results {
+			if result.Error == nil {
+				fmt.Printf("Applied %s in %s\n", result.Source.Path, result.Duration.Round(time.Millisecond))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	}
+
+	statuses, err := migrations.Status(ctx, db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.Source.Path, s.State, appliedAt)
+	}
+	return w.Flush()
+}
+
+// migrationStatus is the part of services.MigrationStatus the CLI shows
+type migrationStatus struct {
+	Name        string          `json:"name"`
+	Table       string          `json:"table"`
+	OldColumn   string          `json:"old_column"`
+	NewColumn   string          `json:"new_column"`
+	Phases      map[string]bool `json:"phases"`
+	PendingRows int64           `json:"pending_rows"`
+	Backfill    struct {
+		Running bool   `json:"running"`
+		Updated int64  `json:"updated"`
+		Error   string `json:"error"`
+	} `json:"backfill"`
+}
+
+// migrationPhases in the order they are reached (services.MigrationPhase*)
+var migrationPhases = []string{"expand", "dual_write", "backfill", "read_new", "contract"}
+
+// runMigrations lists the expand/contract migrations, or runs one step of one of them
+func runMigrations(args []string) error {
+	fs := flag.NewFlagSet("run-migrations", flag.ExitOnError)
+	name := profileFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: djjsctl run-migrations [flags] [<migration> <step>]")
+		fmt.Fprintln(os.Stderr, "steps: expand, dual-write, backfill, cutover, rollback, contract (irreversible)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	c, err := newClient(*name)
+	if err != nil {
+		return err
+	}
+
+	switch fs.NArg() {
+	case 0:
+		var statuses []migrationStatus
+		if err := c.call(http.MethodGet, "/admin/migrations", nil, &statuses); err != nil {
+			return err
+		}
+		return printMigrations(statuses...)
+	case 2:
+		migration, step := fs.Arg(0), fs.Arg(1)
+		var status migrationStatus
+		path := "/admin/migrations/" + url.PathEscape(migration) + "/" + url.PathEscape(step)
+		if err := c.call(http.MethodPost, path, nil, &status); err != nil {
+			return err
+		}
+		fmt.Printf("Ran %s of %s\n", step, migration)
+		return printMigrations(status)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func printMigrations(statuses ...migrationStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tCOLUMNS\tPHASES\tPENDING ROWS\tBACKFILL")
+	for _, s := range statuses {
+		var phases []string
+		for _, phase := range migrationPhases {
+			if s.Phases[phase] {
+				phases = append(phases, phase)
+			}
+		}
+		backfill := "-"
+		switch {
+		case s.Backfill.Running:
+			backfill = fmt.Sprintf("running (%d updated)", s.Backfill.Updated)
+		case s.Backfill.Error != "":
+			backfill = "failed: " + s.Backfill.Error
+		case s.Backfill.Updated > 0:
+			backfill = fmt.Sprintf("done (%d updated)", s.Backfill.Updated)
+		}
+		fmt.Fprintf(w, "%s\t%s.%s -> %s\t%s\t%d\t%s\n", s.Name, s.Table, s.OldColumn, s.NewColumn,
+			strings.Join(phases, ","), s.PendingRows, backfill)
+	}
+	return w.Flush()
+}
+
+func runTriggerGC(args []string) error {
+	fs := flag.NewFlagSet("trigger-gc", flag.ExitOnError)
+	name := profileFlag(fs)
+	wait := fs.Bool("wait", false, "wait for the garbage collection to finish and print what it deleted")
+	fs.Parse(args)
+
+	c, err := newClient(*name)
+	if err != nil {
+		return err
+	}
+	var job models.Job
+	if err := c.call(http.MethodPost, "/admin/gc", nil, &job); err != nil {
+		return err
+	}
+	fmt.Printf("Garbage collection queued as job %d\n", job.ID)
+	if !*wait {
+		return nil
+	}
+
+	for job.Status == models.JobStatusQueued || job.Status == models.JobStatusRunning {
+		time.Sleep(2 * time.Second)
+		if err := c.call(http.MethodGet, "/jobs/"+strconv.FormatUint(uint64(job.ID), 10), nil, &job); err != nil {
+			return err
+		}
+	}
+	if job.Status == models.JobStatusFailed {
+		return fmt.Errorf("job %d failed: %s", job.ID, job.Error)
+	}
+	result, err := json.MarshalIndent(job.Result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(result))
+	return nil
+}
+
+// runRequeueFailedJobs retries every failed job (of a type), like POST /admin/jobs/:id/retry
+// for each of them
+func runRequeueFailedJobs(args []string) error {
+	fs := flag.NewFlagSet("requeue-failed-jobs", flag.ExitOnError)
+	name := profileFlag(fs)
+	jobType := fs.String("type", "", "only jobs of this type, e.g. thumbnails or email")
+	dryRun := fs.Bool("dry-run", false, "list the failed jobs without requeueing them")
+	fs.Parse(args)
+
+	c, err := newClient(*name)
+	if err != nil {
+		return err
+	}
+
+	// Collect first: requeued jobs leave the failed list and would shift the pages
+	var failed []models.Job
+	for offset := 0; ; offset += jobsPageSize {
+		query := url.Values{"status": {models.JobStatusFailed}, "limit": {strconv.Itoa(jobsPageSize)}, "offset": {strconv.Itoa(offset)}}
+		if *jobType != "" {
+			query.Set("type", *jobType)
+		}
+		var page struct {
+			Data  []models.Job `json:"data"`
+			Total int64        `json:"total"`
+		}
+		if err := c.call(http.MethodGet, "/admin/jobs?"+query.Encode(), nil, &page); err != nil {
+			return err
+		}
+		failed = append(failed, page.Data...)
+		if len(page.Data) < jobsPageSize || int64(len(failed)) >= page.Total {
+			break
+		}
+	}
+
+	requeued, skipped := 0, 0
+	for _, job := range failed {
+		if *dryRun {
+			fmt.Printf("job %d (%s): %s\n", job.ID, job.Type, job.Error)
+			continue
+		}
+		err := c.call(http.MethodPost, "/admin/jobs/"+strconv.FormatUint(uint64(job.ID), 10)+"/retry", nil, nil)
+		var apiErr *apiError
+		switch {
+		case err == nil:
+			requeued++
+		case errors.As(err, &apiErr) && (apiErr.Status == http.StatusConflict || apiErr.Status == http.StatusNotFound):
+			// Retried or cleaned up by someone else in the meantime
+			skipped++
+		default:
+			return fmt.Errorf("job %d: %w (%d requeued so far)", job.ID, err, requeued)
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d failed jobs\n", len(failed))
+		return nil
+	}
+	fmt.Printf("Requeued %d failed jobs", requeued)
+	if skipped > 0 {
+		fmt.Printf(" (%d no longer failed)", skipped)
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,69 @@
+// Command djjsctl runs common admin tasks against a running backend through its admin API,
+// so operators do not have to craft curl commands against production.
+//
+// Usage:
+//
+//	go run ./app/djjsctl login -profile prod -url https://api.example.org -email ops@example.org
+//	go run ./app/djjsctl create-admin-user -profile prod -name "Jane Doe" -email jane@example.org
+//	go run ./app/djjsctl run-migrations -profile prod [<migration> <step>]
+//	go run ./app/djjsctl trigger-gc -profile prod [-wait]
+//	go run ./app/djjsctl requeue-failed-jobs -profile prod [-type thumbnails] [-dry-run]
+//	go run ./app/djjsctl rotate-jwt-secret
+//	go run ./app/djjsctl db-migrate [-status]
+//
+// login signs in once per profile (an admin account, with its two-factor code if enabled) and
+// stores the API URL and the session in the profiles file, $XDG_CONFIG_HOME/djjsctl/profiles.json
+// or its platform equivalent; later commands refresh the access token on their own. -profile
+// defaults to $DJJSCTL_PROFILE, then "default". The local commands need no profile; db-migrate
+// connects to the database with the API's settings (environment and CONFIG_FILE).
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "login":
+		err = runLogin(os.Args[2:])
+	case "logout":
+		err = runLogout(os.Args[2:])
+	case "profiles":
+		err = runProfiles(os.Args[2:])
+	case "create-admin-user":
+		err = runCreateAdminUser(os.Args[2:])
+	case "rotate-jwt-secret":
+		err = runRotateJWTSecret(os.Args[2:])
+	case "db-migrate":
+		err = runDBMigrate(os.Args[2:])
+	case "run-migrations":
+		err = runMigrations(os.Args[2:])
+	case "trigger-gc":
+		err = runTriggerGC(os.Args[2:])
+	case "requeue-failed-jobs":
+		err = runRequeueFailedJobs(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalf("djjsctl %s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: djjsctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "profiles:   login, logout, profiles")
+	fmt.Fprintln(os.Stderr, "admin:      create-admin-user, run-migrations, trigger-gc, requeue-failed-jobs")
+	fmt.Fprintln(os.Stderr, "local:      rotate-jwt-secret, db-migrate")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "run 'djjsctl <command> -h' for the flags of a command")
+	os.Exit(2)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPIUsageHandler godoc
+// @Summary API usage per user, client and endpoint
+// @Description Returns request counts, error counts (5xx) and latencies from the daily usage rollup: totals, per day, and the busiest users, clients and endpoints. Clients identify themselves with the X-Client-ID header (the User-Agent product is used otherwise). Counters are flushed every minute. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default: 6 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default: today, UTC)"
+// @Param user_id query int false "Only this user (0 for unauthenticated requests)"
+// @Param client query string false "Only this client ID"
+// @Param limit query int false "Rows per breakdown (default 20, max 100)"
+// @Success 200 {object} utils.Response{data=services.APIUsageReport}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/usage [get]
+func GetAPIUsageHandler(c *gin.Context) {
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid to date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -6)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid from date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		utils.BadRequest(c, "from must not be after to")
+		return
+	}
+
+	filter := services.APIUsageFilter{From: from, To: to, Client: c.Query("client")}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid user_id")
+			return
+		}
+		id := uint(userID)
+		filter.UserID = &id
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	report, err := services.GetAPIUsage(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", report)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportBranchArchiveHandler godoc
+// @Summary Export a branch as JSON for archival
+// @Description Returns a complete nested snapshot of a branch: the branch with its infrastructure and members, branch media, coordinator handovers, change requests, all events with their child records, and the same for every child branch at any depth. Soft-deleted records are included. media_manifest lists every file with a presigned URL valid for 24 hours. Admin only.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Success 200 {object} utils.Response{data=services.ArchiveExport}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/export [get]
+func ExportBranchArchiveHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+
+	export, err := services.ExportBranchArchive(c.Request.Context(), uint(branchID))
+	if err != nil {
+		respondArchiveExportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=branch_%d_export_%s.json", branchID, time.Now().Format("20060102_150405")))
+	utils.OK(c, "", export)
+}
+
+// ExportEventArchiveHandler godoc
+// @Summary Export an event as JSON for archival
+// @Description Returns a complete nested snapshot of an event with its recurrence, status history, special guests, volunteers, donations, promotion materials and media. media_manifest lists every file with a presigned URL valid for 24 hours. Admin only.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response{data=services.ArchiveExport}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/export [get]
+func ExportEventArchiveHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid event ID")
+		return
+	}
+
+	export, err := services.ExportEventArchive(c.Request.Context(), uint(eventID))
+	if err != nil {
+		respondArchiveExportError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=event_%d_export_%s.json", eventID, time.Now().Format("20060102_150405")))
+	utils.OK(c, "", export)
+}
+
+func respondArchiveExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBranchNotFound), errors.Is(err, services.ErrEventNotFound):
+		utils.NotFound(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
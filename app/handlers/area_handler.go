@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"net/http"
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -17,30 +17,29 @@ import (
 // @Accept json
 // @Produce json
 // @Param area body models.Area true "Area payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/areas [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/areas [post]
 func CreateAreaHandler(c *gin.Context) {
 	var area models.Area
 	if err := c.ShouldBindJSON(&area); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateAreaInput(area.BranchID, area.DistrictID.String(), area.AreaName, area.AreaCoverage); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.CreateArea(&area); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Area created successfully",
-		"area":    area,
+	utils.Created(c, "Area created successfully", gin.H{
+		"area": area,
 	})
 }
 
@@ -49,16 +48,16 @@ func CreateAreaHandler(c *gin.Context) {
 // @Tags Areas
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Area
-// @Failure 500 {object} map[string]string
-// @Router /api/areas [get]
+// @Success 200 {object} utils.Response{data=[]models.Area}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/areas [get]
 func GetAllAreasHandler(c *gin.Context) {
 	areas, err := services.GetAllAreas()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, areas)
+	utils.OK(c, "", areas)
 }
 
 // GetAreaSearchHandler godoc
@@ -68,19 +67,19 @@ func GetAllAreasHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param area_name query string false "Area Name"
-// @Success 200 {array} models.Area
-// @Failure 404 {object} map[string]string
-// @Router /api/areas/search [get]
+// @Success 200 {object} utils.Response{data=[]models.Area}
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/areas/search [get]
 func GetAreaSearchHandler(c *gin.Context) {
 	areaName := c.Query("area_name")
 
 	areas, err := services.GetAreaSearch(areaName)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, areas)
+	utils.OK(c, "", areas)
 }
 
 // UpdateAreaHandler godoc
@@ -91,35 +90,35 @@ func GetAreaSearchHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Area ID"
 // @Param area body map[string]interface{} true "Updated fields"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/areas/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/areas/{id} [put]
 func UpdateAreaHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	areaID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid area ID"})
+		utils.BadRequest(c, "Invalid area ID")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateAreaUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.UpdateArea(uint(areaID), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Area updated successfully"})
+	utils.OK(c, "Area updated successfully", nil)
 }
 
 // DeleteAreaHandler godoc
@@ -128,22 +127,22 @@ func UpdateAreaHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Area ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/areas/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/areas/{id} [delete]
 func DeleteAreaHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	areaID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid area ID"})
+		utils.BadRequest(c, "Invalid area ID")
 		return
 	}
 
 	if err := services.DeleteArea(uint(areaID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Area deleted successfully"})
+	utils.OK(c, "Area deleted successfully", nil)
 }
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogsHandler godoc
+// @Summary List audit logs
+// @Description List create/update/delete audit entries with field-level diffs. Admin only.
+// @Tags AuditLogs
+// @Security ApiKeyAuth
+// @Produce json
+// @Produce application/x-ndjson
+// @Param entity_type query string false "Entity type (user, branch, child_branch, event, volunteer, donation, event_media, branch_media, feature_flag, person, webhook)"
+// @Param entity_id query int false "Entity ID"
+// @Param actor_id query int false "Actor user ID"
+// @Param action query string false "create, update or delete"
+// @Param from query string false "From timestamp (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "To timestamp (RFC3339 or YYYY-MM-DD)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Param format query string false "Set to ndjson to stream all matches as newline-delimited JSON (also via Accept: application/x-ndjson)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/audit-logs [get]
+func GetAuditLogsHandler(c *gin.Context) {
+	filter := services.AuditLogFilter{
+		EntityType: c.Query("entity_type"),
+		Action:     c.Query("action"),
+	}
+
+	if filter.EntityType != "" && !services.IsAuditedEntity(filter.EntityType) {
+		utils.BadRequest(c, "invalid entity_type")
+		return
+	}
+
+	for param, target := range map[string]*uint{
+		"entity_id": &filter.EntityID,
+		"actor_id":  &filter.ActorID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+param)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+
+	for param, target := range map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if value := c.Query(param); value != "" {
+			t, err := parseAuditTime(value)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+param+" (use RFC3339 or YYYY-MM-DD)")
+				return
+			}
+			*target = &t
+		}
+	}
+
+	// Stream every match as NDJSON instead of a single page
+	if utils.WantsNDJSON(c) {
+		roleID, _ := middleware.CurrentRoleID(c)
+		stream := utils.NewNDJSONWriter(c, !middleware.CanViewPII(roleID))
+		err := services.StreamAuditLogs(c.Request.Context(), filter, func(entry *models.AuditLog) error {
+			return stream.Write(entry)
+		})
+		if err != nil && c.Request.Context().Err() == nil {
+			stream.WriteError(err)
+			return
+		}
+		stream.Flush()
+		return
+	}
+
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	logs, total, err := services.GetAuditLogs(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", gin.H{
+		"data":  logs,
+		"total": total,
+	})
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// auditActor describes the current request for audit entries written by services
+func auditActor(c *gin.Context) services.AuditActor {
+	actor := services.AuditActor{
+		IP:     middleware.GetClientIP(c),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+	}
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		actor.UserID = &userID
+	}
+	if roleID, ok := middleware.CurrentRoleID(c); ok {
+		actor.RoleID = &roleID
+	}
+	return actor
+}
@@ -1,15 +1,19 @@
 package handlers
 
 import (
-    "log"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
 	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 )
 
 type AuthHandler struct {
@@ -38,21 +42,21 @@ type RegisterRequest struct {
 // @Accept json
 // @Produce json
 // @Param registerRequest body RegisterRequest true "Registration payload"
-// @Success 201 {object} map[string]string "Registration successful"
-// @Failure 400 {object} map[string]string "Invalid request or validation failed"
-// @Failure 409 {object} map[string]string "Account already exists"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/register [post]
+// @Success 201 {object} utils.Response "Registration successful"
+// @Failure 400 {object} utils.Response "Invalid request or validation failed"
+// @Failure 409 {object} utils.Response "Account already exists"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeBadRequest, "invalid request", err.Error())
 		return
 	}
 
 	// Validate
 	if err := h.validator.Struct(req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": err.Error()})
+		utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeValidationFailed, "validation failed", err.Error())
 		return
 	}
 
@@ -60,16 +64,19 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	userAgent := c.GetHeader("User-Agent")
 
 	if err := h.authService.Register(c.Request.Context(), req.Email, req.Password, req.Name, ip, userAgent); err != nil {
+		if respondPasswordPolicyError(c, err) {
+			return
+		}
 		if err == auth.ErrUserNotFound {
 			// Generic error - don't reveal if user exists
-			c.JSON(http.StatusConflict, gin.H{"error": "account already exists"})
+			utils.Conflict(c, "account already exists")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register"})
+		utils.InternalServerError(c, "failed to register")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "registration successful. please verify your email"})
+	utils.Created(c, "registration successful. please verify your email", nil)
 }
 
 // VerifyEmailRequest represents email verification payload
@@ -84,32 +91,32 @@ type VerifyEmailRequest struct {
 // @Accept json
 // @Produce json
 // @Param verifyEmailRequest body VerifyEmailRequest true "Email verification payload"
-// @Success 200 {object} map[string]string "Email verified successfully"
-// @Failure 400 {object} map[string]string "Invalid token, expired token, or token already used"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/verify-email [post]
+// @Success 200 {object} utils.Response "Email verified successfully"
+// @Failure 400 {object} utils.Response "Invalid token, expired token, or token already used"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/verify-email [post]
 func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	var req VerifyEmailRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BadRequest(c, "invalid request")
 		return
 	}
 
 	if err := h.authService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
 		switch err {
 		case auth.ErrInvalidToken:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token"})
+			utils.BadRequest(c, "invalid token")
 		case auth.ErrTokenExpired:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token expired"})
+			utils.BadRequest(c, "token expired")
 		case auth.ErrTokenUsed:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token already used"})
+			utils.BadRequest(c, "token already used")
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+			utils.InternalServerError(c, "failed to verify email")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+	utils.OK(c, "email verified successfully", nil)
 }
 
 // LoginRequest represents login payload
@@ -130,23 +137,39 @@ type UserResponse struct {
 	ID    int64  `json:"id"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+	// MustChangePassword is true while the account has a temporary or expired password;
+	// other API calls are rejected with code "password_change_required" until it is changed
+	MustChangePassword bool `json:"mustChangePassword"`
+	// TwoFactorSetupRequired is true when the user's role must use two-factor authentication
+	// and they have not enrolled yet; other API calls are rejected with code
+	// "two_factor_setup_required" until they do (see /auth/2fa/setup)
+	TwoFactorSetupRequired bool `json:"twoFactorSetupRequired"`
+}
+
+// TwoFactorChallengeResponse is the login response for accounts with two-factor
+// authentication: the password was right and the code is sent to /auth/login/2fa
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	TwoFactorToken    string    `json:"twoFactorToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return access token. Refresh token is set as HttpOnly cookie.
+// @Description Authenticate user and return access token. Refresh token is set as HttpOnly cookie. For accounts with two-factor authentication no session is created yet: the response carries twoFactorRequired and a twoFactorToken to send with the code to /auth/login/2fa.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param loginRequest body LoginRequest true "Login credentials"
-// @Success 200 {object} LoginResponse "Login successful"
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 401 {object} map[string]string "Invalid credentials"
-// @Router /api/auth/login [post]
+// @Success 200 {object} utils.Response{data=LoginResponse} "Login successful"
+// @Success 202 {object} utils.Response{data=TwoFactorChallengeResponse} "Password accepted, two-factor code required"
+// @Failure 400 {object} utils.Response "Invalid request"
+// @Failure 401 {object} utils.Response "Invalid credentials"
+// @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BadRequest(c, "invalid request")
 		return
 	}
 
@@ -155,26 +178,44 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	user, accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, ip, userAgent)
 	if err != nil {
+		var challenge *auth.TOTPRequiredError
+		if errors.As(err, &challenge) {
+			utils.Accepted(c, "two-factor code required", TwoFactorChallengeResponse{
+				TwoFactorRequired: true,
+				TwoFactorToken:    challenge.ChallengeToken,
+				ExpiresAt:         challenge.ExpiresAt,
+			})
+			return
+		}
+
 		// Log the actual error for debugging (remove in production)
 		// fmt.Printf("Login error: %v\n", err)
 		
 		// Generic error message - don't reveal if email exists
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		utils.Unauthorized(c, "invalid credentials")
 		return
 	}
 
+	h.respondSignedIn(c, user, accessToken, refreshToken)
+}
+
+// respondSignedIn sets the refresh token and CSRF cookies of a new session and returns the
+// access token
+func (h *AuthHandler) respondSignedIn(c *gin.Context, user *auth.User, accessToken, refreshToken string) {
 	// Set refresh token cookie
 	h.setRefreshTokenCookie(c, refreshToken)
 
 	// Set CSRF token cookie and get token value
 	csrfToken := middleware.SetCSRFToken(c)
 
-	c.JSON(http.StatusOK, LoginResponse{
+	utils.OK(c, "", LoginResponse{
 		AccessToken: accessToken,
 		User: UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
+			ID:                     user.ID,
+			Email:                  user.Email,
+			Name:                   user.Name,
+			MustChangePassword:     user.MustChangePassword,
+			TwoFactorSetupRequired: !user.TOTPEnabled && services.TwoFactorRequired(uint(user.RoleID)),
 		},
 		CsrfToken: csrfToken,
 	})
@@ -191,30 +232,33 @@ type RefreshResponse struct {
 // @Description Refresh the access token using the refresh token from HttpOnly cookie.
 // @Tags Auth
 // @Produce json
-// @Success 200 {object} RefreshResponse "Token refreshed successfully"
-// @Failure 401 {object} map[string]string "Refresh token missing or invalid"
-// @Router /api/auth/refresh [post]
+// @Success 200 {object} utils.Response{data=RefreshResponse} "Token refreshed successfully"
+// @Failure 401 {object} utils.Response "Refresh token missing or invalid"
+// @Router /api/v1/auth/refresh [post]
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	// Get refresh token from cookie
 	refreshToken, err := c.Cookie("refresh_token")
 	if err != nil {
-		// Log the error for debugging
-		log.Printf("[Refresh] Failed to get refresh_token cookie: %v", err)
-		log.Printf("[Refresh] Request cookies: %v", c.Request.Cookies())
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token missing"})
+		// Log the error for debugging (cookie names only, never their values)
+		cookieNames := make([]string, 0, len(c.Request.Cookies()))
+		for _, cookie := range c.Request.Cookies() {
+			cookieNames = append(cookieNames, cookie.Name)
+		}
+		utils.Logger(c.Request.Context()).Debug("Refresh token cookie missing", zap.Error(err), zap.Strings("cookies", cookieNames))
+		utils.Unauthorized(c, "refresh token missing")
 		return
 	}
 
 	if refreshToken == "" {
-		log.Printf("[Refresh] Refresh token cookie is empty")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token missing"})
+		utils.Logger(c.Request.Context()).Debug("Refresh token cookie is empty")
+		utils.Unauthorized(c, "refresh token missing")
 		return
 	}
 
 	accessToken, newRefreshToken, err := h.authService.RefreshToken(c.Request.Context(), refreshToken)
 	if err != nil {
-		log.Printf("[Refresh] Refresh token validation failed: %v", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		utils.Logger(c.Request.Context()).Info("Refresh token validation failed", zap.Error(err))
+		utils.Unauthorized(c, "invalid refresh token")
 		return
 	}
 
@@ -224,7 +268,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	// Update CSRF token cookie and get token value
 	csrfToken := middleware.SetCSRFToken(c)
 
-	c.JSON(http.StatusOK, RefreshResponse{
+	utils.OK(c, "", RefreshResponse{
 		AccessToken: accessToken,
 		CsrfToken:   csrfToken,
 	})
@@ -232,30 +276,32 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 
 // Logout godoc
 // @Summary Logout user
-// @Description Logout user and revoke current session. Clears authentication cookies.
+// @Description Logout user and revoke the current session: the session of the refresh token cookie and, when the request carries a valid access token, the access token's session. Access tokens of revoked sessions are rejected from then on. Clears authentication cookies.
 // @Tags Auth
 // @Produce json
-// @Success 200 {object} map[string]string "Logged out successfully"
-// @Router /api/auth/logout [post]
+// @Success 200 {object} utils.Response "Logged out successfully"
+// @Router /api/v1/auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	userID, exists := middleware.GetUserID(c)
-	if !exists {
-		// No user context, just clear cookies
-		h.clearAuthCookies(c)
-		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
-		return
+	// The route is public so expired access tokens can still log out; a valid one identifies
+	// the session to revoke even without the refresh token cookie
+	var userID int64
+	var sessionID string
+	if bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); bearer != "" {
+		if claims, err := auth.VerifyAccessToken(bearer); err == nil {
+			userID, _ = auth.ParseUserIDFromToken(claims)
+			sessionID, _ = auth.ParseSessionIDFromToken(claims)
+		}
 	}
-
-	// Get refresh token from cookie
 	refreshToken, _ := c.Cookie("refresh_token")
 
-	// Revoke session
-	_ = h.authService.Logout(c.Request.Context(), refreshToken, userID)
+	if err := h.authService.Logout(c.Request.Context(), refreshToken, userID, sessionID); err != nil {
+		utils.Logger(c.Request.Context()).Error("Failed to revoke session on logout", zap.Error(err))
+	}
 
 	// Clear cookies
 	h.clearAuthCookies(c)
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	utils.OK(c, "logged out", nil)
 }
 
 // MeResponse represents current user info
@@ -269,33 +315,37 @@ type MeResponse struct {
 // @Tags Auth
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} MeResponse "User information"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 404 {object} map[string]string "User not found"
-// @Router /api/auth/me [get]
+// @Success 200 {object} utils.Response{data=MeResponse} "User information"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 404 {object} utils.Response "User not found"
+// @Router /api/v1/auth/me [get]
 func (h *AuthHandler) Me(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		utils.Unauthorized(c, "unauthorized")
 		return
 	}
 
 	// Get user from database
 	var user auth.User
+	var passwordChangedAt *time.Time
 	err := config.AuthDB.QueryRow(c.Request.Context(),
-		`SELECT id, email, name FROM users WHERE id = $1 AND is_deleted = false`,
-		userID).Scan(&user.ID, &user.Email, &user.Name)
+		`SELECT id, email, name, must_change_password, password_changed_at, role_id, totp_enabled_at IS NOT NULL
+		 FROM users WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&user.ID, &user.Email, &user.Name, &user.MustChangePassword, &passwordChangedAt, &user.RoleID, &user.TOTPEnabled)
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		utils.NotFound(c, "user not found")
 		return
 	}
 
-	c.JSON(http.StatusOK, MeResponse{
+	utils.OK(c, "", MeResponse{
 		User: UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
+			ID:                 user.ID,
+			Email:              user.Email,
+			Name:               user.Name,
+			MustChangePassword:     auth.PasswordChangeRequired(user.MustChangePassword, passwordChangedAt),
+			TwoFactorSetupRequired: !user.TOTPEnabled && services.TwoFactorRequired(uint(user.RoleID)),
 		},
 	})
 }
@@ -307,18 +357,20 @@ type ForgotPasswordRequest struct {
 
 // ForgotPassword godoc
 // @Summary Request password reset
-// @Description Request a password reset link to be sent to the provided email address. Always returns 200 to prevent email enumeration.
+// @Description Request a password reset link to be sent to the provided email address. Always returns 200 to prevent email enumeration. Rate limited by IP; each account gets at most RATE_LIMIT_FORGOT_PASSWORD_PER_EMAIL links per window and only the latest link works.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param forgotPasswordRequest body ForgotPasswordRequest true "Password reset request"
-// @Success 200 {object} map[string]string "Password reset link sent (if account exists)"
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Router /api/auth/forgot-password [post]
+// @Success 200 {object} utils.Response "Password reset link sent (if account exists)"
+// @Failure 400 {object} utils.Response "Invalid request"
+// @Failure 429 {object} utils.Response "Rate limit exceeded"
+// @Router /api/v1/auth/forgot-password [post]
+// @Router /api/v1/forgot-password [post]
 func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	var req ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BadRequest(c, "invalid request")
 		return
 	}
 
@@ -328,7 +380,7 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	// Always return 200 to avoid email enumeration
 	_ = h.authService.ForgotPassword(c.Request.Context(), req.Email, ip, userAgent)
 
-	c.JSON(http.StatusOK, gin.H{"message": "if an account exists with that email, a password reset link has been sent"})
+	utils.OK(c, "if an account exists with that email, a password reset link has been sent", nil)
 }
 
 // ResetPasswordRequest represents password reset payload
@@ -344,32 +396,67 @@ type ResetPasswordRequest struct {
 // @Accept json
 // @Produce json
 // @Param resetPasswordRequest body ResetPasswordRequest true "Password reset payload"
-// @Success 200 {object} map[string]string "Password reset successful"
-// @Failure 400 {object} map[string]string "Invalid token, expired token, or token already used"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/reset-password [post]
+// @Success 200 {object} utils.Response "Password reset successful"
+// @Failure 400 {object} utils.Response "Invalid token, expired token, token already used or password rejected by the policy"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/reset-password [post]
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	var req ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BadRequest(c, "invalid request")
+		return
+	}
+
+	h.resetPassword(c, req.Token, req.NewPassword)
+}
+
+// ResetPasswordWithTokenRequest is the payload of POST /api/reset-password/:token
+type ResetPasswordWithTokenRequest struct {
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
+}
+
+// ResetPasswordWithToken godoc
+// @Summary Reset password (token in path)
+// @Description Same as /api/auth/reset-password with the emailed token in the URL. Tokens are single use, expire after PASSWORD_RESET_TTL and are stored hashed; the request is rate limited by IP.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token path string true "Reset token from the email"
+// @Param resetPasswordRequest body ResetPasswordWithTokenRequest true "New password"
+// @Success 200 {object} utils.Response "Password reset successful"
+// @Failure 400 {object} utils.Response "Invalid token, expired token, token already used or password rejected by the policy"
+// @Failure 429 {object} utils.Response "Rate limit exceeded"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/reset-password/{token} [post]
+func (h *AuthHandler) ResetPasswordWithToken(c *gin.Context) {
+	var req ResetPasswordWithTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request")
 		return
 	}
 
-	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+	h.resetPassword(c, c.Param("token"), req.NewPassword)
+}
+
+func (h *AuthHandler) resetPassword(c *gin.Context, token, newPassword string) {
+	if err := h.authService.ResetPassword(c.Request.Context(), token, newPassword); err != nil {
+		if respondPasswordPolicyError(c, err) {
+			return
+		}
 		switch err {
 		case auth.ErrInvalidToken:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token"})
+			utils.BadRequest(c, "invalid token")
 		case auth.ErrTokenExpired:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token expired"})
+			utils.BadRequest(c, "token expired")
 		case auth.ErrTokenUsed:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token already used"})
+			utils.BadRequest(c, "token already used")
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+			utils.InternalServerError(c, "failed to reset password")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "password reset successful"})
+	utils.OK(c, "password reset successful", nil)
 }
 
 // ChangePasswordRequest represents change password payload
@@ -386,35 +473,53 @@ type ChangePasswordRequest struct {
 // @Accept json
 // @Produce json
 // @Param changePasswordRequest body ChangePasswordRequest true "Password change payload"
-// @Success 200 {object} map[string]string "Password changed successfully"
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 401 {object} map[string]string "Unauthorized or invalid current password"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/change-password [post]
+// @Success 200 {object} utils.Response "Password changed successfully"
+// @Failure 400 {object} utils.Response "Invalid request or password rejected by the policy"
+// @Failure 401 {object} utils.Response "Unauthorized or invalid current password"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/change-password [post]
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		utils.Unauthorized(c, "unauthorized")
 		return
 	}
 
 	var req ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		utils.BadRequest(c, "invalid request")
 		return
 	}
 
 	if err := h.authService.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		if respondPasswordPolicyError(c, err) {
+			return
+		}
 		switch err {
 		case auth.ErrInvalidPassword:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid current password"})
+			utils.Unauthorized(c, "invalid current password")
 		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+			utils.InternalServerError(c, "failed to change password")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
+	utils.OK(c, "password changed successfully", nil)
+}
+
+// respondPasswordPolicyError writes a 400 for passwords rejected by the password policy
+// (complexity rules or reuse of a recent password) and reports whether it did
+func respondPasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *auth.PasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeValidationFailed, "password does not meet the policy", policyErr.Violations)
+	case errors.Is(err, auth.ErrPasswordReused):
+		utils.BadRequest(c, err.Error())
+	default:
+		return false
+	}
+	return true
 }
 
 // GetSessionsResponse represents sessions list response
@@ -438,14 +543,14 @@ type SessionResponse struct {
 // @Tags Auth
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} GetSessionsResponse "List of active sessions"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/sessions [get]
+// @Success 200 {object} utils.Response{data=GetSessionsResponse} "List of active sessions"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/sessions [get]
 func (h *AuthHandler) GetSessions(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		utils.Unauthorized(c, "unauthorized")
 		return
 	}
 
@@ -453,7 +558,7 @@ func (h *AuthHandler) GetSessions(c *gin.Context) {
 
 	sessions, err := h.authService.GetSessions(c.Request.Context(), userID, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get sessions"})
+		utils.InternalServerError(c, "failed to get sessions")
 		return
 	}
 
@@ -469,7 +574,7 @@ func (h *AuthHandler) GetSessions(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, GetSessionsResponse{
+	utils.OK(c, "", GetSessionsResponse{
 		Sessions: sessionResponses,
 	})
 }
@@ -481,30 +586,30 @@ func (h *AuthHandler) GetSessions(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path string true "Session ID"
-// @Success 200 {object} map[string]string "Session revoked successfully"
-// @Failure 400 {object} map[string]string "Bad request"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 500 {object} map[string]string "Internal server error"
-// @Router /api/auth/sessions/{id} [delete]
+// @Success 200 {object} utils.Response "Session revoked successfully"
+// @Failure 400 {object} utils.Response "Bad request"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Router /api/v1/auth/sessions/{id} [delete]
 func (h *AuthHandler) RevokeSession(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		utils.Unauthorized(c, "unauthorized")
 		return
 	}
 
 	sessionID := c.Param("id")
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "session id required"})
+		utils.BadRequest(c, "session id required")
 		return
 	}
 
 	if err := h.authService.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		utils.InternalServerError(c, "failed to revoke session")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+	utils.OK(c, "session revoked", nil)
 }
 
 // Helper methods
@@ -526,8 +631,8 @@ func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string) {
 		true, // HttpOnly
 	)
 	
-	log.Printf("[setRefreshTokenCookie] Cookie set: path=%s, domain='%s', secure=%v, maxAge=%d", 
-		config.CookiePath, domain, config.CookieSecure, maxAge)
+	utils.Logger(c.Request.Context()).Debug("Refresh token cookie set",
+		zap.String("path", config.CookiePath), zap.String("domain", domain), zap.Bool("secure", config.CookieSecure), zap.Int("max_age", maxAge))
 }
 
 func (h *AuthHandler) clearAuthCookies(c *gin.Context) {
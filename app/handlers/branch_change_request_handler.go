@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// BranchChangeRequestBody is a coordinator's proposed edit of their branch profile
+type BranchChangeRequestBody struct {
+	Changes map[string]interface{} `json:"changes" binding:"required"`
+	Comment string                 `json:"comment" binding:"max=2000"`
+}
+
+// BranchChangeReviewBody is the reviewer's note on an approval or rejection
+type BranchChangeReviewBody struct {
+	Comment string `json:"comment" binding:"max=2000"`
+}
+
+// SubmitBranchChangeRequestHandler godoc
+// @Summary Propose changes to a branch profile
+// @Description Coordinators (users whose branch_id is the branch or its parent) submit edits to profile fields (name, email, coordinator_name, contact_number, established_on, aashram_area, country_id, state_id, district_id, city_id, address, pincode, post_office, police_station, open_days, daily_start_time, daily_end_time, latitude, longitude). The change is applied once a regional manager or admin approves it.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param request body BranchChangeRequestBody true "Proposed field values"
+// @Success 201 {object} utils.Response{data=models.BranchChangeRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branches/{id}/change-requests [post]
+func SubmitBranchChangeRequestHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid branch ID")
+		return
+	}
+	var body BranchChangeRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	request, err := services.SubmitBranchChangeRequest(uint(branchID), services.BranchChangeInput{
+		Changes: body.Changes,
+		Comment: body.Comment,
+	}, auditActor(c))
+	if err != nil {
+		respondBranchChangeError(c, err)
+		return
+	}
+	utils.Created(c, "", request)
+}
+
+// GetBranchChangeRequestsHandler godoc
+// @Summary List branch change requests
+// @Description Admins see every request, managers those of their region's branches (users.region_id), everyone else the requests they submitted. Newest first.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "pending, approved, rejected or withdrawn"
+// @Param branch_id query int false "Branch ID"
+// @Param mine query bool false "Only requests submitted by the current user"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-change-requests [get]
+func GetBranchChangeRequestsHandler(c *gin.Context) {
+	actor := auditActor(c)
+	filter := services.BranchChangeRequestFilter{Status: c.Query("status")}
+	if value := c.Query("branch_id"); value != "" {
+		branchID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid branch_id")
+			return
+		}
+		filter.BranchID = uint(branchID)
+	}
+	if c.Query("mine") == "true" {
+		filter.RequestedBy = actor.UserID
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	requests, total, err := services.ListBranchChangeRequests(filter, actor)
+	if err != nil {
+		respondBranchChangeError(c, err)
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":  requests,
+		"total": total,
+	})
+}
+
+// GetBranchChangeRequestHandler godoc
+// @Summary Get a branch change request with its diff
+// @Description Returns the request with, per field, the value when it was submitted, the current value and the proposed value. conflict is set when the branch changed since the request was made.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Change request ID"
+// @Success 200 {object} utils.Response{data=services.BranchChangeRequestDetail}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branch-change-requests/{id} [get]
+func GetBranchChangeRequestHandler(c *gin.Context) {
+	id, ok := branchChangeRequestIDParam(c)
+	if !ok {
+		return
+	}
+	detail, err := services.GetBranchChangeRequest(id, auditActor(c))
+	if err != nil {
+		respondBranchChangeError(c, err)
+		return
+	}
+	utils.OK(c, "", detail)
+}
+
+// ApproveBranchChangeRequestHandler godoc
+// @Summary Approve a branch change request
+// @Description Applies the proposed changes to the branch (with the usual branch validation) and records them in the audit log. Admins and managers of the branch's region only; reviewers cannot approve their own requests.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Change request ID"
+// @Param request body BranchChangeReviewBody false "Review comment"
+// @Success 200 {object} utils.Response{data=models.BranchChangeRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/branch-change-requests/{id}/approve [post]
+func ApproveBranchChangeRequestHandler(c *gin.Context) {
+	reviewBranchChangeRequest(c, true)
+}
+
+// RejectBranchChangeRequestHandler godoc
+// @Summary Reject a branch change request
+// @Description Admins and managers of the branch's region only. The comment is shown to the coordinator.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Change request ID"
+// @Param request body BranchChangeReviewBody false "Review comment"
+// @Success 200 {object} utils.Response{data=models.BranchChangeRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/branch-change-requests/{id}/reject [post]
+func RejectBranchChangeRequestHandler(c *gin.Context) {
+	reviewBranchChangeRequest(c, false)
+}
+
+func reviewBranchChangeRequest(c *gin.Context, approve bool) {
+	id, ok := branchChangeRequestIDParam(c)
+	if !ok {
+		return
+	}
+	var body BranchChangeReviewBody
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	request, err := services.ReviewBranchChangeRequest(id, approve, body.Comment, auditActor(c))
+	if err != nil {
+		respondBranchChangeError(c, err)
+		return
+	}
+	utils.OK(c, "", request)
+}
+
+// WithdrawBranchChangeRequestHandler godoc
+// @Summary Withdraw a pending branch change request
+// @Description Only the submitter can withdraw, and only while the request is pending.
+// @Tags Branch Change Requests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Change request ID"
+// @Success 200 {object} utils.Response{data=models.BranchChangeRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/branch-change-requests/{id} [delete]
+func WithdrawBranchChangeRequestHandler(c *gin.Context) {
+	id, ok := branchChangeRequestIDParam(c)
+	if !ok {
+		return
+	}
+	request, err := services.WithdrawBranchChangeRequest(id, auditActor(c))
+	if err != nil {
+		respondBranchChangeError(c, err)
+		return
+	}
+	utils.OK(c, "", request)
+}
+
+func branchChangeRequestIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid change request ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondBranchChangeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBranchChangeRequestNotFound), errors.Is(err, services.ErrBranchNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidBranchChange):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrBranchChangeNotAllowed), errors.Is(err, services.ErrBranchChangeNotReviewer),
+		errors.Is(err, services.ErrUserNotFound):
+		utils.Forbidden(c, err.Error())
+	case errors.Is(err, services.ErrBranchChangeNotPending):
+		utils.Conflict(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
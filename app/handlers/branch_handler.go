@@ -1,13 +1,16 @@
 package handlers
 
 import (
-	"net/http"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
@@ -43,6 +46,8 @@ type BranchCreateRequest struct {
 	Status         *bool       `json:"status,omitempty"`
 	NCR            *bool       `json:"ncr,omitempty"`
 	RegionID       *uint       `json:"region_id,omitempty"`
+	Latitude       *float64    `json:"latitude,omitempty"`
+	Longitude      *float64    `json:"longitude,omitempty"`
 	BranchCode     string      `json:"branch_code,omitempty"`
 	CreatedBy      string      `json:"created_by,omitempty"`
 	UpdatedBy      string      `json:"updated_by,omitempty"`
@@ -101,6 +106,9 @@ func (r *BranchCreateRequest) ToBranch() (*models.Branch, error) {
 		branch.RegionID = r.RegionID
 	}
 
+	branch.Latitude = r.Latitude
+	branch.Longitude = r.Longitude
+
 	if r.AashramArea != nil {
 		branch.AashramArea = *r.AashramArea
 	}
@@ -270,32 +278,39 @@ func parseTime(timeStr string) (time.Time, error) {
 // @Accept json
 // @Produce json
 // @Param branch body BranchCreateRequest true "Branch payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branches [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches [post]
 func CreateBranchHandler(c *gin.Context) {
 	var req BranchCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Convert request to Branch model
 	branch, err := req.ToBranch()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
 		return
 	}
 
 	// Validate branch input
 	if err := validators.ValidateBranchInput(branch.Name, branch.Email, branch.ContactNumber, branch.CoordinatorName); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := validators.ValidateCoordinates(branch.Latitude, branch.Longitude); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if !checkBranchAccess(c, branch.ParentBranchID) {
 		return
 	}
 
 	if err := services.CreateBranch(branch); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
@@ -303,7 +318,7 @@ func CreateBranchHandler(c *gin.Context) {
 	for _, infra := range req.Infrastructure {
 		rt := infra.Type
 		if rt == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "infrastructure.type is required"})
+			utils.BadRequest(c, "infrastructure.type is required")
 			return
 		}
 
@@ -315,7 +330,7 @@ func CreateBranchHandler(c *gin.Context) {
 				if n, err := strconv.Atoi(v); err == nil {
 					num = n
 				} else {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "infrastructure.count must be numeric"})
+					utils.BadRequest(c, "infrastructure.count must be numeric")
 					return
 				}
 			}
@@ -326,7 +341,7 @@ func CreateBranchHandler(c *gin.Context) {
 		case nil:
 			num = 0
 		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "infrastructure.count must be a number or numeric string"})
+			utils.BadRequest(c, "infrastructure.count must be a number or numeric string")
 			return
 		}
 
@@ -337,7 +352,7 @@ func CreateBranchHandler(c *gin.Context) {
 			CreatedBy: branch.CreatedBy,
 		}
 		if err := services.CreateBranchInfrastructure(&infraModel); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 			return
 		}
 	}
@@ -345,18 +360,18 @@ func CreateBranchHandler(c *gin.Context) {
 	// Link child branches by id (child_branches[].branchId)
 	for _, child := range req.ChildBranches {
 		if child.BranchID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "child_branches entries must include branchId to link existing branches"})
+			utils.BadRequest(c, "child_branches entries must include branchId to link existing branches")
 			return
 		}
 		cid, err := strconv.ParseUint(child.BranchID, 10, 64)
 		if err != nil || cid == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branchId"})
+			utils.BadRequest(c, "invalid child branchId")
 			return
 		}
 		// Update child branch to set parent_branch_id to created branch
 		updateData := map[string]interface{}{"parent_branch_id": branch.ID}
-		if err := services.UpdateBranch(uint(cid), updateData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := services.UpdateBranch(uint(cid), updateData, nil); err != nil {
+			utils.InternalServerError(c, err.Error())
 			return
 		}
 	}
@@ -367,33 +382,64 @@ func CreateBranchHandler(c *gin.Context) {
 			continue
 		}
 		if err := services.UpdateBranchMember(memberID, map[string]interface{}{"branch_id": branch.ID}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 			return
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Branch created successfully",
-		"branch":  branch,
+	utils.Created(c, "Branch created successfully", gin.H{
+		"branch": branch,
 	})
 }
 
 // GetAllBranchesHandler godoc
 // @Summary Get all branches
-// @Description Retrieve all branches with their related data (country, state, district, city, infrastructure, members)
+// @Description Retrieve all branches with their related data (country, state, district, city, infrastructure, members). Filter by any listed field, e.g. state_id=4, ncr=true, name[contains]=nagar or event_count[gte]=10 (operators: eq ne lt lte gt gte in contains null), and sort with sort=name,-created_on (fields: id, name, coordinator_name, branch_code, established_on, created_on, updated_on, event_count, member_count, media_count, last_activity_on; newest first by default). Filterable fields: the sortable ones plus email, status, ncr, country_id, state_id, district_id, city_id, region_id.
 // @Tags Branches
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Branch
-// @Failure 500 {object} map[string]string
-// @Router /api/branches [get]
+// @Param sort query string false "Comma-separated sort fields, - for descending"
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Param fields query string false "Comma-separated columns to return, e.g. name,branch_code (id is always returned)"
+// @Param include query string false "Comma-separated associations to return: country, state, district, city, parent, children, infrastructure, branch_members, cover_image, coordinator_photo"
+// @Success 200 {object} utils.Response{data=[]models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches [get]
 func GetAllBranchesHandler(c *gin.Context) {
-	branches, err := services.GetAllBranches()
+	query, err := services.BranchListSchema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	projection, err := services.BranchFieldSet.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	branches, err := services.GetAllBranches(query, projection, middleware.IncludeDeleted(c), scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	respondBranchList(c, projection, branches)
+}
+
+// respondBranchList presigns the images of a branch list and writes it in the requested shape
+func respondBranchList(c *gin.Context, projection *listquery.Projection, branches []models.Branch) {
+	if projection.Includes("cover_image", "coordinator_photo", "children", "parent") {
+		services.AttachBranchListImages(c.Request.Context(), branches)
+	}
+	items, err := listquery.Items(projection, branches)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, branches)
+	utils.OK(c, "", items)
 }
 
 // GetBranchHandler godoc
@@ -403,30 +449,110 @@ func GetAllBranchesHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Branch ID"
-// @Success 200 {object} models.Branch
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/branches/{id} [get]
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branches/{id} [get]
 func GetBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 
 	branchID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid branch ID",
-		})
+		utils.BadRequest(c, "invalid branch ID")
 		return
 	}
 
 	branch, err := services.GetBranch(uint(branchID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
+		utils.NotFound(c, err.Error())
 		return
 	}
+	services.AttachBranchImages(c.Request.Context(), branch)
+	setVersionETag(c, branch.Version)
 
-	c.JSON(http.StatusOK, branch)
+	utils.OK(c, "", branch)
+}
+
+// GetBranchStatsHandler godoc
+// @Summary Get branch statistics
+// @Description Aggregates events, beneficiaries, initiations, donations and members of a branch. With include_children=true the totals also cover all child branches (at any depth) and "branches" lists each branch's own figures. With as_of the figures are those of the latest daily snapshot on or before the date, as they were reported then.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param include_children query bool false "Roll up child branches"
+// @Param as_of query string false "Figures as reported on this date (YYYY-MM-DD)"
+// @Success 200 {object} utils.Response{data=services.BranchStats}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/stats [get]
+func GetBranchStatsHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+	includeChildren := false
+	if value := c.Query("include_children"); value != "" {
+		if includeChildren, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "include_children must be true or false")
+			return
+		}
+	}
+
+	var stats *services.BranchStats
+	if value := c.Query("as_of"); value != "" {
+		asOf, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid as_of (use YYYY-MM-DD)")
+			return
+		}
+		stats, err = services.GetBranchStatsAsOf(uint(branchID), includeChildren, asOf)
+	} else {
+		stats, err = services.GetBranchStats(uint(branchID), includeChildren)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrBranchNotFound) || errors.Is(err, services.ErrNoStatsSnapshot) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", stats)
+}
+
+// GetNearbyBranchesHandler godoc
+// @Summary Branches near a point
+// @Description Returns active branches and child branches with coordinates within radius_km of lat/lng, nearest first, with their distance for the branch map.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param lat query number true "Latitude"
+// @Param lng query number true "Longitude"
+// @Param radius_km query number false "Search radius in km (default 25, max 500)"
+// @Param limit query int false "Maximum number of branches (default 50, max 200)"
+// @Success 200 {object} utils.Response{data=[]services.NearbyBranch}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/nearby [get]
+func GetNearbyBranchesHandler(c *gin.Context) {
+	point, radiusKm, limit, err := services.ParseNearbyQuery(c.Query("lat"), c.Query("lng"), c.Query("radius_km"), c.Query("limit"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	branches, err := services.GetNearbyBranches(point, radiusKm, limit, scope)
+	if err != nil {
+		utils.InternalServerError(c, "failed to search nearby branches")
+		return
+	}
+	utils.OK(c, "", branches)
 }
 
 // GetBranchSearchHandler godoc
@@ -437,26 +563,31 @@ func GetBranchHandler(c *gin.Context) {
 // @Produce json
 // @Param name query string false "Branch Name"
 // @Param coordinator query string false "Coordinator Name"
-// @Success 200 {array} models.Branch
-// @Failure 404 {object} map[string]string
-// @Router /api/branches/search [get]
+// @Success 200 {object} utils.Response{data=[]models.Branch}
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branches/search [get]
 func GetBranchSearchHandler(c *gin.Context) {
 	name := c.Query("name")
 	coordinator := c.Query("coordinator")
 
-	branches, err := services.GetBranchSearch(name, coordinator)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	branches, err := services.GetBranchSearch(name, coordinator, scope)
 	if err != nil {
 		// Only return error for actual database errors, not for empty results
 		if err.Error() == "error fetching branches" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 			return
 		}
 		// For "no branches found" or other cases, return empty array
-		c.JSON(http.StatusOK, []models.Branch{})
+		utils.OK(c, "", []models.Branch{})
 		return
 	}
+	services.AttachBranchListImages(c.Request.Context(), branches)
 
-	c.JSON(http.StatusOK, branches)
+	utils.OK(c, "", branches)
 }
 
 // GetChildBranchesHandler godoc
@@ -466,57 +597,61 @@ func GetBranchSearchHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param parent_id path int true "Parent Branch ID"
-// @Success 200 {array} models.Branch
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/branches/parent/{parent_id}/children [get]
+// @Success 200 {object} utils.Response{data=[]models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branches/parent/{parent_id}/children [get]
 func GetChildBranchesHandler(c *gin.Context) {
 	parentIDParam := c.Param("parent_id")
 
 	parentID, err := strconv.ParseUint(parentIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid parent branch ID",
-		})
+		utils.BadRequest(c, "invalid parent branch ID")
 		return
 	}
 
 	branches, err := services.GetChildBranches(uint(parentID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
+	services.AttachBranchListImages(c.Request.Context(), branches)
 
-	c.JSON(http.StatusOK, branches)
+	utils.OK(c, "", branches)
 }
 
 // UpdateBranchHandler godoc
 // @Summary Update a branch
-// @Description Update branch details, infrastructure, child branches, and member associations
+// @Description Update branch details, infrastructure, child branches, and member associations. Send the version last read (If-Match with the ETag of the GET, or a "version" field) to have the update rejected with 409 and the latest branch if someone else updated it since.
 // @Tags Branches
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Branch ID"
+// @Param If-Match header string false "Version last read (the ETag of the GET)"
 // @Param branch body map[string]interface{} true "Updated fields"
-// @Success 200 {object} models.Branch
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branches/{id} [put]
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id} [put]
 func UpdateBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	branchID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		utils.BadRequest(c, "Invalid branch ID")
 		return
 	}
 
 	// Bind into a generic map so we can accept nested keys (infrastructure, child_branches, branch_members)
 	var payload map[string]interface{}
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	version, err := expectedVersion(c, payload)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
@@ -540,6 +675,11 @@ func UpdateBranchHandler(c *gin.Context) {
 	delete(payload, "created_on") // Should not be updated
 	delete(payload, "created_by") // Should not be updated
 
+	// Branch images have their own endpoints (media must belong to the branch)
+	delete(payload, "cover_media_id")
+	delete(payload, "coordinator_photo_media_id")
+	delete(payload, "public_id")
+
 	// Handle empty strings - convert to nil for optional fields
 	// Email: if empty string, remove it (don't update) or set to nil if explicitly clearing
 	if email, ok := payload["email"]; ok {
@@ -554,7 +694,7 @@ func UpdateBranchHandler(c *gin.Context) {
 		if contactStr, ok := contactNumber.(string); ok {
 			if strings.TrimSpace(contactStr) == "" {
 				// Contact number is required, don't allow empty
-				c.JSON(http.StatusBadRequest, gin.H{"error": "contact number is required and cannot be empty"})
+				utils.BadRequest(c, "contact number is required and cannot be empty")
 				return
 			}
 		}
@@ -659,13 +799,16 @@ func UpdateBranchHandler(c *gin.Context) {
 
 	// Validate remaining branch update fields
 	if err := validators.ValidateBranchUpdateFields(payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Update branch table
-	if err := services.UpdateBranch(uint(branchID), payload); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := services.UpdateBranch(uint(branchID), payload, version); err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
@@ -744,7 +887,7 @@ func UpdateBranchHandler(c *gin.Context) {
 								if address, ok := m["address"]; ok && address != nil && address != "" {
 									updateData["address"] = address
 								}
-								_ = services.UpdateBranch(uint(cid), updateData)
+								_ = services.UpdateBranch(uint(cid), updateData, nil)
 							}
 						}
 					}
@@ -772,13 +915,13 @@ func UpdateBranchHandler(c *gin.Context) {
 	// Return the updated branch object (with relations preloaded)
 	branch, err := services.GetBranch(uint(branchID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Branch updated successfully",
-		"branch":  branch,
+	setVersionETag(c, branch.Version)
+	utils.OK(c, "Branch updated successfully", gin.H{
+		"branch": branch,
 	})
 }
 
@@ -789,24 +932,56 @@ func UpdateBranchHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Branch ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branches/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id} [delete]
 func DeleteBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	branchID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		utils.BadRequest(c, "Invalid branch ID")
 		return
 	}
 
 	if err := services.DeleteBranch(uint(branchID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "Branch deleted successfully", nil)
+}
+
+// RestoreBranchHandler godoc
+// @Summary Restore a deleted branch
+// @Description Restore a soft-deleted branch (admin only)
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/restore [post]
+func RestoreBranchHandler(c *gin.Context) {
+	idParam := c.Param("id")
+	branchID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid branch ID")
+		return
+	}
+
+	if err := services.RestoreBranch(uint(branchID)); err != nil {
+		if errors.Is(err, services.ErrNotDeleted) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch deleted successfully"})
+	utils.OK(c, "Branch restored successfully", nil)
 }
 
 // *************************************** Branch Infrastructure ****************************************************** //
@@ -819,32 +994,29 @@ func DeleteBranchHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param infra body models.BranchInfrastructure true "Infrastructure payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-infra [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-infra [post]
 func CreateBranchInfrastructureHandler(c *gin.Context) {
 	var infra models.BranchInfrastructure
 	if err := c.ShouldBindJSON(&infra); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate infrastructure input
 	if err := validators.ValidateBranchInfrastructure(infra.BranchID, infra.Type, infra.Count); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.CreateBranchInfrastructure(&infra); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Infrastructure created successfully",
-		"data":    infra,
-	})
+	utils.Created(c, "Infrastructure created successfully", infra)
 }
 
 // GetAllBranchInfrastructureHandler godoc
@@ -853,16 +1025,16 @@ func CreateBranchInfrastructureHandler(c *gin.Context) {
 // @Tags BranchInfrastructure
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.BranchInfrastructure
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-infra [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchInfrastructure}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-infra [get]
 func GetAllBranchInfrastructureHandler(c *gin.Context) {
 	infra, err := services.GetAllBranchInfrastructure()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, infra)
+	utils.OK(c, "", infra)
 }
 
 // GetInfrastructureByBranchHandler godoc
@@ -872,25 +1044,25 @@ func GetAllBranchInfrastructureHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param branch_id path int true "Branch ID"
-// @Success 200 {array} models.BranchInfrastructure
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/branch-infra/branch/{branch_id} [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchInfrastructure}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branch-infra/branch/{branch_id} [get]
 func GetInfrastructureByBranchHandler(c *gin.Context) {
 	branchIDParam := c.Param("branch_id")
 	branchID, err := strconv.ParseUint(branchIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		utils.BadRequest(c, "invalid branch ID")
 		return
 	}
 
 	infra, err := services.GetInfrastructureByBranch(uint(branchID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, infra)
+	utils.OK(c, "", infra)
 }
 
 // UpdateBranchInfrastructureHandler godoc
@@ -902,36 +1074,36 @@ func GetInfrastructureByBranchHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Infrastructure ID"
 // @Param infra body map[string]interface{} true "Updated fields"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-infra/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-infra/{id} [put]
 func UpdateBranchInfrastructureHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate update fields
 	if err := validators.ValidateBranchInfrastructureUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.UpdateBranchInfrastructure(uint(id), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Infrastructure updated successfully"})
+	utils.OK(c, "Infrastructure updated successfully", nil)
 }
 
 // DeleteBranchInfrastructureHandler godoc
@@ -941,24 +1113,24 @@ func UpdateBranchInfrastructureHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Infrastructure ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-infra/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-infra/{id} [delete]
 func DeleteBranchInfrastructureHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
 	if err := services.DeleteBranchInfrastructure(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Infrastructure deleted successfully"})
+	utils.OK(c, "Infrastructure deleted successfully", nil)
 }
 
 // *************************************** Branch Infrastructure ****************************************************** //
@@ -1015,39 +1187,36 @@ func (r *BranchMemberCreateRequest) ToBranchMember() (*models.BranchMember, erro
 // @Accept json
 // @Produce json
 // @Param member body BranchMemberCreateRequest true "Branch Member payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-member [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-member [post]
 func CreateBranchMemberHandler(c *gin.Context) {
 	var req BranchMemberCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Convert request to BranchMember model
 	member, err := req.ToBranchMember()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
 		return
 	}
 
 	// Validate branch member input
 	if err := validators.ValidateBranchMember(member.Name, member.MemberType, member.BranchID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.CreateBranchMember(member); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Branch member created successfully",
-		"data":    member,
-	})
+	utils.Created(c, "Branch member created successfully", member)
 }
 
 // GetAllBranchMembersHandler godoc
@@ -1056,16 +1225,16 @@ func CreateBranchMemberHandler(c *gin.Context) {
 // @Tags BranchMember
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.BranchMember
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-member [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchMember}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-member [get]
 func GetAllBranchMembersHandler(c *gin.Context) {
 	members, err := services.GetAllBranchMembers()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, members)
+	utils.OK(c, "", members)
 }
 
 // GetMembersByBranchHandler godoc
@@ -1075,25 +1244,25 @@ func GetAllBranchMembersHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param branch_id path int true "Branch ID"
-// @Success 200 {array} models.BranchMember
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/branch-member/branch/{branch_id} [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchMember}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/branch-member/branch/{branch_id} [get]
 func GetMembersByBranchHandler(c *gin.Context) {
 	branchIDParam := c.Param("branch_id")
 	branchID, err := strconv.ParseUint(branchIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		utils.BadRequest(c, "invalid branch ID")
 		return
 	}
 
 	members, err := services.GetMembersByBranch(uint(branchID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, members)
+	utils.OK(c, "", members)
 }
 
 // UpdateBranchMemberHandler godoc
@@ -1105,36 +1274,36 @@ func GetMembersByBranchHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Member ID"
 // @Param member body map[string]interface{} true "Updated fields"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-member/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-member/{id} [put]
 func UpdateBranchMemberHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate update fields
 	if err := validators.ValidateBranchMemberUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.UpdateBranchMember(uint(id), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch member updated successfully"})
+	utils.OK(c, "Branch member updated successfully", nil)
 }
 
 // DeleteBranchMemberHandler godoc
@@ -1144,22 +1313,66 @@ func UpdateBranchMemberHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Member ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-member/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-member/{id} [delete]
 func DeleteBranchMemberHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
 	if err := services.DeleteBranchMember(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Branch member deleted successfully"})
+	utils.OK(c, "Branch member deleted successfully", nil)
+}
+
+// currentBranchScope returns the branches the user may access (nil for admins), writing a 500
+// response when it cannot be resolved
+func currentBranchScope(c *gin.Context) (*services.BranchScope, bool) {
+	scope, err := middleware.CurrentBranchScope(c)
+	if err != nil {
+		utils.InternalServerError(c, "failed to resolve branch access")
+		return nil, false
+	}
+	return scope, true
+}
+
+// checkBranchAccess writes a 403 and returns false unless the user may access the branch. A
+// nil branch (a new top-level branch, an event without a branch) is reserved for users
+// without a branch scope.
+func checkBranchAccess(c *gin.Context, branchID *uint) bool {
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return false
+	}
+	if scope != nil && (branchID == nil || !scope.Allows(*branchID)) {
+		utils.Forbidden(c, services.ErrOutOfBranchScope.Error())
+		return false
+	}
+	return true
+}
+
+// checkRecordAccess writes a 403 and returns false when the record of the given kind
+// (services.ScopeEvent, ...) belongs to a branch the user may not access
+func checkRecordAccess(c *gin.Context, kind string, id uint) bool {
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return false
+	}
+	if err := scope.Check(kind, id); err != nil {
+		if errors.Is(err, services.ErrOutOfBranchScope) {
+			utils.Forbidden(c, err.Error())
+		} else {
+			utils.InternalServerError(c, "failed to check branch access")
+		}
+		return false
+	}
+	return true
 }
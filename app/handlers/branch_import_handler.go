@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBranchImportFileSize caps uploaded import spreadsheets
+const maxBranchImportFileSize = 5 << 20 // 5MB
+
+// ImportBranchesHandler godoc
+// @Summary Bulk import branches
+// @Description Import branches from a CSV or XLSX file (first sheet). The header row names the columns: name, email, coordinator_name, contact_number, established_on (YYYY-MM-DD), aashram_area, country, state, district, city (IDs or names), address, pincode, post_office, police_station, open_days, daily_start_time, daily_end_time, branch_code, ncr. All rows are validated first; nothing is inserted if any row fails or dry_run is set. Admins and managers only; users with a branch scope cannot import top-level branches. The importing user's email is recorded as created_by.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run formData bool false "Validate only, do not insert"
+// @Param async formData bool false "Import in the background; poll GET /api/jobs/{id} for the result"
+// @Success 200 {object} utils.Response{data=services.BranchImportResult} "Dry run"
+// @Success 201 {object} utils.Response{data=services.BranchImportResult} "Imported"
+// @Success 202 {object} utils.Response{data=models.Job} "Queued (async)"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 422 {object} utils.Response{details=services.BranchImportResult} "Row validation errors"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/import [post]
+func ImportBranchesHandler(c *gin.Context) {
+	importBranches(c, false)
+}
+
+// ImportChildBranchesHandler godoc
+// @Summary Bulk import child branches
+// @Description Import child branches from a CSV or XLSX file. Same columns as /api/branches/import plus parent_branch_id or parent_branch_code; coordinator_name is always inherited from the parent, which must be within the user's branch scope. Nothing is inserted if any row fails or dry_run is set. Admins and managers only.
+// @Tags Child Branches
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run formData bool false "Validate only, do not insert"
+// @Param async formData bool false "Import in the background; poll GET /api/jobs/{id} for the result"
+// @Success 200 {object} utils.Response{data=services.BranchImportResult} "Dry run"
+// @Success 201 {object} utils.Response{data=services.BranchImportResult} "Imported"
+// @Success 202 {object} utils.Response{data=models.Job} "Queued (async)"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 422 {object} utils.Response{details=services.BranchImportResult} "Row validation errors"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/child-branches/import [post]
+func ImportChildBranchesHandler(c *gin.Context) {
+	importBranches(c, true)
+}
+
+func importBranches(c *gin.Context, child bool) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "file is required")
+		return
+	}
+	if file.Size > maxBranchImportFileSize {
+		utils.BadRequest(c, "file exceeds the 5MB import limit")
+		return
+	}
+
+	dryRun := false
+	if value := c.PostForm("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "invalid dry_run")
+			return
+		}
+	}
+	async := false
+	if value := c.PostForm("async"); value != "" {
+		if async, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "invalid async")
+			return
+		}
+	}
+
+	// New top-level branches are reserved for users without a branch scope; parents of child
+	// branches are checked per row
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	if !child && !checkBranchAccess(c, nil) {
+		return
+	}
+	createdBy, ok := importCreatedBy(c)
+	if !ok {
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "failed to open file")
+		return
+	}
+	defer src.Close()
+
+	if async {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			utils.InternalServerError(c, "failed to read file")
+			return
+		}
+		job, err := services.QueueBranchImport(c.Request.Context(), file.Filename, data, child, dryRun, createdBy, scope, auditActor(c))
+		if err != nil {
+			if errors.Is(err, services.ErrUnsupportedImportFile) {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			utils.InternalServerError(c, err.Error())
+			return
+		}
+		utils.Accepted(c, "", job)
+		return
+	}
+
+	rows, err := services.ParseBranchImportFile(file.Filename, src)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := services.ImportBranches(rows, child, dryRun, createdBy, scope, auditActor(c))
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		utils.ErrorCodeResponse(c, http.StatusUnprocessableEntity, utils.CodeValidationFailed, "some rows are invalid, nothing was imported", result)
+	case result.DryRun:
+		utils.OK(c, "", result)
+	default:
+		utils.Created(c, "", result)
+	}
+}
+
+// importCreatedBy is the created_by recorded on imported branches: the authenticated user's
+// email, never a value from the form
+func importCreatedBy(c *gin.Context) (string, bool) {
+	userID, _ := middleware.CurrentUserID(c)
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		utils.InternalServerError(c, "failed to resolve the importing user")
+		return "", false
+	}
+	return user.Email, true
+}
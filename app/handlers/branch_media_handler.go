@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,14 +19,25 @@ import (
 // @Security ApiKeyAuth
 // @Produce json
 // @Param branch_id path int true "Branch ID"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Router /api/branch-media/branch/{branch_id} [get]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Param limit query int false "Page size (default 20, max 100); pages the gallery by newest first"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Router /api/v1/branch-media/branch/{branch_id} [get]
 func GetBranchMediaByBranchIDHandler(c *gin.Context) {
 	branchIDParam := c.Param("branch_id")
 	branchID, err := strconv.ParseUint(branchIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch ID"})
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+	limit, cursor, paged, ok := mediaPageFromQuery(c)
+	if !ok {
+		return
+	}
+	if paged {
+		branchMediaPage(c, uint(branchID), false, limit, cursor)
 		return
 	}
 
@@ -34,53 +48,197 @@ func GetBranchMediaByBranchIDHandler(c *gin.Context) {
 	}
 
 	// Convert to presigned URLs - fail fast on errors
-	mediaListWithPresignedURLs, err := services.ConvertBranchMediaToPresignedURLs(c.Request.Context(), mediaList)
+	mediaListWithPresignedURLs, err := branchGalleryURLs(c, mediaList)
 	if err != nil {
 		// Fail fast - return HTTP 500 with structured error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to generate presigned URLs",
-			"details": err.Error(),
-		})
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Branch Media fetched successfully",
-		"data":    mediaListWithPresignedURLs,
-	})
+	utils.OK(c, "Branch Media fetched successfully", mediaListWithPresignedURLs)
 }
 
 // GetAllBranchMediaHandler retrieves all BranchMedia records
 // @Summary Get all Branch Media
-// @Description Retrieve all BranchMedia records
+// @Description Retrieve all BranchMedia records. Without paging, sort with sort=name,-created_on (fields: id, name, file_type, category, created_on, updated_on; newest first by default) and filter by any of them or branch_id, scan_status (see GET /branches for the operators). Pages are always newest first.
 // @Tags BranchMedia
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
-// @Router /api/branch-media [get]
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Param limit query int false "Page size (default 20, max 100); pages the list by newest first"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma-separated sort fields, - for descending (not with limit or cursor)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-media [get]
 func GetAllBranchMediaHandler(c *gin.Context) {
-	medias, err := services.GetAllBranchMedia()
+	limit, cursor, paged, ok := mediaPageFromQuery(c)
+	if !ok {
+		return
+	}
+	query, ok := mediaListQuery(c, services.BranchMediaListSchema, paged)
+	if !ok {
+		return
+	}
+	if paged {
+		branchMediaPage(c, 0, middleware.IncludeDeleted(c), limit, cursor)
+		return
+	}
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	medias, err := services.GetAllBranchMedia(query, middleware.IncludeDeleted(c), scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch records"})
+		utils.InternalServerError(c, "failed to fetch records")
 		return
 	}
 	
 	// Convert to presigned URLs - fail fast on errors
-	mediasWithPresignedURLs, err := services.ConvertBranchMediaToPresignedURLs(c.Request.Context(), medias)
+	mediasWithPresignedURLs, err := branchGalleryURLs(c, medias)
 	if err != nil {
 		// Fail fast - return HTTP 500 with structured error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to generate presigned URLs",
-			"details": err.Error(),
-		})
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Branch Media fetched successfully",
-		"data":    mediasWithPresignedURLs,
+	utils.OK(c, "Branch Media fetched successfully", mediasWithPresignedURLs)
+}
+
+// RestoreBranchMediaHandler restores a soft-deleted BranchMedia record
+// @Summary Restore Branch Media
+// @Description Restore a soft-deleted BranchMedia record (admin only)
+// @Tags BranchMedia
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch Media ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branch-media/{id}/restore [post]
+func RestoreBranchMediaHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid ID")
+		return
+	}
+
+	if err := services.RestoreBranchMedia(uint(id)); err != nil {
+		if errors.Is(err, services.ErrNotDeleted) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "branch media restored", nil)
+}
+
+// branchMediaPage writes one page of branch media, presigning only that page
+func branchMediaPage(c *gin.Context, branchID uint, includeDeleted bool, limit int, cursor *services.PaginationCursor) {
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	page, err := services.GetBranchMediaPaginated(branchID, includeDeleted, limit, cursor, scope)
+	if err != nil {
+		utils.InternalServerError(c, "failed to fetch records")
+		return
+	}
+	mediaList, err := branchGalleryURLs(c, page.Data)
+	if err != nil {
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+		return
+	}
+	utils.OK(c, "Branch Media fetched successfully", gin.H{
+		"data":        mediaList,
+		"next_cursor": page.NextCursor,
+		"has_more":    page.HasMore,
 	})
 }
 
+// branchGalleryURLs presigns thumbnails for gallery listings; ?include_original=true
+// also presigns the full-resolution originals
+func branchGalleryURLs(c *gin.Context, mediaList []models.BranchMedia) ([]models.BranchMedia, error) {
+	if c.Query("include_original") == "true" {
+		return services.ConvertBranchMediaToPresignedURLs(c.Request.Context(), mediaList)
+	}
+	return services.ConvertBranchMediaToGalleryURLs(c.Request.Context(), mediaList)
+}
+
+// SetBranchImageRequest selects a branch media item; a null media_id clears the image
+type SetBranchImageRequest struct {
+	MediaID *uint `json:"media_id"`
+}
+
+// SetBranchCoverImageHandler godoc
+// @Summary Set the branch cover image
+// @Description Marks one of the branch's images as its cover image, returned presigned as cover_image on branch list/detail endpoints. A null media_id clears it. Works for branches and child branches.
+// @Tags BranchMedia
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param payload body SetBranchImageRequest true "Branch media ID"
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/cover-image [put]
+// @Router /api/v1/child-branches/{id}/cover-image [put]
+func SetBranchCoverImageHandler(c *gin.Context) {
+	setBranchImage(c, services.BranchImageCover)
+}
+
+// SetBranchCoordinatorPhotoHandler godoc
+// @Summary Set the branch coordinator's photo
+// @Description Marks one of the branch's images as the coordinator's photo, returned presigned as coordinator_photo on branch list/detail endpoints. A null media_id clears it. Works for branches and child branches.
+// @Tags BranchMedia
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param payload body SetBranchImageRequest true "Branch media ID"
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/coordinator-photo [put]
+// @Router /api/v1/child-branches/{id}/coordinator-photo [put]
+func SetBranchCoordinatorPhotoHandler(c *gin.Context) {
+	setBranchImage(c, services.BranchImageCoordinatorPhoto)
+}
+
+func setBranchImage(c *gin.Context, slot string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
 
+	var req SetBranchImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	branch, err := services.SetBranchImage(uint(id), slot, req.MediaID, auditActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBranchNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidBranchImage):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	services.AttachBranchImages(c.Request.Context(), branch)
+
+	utils.OK(c, "", branch)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
-	"net/http"
+	"errors"
 	"strconv"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
@@ -18,26 +21,38 @@ import (
 // @Accept json
 // @Produce json
 // @Param childBranch body models.Branch true "Child Branch Data"
-// @Success 201 {object} models.Branch
-// @Failure 400 {object} map[string]string
-// @Router /api/child-branches [post]
+// @Success 201 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/child-branches [post]
 func CreateChildBranchHandler(c *gin.Context) {
 	var childBranch models.Branch
 
 	if err := c.ShouldBindJSON(&childBranch); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	if err := validators.ValidateCoordinates(childBranch.Latitude, childBranch.Longitude); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	// Assigned by the server (public ID) or through their own endpoints (images)
+	childBranch.PublicID = ""
+	childBranch.CoverMediaID = nil
+	childBranch.CoordinatorPhotoMediaID = nil
+
 	// Validate parent branch exists
 	if childBranch.ParentBranchID == nil || *childBranch.ParentBranchID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "parent_branch_id is required"})
+		utils.BadRequest(c, "parent_branch_id is required")
+		return
+	}
+	if !checkBranchAccess(c, childBranch.ParentBranchID) {
 		return
 	}
 
 	var parentBranch models.Branch
 	if err := config.DB.First(&parentBranch, *childBranch.ParentBranchID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent_branch_id"})
+		utils.BadRequest(c, "invalid parent_branch_id")
 		return
 	}
 
@@ -52,18 +67,18 @@ func CreateChildBranchHandler(c *gin.Context) {
 	}
 
 	if err := services.CreateChildBranch(&childBranch); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
 	// Reload with relations
 	createdBranch, err := services.GetChildBranch(childBranch.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch created child branch"})
+		utils.InternalServerError(c, "failed to fetch created child branch")
 		return
 	}
 
-	c.JSON(http.StatusCreated, createdBranch)
+	utils.Created(c, "", createdBranch)
 }
 
 // GetAllChildBranchesHandler godoc
@@ -72,15 +87,34 @@ func CreateChildBranchHandler(c *gin.Context) {
 // @Tags Child Branches
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Branch
-// @Router /api/child-branches [get]
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Param sort query string false "Comma-separated sort fields, - for descending (as GET /branches)"
+// @Param fields query string false "Comma-separated columns to return, e.g. name,parent_branch_id (id is always returned)"
+// @Param include query string false "Comma-separated associations to return: country, state, district, city, parent, children, infrastructure, branch_members, cover_image, coordinator_photo"
+// @Success 200 {object} utils.Response{data=[]models.Branch}
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/child-branches [get]
 func GetAllChildBranchesHandler(c *gin.Context) {
-	childBranches, err := services.GetAllChildBranches()
+	query, err := services.BranchListSchema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	projection, err := services.BranchFieldSet.Parse(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, childBranches)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	childBranches, err := services.GetAllChildBranches(query, projection, middleware.IncludeDeleted(c), scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	respondBranchList(c, projection, childBranches)
 }
 
 // GetChildBranchHandler godoc
@@ -90,24 +124,26 @@ func GetAllChildBranchesHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Child Branch ID"
-// @Success 200 {object} models.Branch
-// @Failure 404 {object} map[string]string
-// @Router /api/child-branches/{id} [get]
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/child-branches/{id} [get]
 func GetChildBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branch ID"})
+		utils.BadRequest(c, "invalid child branch ID")
 		return
 	}
 
 	childBranch, err := services.GetChildBranch(uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
+	services.AttachBranchImages(c.Request.Context(), childBranch)
+	setVersionETag(c, childBranch.Version)
 
-	c.JSON(http.StatusOK, childBranch)
+	utils.OK(c, "", childBranch)
 }
 
 // GetChildBranchesByParentHandler godoc
@@ -117,64 +153,84 @@ func GetChildBranchHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param parent_id path int true "Parent Branch ID"
-// @Success 200 {array} models.Branch
-// @Failure 400 {object} map[string]string
-// @Router /api/child-branches/parent/{parent_id} [get]
+// @Param sort query string false "Comma-separated sort fields, - for descending (as GET /branches)"
+// @Param fields query string false "Comma-separated columns to return (id is always returned)"
+// @Param include query string false "Comma-separated associations to return (see GET /child-branches)"
+// @Success 200 {object} utils.Response{data=[]models.Branch}
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/child-branches/parent/{parent_id} [get]
 func GetChildBranchesByParentHandler(c *gin.Context) {
 	parentIDParam := c.Param("parent_id")
 	parentID, err := strconv.ParseUint(parentIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent branch ID"})
+		utils.BadRequest(c, "invalid parent branch ID")
 		return
 	}
 
-	childBranches, err := services.GetChildBranchesByParent(uint(parentID))
+	query, err := services.BranchListSchema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	projection, err := services.BranchFieldSet.Parse(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, childBranches)
+	childBranches, err := services.GetChildBranchesByParent(uint(parentID), query, projection)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	respondBranchList(c, projection, childBranches)
 }
 
 // UpdateChildBranchHandler godoc
 // @Summary Update a child branch
-// @Description Update an existing child branch
+// @Description Update an existing child branch. Send the version last read (If-Match with the ETag of the GET, or a "version" field) to have the update rejected with 409 and the latest child branch if someone else updated it since.
 // @Tags Child Branches
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Child Branch ID"
+// @Param If-Match header string false "Version last read (the ETag of the GET)"
 // @Param childBranch body map[string]interface{} true "Update Data"
-// @Success 200 {object} models.Branch
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/child-branches/{id} [put]
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/child-branches/{id} [put]
 func UpdateChildBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branch ID"})
+		utils.BadRequest(c, "invalid child branch ID")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	version, err := expectedVersion(c, updateData)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Get the child branch to find its parent
 	var childBranch models.Branch
 	if err := config.DB.Where("id = ? AND parent_branch_id IS NOT NULL", id).First(&childBranch).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "child branch not found"})
+		utils.NotFound(c, "child branch not found")
 		return
 	}
 
 	// Get parent branch to inherit coordinator
 	var parentBranch models.Branch
 	if err := config.DB.First(&parentBranch, *childBranch.ParentBranchID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parent_branch_id"})
+		utils.BadRequest(c, "invalid parent_branch_id")
 		return
 	}
 
@@ -186,21 +242,87 @@ func UpdateChildBranchHandler(c *gin.Context) {
 	delete(updateData, "id")
 	delete(updateData, "created_on")
 	delete(updateData, "created_by")
-	delete(updateData, "parent_branch_id") // Don't allow changing parent
+	delete(updateData, "parent_branch_id") // Parent changes go through POST /child-branches/:id/transfer
+
+	// Branch images have their own endpoints (media must belong to the branch)
+	delete(updateData, "cover_media_id")
+	delete(updateData, "coordinator_photo_media_id")
+	delete(updateData, "public_id")
+	if err := validators.ValidateCoordinateFields(updateData); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 
-	if err := services.UpdateChildBranch(uint(id), updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := services.UpdateChildBranch(uint(id), updateData, version); err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Fetch updated child branch
 	updatedBranch, err := services.GetChildBranch(uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch updated child branch"})
+		utils.InternalServerError(c, "failed to fetch updated child branch")
+		return
+	}
+	setVersionETag(c, updatedBranch.Version)
+
+	utils.OK(c, "", updatedBranch)
+}
+
+// TransferChildBranchRequest is the payload for moving a child branch to another parent
+type TransferChildBranchRequest struct {
+	TargetParentID uint  `json:"target_parent_id" binding:"required"`
+	MoveMembers    *bool `json:"move_members"` // default true; false reassigns the members to the current parent
+}
+
+// TransferChildBranchHandler godoc
+// @Summary Transfer a child branch to another parent branch
+// @Description Moves a child branch under another active parent branch. The child re-inherits the new parent's coordinator. Members move with the child unless move_members is false, in which case they are reassigned to the current parent. The change is audit logged. Admin only.
+// @Tags Child Branches
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Child Branch ID"
+// @Param payload body TransferChildBranchRequest true "Transfer target"
+// @Success 200 {object} utils.Response{data=models.Branch}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/child-branches/{id}/transfer [post]
+func TransferChildBranchHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid child branch ID")
+		return
+	}
+
+	var req TransferChildBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	transfer := services.ChildBranchTransfer{TargetParentID: req.TargetParentID, MoveMembers: true}
+	if req.MoveMembers != nil {
+		transfer.MoveMembers = *req.MoveMembers
+	}
+
+	branch, err := services.TransferChildBranch(uint(id), transfer, auditActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrChildBranchNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidTransferTarget):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, updatedBranch)
+	utils.OK(c, "", branch)
 }
 
 // DeleteChildBranchHandler godoc
@@ -210,24 +332,51 @@ func UpdateChildBranchHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Child Branch ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/child-branches/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/child-branches/{id} [delete]
 func DeleteChildBranchHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branch ID"})
+		utils.BadRequest(c, "invalid child branch ID")
 		return
 	}
 
 	if err := services.DeleteChildBranch(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "child branch deleted successfully", nil)
+}
+
+// RestoreChildBranchHandler godoc
+// @Summary Restore a deleted child branch
+// @Description Restore a soft-deleted child branch (admin only)
+// @Tags Child Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Child Branch ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/child-branches/{id}/restore [post]
+func RestoreChildBranchHandler(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid child branch ID")
+		return
+	}
+
+	if err := services.RestoreChildBranch(uint(id)); err != nil {
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "child branch deleted successfully"})
+	utils.OK(c, "child branch restored successfully", nil)
 }
 
 // *************************************** Child Branch Infrastructure Handlers ****************************************************** //
@@ -240,22 +389,22 @@ func DeleteChildBranchHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param infrastructure body models.BranchInfrastructure true "Infrastructure Data"
-// @Success 201 {object} models.BranchInfrastructure
-// @Failure 400 {object} map[string]string
-// @Router /api/child-branches/{id}/infrastructure [post]
+// @Success 201 {object} utils.Response{data=models.BranchInfrastructure}
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/child-branches/{id}/infrastructure [post]
 func CreateChildBranchInfrastructureHandler(c *gin.Context) {
 	var infra models.BranchInfrastructure
 	if err := c.ShouldBindJSON(&infra); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.CreateChildBranchInfrastructure(&infra); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, infra)
+	utils.Created(c, "", infra)
 }
 
 // GetChildBranchInfrastructureHandler godoc
@@ -265,23 +414,23 @@ func CreateChildBranchInfrastructureHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Child Branch ID"
-// @Success 200 {array} models.BranchInfrastructure
-// @Router /api/child-branches/{id}/infrastructure [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchInfrastructure}
+// @Router /api/v1/child-branches/{id}/infrastructure [get]
 func GetChildBranchInfrastructureHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branch ID"})
+		utils.BadRequest(c, "invalid child branch ID")
 		return
 	}
 
 	infra, err := services.GetInfrastructureByChildBranch(uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, infra)
+	utils.OK(c, "", infra)
 }
 
 // *************************************** Child Branch Member Handlers ****************************************************** //
@@ -294,22 +443,22 @@ func GetChildBranchInfrastructureHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param member body models.BranchMember true "Member Data"
-// @Success 201 {object} models.BranchMember
-// @Failure 400 {object} map[string]string
-// @Router /api/child-branches/{id}/members [post]
+// @Success 201 {object} utils.Response{data=models.BranchMember}
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/child-branches/{id}/members [post]
 func CreateChildBranchMemberHandler(c *gin.Context) {
 	var member models.BranchMember
 	if err := c.ShouldBindJSON(&member); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.CreateChildBranchMember(&member); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, member)
+	utils.Created(c, "", member)
 }
 
 // GetChildBranchMembersHandler godoc
@@ -319,22 +468,22 @@ func CreateChildBranchMemberHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Child Branch ID"
-// @Success 200 {array} models.BranchMember
-// @Router /api/child-branches/{id}/members [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchMember}
+// @Router /api/v1/child-branches/{id}/members [get]
 func GetChildBranchMembersHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid child branch ID"})
+		utils.BadRequest(c, "invalid child branch ID")
 		return
 	}
 
 	members, err := services.GetMembersByChildBranch(uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, members)
+	utils.OK(c, "", members)
 }
 
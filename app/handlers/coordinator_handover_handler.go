@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// CoordinatorHandoverRequest names the new coordinator of a branch
+type CoordinatorHandoverRequest struct {
+	ToUserID        uint   `json:"to_user_id" binding:"required"`
+	CoordinatorName string `json:"coordinator_name" binding:"max=255"` // defaults to the user's name
+	Note            string `json:"note" binding:"max=2000"`
+}
+
+// HandOverBranchHandler godoc
+// @Summary Hand a branch over to a new coordinator
+// @Description Makes to_user_id the coordinator of a top-level branch: previous coordinators (users whose branch_id is the branch) lose it, coordinator_name is updated on the branch and the child branches that inherit it, pending change requests of the previous coordinators move to the new one, and review outcomes of events they submitted are emailed to the new coordinator. Admins and managers of the branch's region only. Recorded in the audit log (action handover) and in the branch's handover history.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param request body CoordinatorHandoverRequest true "New coordinator"
+// @Success 201 {object} utils.Response{data=models.CoordinatorHandover}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/handover [post]
+func HandOverBranchHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid branch ID")
+		return
+	}
+	var req CoordinatorHandoverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	handover, err := services.HandOverBranch(uint(branchID), services.CoordinatorHandoverInput{
+		ToUserID:        req.ToUserID,
+		CoordinatorName: req.CoordinatorName,
+		Note:            req.Note,
+	}, auditActor(c))
+	if err != nil {
+		respondHandoverError(c, err)
+		return
+	}
+	utils.Created(c, "Branch handed over", handover)
+}
+
+// GetCoordinatorHandoversHandler godoc
+// @Summary List a branch's coordinator handovers
+// @Description Returns the coordinator handovers of a branch, newest first, with what moved to the new coordinator.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Success 200 {object} utils.Response{data=[]models.CoordinatorHandover}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/handovers [get]
+func GetCoordinatorHandoversHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid branch ID")
+		return
+	}
+	handovers, err := services.ListCoordinatorHandovers(uint(branchID))
+	if err != nil {
+		respondHandoverError(c, err)
+		return
+	}
+	utils.OK(c, "", handovers)
+}
+
+func respondHandoverError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBranchNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidHandover):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrHandoverNotAllowed):
+		utils.Forbidden(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
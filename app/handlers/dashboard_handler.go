@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetDashboardHandler godoc
+// @Summary Get the dashboard
+// @Description Returns all dashboard sections in one call: events by month, beneficiary/initiation trends, top branches, donations by type and media counts. Dates filter on the event start date.
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Param limit query int false "Number of top branches (default 10, max 100)"
+// @Success 200 {object} utils.Response{data=services.Dashboard}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard [get]
+func GetDashboardHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "all", services.GetDashboard)
+	})
+}
+
+// GetDashboardEventsByMonthHandler godoc
+// @Summary Get events by month
+// @Description Number of events (and completed events) per month of the event start date
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=[]services.DashboardMonthEvents}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/events-by-month [get]
+func GetDashboardEventsByMonthHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "events_by_month", services.GetDashboardEventsByMonth)
+	})
+}
+
+// GetDashboardTrendsHandler godoc
+// @Summary Get beneficiary and initiation trends
+// @Description Beneficiaries and initiations (men, women, children and totals) per month of the event start date
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=[]services.DashboardMonthTrend}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/trends [get]
+func GetDashboardTrendsHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "trends", services.GetDashboardTrends)
+	})
+}
+
+// GetDashboardTopBranchesHandler godoc
+// @Summary Get the most active branches
+// @Description Branches ranked by number of events, then beneficiaries, with their initiations and donations
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Param limit query int false "Number of branches (default 10, max 100)"
+// @Success 200 {object} utils.Response{data=[]services.DashboardBranchActivity}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/top-branches [get]
+func GetDashboardTopBranchesHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "top_branches", services.GetDashboardTopBranches)
+	})
+}
+
+// GetDashboardDonationsHandler godoc
+// @Summary Get donation totals by type
+// @Description Number and amount of donations per donation type, largest amount first
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=[]services.DashboardDonationType}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/donations [get]
+func GetDashboardDonationsHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "donations", services.GetDashboardDonations)
+	})
+}
+
+// GetDashboardMediaHandler godoc
+// @Summary Get media counts
+// @Description Event and branch media counts by file type. Branch media is filtered on its upload date.
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=services.DashboardMediaCounts}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/media [get]
+func GetDashboardMediaHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.CachedDashboard(filter, "media", services.GetDashboardMedia)
+	})
+}
+
+// GetYearOverYearHandler godoc
+// @Summary Compare an event type or category year over year
+// @Description Compares the events of an event type or category (a recurring event, optionally narrowed by theme) across financial years for annual reporting: events, beneficiaries, initiations, volunteers, donations, donation total and media, each with its delta and percentage growth (growth_pct) from the previous listed year. growth_pct is null when the previous value is 0. Events count in the financial year of their start date; archived years are included.
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_type_id query int false "Event type (event_type_id or event_category_id is required)"
+// @Param event_category_id query int false "Event category"
+// @Param theme query string false "Only events whose theme contains this text"
+// @Param years query string false "Comma-separated financial years, e.g. 2023-24,2024-25 (default: the last three up to the current one, at most 10)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=services.EventComparison}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/year-over-year [get]
+func GetYearOverYearHandler(c *gin.Context) {
+	var comparison services.EventComparisonFilter
+	for param, id := range map[string]*uint{"event_type_id": &comparison.EventTypeID, "event_category_id": &comparison.EventCategoryID} {
+		if value := c.Query(param); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+param)
+				return
+			}
+			*id = uint(parsed)
+		}
+	}
+	years, err := services.ParseComparisonYears(c.Query("years"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	comparison.Years = years
+	comparison.Theme = strings.TrimSpace(c.Query("theme"))
+
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		comparison.BranchID = filter.BranchID
+		comparison.IncludeChildren = filter.IncludeChildren
+		comparison.Scope = filter.Scope
+		return services.GetEventComparison(comparison)
+	})
+}
+
+// GetBranchRollupHandler godoc
+// @Summary Roll branch activity up the branch hierarchy
+// @Description Returns the branch tree with each branch's own figures (events, beneficiaries, initiations, donations, members, infrastructure by type) and its totals rolled up over every branch below it. With branch_id the tree of that branch; without it one tree per top branch the user can see. Sandbox branches are left out. Events and donations of archived years are included.
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param branch_id query int false "Branch ID"
+// @Success 200 {object} utils.Response{data=[]services.BranchRollupNode}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/branch-rollup [get]
+func GetBranchRollupHandler(c *gin.Context) {
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.GetBranchRollup(filter.BranchID, filter.Scope)
+	})
+}
+
+// GetLocationRollupHandler godoc
+// @Summary Roll branch activity up by state or country
+// @Description Adds up the figures of the branches (as in GET /dashboard/branch-rollup) by the state or country each branch is in, most events first. Branches without a location are grouped as "Unassigned" with a null id.
+// @Tags Dashboard
+// @Security ApiKeyAuth
+// @Produce json
+// @Param level query string false "state (default) or country"
+// @Param branch_id query int false "Only this branch and the branches below it"
+// @Success 200 {object} utils.Response{data=[]services.LocationRollup}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/dashboard/location-rollup [get]
+func GetLocationRollupHandler(c *gin.Context) {
+	level := c.DefaultQuery("level", services.RollupLevelState)
+	dashboardSection(c, func(filter services.DashboardFilter) (interface{}, error) {
+		return services.GetLocationRollup(level, filter.BranchID, filter.Scope)
+	})
+}
+
+// dashboardSection parses the shared filter query and writes the section computed by load
+func dashboardSection(c *gin.Context, load func(services.DashboardFilter) (interface{}, error)) {
+	filter, ok := dashboardFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	result, err := load(filter)
+	if err != nil {
+		if errors.Is(err, services.ErrBranchNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrOutOfBranchScope) {
+			utils.Forbidden(c, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrInvalidComparison) || errors.Is(err, services.ErrInvalidRollupLevel) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", result)
+}
+
+func dashboardFilterFromQuery(c *gin.Context) (services.DashboardFilter, bool) {
+	var filter services.DashboardFilter
+	if value := c.Query("branch_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid branch_id")
+			return filter, false
+		}
+		filter.BranchID = uint(id)
+	}
+	if value := c.Query("include_children"); value != "" {
+		includeChildren, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.BadRequest(c, "include_children must be true or false")
+			return filter, false
+		}
+		filter.IncludeChildren = includeChildren
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid from (use YYYY-MM-DD)")
+			return filter, false
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid to (use YYYY-MM-DD)")
+			return filter, false
+		}
+		// Include the whole "to" day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		utils.BadRequest(c, "to must not be before from")
+		return filter, false
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return filter, false
+	}
+	filter.Scope = scope
+	return filter, true
+}
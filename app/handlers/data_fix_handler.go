@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ReassignEventBranchRequest is the payload for moving an event to another branch
+type ReassignEventBranchRequest struct {
+	EventID        uint  `json:"event_id" binding:"required"`
+	BranchID       uint  `json:"branch_id" binding:"required"`
+	IncludeRelated bool  `json:"include_related"` // also move the event's volunteers and donations
+	DryRun         *bool `json:"dry_run"`         // defaults to true
+}
+
+// SwapMediaOwnerRequest is the payload for moving a media record to another owner
+type SwapMediaOwnerRequest struct {
+	MediaType  string `json:"media_type" binding:"required"` // event or branch
+	MediaID    uint   `json:"media_id" binding:"required"`
+	NewOwnerID uint   `json:"new_owner_id" binding:"required"` // event ID or branch ID
+	DryRun     *bool  `json:"dry_run"`                         // defaults to true
+}
+
+// FixEventDatesRequest is the payload for correcting dates on several events at once.
+// Either start_date/end_date (YYYY-MM-DD or RFC3339) or shift_days must be set.
+type FixEventDatesRequest struct {
+	EventIDs  []uint `json:"event_ids" binding:"required"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	ShiftDays int    `json:"shift_days"`
+	DryRun    *bool  `json:"dry_run"` // defaults to true
+}
+
+// ReassignAttributionRequest is the payload for re-pointing records from one user account to another
+type ReassignAttributionRequest struct {
+	FromUserID uint     `json:"from_user_id" binding:"required"`
+	ToUserID   uint     `json:"to_user_id" binding:"required"` // same as from_user_id for a renamed user
+	FromNames  []string `json:"from_names"`                    // other created_by/updated_by spellings of the old account
+	DryRun     *bool    `json:"dry_run"`                       // defaults to true
+}
+
+// ReassignEventBranchHandler godoc
+// @Summary Reassign an event to another branch
+// @Description Data fix: move an event (and optionally its volunteers and donations) to the correct branch. Runs as a dry-run preview unless dry_run is false. Admin only.
+// @Tags DataFixes
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body ReassignEventBranchRequest true "Reassignment"
+// @Success 200 {object} utils.Response{data=services.DataFixResult}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/data-fixes/reassign-event-branch [post]
+func ReassignEventBranchHandler(c *gin.Context) {
+	var req ReassignEventBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := services.ReassignEventBranch(req.EventID, req.BranchID, req.IncludeRelated, dataFixDryRun(req.DryRun), auditActor(c))
+	respondDataFix(c, result, err)
+}
+
+// SwapMediaOwnerHandler godoc
+// @Summary Move a media record to another owner
+// @Description Data fix: move an event media record to another event, or a branch media record to another branch. Runs as a dry-run preview unless dry_run is false. Admin only.
+// @Tags DataFixes
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body SwapMediaOwnerRequest true "Media owner change"
+// @Success 200 {object} utils.Response{data=services.DataFixResult}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/data-fixes/swap-media-owner [post]
+func SwapMediaOwnerHandler(c *gin.Context) {
+	var req SwapMediaOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := services.SwapMediaOwner(req.MediaType, req.MediaID, req.NewOwnerID, dataFixDryRun(req.DryRun), auditActor(c))
+	respondDataFix(c, result, err)
+}
+
+// FixEventDatesHandler godoc
+// @Summary Fix event dates in bulk
+// @Description Data fix: set start/end dates on up to 500 events, or shift their dates by a number of days. Runs as a dry-run preview unless dry_run is false. Admin only.
+// @Tags DataFixes
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body FixEventDatesRequest true "Date correction"
+// @Success 200 {object} utils.Response{data=services.DataFixResult}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/data-fixes/event-dates [post]
+func FixEventDatesHandler(c *gin.Context) {
+	var req FixEventDatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	fix := services.EventDateFix{EventIDs: req.EventIDs, ShiftDays: req.ShiftDays}
+	for _, date := range []struct {
+		name   string
+		value  string
+		target **time.Time
+	}{
+		{"start_date", req.StartDate, &fix.StartDate},
+		{"end_date", req.EndDate, &fix.EndDate},
+	} {
+		if date.value == "" {
+			continue
+		}
+		t, err := parseAuditTime(date.value)
+		if err != nil {
+			utils.BadRequest(c, "invalid "+date.name+" (use RFC3339 or YYYY-MM-DD)")
+			return
+		}
+		*date.target = &t
+	}
+
+	result, err := services.FixEventDates(fix, dataFixDryRun(req.DryRun), auditActor(c))
+	respondDataFix(c, result, err)
+}
+
+// ReassignAttributionHandler godoc
+// @Summary Reassign record attribution to another user
+// @Description Data fix after merging or renaming user accounts: re-points created_by/updated_by on all tables (text columns matching the old account's email, name or from_names), the user ID columns that attribute records to a user (reviewers, job owners, ...) and audit log actors, in one transaction. Runs as a dry-run preview with per-column row counts unless dry_run is false. Admin only.
+// @Tags DataFixes
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body ReassignAttributionRequest true "Old and new account"
+// @Success 200 {object} utils.Response{data=services.AttributionResult}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response "Records of an archived financial year would change"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/data-fixes/reassign-attribution [post]
+func ReassignAttributionHandler(c *gin.Context) {
+	var req ReassignAttributionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := services.ReassignUserAttribution(req.FromUserID, req.ToUserID, req.FromNames, dataFixDryRun(req.DryRun), auditActor(c))
+	if err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		respondDataFix(c, nil, err)
+		return
+	}
+	utils.OK(c, "", result)
+}
+
+// dataFixDryRun makes dry runs the default so a fix is only applied when explicitly requested
+func dataFixDryRun(dryRun *bool) bool {
+	return dryRun == nil || *dryRun
+}
+
+func respondDataFix(c *gin.Context, result *services.DataFixResult, err error) {
+	switch {
+	case err == nil:
+		utils.OK(c, "", result)
+	case errors.Is(err, services.ErrDataFixInvalidArgs), errors.Is(err, services.ErrDataFixNoChanges):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrDataFixNotFound):
+		utils.NotFound(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
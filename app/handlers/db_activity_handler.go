@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RunningQueriesResponse lists the long running statements and the statement timeout the
+// API's own sessions run with
+type RunningQueriesResponse struct {
+	StatementTimeoutMs int64                   `json:"statement_timeout_ms"`
+	Queries            []services.RunningQuery `json:"queries"`
+}
+
+// GetRunningQueriesHandler godoc
+// @Summary List long running queries
+// @Description Lists the statements running on the database for at least min_duration, longest first, from pg_stat_activity. The API's own sessions have application_name djjs-api and are cancelled by the server after DB_STATEMENT_TIMEOUT. Admin only.
+// @Tags Database
+// @Security ApiKeyAuth
+// @Produce json
+// @Param min_duration query string false "Minimum running time as a duration, e.g. 500ms or 10s (default 5s)"
+// @Success 200 {object} utils.Response{data=RunningQueriesResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/db-queries [get]
+func GetRunningQueriesHandler(c *gin.Context) {
+	minDuration, err := time.ParseDuration(c.DefaultQuery("min_duration", "5s"))
+	if err != nil || minDuration < 0 {
+		utils.BadRequest(c, "invalid min_duration (use a duration such as 500ms or 10s)")
+		return
+	}
+
+	queries, err := services.ListRunningQueries(minDuration)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", RunningQueriesResponse{
+		StatementTimeoutMs: config.StatementTimeout.Milliseconds(),
+		Queries:            queries,
+	})
+}
+
+// CancelQueryHandler godoc
+// @Summary Cancel a running query
+// @Description Cancels the statement running on the backend (pg_cancel_backend). With terminate=true the connection is closed instead (pg_terminate_backend), e.g. for sessions idle in a transaction that hold locks. Admin only.
+// @Tags Database
+// @Security ApiKeyAuth
+// @Produce json
+// @Param pid path int true "Backend PID from the running query list"
+// @Param terminate query bool false "Close the connection instead of cancelling the statement"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/db-queries/{pid}/cancel [post]
+func CancelQueryHandler(c *gin.Context) {
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil || pid <= 0 {
+		utils.BadRequest(c, "invalid pid")
+		return
+	}
+	terminate := false
+	if value := c.Query("terminate"); value != "" {
+		if terminate, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "terminate must be true or false")
+			return
+		}
+	}
+
+	if err := services.CancelQuery(pid, terminate); err != nil {
+		if errors.Is(err, services.ErrQueryNotRunning) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	actorID, _ := middleware.CurrentUserID(c)
+	utils.Logger(c.Request.Context()).Warn("Database query cancelled by admin",
+		zap.Int("pid", pid), zap.Bool("terminate", terminate), zap.Uint("admin_id", actorID))
+	if terminate {
+		utils.OK(c, "Connection terminated", nil)
+		return
+	}
+	utils.OK(c, "Query cancelled", nil)
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -17,49 +23,76 @@ import (
 // @Accept json
 // @Produce json
 // @Param donation body models.Donation true "Donation Payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/donations [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations [post]
 func CreateDonation(c *gin.Context) {
 	var donation models.Donation
 
 	if err := c.ShouldBindJSON(&donation); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateDonationInput(donation.EventID, donation.BranchID, donation.DonationType, donation.Amount); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// OCR text is only ever written by the OCR worker, and receipts are numbered from the
+	// branch's series (models.Donation.BeforeCreate) and voided through VoidDonationReceipt
+	donation.OCRText = ""
+	donation.ReceiptNumber = ""
+	donation.VoidedOn = nil
+	donation.VoidedBy = nil
+	donation.VoidReason = ""
+
+	if !checkBranchAccess(c, &donation.BranchID) || !checkRecordAccess(c, services.ScopeEvent, donation.EventID) {
 		return
 	}
 
 	if err := services.CreateDonation(&donation); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":  "Donation created successfully",
+	utils.Created(c, "Donation created successfully", gin.H{
 		"donation": donation,
 	})
 }
 
 // GetAllDonations godoc
 // @Summary Get all donations
+// @Description Sort with sort=-amount,donor_name (fields: id, donation_type, amount, donor_name, receipt_number, created_on, updated_on; newest first by default) and filter by any of them or event_id, branch_id, kind_type, voided_on (see GET /branches for the operators).
 // @Tags Donations
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Donation
-// @Failure 500 {object} map[string]string
-// @Router /api/donations [get]
+// @Param sort query string false "Comma-separated sort fields, - for descending"
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Success 200 {object} utils.Response{data=[]models.Donation}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations [get]
 func GetAllDonations(c *gin.Context) {
-	donations, err := services.GetAllDonations()
+	query, err := services.DonationListSchema.Parse(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, donations)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	donations, err := services.GetAllDonations(query, middleware.IncludeDeleted(c), scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", donations)
 }
 
 // GetDonationsByEvent godoc
@@ -69,26 +102,26 @@ func GetAllDonations(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {array} models.Donation
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/events/{event_id}/donations [get]
+// @Success 200 {object} utils.Response{data=[]models.Donation}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/events/{event_id}/donations [get]
 func GetDonationsByEvent(c *gin.Context) {
 	eventIDParam := c.Param("event_id")
 
 	eventID, err := strconv.ParseUint(eventIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		utils.BadRequest(c, "invalid event ID")
 		return
 	}
 
 	donations, err := services.GetDonationsByEvent(uint(eventID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, donations)
+	utils.OK(c, "", donations)
 }
 
 // UpdateDonation godoc
@@ -99,35 +132,43 @@ func GetDonationsByEvent(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Donation ID"
 // @Param donation body map[string]interface{} true "Updated fields"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Router /api/donations/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response "Receipt has been voided"
+// @Router /api/v1/donations/{id} [put]
 func UpdateDonation(c *gin.Context) {
 	idStr := c.Param("id")
 
 	donationID, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid donation ID"})
+		utils.BadRequest(c, "Invalid donation ID")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateDonationUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.UpdateDonation(uint(donationID), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDonationVoided) {
+			utils.Conflict(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Donation updated successfully"})
+	utils.OK(c, "Donation updated successfully", nil)
 }
 
 // DeleteDonation godoc
@@ -136,22 +177,261 @@ func UpdateDonation(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Donation ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Router /api/donations/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/donations/{id} [delete]
 func DeleteDonation(c *gin.Context) {
 	idStr := c.Param("id")
 
 	donationID, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid donation ID"})
+		utils.BadRequest(c, "Invalid donation ID")
 		return
 	}
 
 	if err := services.DeleteDonation(uint(donationID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "Donation deleted successfully", nil)
+}
+
+// RestoreDonation godoc
+// @Summary Restore a deleted donation
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Donation ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/donations/{id}/restore [post]
+func RestoreDonation(c *gin.Context) {
+	donationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid donation ID")
+		return
+	}
+
+	if err := services.RestoreDonation(uint(donationID)); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrNotDeleted) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "Donation restored successfully", nil)
+}
+
+// SearchDonations godoc
+// @Summary Search donations
+// @Description Search donations by remarks and text extracted from uploaded receipts (e.g. "Sharma Caterers")
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string true "Search term"
+// @Success 200 {object} utils.Response{data=[]models.Donation}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations/search [get]
+func SearchDonations(c *gin.Context) {
+	searchTerm := c.Query("search")
+	if searchTerm == "" {
+		utils.BadRequest(c, "search parameter is required")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	donations, err := services.SearchDonations(searchTerm, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", donations)
+}
+
+// UploadDonationReceipt godoc
+// @Summary Upload donation receipt
+// @Description Upload a receipt image/PDF for a donation. Text is extracted in the background (OCR) and made searchable.
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Donation ID"
+// @Param file formData file true "Receipt file"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations/{id}/receipt [post]
+func UploadDonationReceipt(c *gin.Context) {
+	donationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid donation ID")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "file is required")
+		return
+	}
+
+	contentType := file.Header.Get("Content-Type")
+	if !services.ValidateFileType(contentType) {
+		utils.BadRequest(c, "file type not allowed")
+		return
+	}
+
+	fileType := services.GetFileTypeFromContentType(contentType)
+	if err := services.ValidateFileSize(file.Size, fileType); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "failed to open file")
+		return
+	}
+	defer src.Close()
+
+	fileData, err := io.ReadAll(src)
+	if err != nil {
+		utils.InternalServerError(c, "failed to read file")
+		return
+	}
+
+	// Receipts photographed with a phone: strip EXIF, apply orientation, convert HEIC
+	normalized, err := services.NormalizeImage(c.Request.Context(), fileData, contentType, file.Filename)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	fileData, contentType = normalized.Data, normalized.ContentType
+
+	uploadResult, err := services.UploadFile(c.Request.Context(), fileData, normalized.Filename, contentType, "receipts")
+	if err != nil {
+		utils.InternalServerError(c, "failed to upload file")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Donation deleted successfully"})
+	if err := services.AttachDonationReceipt(uint(donationID), uploadResult.S3Key); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if err.Error() == "donation not found" {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	services.QueueOCR(c.Request.Context(), services.OCRTargetDonation, uint(donationID), uploadResult.S3Key, contentType)
+
+	utils.OK(c, "Receipt uploaded successfully", gin.H{
+		"donation_id":    donationID,
+		"receipt_s3_key": uploadResult.S3Key,
+		"ocr_eligible":   services.IsOCRSupported(contentType),
+	})
+}
+
+// VoidDonationReceiptRequest is the body of POST /donations/:id/void
+type VoidDonationReceiptRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// VoidDonationReceipt godoc
+// @Summary Void a donation receipt
+// @Description Cancels the receipt of a donation. The receipt number stays allocated so the branch's series has no gaps, the receipt PDF is stamped VOID and the donation can no longer be edited. Audited against the donation. Admin or manager only.
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Donation ID"
+// @Param request body VoidDonationReceiptRequest true "Reason for the cancellation"
+// @Success 200 {object} utils.Response{data=models.Donation}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response "Already voided"
+// @Failure 423 {object} utils.Response "Financial year archived"
+// @Router /api/v1/donations/{id}/void [post]
+func VoidDonationReceipt(c *gin.Context) {
+	donationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid donation ID")
+		return
+	}
+
+	var req VoidDonationReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	donation, err := services.VoidDonationReceipt(uint(donationID), req.Reason, auditActor(c))
+	if err != nil {
+		respondDonationReceiptError(c, err)
+		return
+	}
+	utils.OK(c, "Donation receipt voided", donation)
+}
+
+// GetDonationReceiptPDF godoc
+// @Summary Download a donation receipt
+// @Description Renders the receipt of a donation as PDF, numbered in the branch's series for the financial year (e.g. RCPT/12/2025-26/000042). Donations recorded before receipts were numbered get their number on first download. Voided receipts are stamped VOID.
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Produce application/pdf
+// @Param id path int true "Donation ID"
+// @Success 200 {file} file "Receipt PDF file"
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations/{id}/receipt.pdf [get]
+func GetDonationReceiptPDF(c *gin.Context) {
+	donationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid donation ID")
+		return
+	}
+
+	pdfBytes, donation, err := services.GenerateDonationReceiptPDF(uint(donationID))
+	if err != nil {
+		respondDonationReceiptError(c, err)
+		return
+	}
+
+	filename := strings.ReplaceAll(donation.ReceiptNumber, "/", "_") + ".pdf"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+func respondDonationReceiptError(c *gin.Context, err error) {
+	if respondArchivedYear(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidVoid):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrDonationNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrDonationVoided):
+		utils.Conflict(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB opens the migrated database in TEST_DATABASE_URL inside a transaction that is rolled
+// back when the test ends, and makes it config.DB for the test. The test is skipped without
+// TEST_DATABASE_URL.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("begin transaction: %v", tx.Error)
+	}
+	previous := config.DB
+	config.DB = tx
+	t.Cleanup(func() {
+		config.DB = previous
+		tx.Rollback()
+	})
+	return tx
+}
+
+func TestCreateDonationAssignsReceiptNumber(t *testing.T) {
+	tx := testDB(t)
+
+	var branchID, eventID uint
+	contact := fmt.Sprintf("9%09d", time.Now().UnixNano()%1e9)
+	if err := tx.Raw(`INSERT INTO branches (name, contact_number) VALUES (?, ?) RETURNING id`,
+		"Receipt test branch", contact).Scan(&branchID).Error; err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	if err := tx.Raw(`INSERT INTO event_details (branch_id, start_date, end_date) VALUES (?, CURRENT_DATE, CURRENT_DATE) RETURNING id`,
+		branchID).Scan(&eventID).Error; err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/donations", func(c *gin.Context) { c.Set("roleID", models.RoleAdmin) }, CreateDonation)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"event_id":       eventID,
+		"branch_id":      branchID,
+		"donation_type":  "cash",
+		"amount":         501,
+		"receipt_number": "RCPT/FORGED/000001",
+		"voided_on":      time.Now(),
+		"void_reason":    "forged",
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/donations", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	var stored models.Donation
+	if err := tx.Where("event_id = ?", eventID).First(&stored).Error; err != nil {
+		t.Fatalf("load donation: %v", err)
+	}
+	prefix := fmt.Sprintf("RCPT/%d/%s/", branchID, sequence.FinancialYear(time.Now()))
+	if !strings.HasPrefix(stored.ReceiptNumber, prefix) {
+		t.Errorf("receipt_number = %q, want the next number of the branch's series (%s...)", stored.ReceiptNumber, prefix)
+	}
+	if stored.VoidedOn != nil || stored.VoidedBy != nil || stored.VoidReason != "" {
+		t.Errorf("new donation is voided: voided_on=%v voided_by=%v void_reason=%q", stored.VoidedOn, stored.VoidedBy, stored.VoidReason)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetDonationSummaryHandler godoc
+// @Summary Get a donation summary
+// @Description Totals donations grouped by any of event, branch, type (cash/kind/online) and month, largest amount first. Dates filter on the event start date. Voided receipts are left out.
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param group_by query string false "Comma-separated groupings: event, branch, type, month (none for the grand total)"
+// @Param donation_type query string false "Only donations of this type"
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=services.DonationSummary}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations/summary [get]
+func GetDonationSummaryHandler(c *gin.Context) {
+	filter, ok := dashboardFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	var groupBy []string
+	if value := c.Query("group_by"); value != "" {
+		groupBy = strings.Split(value, ",")
+	}
+	summary, err := services.GetDonationSummary(filter, groupBy, strings.TrimSpace(c.Query("donation_type")))
+	if err != nil {
+		respondDonationReportError(c, err)
+		return
+	}
+	utils.OK(c, "", summary)
+}
+
+// GetDonationReconciliationHandler godoc
+// @Summary Reconcile declared and itemized donations
+// @Description Compares, per event, the declared donation total with the sum of its itemized donations (voided receipts excluded) and flags mismatches. Events with neither are left out.
+// @Tags Donations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Only rows with this status: matched, mismatch or not_declared"
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param include_children query bool false "With branch_id: roll up child branches"
+// @Success 200 {object} utils.Response{data=services.DonationReconciliation}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/donations/reconciliation [get]
+func GetDonationReconciliationHandler(c *gin.Context) {
+	filter, ok := dashboardFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	report, err := services.GetDonationReconciliation(filter, strings.ToLower(strings.TrimSpace(c.Query("status"))))
+	if err != nil {
+		respondDonationReportError(c, err)
+		return
+	}
+	utils.OK(c, "", report)
+}
+
+func respondDonationReportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDonationReport):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrBranchNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrOutOfBranchScope):
+		utils.Forbidden(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetEventAttendanceHandler godoc
+// @Summary Get the attendance of an event
+// @Description Returns the attendance recorded for each day of the event by age band (child, youth, adult, senior), split into male and female and into new and returning attendees, with totals per day and for the event
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response{data=services.EventAttendanceSummary}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/attendance [get]
+func GetEventAttendanceHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	summary, err := services.GetEventAttendance(uint(eventID))
+	if err != nil {
+		respondEventAttendanceError(c, err)
+		return
+	}
+	utils.OK(c, "", summary)
+}
+
+// SetEventAttendanceDayHandler godoc
+// @Summary Record the attendance of a day of an event
+// @Description Replaces the attendance of one day of the event, which must fall within its dates; age bands left out are removed. The event's beneficiary figures are recomputed from all of its days: the child band counts as children, the other bands as men and women.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param day path string true "Day (YYYY-MM-DD)"
+// @Param attendance body services.EventAttendanceDayInput true "Attendance by age band"
+// @Success 200 {object} utils.Response{data=services.EventAttendanceSummary}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/attendance/{day} [put]
+func SetEventAttendanceDayHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	var input services.EventAttendanceDayInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	var userID *uint
+	if id, ok := middleware.CurrentUserID(c); ok {
+		userID = &id
+	}
+
+	summary, err := services.SetEventAttendanceDay(uint(eventID), c.Param("day"), input, userID)
+	if err != nil {
+		respondEventAttendanceError(c, err)
+		return
+	}
+	utils.OK(c, "Attendance saved", summary)
+}
+
+// DeleteEventAttendanceDayHandler godoc
+// @Summary Remove the attendance of a day of an event
+// @Description Removes the attendance of one day and recomputes the event's beneficiary figures from the remaining days. When no day is left, the figures are kept as they were.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param day path string true "Day (YYYY-MM-DD)"
+// @Success 200 {object} utils.Response{data=services.EventAttendanceSummary}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/attendance/{day} [delete]
+func DeleteEventAttendanceDayHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	summary, err := services.DeleteEventAttendanceDay(uint(eventID), c.Param("day"))
+	if err != nil {
+		respondEventAttendanceError(c, err)
+		return
+	}
+	utils.OK(c, "Attendance removed", summary)
+}
+
+func respondEventAttendanceError(c *gin.Context, err error) {
+	if respondArchivedYear(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrEventNotFound), errors.Is(err, services.ErrAttendanceNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidAttendance):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
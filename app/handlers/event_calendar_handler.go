@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetEventCalendarHandler godoc
+// @Summary Event calendar
+// @Description Returns the events taking place between from and to (inclusive, at most 366 days) bucketed by day for calendar rendering. Multi-day events appear on each of their days; recurring series are expanded into their occurrences within the window. Only days with events are listed.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string true "First day (YYYY-MM-DD)"
+// @Param to query string true "Last day (YYYY-MM-DD)"
+// @Param branch_id query int false "Only events of this branch"
+// @Success 200 {object} utils.Response{data=services.EventCalendar}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/calendar [get]
+func GetEventCalendarHandler(c *gin.Context) {
+	from, to, err := services.ParseCalendarRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	var branchID *uint
+	if value := c.Query("branch_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			utils.BadRequest(c, "Invalid branch ID")
+			return
+		}
+		branch := uint(id)
+		branchID = &branch
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	calendar, err := services.GetEventCalendar(from, to, branchID, scope)
+	if err != nil {
+		utils.InternalServerError(c, "failed to load event calendar")
+		return
+	}
+	utils.OK(c, "", calendar)
+}
+
+// GetEventRecurrenceHandler godoc
+// @Summary Get the recurrence of an event
+// @Description Returns the schedule on which the event repeats
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response{data=models.EventRecurrence}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/recurrence [get]
+func GetEventRecurrenceHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	recurrence, err := services.GetEventRecurrence(uint(eventID))
+	if err != nil {
+		respondEventRecurrenceError(c, err)
+		return
+	}
+	utils.OK(c, "", recurrence)
+}
+
+// SetEventRecurrenceHandler godoc
+// @Summary Make an event recurring
+// @Description Makes the event the first occurrence of a series (e.g. a weekly satsang) or replaces the schedule of its series. Weekly series repeat every interval weeks on the given weekdays (0 = Sunday; default the event's weekday), monthly ones on the event's day of the month. The series ends on until or after count occurrences; except_dates skips single occurrences. Occurrences have the event's length and daily times.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param recurrence body services.EventRecurrenceInput true "Schedule"
+// @Success 200 {object} utils.Response{data=models.EventRecurrence}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/recurrence [put]
+func SetEventRecurrenceHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	var input services.EventRecurrenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	var userID *uint
+	if id, ok := middleware.CurrentUserID(c); ok {
+		userID = &id
+	}
+
+	recurrence, err := services.SetEventRecurrence(uint(eventID), input, userID)
+	if err != nil {
+		respondEventRecurrenceError(c, err)
+		return
+	}
+	utils.OK(c, "Recurrence saved", recurrence)
+}
+
+// DeleteEventRecurrenceHandler godoc
+// @Summary Stop an event from recurring
+// @Description Removes the schedule of the event's series; the event itself is kept as a one-off event
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/recurrence [delete]
+func DeleteEventRecurrenceHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+	if err := services.DeleteEventRecurrence(uint(eventID)); err != nil {
+		respondEventRecurrenceError(c, err)
+		return
+	}
+	utils.OK(c, "Recurrence removed", nil)
+}
+
+func respondEventRecurrenceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEventNotFound), errors.Is(err, services.ErrRecurrenceNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidRecurrence):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
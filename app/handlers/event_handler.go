@@ -1,18 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // ----------------------------------------------------
@@ -27,10 +30,10 @@ import (
 // @Accept json
 // @Produce json
 // @Param event body object true "Frontend event payload" example({"generalDetails":{"eventType":"Spiritual","scale":"Large (L)","theme":"Devotional"},"mediaPromotion":{},"involvedParticipants":{"beneficiariesMen":50},"donationTypes":[],"materialTypes":[],"specialGuests":[],"volunteers":[],"uploadedFiles":{},"draftId":1})
-// @Success 201 {object} map[string]interface{} "Event created successfully" example({"message":"Event created successfully","event":{"id":1,"event_type_id":1,"event_category_id":1}})
-// @Failure 400 {object} map[string]string "Bad Request" example({"error":"Invalid event data"})
-// @Failure 500 {object} map[string]string "Internal Server Error" example({"error":"Failed to create event"})
-// @Router /api/events [post]
+// @Success 201 {object} utils.Response "Event created successfully" example({"message":"Event created successfully","event":{"id":1,"event_type_id":1,"event_category_id":1}})
+// @Failure 400 {object} utils.Response "Bad Request" example({"error":"Invalid event data"})
+// @Failure 500 {object} utils.Response "Internal Server Error" example({"error":"Failed to create event"})
+// @Router /api/v1/events [post]
 func CreateEventHandler(c *gin.Context) {
 	// Accept frontend payload structure
 	var frontendPayload struct {
@@ -48,26 +51,32 @@ func CreateEventHandler(c *gin.Context) {
 
 	// Try to bind frontend payload structure first
 	if err := c.ShouldBindJSON(&frontendPayload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format: " + err.Error()})
+		utils.BadRequest(c, "Invalid payload format: " + err.Error())
 		return
 	}
 
 	// Process frontend payload - map to EventDetails with status support
 	event, err := services.MapFrontendPayloadToEventWithStatus(frontendPayload.GeneralDetails, frontendPayload.InvolvedParticipants, frontendPayload.Status)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate event
 	if err := validators.ValidateEventInput(event.EventTypeID, event.EventCategoryID, event.StartDate, event.EndDate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if !checkBranchAccess(c, event.BranchID) {
 		return
 	}
 
 	// Create event in main table
 	if err := services.CreateEvent(event); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create event"})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "failed to create event")
 		return
 	}
 
@@ -75,7 +84,7 @@ func CreateEventHandler(c *gin.Context) {
 	if err := services.CreateEventRelatedData(event.ID, frontendPayload); err != nil {
 		// Log error but don't fail event creation
 		// The event is already created, related data can be added later
-		log.Printf("Warning: Failed to create related data: %v", err)
+		utils.Logger(c.Request.Context()).Warn("Failed to create related event data", zap.Uint("event_id", event.ID), zap.Error(err))
 	}
 
 	// Delete draft ONLY after successful event creation with status='complete' (submit)
@@ -84,95 +93,113 @@ func CreateEventHandler(c *gin.Context) {
 		_ = services.DeleteDraft(*frontendPayload.DraftID)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Event created successfully",
-		"event":   event,
+	utils.Created(c, "Event created successfully", gin.H{
+		"event": event,
 	})
 }
 
 // ----------------------------------------------------
-// Get All Events
+// Create Event with Related Data (atomic)
 // ----------------------------------------------------
 
-// GetAllEventsHandler godoc
-// @Summary Get all events
-// @Description Get all events, optionally filtered by status (complete/incomplete)
+// CreateFullEventHandler godoc
+// @Summary Create an event with all related data in one transaction
+// @Description Takes the same payload as POST /events (generalDetails, mediaPromotion, involvedParticipants, donationTypes, materialTypes, specialGuests, volunteers, draftId, status) and saves the event, media, promotion materials, special guests, volunteers and donations in a single database transaction. If anything fails nothing is saved. Items POST /events would silently skip (missing required fields, unknown branch or material type) are rejected with 400 naming the item, e.g. "volunteers[2]: a known branchId and name are required".
 // @Tags Events
 // @Security ApiKeyAuth
+// @Accept json
 // @Produce json
-// @Param status query string false "Filter by status: complete or incomplete"
-// @Success 200 {array} models.EventDetails
-// @Failure 500 {object} map[string]string
-// @Router /api/events [get]
-func GetAllEventsHandler(c *gin.Context) {
-	statusFilter := c.Query("status")
-	events, err := services.GetAllEvents(statusFilter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch events"})
+// @Param event body services.EventPayload true "Event payload"
+// @Success 201 {object} utils.Response{data=models.EventDetails}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/full [post]
+func CreateFullEventHandler(c *gin.Context) {
+	var payload services.EventPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.BadRequest(c, "Invalid payload format: "+err.Error())
 		return
 	}
 
-	// Add counts for related data to each event
-	eventsWithCounts := make([]gin.H, 0, len(events))
-	for _, event := range events {
-		// Get counts for related data (return empty arrays if not found)
-		specialGuests, errSG := services.GetSpecialGuestByEventID(event.ID)
-		if errSG != nil {
-			specialGuests = []models.SpecialGuest{}
-		}
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
 
-		volunteers, errVol := services.GetVolunteerByEventID(event.ID)
-		if errVol != nil {
-			volunteers = []models.Volunteer{}
+	event, err := services.CreateFullEvent(payload, scope)
+	if err != nil {
+		if respondArchivedYear(c, err) {
+			return
 		}
-
-		mediaList, errMedia := services.GetEventMediaByEventID(event.ID)
-		if errMedia != nil {
-			mediaList = []models.EventMedia{}
+		if errors.Is(err, services.ErrInvalidEventPayload) {
+			utils.BadRequest(c, err.Error())
+			return
 		}
-		// Convert to presigned URLs - HARD GUARD: fail fast if S3Key is empty
-		mediaListWithPresignedURLs, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), mediaList)
-		if err != nil {
-			// Log the error for debugging
-			log.Printf("ERROR: Failed to generate presigned URLs for event %d: %v", event.ID, err)
-			// Fail fast - return HTTP 500 with structured error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "failed to generate presigned URLs for event media",
-				"details": err.Error(),
-			})
+		if errors.Is(err, services.ErrOutOfBranchScope) {
+			utils.Forbidden(c, err.Error())
 			return
 		}
-		mediaList = mediaListWithPresignedURLs
+		utils.Logger(c.Request.Context()).Error("Failed to create event with related data", zap.Error(err))
+		utils.InternalServerError(c, "failed to create event, nothing was saved")
+		return
+	}
+	utils.Created(c, "Event created successfully", event)
+}
 
-		// Get promotion materials count
-		promotionMaterials, errPromo := services.GetPromotionMaterialDetailsByEventID(event.ID)
-		if errPromo != nil {
-			promotionMaterials = []models.PromotionMaterialDetails{}
-		}
+// ----------------------------------------------------
+// Get All Events
+// ----------------------------------------------------
 
-		// Get donations count
-		donations, errDonations := services.GetDonationsByEvent(event.ID)
-		if errDonations != nil {
-			donations = []models.Donation{}
-		}
+// GetAllEventsHandler godoc
+// @Summary Get all events
+// @Description Get all events with their attachment counts (special_guests_count, volunteers_count, media_count, promotion_materials_count, donations_count) and donations_total, read from counter columns so no extra requests are needed per row. Filter by any listed field, e.g. status=complete, start_date[gte]=2025-04-01, event_type_id[in]=1,2 or theme[contains]=satsang (operators: eq ne lt lte gt gte in contains null), and sort with sort=-start_date,theme (fields: id, theme, scale, spiritual_orator, status, approval_status, report_number, city, start_date, end_date, created_on, updated_on, last_activity_on). Filterable fields: the sortable ones plus language, country, state, district, branch_id, event_type_id, event_category_id. from and to list the events taking place in a period (start_date to end_date overlapping it). With limit or offset the response is one page in the requested sort: {data, total, limit, offset}; without them every matching event is listed as before.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status: complete or incomplete"
+// @Param branch_id query int false "Filter by branch"
+// @Param event_type_id query int false "Filter by event type"
+// @Param event_category_id query int false "Filter by event category"
+// @Param state query string false "Filter by state"
+// @Param district query string false "Filter by district"
+// @Param from query string false "Events ending on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param sort query string false "Comma-separated sort fields, - for descending"
+// @Param limit query int false "Page size (1-200, default 50); pages the response"
+// @Param offset query int false "Events to skip; pages the response"
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Success 200 {object} utils.Response{data=[]models.EventDetails}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events [get]
+func GetAllEventsHandler(c *gin.Context) {
+	query, err := services.EventListSchema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	events, total, err := services.GetAllEvents(query, middleware.IncludeDeleted(c), scope)
+	if err != nil {
+		utils.InternalServerError(c, "failed to fetch events")
+		return
+	}
 
-		// Get branch from first volunteer or donation
+	// Counts come from the denormalized counter columns on event_details
+	eventsWithCounts := make([]gin.H, 0, len(events))
+	for _, event := range events {
 		var branchName string
 		var branchID uint
-		if len(volunteers) > 0 && volunteers[0].BranchID > 0 {
-			// Try to get branch from first volunteer
-			var branch models.Branch
-			if err := config.DB.First(&branch, volunteers[0].BranchID).Error; err == nil {
-				branchName = branch.Name
-				branchID = branch.ID
-			}
-		} else if len(donations) > 0 && donations[0].BranchID > 0 {
-			// Fallback to first donation's branch
-			var branch models.Branch
-			if err := config.DB.First(&branch, donations[0].BranchID).Error; err == nil {
-				branchName = branch.Name
-				branchID = branch.ID
-			}
+		if event.Branch != nil {
+			branchName = event.Branch.Name
+			branchID = event.Branch.ID
+		} else if branch, err := services.GetEventFallbackBranch(event.ID); err == nil {
+			// Legacy events without branch_id: use the branch of the first volunteer or donation
+			branchName = branch.Name
+			branchID = branch.ID
 		}
 
 		// Convert event to map and add counts
@@ -210,16 +237,27 @@ func GetAllEventsHandler(c *gin.Context) {
 			"updated_by":               event.UpdatedBy,
 			"event_type":               event.EventType,
 			"event_category":           event.EventCategory,
-			"special_guests_count":     len(specialGuests),
-			"volunteers_count":         len(volunteers),
-			"media_count":              len(mediaList),
-			"promotion_materials_count": len(promotionMaterials),
-			"donations_count":          len(donations),
+			"special_guests_count":     event.SpecialGuestCount,
+			"volunteers_count":         event.VolunteerCount,
+			"media_count":              event.MediaCount,
+			"promotion_materials_count": event.PromotionMaterialCount,
+			"donations_count":          event.DonationCount,
+			"donations_total":          event.DonationTotal,
+			"last_activity_on":         event.LastActivityOn,
 		}
 		eventsWithCounts = append(eventsWithCounts, eventMap)
 	}
 
-	c.JSON(http.StatusOK, eventsWithCounts)
+	if query.Paged() {
+		utils.OK(c, "", gin.H{
+			"data":   eventsWithCounts,
+			"total":  total,
+			"limit":  query.Limit(),
+			"offset": query.Offset(),
+		})
+		return
+	}
+	utils.OK(c, "", eventsWithCounts)
 }
 
 // ----------------------------------------------------
@@ -233,24 +271,25 @@ func GetAllEventsHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {object} map[string]interface{} "Event with related data"
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/events/{event_id} [get]
+// @Success 200 {object} utils.Response "Event with related data"
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id} [get]
 func GetEventByIdHandler(c *gin.Context) {
 	idParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
 	event, err := services.GetEventByID(uint(eventID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
+	setVersionETag(c, event.Version)
 
 	// Fetch related data (return empty arrays if not found)
 	specialGuests, errSG := services.GetSpecialGuestByEventID(uint(eventID))
@@ -274,10 +313,7 @@ func GetEventByIdHandler(c *gin.Context) {
 		mediaListWithPresignedURLs, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), mediaList)
 		if err != nil {
 			// Fail fast - return HTTP 500 with structured error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "failed to generate presigned URLs for event media",
-				"details": err.Error(),
-			})
+			utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs for event media", err.Error())
 			return
 		}
 		mediaList = mediaListWithPresignedURLs
@@ -331,7 +367,7 @@ func GetEventByIdHandler(c *gin.Context) {
 		"donationsCount":         len(donations),
 	}
 
-	c.JSON(http.StatusOK, response)
+	utils.OK(c, "", response)
 }
 
 // ----------------------------------------------------
@@ -345,19 +381,23 @@ func GetEventByIdHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param search query string false "Search keyword"
-// @Success 200 {array} models.EventDetails
-// @Failure 500 {object} map[string]string
-// @Router /api/events/search [get]
+// @Success 200 {object} utils.Response{data=[]models.EventDetails}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/search [get]
 func SearchEventsHandler(c *gin.Context) {
 	search := c.Query("search")
 
-	events, err := services.SearchEvents(search)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	events, err := services.SearchEvents(search, scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, events)
+	utils.OK(c, "", events)
 }
 
 // ----------------------------------------------------
@@ -366,22 +406,24 @@ func SearchEventsHandler(c *gin.Context) {
 
 // UpdateEventHandler godoc
 // @Summary Update an event
-// @Description Updates an event. Accepts both flat structure (for simple updates) and nested frontend payload structure (for full updates with related data)
+// @Description Updates an event. Accepts both flat structure (for simple updates) and nested frontend payload structure (for full updates with related data). Send the version last read (If-Match with the ETag of the GET, or a "version" field) to have the update rejected with 409 and the latest event if someone else updated it since.
 // @Tags Events
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param event_id path int true "Event ID"
+// @Param If-Match header string false "Version last read (the ETag of the GET)"
 // @Param event body object true "Updated fields (can be flat or nested frontend payload)"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/events/{event_id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id} [put]
 func UpdateEventHandler(c *gin.Context) {
 	idParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
@@ -401,10 +443,17 @@ func UpdateEventHandler(c *gin.Context) {
 
 	// Check if it's a nested frontend payload
 	if err := c.ShouldBindJSON(&frontendPayload); err == nil && frontendPayload.GeneralDetails != nil {
+		// The nested payload carries the version with the general details
+		version, err := expectedVersion(c, frontendPayload.GeneralDetails)
+		if err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+
 		// It's a nested frontend payload - map to EventDetails and update
 		event, err := services.MapFrontendPayloadToEventWithStatus(frontendPayload.GeneralDetails, frontendPayload.InvolvedParticipants, frontendPayload.Status)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			utils.BadRequest(c, err.Error())
 			return
 		}
 
@@ -485,19 +534,22 @@ func UpdateEventHandler(c *gin.Context) {
 
 		// Validate update fields
 		if err := validators.ValidateEventUpdateFields(updateData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			utils.BadRequest(c, err.Error())
 			return
 		}
 
 		// Update event
-		if err := services.UpdateEvent(uint(eventID), updateData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := services.UpdateEvent(uint(eventID), updateData, version); err != nil {
+			if respondArchivedYear(c, err) || respondVersionConflict(c, err) {
+				return
+			}
+			utils.InternalServerError(c, err.Error())
 			return
 		}
 
 		// Update related data if provided
 		if err := services.CreateEventRelatedData(uint(eventID), frontendPayload); err != nil {
-			log.Printf("Warning: Failed to update related data: %v", err)
+			utils.Logger(c.Request.Context()).Warn("Failed to update related event data", zap.Uint64("event_id", eventID), zap.Error(err))
 		}
 
 		// Delete draft ONLY if status is 'complete' (submit)
@@ -506,17 +558,35 @@ func UpdateEventHandler(c *gin.Context) {
 			_ = services.DeleteDraft(*frontendPayload.DraftID)
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
+		utils.OK(c, "Event updated successfully", nil)
 		return
 	}
 
 	// Fallback: try as flat structure (for simple updates)
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	version, err := expectedVersion(c, updateData)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// Moving the event is limited to the user's branches too
+	if value, ok := updateData["branch_id"]; ok {
+		var branchID *uint
+		if number, isNumber := value.(float64); isNumber {
+			id := uint(number)
+			branchID = &id
+		}
+		if !checkBranchAccess(c, branchID) {
+			return
+		}
+	}
+
 	// Extract draftId and status from flat structure if present
 	var draftID *uint
 	var status string
@@ -560,12 +630,15 @@ func UpdateEventHandler(c *gin.Context) {
 	}
 
 	if err := validators.ValidateEventUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	if err := services.UpdateEvent(uint(eventID), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := services.UpdateEvent(uint(eventID), updateData, version); err != nil {
+		if respondArchivedYear(c, err) || respondVersionConflict(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
@@ -575,7 +648,7 @@ func UpdateEventHandler(c *gin.Context) {
 		_ = services.DeleteDraft(*draftID)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully"})
+	utils.OK(c, "Event updated successfully", nil)
 }
 
 // ----------------------------------------------------
@@ -584,28 +657,76 @@ func UpdateEventHandler(c *gin.Context) {
 
 // DeleteEventHandler godoc
 // @Summary Delete an event
+// @Description Soft-deletes the event along with its volunteers and donations. Blocked with 423 while the event or any of its media is on legal hold.
 // @Tags Events
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/events/{event_id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id} [delete]
 func DeleteEventHandler(c *gin.Context) {
 	idParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
 	if err := services.DeleteEvent(uint(eventID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrEventNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrLegalHold) {
+			utils.ErrorResponse(c, http.StatusLocked, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "Event deleted successfully", nil)
+}
+
+// RestoreEventHandler godoc
+// @Summary Restore a deleted event
+// @Description Restores a soft-deleted event together with the volunteers and donations deleted with it (admin only)
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/restore [post]
+func RestoreEventHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	if err := services.RestoreEvent(uint(eventID)); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrNotDeleted) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
+	utils.OK(c, "Event restored successfully", nil)
 }
 
 // ----------------------------------------------------
@@ -620,22 +741,22 @@ func DeleteEventHandler(c *gin.Context) {
 // @Produce application/pdf
 // @Param event_id path int true "Event ID"
 // @Success 200 {file} file "Event data PDF file"
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/events/{event_id}/download [get]
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/download [get]
 func DownloadEventHandler(c *gin.Context) {
 	idParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
 	// Get event with all related data
 	event, err := services.GetEventByID(uint(eventID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
@@ -647,10 +768,7 @@ func DownloadEventHandler(c *gin.Context) {
 	mediaListWithPresignedURLs, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), mediaList)
 	if err != nil {
 		// Fail fast - return HTTP 500 with structured error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to generate presigned URLs for event media",
-			"details": err.Error(),
-		})
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs for event media", err.Error())
 		return
 	}
 	mediaList = mediaListWithPresignedURLs
@@ -660,7 +778,7 @@ func DownloadEventHandler(c *gin.Context) {
 	// Generate PDF document
 	pdfBytes, err := services.GenerateEventPDF(event, specialGuests, volunteers, mediaList, promotionMaterials, donations)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF: " + err.Error()})
+		utils.InternalServerError(c, "Failed to generate PDF: " + err.Error())
 		return
 	}
 
@@ -670,31 +788,224 @@ func DownloadEventHandler(c *gin.Context) {
 	c.Data(http.StatusOK, "application/pdf", pdfBytes)
 }
 
+// ----------------------------------------------------
+// Event Report PDF
+// ----------------------------------------------------
+
+// GetEventReportPDFHandler godoc
+// @Summary Download formatted event report as PDF
+// @Description Renders the event report with details, beneficiary counts, guest list, donation totals and photo thumbnails
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce application/pdf
+// @Param event_id path int true "Event ID"
+// @Success 200 {file} file "Event report PDF file"
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/report.pdf [get]
+func GetEventReportPDFHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	roleID, _ := middleware.CurrentRoleID(c)
+	pdfBytes, err := services.GenerateEventReportPDF(c.Request.Context(), uint(eventID), !middleware.CanViewPII(roleID))
+	if err != nil {
+		if errors.Is(err, services.ErrEventNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to generate report: " + err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=event_report_%d.pdf", eventID))
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// QueueEventReportPDFHandler godoc
+// @Summary Generate the event report PDF in the background
+// @Description Queues the same report as /api/events/{event_id}/report.pdf for events with many photos. Poll GET /api/jobs/{id}; the finished job's result.download_url links to the PDF.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/report-jobs [post]
+func QueueEventReportPDFHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	roleID, _ := middleware.CurrentRoleID(c)
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.QueueEventReportPDF(c.Request.Context(), uint(eventID), !middleware.CanViewPII(roleID), createdBy)
+	if err != nil {
+		if errors.Is(err, services.ErrEventNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "Failed to queue report: " + err.Error())
+		return
+	}
+	utils.Accepted(c, "", job)
+}
+
+// GetEventShareTextHandler godoc
+// @Summary Get WhatsApp share text for an event
+// @Description Renders a localized summary of the event (theme, dates, beneficiaries, highlights) from the same data as the report PDF, formatted for pasting into WhatsApp groups. Donation amounts are left out for roles that cannot view PII.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param lang query string false "Language: en (default) or hi"
+// @Success 200 {object} utils.Response{data=services.ShareText}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/share-text [get]
+func GetEventShareTextHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	roleID, _ := middleware.CurrentRoleID(c)
+	lang := strings.ToLower(strings.TrimSpace(c.Query("lang")))
+	shareText, err := services.GenerateEventShareText(uint(eventID), lang, !middleware.CanViewPII(roleID))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedShareLanguage):
+			utils.BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrEventNotFound):
+			utils.NotFound(c, err.Error())
+		default:
+			utils.InternalServerError(c, "Failed to generate share text: " + err.Error())
+		}
+		return
+	}
+	utils.OK(c, "", shareText)
+}
+
+// ----------------------------------------------------
+// Export Events
+// ----------------------------------------------------
+
+// ExportEventsHandler godoc
+// @Summary Export events as an Excel workbook
+// @Description Exports the filtered events as XLSX with separate sheets for events, beneficiaries, initiations, donations and volunteers
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param event_type_id query int false "Event type ID"
+// @Param scale query string false "Event scale"
+// @Success 200 {file} file "Events XLSX file"
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/export [get]
+func ExportEventsHandler(c *gin.Context) {
+	filter := services.EventExportFilter{Scale: c.Query("scale")}
+
+	for param, target := range map[string]*uint{
+		"branch_id":     &filter.BranchID,
+		"event_type_id": &filter.EventTypeID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.BadRequest(c, "invalid " + param)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid from (use YYYY-MM-DD)")
+			return
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid to (use YYYY-MM-DD)")
+			return
+		}
+		// Include the whole "to" day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		utils.BadRequest(c, "to must not be before from")
+		return
+	}
+
+	roleID, _ := middleware.CurrentRoleID(c)
+	filter.MaskPII = !middleware.CanViewPII(roleID)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	filter.Scope = scope
+
+	data, err := services.GenerateEventsXLSX(filter)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to generate export: " + err.Error())
+		return
+	}
+
+	const contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=events_%s.xlsx", time.Now().Format("20060102_150405")))
+	c.Data(http.StatusOK, contentType, data)
+}
+
 // ----------------------------------------------------
 // Save Draft
 // ----------------------------------------------------
 
 // SaveDraftHandler godoc
 // @Summary Save draft data for a specific step
-// @Description Saves draft data for event creation. Creates a new draft if draftId is not provided, or updates existing draft. Drafts are stored in a separate event_drafts table and automatically deleted when event is submitted.
+// @Description Saves draft data for event creation. Creates a new draft if draftId is not provided, or updates existing draft. With eventId (and no draftId) the user's draft of changes to that event is updated, or started. Every save bumps the draft version; send the version last read to get 409 (with the stored version in details) instead of overwriting a save made in another tab or device. Drafts are stored in a separate event_drafts table, deleted when the event is submitted, and cleaned up after EVENT_DRAFT_RETENTION_DAYS (default 30) without saves.
 // @Tags Events
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
-// @Param draft body object true "Draft payload" example({"draftId":null,"step":"generalDetails","data":{"eventType":"Spiritual","eventName":"Bhagwat Katha","scale":"Large (L)"}})
-// @Success 200 {object} map[string]interface{} "Draft saved successfully" example({"draftId":1,"message":"Draft saved successfully"})
-// @Failure 400 {object} map[string]string "Bad Request" example({"error":"Invalid step name. Must be one of: generalDetails, mediaPromotion, specialGuests, volunteers, donations"})
-// @Failure 500 {object} map[string]string "Internal Server Error" example({"error":"Failed to save draft"})
-// @Router /api/events/draft [post]
+// @Param draft body object true "Draft payload" example({"draftId":1,"version":3,"step":"generalDetails","data":{"eventType":"Spiritual","eventName":"Bhagwat Katha","scale":"Large (L)"}})
+// @Success 200 {object} utils.Response "Draft saved successfully" example({"draftId":1,"version":4,"updatedOn":"2024-01-01T10:00:00Z"})
+// @Failure 400 {object} utils.Response "Bad Request" example({"error":"Invalid step name. Must be one of: generalDetails, mediaPromotion, specialGuests, volunteers, donations"})
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response{details=services.DraftConflict}
+// @Failure 500 {object} utils.Response "Internal Server Error" example({"error":"Failed to save draft"})
+// @Router /api/v1/events/draft [post]
 func SaveDraftHandler(c *gin.Context) {
 	var draftRequest struct {
 		DraftID interface{} `json:"draftId"` // Changed from eventId to draftId
+		EventID *uint       `json:"eventId"`
+		Version *int        `json:"version"`
 		Step    string      `json:"step"`
 		Data    interface{} `json:"data"`
 	}
 
 	if err := c.ShouldBindJSON(&draftRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
@@ -708,7 +1019,7 @@ func SaveDraftHandler(c *gin.Context) {
 	}
 
 	if !validSteps[draftRequest.Step] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step name. Must be one of: generalDetails, mediaPromotion, specialGuests, volunteers, donations"})
+		utils.BadRequest(c, "Invalid step name. Must be one of: generalDetails, mediaPromotion, specialGuests, volunteers, donations")
 		return
 	}
 
@@ -734,17 +1045,8 @@ func SaveDraftHandler(c *gin.Context) {
 		}
 	}
 
-	// Get user email from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
-		return
-	}
-
-	// Get user email from database
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user information"})
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
 		return
 	}
 
@@ -755,65 +1057,95 @@ func SaveDraftHandler(c *gin.Context) {
 		if data, ok := draftRequest.Data.(map[string]interface{}); ok {
 			dataMap = data
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid data format"})
+			utils.BadRequest(c, "invalid data format")
 			return
 		}
 	} else {
 		dataMap = make(map[string]interface{})
 	}
 
-	savedDraftID, err := services.SaveDraft(draftID, draftRequest.Step, dataMap, user.Email)
+	draft, err := services.SaveDraft(services.DraftSave{
+		DraftID:   draftID,
+		EventID:   draftRequest.EventID,
+		Step:      draftRequest.Step,
+		Data:      dataMap,
+		Version:   draftRequest.Version,
+		UserEmail: userEmail,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondDraftError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"draftId": savedDraftID, // Changed from eventId to draftId
-		"message": "Draft saved successfully",
+	utils.OK(c, "Draft saved successfully", gin.H{
+		"draftId":   draft.ID, // Changed from eventId to draftId
+		"eventId":   draft.EventID,
+		"version":   draft.Version,
+		"updatedOn": draft.UpdatedOn,
 	})
 }
 
+// ----------------------------------------------------
+// List Drafts
+// ----------------------------------------------------
+
+// ListDraftsHandler godoc
+// @Summary List the current user's drafts
+// @Description Lists the authenticated user's drafts, most recently saved first, with the steps that have data but without the data itself.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]services.DraftSummary}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/draft [get]
+func ListDraftsHandler(c *gin.Context) {
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
+		return
+	}
+
+	drafts, err := services.ListDrafts(userEmail)
+	if err != nil {
+		utils.InternalServerError(c, "failed to list drafts")
+		return
+	}
+	utils.OK(c, "", drafts)
+}
+
 // ----------------------------------------------------
 // Get Draft
 // ----------------------------------------------------
 
 // GetDraftHandler godoc
 // @Summary Get draft data by draft ID
-// @Description Retrieves draft data for event creation. Returns all draft steps (generalDetails, mediaPromotion, specialGuests, volunteers, donations) stored in the event_drafts table.
+// @Description Retrieves one of the authenticated user's drafts. Returns all draft steps (generalDetails, mediaPromotion, specialGuests, volunteers, donations) stored in the event_drafts table and the version to send with the next save.
 // @Tags Events
 // @Security ApiKeyAuth
 // @Produce json
 // @Param draftId path int true "Draft ID"
-// @Success 200 {object} map[string]interface{} "Draft data" example({"draftId":1,"generalDetails":{},"mediaPromotion":{},"specialGuests":{},"volunteers":{},"donations":{}})
-// @Failure 400 {object} map[string]string "Bad Request" example({"error":"Invalid draft ID"})
-// @Failure 404 {object} map[string]string "Not Found" example({"error":"Draft not found"})
-// @Failure 500 {object} map[string]string "Internal Server Error" example({"error":"Failed to retrieve draft"})
-// @Router /api/events/draft/{draftId} [get]
+// @Success 200 {object} utils.Response "Draft data" example({"draftId":1,"version":4,"generalDetails":{},"mediaPromotion":{},"specialGuests":{},"volunteers":{},"donations":{}})
+// @Failure 400 {object} utils.Response "Bad Request" example({"error":"Invalid draft ID"})
+// @Failure 404 {object} utils.Response "Not Found" example({"error":"Draft not found"})
+// @Failure 500 {object} utils.Response "Internal Server Error" example({"error":"Failed to retrieve draft"})
+// @Router /api/v1/events/draft/{draftId} [get]
 func GetDraftHandler(c *gin.Context) {
-	draftIDParam := c.Param("draftId")
-	draftID, err := strconv.ParseUint(draftIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+	draftID, ok := draftIDParam(c)
+	if !ok {
+		return
+	}
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
 		return
 	}
 
-	draft, err := services.GetDraft(uint(draftID))
+	draft, err := services.GetDraft(draftID, userEmail)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondDraftError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"draftId":        draft.ID,
-		"generalDetails": draft.GeneralDetailsDraft,
-		"mediaPromotion": draft.MediaPromotionDraft,
-		"specialGuests":  draft.SpecialGuestsDraft,
-		"volunteers":     draft.VolunteersDraft,
-		"donations":      draft.DonationsDraft,
-		"createdOn":      draft.CreatedOn,
-		"updatedOn":      draft.UpdatedOn,
-	})
+	utils.OK(c, "", draftResponse(draft))
 }
 
 // ----------------------------------------------------
@@ -826,34 +1158,112 @@ func GetDraftHandler(c *gin.Context) {
 // @Tags Events
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} map[string]interface{} "Draft data" example({"draftId":1,"generalDetails":{},"mediaPromotion":{},"specialGuests":{},"volunteers":{},"donations":{}})
-// @Failure 404 {object} map[string]string "Not Found" example({"error":"No draft found for user"})
-// @Failure 500 {object} map[string]string "Internal Server Error" example({"error":"Failed to retrieve draft"})
-// @Router /api/events/draft/latest [get]
+// @Success 200 {object} utils.Response "Draft data" example({"draftId":1,"version":4,"generalDetails":{},"mediaPromotion":{},"specialGuests":{},"volunteers":{},"donations":{}})
+// @Failure 404 {object} utils.Response "Not Found" example({"error":"No draft found for user"})
+// @Failure 500 {object} utils.Response "Internal Server Error" example({"error":"Failed to retrieve draft"})
+// @Router /api/v1/events/draft/latest [get]
 func GetLatestDraftByUserHandler(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
 		return
 	}
 
-	// Get user email from database
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user information"})
+	// Get latest draft for this user
+	draft, err := services.GetLatestDraftByUserEmail(userEmail)
+	if err != nil {
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	// Get latest draft for this user
-	draft, err := services.GetLatestDraftByUserEmail(user.Email)
+	utils.OK(c, "", draftResponse(draft))
+}
+
+// ----------------------------------------------------
+// Convert Draft to Event
+// ----------------------------------------------------
+
+// ConvertDraftRequest submits a draft as a new event
+type ConvertDraftRequest struct {
+	Status  string `json:"status" binding:"omitempty,oneof=complete incomplete"`
+	Version *int   `json:"version"`
+}
+
+// ConvertDraftHandler godoc
+// @Summary Create an event from a draft
+// @Description Creates the event described by one of the user's drafts and deletes the draft. The draft must pass the same checks as POST /events (event type, category and dates in generalDetails); otherwise 422 lists the problem. List steps keep their lists under the payload key (specialGuests.specialGuests, volunteers.volunteers, donations.donationTypes and donations.materialTypes). Drafts of changes to an existing event are submitted with PUT /events/{event_id} instead. With version, a draft saved since returns 409.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param draftId path int true "Draft ID"
+// @Param data body ConvertDraftRequest false "Event status (default incomplete) and draft version"
+// @Success 201 {object} utils.Response{data=models.EventDetails}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response{details=services.DraftConflict}
+// @Failure 422 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/draft/{draftId}/convert [post]
+func ConvertDraftHandler(c *gin.Context) {
+	draftID, ok := draftIDParam(c)
+	if !ok {
+		return
+	}
+	var req ConvertDraftRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
+		return
+	}
+	// The event's branch is only known once the draft is mapped, so the service checks it
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	event, err := services.ConvertDraftToEvent(draftID, userEmail, req.Status, req.Version, scope)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondDraftError(c, err)
 		return
 	}
+	utils.Created(c, "Event created successfully", event)
+}
+
+func draftIDParam(c *gin.Context) (uint, bool) {
+	draftID, err := strconv.ParseUint(c.Param("draftId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid draft ID")
+		return 0, false
+	}
+	return uint(draftID), true
+}
+
+// currentUserEmail returns the authenticated user's email, which drafts are keyed by
+func currentUserEmail(c *gin.Context) (string, bool) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "user not authenticated")
+		return "", false
+	}
+	var user models.User
+	if err := config.DB.Select("id", "email").First(&user, userID).Error; err != nil {
+		utils.InternalServerError(c, "failed to get user information")
+		return "", false
+	}
+	return user.Email, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
+func draftResponse(draft *models.EventDraft) gin.H {
+	return gin.H{
 		"draftId":        draft.ID,
+		"eventId":        draft.EventID,
+		"version":        draft.Version,
 		"generalDetails": draft.GeneralDetailsDraft,
 		"mediaPromotion": draft.MediaPromotionDraft,
 		"specialGuests":  draft.SpecialGuestsDraft,
@@ -861,7 +1271,26 @@ func GetLatestDraftByUserHandler(c *gin.Context) {
 		"donations":      draft.DonationsDraft,
 		"createdOn":      draft.CreatedOn,
 		"updatedOn":      draft.UpdatedOn,
-	})
+	}
+}
+
+// respondDraftError maps draft errors; conflicts carry the stored version in details
+func respondDraftError(c *gin.Context, err error) {
+	var conflict *services.DraftConflictError
+	switch {
+	case errors.Is(err, services.ErrDraftNotFound), errors.Is(err, services.ErrEventNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.As(err, &conflict):
+		utils.ErrorCodeResponse(c, http.StatusConflict, utils.CodeConflict, err.Error(), conflict.Current)
+	case errors.Is(err, services.ErrInvalidDraft):
+		utils.ErrorCodeResponse(c, http.StatusUnprocessableEntity, utils.CodeValidationFailed, err.Error(), nil)
+	case errors.Is(err, services.ErrArchivedYear):
+		utils.ErrorResponse(c, http.StatusLocked, err.Error())
+	case errors.Is(err, services.ErrOutOfBranchScope):
+		utils.Forbidden(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
 }
 
 // ----------------------------------------------------
@@ -877,16 +1306,16 @@ func GetLatestDraftByUserHandler(c *gin.Context) {
 // @Produce json
 // @Param event_id path int true "Event ID"
 // @Param status body object true "Status update" example({"status":"complete"})
-// @Success 200 {object} map[string]interface{} "Status updated successfully" example({"message":"Event status updated successfully","status":"complete"})
-// @Failure 400 {object} map[string]string "Bad Request" example({"error":"Invalid status. Must be 'complete' or 'incomplete'"})
-// @Failure 404 {object} map[string]string "Not Found" example({"error":"Event not found"})
-// @Failure 500 {object} map[string]string "Internal Server Error" example({"error":"Failed to update event status"})
-// @Router /api/events/{event_id}/status [patch]
+// @Success 200 {object} utils.Response "Status updated successfully" example({"message":"Event status updated successfully","status":"complete"})
+// @Failure 400 {object} utils.Response "Bad Request" example({"error":"Invalid status. Must be 'complete' or 'incomplete'"})
+// @Failure 404 {object} utils.Response "Not Found" example({"error":"Event not found"})
+// @Failure 500 {object} utils.Response "Internal Server Error" example({"error":"Failed to update event status"})
+// @Router /api/v1/events/{event_id}/status [patch]
 func UpdateEventStatusHandler(c *gin.Context) {
 	eventIDParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(eventIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
@@ -895,22 +1324,147 @@ func UpdateEventStatusHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.UpdateEventStatus(uint(eventID), request.Status); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
 		if err.Error() == "event not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "Event status updated successfully", gin.H{
+		"status": request.Status,
+	})
+}
+
+// ----------------------------------------------------
+// Event Approval Workflow
+// ----------------------------------------------------
+
+// TransitionEventStatusHandler godoc
+// @Summary Transition event approval status
+// @Description Move an event through the approval workflow (draft → submitted → under_review → approved/rejected → published). Rejections require a comment.
+// @Tags Events
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param transition body object true "Transition" example({"to_status":"rejected","comment":"Beneficiary counts missing"})
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/events/{event_id}/transitions [post]
+func TransitionEventStatusHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	var request struct {
+		ToStatus string `json:"to_status" binding:"required"`
+		Comment  string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "user not authenticated")
+		return
+	}
+	roleID, _ := middleware.CurrentRoleID(c)
+
+	event, err := services.TransitionEventApprovalStatus(uint(eventID), request.ToStatus, request.Comment, userID, roleID)
+	if err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrEventNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrTransitionNotPermitted):
+			utils.Forbidden(c, err.Error())
+		case errors.Is(err, services.ErrStatusChanged):
+			utils.Conflict(c, err.Error())
+		case errors.Is(err, services.ErrInvalidStatusTransition), errors.Is(err, services.ErrCommentRequired):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, "Failed to update event status")
+		}
+		return
+	}
+
+	utils.OK(c, "Event status updated successfully", gin.H{
+		"event_id":        event.ID,
+		"approval_status": event.ApprovalStatus,
+	})
+}
+
+// GetEventStatusHistoryHandler godoc
+// @Summary Get event approval history
+// @Description List approval status transitions of an event with reviewer comments
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Success 200 {object} utils.Response{data=[]models.EventStatusHistory}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/status-history [get]
+func GetEventStatusHistoryHandler(c *gin.Context) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid event ID")
+		return
+	}
+
+	history, err := services.GetEventStatusHistory(uint(eventID))
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", history)
+}
+
+// GetPendingApprovalEventsHandler godoc
+// @Summary List events pending approval
+// @Description List events waiting on the caller's reviewer role (managers review submissions, admins also publish)
+// @Tags Events
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/pending-approval [get]
+func GetPendingApprovalEventsHandler(c *gin.Context) {
+	roleID, _ := middleware.CurrentRoleID(c)
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	events, err := services.GetEventsPendingApproval(roleID, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Event status updated successfully",
-		"status":  request.Status,
+	utils.OK(c, "", gin.H{
+		"statuses": services.PendingApprovalStatuses(roleID),
+		"events":   events,
 	})
 }
 
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEventMediaItemRequest attaches an uploaded file to an event's gallery
+type CreateEventMediaItemRequest struct {
+	S3Key            string `json:"s3_key" binding:"required"`
+	OriginalFilename string `json:"original_filename"`
+	FileType         string `json:"file_type" binding:"omitempty,oneof=image video audio file"`
+	Category         string `json:"category" binding:"max=100"`
+	Caption          string `json:"caption" binding:"max=1000"`
+	SortOrder        *int   `json:"sort_order"`
+}
+
+// UpdateEventMediaItemRequest updates the gallery fields of an event media item
+type UpdateEventMediaItemRequest struct {
+	Category  *string `json:"category" binding:"omitempty,max=100"`
+	Caption   *string `json:"caption" binding:"omitempty,max=1000"`
+	SortOrder *int    `json:"sort_order"`
+	IsPublic  *bool   `json:"is_public"`
+}
+
+// ReorderEventMediaRequest lists media IDs in their new gallery order
+type ReorderEventMediaRequest struct {
+	MediaIDs []uint `json:"media_ids" binding:"required,min=1"`
+}
+
+// ListEventMediaHandler godoc
+// @Summary List an event's media
+// @Description Returns the event's media in gallery order (sort_order, then id) with presigned URLs, like branch media.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param category query string false "Only this category"
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media [get]
+func ListEventMediaHandler(c *gin.Context) {
+	eventID, ok := eventIDParam(c)
+	if !ok {
+		return
+	}
+
+	mediaList, err := services.ListEventMedia(eventID, c.Query("category"))
+	if err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+
+	mediaListWithPresignedURLs, err := eventGalleryURLs(c, mediaList)
+	if err != nil {
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+		return
+	}
+
+	utils.OK(c, "Event Media fetched successfully", mediaListWithPresignedURLs)
+}
+
+// GetEventMediaItemHandler godoc
+// @Summary Get one media item of an event
+// @Description Returns the media item with a presigned URL for the original and its thumbnails.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param media_id path int true "Event Media ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media/{media_id} [get]
+func GetEventMediaItemHandler(c *gin.Context) {
+	eventID, mediaID, ok := eventMediaParams(c)
+	if !ok {
+		return
+	}
+
+	media, err := services.GetEventMediaItem(eventID, mediaID)
+	if err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+	respondEventMediaItem(c, http.StatusOK, "Event Media fetched successfully", media)
+}
+
+// CreateEventMediaItemHandler godoc
+// @Summary Attach media to an event
+// @Description Links a file uploaded to S3 to the event. The object must exist and must not be linked to other media. Without sort_order the item is appended to the gallery.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param data body CreateEventMediaItemRequest true "Media details"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media [post]
+func CreateEventMediaItemHandler(c *gin.Context) {
+	eventID, ok := eventIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req CreateEventMediaItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	media, err := services.CreateEventMediaItem(c.Request.Context(), eventID, services.EventMediaInput{
+		S3Key:            req.S3Key,
+		OriginalFilename: req.OriginalFilename,
+		FileType:         req.FileType,
+		Category:         req.Category,
+		Caption:          req.Caption,
+		SortOrder:        req.SortOrder,
+	})
+	if err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+	respondEventMediaItem(c, http.StatusCreated, "Event Media created successfully", media)
+}
+
+// UpdateEventMediaItemHandler godoc
+// @Summary Update an event media item
+// @Description Updates the caption, category or sort_order of a media item of the event. is_public=true publishes an image under stable content-addressed URLs (public_url, public_thumbnail_url, public_medium_url) that browsers and CDNs may cache indefinitely; only clean images of non-sandbox events can be made public.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param media_id path int true "Event Media ID"
+// @Param data body UpdateEventMediaItemRequest true "Fields to update"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media/{media_id} [put]
+func UpdateEventMediaItemHandler(c *gin.Context) {
+	eventID, mediaID, ok := eventMediaParams(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateEventMediaItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	media, err := services.UpdateEventMediaItem(c.Request.Context(), eventID, mediaID, services.EventMediaUpdate{
+		Category:  req.Category,
+		Caption:   req.Caption,
+		SortOrder: req.SortOrder,
+		IsPublic:  req.IsPublic,
+	})
+	if err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+	respondEventMediaItem(c, http.StatusOK, "Event Media updated successfully", media)
+}
+
+// DeleteEventMediaItemHandler godoc
+// @Summary Delete an event media item
+// @Description Removes a media item from the event and leaves a tombstone (who, when, original metadata). The S3 object is kept. Blocked with 423 while the media or its event is on legal hold.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param media_id path int true "Event Media ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media/{media_id} [delete]
+func DeleteEventMediaItemHandler(c *gin.Context) {
+	eventID, mediaID, ok := eventMediaParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteEventMediaItem(eventID, mediaID, auditActor(c)); err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+	utils.OK(c, "Event Media deleted successfully", nil)
+}
+
+// ReorderEventMediaHandler godoc
+// @Summary Reorder an event's media
+// @Description Sets the gallery order: media_ids[i] gets sort_order i. Media not listed move after the listed ones.
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param event_id path int true "Event ID"
+// @Param data body ReorderEventMediaRequest true "Media IDs in gallery order"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/events/{event_id}/media/reorder [post]
+func ReorderEventMediaHandler(c *gin.Context) {
+	eventID, ok := eventIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ReorderEventMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	mediaList, err := services.ReorderEventMedia(eventID, req.MediaIDs)
+	if err != nil {
+		respondEventMediaError(c, err)
+		return
+	}
+	mediaListWithPresignedURLs, err := eventGalleryURLs(c, mediaList)
+	if err != nil {
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+		return
+	}
+
+	utils.OK(c, "Event Media reordered successfully", mediaListWithPresignedURLs)
+}
+
+func eventIDParam(c *gin.Context) (uint, bool) {
+	eventID, err := strconv.ParseUint(c.Param("event_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid event ID")
+		return 0, false
+	}
+	return uint(eventID), true
+}
+
+func eventMediaParams(c *gin.Context) (uint, uint, bool) {
+	eventID, ok := eventIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	mediaID, err := strconv.ParseUint(c.Param("media_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid media ID")
+		return 0, 0, false
+	}
+	return eventID, uint(mediaID), true
+}
+
+func respondEventMediaItem(c *gin.Context, status int, message string, media *models.EventMedia) {
+	presigned, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), []models.EventMedia{*media})
+	if err != nil {
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+		return
+	}
+	if len(presigned) == 1 {
+		media = &presigned[0]
+	}
+	utils.SuccessResponse(c, status, message, media)
+}
+
+func respondEventMediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEventNotFound), errors.Is(err, services.ErrEventMediaNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidEventMedia):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrLegalHold), errors.Is(err, services.ErrArchivedYear):
+		utils.ErrorResponse(c, http.StatusLocked, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
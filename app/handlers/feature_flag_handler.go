@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SetFeatureFlagRequest is the payload for turning a feature flag on or off
+type SetFeatureFlagRequest struct {
+	Enabled     *bool  `json:"enabled" binding:"required"`
+	Description string `json:"description"`
+}
+
+// GetFeatureFlagsHandler godoc
+// @Summary List feature flags
+// @Description Returns all feature flags, including the phase flags of expand/contract migrations. Admin only.
+// @Tags FeatureFlags
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.FeatureFlag}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/feature-flags [get]
+func GetFeatureFlagsHandler(c *gin.Context) {
+	flags, err := services.GetFeatureFlags()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", flags)
+}
+
+// SetFeatureFlagHandler godoc
+// @Summary Create or update a feature flag
+// @Description Turns a flag on or off; the flag is created if it does not exist. Running instances pick up the change within 15 seconds. Admin only.
+// @Tags FeatureFlags
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param key path string true "Flag key, e.g. new_dashboard"
+// @Param payload body SetFeatureFlagRequest true "Flag state"
+// @Success 200 {object} utils.Response{data=models.FeatureFlag}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/feature-flags/{key} [put]
+func SetFeatureFlagHandler(c *gin.Context) {
+	var req SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	flag, err := services.SetFeatureFlag(c.Param("key"), *req.Enabled, req.Description, auditActor(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidFeatureFlagKey) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", flag)
+}
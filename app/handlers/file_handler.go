@@ -1,21 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
 
 // UploadFileHandler handles file uploads to S3
 // @Summary Upload file to S3
-// @Description Upload image, video, audio, or PDF file to S3 and associate with event media
+// @Description Upload image, video, audio, or PDF file to S3 and associate with event media. Photos are stored without their EXIF metadata (GPS location), rotated upright, scaled down to MEDIA_IMAGE_MAX_SIDE if set, and HEIC photos are converted to JPEG. Videos browsers cannot play (MOV, MKV, AVI, ...) are transcoded to MP4 in the background when MEDIA_TRANSCODER is set; transcode_status tracks it and media listings then serve the MP4 as url. Images that look like existing photos of the event are accepted, and the near-duplicates are listed in duplicates (closest first). While a media scanner is configured (MEDIA_SCANNER) the file is stored with scan_status pending, scanned in the background and only served once it scans clean; infected files are quarantined.
 // @Tags Files
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
@@ -24,50 +28,23 @@ import (
 // @Param event_id formData int true "Event ID"
 // @Param media_id formData int false "Media ID (if updating existing media)"
 // @Param category formData string false "File category (Event Photos, Video Coverage, Testimonials, Press Release)"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/files/upload [post]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response "Invalid metadata; all invalid fields are listed in details"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/files/upload [post]
 func UploadFileHandler(c *gin.Context) {
-	// Get file from form
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+	// Validate metadata, then get file from form
+	upload, ok := parseUploadRequest(c, eventUploadSpec)
+	if !ok {
 		return
 	}
-
-	// Get event ID
-	eventIDStr := c.PostForm("event_id")
-	if eventIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "event_id is required"})
-		return
-	}
-	eventID, err := strconv.ParseUint(eventIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event_id"})
-		return
-	}
-
-	// Get media ID if provided (for updating existing media)
-	var mediaID uint
-	mediaIDStr := c.PostForm("media_id")
-	if mediaIDStr != "" {
-		id, err := strconv.ParseUint(mediaIDStr, 10, 64)
-		if err == nil {
-			mediaID = uint(id)
-		}
-	}
-
-	// Get category
-	category := c.PostForm("category")
-	if category == "" {
-		category = "Event Photos"
-	}
+	file := upload.Files[0]
+	eventID, mediaID, category := upload.OwnerID, upload.MediaID, upload.Category
 
 	// Open file
 	src, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		utils.InternalServerError(c, "failed to open file")
 		return
 	}
 	defer src.Close()
@@ -76,9 +53,7 @@ func UploadFileHandler(c *gin.Context) {
 	fileData := make([]byte, file.Size)
 	n, err := src.Read(fileData)
 	if err != nil && err.Error() != "EOF" {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("failed to read file: %v", err),
-		})
+		utils.InternalServerError(c, fmt.Sprintf("failed to read file: %v", err))
 		return
 	}
 	if int64(n) != file.Size {
@@ -100,6 +75,10 @@ func UploadFileHandler(c *gin.Context) {
 			contentType = "image/gif"
 		case ".webp":
 			contentType = "image/webp"
+		case ".heic":
+			contentType = "image/heic"
+		case ".heif":
+			contentType = "image/heif"
 		case ".bmp":
 			contentType = "image/bmp"
 		case ".svg":
@@ -152,30 +131,34 @@ func UploadFileHandler(c *gin.Context) {
 
 	// Validate file size
 	if err := services.ValidateFileSize(file.Size, fileType); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate file type
 	if !services.ValidateFileType(contentType) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file type not allowed. Allowed types: " +
+		utils.BadRequest(c, "file type not allowed. Allowed types: " +
 				"Images (JPEG, PNG, GIF, WebP, BMP, SVG), " +
 				"Videos (MP4, MOV, AVI, WMV, WebM, MKV), " +
 				"Audio (MP3, WAV, OGG, AAC, M4A, FLAC), " +
-				"Documents (PDF, DOC, DOCX, XLS, XLSX, PPT, PPTX)",
-		})
+				"Documents (PDF, DOC, DOCX, XLS, XLSX, PPT, PPTX)")
 		return
 	}
 
+	// Strip EXIF (GPS location) from photos, apply their orientation and convert HEIC to JPEG
+	normalized, err := services.NormalizeImage(c.Request.Context(), fileData, contentType, file.Filename)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	fileData, contentType, filename := normalized.Data, normalized.ContentType, normalized.Filename
+
 	folder := services.GetFolderFromFileType(fileType)
 
 	// Upload to S3 - returns opaque S3 key and original filename
-	uploadResult, err := services.UploadFile(c.Request.Context(), fileData, file.Filename, contentType, folder)
+	uploadResult, err := services.UploadFile(c.Request.Context(), fileData, filename, contentType, folder)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to upload file",
-		})
+		utils.InternalServerError(c, "failed to upload file")
 		return
 	}
 
@@ -184,7 +167,7 @@ func UploadFileHandler(c *gin.Context) {
 		// Update existing media
 		var media models.EventMedia
 		if err := config.DB.First(&media, mediaID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			utils.NotFound(c, "media not found")
 			return
 		}
 
@@ -194,19 +177,47 @@ func UploadFileHandler(c *gin.Context) {
 		media.OriginalFilename = uploadResult.OriginalFilename
 		media.FileType = fileType
 		// FileURL is deprecated - leave empty to prevent raw URL usage
+		// The new file is not served until it has been scanned
+		media.ScanStatus = services.UploadScanStatus()
+		media.ScanDetail = ""
+		media.ScannedOn = nil
+
+		// Warn about near-identical photos of the event, other than the one being replaced
+		var duplicates []services.MediaDuplicate
+		media.PHash, duplicates = services.CheckMediaDuplicates(c.Request.Context(), services.ThumbnailTargetEventMedia, media.EventID, media.ID, fileData, contentType)
+
+		// Thumbnails and the video rendition of the previous file are stale; new ones are queued below
+		staleThumbnails := []*string{media.ThumbnailS3Key, media.ThumbnailMediumS3Key, media.TranscodedS3Key}
+		media.ThumbnailS3Key = nil
+		media.ThumbnailMediumS3Key = nil
+		media.TranscodeStatus = services.UploadTranscodeStatus(contentType)
+		media.TranscodeDetail = ""
+		media.TranscodedS3Key = nil
 		if err := config.DB.Save(&media).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update media record"})
+			utils.InternalServerError(c, "failed to update media record")
 			return
 		}
+		services.DeleteThumbnails(c.Request.Context(), staleThumbnails...)
+
+		// Extract text from press clippings in the background
+		services.QueueOCR(c.Request.Context(), services.OCRTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.QueueThumbnails(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, filename, contentType)
+		services.QueueMediaScan(c.Request.Context(), services.MediaScanTargetEventMedia, media.ID, media.S3Key)
+		services.QueueTranscode(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.PublishMediaUploaded(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID)
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "File uploaded and media updated successfully",
-			"data": gin.H{
-				"media_id":  media.ID,
-				"s3_key":    uploadResult.S3Key,
-				"file_type": fileType,
-			},
-		})
+		response := gin.H{
+			"media_id":  media.ID,
+			"s3_key":    uploadResult.S3Key,
+			"file_type": fileType,
+		}
+		if media.ScanStatus != nil {
+			response["scan_status"] = *media.ScanStatus
+		}
+		if len(duplicates) > 0 {
+			response["duplicates"] = duplicates
+		}
+		utils.OK(c, "File uploaded and media updated successfully", response)
 	} else {
 		// Create new media record (minimal record, can be updated later)
 		media := models.EventMedia{
@@ -214,9 +225,11 @@ func UploadFileHandler(c *gin.Context) {
 			S3Key:            uploadResult.S3Key,
 			OriginalFilename: uploadResult.OriginalFilename,
 			FileType:         fileType,
-			CompanyName:      file.Filename, // Keep for backward compatibility
+			CompanyName:      filename, // Keep for backward compatibility
 			FirstName:        "Uploaded",
 			LastName:         "File",
+			ScanStatus:       services.UploadScanStatus(),
+			TranscodeStatus:  services.UploadTranscodeStatus(contentType),
 		}
 		// DO NOT store raw S3 URLs - all access must use presigned URLs
 		// FileURL is deprecated - leave empty to prevent raw URL usage
@@ -227,115 +240,181 @@ func UploadFileHandler(c *gin.Context) {
 			media.MediaCoverageTypeID = mediaType.ID
 		}
 
+		// Warn about near-identical photos of the event
+		var duplicates []services.MediaDuplicate
+		media.PHash, duplicates = services.CheckMediaDuplicates(c.Request.Context(), services.ThumbnailTargetEventMedia, media.EventID, 0, fileData, contentType)
+
 		if err := config.DB.Create(&media).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create media record"})
+			utils.InternalServerError(c, "failed to create media record")
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"message": "File uploaded successfully",
-			"data": gin.H{
-				"media_id":         media.ID,
-				"s3_key":           uploadResult.S3Key,
-				"original_filename": uploadResult.OriginalFilename,
-				"file_type":        fileType,
-				"category":         category,
-			},
-		})
+		services.QueueOCR(c.Request.Context(), services.OCRTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.QueueThumbnails(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, filename, contentType)
+		services.QueueMediaScan(c.Request.Context(), services.MediaScanTargetEventMedia, media.ID, media.S3Key)
+		services.QueueTranscode(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.PublishMediaUploaded(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID)
+
+		response := gin.H{
+			"media_id":         media.ID,
+			"s3_key":           uploadResult.S3Key,
+			"original_filename": uploadResult.OriginalFilename,
+			"file_type":        fileType,
+			"category":         category,
+		}
+		if media.ScanStatus != nil {
+			response["scan_status"] = *media.ScanStatus
+		}
+		if len(duplicates) > 0 {
+			response["duplicates"] = duplicates
+		}
+		utils.Created(c, "File uploaded successfully", response)
 	}
 }
 
-// DownloadFileHandler generates a presigned URL for downloading a file
-// @Summary Get download URL for file
-// @Description Generates a presigned URL for downloading a file from S3
+// DownloadFileHandler serves a media file to users allowed to see it
+// @Summary Download file
+// @Description Downloads an event or branch media file after checking that it belongs to a branch the caller may access. The file is streamed through the API with a Content-Disposition attachment header carrying its original filename; with redirect=true the API answers with a 302 to a presigned URL valid for FILE_DOWNLOAD_URL_TTL (default 60s) instead. Files are only served once they passed the antivirus/moderation scan (when MEDIA_SCANNER is configured): 409 while the scan is pending, 403 for quarantined files or files that could not be scanned.
 // @Tags Files
 // @Security ApiKeyAuth
-// @Produce json
+// @Produce octet-stream
 // @Param media_id path int true "Media ID"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/files/{media_id}/download [get]
+// @Param redirect query bool false "Redirect to a short-lived presigned URL instead of streaming"
+// @Success 200 {file} binary
+// @Success 302 "Redirect to the presigned URL"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response "Outside the caller's branches, or the file failed the scan"
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response "The file is still being scanned"
+// @Router /api/v1/files/{media_id}/download [get]
 func DownloadFileHandler(c *gin.Context) {
 	mediaIDStr := c.Param("media_id")
 	mediaID, err := strconv.ParseUint(mediaIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media_id"})
+		utils.BadRequest(c, "invalid media_id")
 		return
 	}
 
-	var s3Key, fileType, originalFilename string
+	var s3Key, scopeKind, originalFilename string
+	var scanStatus *string
 
 	// Try EventMedia first
 	var eventMedia models.EventMedia
 	if err := config.DB.First(&eventMedia, mediaID).Error; err == nil {
-		// Prefer S3Key over FileURL (new approach)
-		if eventMedia.S3Key != "" {
-			s3Key = eventMedia.S3Key
-		} else if eventMedia.FileURL != "" {
+		s3Key = eventMedia.S3Key
+		if s3Key == "" && eventMedia.FileURL != "" {
 			// Fallback: extract S3 key from legacy FileURL
 			s3Key = services.GetS3KeyFromURL(eventMedia.FileURL)
 		}
-		fileType = eventMedia.FileType
-		// Prefer OriginalFilename over CompanyName
-		if eventMedia.OriginalFilename != "" {
-			originalFilename = eventMedia.OriginalFilename
-		} else {
-			originalFilename = eventMedia.CompanyName
-		}
+		scopeKind = services.ScopeEventMedia
+		scanStatus = eventMedia.ScanStatus
+		originalFilename = eventMedia.OriginalFilename
 	} else {
 		// Try BranchMedia
 		var branchMedia models.BranchMedia
 		if err := config.DB.First(&branchMedia, mediaID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			utils.NotFound(c, "media not found")
 			return
 		}
-		// BranchMedia doesn't have S3Key yet, extract from FileURL
-		if branchMedia.FileURL != "" {
+		s3Key = branchMedia.S3Key
+		if s3Key == "" && branchMedia.FileURL != "" {
 			s3Key = services.GetS3KeyFromURL(branchMedia.FileURL)
 		}
-		fileType = branchMedia.FileType
-		originalFilename = branchMedia.Name
+		scopeKind = services.ScopeBranchMedia
+		scanStatus = branchMedia.ScanStatus
+		originalFilename = branchMedia.OriginalFilename
+		if originalFilename == "" {
+			originalFilename = branchMedia.Name
+		}
 	}
 
+	if !checkRecordAccess(c, scopeKind, uint(mediaID)) {
+		return
+	}
+	if err := services.MediaDownloadable(scanStatus); err != nil {
+		if errors.Is(err, services.ErrMediaScanPending) {
+			utils.Conflict(c, err.Error())
+			return
+		}
+		utils.Forbidden(c, err.Error())
+		return
+	}
 	if s3Key == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "S3 key not found for this media"})
+		utils.NotFound(c, "S3 key not found for this media")
 		return
 	}
 
-	// Try to get original filename from S3 metadata if not in database
-	if originalFilename == "" {
-		originalFilename = services.GetOriginalFilename(c.Request.Context(), s3Key)
-		if originalFilename == "" {
-			// Final fallback: extract from S3 key
-			parts := strings.Split(s3Key, "/")
-			if len(parts) > 0 {
-				originalFilename = parts[len(parts)-1]
-			} else {
-				originalFilename = "download"
-			}
+	ctx := c.Request.Context()
+	// Presigned URLs are handed out per request and never cached, so that they expire
+	// shortly after the access check
+	c.Header("Cache-Control", "private, no-store")
+
+	if redirect, _ := strconv.ParseBool(c.Query("redirect")); redirect {
+		presignedURL, err := services.GetPresignedURL(ctx, s3Key, services.DownloadURLTTL())
+		if err != nil {
+			utils.InternalServerError(c, "failed to generate download URL")
+			return
+		}
+		c.Redirect(http.StatusFound, presignedURL)
+		return
+	}
+
+	storage, err := services.GetStorage()
+	if err != nil {
+		utils.InternalServerError(c, "file storage is not available")
+		return
+	}
+	info, err := storage.Head(ctx, s3Key)
+	if err != nil {
+		if errors.Is(err, services.ErrObjectNotFound) {
+			utils.NotFound(c, "file not found")
+			return
 		}
+		utils.InternalServerError(c, "failed to read file")
+		return
 	}
 
-	// Generate short-lived presigned URL (15 minutes for downloads)
-	presignedURL, err := services.GetPresignedURL(c.Request.Context(), s3Key, 15*time.Minute)
+	// Original filename: database, then S3 metadata, then the key itself
+	if originalFilename == "" {
+		originalFilename = info.Metadata["original-filename"]
+	}
+	if originalFilename == "" {
+		originalFilename = path.Base(s3Key)
+	}
+
+	reader, err := storage.Get(ctx, s3Key)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to generate download URL",
-		})
+		if errors.Is(err, services.ErrObjectNotFound) {
+			utils.NotFound(c, "file not found")
+			return
+		}
+		utils.InternalServerError(c, "failed to read file")
 		return
 	}
+	defer reader.Close()
 
-	c.JSON(http.StatusOK, gin.H{
-		"download_url": presignedURL,
-		"file_type":    fileType,
-		"file_name":    originalFilename,
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, map[string]string{
+		"Content-Disposition":    attachmentDisposition(originalFilename),
+		"X-Content-Type-Options": "nosniff",
 	})
 }
 
+// attachmentDisposition builds a Content-Disposition header that makes browsers save the
+// file under its original name (RFC 6266, non-ASCII names are encoded as filename*)
+func attachmentDisposition(filename string) string {
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
 // DeleteFileHandler deletes a file from S3 and the media record
 // @Summary Delete file from S3
-// @Description Deletes a file from S3 and optionally the media record. Optionally validates event_id or branch_id to ensure file belongs to specific event/branch.
+// @Description Deletes a file from S3 and optionally the media record, leaving a tombstone of the deleted record. Optionally validates event_id or branch_id to ensure file belongs to specific event/branch. Blocked with 423 while the media (or its event) is on legal hold.
 // @Tags Files
 // @Security ApiKeyAuth
 // @Produce json
@@ -344,15 +423,16 @@ func DownloadFileHandler(c *gin.Context) {
 // @Param branch_id query int false "Branch ID (optional, for validation)"
 // @Param is_child_branch query bool false "Whether this is a child branch (optional, for validation)"
 // @Param delete_record query bool false "Delete media record from database (default: true)"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/files/{media_id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Router /api/v1/files/{media_id} [delete]
 func DeleteFileHandler(c *gin.Context) {
 	mediaIDStr := c.Param("media_id")
 	mediaID, err := strconv.ParseUint(mediaIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media_id"})
+		utils.BadRequest(c, "invalid media_id")
 		return
 	}
 
@@ -371,9 +451,7 @@ func DeleteFileHandler(c *gin.Context) {
 		eventID, err := strconv.ParseUint(eventIDStr, 10, 64)
 		if err == nil {
 				if eventMedia.EventID != uint(eventID) {
-				c.JSON(http.StatusForbidden, gin.H{
-					"error": "file does not belong to the specified event",
-				})
+				utils.Forbidden(c, "file does not belong to the specified event")
 				return
 				}
 			}
@@ -382,7 +460,7 @@ func DeleteFileHandler(c *gin.Context) {
 		// Try BranchMedia
 		var branchMedia models.BranchMedia
 		if err := config.DB.First(&branchMedia, mediaID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			utils.NotFound(c, "media not found")
 			return
 		}
 		fileURL = branchMedia.FileURL
@@ -394,9 +472,7 @@ func DeleteFileHandler(c *gin.Context) {
 			branchID, err := strconv.ParseUint(branchIDStr, 10, 64)
 			if err == nil {
 				if branchMedia.BranchID != uint(branchID) {
-					c.JSON(http.StatusForbidden, gin.H{
-						"error": "file does not belong to the specified branch",
-					})
+					utils.Forbidden(c, "file does not belong to the specified branch")
 					return
 				}
 				// Check if branch is a child branch (has parent_branch_id)
@@ -405,15 +481,11 @@ func DeleteFileHandler(c *gin.Context) {
 					isChildBranchQuery := c.Query("is_child_branch")
 					isActuallyChildBranch := branch.ParentBranchID != nil
 					if isChildBranchQuery == "true" && !isActuallyChildBranch {
-						c.JSON(http.StatusForbidden, gin.H{
-							"error": "file does not belong to a child branch",
-						})
+						utils.Forbidden(c, "file does not belong to a child branch")
 						return
 					}
 					if isChildBranchQuery == "false" && isActuallyChildBranch {
-						c.JSON(http.StatusForbidden, gin.H{
-							"error": "file does not belong to a main branch",
-						})
+						utils.Forbidden(c, "file does not belong to a main branch")
 						return
 					}
 				}
@@ -421,39 +493,48 @@ func DeleteFileHandler(c *gin.Context) {
 		}
 	}
 
-	// Delete from S3 if file URL exists
-	if fileURL != "" {
-		s3Key := services.GetS3KeyFromURL(fileURL)
-		if s3Key != "" {
-			_ = services.DeleteFile(c.Request.Context(), s3Key)
-		}
-	}
-
 	// Delete media record if requested (default: true)
 	deleteRecord := c.DefaultQuery("delete_record", "true")
+
+	// Media (or events) on legal hold keep both their record and their file
+	holdType, holdID := models.LegalHoldEntityEventMedia, eventMedia.ID
+	if !isEventMedia {
+		holdType, holdID = models.LegalHoldEntityBranchMedia, uint(mediaID)
+	}
+	if err := services.EnsureNoLegalHold(holdType, holdID); err != nil {
+		respondFileDeleteError(c, err)
+		return
+	}
+
 	if deleteRecord == "true" {
+		// The record is deleted first so the file is only removed once a tombstone exists
 		if isEventMedia {
-			if err := config.DB.Delete(&eventMedia).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete media record"})
+			if err := services.DeleteEventMedia(eventMedia.ID, auditActor(c)); err != nil {
+				respondFileDeleteError(c, err)
 				return
 			}
+			services.DeleteThumbnails(c.Request.Context(), eventMedia.ThumbnailS3Key, eventMedia.ThumbnailMediumS3Key, eventMedia.TranscodedS3Key)
+			if fileURL != "" {
+				services.QueueStorageCleanup(c.Request.Context(), time.Time{}, services.GetS3KeyFromURL(fileURL))
+			}
 		} else {
-			var branchMedia models.BranchMedia
-			if err := config.DB.First(&branchMedia, mediaID).Error; err == nil {
-				if err := config.DB.Delete(&branchMedia).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete media record"})
-			return
-				}
+			// Branch media records are soft-deleted, so their object is kept to allow an admin restore
+			if err := services.DeleteBranchMedia(uint(mediaID), auditActor(c)); err != nil {
+				respondFileDeleteError(c, err)
+				return
 			}
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "File and media record deleted successfully"})
+		utils.OK(c, "File and media record deleted successfully", nil)
 	} else {
+		if fileURL != "" {
+			services.QueueStorageCleanup(c.Request.Context(), time.Time{}, services.GetS3KeyFromURL(fileURL))
+		}
 		// Just clear the file URL
 		if isEventMedia {
 			eventMedia.FileURL = ""
 			eventMedia.FileType = ""
 			if err := config.DB.Save(&eventMedia).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update media record"})
+				utils.InternalServerError(c, "failed to update media record")
 				return
 			}
 		} else {
@@ -462,18 +543,26 @@ func DeleteFileHandler(c *gin.Context) {
 				branchMedia.FileURL = ""
 				branchMedia.FileType = ""
 				if err := config.DB.Save(&branchMedia).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update media record"})
+			utils.InternalServerError(c, "failed to update media record")
 			return
 				}
 			}
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully, media record kept"})
+		utils.OK(c, "File deleted successfully, media record kept", nil)
 	}
 }
 
+func respondFileDeleteError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrLegalHold) || errors.Is(err, services.ErrArchivedYear) {
+		utils.ErrorResponse(c, http.StatusLocked, err.Error())
+		return
+	}
+	utils.InternalServerError(c, "failed to delete media record")
+}
+
 // UploadMultipleFilesHandler handles multiple file uploads to S3 in a single request
 // @Summary Upload multiple files to S3
-// @Description Upload multiple image, video, audio, or PDF files to S3 and associate with event media
+// @Description Upload multiple image, video, audio, or PDF files to S3 and associate with event media. Photos are stripped of EXIF metadata, rotated upright and HEIC converted to JPEG as for single uploads, and videos browsers cannot play are transcoded to MP4. Each result lists the existing photos of the event the image looks like in duplicates, and scan_status while files await the malware scan.
 // @Tags Files
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
@@ -481,42 +570,18 @@ func DeleteFileHandler(c *gin.Context) {
 // @Param files formData file true "Files to upload (multiple files allowed)"
 // @Param event_id formData int true "Event ID"
 // @Param category formData string false "File category (Event Photos, Video Coverage, Testimonials, Press Release)"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/files/upload-multiple [post]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response "Invalid metadata; all invalid fields are listed in details"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/files/upload-multiple [post]
 func UploadMultipleFilesHandler(c *gin.Context) {
-	// Get event ID
-	eventIDStr := c.PostForm("event_id")
-	if eventIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "event_id is required"})
-		return
-	}
-	eventID, err := strconv.ParseUint(eventIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event_id"})
-		return
-	}
-
-	// Get category
-	category := c.PostForm("category")
-	if category == "" {
-		category = "Event Photos"
-	}
-
-	// Get multipart form
-	form, err := c.MultipartForm()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse multipart form"})
-		return
-	}
-
-	// Get all files
-	files := form.File["files"]
-	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no files provided"})
+	// Validate metadata, then get all files
+	upload, ok := parseUploadRequest(c, eventMultiUploadSpec)
+	if !ok {
 		return
 	}
+	files := upload.Files
+	eventID := upload.OwnerID
 
 	// Process each file
 	var results []map[string]interface{}
@@ -557,6 +622,10 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 				contentType = "image/gif"
 			case ".webp":
 				contentType = "image/webp"
+			case ".heic":
+				contentType = "image/heic"
+			case ".heif":
+				contentType = "image/heif"
 			case ".bmp":
 				contentType = "image/bmp"
 			case ".svg":
@@ -619,10 +688,18 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 			continue
 		}
 
+		// Strip EXIF (GPS location) from photos, apply their orientation and convert HEIC to JPEG
+		normalized, err := services.NormalizeImage(c.Request.Context(), fileData, contentType, fileHeader.Filename)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", fileHeader.Filename, err))
+			continue
+		}
+		fileData, contentType, filename := normalized.Data, normalized.ContentType, normalized.Filename
+
 		folder := services.GetFolderFromFileType(fileType)
 
 		// Upload to S3 - returns opaque S3 key and original filename
-		uploadResult, err := services.UploadFile(c.Request.Context(), fileData, fileHeader.Filename, contentType, folder)
+		uploadResult, err := services.UploadFile(c.Request.Context(), fileData, filename, contentType, folder)
 		if err != nil {
 			// Check if this is an AWS credential/authentication error
 			errStr := err.Error()
@@ -636,10 +713,7 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 				strings.Contains(errStr, "AWS_SECRET_ACCESS_KEY") {
 				// AWS credential error - return HTTP 500 immediately, do NOT continue processing
 				// Do NOT attempt DB writes if S3 upload fails
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "AWS S3 authentication failed",
-					"details": fmt.Sprintf("S3 upload failed for %s: %v. Check AWS credentials (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY)", fileHeader.Filename, err),
-				})
+				utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "AWS S3 authentication failed", fmt.Sprintf("S3 upload failed for %s: %v. Check AWS credentials (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY)", fileHeader.Filename, err))
 				return
 			}
 			// Other S3 errors - add to errors list but continue processing other files
@@ -653,9 +727,11 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 			S3Key:            uploadResult.S3Key,
 			OriginalFilename: uploadResult.OriginalFilename,
 			FileType:         fileType,
-			CompanyName:      fileHeader.Filename, // Keep for backward compatibility
+			CompanyName:      filename, // Keep for backward compatibility
 			FirstName:        "Uploaded",
 			LastName:         "File",
+			ScanStatus:       services.UploadScanStatus(),
+			TranscodeStatus:  services.UploadTranscodeStatus(contentType),
 		}
 		// DO NOT store raw S3 URLs - all access must use presigned URLs
 		// FileURL is deprecated - leave empty to prevent raw URL usage
@@ -666,24 +742,41 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 			media.MediaCoverageTypeID = mediaType.ID
 		}
 
+		// Warn about near-identical photos of the event, including earlier files of this batch
+		var duplicates []services.MediaDuplicate
+		media.PHash, duplicates = services.CheckMediaDuplicates(c.Request.Context(), services.ThumbnailTargetEventMedia, media.EventID, 0, fileData, contentType)
+
 		if err := config.DB.Create(&media).Error; err != nil {
 			errors = append(errors, fmt.Sprintf("%s: failed to create media record", fileHeader.Filename))
 			continue
 		}
 
-		results = append(results, map[string]interface{}{
+		services.QueueOCR(c.Request.Context(), services.OCRTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.QueueThumbnails(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, filename, contentType)
+		services.QueueMediaScan(c.Request.Context(), services.MediaScanTargetEventMedia, media.ID, media.S3Key)
+		services.QueueTranscode(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID, media.S3Key, contentType)
+		services.PublishMediaUploaded(c.Request.Context(), services.ThumbnailTargetEventMedia, media.ID)
+
+		result := map[string]interface{}{
 			"filename":         fileHeader.Filename,
 			"media_id":         media.ID,
 			"s3_key":           uploadResult.S3Key,
 			"original_filename": uploadResult.OriginalFilename,
 			"file_type":        fileType,
 			"status":           "success",
-		})
+		}
+		if media.ScanStatus != nil {
+			result["scan_status"] = *media.ScanStatus
+		}
+		if len(duplicates) > 0 {
+			result["duplicates"] = duplicates
+		}
+		results = append(results, result)
 	}
 
 	// Return results
+	message := fmt.Sprintf("Processed %d file(s)", len(files))
 	response := map[string]interface{}{
-		"message": fmt.Sprintf("Processed %d file(s)", len(files)),
 		"success": len(results),
 		"failed":  len(errors),
 		"results": results,
@@ -694,15 +787,15 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 	}
 
 	if len(results) > 0 {
-		c.JSON(http.StatusOK, response)
+		utils.OK(c, message, response)
 	} else {
-		c.JSON(http.StatusBadRequest, response)
+		utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeBadRequest, "none of the files could be uploaded", response)
 	}
 }
 
 // UploadBranchFilesHandler handles multiple file uploads to S3 for branches
 // @Summary Upload multiple files to S3 for branch
-// @Description Upload multiple image, video, audio, or PDF files to S3 and associate with branch media (works for both branches and child branches)
+// @Description Upload multiple image, video, audio, or PDF files to S3 and associate with branch media (works for both branches and child branches). Photos are stripped of EXIF metadata, rotated upright and HEIC converted to JPEG as for single uploads, and videos browsers cannot play are transcoded to MP4. Each result lists the existing photos of the branch the image looks like in duplicates, and scan_status while files await the malware scan.
 // @Tags Files
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
@@ -710,50 +803,19 @@ func UploadMultipleFilesHandler(c *gin.Context) {
 // @Param files formData file true "Files to upload (multiple files allowed)"
 // @Param branch_id formData int true "Branch ID"
 // @Param category formData string false "File category (Branch Photos, Video Coverage, Documents, Other)"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/files/upload-branch [post]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response "Invalid metadata; all invalid fields are listed in details"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/files/upload-branch [post]
 func UploadBranchFilesHandler(c *gin.Context) {
-	// Get branch ID
-	branchIDStr := c.PostForm("branch_id")
-	if branchIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "branch_id is required"})
-		return
-	}
-	branchID, err := strconv.ParseUint(branchIDStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch_id"})
-		return
-	}
-
-	// Check if branch is a child branch by checking parent_branch_id
-	var branch models.Branch
-	if err := config.DB.Select("parent_branch_id").First(&branch, branchID).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "branch not found"})
-		return
-	}
-	isChildBranch := branch.ParentBranchID != nil
-
-	// Get category
-	category := c.PostForm("category")
-	if category == "" {
-		category = "Branch Photos"
-	}
-
-	// Get multipart form
-	form, err := c.MultipartForm()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse multipart form"})
-		return
-	}
-
-	// Get all files
-	files := form.File["files"]
-	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no files provided"})
+	// Validate metadata (the branch must exist), then get all files
+	upload, ok := parseUploadRequest(c, branchUploadSpec)
+	if !ok {
 		return
 	}
+	files := upload.Files
+	branchID, category := upload.OwnerID, upload.Category
+	isChildBranch := upload.Branch.ParentBranchID != nil
 
 	// Process each file
 	var results []map[string]interface{}
@@ -794,6 +856,10 @@ func UploadBranchFilesHandler(c *gin.Context) {
 				contentType = "image/gif"
 			case ".webp":
 				contentType = "image/webp"
+			case ".heic":
+				contentType = "image/heic"
+			case ".heif":
+				contentType = "image/heif"
 			case ".bmp":
 				contentType = "image/bmp"
 			case ".svg":
@@ -856,6 +922,14 @@ func UploadBranchFilesHandler(c *gin.Context) {
 			continue
 		}
 
+		// Strip EXIF (GPS location) from photos, apply their orientation and convert HEIC to JPEG
+		normalized, err := services.NormalizeImage(c.Request.Context(), fileData, contentType, fileHeader.Filename)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", fileHeader.Filename, err))
+			continue
+		}
+		fileData, contentType, filename := normalized.Data, normalized.ContentType, normalized.Filename
+
 		// Create folder path: branches/{branchId}/images/ or child-branches/{branchId}/images/
 		baseFolder := "branches"
 		if isChildBranch {
@@ -865,7 +939,7 @@ func UploadBranchFilesHandler(c *gin.Context) {
 		folder := fmt.Sprintf("%s/%d/%s", baseFolder, branchID, fileTypeFolder)
 
 		// Upload to S3 - returns opaque S3 key and original filename
-		uploadResult, err := services.UploadFile(c.Request.Context(), fileData, fileHeader.Filename, contentType, folder)
+		uploadResult, err := services.UploadFile(c.Request.Context(), fileData, filename, contentType, folder)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", fileHeader.Filename, err))
 			continue
@@ -876,29 +950,49 @@ func UploadBranchFilesHandler(c *gin.Context) {
 			BranchID: uint(branchID),
 			// DO NOT store raw S3 URLs - all access must use presigned URLs
 			// FileURL is deprecated - leave empty to prevent raw URL usage
-			FileType: fileType,
-			Name:     fileHeader.Filename,
-			Category: category,
+			S3Key:            uploadResult.S3Key,
+			OriginalFilename: uploadResult.OriginalFilename,
+			FileType:         fileType,
+			Name:             filename,
+			Category:         category,
+			ScanStatus:       services.UploadScanStatus(),
+			TranscodeStatus:  services.UploadTranscodeStatus(contentType),
 		}
 
+		// Warn about near-identical photos of the branch, including earlier files of this batch
+		var duplicates []services.MediaDuplicate
+		media.PHash, duplicates = services.CheckMediaDuplicates(c.Request.Context(), services.ThumbnailTargetBranchMedia, media.BranchID, 0, fileData, contentType)
+
 		if err := config.DB.Create(&media).Error; err != nil {
 			errors = append(errors, fmt.Sprintf("%s: failed to create media record", fileHeader.Filename))
 			continue
 		}
 
-		results = append(results, map[string]interface{}{
+		services.QueueThumbnails(c.Request.Context(), services.ThumbnailTargetBranchMedia, media.ID, media.S3Key, filename, contentType)
+		services.QueueMediaScan(c.Request.Context(), services.MediaScanTargetBranchMedia, media.ID, media.S3Key)
+		services.QueueTranscode(c.Request.Context(), services.ThumbnailTargetBranchMedia, media.ID, media.S3Key, contentType)
+		services.PublishMediaUploaded(c.Request.Context(), services.ThumbnailTargetBranchMedia, media.ID)
+
+		result := map[string]interface{}{
 			"filename":         fileHeader.Filename,
 			"media_id":          media.ID,
 			"s3_key":            uploadResult.S3Key,
 			"original_filename": uploadResult.OriginalFilename,
 			"file_type":         fileType,
 			"status":            "success",
-		})
+		}
+		if media.ScanStatus != nil {
+			result["scan_status"] = *media.ScanStatus
+		}
+		if len(duplicates) > 0 {
+			result["duplicates"] = duplicates
+		}
+		results = append(results, result)
 	}
 
 	// Return results
+	message := fmt.Sprintf("Processed %d file(s)", len(files))
 	response := map[string]interface{}{
-		"message": fmt.Sprintf("Processed %d file(s)", len(files)),
 		"success": len(results),
 		"failed":  len(errors),
 		"results": results,
@@ -909,8 +1003,8 @@ func UploadBranchFilesHandler(c *gin.Context) {
 	}
 
 	if len(results) > 0 {
-		c.JSON(http.StatusOK, response)
+		utils.OK(c, message, response)
 	} else {
-		c.JSON(http.StatusBadRequest, response)
+		utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeBadRequest, "none of the files could be uploaded", response)
 	}
 }
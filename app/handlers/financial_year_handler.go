@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveFinancialYearRequest archives a financial year
+type ArchiveFinancialYearRequest struct {
+	Note string `json:"note" binding:"max=2000"`
+}
+
+// GetArchivedFinancialYearsHandler godoc
+// @Summary List archived financial years
+// @Description Returns the archived financial years, newest first, with the event and donation totals of their rollups. Admin only.
+// @Tags Financial Years
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.ArchivedFinancialYear}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/financial-years [get]
+func GetArchivedFinancialYearsHandler(c *gin.Context) {
+	years, err := services.ListArchivedFinancialYears()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", years)
+}
+
+// ArchiveFinancialYearHandler godoc
+// @Summary Archive a financial year
+// @Description Makes a past financial year (April-March) read-only: its events and their media, special guests, volunteers, donations and promotion materials stay queryable, but every write to them is rejected with 423. Dashboard and branch stats for the year are served from rollups computed now, and its rows leave the full-text search indexes (search with include_archived=true). Years with events awaiting review cannot be archived. Admin only.
+// @Tags Financial Years
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param year path string true "Financial year, e.g. 2023-24"
+// @Param request body ArchiveFinancialYearRequest false "Note"
+// @Success 201 {object} utils.Response{data=models.ArchivedFinancialYear}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/financial-years/{year}/archive [post]
+func ArchiveFinancialYearHandler(c *gin.Context) {
+	var req ArchiveFinancialYearRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	year, err := services.ArchiveFinancialYear(c.Param("year"), req.Note, auditActor(c))
+	if err != nil {
+		respondFinancialYearError(c, err)
+		return
+	}
+	utils.Created(c, "Financial year archived", year)
+}
+
+// UnarchiveFinancialYearHandler godoc
+// @Summary Unarchive a financial year
+// @Description Makes an archived financial year writable again. Its rollups are dropped and its stats are computed live again. Admin only.
+// @Tags Financial Years
+// @Security ApiKeyAuth
+// @Produce json
+// @Param year path string true "Financial year, e.g. 2023-24"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/financial-years/{year}/unarchive [post]
+func UnarchiveFinancialYearHandler(c *gin.Context) {
+	if err := services.UnarchiveFinancialYear(c.Param("year"), auditActor(c)); err != nil {
+		respondFinancialYearError(c, err)
+		return
+	}
+	utils.OK(c, "Financial year unarchived", nil)
+}
+
+func respondFinancialYearError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidFinancialYear):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrFinancialYearArchived):
+		utils.Conflict(c, err.Error())
+	case errors.Is(err, services.ErrFinancialYearNotArchived):
+		utils.NotFound(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// respondArchivedYear writes a 423 for writes rejected because their financial year is
+// archived and reports whether it did
+func respondArchivedYear(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrArchivedYear) {
+		return false
+	}
+	utils.ErrorResponse(c, http.StatusLocked, err.Error())
+	return true
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
+	"github.com/gin-gonic/gin"
+)
+
+// GuestFollowUpRequest records the follow-up of a special guest appearance. Dates are
+// YYYY-MM-DD; an empty string clears the date (clearing feedback_received_on also clears
+// the feedback). Omitted fields are left unchanged.
+type GuestFollowUpRequest struct {
+	LetterSentOn       *string `json:"letter_sent_on"`
+	FeedbackReceivedOn *string `json:"feedback_received_on"`
+	Feedback           *string `json:"feedback" binding:"omitempty,max=2000"`
+}
+
+// GuestFollowUpsResponse lists the appearances due for protocol follow-up
+type GuestFollowUpsResponse struct {
+	Appearances []services.GuestAppearance `json:"appearances"`
+	Truncated   bool                       `json:"truncated"`
+}
+
+// SearchGuestsHandler lists special guest master records
+// @Summary Search special guests
+// @Description Finds guest master records by name across scripts/spellings, organization, designation, email or contact number, with the number of events they appeared at. Users with a branch scope see the guests who appeared at events of their branches, and those appearances only.
+// @Tags Guests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string false "Name, organization, designation, email or contact number"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response{data=[]services.GuestSummary}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/guests [get]
+func SearchGuestsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	guests, err := services.SearchGuests(c.Query("search"), limit, offset, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", guests)
+}
+
+// GetGuestHandler returns a special guest with all their appearances
+// @Summary Get a special guest
+// @Description Returns the guest master record with every event they appeared at and the follow-up of each appearance, most recent first. Users with a branch scope see the appearances at events of their branches, and get 403 for guests who never appeared there.
+// @Tags Guests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Guest ID"
+// @Success 200 {object} utils.Response{data=services.GuestProfile}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/guests/{id} [get]
+func GetGuestHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid guest ID")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	profile, err := services.GetGuestProfile(uint(id), scope)
+	if err != nil {
+		respondGuestError(c, err)
+		return
+	}
+
+	utils.OK(c, "", profile)
+}
+
+// UpdateGuestHandler updates a special guest master record
+// @Summary Update a special guest
+// @Description Updates the details of the guest master record (prefix, name, designation, organization, email, contact, city, state). Appearances keep the details recorded at the event. Users with a branch scope can only update guests who appeared at events of their branches.
+// @Tags Guests
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Guest ID"
+// @Param payload body object true "Fields to update"
+// @Success 200 {object} utils.Response{data=models.Guest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/guests/{id} [put]
+func UpdateGuestHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid guest ID")
+		return
+	}
+
+	var updateData map[string]interface{}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if len(updateData) == 0 {
+		utils.BadRequest(c, "no valid fields provided")
+		return
+	}
+	if err := validators.ValidateGuestUpdateFields(updateData); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	guest, err := services.UpdateGuest(uint(id), updateData, scope)
+	if err != nil {
+		respondGuestError(c, err)
+		return
+	}
+
+	utils.OK(c, "Guest updated", guest)
+}
+
+func respondGuestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGuestNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrOutOfBranchScope):
+		utils.Forbidden(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
+
+// GetGuestFollowUpsHandler lists guest appearances for protocol follow-up
+// @Summary List guests for follow-up
+// @Description Lists the special guests who attended events in the date range, oldest event first, with the follow-up of each appearance (thank-you letter sent, feedback received). Users with a branch scope only see events of their branches.
+// @Tags Guests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param from query string false "Events starting on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Events starting on or before this date (YYYY-MM-DD)"
+// @Param branch_id query int false "Branch ID"
+// @Param letter_sent query bool false "Only appearances with (true) or without (false) a letter sent"
+// @Param feedback_received query bool false "Only appearances with (true) or without (false) feedback received"
+// @Success 200 {object} utils.Response{data=GuestFollowUpsResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/guests/follow-ups [get]
+func GetGuestFollowUpsHandler(c *gin.Context) {
+	var filter services.GuestFollowUpFilter
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid from (use YYYY-MM-DD)")
+			return
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid to (use YYYY-MM-DD)")
+			return
+		}
+		// Include the whole "to" day
+		to = to.Add(24*time.Hour - time.Nanosecond)
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		utils.BadRequest(c, "to must not be before from")
+		return
+	}
+	if value := c.Query("branch_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid branch_id")
+			return
+		}
+		filter.BranchID = uint(id)
+		if !checkBranchAccess(c, &filter.BranchID) {
+			return
+		}
+	}
+	for param, target := range map[string]**bool{
+		"letter_sent":       &filter.LetterSent,
+		"feedback_received": &filter.FeedbackReceived,
+	} {
+		if value := c.Query(param); value != "" {
+			set, err := strconv.ParseBool(value)
+			if err != nil {
+				utils.BadRequest(c, param+" must be true or false")
+				return
+			}
+			*target = &set
+		}
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	filter.Scope = scope
+
+	appearances, truncated, err := services.GetGuestFollowUps(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", GuestFollowUpsResponse{Appearances: appearances, Truncated: truncated})
+}
+
+// UpdateGuestFollowUpHandler records the follow-up of a special guest appearance
+// @Summary Record guest follow-up
+// @Description Sets the date the thank-you letter was sent to the guest and the date and content of the feedback received for one appearance
+// @Tags SpecialGuests
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Special guest ID"
+// @Param payload body GuestFollowUpRequest true "Follow-up"
+// @Success 200 {object} utils.Response{data=services.GuestAppearance}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests/{id}/follow-up [patch]
+func UpdateGuestFollowUpHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid special guest ID")
+		return
+	}
+
+	var req GuestFollowUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	input := services.GuestFollowUpInput{Feedback: req.Feedback}
+	for field, date := range map[string]struct {
+		value  *string
+		target **time.Time
+		clear  *bool
+	}{
+		"letter_sent_on":       {req.LetterSentOn, &input.LetterSentOn, &input.ClearLetter},
+		"feedback_received_on": {req.FeedbackReceivedOn, &input.FeedbackReceivedOn, &input.ClearFeedback},
+	} {
+		switch {
+		case date.value == nil:
+		case *date.value == "":
+			*date.clear = true
+		default:
+			parsed, err := time.Parse("2006-01-02", *date.value)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+field+" (use YYYY-MM-DD)")
+				return
+			}
+			*date.target = &parsed
+		}
+	}
+
+	appearance, err := services.UpdateGuestFollowUp(uint(id), input)
+	if err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrSpecialGuestNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidGuestFollowUp):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "Follow-up updated", appearance)
+}
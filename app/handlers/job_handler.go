@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyJobsHandler godoc
+// @Summary List my background jobs
+// @Description Returns the background jobs (reports, imports) started by the current user, newest first. Finished jobs that produced a file include result.download_url.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param type query string false "Job type (thumbnails, event_report, branch_import, email, storage_cleanup, media_scan_backfill, media_export, media_import, approval_digest, garbage_collection, media_transcode, storage_reconcile, storage_usage)"
+// @Param status query string false "Status (queued, running, succeeded, failed)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/jobs [get]
+func GetMyJobsHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	filter := jobFilterFromQuery(c)
+	filter.CreatedBy = &userID
+	listJobs(c, filter)
+}
+
+// GetJobsHandler godoc
+// @Summary List all background jobs
+// @Description Returns background jobs of every user, newest first. Admin only.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param type query string false "Job type (thumbnails, event_report, branch_import, email, storage_cleanup, media_scan_backfill, media_export, media_import, approval_digest, garbage_collection, media_transcode, storage_reconcile, storage_usage)"
+// @Param status query string false "Status (queued, running, succeeded, failed)"
+// @Param created_by query int false "User who started the job"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/jobs [get]
+func GetJobsHandler(c *gin.Context) {
+	filter := jobFilterFromQuery(c)
+	if value := c.Query("created_by"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid created_by")
+			return
+		}
+		createdBy := uint(id)
+		filter.CreatedBy = &createdBy
+	}
+	listJobs(c, filter)
+}
+
+// GetJobHandler godoc
+// @Summary Get a background job
+// @Description Returns the status and progress of a job, for polling. Users see their own jobs, admins every job.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/jobs/{id} [get]
+func GetJobHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := services.GetJob(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	if !canViewJob(c, job) {
+		respondJobError(c, services.ErrJobNotFound)
+		return
+	}
+	utils.OK(c, "", job)
+}
+
+// RetryJobHandler godoc
+// @Summary Retry a failed background job
+// @Description Queues a failed job again with a fresh set of attempts. Admin only.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/jobs/{id}/retry [post]
+func RetryJobHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+	job, err := services.RetryJob(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	utils.OK(c, "", job)
+}
+
+// StartGarbageCollectionHandler godoc
+// @Summary Run garbage collection
+// @Description Queues a background job that deletes sessions and auth tokens expired or revoked more than a day ago, drafts not saved for EVENT_DRAFT_RETENTION_DAYS and finished jobs older than JOB_RETENTION_DAYS (default 30). Its result counts the deleted rows per table; poll GET /api/jobs/{id}. Admin only.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 409 {object} utils.Response "A garbage collection is already running"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/gc [post]
+func StartGarbageCollectionHandler(c *gin.Context) {
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartGarbageCollection(c.Request.Context(), createdBy)
+	if err != nil {
+		if errors.Is(err, services.ErrGarbageCollectionRunning) {
+			utils.Conflict(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.Accepted(c, "", job)
+}
+
+// StartStorageReconcileHandler godoc
+// @Summary Reconcile storage with the database
+// @Description Queues a background job that lists the bucket and compares it with every stored object key (event and branch media with their thumbnails and renditions, donation receipts, public assets, tombstones). Its result counts and lists (up to 1000 each) orphans, objects older than grace_hours (default 24) that no row points at, and missing_rows, rows whose object is gone. Orphans are only deleted with delete_orphans. Job files, exports, manifests and upload parts are left alone. Poll GET /api/jobs/{id}. Admin only.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.StorageReconcileOptions false "delete_orphans, grace_hours"
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response "A reconciliation is already running"
+// @Failure 503 {object} utils.Response "Storage is not configured"
+// @Router /api/v1/admin/storage/reconcile [post]
+func StartStorageReconcileHandler(c *gin.Context) {
+	var opts services.StorageReconcileOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartStorageReconcile(c.Request.Context(), opts, createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrStorageReconcileRunning):
+			utils.Conflict(c, err.Error())
+		case errors.Is(err, services.ErrStorageNotConfigured):
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.Accepted(c, "", job)
+}
+
+func jobFilterFromQuery(c *gin.Context) services.JobFilter {
+	filter := services.JobFilter{
+		Type:   c.Query("type"),
+		Status: c.Query("status"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	return filter
+}
+
+func listJobs(c *gin.Context, filter services.JobFilter) {
+	jobs, total, err := services.ListJobs(c.Request.Context(), filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":  jobs,
+		"total": total,
+	})
+}
+
+// canViewJob lets users poll the jobs they started; admins see every job
+func canViewJob(c *gin.Context, job *models.Job) bool {
+	if roleID, ok := middleware.CurrentRoleID(c); ok && roleID == models.RoleAdmin {
+		return true
+	}
+	userID, ok := middleware.CurrentUserID(c)
+	return ok && job.CreatedBy != nil && *job.CreatedBy == userID
+}
+
+func jobIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid job ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrJobNotRetryable):
+		utils.Conflict(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
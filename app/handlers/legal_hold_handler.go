@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// LegalHoldRequest places a legal hold
+type LegalHoldRequest struct {
+	EntityType string `json:"entity_type" binding:"required,oneof=event event_media branch_media"`
+	EntityID   uint   `json:"entity_id" binding:"required"`
+	Reason     string `json:"reason" binding:"required,max=2000"`
+}
+
+// GetLegalHoldsHandler godoc
+// @Summary List legal holds
+// @Description Returns legal holds, newest first. Admin only.
+// @Tags Legal Holds
+// @Security ApiKeyAuth
+// @Produce json
+// @Param entity_type query string false "event, event_media or branch_media"
+// @Param entity_id query int false "Entity ID"
+// @Param active query bool false "Only holds that have not been released"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/legal-holds [get]
+func GetLegalHoldsHandler(c *gin.Context) {
+	filter := services.LegalHoldFilter{
+		EntityType: c.Query("entity_type"),
+		ActiveOnly: c.Query("active") == "true",
+	}
+	if value := c.Query("entity_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid entity_id")
+			return
+		}
+		filter.EntityID = uint(id)
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	holds, total, err := services.ListLegalHolds(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":  holds,
+		"total": total,
+	})
+}
+
+// PlaceLegalHoldHandler godoc
+// @Summary Place a legal hold
+// @Description Blocks deletion of an event (and all of its media) or of a single media record while litigation or an audit is pending. Deletes answer 423 until the hold is released. Admin only.
+// @Tags Legal Holds
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body LegalHoldRequest true "Entity and reason"
+// @Success 201 {object} utils.Response{data=models.LegalHold}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/legal-holds [post]
+func PlaceLegalHoldHandler(c *gin.Context) {
+	var req LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	hold, err := services.PlaceLegalHold(services.LegalHoldInput{
+		EntityType: req.EntityType,
+		EntityID:   req.EntityID,
+		Reason:     req.Reason,
+	}, auditActor(c))
+	if err != nil {
+		respondLegalHoldError(c, err)
+		return
+	}
+	utils.Created(c, "", hold)
+}
+
+// ReleaseLegalHoldHandler godoc
+// @Summary Release a legal hold
+// @Description Lifts the hold so the entity can be deleted again. The hold stays on record. Admin only.
+// @Tags Legal Holds
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Legal hold ID"
+// @Success 200 {object} utils.Response{data=models.LegalHold}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/legal-holds/{id}/release [post]
+func ReleaseLegalHoldHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid legal hold ID")
+		return
+	}
+	hold, err := services.ReleaseLegalHold(uint(id), auditActor(c))
+	if err != nil {
+		respondLegalHoldError(c, err)
+		return
+	}
+	utils.OK(c, "", hold)
+}
+
+// GetMediaTombstonesHandler godoc
+// @Summary List deleted media
+// @Description Returns tombstones of deleted event and branch media: who deleted it, when, and the record as it was. Admin only.
+// @Tags Legal Holds
+// @Security ApiKeyAuth
+// @Produce json
+// @Param media_type query string false "event_media or branch_media"
+// @Param media_id query int false "Media ID"
+// @Param event_id query int false "Event ID"
+// @Param branch_id query int false "Branch ID"
+// @Param deleted_by query int false "User who deleted the media"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/media-tombstones [get]
+func GetMediaTombstonesHandler(c *gin.Context) {
+	filter := services.MediaTombstoneFilter{MediaType: c.Query("media_type")}
+	for param, target := range map[string]*uint{
+		"media_id":  &filter.MediaID,
+		"event_id":  &filter.EventID,
+		"branch_id": &filter.BranchID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+param)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	if value := c.Query("deleted_by"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid deleted_by")
+			return
+		}
+		deletedBy := uint(id)
+		filter.DeletedBy = &deletedBy
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	tombstones, total, err := services.ListMediaTombstones(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":  tombstones,
+		"total": total,
+	})
+}
+
+func respondLegalHoldError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidLegalHold):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrLegalHoldNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrLegalHoldExists), errors.Is(err, services.ErrLegalHoldReleased):
+		utils.Conflict(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ServeLocalFileHandler godoc
+// @Summary Download a locally stored file
+// @Description Serves files of the local storage backend (STORAGE_DRIVER=local) through the signed links returned as presigned URLs. No login is needed; the link's expires and signature parameters authorize the download. Supports Range requests.
+// @Tags Files
+// @Produce octet-stream
+// @Param key path string true "Object key"
+// @Param expires query int true "Expiry (Unix seconds)"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/files/local/{key} [get]
+func ServeLocalFileHandler(c *gin.Context) {
+	storage, err := services.GetStorage()
+	local, ok := storage.(*services.LocalStorage)
+	if err != nil || !ok {
+		utils.NotFound(c, "local file storage is not enabled")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.VerifySignature(key, c.Query("expires"), c.Query("signature")); err != nil {
+		utils.Forbidden(c, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	info, err := local.Head(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrObjectNotFound):
+			utils.NotFound(c, "file not found")
+		case errors.Is(err, services.ErrInvalidObjectKey):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	reader, err := local.Get(ctx, key)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	// Same caching as presigned S3 URLs
+	c.Header("Cache-Control", "public, max-age=3600")
+	if info.ETag != "" {
+		c.Header("ETag", `"`+info.ETag+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, path.Base(key), info.LastModified, reader.(io.ReadSeeker))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxLocationImportFileSize caps uploaded location datasets
+const maxLocationImportFileSize = 20 << 20 // 20MB
+
+// ImportLocationsHandler godoc
+// @Summary Bulk import locations
+// @Description Loads an official location dataset from a CSV or XLSX file (first sheet) with the columns country, state, district and city, one path of the hierarchy per row (state, district and city may be empty). Locations are matched by case-insensitive name under their parent and created when missing, so re-importing a dataset is safe; existing cities without a district are linked to the district of their row. All rows are validated first; nothing is written if any row fails or dry_run is set. Admin only.
+// @Tags Location
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run formData bool false "Validate and count only, do not insert"
+// @Success 200 {object} utils.Response{data=services.LocationImportResult} "Dry run"
+// @Success 201 {object} utils.Response{data=services.LocationImportResult} "Imported"
+// @Failure 400 {object} utils.Response
+// @Failure 422 {object} utils.Response{details=services.LocationImportResult} "Row validation errors"
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/locations/import [post]
+func ImportLocationsHandler(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "file is required")
+		return
+	}
+	if file.Size > maxLocationImportFileSize {
+		utils.BadRequest(c, "file exceeds the 20MB import limit")
+		return
+	}
+
+	dryRun := false
+	if value := c.PostForm("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "invalid dry_run")
+			return
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.InternalServerError(c, "failed to open file")
+		return
+	}
+	defer src.Close()
+
+	rows, err := services.ParseLocationImportFile(file.Filename, src)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := services.ImportLocations(rows, dryRun)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		utils.ErrorCodeResponse(c, http.StatusUnprocessableEntity, utils.CodeValidationFailed, "some rows are invalid, nothing was imported", result)
+	case result.DryRun:
+		utils.OK(c, "", result)
+	default:
+		utils.Created(c, "", result)
+	}
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"net/http"
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,16 +16,16 @@ import (
 // @Tags EventTypes
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.EventType
-// @Failure 500 {object} map[string]string
-// @Router /api/event-types [get]
+// @Success 200 {object} utils.Response{data=[]models.EventType}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-types [get]
 func GetAllEventTypesHandler(c *gin.Context) {
 	list, err := services.GetAllEventTypesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, list)
+	utils.OK(c, "", list)
 }
 
 // --------------------- Event Categories ---------------------
@@ -36,16 +36,16 @@ func GetAllEventTypesHandler(c *gin.Context) {
 // @Tags EventCategories
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.EventCategory
-// @Failure 500 {object} map[string]string
-// @Router /api/event-categories [get]
+// @Success 200 {object} utils.Response{data=[]models.EventCategory}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-categories [get]
 func GetAllEventCategoriesHandler(c *gin.Context) {
 	list, err := services.GetAllEventCategoriesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, list)
+	utils.OK(c, "", list)
 }
 
 // --------------------- Countries ---------------------
@@ -56,16 +56,16 @@ func GetAllEventCategoriesHandler(c *gin.Context) {
 // @Tags Location
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Country
-// @Failure 500 {object} map[string]string
-// @Router /api/countries [get]
+// @Success 200 {object} utils.Response{data=[]models.Country}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/countries [get]
 func GetAllCountriesHandler(c *gin.Context) {
 	countries, err := services.GetAllCountriesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, countries)
+	utils.OK(c, "", countries)
 }
 
 // GetAllStatesHandler godoc
@@ -74,16 +74,16 @@ func GetAllCountriesHandler(c *gin.Context) {
 // @Tags Location
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.State
-// @Failure 500 {object} map[string]string
-// @Router /api/states [get]
+// @Success 200 {object} utils.Response{data=[]models.State}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/states [get]
 func GetAllStatesHandler(c *gin.Context) {
 	states, err := services.GetAllStatesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, states)
+	utils.OK(c, "", states)
 }
 
 // GetStatesByCountryHandler godoc
@@ -93,25 +93,79 @@ func GetAllStatesHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param country_id path int true "Country ID"
-// @Success 200 {array} models.State
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/countries/{country_id}/states [get]
+// @Success 200 {object} utils.Response{data=[]models.State}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/countries/{country_id}/states [get]
 func GetStatesByCountryHandler(c *gin.Context) {
 	countryIDStr := c.Param("country_id")
 	countryID, err := strconv.ParseUint(countryIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid country ID"})
+		utils.BadRequest(c, "Invalid country ID")
 		return
 	}
 
 	states, err := services.GetStatesByCountryService(uint(countryID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, states)
+	utils.OK(c, "", states)
+}
+
+// GetDistrictsByStateHandler godoc
+// @Summary Get districts by state ID
+// @Description Returns the districts of a state, ordered by name, for cascading location selection
+// @Tags Location
+// @Security ApiKeyAuth
+// @Produce json
+// @Param state_id path int true "State ID"
+// @Success 200 {object} utils.Response{data=[]models.District}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/states/{state_id}/districts [get]
+func GetDistrictsByStateHandler(c *gin.Context) {
+	stateID, err := strconv.ParseUint(c.Param("state_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid state ID")
+		return
+	}
+
+	districts, err := services.GetDistrictsByStateService(uint(stateID))
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", districts)
+}
+
+// GetCitiesByDistrictHandler godoc
+// @Summary Get cities by district ID
+// @Description Returns the cities of a district, ordered by name, for cascading location selection. Cities not linked to a district yet are only listed by state (/cities/by-state).
+// @Tags Location
+// @Security ApiKeyAuth
+// @Produce json
+// @Param district_id path int true "District ID"
+// @Success 200 {object} utils.Response{data=[]models.City}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/districts/{district_id}/cities [get]
+func GetCitiesByDistrictHandler(c *gin.Context) {
+	districtID, err := strconv.ParseUint(c.Param("district_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid district ID")
+		return
+	}
+
+	cities, err := services.GetCitiesByDistrictService(uint(districtID))
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", cities)
 }
 
 // --------------------- Cities ---------------------
@@ -122,16 +176,16 @@ func GetStatesByCountryHandler(c *gin.Context) {
 // @Tags Cities
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.City
-// @Failure 500 {object} map[string]string
-// @Router /api/cities [get]
+// @Success 200 {object} utils.Response{data=[]models.City}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/cities [get]
 func GetAllCitiesHandler(c *gin.Context) {
 	cities, err := services.GetAllCitiesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, cities)
+	utils.OK(c, "", cities)
 }
 
 // GetCitiesByStateHandler godoc
@@ -141,30 +195,30 @@ func GetAllCitiesHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param state_id query int true "State ID"
-// @Success 200 {array} models.City
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/cities/by-state [get]
+// @Success 200 {object} utils.Response{data=[]models.City}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/cities/by-state [get]
 func GetCitiesByStateHandler(c *gin.Context) {
 	stateIDStr := c.Query("state_id")
 	if stateIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "state_id query parameter is required"})
+		utils.BadRequest(c, "state_id query parameter is required")
 		return
 	}
 
 	stateID, err := strconv.ParseUint(stateIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state_id"})
+		utils.BadRequest(c, "Invalid state_id")
 		return
 	}
 
 	cities, err := services.GetCitiesByStateService(uint(stateID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, cities)
+	utils.OK(c, "", cities)
 }
 
 // --------------------- Districts ---------------------
@@ -177,9 +231,9 @@ func GetCitiesByStateHandler(c *gin.Context) {
 // @Produce json
 // @Param state_id query int false "State ID"
 // @Param country_id query int false "Country ID"
-// @Success 200 {array} models.District
-// @Failure 500 {object} map[string]string
-// @Router /api/districts [get]
+// @Success 200 {object} utils.Response{data=[]models.District}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/districts [get]
 func GetDistrictsHandler(c *gin.Context) {
 	stateIDStr := c.Query("state_id")
 	countryIDStr := c.Query("country_id")
@@ -198,11 +252,11 @@ func GetDistrictsHandler(c *gin.Context) {
 
 	districts, err := services.GetDistrictsByStateCountry(stateID, countryID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, districts)
+	utils.OK(c, "", districts)
 }
 
 // GetAllDistrictsHandler godoc
@@ -211,16 +265,16 @@ func GetDistrictsHandler(c *gin.Context) {
 // @Tags Districts
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.District
-// @Failure 500 {object} map[string]string
-// @Router /api/districts/all [get]
+// @Success 200 {object} utils.Response{data=[]models.District}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/districts/all [get]
 func GetAllDistrictsHandler(c *gin.Context) {
 	districts, err := services.GetAllDistricts()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, districts)
+	utils.OK(c, "", districts)
 }
 
 // --------------------- Promotion Material Types ---------------------
@@ -231,16 +285,16 @@ func GetAllDistrictsHandler(c *gin.Context) {
 // @Tags PromotionMaterialTypes
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.PromotionMaterialType
-// @Failure 500 {object} map[string]string
-// @Router /api/promotion-material-types [get]
+// @Success 200 {object} utils.Response{data=[]models.PromotionMaterialType}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/promotion-material-types [get]
 func GetAllPromotionMaterialTypesHandler(c *gin.Context) {
 	list, err := services.GetAllPromotionMaterialTypesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, list)
+	utils.OK(c, "", list)
 }
 
 // GetCoordinatorDropdownHandler godoc
@@ -249,18 +303,18 @@ func GetAllPromotionMaterialTypesHandler(c *gin.Context) {
 // @Tags BranchCoordinator
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.BranchMember
-// @Failure 500 {object} map[string]string
-// @Router /api/coordinators [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchMember}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/coordinators [get]
 func GetCoordinatorDropdownHandler(c *gin.Context) {
 
 	list, err := services.GetCoordinatorDropdownService()
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(200, list)
+	utils.OK(c, "", list)
 }
 
 // GetOratorDropdownHandler godoc
@@ -269,18 +323,18 @@ func GetCoordinatorDropdownHandler(c *gin.Context) {
 // @Tags Orator
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.BranchMember
-// @Failure 500 {object} map[string]string
-// @Router /api/orators [get]
+// @Success 200 {object} utils.Response{data=[]models.BranchMember}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/orators [get]
 func GetOratorDropdownHandler(c *gin.Context) {
 
 	list, err := services.GetOratorDropdownService()
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(200, list)
+	utils.OK(c, "", list)
 }
 
 // --------------------- Languages ---------------------
@@ -291,16 +345,16 @@ func GetOratorDropdownHandler(c *gin.Context) {
 // @Tags Languages
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Language
-// @Failure 500 {object} map[string]string
-// @Router /api/languages [get]
+// @Success 200 {object} utils.Response{data=[]models.Language}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/languages [get]
 func GetAllLanguagesHandler(c *gin.Context) {
 	languages, err := services.GetAllLanguagesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, languages)
+	utils.OK(c, "", languages)
 }
 
 // --------------------- Seva Types ---------------------
@@ -311,16 +365,16 @@ func GetAllLanguagesHandler(c *gin.Context) {
 // @Tags SevaTypes
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.SevaType
-// @Failure 500 {object} map[string]string
-// @Router /api/seva-types [get]
+// @Success 200 {object} utils.Response{data=[]models.SevaType}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/seva-types [get]
 func GetAllSevaTypesHandler(c *gin.Context) {
 	sevaTypes, err := services.GetAllSevaTypesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, sevaTypes)
+	utils.OK(c, "", sevaTypes)
 }
 
 // --------------------- Event Sub Categories ---------------------
@@ -331,16 +385,16 @@ func GetAllSevaTypesHandler(c *gin.Context) {
 // @Tags EventSubCategories
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.EventSubCategory
-// @Failure 500 {object} map[string]string
-// @Router /api/event-sub-categories [get]
+// @Success 200 {object} utils.Response{data=[]models.EventSubCategory}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-sub-categories [get]
 func GetAllEventSubCategoriesHandler(c *gin.Context) {
 	subCategories, err := services.GetAllEventSubCategoriesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, subCategories)
+	utils.OK(c, "", subCategories)
 }
 
 // GetEventSubCategoriesByCategoryHandler godoc
@@ -350,30 +404,30 @@ func GetAllEventSubCategoriesHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param category_id query int true "Event Category ID"
-// @Success 200 {array} models.EventSubCategory
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/event-sub-categories/by-category [get]
+// @Success 200 {object} utils.Response{data=[]models.EventSubCategory}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-sub-categories/by-category [get]
 func GetEventSubCategoriesByCategoryHandler(c *gin.Context) {
 	categoryIDStr := c.Query("category_id")
 	if categoryIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "category_id query parameter is required"})
+		utils.BadRequest(c, "category_id query parameter is required")
 		return
 	}
 
 	categoryID, err := strconv.ParseUint(categoryIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category_id"})
+		utils.BadRequest(c, "Invalid category_id")
 		return
 	}
 
 	subCategories, err := services.GetEventSubCategoriesByCategoryService(uint(categoryID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, subCategories)
+	utils.OK(c, "", subCategories)
 }
 
 // --------------------- Roles ---------------------
@@ -384,16 +438,16 @@ func GetEventSubCategoriesByCategoryHandler(c *gin.Context) {
 // @Tags Roles
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Role
-// @Failure 500 {object} map[string]string
-// @Router /api/roles [get]
+// @Success 200 {object} utils.Response{data=[]models.Role}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/roles [get]
 func GetAllRolesHandler(c *gin.Context) {
 	roles, err := services.GetAllRolesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, roles)
+	utils.OK(c, "", roles)
 }
 
 // --------------------- Themes ---------------------
@@ -404,14 +458,14 @@ func GetAllRolesHandler(c *gin.Context) {
 // @Tags Themes
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Theme
-// @Failure 500 {object} map[string]string
-// @Router /api/themes [get]
+// @Success 200 {object} utils.Response{data=[]models.Theme}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/themes [get]
 func GetAllThemesHandler(c *gin.Context) {
 	themes, err := services.GetAllThemesService()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, themes)
+	utils.OK(c, "", themes)
 }
\ No newline at end of file
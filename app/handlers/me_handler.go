@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyPreferencesHandler godoc
+// @Summary Get my preferences
+// @Description Returns the signed-in user's preferences: digest_frequency (daily, weekly or off) of the reviewer email digest and notification_channel (email, sms or whatsapp) of critical alerts such as event review results and new passwords
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.UserPreferences}
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/me/preferences [get]
+func GetMyPreferencesHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	prefs, err := services.GetUserPreferences(userID)
+	if err != nil {
+		respondMeError(c, err)
+		return
+	}
+	utils.OK(c, "", prefs)
+}
+
+// UpdateMyPreferencesHandler godoc
+// @Summary Update my preferences
+// @Description Sets how often the signed-in reviewer receives the email digest of pending approvals, data-quality flags and overdue reports (daily, weekly or off) and where critical alerts go besides email: email (only), sms or whatsapp to the contact number of the profile. SMS and WhatsApp need a contact number and are only accepted when a provider is configured for them; notification_channel left out stays as it is.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.UserPreferences true "Preferences"
+// @Success 200 {object} utils.Response{data=services.UserPreferences}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/me/preferences [put]
+func UpdateMyPreferencesHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	var req services.UserPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	prefs, err := services.UpdateUserPreferences(userID, req)
+	if err != nil {
+		respondMeError(c, err)
+		return
+	}
+	utils.OK(c, "", prefs)
+}
+
+// GetMyApprovalDigestHandler godoc
+// @Summary Preview my approval digest
+// @Description Returns what the signed-in reviewer's email digest currently contains: events pending their approval (with data-quality flags) and reports not submitted EVENT_REPORT_DUE_DAYS after the event ended. Empty for users who do not review.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.ApprovalDigest}
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/me/approval-digest [get]
+func GetMyApprovalDigestHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	digest, err := services.GetApprovalDigest(userID)
+	if err != nil {
+		respondMeError(c, err)
+		return
+	}
+	utils.OK(c, "", digest)
+}
+
+// TokenScopeResponse is what the token itself claims the user may see ("role" and "bid"
+// claims). Permissions are checked against the user's current role and branch instead, so
+// the two differ until the token is refreshed after a role or branch change.
+type TokenScopeResponse struct {
+	RoleID   *int64 `json:"role_id,omitempty"`
+	BranchID *int64 `json:"branch_id,omitempty"`
+}
+
+// TokenIntrospectionResponse describes the access token of the request
+type TokenIntrospectionResponse struct {
+	Claims           map[string]interface{} `json:"claims"`
+	UserID           uint                   `json:"user_id"`
+	SessionID        string                 `json:"session_id,omitempty"`
+	IssuedAt         *time.Time             `json:"issued_at,omitempty"`
+	ExpiresAt        *time.Time             `json:"expires_at,omitempty"`
+	ExpiresInSeconds int64                  `json:"expires_in_seconds"`
+	Scopes           TokenScopeResponse     `json:"scopes"`
+	RoleID           uint                   `json:"role_id"`
+	RoleName         string                 `json:"role_name,omitempty"`
+	// BranchIDs are the branches whose data the user may access; nil means all branches
+	BranchIDs   []uint   `json:"branch_ids"`
+	Permissions []string `json:"permissions"`
+	// Warnings point out why requests with this token may be refused
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Permissions reported by GET /api/me/token. Besides "records:read" (every role, within the
+// user's branches) they mirror the role checks of the routes: RequireRoles,
+// middleware.CanViewPII and the event status transitions.
+const (
+	PermissionRecordsRead     = "records:read"
+	PermissionPIIRead         = "pii:read"
+	PermissionEventsReview    = "events:review"
+	PermissionGuestsUpdate    = "guests:update"
+	PermissionDonationReports = "donations:reports"
+	PermissionDonationsVoid   = "donations:void"
+	PermissionRecordsRestore  = "records:restore"
+	PermissionRecordsMerge    = "records:merge"
+	PermissionArchivesExport  = "archives:export"
+	PermissionBranchTransfer  = "branches:transfer"
+	PermissionAuditRead       = "audit:read"
+	PermissionAdmin           = "admin"
+)
+
+var rolePermissions = map[uint][]string{
+	models.RoleAdmin: {
+		PermissionRecordsRead, PermissionEventsReview, PermissionGuestsUpdate,
+		PermissionDonationReports, PermissionDonationsVoid, PermissionRecordsRestore, PermissionRecordsMerge,
+		PermissionArchivesExport, PermissionBranchTransfer, PermissionAuditRead, PermissionAdmin,
+	},
+	models.RoleManager: {
+		PermissionRecordsRead, PermissionEventsReview, PermissionGuestsUpdate,
+		PermissionDonationReports, PermissionDonationsVoid,
+	},
+	models.RoleUser: {PermissionRecordsRead},
+}
+
+// effectivePermissions lists the permissions of a role, sorted
+func effectivePermissions(roleID uint) []string {
+	permissions := append([]string{}, rolePermissions[roleID]...)
+	if middleware.CanViewPII(roleID) {
+		permissions = append(permissions, PermissionPIIRead)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// GetMyTokenHandler godoc
+// @Summary Introspect my access token
+// @Description Decodes the access token of the request: its claims, session, expiry and the role/branch scope it carries, with the permissions and branches the signed-in user effectively has. Warnings explain stale claims (the role or branch changed since the token was issued) and tokens about to expire. Meant for debugging authorization issues.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=TokenIntrospectionResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/me/token [get]
+func GetMyTokenHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	claims, ok := middleware.CurrentTokenClaims(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		respondMeError(c, err)
+		return
+	}
+	branchScope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	response := TokenIntrospectionResponse{
+		Claims:      claims,
+		UserID:      userID,
+		RoleID:      user.RoleID,
+		RoleName:    user.Role.Name,
+		Permissions: effectivePermissions(user.RoleID),
+	}
+	if branchScope != nil {
+		response.BranchIDs = branchScope.BranchIDs
+	}
+	if sid, err := auth.ParseSessionIDFromToken(claims); err == nil {
+		response.SessionID = sid
+	} else {
+		response.Warnings = append(response.Warnings, "legacy token without a session: it cannot be revoked by logout, sign in again")
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		response.IssuedAt = &iat.Time
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		response.ExpiresAt = &exp.Time
+		response.ExpiresInSeconds = int64(time.Until(exp.Time).Seconds())
+		if response.ExpiresInSeconds < 60 {
+			response.Warnings = append(response.Warnings, "token expires within a minute, refresh it")
+		}
+	}
+
+	tokenScope := auth.ParseScopeFromToken(claims)
+	if _, ok := claims["role"]; ok {
+		response.Scopes.RoleID = &tokenScope.RoleID
+		if uint(tokenScope.RoleID) != user.RoleID {
+			response.Warnings = append(response.Warnings, fmt.Sprintf("role claim (%d) differs from the current role (%d): permissions follow the current role, refresh the token to update the claim", tokenScope.RoleID, user.RoleID))
+		}
+	}
+	response.Scopes.BranchID = tokenScope.BranchID
+	if user.RoleID != models.RoleAdmin && !sameBranch(tokenScope.BranchID, user.BranchID) {
+		response.Warnings = append(response.Warnings, "branch claim differs from the current branch: access follows the current branch, refresh the token to update the claim")
+	}
+	if user.RoleID != models.RoleAdmin && user.BranchID == nil {
+		response.Warnings = append(response.Warnings, "no branch is assigned to the user, so no branch data is visible")
+	}
+
+	utils.OK(c, "", response)
+}
+
+// sameBranch compares a branch claim with the user's branch
+func sameBranch(claim *int64, branchID *uint) bool {
+	if claim == nil || branchID == nil {
+		return claim == nil && branchID == nil
+	}
+	return uint(*claim) == *branchID
+}
+
+func respondMeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPreferences):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.NotFound(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
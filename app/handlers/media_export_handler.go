@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StartMediaExportHandler godoc
+// @Summary Export all media metadata
+// @Description Queues a background job that writes every event and branch media record (including soft-deleted branch media) with the keys, ETags and sizes of its objects as NDJSON chunks under exports/media/<export id>/ in the bucket, plus an index.json with the SHA-256 of each chunk. The job result holds index_key and a download link to the index. Admin only.
+// @Tags Media Export
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.MediaExportOptions false "Kinds (event_media, branch_media) and chunk_size"
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/media-exports [post]
+func StartMediaExportHandler(c *gin.Context) {
+	var opts services.MediaExportOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartMediaExport(c.Request.Context(), opts, createdBy)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMediaExport) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.Accepted(c, "", job)
+}
+
+// StartMediaImportHandler godoc
+// @Summary Import exported media metadata
+// @Description Queues a background job that re-registers the records of an export whose folder and objects were copied into this deployment's bucket. Each chunk is checked against the index checksum; event_id_map and branch_id_map translate source IDs to IDs in this deployment. Records whose s3_key is already registered or whose event/branch does not exist are skipped and counted in the job result; with verify_objects, so are records whose objects are missing or differ. dry_run counts without writing. Admin only.
+// @Tags Media Export
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.MediaImportOptions true "index_key, ID maps, verify_objects, dry_run"
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/media-exports/import [post]
+func StartMediaImportHandler(c *gin.Context) {
+	var opts services.MediaImportOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartMediaImport(c.Request.Context(), opts, createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMediaImport):
+			utils.BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrMediaImportRunning):
+			utils.Conflict(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.Accepted(c, "", job)
+}
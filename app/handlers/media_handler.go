@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -19,64 +22,139 @@ import (
 // @Accept json
 // @Produce json
 // @Param data body models.EventMedia true "Event Media Details"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/event-media [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-media [post]
 func CreateEventMediaHandler(c *gin.Context) {
 	var media models.EventMedia
 	if err := c.ShouldBindJSON(&media); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateEventMediaInput(media.EventID, media.MediaCoverageTypeID, media.CompanyName, media.FirstName, media.LastName); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if !checkRecordAccess(c, services.ScopeEvent, media.EventID) {
 		return
 	}
 
 	if err := services.CreateEventMedia(&media); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create record"})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "failed to create record")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Event Media created successfully",
-		"data":    media,
-	})
+	utils.Created(c, "Event Media created successfully", media)
 }
 
 // GetAllEventMediaHandler retrieves all EventMedia records
 // @Summary Get all Event Media
-// @Description Retrieve all EventMedia records
+// @Description Retrieve all EventMedia records. Without paging, sort with sort=category,-created_on (fields: id, company_name, file_type, category, sort_order, created_on; newest first by default) and filter by any of them or event_id, media_coverage_type_id, scan_status, is_public (see GET /branches for the operators). Pages are always newest first.
 // @Tags EventMedia
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
-// @Router /api/event-media [get]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Param limit query int false "Page size (default 20, max 100); pages the list by newest first"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma-separated sort fields, - for descending (not with limit or cursor)"
+// @Router /api/v1/event-media [get]
 func GetAllEventMediaHandler(c *gin.Context) {
-	medias, err := services.GetAllEventMedia()
+	limit, cursor, paged, ok := mediaPageFromQuery(c)
+	if !ok {
+		return
+	}
+	query, ok := mediaListQuery(c, services.EventMediaListSchema, paged)
+	if !ok {
+		return
+	}
+	if paged {
+		scope, ok := currentBranchScope(c)
+		if !ok {
+			return
+		}
+		page, err := services.GetAllEventMediaPaginated(limit, cursor, scope)
+		if err != nil {
+			utils.InternalServerError(c, "failed to fetch records")
+			return
+		}
+		mediasWithPresignedURLs, err := eventGalleryURLs(c, page.Data)
+		if err != nil {
+			utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+			return
+		}
+		utils.OK(c, "Event Media fetched successfully", gin.H{
+			"data":        mediasWithPresignedURLs,
+			"next_cursor": page.NextCursor,
+			"has_more":    page.HasMore,
+		})
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	medias, err := services.GetAllEventMedia(query, scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch records"})
+		utils.InternalServerError(c, "failed to fetch records")
 		return
 	}
 	
 	// Convert to presigned URLs - fail fast on errors
-	mediasWithPresignedURLs, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), medias)
+	mediasWithPresignedURLs, err := eventGalleryURLs(c, medias)
 	if err != nil {
 		// Fail fast - return HTTP 500 with structured error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to generate presigned URLs",
-			"details": err.Error(),
-		})
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Event Media fetched successfully",
-		"data":    mediasWithPresignedURLs,
-	})
+	utils.OK(c, "Event Media fetched successfully", mediasWithPresignedURLs)
+}
+
+// SearchEventMediaHandler godoc
+// @Summary Search Event Media
+// @Description Search event media by company name, filename, or text extracted from clippings (OCR)
+// @Tags EventMedia
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string true "Search term"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Router /api/v1/event-media/search [get]
+func SearchEventMediaHandler(c *gin.Context) {
+	searchTerm := c.Query("search")
+	if searchTerm == "" {
+		utils.BadRequest(c, "search parameter is required")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	medias, err := services.SearchEventMedia(searchTerm, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	mediasWithPresignedURLs, err := eventGalleryURLs(c, medias)
+	if err != nil {
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
+		return
+	}
+
+	utils.OK(c, "Event Media fetched successfully", mediasWithPresignedURLs)
 }
 
 // GetEventMediaByEventIDHandler godoc
@@ -87,34 +165,30 @@ func GetAllEventMediaHandler(c *gin.Context) {
 // @Produce json
 // @Param event_id path int true "Event ID"
 // @Param limit query int false "Number of items per page (default: 20, max: 100)"
-// @Param cursor_created_at query string false "Cursor: created_at timestamp (RFC3339)"
-// @Param cursor_id query int false "Cursor: media ID"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Router /api/event-media/event/{event_id} [get]
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param cursor_created_at query string false "Deprecated cursor: created_on timestamp (RFC3339), with cursor_id"
+// @Param cursor_id query int false "Deprecated cursor: media ID, with cursor_created_at"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Param include_original query bool false "Also presign full-resolution originals (default: thumbnails only when available)"
+// @Router /api/v1/event-media/event/{event_id} [get]
 func GetEventMediaByEventIDHandler(c *gin.Context) {
 	eventIDParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(eventIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		utils.BadRequest(c, "invalid event ID")
 		return
 	}
 
-	// Parse pagination parameters
-	limitParam := c.DefaultQuery("limit", "20")
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 {
-		limit = 20
+	limit, cursor, _, ok := mediaPageFromQuery(c)
+	if !ok {
+		return
 	}
-
-	// Parse cursor if provided
-	var cursor *services.PaginationCursor
-	cursorCreatedAtStr := c.Query("cursor_created_at")
-	cursorIDStr := c.Query("cursor_id")
-	if cursorCreatedAtStr != "" && cursorIDStr != "" {
-		cursorCreatedAt, err := time.Parse(time.RFC3339, cursorCreatedAtStr)
+	// Older clients send the cursor as two parameters
+	if cursor == nil && c.Query("cursor_created_at") != "" && c.Query("cursor_id") != "" {
+		cursorCreatedAt, err := time.Parse(time.RFC3339Nano, c.Query("cursor_created_at"))
 		if err == nil {
-			cursorID, err := strconv.ParseUint(cursorIDStr, 10, 64)
+			cursorID, err := strconv.ParseUint(c.Query("cursor_id"), 10, 64)
 			if err == nil {
 				cursor = &services.PaginationCursor{
 					CreatedAt: cursorCreatedAt,
@@ -133,38 +207,28 @@ func GetEventMediaByEventIDHandler(c *gin.Context) {
 			mediaList = []models.EventMedia{}
 		}
 		// Convert to presigned URLs - fail fast on errors
-		mediaListWithPresignedURLs, fallbackErr := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), mediaList)
+		mediaListWithPresignedURLs, fallbackErr := eventGalleryURLs(c, mediaList)
 		if fallbackErr != nil {
 			// Fail fast - return HTTP 500 with structured error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "failed to generate presigned URLs",
-				"details": fallbackErr.Error(),
-			})
+			utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", fallbackErr.Error())
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Event Media fetched successfully",
-			"data":    mediaListWithPresignedURLs,
-		})
+		utils.OK(c, "Event Media fetched successfully", mediaListWithPresignedURLs)
 		return
 	}
 
 	// Convert to presigned URLs - fail fast on errors
-	mediaListWithPresignedURLs, err := services.ConvertEventMediaToPresignedURLs(c.Request.Context(), paginatedResult.Data)
+	mediaListWithPresignedURLs, err := eventGalleryURLs(c, paginatedResult.Data)
 	if err != nil {
 		// Fail fast - return HTTP 500 with structured error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "failed to generate presigned URLs",
-			"details": err.Error(),
-		})
+		utils.ErrorCodeResponse(c, http.StatusInternalServerError, utils.CodeInternal, "failed to generate presigned URLs", err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "Event Media fetched successfully",
-		"data":       mediaListWithPresignedURLs,
+	utils.OK(c, "Event Media fetched successfully", gin.H{
+		"data":        mediaListWithPresignedURLs,
 		"next_cursor": paginatedResult.NextCursor,
-		"has_more":   paginatedResult.HasMore,
+		"has_more":    paginatedResult.HasMore,
 	})
 }
 
@@ -177,47 +241,47 @@ func GetEventMediaByEventIDHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Event Media ID"
 // @Param data body models.EventMedia true "Updated details"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/event-media/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/event-media/{id} [put]
 func UpdateEventMediaHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
 	var media models.EventMedia
 	if err := c.ShouldBindJSON(&media); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Convert to map for validation
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateEventMediaUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	media.ID = uint(id)
 
 	if err := services.UpdateEventMedia(&media); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Event Media updated successfully",
-		"data":    media,
-	})
+	utils.OK(c, "Event Media updated successfully", media)
 }
 
 // DeleteEventMediaHandler deletes an EventMedia record by ID
@@ -227,22 +291,79 @@ func UpdateEventMediaHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Event Media ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/event-media/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Router /api/v1/event-media/{id} [delete]
 func DeleteEventMediaHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		utils.BadRequest(c, "invalid ID")
 		return
 	}
 
-	if err := services.DeleteEventMedia(uint(id)); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	if err := services.DeleteEventMedia(uint(id), auditActor(c)); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrLegalHold) {
+			utils.ErrorResponse(c, http.StatusLocked, err.Error())
+			return
+		}
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event Media deleted successfully"})
+	utils.OK(c, "Event Media deleted successfully", nil)
+}
+
+// eventGalleryURLs presigns thumbnails for gallery listings; ?include_original=true
+// also presigns the full-resolution originals
+func eventGalleryURLs(c *gin.Context, mediaList []models.EventMedia) ([]models.EventMedia, error) {
+	if c.Query("include_original") == "true" {
+		return services.ConvertEventMediaToPresignedURLs(c.Request.Context(), mediaList)
+	}
+	return services.ConvertEventMediaToGalleryURLs(c.Request.Context(), mediaList)
+}
+
+// mediaPageFromQuery reads ?limit= and ?cursor= of gallery listings. paged reports whether the
+// client asked for a page at all; lists that predate pagination return everything otherwise.
+// mediaListQuery parses the sort and filters of an unpaged media list. Cursor pages are always
+// newest first, so a sort is rejected along with limit or cursor.
+func mediaListQuery(c *gin.Context, schema *listquery.Schema, paged bool) (*listquery.Query, bool) {
+	if paged {
+		if c.Query("sort") != "" {
+			utils.BadRequest(c, "sort cannot be combined with limit or cursor: pages are newest first")
+			return nil, false
+		}
+		return nil, true
+	}
+	query, err := schema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return nil, false
+	}
+	return query, true
+}
+
+func mediaPageFromQuery(c *gin.Context) (limit int, cursor *services.PaginationCursor, paged bool, ok bool) {
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.BadRequest(c, "invalid limit")
+			return 0, nil, false, false
+		}
+		paged = true
+	}
+	if token := c.Query("cursor"); token != "" {
+		var err error
+		if cursor, err = services.ParsePaginationCursor(token); err != nil {
+			utils.BadRequest(c, err.Error())
+			return 0, nil, false, false
+		}
+		paged = true
+	}
+	return limit, cursor, paged, true
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMediaManifestsHandler godoc
+// @Summary List media manifests
+// @Description Returns the signed manifests of uploaded documents, newest first. Admin only.
+// @Tags MediaManifests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "Maximum number of manifests (default 50, max 500)"
+// @Success 200 {object} utils.Response{data=[]models.MediaManifest}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/media-manifests [get]
+func GetMediaManifestsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	manifests, err := services.GetMediaManifests(limit)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", manifests)
+}
+
+// GenerateMediaManifestHandler godoc
+// @Summary Generate a media manifest now
+// @Description Builds, signs and stores a manifest of all uploaded documents (event media, branch media, donation receipts) without waiting for the schedule. Admin only.
+// @Tags MediaManifests
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 201 {object} utils.Response{data=models.MediaManifest}
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/admin/media-manifests [post]
+func GenerateMediaManifestHandler(c *gin.Context) {
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+
+	ctx, cancel := manifestContext(c)
+	defer cancel()
+	manifest, err := services.GenerateMediaManifest(ctx, services.ManifestSourceManual, createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrManifestSigningKeyMissing):
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		case errors.Is(err, services.ErrManifestBusy):
+			utils.Conflict(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.Created(c, "", manifest)
+}
+
+// VerifyMediaManifestHandler godoc
+// @Summary Verify a media manifest against the bucket
+// @Description Checks the manifest signature, its digest and hash chain, then compares every entry with the current bucket contents. "valid" is false if anything was missing, modified or tampered with. Admin only.
+// @Tags MediaManifests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Manifest ID"
+// @Success 200 {object} utils.Response{data=services.ManifestVerification}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/admin/media-manifests/{id}/verify [get]
+func VerifyMediaManifestHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "invalid manifest id")
+		return
+	}
+
+	ctx, cancel := manifestContext(c)
+	defer cancel()
+	result, err := services.VerifyMediaManifest(ctx, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrManifestNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrManifestSigningKeyMissing):
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.OK(c, "", result)
+}
+
+// manifestContext detaches manifest work from the 30 second request timeout: listing a large
+// bucket takes longer. The request ID is kept for logging.
+func manifestContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(c.Request.Context()), 30*time.Minute)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMediaScanSummaryHandler godoc
+// @Summary Media scan status
+// @Description Counts event and branch media per antivirus/moderation scan status ("unscanned" is the remaining backlog) and returns the latest backfill job. Admin only.
+// @Tags Media Scan
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.MediaScanSummary}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/media-scan [get]
+func GetMediaScanSummaryHandler(c *gin.Context) {
+	summary, err := services.GetMediaScanSummary(c.Request.Context())
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", summary)
+}
+
+// StartMediaScanBackfillHandler godoc
+// @Summary Scan the legacy media backlog
+// @Description Queues a background job that scans every unscanned event and branch media file with the configured scanner (MEDIA_SCANNER), in batches with a pause after each file, and records scan_status on each record. Poll GET /api/jobs/{id} for progress. Admin only.
+// @Tags Media Scan
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.MediaScanBackfillOptions false "Target (event_media, branch_media, all), batch_size, throttle_ms, rescan_errors"
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/admin/media-scan/backfill [post]
+func StartMediaScanBackfillHandler(c *gin.Context) {
+	var opts services.MediaScanBackfillOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartMediaScanBackfill(c.Request.Context(), opts, createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMediaScanTarget):
+			utils.BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrMediaScanRunning):
+			utils.Conflict(c, err.Error())
+		case errors.Is(err, services.ErrMediaScannerNotConfigured):
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.Accepted(c, "", job)
+}
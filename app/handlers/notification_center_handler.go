@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyNotificationsHandler godoc
+// @Summary List my notifications
+// @Description Returns the signed-in user's in-app notifications, newest first: events awaiting their review, results of events they submitted, their password expiring soon and finished imports, exports and reports they started. The response also carries the unread count.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/me/notifications [get]
+func GetMyNotificationsHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	filter := services.NotificationFilter{UserID: userID}
+	filter.Unread, _ = strconv.ParseBool(c.DefaultQuery("unread", "false"))
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	notifications, total, err := services.GetNotifications(c.Request.Context(), filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	unread, err := services.CountUnreadNotifications(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":   notifications,
+		"total":  total,
+		"unread": unread,
+	})
+}
+
+// GetMyUnreadNotificationCountHandler godoc
+// @Summary Count my unread notifications
+// @Description Returns how many of the signed-in user's notifications are unread, for the badge polled by the frontend
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/me/notifications/unread-count [get]
+func GetMyUnreadNotificationCountHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	unread, err := services.CountUnreadNotifications(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{"unread": unread})
+}
+
+// MarkMyNotificationReadHandler godoc
+// @Summary Mark a notification read
+// @Description Marks one of the signed-in user's notifications read. Marking it again keeps the time it was first read.
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} utils.Response{data=models.Notification}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/me/notifications/{id}/read [post]
+func MarkMyNotificationReadHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid notification ID")
+		return
+	}
+	notification, err := services.MarkNotificationRead(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", notification)
+}
+
+// MarkAllMyNotificationsReadHandler godoc
+// @Summary Mark all my notifications read
+// @Description Marks every unread notification of the signed-in user read and returns how many there were
+// @Tags Me
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/me/notifications/read-all [post]
+func MarkAllMyNotificationsReadHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	marked, err := services.MarkAllNotificationsRead(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", gin.H{"marked": marked})
+}
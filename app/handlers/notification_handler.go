@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationLogsHandler godoc
+// @Summary List sent notifications
+// @Description Returns the outgoing email, SMS and WhatsApp log (channel, recipient, template, subject, provider, delivery status), newest first. Message bodies are never stored. Admin only.
+// @Tags Notifications
+// @Security ApiKeyAuth
+// @Produce json
+// @Param channel query string false "Channel (email, sms, whatsapp)"
+// @Param recipient query string false "Recipient email address or phone number (+919876543210)"
+// @Param template query string false "Template (welcome, temporary_password, password_reset, verify_email, event_approved, event_rejected)"
+// @Param status query string false "Delivery status (sent, failed, logged)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/notifications [get]
+func GetNotificationLogsHandler(c *gin.Context) {
+	filter := services.NotificationLogFilter{
+		Channel:   c.Query("channel"),
+		Recipient: c.Query("recipient"),
+		Template:  c.Query("template"),
+		Status:    c.Query("status"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	logs, total, err := services.GetNotificationLogs(filter)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", gin.H{
+		"data":  logs,
+		"total": total,
+	})
+}
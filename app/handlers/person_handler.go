@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// MergePersonsRequest lists the duplicate persons to merge into the target
+type MergePersonsRequest struct {
+	SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+}
+
+// SearchPersonsHandler lists persons
+// @Summary Search persons
+// @Description Finds persons (the individuals behind users, members, volunteers and donors) by name across scripts/spellings, email or contact number. Merged persons are excluded. Users with a branch scope only find persons with a user account, membership, volunteer record or donation in their branches.
+// @Tags Persons
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string false "Name, email or contact number"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response{data=[]models.Person}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/persons [get]
+func SearchPersonsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	persons, err := services.SearchPersons(c.Query("search"), limit, offset, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", persons)
+}
+
+// GetPersonProfileHandler returns a person with all of their roles
+// @Summary Get person profile
+// @Description Returns the person with their user accounts, branch memberships, volunteer records and donations. The ID of a merged person resolves to the person it was merged into. Users with a branch scope only see the records of their branches, and get 403 for a person with none.
+// @Tags Persons
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Person ID"
+// @Success 200 {object} utils.Response{data=services.PersonProfile}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/persons/{id} [get]
+func GetPersonProfileHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid person ID")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+
+	profile, err := services.GetPersonProfile(uint(id), scope)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPersonNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrOutOfBranchScope):
+			utils.Forbidden(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "", profile)
+}
+
+// GetPersonDuplicatesHandler lists candidate duplicate persons
+// @Summary Find duplicate persons
+// @Description Groups unmerged persons whose names match across scripts/spellings ("Vikas", "Vikaas", "विकास"). Admin only.
+// @Tags Persons
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "Maximum number of groups (default 50, max 200)"
+// @Success 200 {object} utils.Response{data=[]services.PersonDuplicateGroup}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/persons/duplicates [get]
+func GetPersonDuplicatesHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	groups, err := services.FindPersonDuplicates(limit)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", groups)
+}
+
+// MergePersonsHandler merges duplicate persons into one
+// @Summary Merge duplicate persons
+// @Description Re-points every user, member, volunteer and donation of the source persons to the target and marks the sources as merged. Details missing on the target are copied from the sources. The merge is audit logged. Admin only.
+// @Tags Persons
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Target person ID"
+// @Param payload body MergePersonsRequest true "Persons to merge into the target"
+// @Success 200 {object} utils.Response{data=services.PersonProfile}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/persons/{id}/merge [post]
+func MergePersonsHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid person ID")
+		return
+	}
+
+	var req MergePersonsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	profile, err := services.MergePersons(uint(id), req.SourceIDs, auditActor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPersonNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidPersonMerge):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "", profile)
+}
@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"net/http"
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -18,31 +18,35 @@ import (
 // @Accept json
 // @Produce json
 // @Param data body models.PromotionMaterialDetails true "Promotion Material Details"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/promotion-material-details [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/promotion-material-details [post]
 func CreatePromotionMaterialDetailsHandler(c *gin.Context) {
 	var detail models.PromotionMaterialDetails
 	if err := c.ShouldBindJSON(&detail); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidatePromotionMaterialDetailsInput(detail.EventID, detail.PromotionMaterialID, detail.Quantity); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if !checkRecordAccess(c, services.ScopeEvent, detail.EventID) {
 		return
 	}
 
 	if err := services.CreatePromotionMaterialDetails(&detail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create record"})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, "failed to create record")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Promotion Material Details created successfully",
-		"data":    detail,
-	})
+	utils.Created(c, "Promotion Material Details created successfully", detail)
 }
 
 // GetAllPromotionMaterialDetailsHandler godoc
@@ -51,20 +55,21 @@ func CreatePromotionMaterialDetailsHandler(c *gin.Context) {
 // @Tags PromotionMaterialDetails
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]string
-// @Router /api/promotion-material-details [get]
+// @Success 200 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/promotion-material-details [get]
 func GetAllPromotionMaterialDetailsHandler(c *gin.Context) {
-	details, err := services.GetAllPromotionMaterialDetails()
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	details, err := services.GetAllPromotionMaterialDetails(scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch records"})
+		utils.InternalServerError(c, "failed to fetch records")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Promotion Material Details fetched successfully",
-		"data":    details,
-	})
+	utils.OK(c, "Promotion Material Details fetched successfully", details)
 }
 
 // GetPromotionMaterialDetailsByEventIDHandler godoc
@@ -74,28 +79,25 @@ func GetAllPromotionMaterialDetailsHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/promotion-material-details/event/{event_id} [get]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/promotion-material-details/event/{event_id} [get]
 func GetPromotionMaterialDetailsByEventIDHandler(c *gin.Context) {
 	eventIDParam := c.Param("event_id")
 	eventID, err := strconv.ParseUint(eventIDParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		utils.BadRequest(c, "invalid event ID")
 		return
 	}
 
 	details, err := services.GetPromotionMaterialDetailsByEventID(uint(eventID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Promotion Material Details fetched successfully",
-		"data":    details,
-	})
+	utils.OK(c, "Promotion Material Details fetched successfully", details)
 }
 
 // UpdatePromotionMaterialDetailsHandler godoc
@@ -107,43 +109,43 @@ func GetPromotionMaterialDetailsByEventIDHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Promotion Material Details ID"
 // @Param data body models.PromotionMaterialDetails true "Updated details"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/promotion-material-details/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/promotion-material-details/{id} [put]
 func UpdatePromotionMaterialDetailsHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, _ := strconv.ParseUint(idParam, 10, 64)
 
 	var detail models.PromotionMaterialDetails
 	if err := c.ShouldBindJSON(&detail); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Convert to map for validation
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidatePromotionMaterialDetailsUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	detail.ID = uint(id)
 
 	if err := services.UpdatePromotionMaterialDetails(&detail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Promotion Material Details updated successfully",
-		"data":    detail,
-	})
+	utils.OK(c, "Promotion Material Details updated successfully", detail)
 }
 
 // DeletePromotionMaterialDetailsHandler godoc
@@ -153,17 +155,20 @@ func UpdatePromotionMaterialDetailsHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Promotion Material Details ID"
-// @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/promotion-material-details/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/promotion-material-details/{id} [delete]
 func DeletePromotionMaterialDetailsHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, _ := strconv.ParseUint(idParam, 10, 64)
 
 	if err := services.DeletePromotionMaterialDetails(uint(id)); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Promotion Material Details deleted successfully"})
+	utils.OK(c, "Promotion Material Details deleted successfully", nil)
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ServePublicAssetHandler godoc
+// @Summary Download a public asset
+// @Description Serves public event media (website posters) by the SHA-256 of their content, as linked by public_url, public_thumbnail_url and public_medium_url. The content of a URL never changes, so responses are cacheable forever (Cache-Control: immutable) and suitable for a CDN. No authentication.
+// @Tags Public
+// @Produce octet-stream
+// @Param file path string true "SHA-256 of the content, optionally with a file extension"
+// @Success 200 {file} binary
+// @Success 304 "Not modified"
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /public/assets/{file} [get]
+func ServePublicAssetHandler(c *gin.Context) {
+	asset, err := services.GetPublicAsset(c.Param("file"))
+	if err != nil {
+		if errors.Is(err, services.ErrPublicAssetNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	etag := `"` + asset.SHA256 + `"`
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match == "*" || strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	storage, err := services.GetStorage()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	reader, err := storage.Get(c.Request.Context(), asset.S3Key)
+	if err != nil {
+		if errors.Is(err, services.ErrObjectNotFound) {
+			utils.NotFound(c, "file not found")
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, reader, nil)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetBranchMapHandler godoc
+// @Summary Public branch map
+// @Description Returns active branches inside the bounding box as map pins, clustered on a grid sized for the zoom level. Single-branch pins carry the public_id for the detail endpoint; clusters carry their bounds. Responses are cached. No authentication; rate limited per IP.
+// @Tags Public
+// @Produce json
+// @Param bbox query string true "west,south,east,north in degrees"
+// @Param zoom query int false "Map zoom level 0-16 (default 5); 16 returns every branch as its own pin"
+// @Success 200 {object} utils.Response{data=services.BranchMap}
+// @Failure 400 {object} utils.Response
+// @Failure 429 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /public/api/branches/map [get]
+func GetBranchMapHandler(c *gin.Context) {
+	bbox, err := services.ParseBoundingBox(c.Query("bbox"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	zoom, err := strconv.Atoi(c.DefaultQuery("zoom", "5"))
+	if err != nil {
+		utils.BadRequest(c, "zoom must be an integer")
+		return
+	}
+
+	result, err := services.GetBranchMap(bbox, zoom)
+	if err != nil {
+		utils.InternalServerError(c, "failed to load branch map")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	utils.OK(c, "", result)
+}
+
+// GetPublicBranchHandler godoc
+// @Summary Public branch details
+// @Description Returns the public directory details of an active branch (address, contact, opening hours, location and cover image). No authentication; rate limited per IP.
+// @Tags Public
+// @Produce json
+// @Param public_id path string true "Branch public ID (UUID)"
+// @Success 200 {object} utils.Response{data=services.PublicBranch}
+// @Failure 404 {object} utils.Response
+// @Failure 429 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /public/api/branches/{public_id} [get]
+func GetPublicBranchHandler(c *gin.Context) {
+	branch, err := services.GetPublicBranch(c.Request.Context(), c.Param("public_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrBranchNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, "failed to load branch")
+		return
+	}
+
+	// Short: the cover image URL is presigned
+	c.Header("Cache-Control", "public, max-age=60")
+	utils.OK(c, "", branch)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidVersion = errors.New("version must be a positive integer")
+
+// ifMatchVersion reads the version precondition of an update from the If-Match header, the ETag
+// of the record's GET ("3"; W/"3" is accepted too). A missing header or "*" means none.
+func ifMatchVersion(c *gin.Context) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("invalid If-Match header: %w", errInvalidVersion)
+	}
+	return &version, nil
+}
+
+// expectedVersion reads the version precondition of an update from the If-Match header or,
+// without one, from a "version" field of the payload. The field is removed from the payload
+// either way, since the service bumps the version itself.
+func expectedVersion(c *gin.Context, payload map[string]interface{}) (*int, error) {
+	raw, hasField := payload["version"]
+	delete(payload, "version")
+
+	version, err := ifMatchVersion(c)
+	if err != nil || version != nil || !hasField || raw == nil {
+		return version, err
+	}
+	var v int
+	switch x := raw.(type) {
+	case float64:
+		v = int(x)
+		if float64(v) != x {
+			return nil, errInvalidVersion
+		}
+	case string:
+		if v, err = strconv.Atoi(x); err != nil {
+			return nil, errInvalidVersion
+		}
+	default:
+		return nil, errInvalidVersion
+	}
+	if v < 1 {
+		return nil, errInvalidVersion
+	}
+	return &v, nil
+}
+
+// setVersionETag exposes a record's version as its ETag, for the If-Match of the next update
+func setVersionETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// respondVersionConflict writes a 409 carrying the latest record for updates made from a stale
+// version and reports whether it did
+func respondVersionConflict(c *gin.Context, err error) bool {
+	var conflict *services.VersionConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	setVersionETag(c, conflict.Version)
+	utils.ErrorCodeResponse(c, http.StatusConflict, utils.CodeConflict, err.Error(), conflict.Current)
+	return true
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SetBranchSandboxRequest is the body of PUT /api/admin/sandbox/branches/:id
+type SetBranchSandboxRequest struct {
+	IsSandbox *bool `json:"is_sandbox" binding:"required"`
+}
+
+// GetSandboxHandler godoc
+// @Summary List sandbox branches
+// @Description Lists the branches in sandbox mode (training branches whose data is excluded from stats, exports and the public site) with their event and branch media counts. Admin only.
+// @Tags Sandbox
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.SandboxSummary}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/sandbox [get]
+func GetSandboxHandler(c *gin.Context) {
+	summary, err := services.GetSandboxSummary()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", summary)
+}
+
+// SetBranchSandboxHandler godoc
+// @Summary Put a branch into or out of sandbox mode
+// @Description Sets is_sandbox on the branch and all of its child branches; child branches created later inherit it. Taking a branch out of sandbox mode turns its practice data into real data, so wipe the sandbox first. Returns the IDs of the branches that changed. Admin only.
+// @Tags Sandbox
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param request body SetBranchSandboxRequest true "is_sandbox"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/sandbox/branches/{id} [put]
+func SetBranchSandboxHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+	var req SetBranchSandboxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	changed, err := services.SetBranchSandbox(uint(branchID), *req.IsSandbox, auditActor(c))
+	if err != nil {
+		respondSandboxError(c, err)
+		return
+	}
+	utils.OK(c, "", gin.H{"is_sandbox": *req.IsSandbox, "branch_ids": changed})
+}
+
+// WipeSandboxHandler godoc
+// @Summary Wipe the sandbox
+// @Description Permanently deletes all events (with special guests, volunteers, donations, media and promotion materials) and branch media of the sandbox branches, and with include_branches the sandbox branches themselves. Stored files are removed by a storage cleanup job. dry_run returns the counts without deleting. Admin only.
+// @Tags Sandbox
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body services.SandboxWipeOptions false "dry_run, include_branches"
+// @Success 200 {object} utils.Response{data=services.SandboxWipeResult}
+// @Failure 400 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/sandbox/wipe [post]
+func WipeSandboxHandler(c *gin.Context) {
+	var opts services.SandboxWipeOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	result, err := services.WipeSandbox(c.Request.Context(), opts, auditActor(c))
+	if err != nil {
+		respondSandboxError(c, err)
+		return
+	}
+	utils.OK(c, "", result)
+}
+
+func respondSandboxError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBranchNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidSandbox):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrLegalHold):
+		utils.ErrorResponse(c, http.StatusLocked, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
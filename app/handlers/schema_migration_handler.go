@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSchemaMigrationsHandler godoc
+// @Summary List expand/contract migrations
+// @Description Returns every registered expand/contract migration with its phase flags, schema state, rows still to backfill and backfill progress. Admin only.
+// @Tags Migrations
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]services.MigrationStatus}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/migrations [get]
+func GetSchemaMigrationsHandler(c *gin.Context) {
+	statuses, err := services.ListExpandContractMigrations()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", statuses)
+}
+
+// GetSchemaMigrationHandler godoc
+// @Summary Get an expand/contract migration
+// @Tags Migrations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name path string true "Migration name"
+// @Success 200 {object} utils.Response{data=services.MigrationStatus}
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/migrations/{name} [get]
+func GetSchemaMigrationHandler(c *gin.Context) {
+	m, err := services.GetExpandContractMigration(c.Param("name"))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+	status, err := m.Status()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", status)
+}
+
+// RunSchemaMigrationStepHandler godoc
+// @Summary Advance an expand/contract migration
+// @Description Runs one step: expand (add the new column), dual-write (install the sync trigger), backfill (fill existing rows in the background, returns 202), cutover (read the new column), rollback (read the old column again) or contract (drop the trigger and the old column; irreversible). Steps must run in order. Admin only.
+// @Tags Migrations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name path string true "Migration name"
+// @Param step path string true "expand, dual-write, backfill, cutover, rollback or contract"
+// @Success 200 {object} utils.Response{data=services.MigrationStatus}
+// @Success 202 {object} utils.Response{data=services.MigrationStatus} "Backfill started"
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/migrations/{name}/{step} [post]
+func RunSchemaMigrationStepHandler(c *gin.Context) {
+	m, err := services.GetExpandContractMigration(c.Param("name"))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	actor := auditActor(c)
+	status := http.StatusOK
+	switch c.Param("step") {
+	case "expand":
+		err = m.Expand(actor)
+	case "dual-write":
+		err = m.EnableDualWrite(actor)
+	case "backfill":
+		err = m.StartBackfill(actor)
+		status = http.StatusAccepted
+	case "cutover":
+		err = m.Cutover(actor)
+	case "rollback":
+		err = m.Rollback(actor)
+	case "contract":
+		err = m.Contract(actor)
+	default:
+		utils.BadRequest(c, "unknown step, use expand, dual-write, backfill, cutover, rollback or contract")
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, services.ErrMigrationPhaseOrder) || errors.Is(err, services.ErrMigrationBackfillBusy) {
+			utils.Conflict(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	result, err := m.Status()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.SuccessResponse(c, status, "", result)
+}
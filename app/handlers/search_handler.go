@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler godoc
+// @Summary Search events, branches, special guests and volunteers
+// @Description Prefix full-text search over event themes, orators and cities, branch and coordinator names, special guests (name, organization, designation) and volunteers. Every word must match. Results are grouped by type, best matches first.
+// @Tags Search
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string true "Search text"
+// @Param types query string false "Comma-separated result types (events, branches, special_guests, volunteers); default all"
+// @Param limit query int false "Results per type (default 10, max 50)"
+// @Param include_archived query bool false "Also search archived financial years (slower)"
+// @Success 200 {object} utils.Response{data=services.SearchResults}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/search [get]
+func SearchHandler(c *gin.Context) {
+	opts := services.SearchOptions{}
+	if value := c.Query("types"); value != "" {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				opts.Types = append(opts.Types, t)
+			}
+		}
+	}
+	opts.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	opts.IncludeArchived = c.Query("include_archived") == "true"
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	opts.Scope = scope
+
+	results, err := services.Search(c.Query("q"), opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearch) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", results)
+}
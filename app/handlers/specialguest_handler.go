@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"errors"
-	"net/http"
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -19,28 +19,35 @@ import (
 // @Accept json
 // @Produce json
 // @Param specialGuest body models.SpecialGuest true "Special guest payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/specialguests [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests [post]
 func CreateSpecialGuestHandler(c *gin.Context) {
 	var sg models.SpecialGuest
 	if err := c.ShouldBindJSON(&sg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateSpecialGuestInput(sg.EventID, sg.Prefix, sg.FirstName, sg.LastName); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if !checkRecordAccess(c, services.ScopeEvent, sg.EventID) {
 		return
 	}
 
 	if err := services.CreateSpecialGuest(&sg); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Special guest created", "specialGuest": sg})
+	utils.Created(c, "Special guest created", gin.H{"specialGuest": sg})
 }
 
 // GetAllSpecialGuestsHandler returns all special guests
@@ -48,16 +55,81 @@ func CreateSpecialGuestHandler(c *gin.Context) {
 // @Tags SpecialGuests
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.SpecialGuest
-// @Failure 500 {object} map[string]string
-// @Router /api/specialguests [get]
+// @Success 200 {object} utils.Response{data=[]models.SpecialGuest}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests [get]
 func GetAllSpecialGuestsHandler(c *gin.Context) {
-	guests, err := services.GetAllSpecialGuests()
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	guests, err := services.GetAllSpecialGuests(scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", guests)
+}
+
+// SearchSpecialGuestsHandler searches special guests by name, organization or contact
+// @Summary Search special guests
+// @Description Name matching is transliteration-aware ("Vikas" also finds "विकास")
+// @Tags SpecialGuests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param search query string true "Search term"
+// @Success 200 {object} utils.Response{data=[]models.SpecialGuest}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests/search [get]
+func SearchSpecialGuestsHandler(c *gin.Context) {
+	searchTerm := c.Query("search")
+	if searchTerm == "" {
+		utils.BadRequest(c, "search parameter is required")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	guests, err := services.SearchSpecialGuests(searchTerm, scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", guests)
+}
+
+// GetSpecialGuestDuplicatesHandler lists special guests with the same name across scripts/spellings
+// @Summary Find duplicate special guests
+// @Tags SpecialGuests
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name query string true "Guest name (Latin or Devanagari)"
+// @Success 200 {object} utils.Response{data=[]models.SpecialGuest}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests/duplicates [get]
+func GetSpecialGuestDuplicatesHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		utils.BadRequest(c, "name parameter is required")
+		return
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	guests, err := services.FindSpecialGuestDuplicates(name, scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, guests)
+
+	utils.OK(c, "", guests)
 }
 
 // GetSpecialGuestByEventID returns the special guest linked to an event
@@ -66,26 +138,26 @@ func GetAllSpecialGuestsHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {object} models.SpecialGuest
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/events/{event_id}/specialguests [get]
+// @Success 200 {object} utils.Response{data=models.SpecialGuest}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/events/{event_id}/specialguests [get]
 func GetSpecialGuestByEventID(c *gin.Context) {
 	eventID := c.Param("event_id")
 
 	evID, err := strconv.ParseUint(eventID, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
 	sg, err := services.GetSpecialGuestByEventID(uint(evID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, sg)
+	utils.OK(c, "", sg)
 }
 
 // UpdateSpecialGuestHandler updates fields of a special guest
@@ -96,45 +168,48 @@ func GetSpecialGuestByEventID(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Special guest ID"
 // @Param updates body map[string]interface{} true "Fields to update"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/specialguests/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests/{id} [put]
 func UpdateSpecialGuestHandler(c *gin.Context) {
 	specialGuest, exists := c.Get("specialGuest")
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "special guest not found"})
+		utils.NotFound(c, "special guest not found")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := validators.ValidateSpecialGuestUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	updates := sanitizeSpecialGuestUpdates(updateData)
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no valid fields provided"})
+		utils.BadRequest(c, "no valid fields provided")
 		return
 	}
 
 	if err := services.UpdateSpecialGuest(specialGuest.(*models.SpecialGuest).ID, updates); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
 		if errors.Is(err, services.ErrSpecialGuestNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Special guest updated"})
+	utils.OK(c, "Special guest updated", nil)
 }
 
 // DeleteSpecialGuestHandler deletes a special guest
@@ -143,27 +218,30 @@ func UpdateSpecialGuestHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Special guest ID"
-// @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/specialguests/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/specialguests/{id} [delete]
 func DeleteSpecialGuestHandler(c *gin.Context) {
 	specialGuest, exists := c.Get("specialGuest")
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "special guest not found"})
+		utils.NotFound(c, "special guest not found")
 		return
 	}
 
 	if err := services.DeleteSpecialGuest(specialGuest.(*models.SpecialGuest).ID); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
 		if errors.Is(err, services.ErrSpecialGuestNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Special guest deleted"})
+	utils.OK(c, "Special guest deleted", nil)
 }
 
 func sanitizeSpecialGuestUpdates(payload map[string]interface{}) map[string]interface{} {
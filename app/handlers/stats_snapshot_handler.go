@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StatsSnapshotResponse is the result of a manual statistics snapshot
+type StatsSnapshotResponse struct {
+	SnapshotDate string `json:"snapshot_date"`
+	Branches     int    `json:"branches"`
+}
+
+// GetStatsSnapshotsHandler godoc
+// @Summary List statistics snapshots
+// @Description Lists the dates branch statistics were snapshotted on, most recent first, with the number of branches in each. Admin only.
+// @Tags StatsSnapshots
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "Number of dates (default 60, max 366)"
+// @Success 200 {object} utils.Response{data=[]services.StatsSnapshotDay}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/stats-snapshots [get]
+func GetStatsSnapshotsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "60"))
+	days, err := services.GetStatsSnapshotDays(limit)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", days)
+}
+
+// TakeStatsSnapshotHandler godoc
+// @Summary Snapshot statistics now
+// @Description Stores the current figures of every branch as today's snapshot (replacing one already taken today), e.g. right after the year-end figures are reported. Admin only.
+// @Tags StatsSnapshots
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 201 {object} utils.Response{data=StatsSnapshotResponse}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/stats-snapshots [post]
+func TakeStatsSnapshotHandler(c *gin.Context) {
+	now := time.Now()
+	branches, err := services.TakeStatsSnapshot(now)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.Created(c, "Statistics snapshot taken", StatsSnapshotResponse{SnapshotDate: now.Format("2006-01-02"), Branches: branches})
+}
+
+// GetBranchStatsRestatementsHandler godoc
+// @Summary Restatements of branch statistics
+// @Description Compares the figures of a branch as reported on as_of (the latest snapshot on or before the date) with the current figures, or with those reported on against. Lists every restated figure with its difference and, with include_children=true, the branches whose own figures changed.
+// @Tags Branches
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Branch ID"
+// @Param as_of query string true "Reported date (YYYY-MM-DD)"
+// @Param against query string false "Compare with the figures reported on this date instead of the current ones (YYYY-MM-DD)"
+// @Param include_children query bool false "Roll up child branches"
+// @Success 200 {object} utils.Response{data=services.BranchStatsRestatements}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/branches/{id}/stats/restatements [get]
+func GetBranchStatsRestatementsHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+	includeChildren := false
+	if value := c.Query("include_children"); value != "" {
+		if includeChildren, err = strconv.ParseBool(value); err != nil {
+			utils.BadRequest(c, "include_children must be true or false")
+			return
+		}
+	}
+	if c.Query("as_of") == "" {
+		utils.BadRequest(c, "as_of is required")
+		return
+	}
+	asOf, err := time.Parse("2006-01-02", c.Query("as_of"))
+	if err != nil {
+		utils.BadRequest(c, "invalid as_of (use YYYY-MM-DD)")
+		return
+	}
+	var against *time.Time
+	if value := c.Query("against"); value != "" {
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.BadRequest(c, "invalid against (use YYYY-MM-DD)")
+			return
+		}
+		against = &date
+	}
+
+	restatements, err := services.GetBranchStatsRestatements(uint(branchID), includeChildren, asOf, against)
+	if err != nil {
+		if errors.Is(err, services.ErrBranchNotFound) || errors.Is(err, services.ErrNoStatsSnapshot) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", restatements)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SetBranchStorageQuotaRequest sets or (with a null quota_bytes) removes a branch's quota
+type SetBranchStorageQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
+
+// GetStorageUsageHandler godoc
+// @Summary Storage usage per branch
+// @Description Returns the storage used by each branch, largest first, split by media category: event media (through the event's branch), branch media, public copies and donation receipts, with thumbnails and video renditions. Figures are from the last storage_usage job (computed_on), which runs every STORAGE_USAGE_INTERVAL (default 24h). Branches with a quota include quota_bytes and the share used. Admin only.
+// @Tags Storage
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.StorageUsageReport}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/storage/usage [get]
+func GetStorageUsageHandler(c *gin.Context) {
+	report, err := services.GetBranchStorageUsage(c.Request.Context())
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", report)
+}
+
+// StartStorageUsageRefreshHandler godoc
+// @Summary Recompute storage usage
+// @Description Queues the storage_usage job, which lists the bucket and attributes every file to its branch. Poll GET /api/jobs/{id}. Admin only.
+// @Tags Storage
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 202 {object} utils.Response{data=models.Job}
+// @Failure 409 {object} utils.Response "Storage usage is already being computed"
+// @Failure 503 {object} utils.Response "Storage is not configured"
+// @Router /api/v1/admin/storage/usage/refresh [post]
+func StartStorageUsageRefreshHandler(c *gin.Context) {
+	var createdBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		createdBy = &userID
+	}
+	job, err := services.StartStorageUsageRefresh(c.Request.Context(), createdBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrStorageUsageRunning):
+			utils.Conflict(c, err.Error())
+		case errors.Is(err, services.ErrStorageNotConfigured):
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	utils.Accepted(c, "", job)
+}
+
+// SetBranchStorageQuotaHandler godoc
+// @Summary Set a branch storage quota
+// @Description Sets the storage quota of a branch in bytes; null removes it. Once the computed usage of the branch reaches the quota, uploads to the branch and its events are rejected until files are deleted and usage is recomputed. Admin only.
+// @Tags Storage
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param branch_id path int true "Branch ID"
+// @Param request body SetBranchStorageQuotaRequest true "Quota"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/storage/quotas/{branch_id} [put]
+func SetBranchStorageQuotaHandler(c *gin.Context) {
+	branchID, err := strconv.ParseUint(c.Param("branch_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid branch ID")
+		return
+	}
+	var req SetBranchStorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	var updatedBy *uint
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		updatedBy = &userID
+	}
+	if err := services.SetBranchStorageQuota(c.Request.Context(), uint(branchID), req.QuotaBytes, updatedBy); err != nil {
+		switch {
+		case errors.Is(err, services.ErrBranchNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidStorageQuota):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	if req.QuotaBytes == nil {
+		utils.OK(c, "Storage quota removed", nil)
+		return
+	}
+	utils.OK(c, "Storage quota set", nil)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginTwoFactorRequest is the second step of a sign-in with two-factor authentication
+type LoginTwoFactorRequest struct {
+	TwoFactorToken string `json:"twoFactorToken" binding:"required"`
+	// Code is the 6 digit code of the authenticator app or one of the recovery codes
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest confirms an action with an authenticator code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest turns two-factor authentication off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // authenticator or recovery code
+}
+
+// TwoFactorStatusResponse describes the two-factor setup of the current user
+type TwoFactorStatusResponse struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+	Pending           bool       `json:"pending"` // setup started but not confirmed
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+}
+
+// TwoFactorSetupResponse is the secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`     // base32, for manual entry
+	OtpauthURL string `json:"otpauthUrl"` // render as a QR code
+}
+
+// RecoveryCodesResponse lists new recovery codes; they are not shown again
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete login with a two-factor code
+// @Description Second step of the login of accounts with two-factor authentication: exchanges the twoFactorToken returned by /auth/login and a code from the authenticator app (or a recovery code, which is then used up) for the session. The token expires after 5 minutes and 5 wrong codes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param loginTwoFactorRequest body LoginTwoFactorRequest true "Challenge token and code"
+// @Success 200 {object} utils.Response{data=LoginResponse} "Login successful"
+// @Failure 400 {object} utils.Response "Invalid request"
+// @Failure 401 {object} utils.Response "Invalid code or expired challenge"
+// @Router /api/v1/auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request")
+		return
+	}
+
+	ip := middleware.GetClientIP(c)
+	userAgent := c.GetHeader("User-Agent")
+
+	user, accessToken, refreshToken, err := h.authService.VerifyLoginTOTP(c.Request.Context(), req.TwoFactorToken, req.Code, ip, userAgent)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTOTPCode):
+			utils.Unauthorized(c, "invalid two-factor code")
+		case errors.Is(err, auth.ErrInvalidChallenge):
+			utils.Unauthorized(c, err.Error())
+		case errors.Is(err, auth.ErrUserDisabled), errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrTOTPNotEnabled):
+			utils.Unauthorized(c, "invalid credentials")
+		default:
+			utils.InternalServerError(c, "failed to verify two-factor code")
+		}
+		return
+	}
+
+	h.respondSignedIn(c, user, accessToken, refreshToken)
+}
+
+// GetTwoFactorStatus godoc
+// @Summary Get two-factor status
+// @Description Whether the current user has two-factor authentication, whether their role requires it, and how many recovery codes are left.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=TwoFactorStatusResponse}
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Router /api/v1/auth/2fa [get]
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+
+	status, err := auth.GetTOTPStatus(c.Request.Context(), userID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	required, err := services.TwoFactorRequiredForUser(uint(userID))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	utils.OK(c, "", TwoFactorStatusResponse{
+		Enabled:           status.Enabled,
+		EnabledAt:         status.EnabledAt,
+		Pending:           status.Pending,
+		Required:          required,
+		RecoveryCodesLeft: status.RecoveryCodesLeft,
+	})
+}
+
+// SetupTwoFactor godoc
+// @Summary Start two-factor enrollment
+// @Description Generates a new authenticator secret for the current user and returns it with its otpauth:// URL (to show as a QR code). Two-factor authentication is only turned on once a code is confirmed with /auth/2fa/enable; calling this again replaces an unconfirmed secret.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=TwoFactorSetupResponse}
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 409 {object} utils.Response "Two-factor authentication is already enabled"
+// @Router /api/v1/auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+
+	enrollment, err := h.authService.StartTOTPEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	utils.OK(c, "", TwoFactorSetupResponse{Secret: enrollment.Secret, OtpauthURL: enrollment.URI})
+}
+
+// EnableTwoFactor godoc
+// @Summary Confirm two-factor enrollment
+// @Description Checks a code of the secret from /auth/2fa/setup and turns two-factor authentication on. Returns 10 single-use recovery codes, which are only shown this once.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} utils.Response{data=RecoveryCodesResponse}
+// @Failure 400 {object} utils.Response "Invalid code, or setup not started"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 409 {object} utils.Response "Two-factor authentication is already enabled"
+// @Router /api/v1/auth/2fa/enable [post]
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request")
+		return
+	}
+
+	codes, err := h.authService.ConfirmTOTPEnrollment(c.Request.Context(), userID, req.Code, middleware.GetClientIP(c), c.GetHeader("User-Agent"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	utils.OK(c, "two-factor authentication enabled", RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Turns two-factor authentication off for the current user, confirmed with their password and an authenticator or recovery code. Not allowed while the user's role requires two-factor authentication.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body DisableTwoFactorRequest true "Password and code"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response "Invalid code, or two-factor authentication is not enabled"
+// @Failure 401 {object} utils.Response "Unauthorized or invalid password"
+// @Failure 403 {object} utils.Response "Required for the user's role"
+// @Router /api/v1/auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request")
+		return
+	}
+
+	required, err := services.TwoFactorRequiredForUser(uint(userID))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	if required {
+		utils.Forbidden(c, "two-factor authentication is required for your role")
+		return
+	}
+
+	if err := h.authService.DisableTOTP(c.Request.Context(), userID, req.Password, req.Code, middleware.GetClientIP(c), c.GetHeader("User-Agent")); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	utils.OK(c, "two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replaces the recovery codes of the current user, confirmed with a code of the authenticator app (not a recovery code). The new codes are only shown this once.
+// @Tags Auth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} utils.Response{data=RecoveryCodesResponse}
+// @Failure 400 {object} utils.Response "Invalid code, or two-factor authentication is not enabled"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Router /api/v1/auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.Unauthorized(c, "unauthorized")
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request")
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code, middleware.GetClientIP(c), c.GetHeader("User-Agent"))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	utils.OK(c, "recovery codes regenerated", RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidTOTPCode), errors.Is(err, auth.ErrTOTPNotEnrolled), errors.Is(err, auth.ErrTOTPNotEnabled):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+		utils.Conflict(c, err.Error())
+	case errors.Is(err, auth.ErrInvalidPassword):
+		utils.Unauthorized(c, "invalid password")
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, services.ErrUserNotFound):
+		utils.NotFound(c, "user not found")
+	default:
+		utils.InternalServerError(c, "two-factor authentication failed")
+	}
+}
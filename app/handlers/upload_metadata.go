@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadPeekBytes bounds how much of a multipart body is buffered while looking for the
+// metadata fields sent ahead of the files
+const maxUploadPeekBytes = 64 << 10
+
+// uploadSpec describes the form of a media upload endpoint
+type uploadSpec struct {
+	FileField       string // "file" or "files"
+	OwnerField      string // "event_id" or "branch_id"
+	Categories      []string
+	DefaultCategory string
+	AllowMediaID    bool // media_id replaces the file of existing event media
+}
+
+var (
+	eventUploadSpec = uploadSpec{
+		FileField: "file", OwnerField: "event_id", AllowMediaID: true,
+		Categories: validators.EventMediaCategories, DefaultCategory: "Event Photos",
+	}
+	eventMultiUploadSpec = uploadSpec{
+		FileField: "files", OwnerField: "event_id",
+		Categories: validators.EventMediaCategories, DefaultCategory: "Event Photos",
+	}
+	branchUploadSpec = uploadSpec{
+		FileField: "files", OwnerField: "branch_id",
+		Categories: validators.BranchMediaCategories, DefaultCategory: "Branch Photos",
+	}
+)
+
+// uploadMetadata is the validated form of an upload request
+type uploadMetadata struct {
+	OwnerID  uint
+	MediaID  uint
+	Category string
+	Branch   *models.Branch // branch uploads only (id and parent_branch_id)
+	Files    []*multipart.FileHeader
+}
+
+// parseUploadRequest validates the metadata fields of an upload and returns them with the
+// files. Fields sent ahead of the files are checked before the file bytes are read, so an
+// invalid request is rejected without receiving the upload; the rest are checked once the form
+// is parsed. All invalid fields are reported together. It writes the error response itself.
+func parseUploadRequest(c *gin.Context, spec uploadSpec) (*uploadMetadata, bool) {
+	meta := &uploadMetadata{}
+	checked := make(map[string]bool)
+
+	fields, firstFile, err := peekUploadFields(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return nil, false
+	}
+	errs := spec.check(meta, fields, checked, false)
+	if firstFile != "" {
+		if err := validators.ValidateUploadFilename(firstFile); err != nil {
+			errs = append(errs, validators.FieldError{Field: spec.FileField, Error: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		// Do not wait for the rest of the body; the client can stop sending it
+		c.Header("Connection", "close")
+		respondUploadErrors(c, errs)
+		return nil, false
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		utils.BadRequest(c, "failed to parse multipart form")
+		return nil, false
+	}
+	fields = make(map[string]string, len(form.Value))
+	for name, values := range form.Value {
+		if len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+	errs = spec.check(meta, fields, checked, true)
+	meta.Files = form.File[spec.FileField]
+	if len(meta.Files) == 0 {
+		errs = append(errs, validators.FieldError{Field: spec.FileField, Error: "at least one file is required"})
+	}
+	for i, file := range meta.Files {
+		if err := validators.ValidateUploadFilename(file.Filename); err != nil {
+			errs = append(errs, validators.FieldError{Field: fmt.Sprintf("%s[%d]", spec.FileField, i), Error: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		respondUploadErrors(c, errs)
+		return nil, false
+	}
+	return meta, true
+}
+
+// check validates the fields not checked yet. Missing fields are only reported once the whole
+// form has been read (final), since they may come after the files.
+func (spec uploadSpec) check(meta *uploadMetadata, fields map[string]string, checked map[string]bool, final bool) []validators.FieldError {
+	var errs []validators.FieldError
+	fail := func(field string, err error) {
+		errs = append(errs, validators.FieldError{Field: field, Error: err.Error()})
+	}
+
+	if value, ok := fields[spec.OwnerField]; ok && !checked[spec.OwnerField] {
+		checked[spec.OwnerField] = true
+		if id, err := validators.ValidateUploadID(value); err != nil {
+			fail(spec.OwnerField, err)
+		} else if err := spec.loadOwner(meta, id); err != nil {
+			fail(spec.OwnerField, err)
+		}
+	} else if !ok && final && !checked[spec.OwnerField] {
+		fail(spec.OwnerField, errors.New("is required"))
+	}
+
+	// media_id is checked against its event, so it waits for a valid event_id
+	if value, ok := fields["media_id"]; ok && spec.AllowMediaID && !checked["media_id"] && (meta.OwnerID != 0 || final) {
+		checked["media_id"] = true
+		if id, err := validators.ValidateUploadID(value); err != nil {
+			fail("media_id", err)
+		} else if meta.OwnerID != 0 {
+			var count int64
+			if err := config.DB.Model(&models.EventMedia{}).Where("id = ? AND event_id = ?", id, meta.OwnerID).Count(&count).Error; err != nil {
+				fail("media_id", errors.New("could not be checked"))
+			} else if count == 0 {
+				fail("media_id", errors.New("media not found for this event"))
+			} else {
+				meta.MediaID = id
+			}
+		}
+	}
+
+	if value, ok := fields["category"]; ok && !checked["category"] {
+		checked["category"] = true
+		meta.Category = value
+		if value == "" {
+			meta.Category = spec.DefaultCategory
+		} else if err := validators.ValidateUploadCategory(value, spec.Categories); err != nil {
+			fail("category", err)
+		}
+	} else if !ok && final && !checked["category"] {
+		meta.Category = spec.DefaultCategory
+	}
+	return errs
+}
+
+// loadOwner checks that the event or branch the files are uploaded to exists and has not used
+// up its branch's storage quota
+func (spec uploadSpec) loadOwner(meta *uploadMetadata, id uint) error {
+	switch spec.OwnerField {
+	case "branch_id":
+		var branch models.Branch
+		if err := config.DB.Select("id", "parent_branch_id").First(&branch, id).Error; err != nil {
+			return errors.New("branch not found")
+		}
+		meta.Branch = &branch
+		if err := services.CheckBranchStorageQuota(id); err != nil {
+			return quotaError(err)
+		}
+	default:
+		var count int64
+		if err := config.DB.Model(&models.EventDetails{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return errors.New("could not be checked")
+		}
+		if count == 0 {
+			return errors.New("event not found")
+		}
+		if err := services.CheckEventStorageQuota(id); err != nil {
+			return quotaError(err)
+		}
+	}
+	meta.OwnerID = id
+	return nil
+}
+
+// quotaError reports a branch over its storage quota as is, other errors of the check generically
+func quotaError(err error) error {
+	if errors.Is(err, services.ErrStorageQuotaExceeded) {
+		return err
+	}
+	return errors.New("could not be checked")
+}
+
+func respondUploadErrors(c *gin.Context, errs []validators.FieldError) {
+	utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeValidationFailed, "invalid upload request", errs)
+}
+
+// peekUploadFields reads the form fields sent ahead of the first file of a multipart request,
+// and the name of that file, then puts the bytes read back for the full parse. Browsers send
+// FormData fields in the order they were appended, so clients appending metadata before files
+// get invalid requests rejected before the files are sent. A malformed body is left to the
+// full parse to report.
+func peekUploadFields(c *gin.Context) (map[string]string, string, error) {
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, "", errors.New("request must be multipart/form-data")
+	}
+
+	body := c.Request.Body
+	var peeked bytes.Buffer
+	defer func() {
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&peeked, body), body}
+	}()
+
+	reader := multipart.NewReader(io.TeeReader(io.LimitReader(body, maxUploadPeekBytes), &peeked), params["boundary"])
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return fields, "", nil
+		}
+		if part.FileName() != "" {
+			return fields, part.FileName(), nil
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return fields, "", nil
+		}
+		if _, seen := fields[part.FormName()]; !seen {
+			fields[part.FormName()] = string(value)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadChunkTimeout bounds storing one chunk; slow connections take longer than the 30
+// second request timeout to send it
+const uploadChunkTimeout = 10 * time.Minute
+
+// CreateUploadSessionRequest starts a resumable upload to an event or a branch
+type CreateUploadSessionRequest struct {
+	EventID     uint   `json:"event_id"`
+	BranchID    uint   `json:"branch_id"`
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+	Category    string `json:"category"`
+}
+
+// CreateUploadSessionHandler godoc
+// @Summary Start a resumable upload
+// @Description Starts a chunked upload of a large video, audio or document file to an event (event_id) or branch (branch_id), for connections that cannot send the file in one request. Send the file with PATCH /files/uploads/{id} in chunks of chunk_size bytes (the last one may be shorter), then complete the session. Photos are uploaded with the regular endpoints. Sessions expire UPLOAD_SESSION_TTL (default 24h) after their last chunk.
+// @Tags Files
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateUploadSessionRequest true "File to upload"
+// @Success 201 {object} utils.Response{data=models.UploadSession}
+// @Failure 400 {object} utils.Response "Invalid metadata; all invalid fields are listed in details"
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/files/uploads [post]
+func CreateUploadSessionHandler(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "user not authenticated")
+		return
+	}
+	var req CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "invalid request: "+err.Error())
+		return
+	}
+
+	spec, target, ownerID := eventUploadSpec, services.ThumbnailTargetEventMedia, req.EventID
+	if req.BranchID != 0 {
+		spec, target, ownerID = branchUploadSpec, services.ThumbnailTargetBranchMedia, req.BranchID
+	}
+	var errs []validators.FieldError
+	if (req.EventID == 0) == (req.BranchID == 0) {
+		errs = append(errs, validators.FieldError{Field: "event_id", Error: "exactly one of event_id and branch_id is required"})
+	}
+	meta := &uploadMetadata{}
+	if ownerID != 0 {
+		if err := spec.loadOwner(meta, ownerID); err != nil {
+			errs = append(errs, validators.FieldError{Field: spec.OwnerField, Error: err.Error()})
+		}
+	}
+	if err := validators.ValidateUploadFilename(req.Filename); err != nil {
+		errs = append(errs, validators.FieldError{Field: "filename", Error: err.Error()})
+	}
+	if req.Category == "" {
+		req.Category = spec.DefaultCategory
+	} else if err := validators.ValidateUploadCategory(req.Category, spec.Categories); err != nil {
+		errs = append(errs, validators.FieldError{Field: "category", Error: err.Error()})
+	}
+	if len(errs) > 0 {
+		respondUploadErrors(c, errs)
+		return
+	}
+
+	if target == services.ThumbnailTargetBranchMedia {
+		if !checkBranchAccess(c, &meta.OwnerID) {
+			return
+		}
+	} else if !checkRecordAccess(c, services.ScopeEvent, meta.OwnerID) {
+		return
+	}
+
+	session, err := services.CreateUploadSession(c.Request.Context(), services.UploadSessionInput{
+		UserID:      userID,
+		Target:      target,
+		OwnerID:     meta.OwnerID,
+		Category:    req.Category,
+		Filename:    req.Filename,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+	})
+	if err != nil {
+		respondUploadSessionError(c, err)
+		return
+	}
+	c.Header("Upload-Offset", "0")
+	utils.Created(c, "Upload session created", session)
+}
+
+// GetUploadSessionHandler godoc
+// @Summary Get a resumable upload
+// @Description Returns an upload session of the caller; offset is where the next chunk starts, so a client resumes from there after a dropped connection.
+// @Tags Files
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "Upload session ID"
+// @Success 200 {object} utils.Response{data=models.UploadSession}
+// @Failure 404 {object} utils.Response "Unknown or expired session"
+// @Router /api/v1/files/uploads/{upload_id} [get]
+func GetUploadSessionHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(session.ReceivedBytes, 10))
+	utils.OK(c, "", session)
+}
+
+// UploadChunkHandler godoc
+// @Summary Upload a chunk
+// @Description Appends the raw request body to the upload at the offset given in the Upload-Offset header, which must equal the session's offset. Every chunk but the last must be exactly chunk_size bytes. A chunk at another offset is rejected with 409 and the session's offset in details.offset (and the Upload-Offset response header), where the client resumes.
+// @Tags Files
+// @Security ApiKeyAuth
+// @Accept octet-stream
+// @Produce json
+// @Param upload_id path string true "Upload session ID"
+// @Param Upload-Offset header int true "Offset of the chunk in the file"
+// @Success 200 {object} utils.Response{data=models.UploadSession}
+// @Failure 400 {object} utils.Response "Missing offset or wrong chunk size"
+// @Failure 404 {object} utils.Response "Unknown or expired session"
+// @Failure 409 {object} utils.Response "Wrong offset, or the session is completed"
+// @Router /api/v1/files/uploads/{upload_id} [patch]
+func UploadChunkHandler(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.BadRequest(c, "Upload-Offset header is required")
+		return
+	}
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), uploadChunkTimeout)
+	defer cancel()
+	session, err = services.WriteUploadChunk(ctx, session, offset, c.Request.Body)
+	if err != nil {
+		respondUploadSessionError(c, err)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(session.ReceivedBytes, 10))
+	utils.OK(c, "Chunk stored", session)
+}
+
+// CompleteUploadSessionHandler godoc
+// @Summary Complete a resumable upload
+// @Description Assembles the uploaded chunks into the file and creates its event or branch media record. The file is scanned (and videos browsers cannot play are transcoded) as for regular uploads. Completing a session again returns the same media.
+// @Tags Files
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "Upload session ID"
+// @Success 201 {object} utils.Response{data=services.UploadCompletion}
+// @Failure 404 {object} utils.Response "Unknown or expired session"
+// @Failure 409 {object} utils.Response "Chunks are missing"
+// @Router /api/v1/files/uploads/{upload_id}/complete [post]
+func CompleteUploadSessionHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	// Assembling a large file on a non-S3 backend takes longer than the request timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), uploadChunkTimeout)
+	defer cancel()
+	completion, err := services.CompleteUploadSession(ctx, session)
+	if err != nil {
+		respondUploadSessionError(c, err)
+		return
+	}
+	utils.Created(c, "File uploaded successfully", completion)
+}
+
+// AbortUploadSessionHandler godoc
+// @Summary Cancel a resumable upload
+// @Description Discards an upload session that is not completed and the chunks sent so far.
+// @Tags Files
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "Upload session ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response "Unknown or expired session"
+// @Failure 409 {object} utils.Response "The session is completed"
+// @Router /api/v1/files/uploads/{upload_id} [delete]
+func AbortUploadSessionHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if err := services.AbortUploadSession(c.Request.Context(), session); err != nil {
+		respondUploadSessionError(c, err)
+		return
+	}
+	utils.OK(c, "Upload cancelled", nil)
+}
+
+// loadUploadSession returns the caller's session named in the path
+func loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "user not authenticated")
+		return nil, false
+	}
+	session, err := services.GetUploadSession(c.Request.Context(), c.Param("upload_id"), userID)
+	if err != nil {
+		respondUploadSessionError(c, err)
+		return nil, false
+	}
+	return session, true
+}
+
+func respondUploadSessionError(c *gin.Context, err error) {
+	var offsetErr *services.UploadOffsetError
+	switch {
+	case errors.As(err, &offsetErr):
+		c.Header("Upload-Offset", strconv.FormatInt(offsetErr.Offset, 10))
+		utils.ErrorCodeResponse(c, http.StatusConflict, utils.CodeConflict, err.Error(), gin.H{"offset": offsetErr.Offset})
+	case errors.Is(err, services.ErrUploadSessionNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrUploadSessionCompleted), errors.Is(err, services.ErrUploadIncomplete):
+		utils.Conflict(c, err.Error())
+	case errors.Is(err, services.ErrUploadNotAllowed), errors.Is(err, services.ErrUploadChunkSize),
+		errors.Is(err, services.ErrUploadTooLarge):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrStorageNotConfigured):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		utils.InternalServerError(c, "upload failed")
+	}
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AssignUserRoleRequest is the payload of PUT /api/admin/users/:id/role
+type AssignUserRoleRequest struct {
+	RoleID uint `json:"role_id" binding:"required"`
+}
+
+// AssignUserBranchRequest is the payload of PUT /api/admin/users/:id/branch. Null (or a
+// missing field) clears the branch or region.
+type AssignUserBranchRequest struct {
+	BranchID *uint `json:"branch_id"`
+	RegionID *uint `json:"region_id"`
+}
+
+// ForceLogoutResponse reports how many sessions a forced logout revoked
+type ForceLogoutResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// ListUsersHandler godoc
+// @Summary List users
+// @Description Lists users (excluding deleted ones) by name, filtered by role, branch and status, with the total for pagination. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param role_id query int false "Role ID"
+// @Param branch_id query int false "Branch ID"
+// @Param status query string false "active or deactivated"
+// @Param search query string false "Name or email"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users [get]
+func ListUsersHandler(c *gin.Context) {
+	filter := services.UserListFilter{
+		Status: c.Query("status"),
+		Search: c.Query("search"),
+	}
+	for param, target := range map[string]*uint{"role_id": &filter.RoleID, "branch_id": &filter.BranchID} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.BadRequest(c, "invalid "+param)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	users, total, err := services.ListUsers(filter)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUserList) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", gin.H{
+		"data":  users,
+		"total": total,
+	})
+}
+
+// DeactivateUserHandler godoc
+// @Summary Deactivate a user
+// @Description Blocks the user from signing in and revokes all of their sessions and access tokens. The account is kept (unlike DELETE /users/{id}) and can be reactivated. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/deactivate [post]
+func DeactivateUserHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.CurrentUserID(c)
+
+	user, err := services.DeactivateUser(c.Request.Context(), userID, actorID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "User deactivated", user)
+}
+
+// ReactivateUserHandler godoc
+// @Summary Reactivate a user
+// @Description Lets a deactivated user sign in again. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/reactivate [post]
+func ReactivateUserHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := services.ReactivateUser(userID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "User reactivated", user)
+}
+
+// AssignUserRoleHandler godoc
+// @Summary Assign a role
+// @Description Gives the user the role (1 admin, 2 manager, 3 user). Admins cannot remove their own admin role. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param payload body AssignUserRoleRequest true "Role"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/role [put]
+func AssignUserRoleHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+	var req AssignUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	actorID, _ := middleware.CurrentUserID(c)
+
+	user, err := services.AssignUserRole(userID, req.RoleID, actorID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "Role assigned", user)
+}
+
+// AssignUserBranchHandler godoc
+// @Summary Assign a branch
+// @Description Sets the branch the user coordinates and the region whose branch change requests they review; null clears either. Non-admin users see the data of their branch and its child branches only, so the branch cannot be changed through PUT /users/{id}. Applies from the user's next request. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param payload body AssignUserBranchRequest true "Branch and region"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/branch [put]
+func AssignUserBranchHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+	var req AssignUserBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	user, err := services.AssignUserBranch(userID, req.BranchID, req.RegionID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "Branch assigned", user)
+}
+
+// RemoveUserRoleHandler godoc
+// @Summary Remove a role
+// @Description Takes the user's role away, leaving them the read-only user role. Admins cannot remove their own admin role. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/role [delete]
+func RemoveUserRoleHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+	actorID, _ := middleware.CurrentUserID(c)
+
+	user, err := services.RemoveUserRole(userID, actorID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "Role removed", user)
+}
+
+// ForceLogoutUserHandler godoc
+// @Summary Force logout
+// @Description Signs the user out on every device: revokes all sessions (refresh tokens) and rejects access tokens issued so far. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=ForceLogoutResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/logout [post]
+func ForceLogoutUserHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := services.ForceLogoutUser(c.Request.Context(), userID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "User signed out", ForceLogoutResponse{RevokedSessions: revoked})
+}
+
+// GetUserSessionsHandler godoc
+// @Summary List a user's sessions
+// @Description Lists the active sessions of the user: the device (user agent) and IP address each was started from, when, and when it was last refreshed. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=[]SessionResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/sessions [get]
+func GetUserSessionsHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := services.ListUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	response := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		response[i] = SessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+		}
+	}
+	utils.OK(c, "", response)
+}
+
+// RevokeUserSessionHandler godoc
+// @Summary Revoke a user's session
+// @Description Signs the user out of one session: its refresh token stops working and its access tokens are rejected. Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/sessions/{session_id} [delete]
+func RevokeUserSessionHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeUserSession(c.Request.Context(), userID, c.Param("session_id")); err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "Session revoked", nil)
+}
+
+// ResetUserTwoFactorHandler godoc
+// @Summary Reset a user's two-factor authentication
+// @Description Removes the authenticator secret and recovery codes of a user who lost both, so they can sign in with their password and enroll again (required at once if their role must use two-factor authentication). Admin only.
+// @Tags AdminUsers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} utils.Response{data=models.User}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/users/{id}/2fa [delete]
+func ResetUserTwoFactorHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := services.ResetUserTwoFactor(c.Request.Context(), userID)
+	if err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	utils.OK(c, "Two-factor authentication reset", user)
+}
+
+func parseUserIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid user ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondUserAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, auth.ErrSessionNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrBranchNotFound),
+		errors.Is(err, services.ErrOwnAccountChange):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
@@ -4,70 +4,117 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/mail"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // CreateUserHandler godoc
 // @Summary Create a new user
-// @Description Create user with auto-generated password (returned in response)
+// @Description Create user with an auto-generated temporary password. The password is emailed to the user (and sent by SMS or WhatsApp when that is their notification_channel), never returned; failed deliveries are retried in the background with a new password. Fails with 503 when neither email nor the user's channel is configured. Only admins can create users with a role other than 3 (user) or set branch_id and region_id.
 // @Tags Users
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param user body models.User true "User payload"
-// @Success 201 {object} models.CreateUserResponse
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/users [post]
+// @Success 201 {object} utils.Response{data=models.CreateUserResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/users [post]
 func CreateUserHandler(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format"})
+		utils.BadRequest(c, "invalid request format")
 		return
 	}
 
 	// Validate user input
 	if err := validators.ValidateUserInput(user.Name, user.Email, user.ContactNumber, user.RoleID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := validators.ValidateNotificationChannel(user.NotificationChannel); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	// The role and branch decide what the user can do and see (see services.BranchScopeFor),
+	// so only admins create users with more than read-only access or assign a branch
+	if roleID, _ := middleware.CurrentRoleID(c); roleID != models.RoleAdmin {
+		if user.RoleID != models.RoleUser {
+			utils.Forbidden(c, "only admins can create users with a role other than user")
+			return
+		}
+		if user.BranchID != nil || user.RegionID != nil {
+			utils.Forbidden(c, "only admins can assign a branch or region")
+			return
+		}
+	}
+
+	// The temporary password is only ever sent to the user: without a way to send it the
+	// account could not be used
+	if !services.CanDeliverCredentials(&user) {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, services.ErrCredentialsUndeliverable.Error())
 		return
 	}
 
 	if err := services.CreateUser(&user); err != nil {
 		// Check if it's an email already exists error
 		if err.Error() == "email already exists" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Email ID already exists. Please use a different email."})
+			utils.BadRequest(c, "Email ID already exists. Please use a different email.")
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 		}
 		return
 	}
 
+	password := user.Password
+	user.Password = ""
 	response := models.CreateUserResponse{
-		Message:  "User created successfully",
-		User:     user,
-		Password: user.Password, // show auto-generated password
+		Message: "User created successfully. Login details were sent to the user",
+		User:    user,
+	}
+	if err := services.SendWelcomeEmail(c.Request.Context(), &user, password); err != nil {
+		response.Message = "User created successfully. Login details could not be sent yet and will be retried"
+		if err := services.QueueCredentials(c.Request.Context(), user.ID, mail.TemplateWelcome); err != nil {
+			utils.Logger(c.Request.Context()).Error("Failed to queue welcome email", zap.Uint("user_id", user.ID), zap.Error(err))
+			response.Message = "User created successfully, but login details could not be sent. Reset the password to send new ones"
+		}
+	} else {
+		response.EmailSent = true
 	}
-	c.JSON(http.StatusCreated, response)
+	utils.Created(c, "", response)
 }
 
 // GetAllUsersHandler godoc
 // @Summary     Get all users
+// @Description Sort with sort=name,-last_login_on (fields: id, name, email, created_on, last_login_on; newest first by default) and filter by any of them or role_id, branch_id, region_id (see GET /branches for the operators).
 // @Tags        Users
 // @Security    ApiKeyAuth
 // @Produce     json
-// @Success     200 {array} models.User
-// @Failure     500 {object} map[string]string
-// @Router      /api/users [get]
+// @Param       sort query string false "Comma-separated sort fields, - for descending"
+// @Success     200 {object} utils.Response{data=[]models.User}
+// @Failure     400 {object} utils.Response
+// @Failure     500 {object} utils.Response
+// @Router      /api/v1/users [get]
 func GetAllUsersHandler(c *gin.Context) {
-	users, err := services.GetAllUsers()
+	query, err := services.UserListSchema.Parse(c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	users, err := services.GetAllUsers(query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		utils.InternalServerError(c, "failed to fetch users")
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	utils.OK(c, "", users)
 }
 
 // GetUserSearchHandler godoc
@@ -78,27 +125,27 @@ func GetAllUsersHandler(c *gin.Context) {
 // @Produce     json
 // @Param       email           query string false "User Email"
 // @Param       contact_number  query string false "User Contact Number"
-// @Success     200 {array} models.User
-// @Failure     400 {object} map[string]string
-// @Failure     404 {object} map[string]string
-// @Router      /api/users/search [get]
+// @Success     200 {object} utils.Response{data=[]models.User}
+// @Failure     400 {object} utils.Response
+// @Failure     404 {object} utils.Response
+// @Router      /api/v1/users/search [get]
 func GetUserSearchHandler(c *gin.Context) {
 	email := c.Query("email")
 	contact := c.Query("contact_number")
 
 	// Validate search input
 	if err := validators.ValidateSearchInput(email, contact); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	users, err := services.GetUserSearch(email, contact)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	utils.OK(c, "", users)
 }
 
 // GetUserByIDHandler godoc
@@ -108,69 +155,88 @@ func GetUserSearchHandler(c *gin.Context) {
 // @Security    ApiKeyAuth
 // @Produce     json
 // @Param       id  path int true "User ID"
-// @Success     200 {object} models.User
-// @Failure     404 {object} map[string]string
-// @Failure     500 {object} map[string]string
-// @Router      /api/users/{id} [get]
+// @Success     200 {object} utils.Response{data=models.User}
+// @Failure     404 {object} utils.Response
+// @Failure     500 {object} utils.Response
+// @Router      /api/v1/users/{id} [get]
 func GetUserByIDHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.BadRequest(c, "Invalid user ID")
 		return
 	}
 
 	user, err := services.GetUserByID(uint(userID))
 	if err != nil {
 		if err == services.ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
+	setVersionETag(c, user.Version)
 
-	c.JSON(http.StatusOK, user)
+	utils.OK(c, "", user)
 }
 
 // UpdateUserHandler godoc
 // @Summary Update a user
+// @Description Users can update their own profile, admins anyone's. Status, role and branch are changed through the admin endpoints. Send the version last read (If-Match with the ETag of the GET, or a "version" field) to have the update rejected with 409 and the latest user if someone else updated it since.
 // @Tags Users
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
-// @Param user body map[string]interface{} true "Updated fields"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/users/{id} [put]
+// @Param If-Match header string false "Version last read (the ETag of the GET)"
+// @Param user body map[string]interface{} true "Updated fields: name, email, contact_number, notification_channel"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/users/{id} [put]
 func UpdateUserHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.BadRequest(c, "Invalid user ID")
+		return
+	}
+	// Users edit their own profile; admins edit anyone's
+	actorID, _ := middleware.CurrentUserID(c)
+	if roleID, _ := middleware.CurrentRoleID(c); roleID != models.RoleAdmin && uint(userID) != actorID {
+		utils.Forbidden(c, "you can only update your own profile")
 		return
 	}
 
 	var updateData map[string]interface{}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	version, err := expectedVersion(c, updateData)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate update fields
 	if err := validators.ValidateUpdateFields(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	if err := services.UpdateUser(uint(userID), updateData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := services.UpdateUser(uint(userID), updateData, version); err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+	utils.OK(c, "User updated successfully", nil)
 }
 
 // DeleteUserHandler godoc
@@ -179,24 +245,24 @@ func UpdateUserHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/users/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/users/{id} [delete]
 func DeleteUserHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.BadRequest(c, "Invalid user ID")
 		return
 	}
 
 	if err := services.DeleteUser(uint(userID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	utils.OK(c, "User deleted successfully", nil)
 }
 
 // ChangePasswordHandler godoc
@@ -208,85 +274,108 @@ func DeleteUserHandler(c *gin.Context) {
 // @Produce json
 // @Param id path int true "User ID"
 // @Param passwordData body map[string]string true "Password change data (old_password, new_password, confirm_password)"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/users/{id}/change-password [post]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/users/{id}/change-password [post]
 func ChangePasswordHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.BadRequest(c, "Invalid user ID")
 		return
 	}
 
 	var passwordData map[string]string
 	if err := c.ShouldBindJSON(&passwordData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	oldPassword, ok := passwordData["old_password"]
 	if !ok || oldPassword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "old_password is required"})
+		utils.BadRequest(c, "old_password is required")
 		return
 	}
 
 	newPassword, ok := passwordData["new_password"]
 	if !ok || newPassword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "new_password is required"})
+		utils.BadRequest(c, "new_password is required")
 		return
 	}
 
 	confirmPassword, ok := passwordData["confirm_password"]
 	if !ok || confirmPassword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm_password is required"})
+		utils.BadRequest(c, "confirm_password is required")
 		return
 	}
 
 	// Validate password change request
 	if err := validators.ValidatePasswordChange(oldPassword, newPassword, confirmPassword); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondPasswordPolicyError(c, err) {
+			return
+		}
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	if err := services.ChangePassword(uint(userID), oldPassword, newPassword); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if respondPasswordPolicyError(c, err) {
+			return
+		}
+		utils.Unauthorized(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	utils.OK(c, "Password changed successfully", nil)
 }
 
 // ResetPasswordHandler godoc
 // @Summary Reset user password (admin only)
-// @Description Admin can reset a user's password, generating a new temporary password
+// @Description Admin can reset a user's password, generating a new temporary password. The password is emailed to the user (and sent by SMS or WhatsApp when that is their notification_channel), never returned; failed deliveries are retried in the background with a new password. Fails with 503 when neither email nor the user's channel is configured.
 // @Tags Users
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {object} models.ResetPasswordResponse
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/users/{id}/reset-password [post]
+// @Success 200 {object} utils.Response{data=models.ResetPasswordResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/users/{id}/reset-password [post]
 func ResetPasswordHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	userID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		utils.BadRequest(c, "Invalid user ID")
 		return
 	}
 
 	newPassword, err := services.ResetPassword(uint(userID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case services.ErrUserNotFound:
+			utils.NotFound(c, err.Error())
+		case services.ErrCredentialsUndeliverable:
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
 		return
 	}
 
 	response := models.ResetPasswordResponse{
-		Message:  "Password reset successfully",
-		Password: newPassword,
+		Message: "Password reset successfully. The new temporary password was sent to the user",
+	}
+	if err := services.SendTemporaryPasswordEmail(c.Request.Context(), uint(userID), newPassword); err != nil {
+		response.Message = "Password reset successfully. The new temporary password could not be sent yet and will be retried"
+		if err := services.QueueCredentials(c.Request.Context(), uint(userID), mail.TemplateTemporaryPassword); err != nil {
+			utils.Logger(c.Request.Context()).Error("Failed to queue temporary password email", zap.Uint("user_id", uint(userID)), zap.Error(err))
+			response.Message = "Password reset successfully, but the new temporary password could not be sent. Try again later"
+		}
+	} else {
+		response.EmailSent = true
 	}
-	c.JSON(http.StatusOK, response)
+	utils.OK(c, "", response)
 }
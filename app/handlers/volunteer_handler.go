@@ -2,11 +2,12 @@ package handlers
 
 import (
 	"errors"
-	"net/http"
 	"strconv"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -19,29 +20,36 @@ import (
 // @Accept json
 // @Produce json
 // @Param volunteer body models.Volunteer true "Volunteer payload"
-// @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/volunteers [post]
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers [post]
 func CreateVolunteerHandler(c *gin.Context) {
 	var volunteer models.Volunteer
 	if err := c.ShouldBindJSON(&volunteer); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate volunteer input
 	if err := validators.ValidateVolunteerInput(volunteer.VolunteerName, volunteer.BranchID, volunteer.EventID, volunteer.NumberOfDays); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if !checkBranchAccess(c, &volunteer.BranchID) || !checkRecordAccess(c, services.ScopeEvent, volunteer.EventID) {
 		return
 	}
 
 	if err := services.CreateVolunteer(&volunteer); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondArchivedYear(c, err) {
+			return
+		}
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "volunteer created", "volunteer": volunteer})
+	utils.Created(c, "volunteer created", gin.H{"volunteer": volunteer})
 }
 
 // GetAllVolunteersHandler returns all volunteers
@@ -49,16 +57,21 @@ func CreateVolunteerHandler(c *gin.Context) {
 // @Tags Volunteers
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Volunteer
-// @Failure 500 {object} map[string]string
-// @Router /api/volunteers [get]
+// @Param include_deleted query bool false "Include soft-deleted records (admin only)"
+// @Success 200 {object} utils.Response{data=[]models.Volunteer}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers [get]
 func GetAllVolunteersHandler(c *gin.Context) {
-	volunteers, err := services.GetAllVolunteers()
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	volunteers, err := services.GetAllVolunteers(middleware.IncludeDeleted(c), scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, volunteers)
+	utils.OK(c, "", volunteers)
 }
 
 // GetVolunteerByEventID returns all volunteers linked to an event
@@ -67,26 +80,26 @@ func GetAllVolunteersHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param event_id path int true "Event ID"
-// @Success 200 {array} models.Volunteer
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Router /api/events/{event_id}/volunteers [get]
+// @Success 200 {object} utils.Response{data=[]models.Volunteer}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/events/{event_id}/volunteers [get]
 func GetVolunteerByEventID(c *gin.Context) {
 	eventID := c.Param("event_id")
 
 	evID, err := strconv.ParseUint(eventID, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		utils.BadRequest(c, "Invalid event ID")
 		return
 	}
 
 	vol, err := services.GetVolunteerByEventID(uint(evID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		utils.NotFound(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, vol)
+	utils.OK(c, "", vol)
 }
 
 // UpdateVolunteerHandler updates volunteer fields
@@ -97,46 +110,49 @@ func GetVolunteerByEventID(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Volunteer ID"
 // @Param updates body map[string]interface{} true "Fields to update"
-// @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/volunteers/{id} [put]
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/{id} [put]
 func UpdateVolunteerHandler(c *gin.Context) {
 	volunteer, exists := c.Get("volunteer")
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "volunteer not found"})
+		utils.NotFound(c, "volunteer not found")
 		return
 	}
 
 	var payload map[string]interface{}
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	// Validate update fields
 	if err := validators.ValidateVolunteerUpdateFields(payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
 	updates := sanitizeVolunteerUpdates(payload)
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no valid fields provided"})
+		utils.BadRequest(c, "no valid fields provided")
 		return
 	}
 
 	if err := services.UpdateVolunteer(volunteer.(*models.Volunteer).ID, updates); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
 		if errors.Is(err, services.ErrVolunteerNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "volunteer updated"})
+	utils.OK(c, "volunteer updated", nil)
 }
 
 // DeleteVolunteerHandler deletes a volunteer
@@ -145,27 +161,63 @@ func UpdateVolunteerHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path int true "Volunteer ID"
-// @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/volunteers/{id} [delete]
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/{id} [delete]
 func DeleteVolunteerHandler(c *gin.Context) {
 	volunteer, exists := c.Get("volunteer")
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "volunteer not found"})
+		utils.NotFound(c, "volunteer not found")
 		return
 	}
 
 	if err := services.DeleteVolunteer(volunteer.(*models.Volunteer).ID); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
 		if errors.Is(err, services.ErrVolunteerNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			utils.NotFound(c, err.Error())
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.InternalServerError(c, err.Error())
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "volunteer deleted"})
+	utils.OK(c, "volunteer deleted", nil)
+}
+
+// RestoreVolunteerHandler restores a soft-deleted volunteer
+// @Summary Restore a deleted volunteer
+// @Tags Volunteers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Volunteer ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/{id}/restore [post]
+func RestoreVolunteerHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid volunteer ID")
+		return
+	}
+
+	if err := services.RestoreVolunteer(uint(id)); err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrNotDeleted) {
+			utils.NotFound(c, err.Error())
+		} else {
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "volunteer restored", nil)
 }
 
 // SearchVolunteersHandler searches volunteers by name or contact
@@ -174,24 +226,70 @@ func DeleteVolunteerHandler(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Produce json
 // @Param search query string true "Search term (name or contact)"
-// @Success 200 {array} models.Volunteer
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /api/volunteers/search [get]
+// @Success 200 {object} utils.Response{data=[]models.Volunteer}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/search [get]
 func SearchVolunteersHandler(c *gin.Context) {
 	searchTerm := c.Query("search")
 	if searchTerm == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "search parameter is required"})
+		utils.BadRequest(c, "search parameter is required")
 		return
 	}
 
-	volunteers, err := services.SearchVolunteers(searchTerm)
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	volunteers, err := services.SearchVolunteers(searchTerm, scope)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		utils.InternalServerError(c, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, volunteers)
+	utils.OK(c, "", volunteers)
+}
+
+// GetVolunteerDuplicatesHandler lists volunteers with the same name across scripts/spellings
+// @Summary Find duplicate volunteers
+// @Description Matches "Vikas", "Vikaas" and "विकास" as the same person
+// @Tags Volunteers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name query string true "Volunteer name (Latin or Devanagari)"
+// @Param branch_id query int false "Restrict to a branch"
+// @Success 200 {object} utils.Response{data=[]models.Volunteer}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/duplicates [get]
+func GetVolunteerDuplicatesHandler(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		utils.BadRequest(c, "name parameter is required")
+		return
+	}
+
+	var branchID uint64
+	if branchIDStr := c.Query("branch_id"); branchIDStr != "" {
+		var err error
+		branchID, err = strconv.ParseUint(branchIDStr, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, "invalid branch_id")
+			return
+		}
+	}
+
+	scope, ok := currentBranchScope(c)
+	if !ok {
+		return
+	}
+	volunteers, err := services.FindVolunteerDuplicates(name, uint(branchID), scope)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.OK(c, "", volunteers)
 }
 
 func sanitizeVolunteerUpdates(payload map[string]interface{}) map[string]interface{} {
@@ -213,3 +311,87 @@ func sanitizeVolunteerUpdates(payload map[string]interface{}) map[string]interfa
 
 	return sanitized
 }
+
+// MergeVolunteersRequest lists the duplicate volunteer records to merge into the target
+type MergeVolunteersRequest struct {
+	SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+}
+
+// GetVolunteerHistoryHandler returns every event a volunteer served at
+// @Summary Get a volunteer's history
+// @Description All events and seva performed by the registered person the volunteer record is linked to, most recent first
+// @Tags Volunteers
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Volunteer ID"
+// @Success 200 {object} utils.Response{data=services.VolunteerHistory}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/{id}/history [get]
+func GetVolunteerHistoryHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid volunteer ID")
+		return
+	}
+
+	history, err := services.GetVolunteerHistory(uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrVolunteerNotFound) {
+			utils.NotFound(c, err.Error())
+		} else {
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "", history)
+}
+
+// MergeVolunteersHandler merges duplicate volunteers into one
+// @Summary Merge duplicate volunteers
+// @Description Merges the persons of the source volunteer records into the person of the target record, so all their events show up in one history. The merge is audit logged. Admin only.
+// @Tags Volunteers
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Target volunteer ID"
+// @Param payload body MergeVolunteersRequest true "Volunteer records to merge into the target"
+// @Success 200 {object} utils.Response{data=services.VolunteerHistory}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 423 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/volunteers/{id}/merge [post]
+func MergeVolunteersHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid volunteer ID")
+		return
+	}
+
+	var req MergeVolunteersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	history, err := services.MergeVolunteers(uint(id), req.SourceIDs, auditActor(c))
+	if err != nil {
+		if respondArchivedYear(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrVolunteerNotFound), errors.Is(err, services.ErrPersonNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, services.ErrInvalidVolunteerMerge), errors.Is(err, services.ErrInvalidPersonMerge):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalServerError(c, err.Error())
+		}
+		return
+	}
+
+	utils.OK(c, "", history)
+}
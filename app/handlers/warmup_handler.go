@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// WarmUpHandler godoc
+// @Summary Warm up caches
+// @Description Runs the startup warm-up again on this instance: opens the idle database connections, loads feature flags and the location tables, initializes the PDF report renderer and presigns the branch directory images. Failed steps are reported with their error; the others still run. Admin only.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=services.WarmupResult}
+// @Router /api/v1/admin/warmup [post]
+func WarmUpHandler(c *gin.Context) {
+	utils.OK(c, "", services.WarmUp(c.Request.Context()))
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookSubscriptionRequest creates or updates a webhook subscription. On update, omitted
+// fields are left unchanged.
+type WebhookSubscriptionRequest struct {
+	Name        *string  `json:"name" binding:"omitempty,max=255"`
+	URL         *string  `json:"url" binding:"omitempty,max=2000"`
+	Secret      *string  `json:"secret" binding:"omitempty,max=255"` // generated when omitted on create
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+	EventTypes  []string `json:"event_types"`
+	BranchIDs   []uint   `json:"branch_ids"`
+	RegionIDs   []uint   `json:"region_ids"`
+}
+
+func (r WebhookSubscriptionRequest) input() services.WebhookInput {
+	return services.WebhookInput{
+		Name:        r.Name,
+		URL:         r.URL,
+		Secret:      r.Secret,
+		Description: r.Description,
+		Active:      r.Active,
+		EventTypes:  r.EventTypes,
+		BranchIDs:   r.BranchIDs,
+		RegionIDs:   r.RegionIDs,
+	}
+}
+
+// TestWebhookRequest chooses which sample payload the test delivery sends
+type TestWebhookRequest struct {
+	EventType string `json:"event_type"`
+}
+
+// GetWebhookEventTypesHandler godoc
+// @Summary Webhook event type catalog
+// @Description Lists the event types webhooks can subscribe to, with a sample payload for each. Branch-scoped types are filtered by a subscription's branch_ids and region_ids. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]services.WebhookEventType}
+// @Router /api/v1/admin/webhooks/event-types [get]
+func GetWebhookEventTypesHandler(c *gin.Context) {
+	utils.OK(c, "", services.WebhookEventTypes())
+}
+
+// GetWebhookSubscriptionsHandler godoc
+// @Summary List webhook subscriptions
+// @Description Secrets are never returned. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.WebhookSubscription}
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/webhooks [get]
+func GetWebhookSubscriptionsHandler(c *gin.Context) {
+	subscriptions, err := services.GetWebhookSubscriptions()
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+	utils.OK(c, "", subscriptions)
+}
+
+// GetWebhookSubscriptionHandler godoc
+// @Summary Get a webhook subscription
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} utils.Response{data=models.WebhookSubscription}
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [get]
+func GetWebhookSubscriptionHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	subscription, err := services.GetWebhookSubscription(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "", subscription)
+}
+
+// CreateWebhookSubscriptionHandler godoc
+// @Summary Register a webhook endpoint
+// @Description Payloads are POSTed as JSON with X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and X-Webhook-Signature ("sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret). The secret is only returned by this call. Empty event_types, branch_ids and region_ids mean all. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param payload body WebhookSubscriptionRequest true "Subscription (name and url required)"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/v1/admin/webhooks [post]
+func CreateWebhookSubscriptionHandler(c *gin.Context) {
+	var req WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	subscription, secret, err := services.CreateWebhookSubscription(req.input(), auditActor(c))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.Created(c, "", gin.H{
+		"id":           subscription.ID,
+		"subscription": subscription,
+		"secret":       secret,
+	})
+}
+
+// UpdateWebhookSubscriptionHandler godoc
+// @Summary Update a webhook subscription
+// @Description Omitted fields are unchanged; sending a secret rotates it. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param payload body WebhookSubscriptionRequest true "Fields to update"
+// @Success 200 {object} utils.Response{data=models.WebhookSubscription}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [put]
+func UpdateWebhookSubscriptionHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	var req WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	subscription, err := services.UpdateWebhookSubscription(id, req.input(), auditActor(c))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "", subscription)
+}
+
+// DeleteWebhookSubscriptionHandler godoc
+// @Summary Delete a webhook subscription
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [delete]
+func DeleteWebhookSubscriptionHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	if err := services.DeleteWebhookSubscription(id, auditActor(c)); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "Webhook subscription deleted successfully", nil)
+}
+
+// TestWebhookSubscriptionHandler godoc
+// @Summary Send a test delivery
+// @Description Sends a signed sample payload (marked "test": true) to the endpoint right away, regardless of its filters or active flag, and returns the endpoint's response. Without event_type a "webhook.test" ping is sent. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param payload body TestWebhookRequest false "Sample event type from the catalog"
+// @Success 200 {object} utils.Response{data=services.WebhookDeliveryResult}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id}/test [post]
+func TestWebhookSubscriptionHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	var req TestWebhookRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	result, err := services.TestWebhookSubscription(c.Request.Context(), id, req.EventType)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "", result)
+}
+
+// GetWebhookDeliveriesHandler godoc
+// @Summary List the deliveries of a webhook subscription
+// @Description Returns the delivery log of the subscription, newest first: the payload of each event, its status (pending, succeeded, failed), the number of attempts and the outcome of the latest one (HTTP status, first 1KB of the response, error). Failed attempts are retried with exponential backoff, up to 10 attempts over about four hours. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param event_type query string false "Event type"
+// @Param status query string false "Delivery status (pending, succeeded, failed)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveriesHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	filter := services.WebhookDeliveryFilter{
+		SubscriptionID: id,
+		EventType:      c.Query("event_type"),
+		Status:         c.Query("status"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	deliveries, total, err := services.GetWebhookDeliveries(filter)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "", gin.H{
+		"data":  deliveries,
+		"total": total,
+	})
+}
+
+// GetWebhookDeliveryHandler godoc
+// @Summary Get a webhook delivery
+// @Description Looks a delivery up by its delivery ID, as sent to the receiver in X-Webhook-Delivery. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} utils.Response{data=models.WebhookDelivery}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id}/deliveries/{delivery_id} [get]
+func GetWebhookDeliveryHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	delivery, err := services.GetWebhookDelivery(id, c.Param("delivery_id"))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "", delivery)
+}
+
+// RedeliverWebhookHandler godoc
+// @Summary Redeliver a webhook delivery
+// @Description Sends a succeeded or failed delivery again with a fresh set of attempts, keeping its payload and delivery ID. Admin only.
+// @Tags Webhooks
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} utils.Response{data=models.WebhookDelivery}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhookHandler(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	delivery, err := services.RedeliverWebhook(c.Request.Context(), id, c.Param("delivery_id"))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.OK(c, "Delivery queued", delivery)
+}
+
+func webhookIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid webhook ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrWebhookDeliveryPending):
+		utils.Conflict(c, err.Error())
+	case errors.Is(err, services.ErrInvalidWebhook):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalServerError(c, err.Error())
+	}
+}
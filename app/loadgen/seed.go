@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// seedEventsSQL inserts one batch of synthetic events. Rows are generated server side;
+// categories (with their type) and branches are spread round-robin over the existing ones.
+// Named parameters: @run, @offset, @size, @marker.
+const seedEventsSQL = `
+WITH categories AS (
+    SELECT id, event_type_id, row_number() OVER (ORDER BY id) - 1 AS rn, count(*) OVER () AS total FROM event_categories
+), branches AS (
+    SELECT id, row_number() OVER (ORDER BY id) - 1 AS rn, count(*) OVER () AS total FROM branches WHERE deleted_at IS NULL
+), seq AS (
+    SELECT g, CURRENT_DATE - (g % 730) AS event_day FROM generate_series(@offset + 1, @offset + @size) AS g
+)
+INSERT INTO event_details (
+    event_type_id, event_category_id, scale, theme, start_date, end_date,
+    country, state, city,
+    beneficiary_men, beneficiary_women, beneficiary_child,
+    initiation_men, initiation_women, initiation_child,
+    branch_id, status, approval_status, report_number, created_on, created_by
+)
+SELECT
+    c.event_type_id, c.id,
+    (ARRAY['small', 'medium', 'large'])[1 + g % 3],
+    'Synthetic event ' || g,
+    event_day, event_day + (g % 4),
+    'India', 'State ' || (g % 28), 'City ' || (g % 500),
+    g % 300, g % 400, g % 150,
+    g % 20, g % 25, 0,
+    b.id,
+    (ARRAY['complete', 'complete', 'incomplete'])[1 + g % 3],
+    (ARRAY['draft', 'submitted', 'approved', 'published'])[1 + g % 4],
+    'LOADGEN/' || @run || '/' || g,
+    event_day::timestamptz,
+    @marker
+FROM seq
+LEFT JOIN categories c ON c.rn = seq.g % c.total
+LEFT JOIN branches b ON b.rn = seq.g % b.total`
+
+// seedMediaSQL inserts perEvent media rows with fake S3 keys for a range of seeded events.
+// Every other row gets fake thumbnail keys so gallery listings exercise both code paths.
+const seedMediaSQL = `
+WITH coverage AS (
+    SELECT id, row_number() OVER (ORDER BY id) - 1 AS rn, count(*) OVER () AS total FROM media_coverage_type
+)
+INSERT INTO event_media (
+    event_id, media_coverage_type_id, company_name, first_name, last_name,
+    s3_key, original_filename, file_type, thumbnail_s3_key, thumbnail_medium_s3_key,
+    created_on, updated_on, created_by
+)
+SELECT
+    e.id, c.id, 'Synthetic Media ' || (n % 50), 'Reporter', 'No. ' || n,
+    'loadgen/' || md5(e.id || '-' || n) || '.jpg',
+    'photo-' || n || '.jpg',
+    'image',
+    CASE WHEN n % 2 = 0 THEN 'thumbnails/small/loadgen/' || md5(e.id || '-' || n) || '.jpg' END,
+    CASE WHEN n % 2 = 0 THEN 'thumbnails/medium/loadgen/' || md5(e.id || '-' || n) || '.jpg' END,
+    e.created_on, e.created_on, @marker
+FROM event_details e
+CROSS JOIN generate_series(1, @per_event) AS n
+JOIN coverage c ON c.rn = (e.id + n) % c.total
+WHERE e.created_by = @marker AND e.id BETWEEN @from AND @to`
+
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	events := fs.Int("events", 100000, "number of synthetic events")
+	media := fs.Int("media", 1000000, "number of synthetic event media rows (spread evenly over the events)")
+	batch := fs.Int("batch", 5000, "events per insert batch")
+	fs.Parse(args)
+
+	if *events <= 0 || *batch <= 0 || *media < 0 {
+		return fmt.Errorf("events and batch must be positive, media must not be negative")
+	}
+
+	loadEnv()
+	config.ConnectDB()
+	db := config.DB
+
+	var coverageTypes int64
+	if err := db.Table("media_coverage_type").Count(&coverageTypes).Error; err != nil {
+		return err
+	}
+	if coverageTypes == 0 && *media > 0 {
+		return fmt.Errorf("media_coverage_type is empty; load init/seed_data.sql first")
+	}
+
+	// Counter triggers recompute per row, which makes bulk inserts quadratic. Skip them when
+	// the database user may (superuser) and refresh the counters once at the end instead.
+	skipTriggers := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec("SET LOCAL session_replication_role = replica").Error
+	}) == nil
+	if !skipTriggers {
+		log.Println("Cannot disable triggers (not a superuser); counter triggers will slow down the seed")
+	}
+
+	run := time.Now().Format("20060102150405")
+	started := time.Now()
+
+	var firstID, lastID uint
+	for offset := 0; offset < *events; offset += *batch {
+		size := *batch
+		if offset+size > *events {
+			size = *events - offset
+		}
+
+		var ids []uint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if skipTriggers {
+				if err := tx.Exec("SET LOCAL session_replication_role = replica").Error; err != nil {
+					return err
+				}
+			}
+			return tx.Raw(seedEventsSQL+" RETURNING id", map[string]interface{}{
+				"run": run, "offset": offset, "size": size, "marker": loadgenMarker,
+			}).Scan(&ids).Error
+		})
+		if err != nil {
+			return fmt.Errorf("inserting events: %w", err)
+		}
+		for _, id := range ids {
+			if firstID == 0 || id < firstID {
+				firstID = id
+			}
+			if id > lastID {
+				lastID = id
+			}
+		}
+		log.Printf("events: %d/%d", offset+size, *events)
+	}
+
+	perEvent := *media / *events
+	if *media > 0 && perEvent == 0 {
+		perEvent = 1
+	}
+	if perEvent > 0 {
+		// Media is inserted per range of event IDs so each statement stays a manageable size
+		step := uint(*batch)
+		var inserted int64
+		for from := firstID; from <= lastID; from += step {
+			var rows int64
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if skipTriggers {
+					if err := tx.Exec("SET LOCAL session_replication_role = replica").Error; err != nil {
+						return err
+					}
+				}
+				result := tx.Exec(seedMediaSQL, map[string]interface{}{
+					"per_event": perEvent, "from": from, "to": from + step - 1, "marker": loadgenMarker,
+				})
+				rows = result.RowsAffected
+				return result.Error
+			})
+			if err != nil {
+				return fmt.Errorf("inserting media: %w", err)
+			}
+			inserted += rows
+			log.Printf("media: %d", inserted)
+		}
+	}
+
+	if skipTriggers {
+		refreshSeedCounters(db, firstID, lastID)
+	}
+
+	log.Printf("Seeded %d events (IDs %d-%d) and %d media per event in %s",
+		*events, firstID, lastID, perEvent, time.Since(started).Round(time.Second))
+	log.Printf("Replay traffic with: loadgen traffic -event-ids %d-%d ...", firstID, lastID)
+	return nil
+}
+
+// refreshSeedCounters recomputes the denormalized counters the skipped triggers would have
+// maintained. Missing counter functions (migration not applied) are not an error.
+func refreshSeedCounters(db *gorm.DB, firstID, lastID uint) {
+	log.Println("Refreshing denormalized counters")
+	if err := db.Exec(
+		"SELECT refresh_event_counters(id) FROM event_details WHERE created_by = ? AND id BETWEEN ? AND ?",
+		loadgenMarker, firstID, lastID,
+	).Error; err != nil {
+		log.Printf("Skipping event counters: %v", err)
+	}
+	if err := db.Exec(
+		"SELECT refresh_branch_counters(branch_id) FROM (SELECT DISTINCT branch_id FROM event_details WHERE created_by = ? AND branch_id IS NOT NULL) b",
+		loadgenMarker,
+	).Error; err != nil {
+		log.Printf("Skipping branch counters: %v", err)
+	}
+}
+
+func runClean(args []string) error {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	fs.Parse(args)
+
+	loadEnv()
+	config.ConnectDB()
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		media := tx.Exec("DELETE FROM event_media WHERE created_by = ?", loadgenMarker)
+		if media.Error != nil {
+			return media.Error
+		}
+
+		events := tx.Exec("DELETE FROM event_details WHERE created_by = ?", loadgenMarker)
+		if events.Error != nil {
+			return events.Error
+		}
+
+		log.Printf("Removed %d events and %d media rows", events.RowsAffected, media.RowsAffected)
+		return nil
+	})
+}
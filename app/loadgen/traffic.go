@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// scenario is one kind of request in the replayed traffic mix
+type scenario struct {
+	name   string
+	weight int
+	// path builds the request path; ok=false skips the scenario (e.g. no IDs configured)
+	path func(r *rand.Rand, ids trafficIDs) (path string, ok bool)
+}
+
+// trafficIDs are the ID ranges requests pick from (usually the ranges printed by seed)
+type trafficIDs struct {
+	events   idRange
+	branches idRange
+	media    idRange
+}
+
+// scenarios approximates the production mix: mostly event pages and galleries, with
+// occasional list and search calls
+var scenarios = []scenario{
+	{"event detail", 4, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return fmt.Sprintf("/api/v1/events/%d", ids.events.pick(r)), !ids.events.empty()
+	}},
+	{"event gallery", 5, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return fmt.Sprintf("/api/v1/event-media/event/%d", ids.events.pick(r)), !ids.events.empty()
+	}},
+	{"media page", 2, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return "/api/v1/event-media?limit=50", true
+	}},
+	{"event list", 1, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return "/api/v1/events?status=" + []string{"complete", "incomplete"}[r.Intn(2)], true
+	}},
+	{"event search", 1, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return fmt.Sprintf("/api/v1/events/search?search=Synthetic+event+%d", r.Intn(1000)), true
+	}},
+	{"branch list", 1, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return "/api/v1/branches", true
+	}},
+	{"branch gallery", 2, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return fmt.Sprintf("/api/v1/branch-media/branch/%d", ids.branches.pick(r)), !ids.branches.empty()
+	}},
+	{"media download", 2, func(r *rand.Rand, ids trafficIDs) (string, bool) {
+		return fmt.Sprintf("/api/v1/files/%d/download", ids.media.pick(r)), !ids.media.empty()
+	}},
+}
+
+// idRange is an inclusive range of IDs parsed from "from-to"
+type idRange struct{ from, to int }
+
+func (r *idRange) String() string {
+	if r.empty() {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", r.from, r.to)
+}
+
+func (r *idRange) Set(value string) error {
+	from, to, found := strings.Cut(value, "-")
+	if !found {
+		to = from
+	}
+	var err error
+	if r.from, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+		return fmt.Errorf("invalid range %q", value)
+	}
+	if r.to, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || r.to < r.from {
+		return fmt.Errorf("invalid range %q", value)
+	}
+	return nil
+}
+
+func (r idRange) empty() bool {
+	return r.to == 0
+}
+
+func (r idRange) pick(rnd *rand.Rand) int {
+	return r.from + rnd.Intn(r.to-r.from+1)
+}
+
+// scenarioStats collects the results of one scenario
+type scenarioStats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    int
+	bytes     int64
+}
+
+func runTraffic(args []string) error {
+	fs := flag.NewFlagSet("traffic", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "base URL of the backend")
+	token := fs.String("token", os.Getenv("LOADGEN_TOKEN"), "access token sent as Bearer (default $LOADGEN_TOKEN)")
+	duration := fs.Duration("duration", time.Minute, "how long to send traffic")
+	concurrency := fs.Int("concurrency", 20, "number of concurrent clients")
+	rps := fs.Int("rps", 0, "overall requests per second limit (0 = as fast as possible)")
+	only := fs.String("only", "", "comma separated scenario names to run (default: the full mix)")
+	var ids trafficIDs
+	fs.Var(&ids.events, "event-ids", "event ID range, e.g. 1001-101000")
+	fs.Var(&ids.branches, "branch-ids", "branch ID range")
+	fs.Var(&ids.media, "media-ids", "event media ID range")
+	fs.Parse(args)
+
+	if *token == "" {
+		return fmt.Errorf("an access token is required (-token or LOADGEN_TOKEN)")
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+
+	mix, err := trafficMix(*only, ids)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	// A shared ticker spreads requests evenly when a rate is set
+	var ticks <-chan time.Time
+	if *rps > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(*rps))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+	base := strings.TrimRight(*target, "/")
+
+	var (
+		mu    sync.Mutex
+		stats = map[string]*scenarioStats{}
+		wg    sync.WaitGroup
+	)
+	started := time.Now()
+	for worker := 0; worker < *concurrency; worker++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				s := mix[rnd.Intn(len(mix))]
+				path, _ := s.path(rnd, ids)
+				status, size, latency, err := doRequest(ctx, client, base+path, *token)
+				if ctx.Err() != nil {
+					// requests cut off by the end of the run are not counted
+					return
+				}
+
+				mu.Lock()
+				st, ok := stats[s.name]
+				if !ok {
+					st = &scenarioStats{statuses: map[int]int{}}
+					stats[s.name] = st
+				}
+				if err != nil {
+					st.errors++
+				} else {
+					st.statuses[status]++
+					st.latencies = append(st.latencies, latency)
+					st.bytes += size
+				}
+				mu.Unlock()
+			}
+		}(time.Now().UnixNano() + int64(worker))
+	}
+	wg.Wait()
+
+	printTrafficReport(stats, time.Since(started))
+	return nil
+}
+
+// trafficMix expands the weighted scenarios into a pick list, dropping scenarios
+// that need IDs which were not given
+func trafficMix(only string, ids trafficIDs) ([]scenario, error) {
+	selected := map[string]bool{}
+	for _, name := range strings.Split(only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	probe := rand.New(rand.NewSource(1))
+	var mix []scenario
+	for _, s := range scenarios {
+		if len(selected) > 0 && !selected[s.name] {
+			continue
+		}
+		if _, ok := s.path(probe, ids); !ok {
+			continue
+		}
+		for i := 0; i < s.weight; i++ {
+			mix = append(mix, s)
+		}
+		delete(selected, s.name)
+	}
+	for name := range selected {
+		return nil, fmt.Errorf("unknown scenario %q or its ID range is missing", name)
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("no scenarios to run")
+	}
+	return mix, nil
+}
+
+func doRequest(ctx context.Context, client *http.Client, url, token string) (int, int64, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer resp.Body.Close()
+	// Latency includes reading the body, as a client rendering the page would
+	size, err := io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, size, time.Since(start), err
+}
+
+func printTrafficReport(stats map[string]*scenarioStats, elapsed time.Duration) {
+	names := make([]string, 0, len(stats))
+	total := 0
+	for name, st := range stats {
+		names = append(names, name)
+		total += len(st.latencies) + st.errors
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\n%d requests in %s (%.1f req/s)\n\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCENARIO\tREQUESTS\tERRORS\tP50\tP95\tP99\tMAX\tAVG KB\tSTATUS")
+	for _, name := range names {
+		st := stats[name]
+		sort.Slice(st.latencies, func(i, j int) bool { return st.latencies[i] < st.latencies[j] })
+
+		codes := make([]int, 0, len(st.statuses))
+		for code := range st.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		statuses := make([]string, 0, len(codes))
+		for _, code := range codes {
+			statuses = append(statuses, fmt.Sprintf("%d:%d", code, st.statuses[code]))
+		}
+
+		avgKB := 0.0
+		if n := len(st.latencies); n > 0 {
+			avgKB = float64(st.bytes) / float64(n) / 1024
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%.1f\t%s\n",
+			name, len(st.latencies)+st.errors, st.errors,
+			percentile(st.latencies, 50), percentile(st.latencies, 95), percentile(st.latencies, 99),
+			percentile(st.latencies, 100), avgKB, strings.Join(statuses, " "))
+	}
+	w.Flush()
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1].Round(time.Millisecond)
+}
@@ -17,23 +17,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	dependencies "github.com/followCode/djjs-event-reporting-backend/Dependencies"
 	"github.com/followCode/djjs-event-reporting-backend/app/api"
 	"github.com/followCode/djjs-event-reporting-backend/app/middleware"
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/followCode/djjs-event-reporting-backend/docs"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"go.uber.org/zap"
 
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
@@ -51,58 +57,43 @@ func main() {
 		filepath.Join("..", "..", ".env"),  // Two levels up (if running from app/main/)
 	}
 	
+	// The configured logger needs LOG_LEVEL and LOG_FORMAT from the configuration, so the
+	// default production logger reports on loading the .env file
+	bootLogger := utils.BaseLogger()
 	var loaded bool
 	for _, envPath := range envPaths {
 		if err := godotenv.Load(envPath); err == nil {
-			log.Printf("Loaded .env file from: %s", envPath)
+			bootLogger.Info("Loaded .env file", zap.String("path", envPath))
 			loaded = true
 			break
 		} else {
 			// Debug: log the error for troubleshooting
-			log.Printf("Failed to load .env from %s: %v", envPath, err)
+			bootLogger.Debug("Failed to load .env file", zap.String("path", envPath), zap.Error(err))
 		}
 	}
 	
 	if !loaded {
-		log.Printf("Warning: .env file not found in any of these locations: %v", envPaths)
-		log.Printf("Current working directory: %s", wd)
-		log.Println("Continuing with system environment variables...")
+		bootLogger.Warn(".env file not found, continuing with system environment variables",
+			zap.Strings("paths", envPaths), zap.String("working_directory", wd))
 	}
 
-	// 1️⃣ Connect to Postgres (legacy GORM connection for existing routes)
-	config.ConnectDB()
-
-	// 1️⃣b Initialize new auth system config (pgx + Redis)
-	if err := config.LoadAuthConfig(); err != nil {
-		log.Fatalf("Failed to load auth config: %v", err)
-	}
-
-	// 2️⃣ Set JWT secret from environment (legacy - also set in internal config)
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET is missing in .env")
+	// 0️⃣ The configuration (environment and CONFIG_FILE, all problems reported at once), the
+	// structured JSON logger, then the dependencies in order: Postgres (legacy GORM
+	// connection, archived year plugin), auth config (pgx), the schema check, Redis, email and
+	// file storage. Postgres, auth and the schema check are required; the others are retried in the background while the API
+	// runs degraded, or initialized lazily when listed in LAZY_DEPENDENCIES.
+	deps, err := dependencies.InitializeDependencies()
+	if err != nil {
+		utils.BaseLogger().Fatal("Failed to initialize dependencies", zap.Error(err))
 	}
-	config.JWTSecret = []byte(jwtSecret)
-
-	// 3️⃣ Initialize S3 (fail fast if S3 is not properly configured)
-	if err := services.InitializeS3(); err != nil {
-		log.Printf("═══════════════════════════════════════════════════════════════")
-		log.Printf("ERROR: Failed to initialize S3: %v", err)
-		log.Printf("ERROR: S3 is required for file uploads and media access")
-		log.Printf("ERROR: Please check your .env file for:")
-		log.Printf("ERROR:   - AWS_ACCESS_KEY_ID")
-		log.Printf("ERROR:   - AWS_SECRET_ACCESS_KEY")
-		log.Printf("ERROR:   - AWS_S3_BUCKET_NAME")
-		log.Printf("ERROR:   - AWS_REGION")
-		log.Printf("ERROR: And verify IAM permissions: s3:ListBucket, s3:GetObject, s3:PutObject, s3:DeleteObject")
-		log.Printf("═══════════════════════════════════════════════════════════════")
-		// In production, you might want to fail fast: log.Fatalf(...)
-		// For now, log error but continue (some features may not work)
+	logger := deps.Logger
+	defer logger.Sync()
+	cfg := deps.Config
+	if cfg.Server.GinMode != "" {
+		// Also applies a GIN_MODE set only in CONFIG_FILE
+		gin.SetMode(cfg.Server.GinMode)
 	}
 
-	// 3️⃣b Startup invariant check: verify no legacy records with NULL s3_key
-	checkLegacyRecords()
-
 	// 4️⃣ Create Gin router
 	r := gin.New()
 	
@@ -119,47 +110,52 @@ func main() {
 		r.SetTrustedProxies([]string{"127.0.0.1", "::1"})
 	}
 	
+	// Assign request IDs and write one structured log entry per request
+	// (registered before recovery so panics are logged as 500s with their request ID)
+	r.Use(middleware.RequestID(), middleware.RequestLogger(logger))
+
+	// 503 until startup has finished; /health, /ready and /metrics answer meanwhile
+	r.Use(middleware.ReadinessGate())
+
+	// Prometheus HTTP latency histograms per route (served on /metrics)
+	r.Use(middleware.Metrics())
+
+	// Brown-out: shed low-priority routes (middleware.Sheddable) under load (SHED_* settings)
+	r.Use(middleware.LoadShedding(cfg.Server.Shedding, logger))
+
+	// Latency budgets of routes annotated with middleware.LatencySLO (violations on /metrics)
+	r.Use(middleware.LatencyBudget(cfg.Server.SLOLogViolations, logger))
+
+	// Daily request counts per user and client (GET /api/admin/usage)
+	r.Use(middleware.APIUsage())
+
+	// Reject writes while the database lacks required migrations (SCHEMA_CHECK=read-only)
+	r.Use(middleware.SchemaReadOnly())
+
 	// Add recovery middleware (gin.Default includes this, but we want to control it)
 	r.Use(gin.Recovery())
 	
-	// Add logger middleware only in debug mode
-	if gin.Mode() == gin.DebugMode {
-		r.Use(gin.Logger())
-	}
 
 	// Add timeout middleware (30 seconds)
 	r.Use(middleware.TimeoutMiddleware(30 * time.Second))
 
 	// Enable CORS for Angular frontend
-	// Get allowed origins from environment - REQUIRED in production
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		if gin.Mode() == gin.DebugMode {
-			// Only allow localhost in debug mode
-			allowedOrigins = "http://localhost:4200,http://localhost:3000"
-			log.Println("Warning: ALLOWED_ORIGINS not set, using localhost defaults (debug mode only)")
-		} else {
-			log.Fatal("ALLOWED_ORIGINS environment variable is required in production")
-		}
-	}
-	
-	origins := []string{}
-	for _, origin := range strings.Split(allowedOrigins, ",") {
-		origins = append(origins, strings.TrimSpace(origin))
-	}
+	// Allowed origins are required in production (ALLOWED_ORIGINS, checked by config.Load);
+	// debug mode falls back to localhost
+	origins := cfg.Server.AllowedOrigins
 	
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     origins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With", "x-request-id", "X-Request-Id"},
-		ExposeHeaders:    []string{"Content-Length", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With", "x-request-id", "X-Request-Id", "X-Client-ID", "Upload-Offset"},
+		ExposeHeaders:    []string{"Content-Length", "Authorization", "X-Request-Id", "Upload-Offset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// Swagger documentation route - only enable if ENABLE_SWAGGER is set to "true"
 	// In production, this should be disabled or protected
-	if os.Getenv("ENABLE_SWAGGER") == "true" || gin.Mode() == gin.DebugMode {
+	if cfg.Server.EnableSwagger || gin.Mode() == gin.DebugMode {
 		// Create a custom handler that intercepts doc.json requests
 		swaggerHandler := func(c *gin.Context) {
 			// Check if this is a request for doc.json
@@ -169,12 +165,12 @@ func main() {
 				// Parse the Swagger JSON
 				var swaggerJSON map[string]interface{}
 				if err := json.Unmarshal([]byte(swaggerDoc), &swaggerJSON); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse swagger doc"})
+					utils.InternalServerError(c, "Failed to parse swagger doc")
 					return
 				}
 				
 				// Get API server URL from environment variable
-				apiServerURL := os.Getenv("API_SERVER_URL")
+				apiServerURL := cfg.Server.APIServerURL
 				
 				// If not set, determine from request (works for both dev and production)
 				if apiServerURL == "" {
@@ -233,34 +229,133 @@ func main() {
 	}
 
 	// 5️⃣ Setup all API routes
-	api.SetupRoutes(r)
+	api.SetupRoutes(r, cfg.Server)
 
 	// 6️⃣ Protected route example
 	r.GET("/protected", middleware.AuthMiddleware(), func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		roleID, _ := c.Get("roleID")
-		c.JSON(200, gin.H{
-			"message": "You are authorized",
-			"userID":  userID,
-			"roleID":  roleID,
+		utils.OK(c, "You are authorized", gin.H{
+			"userID": userID,
+			"roleID": roleID,
 		})
 	})
 
-	// 7️⃣ Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// 7️⃣ Listen first so a port already in use fails the start, then finish the startup while
+	// liveness probes pass and /ready reports "starting"
+	listener, err := net.Listen("tcp", ":"+cfg.Server.Port)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.String("port", cfg.Server.Port), zap.Error(err))
 	}
+	srv := &http.Server{Handler: r, ReadHeaderTimeout: 10 * time.Second}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.Serve(listener)
+	}()
+	logger.Info("Server listening", zap.String("port", cfg.Server.Port))
 
-	log.Printf("Server starting on port %s...", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	finishStartup(logger)
+	middleware.SetReadiness(middleware.ReadinessReady)
+	logger.Info("Server ready")
+
+	select {
+	case err := <-serverErr:
+		logger.Fatal("Failed to run server", zap.Error(err))
+	case <-ctx.Done():
 	}
+	stop()
+	shutdown(srv, cfg.Server, logger)
+}
+
+// finishStartup runs the steps that need the dependencies but not the listener: invariant
+// checks, background work and cache warm-up
+func finishStartup(logger *zap.Logger) {
+	// 3️⃣b Startup invariant check: verify no legacy records with NULL s3_key
+	checkLegacyRecords(logger)
+
+	// 3️⃣c Backfills, schedulers and job workers write to the database, so they stay off
+	// while the schema check has left the API read-only (SCHEMA_CHECK=read-only)
+	if reason := services.SchemaReadOnly(); reason != "" {
+		logger.Warn("Read-only mode, not starting backfills, schedulers or job workers", zap.String("reason", reason))
+	} else {
+		startBackgroundWork()
+	}
+
+	// 3️⃣f Business gauges on /metrics (submissions, pending approvals, jobs)
+	services.StartBusinessMetrics()
+
+	// 3️⃣g Prime caches before taking traffic (WARMUP_ON_STARTUP=false skips it)
+	services.WarmUpOnStartup()
+}
+
+// shutdown stops taking traffic and releases resources in order: /ready fails so load
+// balancers stop routing, in-flight requests drain, running jobs finish (or are queued again),
+// buffered usage counters are written and the connection pools closed. The logger is synced by
+// main. A second signal during the drain kills the process as usual.
+func shutdown(srv *http.Server, server config.ServerConfig, logger *zap.Logger) {
+	middleware.SetReadiness(middleware.ReadinessDraining)
+	logger.Info("Shutting down", zap.Duration("drain_delay", server.DrainDelay), zap.Duration("timeout", server.ShutdownTimeout))
+	time.Sleep(server.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server did not drain in time", zap.Error(err))
+	}
+	if err := services.StopJobWorkers(ctx); err != nil {
+		logger.Warn("Jobs still running at the shutdown timeout were queued again", zap.Error(err))
+	}
+	if err := services.FlushAPIUsage(); err != nil {
+		logger.Error("Failed to flush API usage", zap.Error(err))
+	}
+	if err := config.Close(); err != nil {
+		logger.Error("Failed to close connections", zap.Error(err))
+	}
+	logger.Info("Shutdown complete")
+}
+
+// startBackgroundWork runs the startup backfills and starts the schedulers and workers
+func startBackgroundWork() {
+	// Backfill transliteration keys used by name search
+	services.BackfillNameKeys()
+
+	// Link users, members, volunteers and donors created before the persons table existed
+	services.BackfillPersons()
+
+	// Link special guest appearances created before the guests table existed
+	services.BackfillGuests()
+
+	// Periodic signed manifests of uploaded documents (needs MEDIA_MANIFEST_SIGNING_KEY)
+	services.StartMediaManifestScheduler()
+
+	// Daily email digest of pending approvals for reviewers (APPROVAL_DIGEST_HOUR)
+	services.StartApprovalDigestScheduler()
+
+	// Daily snapshots of branch statistics for ?as_of reporting (STATS_SNAPSHOT_HOUR)
+	services.StartStatsSnapshotScheduler()
+
+	// Storage usage per branch for quotas and the admin report (STORAGE_USAGE_INTERVAL)
+	services.StartStorageUsageScheduler()
+
+	// Delete drafts not saved for EVENT_DRAFT_RETENTION_DAYS
+	services.StartDraftCleanup()
+
+	// Abort resumable uploads whose session expired (UPLOAD_SESSION_TTL)
+	services.StartUploadSessionCleanup()
+
+	// Persist API usage counters every minute
+	services.StartAPIUsageFlusher()
+
+	// Background job workers (thumbnails, reports, imports, emails, storage cleanup)
+	services.StartJobWorkers()
 }
 
 // checkLegacyRecords performs startup invariant check for NULL s3_key records
 // Logs ERROR and WARN loudly if legacy records exist
-func checkLegacyRecords() {
+func checkLegacyRecords(logger *zap.Logger) {
 	var eventMediaCount int64
 	var branchMediaCount int64
 
@@ -268,30 +363,26 @@ func checkLegacyRecords() {
 	if err := config.DB.Model(&models.EventMedia{}).
 		Where("s3_key IS NULL OR s3_key = ''").
 		Count(&eventMediaCount).Error; err != nil {
-		log.Printf("WARNING: Failed to check event_media for NULL s3_key: %v", err)
+		logger.Warn("Failed to check media records for NULL s3_key", zap.String("table", "event_media"), zap.Error(err))
 	} else if eventMediaCount > 0 {
-		log.Printf("═══════════════════════════════════════════════════════════════")
-		log.Printf("ERROR: Found %d EventMedia records with NULL or empty s3_key", eventMediaCount)
-		log.Printf("ERROR: These records will cause HTTP 500 errors when accessed")
-		log.Printf("ERROR: Run migration: init/migrations/backfill_s3_key_from_file_url.sql")
-		log.Printf("═══════════════════════════════════════════════════════════════")
+		logger.Error("Media records with NULL or empty s3_key will cause HTTP 500 errors when accessed; "+
+			"migration 00002_media_s3_keys.sql backfills them from file_url, fix the rest by hand",
+			zap.String("table", "event_media"), zap.Int64("count", eventMediaCount))
 	}
 
 	// Count BranchMedia records with NULL s3_key
 	if err := config.DB.Model(&models.BranchMedia{}).
 		Where("s3_key IS NULL OR s3_key = ''").
 		Count(&branchMediaCount).Error; err != nil {
-		log.Printf("WARNING: Failed to check branch_media for NULL s3_key: %v", err)
+		logger.Warn("Failed to check media records for NULL s3_key", zap.String("table", "branch_media"), zap.Error(err))
 	} else if branchMediaCount > 0 {
-		log.Printf("═══════════════════════════════════════════════════════════════")
-		log.Printf("ERROR: Found %d BranchMedia records with NULL or empty s3_key", branchMediaCount)
-		log.Printf("ERROR: These records will cause HTTP 500 errors when accessed")
-		log.Printf("ERROR: Run migration: init/migrations/add_branch_media_fields.sql")
-		log.Printf("═══════════════════════════════════════════════════════════════")
+		logger.Error("Media records with NULL or empty s3_key will cause HTTP 500 errors when accessed; "+
+			"migration 00002_media_s3_keys.sql backfills them from file_url, fix the rest by hand",
+			zap.String("table", "branch_media"), zap.Int64("count", branchMediaCount))
 	}
 
 	if eventMediaCount == 0 && branchMediaCount == 0 {
-		log.Println("✓ Startup check passed: All media records have s3_key populated")
+		logger.Info("Startup check passed: all media records have s3_key populated")
 	}
 }
 
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Business metrics for alerting on anomalies such as "no events submitted in 24h". The gauges
+// are refreshed from the database by services.StartBusinessMetrics; the counters are
+// incremented where the failure happens.
+var (
+	EventsSubmittedToday = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "events_submitted_today",
+		Help:      "Events submitted for approval since midnight (server time).",
+	})
+
+	EventsSubmitted24h = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "events_submitted_24h",
+		Help:      "Events submitted for approval in the last 24 hours.",
+	})
+
+	// LastEventSubmitted is 0 until an event has been submitted
+	LastEventSubmitted = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "last_event_submitted_timestamp_seconds",
+		Help:      "Unix time of the most recent event submission.",
+	})
+
+	PendingApprovals = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "pending_approvals",
+		Help:      "Events waiting for a reviewer by approval status (submitted, under_review).",
+	}, []string{"status"})
+
+	// Jobs counts queued and running jobs; failed jobs are counted by JobsFailed when they fail
+	Jobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "current",
+		Help:      "Background jobs by type and status (queued or running).",
+	}, []string{"type", "status"})
+
+	JobsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "failed_total",
+		Help:      "Background jobs that failed for good (retries exhausted or permanent error) by type.",
+	}, []string{"type"})
+
+	UploadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "upload",
+		Name:      "failures_total",
+		Help:      "File uploads to storage that failed by file type (image, video, audio, file).",
+	}, []string{"file_type"})
+
+	WebhookDeliveryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "delivery_failures_total",
+		Help:      "Webhook deliveries that failed (transport error or non-2xx response) by event type.",
+	}, []string{"event_type"})
+
+	// BusinessMetricsRefreshErrors counts refreshes of the business gauges that failed; the
+	// gauges keep their previous values meanwhile
+	BusinessMetricsRefreshErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "business",
+		Name:      "refresh_errors_total",
+		Help:      "Refreshes of the business gauges from the database that failed.",
+	})
+)
@@ -0,0 +1,108 @@
+// Package metrics holds the Prometheus collectors exposed on /metrics: HTTP latency per route,
+// database query timings (GORM plugin), S3 operations (AWS SDK middleware), upload sizes and
+// business metrics (business.go).
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "djjs"
+
+var (
+	// HTTPRequestDuration is labelled by route template (e.g. /api/events/:id), not the raw
+	// path, so IDs do not explode the series count
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	// HTTPSLORequests counts requests to routes with a latency budget (middleware.LatencySLO)
+	// by result: "ok" or "violation"
+	HTTPSLORequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "slo_requests_total",
+		Help:      "Requests to routes with a latency SLO by method, route and result (ok or violation).",
+	}, []string{"method", "route", "result"})
+
+	HTTPSLOBudget = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "slo_budget_seconds",
+		Help:      "Latency budget of routes with a latency SLO.",
+	}, []string{"method", "route"})
+
+	// HTTPShedRequests counts requests to low-priority routes answered 503 during brown-out
+	// (middleware.Sheddable)
+	HTTPShedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "shed_requests_total",
+		Help:      "Requests to low-priority routes rejected during brown-out by method and route.",
+	}, []string{"method", "route"})
+
+	BrownOuts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "brown_outs_total",
+		Help:      "Times brown-out mode was switched on by reason (in_flight or cpu).",
+	}, []string{"reason"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "GORM statement latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "GORM statements that failed (record not found is not counted).",
+	}, []string{"operation", "table"})
+
+	S3Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "operations_total",
+		Help:      "S3 API calls by operation and result (ok or error). Retries count as separate calls.",
+	}, []string{"operation", "result"})
+
+	S3OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "operation_duration_seconds",
+		Help:      "S3 API call latency by operation.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+
+	UploadSizeBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "upload",
+		Name:      "size_bytes",
+		Help:      "Size of files uploaded to S3 by file type (image, video, audio, file).",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 4, 9), // 16KB .. 1GB
+	}, []string{"file_type"})
+)
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
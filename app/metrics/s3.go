@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// S3APIOption instruments every S3 call made with the client. Add it to aws.Config.APIOptions.
+// It sits in the deserialize step, which presigning never reaches, so presigned URLs are not
+// counted as GetObject calls.
+func S3APIOption(stack *middleware.Stack) error {
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("Metrics", observeS3),
+		middleware.Before)
+}
+
+func observeS3(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	out, metadata, err := next.HandleDeserialize(ctx, in)
+
+	operation := awsmiddleware.GetOperationName(ctx)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	S3Operations.WithLabelValues(operation, result).Inc()
+	S3OperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	return out, metadata, err
+}
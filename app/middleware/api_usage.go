@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIDHeader lets integrations identify themselves in the API usage report
+const ClientIDHeader = "X-Client-ID"
+
+const maxClientIDLength = 64
+
+// APIUsage counts every request per day, user, client and route for GET /api/admin/usage.
+// The user is whoever the route's auth middleware authenticated, so it must run before them.
+func APIUsage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		switch route {
+		case "":
+			route = "unmatched"
+		case "/metrics", "/health", "/api/health":
+			// Scrapers and probes are not API clients
+			return
+		}
+
+		var userID uint
+		switch id := c.Value("userID").(type) {
+		case uint:
+			userID = id
+		case int64:
+			userID = uint(id)
+		}
+
+		services.RecordAPIUsage(userID, apiClientID(c), c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// apiClientID returns the X-Client-ID header, or the product name of the User-Agent
+// (e.g. "okhttp" for "okhttp/4.12.0") for clients that do not send one
+func apiClientID(c *gin.Context) string {
+	client := strings.TrimSpace(c.GetHeader(ClientIDHeader))
+	if client == "" {
+		client, _, _ = strings.Cut(strings.TrimSpace(c.Request.UserAgent()), "/")
+		client, _, _ = strings.Cut(client, " ")
+	}
+	if len(client) > maxClientIDLength {
+		client = client[:maxClientIDLength]
+	}
+	return strings.ToValidUTF8(client, "")
+}
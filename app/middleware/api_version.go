@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The API is mounted twice: under APIV1Prefix, and under the unversioned LegacyAPIPrefix for
+// clients that predate versioning (the mobile app). Both serve the same v1 handlers; the legacy
+// mount also answers with the LegacyAPI deprecation headers.
+const (
+	APIV1Prefix     = "/api/v1"
+	LegacyAPIPrefix = "/api"
+)
+
+// UnversionedRoute maps a /api/v1 route to its legacy /api path, so checks keyed on the route
+// (rate limits, the password change exemption) treat both mounts as the same route
+func UnversionedRoute(route string) string {
+	if strings.HasPrefix(route, APIV1Prefix+"/") {
+		return LegacyAPIPrefix + strings.TrimPrefix(route, APIV1Prefix)
+	}
+	return route
+}
+
+// LegacyAPI marks responses of the unversioned routes as deprecated: "Deprecation: true" and a
+// Link to the same path under /api/v1. With a sunset date (API_LEGACY_SUNSET=YYYY-MM-DD) it also
+// sends a Sunset header announcing when the unversioned paths go away. Legacy traffic per route
+// shows up in GET /api/v1/admin/usage, which is how to tell when clients have moved.
+func LegacyAPI(sunsetDate time.Time) gin.HandlerFunc {
+	sunset := ""
+	if !sunsetDate.IsZero() {
+		sunset = sunsetDate.UTC().Format(http.TimeFormat)
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", "true")
+		if sunset != "" {
+			header.Set("Sunset", sunset)
+		}
+		successor := APIV1Prefix + strings.TrimPrefix(c.Request.URL.Path, LegacyAPIPrefix)
+		header.Add("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
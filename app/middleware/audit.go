@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// auditResponseWriter captures the response body so the ID of a created entity can be read
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// AuditTrail records create/update/delete operations on an entity in the audit log.
+// idParam names the path parameter holding the entity ID ("" for create routes, where the
+// ID is read from the response body). The entity is loaded before and after the handler
+// runs and the field-level diff is stored together with the actor from the JWT.
+// Must be registered after AuthMiddleware.
+func AuditTrail(entityType, idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var entityID uint
+		if idParam != "" {
+			if id, err := strconv.ParseUint(c.Param(idParam), 10, 64); err == nil {
+				entityID = uint(id)
+			}
+		}
+
+		before := services.LoadAuditSnapshot(entityType, entityID)
+
+		var captured *bytes.Buffer
+		if entityID == 0 {
+			captured = &bytes.Buffer{}
+			c.Writer = &auditResponseWriter{ResponseWriter: c.Writer, body: captured}
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+
+		action := models.AuditActionUpdate
+		switch {
+		case c.Request.Method == http.MethodDelete:
+			action = models.AuditActionDelete
+		case entityID == 0:
+			action = models.AuditActionCreate
+			entityID = extractCreatedID(captured.Bytes())
+		}
+		if entityID == 0 {
+			return
+		}
+
+		var after map[string]interface{}
+		if action != models.AuditActionDelete {
+			after = services.LoadAuditSnapshot(entityType, entityID)
+		}
+
+		changes := services.DiffAuditSnapshots(before, after)
+		if action == models.AuditActionUpdate && len(changes) == 0 {
+			return
+		}
+
+		entry := &models.AuditLog{
+			EntityType: entityType,
+			EntityID:   entityID,
+			Action:     action,
+			Changes:    changes,
+			IP:         GetClientIP(c),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+		}
+		if userID, ok := CurrentUserID(c); ok {
+			entry.ActorID = &userID
+		}
+		if roleID, ok := CurrentRoleID(c); ok {
+			entry.ActorRoleID = &roleID
+		}
+
+		if err := services.RecordAuditLog(entry); err != nil {
+			utils.Logger(c.Request.Context()).Warn("Failed to write audit log",
+				zap.String("entity_type", entityType), zap.Uint("entity_id", entityID), zap.Error(err))
+		}
+	}
+}
+
+// extractCreatedID finds the new entity's ID in the data of a create response.
+// Handles {"id": 1}, {"<entity>": {"id": 1}} and {"media_id": 1} shapes.
+func extractCreatedID(body []byte) uint {
+	var envelope utils.Response
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return 0
+	}
+	payload, ok := envelope.Data.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+
+	if id := numericField(payload, "id", "media_id"); id > 0 {
+		return id
+	}
+	for _, value := range payload {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if id := numericField(nested, "id", "media_id"); id > 0 {
+				return id
+			}
+		}
+	}
+	return 0
+}
+
+func numericField(m map[string]interface{}, keys ...string) uint {
+	for _, key := range keys {
+		if v, ok := m[key].(float64); ok && v > 0 {
+			return uint(v)
+		}
+	}
+	return 0
+}
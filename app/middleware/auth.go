@@ -1,10 +1,10 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,7 +19,7 @@ func AuthRequired() gin.HandlerFunc {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header missing"})
+			utils.Unauthorized(c, "authorization header missing")
 			c.Abort()
 			return
 		}
@@ -27,7 +27,7 @@ func AuthRequired() gin.HandlerFunc {
 		// Extract Bearer token
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+			utils.Unauthorized(c, "invalid authorization header format")
 			c.Abort()
 			return
 		}
@@ -37,7 +37,7 @@ func AuthRequired() gin.HandlerFunc {
 		// Verify and parse token
 		claims, err := auth.VerifyAccessToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			utils.Unauthorized(c, "invalid token")
 			c.Abort()
 			return
 		}
@@ -45,7 +45,7 @@ func AuthRequired() gin.HandlerFunc {
 		// Extract user ID
 		userID, err := auth.ParseUserIDFromToken(claims)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			utils.Unauthorized(c, "invalid token claims")
 			c.Abort()
 			return
 		}
@@ -53,7 +53,20 @@ func AuthRequired() gin.HandlerFunc {
 		// Extract session ID
 		sessionID, err := auth.ParseSessionIDFromToken(claims)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			utils.Unauthorized(c, "invalid token claims")
+			c.Abort()
+			return
+		}
+
+		// Access tokens of revoked sessions (logout, forced logout) are rejected
+		revoked, err := auth.IsSessionRevoked(c.Request.Context(), sessionID)
+		if err != nil {
+			utils.InternalServerError(c, "failed to check session")
+			c.Abort()
+			return
+		}
+		if revoked {
+			utils.Unauthorized(c, "session revoked")
 			c.Abort()
 			return
 		}
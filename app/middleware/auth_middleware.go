@@ -1,22 +1,25 @@
 package middleware
 
 import (
-    "log"
     "net/http"
     "strconv"
     "strings"
 
     "github.com/followCode/djjs-event-reporting-backend/config"
     "github.com/followCode/djjs-event-reporting-backend/app/models"
+    "github.com/followCode/djjs-event-reporting-backend/app/services"
+    "github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+    "github.com/followCode/djjs-event-reporting-backend/app/utils"
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
+    "go.uber.org/zap"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header missing"})
+            utils.Unauthorized(c, "authorization header missing")
             c.Abort()
             return
         }
@@ -24,18 +27,18 @@ func AuthMiddleware() gin.HandlerFunc {
         tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
         token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-            return config.JWTSecret, nil // dynamic secret from config
+            return config.JWTVerificationKey(), nil // current and, while rotating, previous secret
         })
 
         if err != nil || !token.Valid {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+            utils.Unauthorized(c, "invalid token")
             c.Abort()
             return
         }
 
         claims, ok := token.Claims.(jwt.MapClaims)
         if !ok {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+            utils.Unauthorized(c, "invalid token claims")
             c.Abort()
             return
         }
@@ -45,21 +48,21 @@ func AuthMiddleware() gin.HandlerFunc {
         if userIDFloat, ok := claims["user_id"].(float64); ok {
             // Old token format - user_id as float64
             userID = uint(userIDFloat)
-            log.Printf("[AuthMiddleware] Using old token format, user_id: %d", userID)
+            utils.Logger(c.Request.Context()).Debug("Using old token format", zap.Uint("user_id", userID))
         } else if sub, ok := claims["sub"].(string); ok {
             // New token format - sub contains user ID as string
             userIDInt, err := strconv.ParseUint(sub, 10, 32)
             if err != nil {
-                log.Printf("[AuthMiddleware] Failed to parse sub claim '%s': %v", sub, err)
-                c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user_id in token"})
+                utils.Logger(c.Request.Context()).Warn("Failed to parse sub claim", zap.String("sub", sub), zap.Error(err))
+                utils.Unauthorized(c, "invalid user_id in token")
                 c.Abort()
                 return
             }
             userID = uint(userIDInt)
-            log.Printf("[AuthMiddleware] Using new token format, sub: %s, user_id: %d", sub, userID)
+            utils.Logger(c.Request.Context()).Debug("Using new token format", zap.Uint("user_id", userID))
         } else {
-            log.Printf("[AuthMiddleware] Token missing both user_id and sub claims")
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user_id in token"})
+            utils.Logger(c.Request.Context()).Warn("Token missing both user_id and sub claims")
+            utils.Unauthorized(c, "invalid user_id in token")
             c.Abort()
             return
         }
@@ -68,7 +71,7 @@ func AuthMiddleware() gin.HandlerFunc {
         var user models.User
         err = config.DB.First(&user, userID).Error
         if err != nil {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+            utils.Unauthorized(c, "user not found")
             c.Abort()
             return
         }
@@ -80,12 +83,134 @@ func AuthMiddleware() gin.HandlerFunc {
             // Old system: token must match database
             // But only enforce if user.Token is actually set (old system)
             // New system doesn't set user.Token, so we skip this check
-            log.Printf("[AuthMiddleware] Token mismatch for user %d (old system check)", userID)
+            utils.Logger(c.Request.Context()).Debug("Token mismatch (old system check)", zap.Uint("user_id", userID))
+        }
+
+        // Tokens of revoked sessions are rejected (tokens without "sid" predate sessions)
+        if sessionID, ok := claims["sid"].(string); ok && sessionID != "" {
+            revoked, err := auth.IsSessionRevoked(c.Request.Context(), sessionID)
+            if err != nil {
+                utils.InternalServerError(c, "failed to check session")
+                c.Abort()
+                return
+            }
+            if revoked {
+                utils.Unauthorized(c, "session revoked")
+                c.Abort()
+                return
+            }
+        }
+
+        // Deactivated accounts and tokens issued before a forced logout are rejected
+        if user.DisabledAt != nil {
+            utils.Unauthorized(c, "account deactivated")
+            c.Abort()
+            return
+        }
+        if user.TokensRevokedAt != nil {
+            issuedAt, ok := claims["iat"].(float64)
+            if !ok || int64(issuedAt) <= user.TokensRevokedAt.Unix() {
+                utils.Unauthorized(c, "token revoked")
+                c.Abort()
+                return
+            }
+        }
+
+        // Temporary (admin issued) and expired passwords must be changed before anything
+        // else; only the user's own change-password route stays reachable
+        if auth.PasswordChangeRequired(user.MustChangePassword, user.PasswordChangedAt) && !isOwnPasswordChange(c, userID) {
+            utils.ErrorCodeResponse(c, http.StatusForbidden, utils.CodePasswordChangeRequired, "password change required", nil)
+            c.Abort()
+            return
+        }
+
+        // Admins and managers must enroll in two-factor authentication when the policy flag is
+        // on; the enrollment routes (/api/auth/2fa/*) use AuthRequired and stay reachable
+        if user.TOTPEnabledAt == nil && services.TwoFactorRequired(user.RoleID) {
+            utils.ErrorCodeResponse(c, http.StatusForbidden, utils.CodeTwoFactorSetupRequired, "two-factor authentication setup required", nil)
+            c.Abort()
+            return
         }
 
         // Pass user info to handlers
         c.Set("userID", userID)
         c.Set("roleID", user.RoleID)
+        c.Set("branchID", user.BranchID)
+        c.Set(contextTokenClaimsKey, claims)
         c.Next()
     }
 }
+
+// passwordChangeRoute is the AuthMiddleware protected route users with a temporary or expired
+// password may still call (POST /api/auth/change-password uses AuthRequired and is not affected)
+const passwordChangeRoute = "/api/users/:id/change-password"
+
+// isOwnPasswordChange reports whether the request changes the authenticated user's own password
+func isOwnPasswordChange(c *gin.Context, userID uint) bool {
+    return UnversionedRoute(c.FullPath()) == passwordChangeRoute && c.Param("id") == strconv.FormatUint(uint64(userID), 10)
+}
+
+// CurrentUserID returns the authenticated user ID set by AuthMiddleware
+func CurrentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return 0, false
+	}
+	id, ok := userID.(uint)
+	return id, ok
+}
+
+// CurrentRoleID returns the authenticated user's role ID set by AuthMiddleware
+func CurrentRoleID(c *gin.Context) (uint, bool) {
+	roleID, exists := c.Get("roleID")
+	if !exists {
+		return 0, false
+	}
+	id, ok := roleID.(uint)
+	return id, ok
+}
+
+const contextTokenClaimsKey = "tokenClaims"
+
+// CurrentTokenClaims returns the claims of the access token AuthMiddleware accepted
+func CurrentTokenClaims(c *gin.Context) (jwt.MapClaims, bool) {
+	claims, exists := c.Get(contextTokenClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	return mapClaims, ok
+}
+
+// RequireRoles allows the request only if the authenticated user has one of the given roles.
+// Must be registered after AuthMiddleware.
+func RequireRoles(roles ...uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleID, ok := CurrentRoleID(c)
+		if !ok {
+			utils.Unauthorized(c, "user role not found")
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if role == roleID {
+				c.Next()
+				return
+			}
+		}
+
+		utils.Forbidden(c, "insufficient permissions")
+		c.Abort()
+	}
+}
+
+// IncludeDeleted reports whether soft-deleted records were requested with ?include_deleted=true.
+// Only admins may see deleted records; the flag is ignored for everyone else.
+func IncludeDeleted(c *gin.Context) bool {
+	if c.Query("include_deleted") != "true" {
+		return false
+	}
+	roleID, ok := CurrentRoleID(c)
+	return ok && roleID == models.RoleAdmin
+}
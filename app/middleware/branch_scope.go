@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const contextBranchScopeKey = "branchScope"
+
+// CurrentBranchScope returns the branches the authenticated user may access (nil for admins,
+// see services.BranchScopeFor). It is resolved from the user's branch set by AuthMiddleware,
+// so a branch reassignment applies from the next request, and cached for the request.
+func CurrentBranchScope(c *gin.Context) (*services.BranchScope, error) {
+	if cached, ok := c.Get(contextBranchScopeKey); ok {
+		return cached.(*services.BranchScope), nil
+	}
+	roleID, ok := CurrentRoleID(c)
+	if !ok {
+		// Not authenticated through AuthMiddleware: nothing is visible
+		return &services.BranchScope{}, nil
+	}
+	var branchID *uint
+	if value, exists := c.Get("branchID"); exists {
+		branchID, _ = value.(*uint)
+	}
+	scope, err := services.BranchScopeFor(roleID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(contextBranchScopeKey, scope)
+	return scope, nil
+}
+
+// RequireBranchScope rejects requests for a record outside the user's branches with 403.
+// param names the route parameter holding the ID of a record of the given kind
+// (services.ScopeEvent, ...); routes without the parameter pass, so it can be registered on a
+// whole route group. Must be registered after AuthMiddleware.
+func RequireBranchScope(kind, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.Param(param)
+		if value == "" {
+			c.Next()
+			return
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			// Let the handler report the malformed ID
+			c.Next()
+			return
+		}
+
+		scope, err := CurrentBranchScope(c)
+		if err == nil {
+			err = scope.Check(kind, uint(id))
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrOutOfBranchScope) {
+				utils.Forbidden(c, err.Error())
+			} else {
+				utils.InternalServerError(c, "failed to check branch access")
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -3,8 +3,8 @@ package middleware
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"net/http"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
@@ -26,7 +26,7 @@ func CSRFProtection() gin.HandlerFunc {
 		// Get CSRF token from cookie
 		cookie, err := c.Cookie(csrfCookieName)
 		if err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "csrf token missing"})
+			utils.Forbidden(c, "csrf token missing")
 			c.Abort()
 			return
 		}
@@ -34,14 +34,14 @@ func CSRFProtection() gin.HandlerFunc {
 		// Get CSRF token from header
 		headerToken := c.GetHeader(csrfHeaderName)
 		if headerToken == "" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "X-CSRF-Token header missing"})
+			utils.Forbidden(c, "X-CSRF-Token header missing")
 			c.Abort()
 			return
 		}
 
 		// Compare tokens (constant-time comparison)
 		if !constantTimeEqual(cookie, headerToken) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid csrf token"})
+			utils.Forbidden(c, "invalid csrf token")
 			c.Abort()
 			return
 		}
@@ -49,7 +49,7 @@ func CSRFProtection() gin.HandlerFunc {
 		// Validate Origin header
 		origin := c.GetHeader("Origin")
 		if origin != "" && origin != config.FrontendOrigin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid origin"})
+			utils.Forbidden(c, "invalid origin")
 			c.Abort()
 			return
 		}
@@ -130,7 +130,7 @@ func OptionalCSRFProtection() gin.HandlerFunc {
 
 		// Compare tokens (constant-time comparison)
 		if !constantTimeEqual(cookie, headerToken) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid csrf token"})
+			utils.Forbidden(c, "invalid csrf token")
 			c.Abort()
 			return
 		}
@@ -138,7 +138,7 @@ func OptionalCSRFProtection() gin.HandlerFunc {
 		// Validate Origin header if present
 		origin := c.GetHeader("Origin")
 		if origin != "" && origin != config.FrontendOrigin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid origin"})
+			utils.Forbidden(c, "invalid origin")
 			c.Abort()
 			return
 		}
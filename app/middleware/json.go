@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -36,7 +37,7 @@ func StrictJSONDecoder(maxSize int64) gin.HandlerFunc {
 		// Read the body
 		bodyBytes, err := io.ReadAll(c.Request.Body)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "details": err.Error()})
+			utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeBadRequest, "invalid request body", err.Error())
 			c.Abort()
 			return
 		}
@@ -52,7 +53,7 @@ func StrictJSONDecoder(maxSize int64) gin.HandlerFunc {
 			var body map[string]interface{}
 			if err := decoder.Decode(&body); err != nil {
 				if err != io.EOF {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json or unknown fields", "details": err.Error()})
+					utils.ErrorCodeResponse(c, http.StatusBadRequest, utils.CodeBadRequest, "invalid json or unknown fields", err.Error())
 					c.Abort()
 					return
 				}
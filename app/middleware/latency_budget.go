@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// latencySLOKey holds the budget set by LatencySLO in the gin context
+const latencySLOKey = "latencySLO"
+
+// LatencySLO annotates a route with its latency budget, e.g.
+//
+//	GET("", middleware.LatencySLO(500*time.Millisecond), handlers.GetAllChildBranchesHandler)
+//
+// LatencyBudget measures the whole request against it. Annotations show up in GET /api/routes.
+func LatencySLO(budget time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(latencySLOKey, budget)
+		c.Next()
+	}
+}
+
+// LatencyBudget checks requests to routes annotated with LatencySLO against their budget and
+// counts them in metrics.HTTPSLORequests (result "ok" or "violation"). With
+// SLO_LOG_VIOLATIONS=true every violation is also logged as a warning with the request ID.
+// Register it early so the measured time includes authentication and the other middleware.
+func LatencyBudget(logViolations bool, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		value, ok := c.Get(latencySLOKey)
+		if !ok {
+			return
+		}
+		budget := value.(time.Duration)
+		latency := time.Since(start)
+		route := c.FullPath()
+		metrics.HTTPSLOBudget.WithLabelValues(c.Request.Method, route).Set(budget.Seconds())
+
+		result := "ok"
+		if latency > budget {
+			result = "violation"
+		}
+		metrics.HTTPSLORequests.WithLabelValues(c.Request.Method, route, result).Inc()
+
+		if result == "violation" && logViolations {
+			fields := []zap.Field{
+				zap.String("request_id", c.GetString("requestID")),
+				zap.String("method", c.Request.Method),
+				zap.String("route", route),
+				zap.Int("status", c.Writer.Status()),
+				zap.Duration("latency", latency),
+				zap.Duration("budget", budget),
+			}
+			if userID, ok := CurrentUserID(c); ok {
+				fields = append(fields, zap.Uint("user_id", userID))
+			}
+			logger.Warn("latency budget exceeded", fields...)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Brown-out mode: under load the routes annotated with Sheddable (exports, statistics,
+// the public website API) answer 503 so data entry stays responsive.
+//
+//	SHED_MAX_IN_FLIGHT   requests in flight that trigger it (default 200, 0 = off)
+//	SHED_CPU_THRESHOLD   CPU utilisation of the process, 0-1, that triggers it (default off)
+//	SHED_COOLDOWN        how long it lasts after the last overload signal (default 30s)
+//
+// (see config.SheddingConfig)
+var (
+	inFlight      atomic.Int64
+	brownOutUntil atomic.Int64  // unix nanoseconds
+	cpuUsage      atomic.Uint64 // fraction * 1e6
+
+	shedOnce        sync.Once
+	shedMaxInFlight int64
+	shedCPU         float64
+	shedCooldown    time.Duration
+)
+
+// cpuSampleInterval is how often the process CPU utilisation is sampled
+const cpuSampleInterval = time.Second
+
+// LoadShedding counts the requests in flight and switches brown-out mode on when there are
+// more than SHED_MAX_IN_FLIGHT or the CPU is above SHED_CPU_THRESHOLD. Register it early so
+// every request is counted.
+func LoadShedding(cfg config.SheddingConfig, logger *zap.Logger) gin.HandlerFunc {
+	shedOnce.Do(func() {
+		shedMaxInFlight = cfg.MaxInFlight
+		shedCooldown = cfg.Cooldown
+		if shedCPU = cfg.CPUThreshold; shedCPU > 0 {
+			go sampleCPU(logger)
+		}
+	})
+	return func(c *gin.Context) {
+		if n := inFlight.Add(1); shedMaxInFlight > 0 && n > shedMaxInFlight {
+			startBrownOut(logger, "in_flight")
+		}
+		defer inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// Sheddable marks a low-priority route, e.g.
+//
+//	GET("/export", middleware.Sheddable(), handlers.ExportEventsHandler)
+//
+// During brown-out it answers 503 with Retry-After instead of running the handler.
+func Sheddable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		until := brownOutUntil.Load()
+		if until == 0 || time.Now().UnixNano() >= until {
+			c.Next()
+			return
+		}
+		metrics.HTTPShedRequests.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+		retryAfter := time.Until(time.Unix(0, until)).Round(time.Second)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		utils.ErrorCodeResponse(c, http.StatusServiceUnavailable, utils.CodeServiceUnavailable,
+			"the server is busy, this report is temporarily unavailable; please retry later", nil)
+		c.Abort()
+	}
+}
+
+// BrownOut reports whether low-priority routes are being shed
+func BrownOut() bool {
+	return time.Now().UnixNano() < brownOutUntil.Load()
+}
+
+// startBrownOut switches brown-out mode on, or extends it, for the cooldown
+func startBrownOut(logger *zap.Logger, reason string) {
+	now := time.Now()
+	previous := brownOutUntil.Swap(now.Add(shedCooldown).UnixNano())
+	if previous < now.UnixNano() {
+		metrics.BrownOuts.WithLabelValues(reason).Inc()
+		logger.Warn("Brown-out: shedding low-priority routes",
+			zap.String("reason", reason),
+			zap.Int64("in_flight", inFlight.Load()),
+			zap.Float64("cpu", float64(cpuUsage.Load())/1e6))
+	}
+}
+
+// sampleCPU measures the CPU utilisation of the process (share of GOMAXPROCS cores) and
+// starts a brown-out when it is above the threshold. It reads /proc/self/stat, so CPU based
+// shedding is Linux only.
+func sampleCPU(logger *zap.Logger) {
+	last, err := processCPUTime()
+	if err != nil {
+		logger.Warn("Cannot read the process CPU time, CPU based shedding disabled", zap.Error(err))
+		return
+	}
+	lastAt := time.Now()
+
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		current, err := processCPUTime()
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		capacity := now.Sub(lastAt).Seconds() * float64(runtime.GOMAXPROCS(0))
+		if capacity > 0 {
+			usage := (current - last).Seconds() / capacity
+			cpuUsage.Store(uint64(usage * 1e6))
+			if usage >= shedCPU {
+				startBrownOut(logger, "cpu")
+			}
+		}
+		last, lastAt = current, now
+	}
+}
+
+// processCPUTime returns the user and system CPU time of the process from /proc/self/stat
+func processCPUTime() (time.Duration, error) {
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, err
+	}
+	// The command name (field 2) may contain spaces; utime and stime are fields 14 and 15
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, errors.New("unexpected /proc/self/stat format")
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 13 {
+		return 0, errors.New("unexpected /proc/self/stat format")
+	}
+	var ticks int64
+	for _, field := range fields[11:13] {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		ticks += n
+	}
+	// USER_HZ is 100 on Linux
+	return time.Duration(ticks) * 10 * time.Millisecond, nil
+}
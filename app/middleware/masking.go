@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// piiRoles may see unmasked personal data
+var piiRoles = map[uint]bool{
+	models.RoleAdmin:   true,
+	models.RoleManager: true,
+}
+
+// CanViewPII reports whether a role may see contact numbers, donation amounts and dates of birth
+func CanViewPII(roleID uint) bool {
+	return piiRoles[roleID]
+}
+
+// maskingResponseWriter buffers JSON responses so they can be masked before being sent.
+// Non-JSON responses (files, PDFs, redirects) are passed through untouched.
+type maskingResponseWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	decided     bool
+	passthrough bool
+}
+
+func (w *maskingResponseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.passthrough = !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *maskingResponseWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+func (w *maskingResponseWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+// MaskSensitiveFields masks personal data (see utils.SensitiveFieldMaskers) in JSON
+// responses for callers whose role is not allowed to view it. Registered on the
+// API groups so individual handlers don't need to know about masking.
+func MaskSensitiveFields() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Users always see their own profile unmasked
+		if strings.HasPrefix(UnversionedRoute(c.Request.URL.Path), "/api/auth/") {
+			c.Next()
+			return
+		}
+
+		writer := &maskingResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		c.Writer = writer.ResponseWriter
+		if writer.passthrough || writer.body.Len() == 0 {
+			return
+		}
+
+		roleID, authenticated := CurrentRoleID(c)
+		if !authenticated || CanViewPII(roleID) {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(writer.body.Bytes()))
+		decoder.UseNumber()
+		var payload interface{}
+		if err := decoder.Decode(&payload); err != nil {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+			return
+		}
+
+		masked, err := json.Marshal(utils.MaskSensitiveFields(payload))
+		if err != nil {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+			return
+		}
+		writer.ResponseWriter.Write(masked)
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the latency of every request in metrics.HTTPRequestDuration, labelled by
+// route template. Requests that match no route share the "unmatched" label.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuth protects /metrics with token (METRICS_TOKEN) when it is set: scrapers send it as
+// a Bearer token. Without a token the endpoint is open (keep it off the public network).
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			utils.Unauthorized(c, "invalid metrics token")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -45,8 +46,8 @@ func RateLimiter(cfg RateLimitConfig) gin.HandlerFunc {
 			return
 		}
 
-		// Create Redis key
-		key := fmt.Sprintf("ratelimit:%s:%s", c.FullPath(), identifier)
+		// Create Redis key (shared by the /api and /api/v1 mounts of a route)
+		key := fmt.Sprintf("ratelimit:%s:%s", UnversionedRoute(c.FullPath()), identifier)
 
 		// Check current count
 		count, err := config.RedisClient.Get(ctx, key).Int()
@@ -60,9 +61,7 @@ func RateLimiter(cfg RateLimitConfig) gin.HandlerFunc {
 
 		// Check if limit exceeded
 		if count >= cfg.MaxRequests {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded",
-			})
+			utils.ErrorResponse(c, http.StatusTooManyRequests, "rate limit exceeded")
 			c.Abort()
 			return
 		}
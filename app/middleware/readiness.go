@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness states reported by /ready. The server listens while it is still starting (warm-up
+// runs after the dependencies and the schema check) so liveness probes pass; load balancers
+// route traffic once it is ready and stop when a shutdown begins.
+const (
+	ReadinessStarting = "starting"
+	ReadinessReady    = "ready"
+	ReadinessDraining = "draining"
+)
+
+var readiness atomic.Value
+
+func init() {
+	readiness.Store(ReadinessStarting)
+}
+
+// SetReadiness records the readiness state (one of the Readiness* constants)
+func SetReadiness(state string) {
+	readiness.Store(state)
+}
+
+// Readiness returns the current readiness state
+func Readiness() string {
+	return readiness.Load().(string)
+}
+
+// probePaths answer while the server is starting
+var probePaths = map[string]bool{
+	"/health":     true,
+	"/api/health": true,
+	"/ready":      true,
+	"/api/ready":  true,
+	"/metrics":    true,
+}
+
+// ReadinessGate answers 503 to everything but the health, readiness and metrics endpoints
+// until the server is ready. Requests arriving while draining are still served: the load
+// balancer needs a moment to notice /ready failing.
+func ReadinessGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Readiness() != ReadinessStarting || probePaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "5")
+		utils.ErrorCodeResponse(c, http.StatusServiceUnavailable, utils.CodeServiceUnavailable,
+			"the server is starting; please retry shortly", nil)
+		c.Abort()
+	}
+}
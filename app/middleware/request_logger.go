@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// requestIDPattern limits which client supplied request IDs are reused (anything else is replaced)
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID assigns every request an ID. A well-formed X-Request-Id sent by the client or a
+// proxy is kept so one ID follows the request across services. The ID is echoed in the
+// X-Request-Id response header, stored as "requestID" in the gin context and added to the
+// request context, so services logging with utils.Logger(ctx) include it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(utils.RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set("requestID", id)
+		c.Header(utils.RequestIDHeader, id)
+		c.Request = c.Request.WithContext(utils.ContextWithRequestID(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
+
+// RequestLogger writes one structured entry per request with method, path, status, latency
+// and the authenticated user. 5xx responses are logged at error level, 4xx at warn.
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("request_id", c.GetString("requestID")),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("ip", GetClientIP(c)),
+		}
+		if userID, ok := CurrentUserID(c); ok {
+			fields = append(fields, zap.Uint("user_id", userID))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		level := zapcore.InfoLevel
+		switch {
+		case status >= 500:
+			level = zapcore.ErrorLevel
+		case status >= 400:
+			level = zapcore.WarnLevel
+		}
+		logger.Log(level, "request", fields...)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SchemaReadOnly answers writes with 503 while the API serves read-only because the database
+// lacks migrations (SCHEMA_CHECK=read-only, see services.VerifySchema). Sign-in, refresh and
+// sign-out under /auth/ stay available so users can keep reading.
+func SchemaReadOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if services.SchemaReadOnly() == "" || strings.Contains(c.Request.URL.Path, "/auth/") {
+			c.Next()
+			return
+		}
+		utils.ErrorCodeResponse(c, http.StatusServiceUnavailable, utils.CodeServiceUnavailable,
+			"the server is read-only until a database upgrade is finished; please retry later", nil)
+		c.Abort()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"github.com/gin-gonic/gin"
 )
@@ -26,14 +27,14 @@ func ValidateSpecialGuestMiddleware() gin.HandlerFunc {
 		// Get and validate special guest ID
 		specialGuestID := c.Param("id")
 		if specialGuestID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "special guest ID is required"})
+			utils.BadRequest(c, "special guest ID is required")
 			c.Abort()
 			return
 		}
 
 		sgID, err := strconv.ParseUint(specialGuestID, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid special guest ID format"})
+			utils.BadRequest(c, "invalid special guest ID format")
 			c.Abort()
 			return
 		}
@@ -41,10 +42,7 @@ func ValidateSpecialGuestMiddleware() gin.HandlerFunc {
 		// Check if special guest exists
 		var specialGuest models.SpecialGuest
 		if err := config.DB.First(&specialGuest, sgID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "special guest not found",
-				"id":    specialGuestID,
-			})
+			utils.ErrorCodeResponse(c, http.StatusNotFound, utils.CodeNotFound, "special guest not found", gin.H{"id": specialGuestID})
 			c.Abort()
 			return
 		}
@@ -52,14 +50,14 @@ func ValidateSpecialGuestMiddleware() gin.HandlerFunc {
 		// Validate user authentication
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			utils.Unauthorized(c, "user not authenticated")
 			c.Abort()
 			return
 		}
 
 		roleID, exists := c.Get("roleID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user role not found"})
+			utils.Unauthorized(c, "user role not found")
 			c.Abort()
 			return
 		}
@@ -67,7 +65,7 @@ func ValidateSpecialGuestMiddleware() gin.HandlerFunc {
 		// Role-based access control
 		role, ok := roleID.(uint)
 		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid role type"})
+			utils.Forbidden(c, "invalid role type")
 			c.Abort()
 			return
 		}
@@ -77,12 +75,12 @@ func ValidateSpecialGuestMiddleware() gin.HandlerFunc {
 			// Allow all operations
 		case 3: // Regular user - read only
 			if c.Request.Method != http.MethodGet {
-				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+				utils.Forbidden(c, "insufficient permissions")
 				c.Abort()
 				return
 			}
 		default:
-			c.JSON(http.StatusForbidden, gin.H{"error": "invalid role"})
+			utils.Forbidden(c, "invalid role")
 			c.Abort()
 			return
 		}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"gorm.io/gorm"
 )
 
 // SpecialGuest represents a special guest in the system
@@ -13,6 +17,7 @@ type SpecialGuest struct {
 	FirstName            string     `json:"first_name,omitempty"`
 	MiddleName           string     `json:"middle_name,omitempty"`
 	LastName             string     `json:"last_name,omitempty"`
+	NameKey              string     `gorm:"column:name_key;index" json:"-"` // script-independent phonetic key (utils.NameKey)
 	Designation          string     `json:"designation,omitempty"`
 	Organization         string     `json:"organization,omitempty"`
 	Email                string     `gorm:"unique" json:"email,omitempty"`
@@ -26,8 +31,38 @@ type SpecialGuest struct {
 	ReferencePersonName  string     `json:"reference_person_name,omitempty"`
 	EventID              uint       `json:"event_id"`
 	Event                Event      `gorm:"foreignKey:EventID;references:ID" json:"event,omitempty"`
+	GuestID              *uint      `gorm:"column:guest_id;index" json:"guest_id,omitempty"` // master record, linked on create (see LinkGuest)
+	LetterSentOn         *time.Time `gorm:"column:letter_sent_on;type:date" json:"letter_sent_on,omitempty"`
+	FeedbackReceivedOn   *time.Time `gorm:"column:feedback_received_on;type:date" json:"feedback_received_on,omitempty"`
+	Feedback             string     `json:"feedback,omitempty"`
 	CreatedOn            time.Time  `json:"created_on,omitempty"`
 	UpdatedOn            *time.Time `json:"updated_on,omitempty"`
 	CreatedBy            string     `json:"created_by,omitempty"`
 	UpdatedBy            string     `json:"updated_by,omitempty"`
 }
+
+// FullName joins first, middle and last name
+func (sg *SpecialGuest) FullName() string {
+	return strings.Join(strings.Fields(sg.FirstName+" "+sg.MiddleName+" "+sg.LastName), " ")
+}
+
+// BeforeCreate links the appearance to the guest's master record
+func (sg *SpecialGuest) BeforeCreate(tx *gorm.DB) error {
+	if sg.GuestID != nil || sg.FullName() == "" {
+		return nil
+	}
+	id, err := LinkGuest(tx, sg)
+	if err != nil {
+		return err
+	}
+	sg.GuestID = &id
+	return nil
+}
+
+// BeforeSave keeps NameKey in sync with the guest's name
+func (sg *SpecialGuest) BeforeSave(tx *gorm.DB) error {
+	if name := sg.FullName(); name != "" {
+		sg.NameKey = utils.NameKey(name)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// APIUsageDaily is the per-day request rollup of one route for one user and client.
+// Anonymous requests use UserID 0; Client comes from the X-Client-ID header (or the
+// User-Agent product when the header is missing).
+type APIUsageDaily struct {
+	Day             time.Time `gorm:"type:date;primaryKey" json:"day"`
+	UserID          uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Client          string    `gorm:"primaryKey" json:"client"`
+	Method          string    `gorm:"primaryKey" json:"method"`
+	Route           string    `gorm:"primaryKey" json:"route"`
+	RequestCount    int64     `gorm:"not null;default:0" json:"request_count"`
+	ErrorCount      int64     `gorm:"not null;default:0" json:"error_count"` // 5xx responses
+	TotalDurationMs int64     `gorm:"not null;default:0" json:"total_duration_ms"`
+	MaxDurationMs   int64     `gorm:"not null;default:0" json:"max_duration_ms"`
+	UpdatedOn       time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (APIUsageDaily) TableName() string {
+	return "api_usage_daily"
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// ArchivedFinancialYear marks a financial year (April-March, e.g. "2023-24") as archived: its
+// events, media, special guests, volunteers, donations and promotion materials can still be
+// read but no longer written. See migrations/00001_baseline.sql.
+type ArchivedFinancialYear struct {
+	FinancialYear string    `gorm:"primaryKey;type:varchar(7)" json:"financial_year"`
+	StartsOn      time.Time `gorm:"type:date;not null" json:"starts_on"`
+	EndsOn        time.Time `gorm:"type:date;not null" json:"ends_on"`
+	Note          string    `json:"note,omitempty"`
+	ArchivedBy    *uint     `json:"archived_by,omitempty"`
+	ArchivedOn    time.Time `gorm:"autoCreateTime" json:"archived_on"`
+
+	// Totals of the year's rollups, filled in by services.ListArchivedFinancialYears
+	Events        int64   `gorm:"-" json:"events"`
+	Donations     int64   `gorm:"-" json:"donations"`
+	DonationTotal float64 `gorm:"-" json:"donation_total"`
+}
+
+func (ArchivedFinancialYear) TableName() string {
+	return "archived_financial_years"
+}
+
+// Kinds of ArchivedYearBreakdown
+const (
+	ArchivedBreakdownDonationType  = "donation_type"
+	ArchivedBreakdownMediaFileType = "media_file_type"
+)
+
+// ArchivedYearRollup holds the event figures of one branch and month (YYYY-MM of the start
+// date) of an archived financial year
+type ArchivedYearRollup struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	FinancialYear    string `gorm:"type:varchar(7);not null" json:"financial_year"`
+	BranchID         *uint  `json:"branch_id,omitempty"`
+	Month            string `gorm:"type:char(7);not null" json:"month"`
+	Events           int64  `json:"events"`
+	EventsComplete   int64  `json:"events_complete"`
+	BeneficiaryMen   int64  `json:"beneficiary_men"`
+	BeneficiaryWomen int64  `json:"beneficiary_women"`
+	BeneficiaryChild int64  `json:"beneficiary_child"`
+	InitiationMen    int64  `json:"initiation_men"`
+	InitiationWomen  int64  `json:"initiation_women"`
+	InitiationChild  int64  `json:"initiation_child"`
+}
+
+func (ArchivedYearRollup) TableName() string {
+	return "archived_year_rollups"
+}
+
+// ArchivedYearBreakdown counts the donations of one donation type, or the event media of one
+// file type, of a branch and month of an archived financial year
+type ArchivedYearBreakdown struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	FinancialYear string  `gorm:"type:varchar(7);not null" json:"financial_year"`
+	BranchID      *uint   `json:"branch_id,omitempty"`
+	Month         string  `gorm:"type:char(7);not null" json:"month"`
+	Kind          string  `gorm:"type:varchar(20);not null" json:"kind"`
+	Key           string  `gorm:"type:varchar(100);not null" json:"key"`
+	Count         int64   `json:"count"`
+	Amount        float64 `json:"amount"`
+}
+
+func (ArchivedYearBreakdown) TableName() string {
+	return "archived_year_breakdowns"
+}
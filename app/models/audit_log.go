@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Audit log actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLog records who changed what on a domain entity
+type AuditLog struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EntityType  string    `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity" json:"entity_type"`
+	EntityID    uint      `gorm:"not null;index:idx_audit_logs_entity" json:"entity_id"`
+	Action      string    `gorm:"type:varchar(20);not null" json:"action"`
+	Changes     JSONB     `gorm:"type:jsonb" json:"changes,omitempty"` // {"field": {"old": ..., "new": ...}}
+	ActorID     *uint     `gorm:"index" json:"actor_id,omitempty"`
+	ActorRoleID *uint     `json:"actor_role_id,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	Method      string    `gorm:"type:varchar(10)" json:"method,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	CreatedOn   time.Time `gorm:"autoCreateTime;index" json:"created_on"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 // swagger:model Branch
 // Branch represents both parent branches and child branches in a single table.
@@ -43,6 +50,57 @@ type Branch struct {
 	UpdatedOn       *time.Time `gorm:"autoUpdateTime" json:"updated_on,omitempty"`
 	CreatedBy       string     `json:"created_by,omitempty"`
 	UpdatedBy       string     `json:"updated_by,omitempty"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
+
+	// Version is bumped by every update, for optimistic locking of edits (If-Match)
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	// Denormalized counters, maintained by database triggers (read-only here)
+	MediaCount     int        `gorm:"->" json:"media_count"`
+	MemberCount    int        `gorm:"->" json:"member_count"`
+	EventCount     int        `gorm:"->" json:"event_count"`
+	LastActivityOn *time.Time `gorm:"->" json:"last_activity_on,omitempty"`
+
+	// Branch media chosen as the directory card image and the coordinator's photo.
+	// Set through PUT /branches/:id/cover-image and /coordinator-photo, not the generic update.
+	CoverMediaID            *uint        `gorm:"column:cover_media_id" json:"cover_media_id,omitempty"`
+	CoordinatorPhotoMediaID *uint        `gorm:"column:coordinator_photo_media_id" json:"coordinator_photo_media_id,omitempty"`
+	CoverImage              *BranchImage `gorm:"-" json:"cover_image,omitempty"`       // Computed: presigned URLs
+	CoordinatorPhoto        *BranchImage `gorm:"-" json:"coordinator_photo,omitempty"` // Computed: presigned URLs
+
+	// Map location for the public branch locator. PublicID is the stable identifier exposed
+	// by /public/api so internal IDs stay private.
+	Latitude  *float64 `gorm:"column:latitude" json:"latitude,omitempty" validate:"omitempty,min=-90,max=90"`
+	Longitude *float64 `gorm:"column:longitude" json:"longitude,omitempty" validate:"omitempty,min=-180,max=180"`
+	PublicID  string   `gorm:"column:public_id;<-:create" json:"public_id,omitempty"`
+	// Where the coordinates came from (LocationSource*); set by the service, not the client
+	LocationSource string `gorm:"column:location_source" json:"location_source,omitempty"`
+
+	// Sandbox branches hold practice data for training sessions: excluded from stats, exports
+	// and the public site, and wiped by POST /admin/sandbox/wipe. Set only through
+	// PUT /admin/sandbox/branches/:id; child branches inherit it when created (database trigger).
+	IsSandbox bool `gorm:"column:is_sandbox;<-:false" json:"is_sandbox"`
+}
+
+// Branch location sources
+const (
+	LocationSourceManual   = "manual"
+	LocationSourceGeocoder = "geocoder"
+)
+
+// BeforeCreate assigns the branch's public identifier
+func (b *Branch) BeforeCreate(tx *gorm.DB) error {
+	if b.PublicID == "" {
+		b.PublicID = uuid.NewString()
+	}
+	return nil
+}
+
+// BranchImage is a presigned branch cover image or coordinator photo
+type BranchImage struct {
+	MediaID      uint   `json:"media_id"`
+	URL          string `json:"url"`                     // Medium thumbnail, or the original when there is none yet
+	ThumbnailURL string `json:"thumbnail_url,omitempty"` // Small thumbnail
 }
 
 // swagger:model BranchInfrastructure
@@ -72,6 +130,8 @@ type BranchMember struct {
 	DateOfSamarpan *time.Time `json:"date_of_samarpan,omitempty"`
 	Qualification  string     `json:"qualification,omitempty" validate:"omitempty,max=255"`
 	DateOfBirth    *time.Time `json:"date_of_birth,omitempty"`
+	MembershipID   string     `gorm:"column:membership_id;unique" json:"membership_id,omitempty"` // e.g. MEM/12/2025-26/000007
+	PersonID       *uint      `gorm:"column:person_id;index" json:"person_id,omitempty"`
 	BranchID       uint       `gorm:"not null" json:"branch_id" validate:"required,min=1"`
 	Branch         Branch     `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
 	CreatedOn      time.Time  `gorm:"autoCreateTime" json:"created_on,omitempty"`
@@ -84,3 +144,22 @@ type BranchMember struct {
 func (BranchMember) TableName() string {
 	return "branch_member"
 }
+
+// BeforeCreate links the member to a person and assigns the next membership ID for the
+// member's branch and financial year
+func (m *BranchMember) BeforeCreate(tx *gorm.DB) error {
+	if err := linkPersonID(tx, &m.PersonID, Person{Name: m.Name, DateOfBirth: m.DateOfBirth}); err != nil {
+		return err
+	}
+	if m.MembershipID != "" {
+		return nil
+	}
+	number, err := sequence.NextNumber(tx.Session(&gorm.Session{NewDB: true}),
+		fmt.Sprintf("%s:%d", sequence.ScopeMembership, m.BranchID),
+		fmt.Sprintf("MEM/%d", m.BranchID), time.Now())
+	if err != nil {
+		return err
+	}
+	m.MembershipID = number
+	return nil
+}
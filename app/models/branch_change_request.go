@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Branch change request statuses
+const (
+	BranchChangeStatusPending   = "pending"
+	BranchChangeStatusApproved  = "approved"
+	BranchChangeStatusRejected  = "rejected"
+	BranchChangeStatusWithdrawn = "withdrawn"
+)
+
+// BranchChangeRequest is an edit to a branch profile proposed by its coordinator. It is only
+// applied to the branch once a regional manager or admin approves it.
+type BranchChangeRequest struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	BranchID uint   `gorm:"not null;index" json:"branch_id"`
+	Status   string `gorm:"not null;default:pending" json:"status"`
+
+	Changes  JSONB  `gorm:"type:jsonb;not null" json:"changes"`  // field -> proposed value
+	Original JSONB  `gorm:"type:jsonb;not null" json:"original"` // field -> value when the request was made
+	Comment  string `json:"comment,omitempty"`                   // submitter's note for the reviewer
+
+	RequestedBy   uint       `gorm:"not null" json:"requested_by"`
+	ReviewedBy    *uint      `json:"reviewed_by,omitempty"`
+	ReviewedOn    *time.Time `json:"reviewed_on,omitempty"`
+	ReviewComment string     `json:"review_comment,omitempty"`
+
+	CreatedOn time.Time `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+
+	Branch *Branch `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
+}
+
+func (BranchChangeRequest) TableName() string {
+	return "branch_change_requests"
+}
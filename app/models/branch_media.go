@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // BranchMedia represents media files for a branch
@@ -12,13 +14,26 @@ type BranchMedia struct {
 	S3Key           string    `json:"s3_key,omitempty" gorm:"column:s3_key"`   // Opaque S3 object key (UUID-based)
 	OriginalFilename string   `json:"original_filename,omitempty" gorm:"column:original_filename"` // Original filename from upload
 	FileType        string    `json:"file_type,omitempty" gorm:"column:file_type"` // image, video, audio, file
+	ThumbnailS3Key       *string `json:"thumbnail_s3_key,omitempty" gorm:"column:thumbnail_s3_key"`               // Small (320px) thumbnail S3 key
+	ThumbnailMediumS3Key *string `json:"thumbnail_medium_s3_key,omitempty" gorm:"column:thumbnail_medium_s3_key"` // Medium (1024px) thumbnail S3 key
+	ScanStatus           *string    `json:"scan_status,omitempty" gorm:"column:scan_status"` // MediaScanStatus*; nil until scanned
+	ScanDetail           string     `json:"scan_detail,omitempty" gorm:"column:scan_detail"`
+	ScannedOn            *time.Time `json:"scanned_on,omitempty" gorm:"column:scanned_on"`
+	PHash                *int64     `json:"-" gorm:"column:phash"` // Perceptual hash of images, see services.FindMediaDuplicates
+	TranscodeStatus      *string    `json:"transcode_status,omitempty" gorm:"column:transcode_status"` // MediaTranscodeStatus*; nil for files served as uploaded
+	TranscodeDetail      string     `json:"transcode_detail,omitempty" gorm:"column:transcode_detail"`
+	TranscodedS3Key      *string    `json:"transcoded_s3_key,omitempty" gorm:"column:transcoded_s3_key"` // MP4 rendition of videos browsers cannot play
 	Name            string    `json:"name,omitempty"`
-	URL             string    `json:"url,omitempty" gorm:"-"` // Computed: presigned URL (populated by ConvertBranchMediaToPresignedURLs)
+	URL             string    `json:"url,omitempty" gorm:"-"` // Computed: presigned URL (populated by ConvertBranchMediaToPresignedURLs); the transcoded rendition when there is one
+	OriginalURL     string    `json:"original_url,omitempty" gorm:"-"` // Computed: presigned URL of the uploaded file when url is a transcoded rendition
+	ThumbnailURL    string    `json:"thumbnail_url,omitempty" gorm:"-"` // Computed: presigned small thumbnail URL
+	MediumURL       string    `json:"medium_url,omitempty" gorm:"-"`    // Computed: presigned medium thumbnail URL
 	Category    string    `json:"category,omitempty"` // Branch Photos, Video Coverage, Documents, Other
 	CreatedOn   time.Time `gorm:"autoCreateTime" json:"created_on"`
 	UpdatedOn   time.Time `gorm:"autoUpdateTime" json:"updated_on"`
 	CreatedBy   string    `json:"created_by,omitempty" gorm:"<-:create"`
 	UpdatedBy   string    `json:"updated_by,omitempty"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
 	Branch      Branch    `gorm:"foreignKey:BranchID;references:ID" json:"branch,omitempty"`
 }
 
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// BranchStorageUsage is the storage used by the files of a branch in one media category, see
+// services.GetBranchStorageUsage
+type BranchStorageUsage struct {
+	BranchID   uint      `gorm:"primaryKey" json:"branch_id"`
+	Category   string    `gorm:"primaryKey" json:"category"`
+	Objects    int64     `gorm:"not null" json:"objects"`
+	Bytes      int64     `gorm:"not null" json:"bytes"`
+	ComputedOn time.Time `gorm:"not null" json:"computed_on"`
+}
+
+func (BranchStorageUsage) TableName() string {
+	return "branch_storage_usage"
+}
+
+// BranchStorageQuota caps the storage of a branch; uploads are refused once it is reached
+type BranchStorageQuota struct {
+	BranchID   uint      `gorm:"primaryKey;autoIncrement:false" json:"branch_id"`
+	QuotaBytes int64     `gorm:"not null" json:"quota_bytes"`
+	UpdatedBy  *uint     `json:"updated_by,omitempty"`
+	UpdatedOn  time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (BranchStorageQuota) TableName() string {
+	return "branch_storage_quotas"
+}
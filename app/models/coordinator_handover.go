@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// CoordinatorHandover records a change of a branch's coordinator and the work that moved with
+// it. Child branches inherit their parent's coordinator, so handovers are made on top-level
+// branches only.
+type CoordinatorHandover struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	BranchID       uint   `gorm:"not null;index" json:"branch_id"`
+	FromUserIDs    []uint `gorm:"type:jsonb;serializer:json" json:"from_user_ids"` // users who coordinated the branch until now
+	ToUserID       uint   `gorm:"not null" json:"to_user_id"`
+	FromName       string `json:"from_name"` // coordinator_name before and after
+	ToName         string `json:"to_name"`
+	Note           string `json:"note,omitempty"`
+	ChildBranchIDs []uint `gorm:"type:jsonb;serializer:json" json:"child_branch_ids"` // child branches whose inherited coordinator_name was updated
+
+	// Work moved to the new coordinator: pending change requests submitted by the previous
+	// coordinators, and events awaiting review whose outcome is now emailed to the new one
+	ChangeRequests int `gorm:"not null;default:0" json:"change_requests"`
+	PendingEvents  int `gorm:"not null;default:0" json:"pending_events"`
+
+	CreatedBy *uint     `json:"created_by,omitempty"`
+	CreatedOn time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (CoordinatorHandover) TableName() string {
+	return "coordinator_handovers"
+}
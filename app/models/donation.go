@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"gorm.io/gorm"
+)
 
 // Donation represents donation details for an event
 type Donation struct {
@@ -11,14 +18,62 @@ type Donation struct {
 	DonationType string  `json:"donation_type,omitempty"`
 	Amount       float64 `json:"amount,omitempty"`
 	KindType     string  `json:"kindtype,omitempty"`
+	Remarks      string  `json:"remarks,omitempty"`
+
+	ReceiptNumber string `gorm:"column:receipt_number;unique" json:"receipt_number,omitempty"` // e.g. RCPT/12/2025-26/000042
+	DonorName    string  `json:"donor_name,omitempty"`
+	DonorNameKey string  `gorm:"column:donor_name_key;index" json:"-"` // script-independent phonetic key (utils.NameKey)
+	PersonID     *uint   `gorm:"column:person_id;index" json:"person_id,omitempty"` // the donor
+
+	ReceiptS3Key string `json:"receipt_s3_key,omitempty" gorm:"column:receipt_s3_key"` // Uploaded receipt (S3 object key)
+	OCRText      string `json:"ocr_text,omitempty" gorm:"column:ocr_text"`             // Text extracted from the receipt by the OCR worker
+
+	// Set when the receipt is cancelled; the receipt number is kept, see services.VoidDonationReceipt
+	VoidedOn   *time.Time `json:"voided_on,omitempty" gorm:"column:voided_on"`
+	VoidedBy   *uint      `json:"voided_by,omitempty" gorm:"column:voided_by"`
+	VoidReason string     `json:"void_reason,omitempty" gorm:"column:void_reason"`
 
 	CreatedOn time.Time `gorm:"autoCreateTime" json:"created_on"`
 	UpdatedOn time.Time `gorm:"autoUpdateTime" json:"updated_on"`
 
 	CreatedBy string `json:"created_by,omitempty" gorm:"<-:create"` // only set on create
 	UpdatedBy string `json:"updated_by,omitempty"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
 
 	// Relations
 	Event  Event  `gorm:"foreignKey:EventID;references:ID" json:"event,omitempty"`
 	Branch Branch `gorm:"foreignKey:BranchID;references:ID" json:"branch,omitempty"`
 }
+
+// BeforeSave keeps DonorNameKey in sync with DonorName
+func (d *Donation) BeforeSave(tx *gorm.DB) error {
+	if d.DonorName != "" {
+		d.DonorNameKey = utils.NameKey(d.DonorName)
+	}
+	return nil
+}
+
+// BeforeCreate links the donor to a person and assigns the next receipt number for the
+// donation's branch and financial year
+func (d *Donation) BeforeCreate(tx *gorm.DB) error {
+	if err := linkPersonID(tx, &d.PersonID, Person{Name: d.DonorName}); err != nil {
+		return err
+	}
+	if d.ReceiptNumber != "" {
+		return nil
+	}
+	return d.AssignReceiptNumber(tx.Session(&gorm.Session{NewDB: true}), time.Now())
+}
+
+// AssignReceiptNumber sets ReceiptNumber to the next number of the branch's series for the
+// financial year containing at. tx must be the transaction that stores the number.
+func (d *Donation) AssignReceiptNumber(tx *gorm.DB, at time.Time) error {
+	number, err := sequence.NextNumber(tx,
+		fmt.Sprintf("%s:%d", sequence.ScopeDonationReceipt, d.BranchID),
+		fmt.Sprintf("RCPT/%d", d.BranchID), at)
+	if err != nil {
+		return err
+	}
+	d.ReceiptNumber = number
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Attendance age bands
+const (
+	AgeBandChild  = "child" // counted as beneficiary_child of the event
+	AgeBandYouth  = "youth"
+	AgeBandAdult  = "adult"
+	AgeBandSenior = "senior"
+)
+
+// AgeBands lists the age bands in display order
+var AgeBands = []string{AgeBandChild, AgeBandYouth, AgeBandAdult, AgeBandSenior}
+
+// EventAttendance is the attendance of one day of an event in one age band. Once an event has
+// attendance, its beneficiary figures are the totals of its days (see
+// services.SetEventAttendanceDay).
+type EventAttendance struct {
+	ID      uint          `gorm:"primaryKey" json:"id"`
+	EventID uint          `gorm:"not null;uniqueIndex:idx_event_attendance_day_band" json:"event_id"`
+	Event   *EventDetails `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	Day     time.Time     `gorm:"type:date;not null;uniqueIndex:idx_event_attendance_day_band" json:"day"`
+	AgeBand string        `gorm:"type:varchar(20);not null;uniqueIndex:idx_event_attendance_day_band" json:"age_band"`
+	Male    int           `gorm:"not null;default:0" json:"male"`
+	Female  int           `gorm:"not null;default:0" json:"female"`
+	// Of Male + Female, how many attended for the first time; the others are returning
+	New int `gorm:"column:new_attendees;not null;default:0" json:"new"`
+
+	CreatedBy *uint      `json:"created_by,omitempty"`
+	CreatedOn time.Time  `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn *time.Time `json:"updated_on,omitempty"`
+}
+
+func (EventAttendance) TableName() string {
+	return "event_attendance"
+}
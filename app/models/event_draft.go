@@ -21,6 +21,9 @@ type EventDraft struct {
 	// User email to track which user created the draft
 	UserEmail string `gorm:"column:user_email" json:"user_email,omitempty"`
 
+	// Version is bumped by every save, for optimistic locking of autosaves
+	Version int `gorm:"not null;default:1" json:"version"`
+
 	CreatedOn time.Time  `json:"created_on,omitempty"`
 	UpdatedOn *time.Time `json:"updated_on,omitempty"`
 }
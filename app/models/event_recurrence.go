@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Recurrence frequencies
+const (
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+// EventRecurrence repeats an event on a schedule, e.g. a weekly satsang series. The event is the
+// first occurrence and the template of the others: their length in days and daily times are the
+// event's. Occurrences are expanded when read (see services.GetEventCalendar), not stored.
+type EventRecurrence struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	EventID   uint          `gorm:"not null;uniqueIndex" json:"event_id"`
+	Event     *EventDetails `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	Frequency string        `gorm:"type:varchar(20);not null" json:"frequency"` // RecurrenceWeekly or RecurrenceMonthly
+	Interval  int           `gorm:"not null;default:1" json:"interval"`         // every n weeks or months
+	// Days of the week of a weekly series, 0 = Sunday; empty means the weekday of the event.
+	// Monthly series repeat on the day of the month of the event.
+	Weekdays []int `gorm:"type:jsonb;serializer:json" json:"weekdays"`
+	// The series ends on Until or after Count occurrences (including the event itself),
+	// whichever comes first; with neither it runs until the recurrence is removed
+	Until *time.Time `gorm:"type:date" json:"until,omitempty"`
+	Count *int       `json:"count,omitempty"`
+	// Dates (YYYY-MM-DD) of occurrences that do not take place
+	ExceptDates []string `gorm:"type:jsonb;serializer:json" json:"except_dates"`
+
+	CreatedBy *uint      `json:"created_by,omitempty"`
+	CreatedOn time.Time  `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn *time.Time `json:"updated_on,omitempty"`
+}
+
+func (EventRecurrence) TableName() string {
+	return "event_recurrences"
+}
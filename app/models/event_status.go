@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Event approval workflow statuses
+const (
+	EventStatusDraft       = "draft"
+	EventStatusSubmitted   = "submitted"
+	EventStatusUnderReview = "under_review"
+	EventStatusApproved    = "approved"
+	EventStatusRejected    = "rejected"
+	EventStatusPublished   = "published"
+)
+
+// EventStatusHistory records every approval status transition of an event
+type EventStatusHistory struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EventID    uint      `gorm:"not null;index" json:"event_id"`
+	FromStatus string    `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	Comment    string    `json:"comment,omitempty"` // reviewer comment (required on rejection)
+	ChangedBy  uint      `json:"changed_by"`        // user ID
+	RoleID     uint      `json:"role_id"`           // role of the user at the time of change
+	CreatedOn  time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (EventStatusHistory) TableName() string {
+	return "event_status_history"
+}
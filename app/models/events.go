@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"gorm.io/gorm"
 )
 
 // JSONB type for PostgreSQL JSONB fields
@@ -166,16 +169,54 @@ type EventDetails struct {
 	InitiationWomen  int `json:"initiation_women"`
 	InitiationChild  int `json:"initiation_child"`
 
+	// Donation total reported for the event as a whole, reconciled against the itemized donations
+	DeclaredDonationTotal *float64 `gorm:"column:declared_donation_total" json:"declared_donation_total,omitempty"`
+
 	// Branch association (nullable - optional field for backward compatibility)
 	BranchID *uint   `json:"branch_id,omitempty"`
 	Branch   *Branch `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
 
 	Status string `gorm:"default:'incomplete';type:varchar(20)" json:"status,omitempty"`
+	// Sequential report number per financial year, e.g. EVT/2025-26/000045
+	ReportNumber string `gorm:"column:report_number;unique" json:"report_number,omitempty"`
+
+	// Approval workflow status (draft → submitted → under_review → approved/rejected → published)
+	ApprovalStatus string `gorm:"default:'draft';type:varchar(20)" json:"approval_status,omitempty"`
 
 	CreatedOn time.Time  `json:"created_on,omitempty"`
 	UpdatedOn *time.Time `json:"updated_on,omitempty"`
 	CreatedBy string     `json:"created_by,omitempty"`
 	UpdatedBy string     `json:"updated_by,omitempty"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
+
+	// Version is bumped by every update, for optimistic locking of edits (If-Match)
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	// Denormalized counters, maintained by database triggers (read-only here)
+	MediaCount             int        `gorm:"->" json:"media_count"`
+	SpecialGuestCount      int        `gorm:"->" json:"special_guest_count"`
+	VolunteerCount         int        `gorm:"->" json:"volunteer_count"`
+	DonationCount          int        `gorm:"->" json:"donation_count"`
+	DonationTotal          float64    `gorm:"->" json:"donation_total"` // excluding voided receipts
+	PromotionMaterialCount int        `gorm:"->" json:"promotion_material_count"`
+	LastActivityOn         *time.Time `gorm:"->" json:"last_activity_on,omitempty"`
+
+	// Set while the event's financial year is archived and the event is read-only
+	Archived bool `gorm:"->" json:"archived"`
 
 	// Note: Draft fields removed - now using separate event_drafts table
 }
+
+// BeforeCreate assigns the next event report number for the current financial year
+func (e *EventDetails) BeforeCreate(tx *gorm.DB) error {
+	if e.ReportNumber != "" {
+		return nil
+	}
+	number, err := sequence.NextNumber(tx.Session(&gorm.Session{NewDB: true}),
+		sequence.ScopeEventReport, "EVT", time.Now())
+	if err != nil {
+		return err
+	}
+	e.ReportNumber = number
+	return nil
+}
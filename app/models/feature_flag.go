@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// FeatureFlag is a runtime switch that can be flipped without a deploy.
+// Keys are dotted names, e.g. "migration.event_media_caption.read_new".
+type FeatureFlag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"type:varchar(150);not null;uniqueIndex" json:"key"`
+	Enabled     bool      `gorm:"not null;default:false" json:"enabled"`
+	Description string    `json:"description,omitempty"`
+	UpdatedBy   *uint     `json:"updated_by,omitempty"`
+	CreatedOn   time.Time `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn   time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (FeatureFlag) TableName() string {
+	return "feature_flags"
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"gorm.io/gorm"
+)
+
+// Guest is the master record of a special guest. Each appearance at an event is a
+// SpecialGuest row linked through guest_id.
+type Guest struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Prefix       string    `json:"prefix,omitempty"`
+	FirstName    string    `json:"first_name,omitempty"`
+	MiddleName   string    `json:"middle_name,omitempty"`
+	LastName     string    `json:"last_name,omitempty"`
+	NameKey      string    `gorm:"column:name_key;index" json:"-"` // script-independent phonetic key (utils.NameKey)
+	Designation  string    `json:"designation,omitempty"`
+	Organization string    `json:"organization,omitempty"`
+	Email        string    `gorm:"column:email;index" json:"email,omitempty"`
+	Contact      string    `gorm:"column:contact;index" json:"contact,omitempty"` // normalized, see NormalizeContact
+	City         string    `json:"city,omitempty"`
+	State        string    `json:"state,omitempty"`
+	CreatedOn    time.Time `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn    time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (Guest) TableName() string {
+	return "guests"
+}
+
+// FullName joins first, middle and last name
+func (g *Guest) FullName() string {
+	return strings.Join(strings.Fields(g.FirstName+" "+g.MiddleName+" "+g.LastName), " ")
+}
+
+// BeforeSave keeps NameKey in sync with the guest's name
+func (g *Guest) BeforeSave(tx *gorm.DB) error {
+	if name := g.FullName(); name != "" {
+		g.NameKey = utils.NameKey(name)
+	}
+	return nil
+}
+
+// LinkGuest returns the ID of the master record of a special guest appearance. An existing
+// guest is reused when the email, the contact number, or the name together with the
+// organization match; otherwise a new guest is created from the appearance. Details missing
+// on a matched guest are filled in, and the designation is kept current.
+func LinkGuest(tx *gorm.DB, appearance *SpecialGuest) (uint, error) {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	candidate := Guest{
+		Prefix:       strings.TrimSpace(appearance.Prefix),
+		FirstName:    strings.TrimSpace(appearance.FirstName),
+		MiddleName:   strings.TrimSpace(appearance.MiddleName),
+		LastName:     strings.TrimSpace(appearance.LastName),
+		Designation:  strings.TrimSpace(appearance.Designation),
+		Organization: strings.TrimSpace(appearance.Organization),
+		Email:        strings.ToLower(strings.TrimSpace(appearance.Email)),
+		Contact:      NormalizeContact(appearance.PersonalNumber),
+		City:         strings.TrimSpace(appearance.City),
+		State:        strings.TrimSpace(appearance.State),
+	}
+
+	var existing Guest
+	err := gorm.ErrRecordNotFound
+	if candidate.Email != "" {
+		err = db.Where("email = ?", candidate.Email).Order("id").First(&existing).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && candidate.Contact != "" {
+		err = db.Where("contact = ?", candidate.Contact).Order("id").First(&existing).Error
+	}
+	if key := utils.NameKey(candidate.FullName()); errors.Is(err, gorm.ErrRecordNotFound) && key != "" && candidate.Organization != "" {
+		err = db.Where("name_key = ? AND LOWER(organization) = LOWER(?)", key, candidate.Organization).
+			Order("id").First(&existing).Error
+	}
+
+	switch {
+	case err == nil:
+		updates := map[string]interface{}{}
+		for column, values := range map[string][2]string{
+			"email":        {existing.Email, candidate.Email},
+			"contact":      {existing.Contact, candidate.Contact},
+			"organization": {existing.Organization, candidate.Organization},
+			"city":         {existing.City, candidate.City},
+			"state":        {existing.State, candidate.State},
+		} {
+			if values[0] == "" && values[1] != "" {
+				updates[column] = values[1]
+			}
+		}
+		if candidate.Designation != "" && candidate.Designation != existing.Designation {
+			updates["designation"] = candidate.Designation
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&Guest{}).Where("id = ?", existing.ID).Updates(updates).Error; err != nil {
+				return 0, err
+			}
+		}
+		return existing.ID, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	if err := db.Create(&candidate).Error; err != nil {
+		return 0, err
+	}
+	return candidate.ID, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a unit of background work (thumbnails, reports, imports, emails, storage cleanup)
+// picked up by the workers in services/job_service.go. The frontend polls GET /api/jobs/:id.
+type Job struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Type   string `gorm:"not null" json:"type"`
+	Status string `gorm:"not null;default:queued" json:"status"`
+
+	Payload JSONB  `gorm:"type:jsonb" json:"-"` // handler input; may hold recipient addresses
+	Result  JSONB  `gorm:"type:jsonb" json:"result,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	Progress        int    `gorm:"not null;default:0" json:"progress"` // 0-100
+	ProgressMessage string `json:"progress_message,omitempty"`
+
+	Attempts    int       `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int       `gorm:"not null;default:3" json:"max_attempts"`
+	RunAt       time.Time `gorm:"not null" json:"run_at"` // not picked up before this time (retry backoff)
+	LockedBy    string    `json:"-"`                      // worker running the job
+
+	StartedOn  *time.Time `json:"started_on,omitempty"`
+	FinishedOn *time.Time `json:"finished_on,omitempty"`
+	CreatedBy  *uint      `json:"created_by,omitempty"`
+	CreatedOn  time.Time  `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn  time.Time  `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
@@ -14,9 +14,10 @@ type State struct {
 }
 
 type City struct {
-	ID      uint   `gorm:"primaryKey" json:"id"`
-	Name    string `json:"name"`
-	StateID uint   `json:"state_id"`
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	Name       string `json:"name"`
+	StateID    uint   `json:"state_id"`
+	DistrictID *uint  `json:"district_id,omitempty"`
 }
 
 type District struct {
@@ -14,6 +14,22 @@ func (MediaCoverageType) TableName() string {
 	return "media_coverage_type"
 }
 
+// Antivirus/moderation scan results of event and branch media, see services.MediaScanner
+const (
+	MediaScanStatusPending  = "pending" // uploaded, not served until it scans clean
+	MediaScanStatusClean    = "clean"
+	MediaScanStatusInfected = "infected"
+	MediaScanStatusFlagged  = "flagged" // rejected by a moderation scanner
+	MediaScanStatusError    = "error"   // could not be scanned (missing object, size limit)
+)
+
+// Transcoding of uploaded videos into a rendition browsers can play, see services.QueueTranscode
+const (
+	MediaTranscodeStatusPending = "pending"
+	MediaTranscodeStatusReady   = "ready"
+	MediaTranscodeStatusFailed  = "failed" // the original is served instead
+)
+
 // EventMedia represents media coverage for a specific event
 type EventMedia struct {
 	ID                  uint              `gorm:"primaryKey" json:"id"`
@@ -33,9 +49,28 @@ type EventMedia struct {
 	FileURL             string            `json:"-" gorm:"column:file_url"` // Internal: NEVER serialize to JSON - stores presigned URL temporarily
 	S3Key               string            `json:"s3_key,omitempty" gorm:"column:s3_key"`   // Opaque S3 object key (UUID-based)
 	OriginalFilename    string            `json:"original_filename,omitempty" gorm:"column:original_filename"` // Original filename from upload
-	ThumbnailS3Key      *string           `json:"thumbnail_s3_key,omitempty" gorm:"column:thumbnail_s3_key"` // Optional thumbnail S3 key
+	ThumbnailS3Key      *string           `json:"thumbnail_s3_key,omitempty" gorm:"column:thumbnail_s3_key"` // Small (320px) thumbnail S3 key
+	ThumbnailMediumS3Key *string          `json:"thumbnail_medium_s3_key,omitempty" gorm:"column:thumbnail_medium_s3_key"` // Medium (1024px) thumbnail S3 key
 	FileType            string            `json:"file_type,omitempty" gorm:"column:file_type"` // image, video, audio, file
-	URL                 string            `json:"url,omitempty" gorm:"-"` // Computed: presigned URL (populated by ConvertEventMediaToPresignedURLs)
+	OCRText             string            `json:"ocr_text,omitempty" gorm:"column:ocr_text"` // Text extracted from press clippings by the OCR worker
+	ScanStatus          *string           `json:"scan_status,omitempty" gorm:"column:scan_status"` // MediaScanStatus*; nil until scanned
+	ScanDetail          string            `json:"scan_detail,omitempty" gorm:"column:scan_detail"` // Signature or reason reported by the scanner
+	ScannedOn           *time.Time        `json:"scanned_on,omitempty" gorm:"column:scanned_on"`
+	PHash               *int64            `json:"-" gorm:"column:phash"` // Perceptual hash of images, see services.FindMediaDuplicates
+	TranscodeStatus     *string           `json:"transcode_status,omitempty" gorm:"column:transcode_status"` // MediaTranscodeStatus*; nil for files served as uploaded
+	TranscodeDetail     string            `json:"transcode_detail,omitempty" gorm:"column:transcode_detail"` // Why transcoding failed
+	TranscodedS3Key     *string           `json:"transcoded_s3_key,omitempty" gorm:"column:transcoded_s3_key"` // MP4 rendition of videos browsers cannot play
+	Category            string            `json:"category,omitempty"` // Event Photos, Video Coverage, Press Clippings, Other
+	Caption             string            `json:"caption,omitempty"`
+	SortOrder           int               `json:"sort_order" gorm:"column:sort_order;default:0"` // Gallery position (ascending)
+	IsPublic            bool              `json:"is_public" gorm:"column:is_public;<-:false"` // Served from content-addressed public URLs, see services.SetEventMediaPublic
+	URL                 string            `json:"url,omitempty" gorm:"-"` // Computed: presigned URL (populated by ConvertEventMediaToPresignedURLs); the transcoded rendition when there is one
+	OriginalURL         string            `json:"original_url,omitempty" gorm:"-"` // Computed: presigned URL of the uploaded file when url is a transcoded rendition
+	ThumbnailURL        string            `json:"thumbnail_url,omitempty" gorm:"-"` // Computed: presigned small thumbnail URL
+	MediumURL           string            `json:"medium_url,omitempty" gorm:"-"`    // Computed: presigned medium thumbnail URL
+	PublicURL           string            `json:"public_url,omitempty" gorm:"-"` // Computed: long-lived public URL of public media
+	PublicThumbnailURL  string            `json:"public_thumbnail_url,omitempty" gorm:"-"`
+	PublicMediumURL     string            `json:"public_medium_url,omitempty" gorm:"-"`
 	CreatedOn           time.Time         `gorm:"autoCreateTime" json:"created_on"`
 	UpdatedOn           time.Time         `gorm:"autoUpdateTime" json:"updated_on"`
 	CreatedBy           string            `json:"created_by,omitempty" gorm:"<-:create"` // only set on create
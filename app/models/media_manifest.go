@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// MediaManifest records one signed snapshot of the uploaded documents (media ID -> S3 key -> checksum).
+// The manifest itself lives in S3; this row keeps the digest of its payload so a manifest that
+// was edited or swapped in the bucket is detected even when the signing key is compromised.
+type MediaManifest struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Bucket         string    `gorm:"not null" json:"bucket"`
+	S3Key          string    `gorm:"column:s3_key;not null" json:"s3_key"`
+	EntryCount     int       `gorm:"not null" json:"entry_count"`
+	MissingCount   int       `gorm:"not null" json:"missing_count"` // rows whose object was not in the bucket when the manifest was built
+	PayloadSHA256  string    `gorm:"column:payload_sha256;not null" json:"payload_sha256"`
+	PreviousSHA256 string    `gorm:"column:previous_sha256" json:"previous_sha256,omitempty"` // payload digest of the manifest before this one (hash chain)
+	Encrypted      bool      `gorm:"not null;default:false" json:"encrypted"`
+	Source         string    `gorm:"not null" json:"source"` // scheduled or manual
+	CreatedBy      *uint     `json:"created_by,omitempty"`
+	CreatedOn      time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (MediaManifest) TableName() string {
+	return "media_manifests"
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Legal hold entity types
+const (
+	LegalHoldEntityEvent       = "event"
+	LegalHoldEntityEventMedia  = "event_media"
+	LegalHoldEntityBranchMedia = "branch_media"
+)
+
+// MediaTombstone is left behind when an event or branch media record is deleted, so the
+// record of who removed which file (and what it was) outlives the row and the S3 object.
+type MediaTombstone struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	MediaType        string     `gorm:"type:varchar(20);not null" json:"media_type"` // event_media or branch_media
+	MediaID          uint       `gorm:"not null" json:"media_id"`
+	EventID          *uint      `json:"event_id,omitempty"`
+	BranchID         *uint      `json:"branch_id,omitempty"`
+	S3Key            string     `gorm:"column:s3_key" json:"s3_key,omitempty"`
+	OriginalFilename string     `json:"original_filename,omitempty"`
+	FileType         string     `json:"file_type,omitempty"`
+	Category         string     `json:"category,omitempty"`
+	Metadata         JSONB      `gorm:"type:jsonb" json:"metadata,omitempty"` // the full record as it was deleted
+	DeletedBy        *uint      `json:"deleted_by,omitempty"`
+	DeletedByRoleID  *uint      `json:"deleted_by_role_id,omitempty"`
+	IP               string     `json:"ip,omitempty"`
+	Path             string     `json:"path,omitempty"`
+	DeletedOn        time.Time  `gorm:"autoCreateTime" json:"deleted_on"`
+	RestoredOn       *time.Time `json:"restored_on,omitempty"` // soft-deleted branch media brought back
+}
+
+func (MediaTombstone) TableName() string {
+	return "media_tombstones"
+}
+
+// LegalHold blocks deletion of an event (and all of its media) or a single media record
+// while litigation or an audit is pending. Released holds are kept for the record.
+type LegalHold struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	EntityType string     `gorm:"type:varchar(20);not null" json:"entity_type"` // LegalHoldEntity*
+	EntityID   uint       `gorm:"not null" json:"entity_id"`
+	Reason     string     `gorm:"not null" json:"reason"`
+	PlacedBy   *uint      `json:"placed_by,omitempty"`
+	PlacedOn   time.Time  `gorm:"autoCreateTime" json:"placed_on"`
+	ReleasedBy *uint      `json:"released_by,omitempty"`
+	ReleasedOn *time.Time `json:"released_on,omitempty"`
+	Active     bool       `gorm:"-" json:"active"`
+}
+
+func (LegalHold) TableName() string {
+	return "legal_holds"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationEventPendingApproval = "event_pending_approval"
+	NotificationEventReviewed        = "event_reviewed"
+	NotificationPasswordExpiring     = "password_expiring"
+	NotificationJobFinished          = "job_finished"
+)
+
+// Notification is an item of a user's in-app notification center, created by the services
+// when something needs the user's attention (see services/notification_center_service.go)
+type Notification struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	UserID     uint   `gorm:"not null;index" json:"user_id"`
+	Type       string `gorm:"type:varchar(50);not null" json:"type"`
+	Title      string `gorm:"not null" json:"title"`
+	Body       string `json:"body,omitempty"`
+	Link       string `json:"link,omitempty"`        // frontend path, e.g. /events/12
+	EntityType string `json:"entity_type,omitempty"` // what the notification is about, e.g. event
+	EntityID   *uint  `json:"entity_id,omitempty"`
+	// Notifications sharing a key are only created once per user
+	DedupKey *string `json:"-"`
+
+	ReadOn    *time.Time `json:"read_on,omitempty"`
+	CreatedOn time.Time  `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
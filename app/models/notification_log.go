@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Notification delivery statuses
+const (
+	NotificationStatusSent   = "sent"
+	NotificationStatusFailed = "failed"
+	NotificationStatusLogged = "logged" // no driver configured for the channel, nothing was delivered
+)
+
+// NotificationLog records every outgoing notification. Only the recipient, template and
+// subject are kept: bodies can contain temporary passwords or reset links.
+type NotificationLog struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	Channel           string    `gorm:"not null" json:"channel"` // email, sms or whatsapp
+	Template          string    `gorm:"not null;index" json:"template"`
+	Recipient         string    `gorm:"not null;index" json:"recipient"`
+	Subject           string    `json:"subject,omitempty"`
+	Status            string    `gorm:"not null" json:"status"`
+	Provider          string    `json:"provider"` // log, smtp, ses, twilio, msg91 or meta
+	ProviderMessageID string    `json:"provider_message_id,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	UserID            *uint     `gorm:"index" json:"user_id,omitempty"` // the recipient's account, if any
+	EntityType        string    `json:"entity_type,omitempty"`          // what the notification is about, e.g. event
+	EntityID          *uint     `json:"entity_id,omitempty"`
+	CreatedOn         time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (NotificationLog) TableName() string {
+	return "notification_logs"
+}
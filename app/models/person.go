@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"gorm.io/gorm"
+)
+
+// Person is the individual behind branch members, volunteers, donors and users. Each of
+// those rows references a person so the same individual is not four unrelated records.
+// swagger:model Person
+type Person struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string     `gorm:"not null" json:"name"`
+	NameKey     string     `gorm:"column:name_key;index" json:"-"` // script-independent phonetic key (utils.NameKey)
+	Email       string     `gorm:"column:email;index" json:"email,omitempty"`
+	Contact     string     `gorm:"column:contact;index" json:"contact,omitempty"` // normalized, see NormalizeContact
+	DateOfBirth *time.Time `gorm:"column:date_of_birth" json:"date_of_birth,omitempty"`
+	// MergedIntoID is set when the person was merged into another one as a duplicate
+	MergedIntoID *uint     `gorm:"column:merged_into_id;index" json:"merged_into_id,omitempty"`
+	CreatedOn    time.Time `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn    time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (Person) TableName() string {
+	return "persons"
+}
+
+// BeforeSave keeps NameKey in sync with Name
+func (p *Person) BeforeSave(tx *gorm.DB) error {
+	if p.Name != "" {
+		p.NameKey = utils.NameKey(p.Name)
+	}
+	return nil
+}
+
+// NormalizeContact reduces a phone number to its digits, dropping an Indian country code,
+// so "+91 98765-43210" and "9876543210" compare equal
+func NormalizeContact(contact string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) && r < unicode.MaxASCII {
+			return r
+		}
+		return -1
+	}, contact)
+	if len(digits) == 12 && strings.HasPrefix(digits, "91") {
+		digits = digits[2:]
+	}
+	return digits
+}
+
+// LinkPerson returns the ID of the person a record belongs to. An existing (not merged)
+// person is reused when the email, the contact number, or the name together with the date
+// of birth match; otherwise a new person is created. Missing details on a matched person
+// are filled in from the candidate. Name alone never links records, those are left to the
+// duplicate review.
+func LinkPerson(tx *gorm.DB, candidate Person) (uint, error) {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	candidate.Name = strings.TrimSpace(candidate.Name)
+	candidate.Email = strings.ToLower(strings.TrimSpace(candidate.Email))
+	candidate.Contact = NormalizeContact(candidate.Contact)
+
+	var existing Person
+	err := gorm.ErrRecordNotFound
+	if candidate.Email != "" {
+		err = db.Where("email = ? AND merged_into_id IS NULL", candidate.Email).Order("id").First(&existing).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && candidate.Contact != "" {
+		err = db.Where("contact = ? AND merged_into_id IS NULL", candidate.Contact).Order("id").First(&existing).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && candidate.DateOfBirth != nil && candidate.Name != "" {
+		err = db.Where("name_key = ? AND date_of_birth = ? AND merged_into_id IS NULL",
+			utils.NameKey(candidate.Name), *candidate.DateOfBirth).Order("id").First(&existing).Error
+	}
+
+	switch {
+	case err == nil:
+		updates := map[string]interface{}{}
+		if existing.Email == "" && candidate.Email != "" {
+			updates["email"] = candidate.Email
+		}
+		if existing.Contact == "" && candidate.Contact != "" {
+			updates["contact"] = candidate.Contact
+		}
+		if existing.DateOfBirth == nil && candidate.DateOfBirth != nil {
+			updates["date_of_birth"] = candidate.DateOfBirth
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&Person{}).Where("id = ?", existing.ID).Updates(updates).Error; err != nil {
+				return 0, err
+			}
+		}
+		return existing.ID, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	if err := db.Create(&candidate).Error; err != nil {
+		return 0, err
+	}
+	return candidate.ID, nil
+}
+
+// linkPersonID sets *personID for a new record unless it was already given
+func linkPersonID(tx *gorm.DB, personID **uint, candidate Person) error {
+	if *personID != nil || strings.TrimSpace(candidate.Name) == "" {
+		return nil
+	}
+	id, err := LinkPerson(tx, candidate)
+	if err != nil {
+		return err
+	}
+	*personID = &id
+	return nil
+}
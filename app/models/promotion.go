@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // PromotionMaterial represents types of promotion materials
@@ -18,6 +20,7 @@ func (PromotionMaterial) TableName() string {
 type Event struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	EventName string `gorm:"not null" json:"event_name"`
+	DeletedAt gorm.DeletedAt `json:"-"` // shares event_details.deleted_at so soft-deleted events are hidden
 }
 
 func (Event) TableName() string {
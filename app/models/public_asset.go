@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PublicAsset is a variant (the original or a thumbnail) of public event media, addressed by the
+// SHA-256 of its content
+type PublicAsset struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	SHA256       string    `gorm:"column:sha256;not null" json:"sha256"`
+	EventMediaID uint      `gorm:"not null" json:"event_media_id"`
+	Variant      string    `gorm:"not null" json:"variant"` // services.PublicAssetOriginal or a thumbnail size name
+	S3Key        string    `gorm:"column:s3_key;not null" json:"-"`
+	ContentType  string    `gorm:"not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	CreatedOn    time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (PublicAsset) TableName() string {
+	return "public_assets"
+}
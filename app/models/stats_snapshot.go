@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// StatsSnapshot is the stored figures of one branch (its own, without child branches) on a
+// date, so statistics can be reproduced as they were reported even after later corrections
+type StatsSnapshot struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	SnapshotDate     time.Time `gorm:"type:date;not null;uniqueIndex:idx_stats_snapshots_date_branch" json:"snapshot_date"`
+	BranchID         uint      `gorm:"not null;uniqueIndex:idx_stats_snapshots_date_branch" json:"branch_id"`
+	ParentBranchID   *uint     `json:"parent_branch_id,omitempty"` // the branch tree as it was on the date
+	Name             string    `json:"name"`
+	Events           int64     `json:"events"`
+	EventsComplete   int64     `json:"events_complete"`
+	BeneficiaryMen   int64     `json:"beneficiary_men"`
+	BeneficiaryWomen int64     `json:"beneficiary_women"`
+	BeneficiaryChild int64     `json:"beneficiary_child"`
+	InitiationMen    int64     `json:"initiation_men"`
+	InitiationWomen  int64     `json:"initiation_women"`
+	InitiationChild  int64     `json:"initiation_child"`
+	Donations        int64     `json:"donations"`
+	DonationAmount   float64   `json:"donation_amount"`
+	Members          int64     `json:"members"`
+	CreatedOn        time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (StatsSnapshot) TableName() string {
+	return "stats_snapshots"
+}
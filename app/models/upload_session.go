@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// UploadSession is a resumable upload of one event or branch media file, see
+// services.CreateUploadSession. Chunks are appended in order and stored as the parts of a
+// multipart upload; completing the session creates the media record.
+type UploadSession struct {
+	ID              string     `gorm:"primaryKey" json:"id"` // random UUID, also the URL of the session
+	UserID          uint       `gorm:"not null" json:"user_id"`
+	Target          string     `gorm:"not null" json:"target"`   // event_media or branch_media
+	OwnerID         uint       `gorm:"not null" json:"owner_id"` // event or branch ID
+	Category        string     `json:"category,omitempty"`
+	Filename        string     `gorm:"not null" json:"filename"`
+	ContentType     string     `gorm:"not null" json:"content_type"`
+	Size            int64      `gorm:"not null" json:"size"`
+	ChunkSize       int64      `gorm:"not null" json:"chunk_size"`       // every chunk but the last has exactly this size
+	ReceivedBytes   int64      `gorm:"not null;default:0" json:"offset"` // where the next chunk starts
+	S3Key           string     `gorm:"column:s3_key;not null" json:"-"`
+	StorageUploadID string     `gorm:"not null" json:"-"`
+	Parts           JSONB      `gorm:"type:jsonb;not null;default:'{}'" json:"-"` // part number -> ETag
+	MediaID         *uint      `json:"media_id,omitempty"`                        // set once completed
+	CompletedOn     *time.Time `json:"completed_on,omitempty"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedOn       time.Time  `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn       time.Time  `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
@@ -2,7 +2,15 @@ package models
 
 import (
 	"time"
-	//"gorm.io/gorm"
+
+	"gorm.io/gorm"
+)
+
+// Well-known role IDs (see roles seed data)
+const (
+	RoleAdmin   uint = 1 // full access
+	RoleManager uint = 2 // branch/event management and reviews
+	RoleUser    uint = 3 // read only
 )
 
 type Role struct {
@@ -20,6 +28,7 @@ type User struct {
 	Name          string     `gorm:"not null" json:"name" validate:"required,min=2,max=255"`
 	Email         string     `gorm:"unique;not null" json:"email" validate:"required,email,max=255"`
 	ContactNumber string     `json:"contact_number,omitempty" validate:"omitempty,max=20"`
+	PersonID      *uint      `gorm:"column:person_id;index" json:"person_id,omitempty"`
 	Password      string     `gorm:"not null" json:"password,omitempty"`
 	RoleID        uint       `gorm:"not null" json:"role_id" validate:"required"`
 	Role          Role       `gorm:"foreignKey:RoleID" json:"role,omitempty"`
@@ -32,4 +41,64 @@ type User struct {
 	UpdatedOn     *time.Time `gorm:"autoUpdateTime" json:"updated_on,omitempty"`
 	CreatedBy     string     `json:"created_by,omitempty"`
 	UpdatedBy     string     `json:"updated_by,omitempty"`
+
+	// Version is bumped by every profile update, for optimistic locking of edits (If-Match)
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	// Branch the user coordinates (may submit change requests for it and its child branches)
+	// and, for managers, the region whose branch change requests they review
+	BranchID *uint `gorm:"column:branch_id" json:"branch_id,omitempty"`
+	RegionID *uint `gorm:"column:region_id" json:"region_id,omitempty"`
+
+	// Deactivated accounts cannot sign in (POST /api/admin/users/:id/deactivate); unlike
+	// IsDeleted they stay listed and can be reactivated
+	DisabledAt *time.Time `gorm:"column:disabled_at" json:"disabled_at,omitempty"`
+	// Access tokens issued before this are rejected (forced logout)
+	TokensRevokedAt *time.Time `gorm:"column:tokens_revoked_at" json:"-"`
+	// Set once two-factor authentication is enrolled; the secret and recovery codes are only
+	// read by the auth package (see services/auth/totp.go)
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at,omitempty"`
+
+	// Password rotation: set for temporary (admin issued) passwords and enforced by AuthMiddleware
+	MustChangePassword bool       `gorm:"default:false" json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+
+	// Reviewer email digest of pending approvals: daily, weekly or off (GET/PUT /api/me/preferences)
+	DigestFrequency string     `gorm:"column:digest_frequency;default:daily" json:"digest_frequency,omitempty"`
+	DigestSentOn    *time.Time `gorm:"column:digest_sent_on" json:"digest_sent_on,omitempty"`
+
+	// Where critical alerts (credentials, event review results) go besides email: email (only),
+	// sms or whatsapp to ContactNumber (GET/PUT /api/me/preferences)
+	NotificationChannel string `gorm:"column:notification_channel;default:email" json:"notification_channel,omitempty"`
+}
+
+// Channels of User.NotificationChannel
+const (
+	NotificationChannelEmail    = "email"
+	NotificationChannelSMS      = "sms"
+	NotificationChannelWhatsApp = "whatsapp"
+)
+
+// Digest frequencies of User.DigestFrequency
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+)
+
+// BeforeCreate links the user to a person
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	return linkPersonID(tx, &u.PersonID, Person{Name: u.Name, Email: u.Email, Contact: u.ContactNumber})
+}
+
+// PasswordHistory keeps the hashes of a user's recent passwords so they cannot be reused
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_history"
 }
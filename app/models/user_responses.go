@@ -1,18 +1,20 @@
 package models
 
-// CreateUserResponse represents the response when creating a user
+// CreateUserResponse represents the response when creating a user. The temporary password
+// is never returned: it is emailed to the user, and sent by SMS or WhatsApp when that is their
+// notification channel. When none of them could be delivered (EmailSent false) the delivery is
+// retried in the background with a new temporary password.
 // swagger:model CreateUserResponse
 type CreateUserResponse struct {
-	Message  string `json:"message"`
-	User     User   `json:"user"`
-	Password string `json:"password"`
+	Message   string `json:"message"`
+	User      User   `json:"user"`
+	EmailSent bool   `json:"email_sent"`
 }
 
-// ResetPasswordResponse represents the response when resetting a user's password
+// ResetPasswordResponse represents the response when resetting a user's password. As with
+// CreateUserResponse the password is only sent to the user, never returned.
 // swagger:model ResetPasswordResponse
 type ResetPasswordResponse struct {
-	Message  string `json:"message"`
-	Password string `json:"password"`
+	Message   string `json:"message"`
+	EmailSent bool   `json:"email_sent"`
 }
-
-
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"gorm.io/gorm"
+)
 
 // Volunteer represents volunteer details captured from UI
 // swagger:model Volunteer
@@ -9,6 +14,8 @@ type Volunteer struct {
 	BranchID      uint       `gorm:"not null" json:"branch_id" validate:"required,min=1"`
 	Branch        Branch     `gorm:"foreignKey:BranchID" json:"branch,omitempty"`
 	VolunteerName string     `gorm:"not null" json:"volunteer_name" validate:"required,min=2,max=255"`
+	NameKey       string     `gorm:"column:name_key;index" json:"-"` // script-independent phonetic key (utils.NameKey)
+	PersonID      *uint      `gorm:"column:person_id;index" json:"person_id,omitempty"`
 	Contact       string     `gorm:"column:contact" json:"contact,omitempty" validate:"omitempty,max=20"`
 	NumberOfDays  int        `gorm:"column:number_of_days" json:"number_of_days,omitempty" validate:"omitempty,min=0,max=365"`
 	SevaInvolved  string     `json:"seva_involved,omitempty" validate:"omitempty,min=2,max=500"`
@@ -19,4 +26,32 @@ type Volunteer struct {
 	UpdatedOn     *time.Time `json:"updated_on,omitempty"`
 	CreatedBy     string     `json:"created_by,omitempty"`
 	UpdatedBy     string     `json:"updated_by,omitempty"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggertype:"string"`
+}
+
+// BeforeCreate links the volunteer to a person: by contact number or, without one, to the
+// person who already volunteered at the branch under the same name
+func (v *Volunteer) BeforeCreate(tx *gorm.DB) error {
+	if v.PersonID == nil && NormalizeContact(v.Contact) == "" && v.BranchID != 0 {
+		if key := utils.NameKey(v.VolunteerName); key != "" {
+			var personIDs []uint
+			if err := tx.Session(&gorm.Session{NewDB: true}).Model(&Volunteer{}).
+				Where("branch_id = ? AND name_key = ? AND person_id IS NOT NULL", v.BranchID, key).
+				Order("id DESC").Limit(1).Pluck("person_id", &personIDs).Error; err != nil {
+				return err
+			}
+			if len(personIDs) > 0 {
+				v.PersonID = &personIDs[0]
+			}
+		}
+	}
+	return linkPersonID(tx, &v.PersonID, Person{Name: v.VolunteerName, Contact: v.Contact})
+}
+
+// BeforeSave keeps NameKey in sync with VolunteerName
+func (v *Volunteer) BeforeSave(tx *gorm.DB) error {
+	if v.VolunteerName != "" {
+		v.NameKey = utils.NameKey(v.VolunteerName)
+	}
+	return nil
 }
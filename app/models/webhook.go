@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// WebhookSubscription is an integrator endpoint that receives signed JSON payloads.
+// Empty EventTypes, BranchIDs and RegionIDs mean "all"; see services.WebhookEventTypes.
+type WebhookSubscription struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"not null" json:"name"`
+	URL         string `gorm:"not null" json:"url"`
+	Secret      string `gorm:"not null" json:"-"` // HMAC key for X-Webhook-Signature, shown once on create
+	Description string `json:"description,omitempty"`
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+
+	EventTypes []string `gorm:"type:jsonb;serializer:json" json:"event_types"`
+	// Scope: only deliver events of these branches (including their child branches) or regions
+	BranchIDs []uint `gorm:"type:jsonb;serializer:json" json:"branch_ids"`
+	RegionIDs []uint `gorm:"type:jsonb;serializer:json" json:"region_ids"`
+
+	LastTestedOn   *time.Time `json:"last_tested_on,omitempty"`
+	LastTestStatus int        `json:"last_test_status,omitempty"` // HTTP status of the last test delivery, 0 if it failed
+	LastTestError  string     `json:"last_test_error,omitempty"`
+
+	CreatedBy *uint     `json:"created_by,omitempty"`
+	CreatedOn time.Time `gorm:"autoCreateTime" json:"created_on"`
+	UpdatedOn time.Time `gorm:"autoUpdateTime" json:"updated_on"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending" // waiting for its first or next attempt
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // no attempt left, or the subscription is gone or inactive
+)
+
+// WebhookDelivery is one event sent to a subscription, with the outcome of its latest attempt.
+// Attempts are made by a webhook_delivery job, which retries with backoff; every attempt
+// sends the same DeliveryID so receivers can drop duplicates.
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"not null;index" json:"subscription_id"`
+	DeliveryID     string    `gorm:"not null;uniqueIndex" json:"delivery_id"` // X-Webhook-Delivery
+	EventType      string    `gorm:"not null" json:"event_type"`
+	OccurredAt     time.Time `gorm:"not null" json:"occurred_at"`
+	Data           JSONB     `gorm:"type:jsonb" json:"data"`
+
+	Status        string     `gorm:"not null;default:pending;index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptOn *time.Time `json:"next_attempt_on,omitempty"`
+	JobID         *uint      `json:"job_id,omitempty"`
+
+	// Latest attempt
+	LastAttemptOn *time.Time `json:"last_attempt_on,omitempty"`
+	StatusCode    int        `json:"status_code,omitempty"` // 0 when no response was received
+	DurationMs    int64      `json:"duration_ms"`
+	ResponseBody  string     `json:"response_body,omitempty"` // first 1KB
+	Error         string     `json:"error,omitempty"`
+
+	CreatedOn time.Time `gorm:"autoCreateTime" json:"created_on"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
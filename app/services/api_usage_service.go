@@ -0,0 +1,254 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// apiUsageFlushInterval is how often in-memory usage counters are written to api_usage_daily
+const apiUsageFlushInterval = time.Minute
+
+// maxAPIUsageBuffer bounds the number of distinct counters kept between flushes, so a client
+// cycling through client IDs cannot grow the buffer without limit
+const maxAPIUsageBuffer = 20000
+
+type apiUsageKey struct {
+	Day    string
+	UserID uint
+	Client string
+	Method string
+	Route  string
+}
+
+type apiUsageCounter struct {
+	Requests, Errors, TotalMs, MaxMs int64
+}
+
+var apiUsageBuffer = struct {
+	sync.Mutex
+	counters map[apiUsageKey]*apiUsageCounter
+}{counters: map[apiUsageKey]*apiUsageCounter{}}
+
+// RecordAPIUsage counts one request in memory. Counters are persisted by StartAPIUsageFlusher.
+// Requests beyond the buffer limit are dropped rather than slowing down the request path.
+func RecordAPIUsage(userID uint, client, method, route string, status int, duration time.Duration) {
+	key := apiUsageKey{
+		Day:    time.Now().UTC().Format("2006-01-02"),
+		UserID: userID,
+		Client: client,
+		Method: method,
+		Route:  route,
+	}
+	ms := duration.Milliseconds()
+
+	apiUsageBuffer.Lock()
+	defer apiUsageBuffer.Unlock()
+	counter, ok := apiUsageBuffer.counters[key]
+	if !ok {
+		if len(apiUsageBuffer.counters) >= maxAPIUsageBuffer {
+			return
+		}
+		counter = &apiUsageCounter{}
+		apiUsageBuffer.counters[key] = counter
+	}
+	counter.Requests++
+	if status >= 500 {
+		counter.Errors++
+	}
+	counter.TotalMs += ms
+	if ms > counter.MaxMs {
+		counter.MaxMs = ms
+	}
+}
+
+// StartAPIUsageFlusher periodically adds the buffered usage counters to api_usage_daily
+func StartAPIUsageFlusher() {
+	go func() {
+		ticker := time.NewTicker(apiUsageFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := FlushAPIUsage(); err != nil {
+				utils.BaseLogger().Error("Failed to flush API usage", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// FlushAPIUsage writes the buffered counters to api_usage_daily. Counters that fail to be
+// written are dropped: usage analytics are best effort.
+func FlushAPIUsage() error {
+	apiUsageBuffer.Lock()
+	counters := apiUsageBuffer.counters
+	apiUsageBuffer.counters = map[apiUsageKey]*apiUsageCounter{}
+	apiUsageBuffer.Unlock()
+	if len(counters) == 0 {
+		return nil
+	}
+
+	rows := make([]models.APIUsageDaily, 0, len(counters))
+	now := time.Now()
+	for key, counter := range counters {
+		day, _ := time.Parse("2006-01-02", key.Day)
+		rows = append(rows, models.APIUsageDaily{
+			Day:             day,
+			UserID:          key.UserID,
+			Client:          key.Client,
+			Method:          key.Method,
+			Route:           key.Route,
+			RequestCount:    counter.Requests,
+			ErrorCount:      counter.Errors,
+			TotalDurationMs: counter.TotalMs,
+			MaxDurationMs:   counter.MaxMs,
+			UpdatedOn:       now,
+		})
+	}
+
+	return config.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "user_id"}, {Name: "client"}, {Name: "method"}, {Name: "route"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"request_count":     gorm.Expr("api_usage_daily.request_count + excluded.request_count"),
+			"error_count":       gorm.Expr("api_usage_daily.error_count + excluded.error_count"),
+			"total_duration_ms": gorm.Expr("api_usage_daily.total_duration_ms + excluded.total_duration_ms"),
+			"max_duration_ms":   gorm.Expr("GREATEST(api_usage_daily.max_duration_ms, excluded.max_duration_ms)"),
+			"updated_on":        now,
+		}),
+	}).CreateInBatches(rows, 500).Error
+}
+
+// APIUsageFilter holds the supported filters of the usage report
+type APIUsageFilter struct {
+	From, To time.Time // inclusive days
+	UserID   *uint
+	Client   string
+	Limit    int // rows per section of the report
+}
+
+// APIUsageStats are the aggregated counters of one report row
+type APIUsageStats struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+}
+
+// APIUsageByUser is the usage of one user (0 for unauthenticated requests)
+type APIUsageByUser struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	APIUsageStats
+}
+
+// APIUsageByClient is the usage of one client ID
+type APIUsageByClient struct {
+	Client string `json:"client"`
+	APIUsageStats
+}
+
+// APIUsageByEndpoint is the usage of one route
+type APIUsageByEndpoint struct {
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	APIUsageStats
+}
+
+// APIUsageByDay is the usage of one day
+type APIUsageByDay struct {
+	Day string `json:"day"`
+	APIUsageStats
+}
+
+// APIUsageReport is the response of the admin usage endpoint. Counters of the last minute
+// may not be included yet, see StartAPIUsageFlusher.
+type APIUsageReport struct {
+	From         string               `json:"from"`
+	To           string               `json:"to"`
+	Totals       APIUsageStats        `json:"totals"`
+	Daily        []APIUsageByDay      `json:"daily"`
+	Users        []APIUsageByUser     `json:"users"`
+	Clients      []APIUsageByClient   `json:"clients"`
+	TopEndpoints []APIUsageByEndpoint `json:"top_endpoints"`
+}
+
+const apiUsageStatsSelect = `COALESCE(SUM(request_count), 0) AS requests, COALESCE(SUM(error_count), 0) AS errors,
+	COALESCE(SUM(total_duration_ms)::float / NULLIF(SUM(request_count), 0), 0) AS avg_latency_ms,
+	COALESCE(MAX(max_duration_ms), 0) AS max_latency_ms`
+
+// GetAPIUsage returns request counts and latencies between filter.From and filter.To, per day
+// and broken down into the busiest users, clients and endpoints
+func GetAPIUsage(filter APIUsageFilter) (*APIUsageReport, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	from, to := filter.From.Format("2006-01-02"), filter.To.Format("2006-01-02")
+
+	base := func() *gorm.DB {
+		query := config.DB.Model(&models.APIUsageDaily{}).Where("day BETWEEN ? AND ?", from, to)
+		if filter.UserID != nil {
+			query = query.Where("user_id = ?", *filter.UserID)
+		}
+		if filter.Client != "" {
+			query = query.Where("client = ?", filter.Client)
+		}
+		return query
+	}
+
+	report := &APIUsageReport{
+		From:         from,
+		To:           to,
+		Daily:        []APIUsageByDay{},
+		Users:        []APIUsageByUser{},
+		Clients:      []APIUsageByClient{},
+		TopEndpoints: []APIUsageByEndpoint{},
+	}
+	if err := base().Select(apiUsageStatsSelect).Scan(&report.Totals).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("TO_CHAR(day, 'YYYY-MM-DD') AS day, " + apiUsageStatsSelect).
+		Group("day").Order("day").Scan(&report.Daily).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("user_id, " + apiUsageStatsSelect).
+		Group("user_id").Order("requests DESC").Limit(filter.Limit).Scan(&report.Users).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("client, " + apiUsageStatsSelect).
+		Group("client").Order("requests DESC").Limit(filter.Limit).Scan(&report.Clients).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("method, route, " + apiUsageStatsSelect).
+		Group("method, route").Order("requests DESC").Limit(filter.Limit).Scan(&report.TopEndpoints).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(report.Users))
+	for _, u := range report.Users {
+		if u.UserID != 0 {
+			userIDs = append(userIDs, u.UserID)
+		}
+	}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := config.DB.Select("id", "name", "email").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		for i := range report.Users {
+			if u, ok := byID[report.Users[i].UserID]; ok {
+				report.Users[i].Name = u.Name
+				report.Users[i].Email = u.Email
+			}
+		}
+	}
+	return report, nil
+}
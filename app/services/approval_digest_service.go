@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/mail"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/messaging"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// digestListLimit caps each section of a digest; the totals still count everything
+	digestListLimit = 20
+	// digestFlagScan is how many pending events are checked for data-quality flags
+	digestFlagScan = 200
+	// defaultReportDueDays is how long after an event ends its report is expected to be submitted
+	defaultReportDueDays = 7
+)
+
+// ErrInvalidPreferences is returned for unknown preference values
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+// ApprovalDigestEvent is one event listed in a digest
+type ApprovalDigestEvent struct {
+	ID             uint      `json:"id"`
+	ReportNumber   string    `json:"report_number"`
+	Theme          string    `json:"theme,omitempty"`
+	Branch         string    `json:"branch,omitempty"`
+	ApprovalStatus string    `json:"approval_status"`
+	StartDate      time.Time `json:"start_date"`
+	EndDate        time.Time `json:"end_date"`
+	Flags          []string  `json:"flags,omitempty"`
+	Link           string    `json:"link,omitempty"`
+}
+
+// ApprovalDigest is what a reviewer is waiting on: events pending their approval, pending
+// events with data-quality problems and reports not submitted long after the event ended
+type ApprovalDigest struct {
+	Pending      []ApprovalDigestEvent `json:"pending"`
+	PendingTotal int64                 `json:"pending_total"`
+	Flagged      []ApprovalDigestEvent `json:"flagged"`
+	Overdue      []ApprovalDigestEvent `json:"overdue"`
+	OverdueTotal int64                 `json:"overdue_total"`
+	DueDays      int                   `json:"due_days"`
+}
+
+// Empty reports whether there is nothing to send
+func (d *ApprovalDigest) Empty() bool {
+	return d.PendingTotal == 0 && d.OverdueTotal == 0
+}
+
+// UserPreferences are the settings users change themselves
+type UserPreferences struct {
+	DigestFrequency string `json:"digest_frequency"` // daily, weekly or off
+	// NotificationChannel is where critical alerts go besides email: email (only), sms or
+	// whatsapp. Left out of an update, it stays as it is.
+	NotificationChannel string `json:"notification_channel,omitempty"`
+}
+
+// GetUserPreferences returns the preferences of a user
+func GetUserPreferences(userID uint) (*UserPreferences, error) {
+	var user models.User
+	if err := config.DB.Select("id", "digest_frequency", "notification_channel").Where("is_deleted = ?", false).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	prefs := &UserPreferences{DigestFrequency: user.DigestFrequency, NotificationChannel: user.NotificationChannel}
+	if prefs.DigestFrequency == "" {
+		prefs.DigestFrequency = models.DigestDaily
+	}
+	if prefs.NotificationChannel == "" {
+		prefs.NotificationChannel = models.NotificationChannelEmail
+	}
+	return prefs, nil
+}
+
+// UpdateUserPreferences stores the preferences of a user. SMS and WhatsApp alerts need a
+// contact number and a configured provider for the channel.
+func UpdateUserPreferences(userID uint, prefs UserPreferences) (*UserPreferences, error) {
+	switch prefs.DigestFrequency {
+	case models.DigestDaily, models.DigestWeekly, models.DigestOff:
+	default:
+		return nil, fmt.Errorf("%w: digest_frequency must be daily, weekly or off", ErrInvalidPreferences)
+	}
+	updates := map[string]interface{}{"digest_frequency": prefs.DigestFrequency}
+	switch prefs.NotificationChannel {
+	case "":
+	case models.NotificationChannelEmail:
+		updates["notification_channel"] = prefs.NotificationChannel
+	case models.NotificationChannelSMS, models.NotificationChannelWhatsApp:
+		var user models.User
+		if err := config.DB.Select("id", "contact_number").Where("is_deleted = ?", false).Limit(1).Find(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		if user.ID == 0 {
+			return nil, ErrUserNotFound
+		}
+		if _, err := messaging.NormalizePhone(user.ContactNumber); user.ContactNumber == "" || err != nil {
+			return nil, fmt.Errorf("%w: %s alerts need a valid contact number on your profile", ErrInvalidPreferences, prefs.NotificationChannel)
+		}
+		if !messaging.Enabled(prefs.NotificationChannel) {
+			return nil, fmt.Errorf("%w: %s alerts are not available", ErrInvalidPreferences, prefs.NotificationChannel)
+		}
+		updates["notification_channel"] = prefs.NotificationChannel
+	default:
+		return nil, fmt.Errorf("%w: notification_channel must be email, sms or whatsapp", ErrInvalidPreferences)
+	}
+	result := config.DB.Model(&models.User{}).
+		Where("id = ? AND is_deleted = ?", userID, false).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	return GetUserPreferences(userID)
+}
+
+// GetApprovalDigest builds the digest of a reviewer (admins and managers; managers with a
+// region only see the events of their region's branches). Other users get an empty digest.
+func GetApprovalDigest(userID uint) (*ApprovalDigest, error) {
+	var user models.User
+	if err := config.DB.Select("id", "role_id", "region_id").Where("is_deleted = ?", false).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return buildApprovalDigest(&user)
+}
+
+func buildApprovalDigest(user *models.User) (*ApprovalDigest, error) {
+	digest := &ApprovalDigest{
+		Pending: []ApprovalDigestEvent{},
+		Flagged: []ApprovalDigestEvent{},
+		Overdue: []ApprovalDigestEvent{},
+		DueDays: reportDueDays(),
+	}
+	if user.RoleID != models.RoleAdmin && user.RoleID != models.RoleManager {
+		return digest, nil
+	}
+
+	if statuses := PendingApprovalStatuses(user.RoleID); len(statuses) > 0 {
+		pending := func() *gorm.DB {
+			return digestEvents(user).Where("approval_status IN ?", statuses)
+		}
+		if err := pending().Count(&digest.PendingTotal).Error; err != nil {
+			return nil, err
+		}
+		var events []models.EventDetails
+		if err := pending().Preload("Branch").
+			Order("updated_on ASC NULLS FIRST, id ASC").
+			Limit(digestFlagScan).Find(&events).Error; err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			item := approvalDigestEvent(&event)
+			item.Flags = eventDataQualityFlags(&event)
+			if len(digest.Pending) < digestListLimit {
+				digest.Pending = append(digest.Pending, item)
+			}
+			if len(item.Flags) > 0 && len(digest.Flagged) < digestListLimit {
+				digest.Flagged = append(digest.Flagged, item)
+			}
+		}
+	}
+
+	dueBefore := time.Now().AddDate(0, 0, -digest.DueDays)
+	overdue := func() *gorm.DB {
+		return digestEvents(user).
+			Where("COALESCE(approval_status, 'draft') IN ?", []string{models.EventStatusDraft, models.EventStatusRejected}).
+			Where("end_date < ?", dueBefore)
+	}
+	if err := overdue().Count(&digest.OverdueTotal).Error; err != nil {
+		return nil, err
+	}
+	var events []models.EventDetails
+	if err := overdue().Preload("Branch").
+		Order("end_date ASC, id ASC").
+		Limit(digestListLimit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		digest.Overdue = append(digest.Overdue, approvalDigestEvent(&event))
+	}
+	return digest, nil
+}
+
+// digestEvents scopes live events to the reviewer's region when they have one. Sandbox
+// (training) events are left out.
+func digestEvents(user *models.User) *gorm.DB {
+	query := excludeSandbox(config.DB.Model(&models.EventDetails{}), "branch_id")
+	if user.RoleID == models.RoleManager && user.RegionID != nil {
+		query = query.Where("branch_id IN (?)",
+			config.DB.Model(&models.Branch{}).Select("id").Where("region_id = ?", *user.RegionID))
+	}
+	return query
+}
+
+func approvalDigestEvent(event *models.EventDetails) ApprovalDigestEvent {
+	item := ApprovalDigestEvent{
+		ID:             event.ID,
+		ReportNumber:   event.ReportNumber,
+		Theme:          strings.TrimSpace(event.Theme),
+		ApprovalStatus: event.ApprovalStatus,
+		StartDate:      event.StartDate,
+		EndDate:        event.EndDate,
+		Link:           mail.Link(fmt.Sprintf("/events/%d", event.ID)),
+	}
+	if item.ReportNumber == "" {
+		item.ReportNumber = fmt.Sprintf("#%d", event.ID)
+	}
+	if event.Branch != nil {
+		item.Branch = event.Branch.Name
+	}
+	return item
+}
+
+// eventDataQualityFlags lists problems a reviewer should look at before approving
+func eventDataQualityFlags(event *models.EventDetails) []string {
+	var flags []string
+	if event.BranchID == nil {
+		flags = append(flags, "no branch")
+	}
+	if strings.TrimSpace(event.Theme) == "" {
+		flags = append(flags, "no theme")
+	}
+	if !event.EndDate.IsZero() && event.EndDate.Before(event.StartDate) {
+		flags = append(flags, "ends before it starts")
+	}
+	if event.StartDate.After(time.Now()) {
+		flags = append(flags, "starts in the future")
+	}
+	if event.BeneficiaryMen+event.BeneficiaryWomen+event.BeneficiaryChild == 0 {
+		flags = append(flags, "no beneficiaries")
+	}
+	if event.InitiationMen < 0 || event.InitiationWomen < 0 || event.InitiationChild < 0 ||
+		event.BeneficiaryMen < 0 || event.BeneficiaryWomen < 0 || event.BeneficiaryChild < 0 {
+		flags = append(flags, "negative counts")
+	}
+	if event.MediaCount == 0 {
+		flags = append(flags, "no media")
+	}
+	return flags
+}
+
+// reportDueDays is EVENT_REPORT_DUE_DAYS (see config.ReportingConfig)
+func reportDueDays() int {
+	return config.Reporting.ReportDueDays
+}
+
+// StartApprovalDigestScheduler queues the digest job once a day at APPROVAL_DIGEST_HOUR
+// (0-23 server time, default 7; "off" disables digests). Instances check every 15 minutes and
+// skip the day once any of them has queued the job; users are also claimed one by one in
+// runApprovalDigestJob, so a race between instances cannot send a digest twice.
+func StartApprovalDigestScheduler() {
+	logger := utils.BaseLogger()
+	hour := config.Jobs.ApprovalDigestHour
+	if hour < 0 {
+		logger.Info("Approval digests disabled by APPROVAL_DIGEST_HOUR")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			now := time.Now()
+			due := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if now.Before(due) {
+				continue
+			}
+			var queued int64
+			if err := config.DB.Model(&models.Job{}).
+				Where("type = ? AND created_on >= ?", JobTypeApprovalDigest, due).
+				Count(&queued).Error; err != nil {
+				logger.Error("Failed to check approval digest jobs", zap.Error(err))
+				continue
+			}
+			if queued > 0 {
+				continue
+			}
+			if _, err := EnqueueJob(context.Background(), JobTypeApprovalDigest, map[string]interface{}{}, JobOptions{}); err != nil {
+				logger.Error("Failed to queue approval digest", zap.Error(err))
+			}
+		}
+	}()
+	logger.Info("Approval digest scheduler started", zap.Int("hour", hour))
+}
+
+// runApprovalDigestJob emails every reviewer whose digest is due. Empty digests are not sent.
+func runApprovalDigestJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var reviewers []models.User
+	if err := config.DB.WithContext(ctx).
+		Select("id", "name", "email", "role_id", "region_id", "digest_frequency", "digest_sent_on").
+		Where("is_deleted = ? AND role_id IN ?", false, []uint{models.RoleAdmin, models.RoleManager}).
+		Where("digest_frequency <> ?", models.DigestOff).
+		Order("id").Find(&reviewers).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	counts := map[string]int{"reviewers": len(reviewers), "sent": 0, "empty": 0, "not_due": 0}
+	for i := range reviewers {
+		user := &reviewers[i]
+		// Leave a few hours of slack so a digest sent a little late yesterday is still due today
+		notAfter := now.Add(-20 * time.Hour)
+		period := models.DigestDaily
+		if user.DigestFrequency == models.DigestWeekly {
+			notAfter, period = now.Add(-7*24*time.Hour+4*time.Hour), models.DigestWeekly
+		}
+		if user.DigestSentOn != nil && user.DigestSentOn.After(notAfter) {
+			counts["not_due"]++
+			continue
+		}
+
+		digest, err := buildApprovalDigest(user)
+		if err != nil {
+			return nil, err
+		}
+		if digest.Empty() {
+			counts["empty"]++
+			continue
+		}
+
+		claim := config.DB.WithContext(ctx).Model(&models.User{}).
+			Where("id = ? AND (digest_sent_on IS NULL OR digest_sent_on <= ?)", user.ID, notAfter).
+			Update("digest_sent_on", now)
+		if claim.Error != nil {
+			return nil, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			counts["not_due"]++
+			continue
+		}
+		QueueEmail(ctx, mail.Notification{
+			To:       user.Email,
+			Template: mail.TemplateApprovalDigest,
+			Data:     approvalDigestMailData(user, period, digest),
+			UserID:   &user.ID,
+		})
+		counts["sent"]++
+		run.SetProgress((i+1)*100/len(reviewers), fmt.Sprintf("Sent %d digests", counts["sent"]))
+	}
+
+	utils.BaseLogger().Info("Approval digests sent", zap.Any("counts", counts))
+	result := models.JSONB{}
+	for key, count := range counts {
+		result[key] = count
+	}
+	return result, nil
+}
+
+// approvalDigestMailData flattens the digest for the template; email jobs store their data as JSON
+func approvalDigestMailData(user *models.User, period string, digest *ApprovalDigest) map[string]interface{} {
+	list := func(events []ApprovalDigestEvent) []map[string]interface{} {
+		items := make([]map[string]interface{}, 0, len(events))
+		for _, e := range events {
+			items = append(items, map[string]interface{}{
+				"ReportNumber": e.ReportNumber,
+				"Theme":        e.Theme,
+				"Branch":       e.Branch,
+				"Status":       strings.ReplaceAll(e.ApprovalStatus, "_", " "),
+				"EndDate":      e.EndDate.Format("2 Jan 2006"),
+				"Flags":        e.Flags,
+				"Link":         e.Link,
+			})
+		}
+		return items
+	}
+	return map[string]interface{}{
+		"Name":           user.Name,
+		"Period":         period,
+		"Pending":        list(digest.Pending),
+		"PendingTotal":   digest.PendingTotal,
+		"Flagged":        list(digest.Flagged),
+		"Overdue":        list(digest.Overdue),
+		"OverdueTotal":   digest.OverdueTotal,
+		"DueDays":        digest.DueDays,
+		"PreferencesURL": mail.Link("/profile"),
+	}
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// archiveURLExpiration is how long the presigned URLs of an archive export stay valid, long
+// enough for the archival tooling to download every file of a large branch
+const archiveURLExpiration = 24 * time.Hour
+
+// maxArchiveBranchDepth guards the child branch walk against cycles in parent_branch_id
+const maxArchiveBranchDepth = 10
+
+// ArchiveExport is a complete JSON snapshot of a branch or an event, taken by the archival team
+// before a branch is decommissioned. Records are exported as stored, soft-deleted ones included
+// (with deleted_at set), and every file is listed once in the media manifest.
+type ArchiveExport struct {
+	ExportedAt    time.Time           `json:"exported_at"`
+	URLsExpireAt  time.Time           `json:"urls_expire_at"`
+	Branch        *BranchArchive      `json:"branch,omitempty"`
+	Event         *EventArchive       `json:"event,omitempty"`
+	MediaManifest []ArchiveMediaEntry `json:"media_manifest"`
+}
+
+// BranchArchive is a branch with everything recorded against it; infrastructure and members are
+// nested in the branch itself
+type BranchArchive struct {
+	Branch         models.Branch                `json:"branch"`
+	Media          []models.BranchMedia         `json:"media"`
+	Handovers      []models.CoordinatorHandover `json:"coordinator_handovers"`
+	ChangeRequests []models.BranchChangeRequest `json:"change_requests"`
+	Events         []EventArchive               `json:"events"`
+	ChildBranches  []BranchArchive              `json:"child_branches"`
+}
+
+// EventArchive is an event with all of its child records
+type EventArchive struct {
+	Event              models.EventDetails               `json:"event"`
+	Recurrence         *models.EventRecurrence           `json:"recurrence,omitempty"`
+	StatusHistory      []models.EventStatusHistory       `json:"status_history"`
+	SpecialGuests      []models.SpecialGuest             `json:"special_guests"`
+	Volunteers         []models.Volunteer                `json:"volunteers"`
+	Donations          []models.Donation                 `json:"donations"`
+	PromotionMaterials []models.PromotionMaterialDetails `json:"promotion_materials"`
+	Media              []models.EventMedia               `json:"media"`
+}
+
+// ArchiveMediaEntry is one file of an archive export. URL is empty when the object could not be
+// presigned (e.g. storage is not configured); the S3 key still identifies it.
+type ArchiveMediaEntry struct {
+	Source           string `json:"source"` // "event_media" or "branch_media"
+	MediaID          uint   `json:"media_id"`
+	OwnerID          uint   `json:"owner_id"` // event or branch ID
+	S3Key            string `json:"s3_key"`
+	OriginalFilename string `json:"original_filename,omitempty"`
+	FileType         string `json:"file_type,omitempty"`
+	Category         string `json:"category,omitempty"`
+	URL              string `json:"url,omitempty"`
+}
+
+// ExportBranchArchive returns the archive of a branch and, recursively, its child branches
+func ExportBranchArchive(ctx context.Context, branchID uint) (*ArchiveExport, error) {
+	db := config.DB.WithContext(ctx).Unscoped()
+
+	var root models.Branch
+	if err := db.First(&root, branchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBranchNotFound
+		}
+		return nil, err
+	}
+
+	// Collect the branch tree level by level
+	ids := []uint{root.ID}
+	parents := map[uint]uint{}
+	level := []uint{root.ID}
+	for depth := 0; depth < maxArchiveBranchDepth && len(level) > 0; depth++ {
+		var rows []models.Branch
+		if err := db.Select("id", "parent_branch_id").Where("parent_branch_id IN ?", level).
+			Order("id").Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		next := make([]uint, 0, len(rows))
+		for _, row := range rows {
+			if _, seen := parents[row.ID]; seen || row.ID == root.ID {
+				continue
+			}
+			parents[row.ID] = *row.ParentBranchID
+			next = append(next, row.ID)
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+
+	var branches []models.Branch
+	if err := db.Preload("Country").Preload("State").Preload("District").Preload("City").
+		Preload("Infrastructures").Preload("Members").
+		Where("id IN ?", ids).Order("id").Find(&branches).Error; err != nil {
+		return nil, err
+	}
+	var media []models.BranchMedia
+	if err := db.Where("branch_id IN ?", ids).Order("branch_id, id").Find(&media).Error; err != nil {
+		return nil, err
+	}
+	var handovers []models.CoordinatorHandover
+	if err := db.Where("branch_id IN ?", ids).Order("branch_id, id").Find(&handovers).Error; err != nil {
+		return nil, err
+	}
+	var changeRequests []models.BranchChangeRequest
+	if err := db.Where("branch_id IN ?", ids).Order("branch_id, id").Find(&changeRequests).Error; err != nil {
+		return nil, err
+	}
+	var events []models.EventDetails
+	if err := db.Preload("EventType").Preload("EventCategory").
+		Where("branch_id IN ?", ids).Order("start_date, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	eventArchives, err := loadEventArchives(db, events)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := make(map[uint]*BranchArchive, len(branches))
+	for _, branch := range branches {
+		archives[branch.ID] = &BranchArchive{
+			Branch:         branch,
+			Media:          []models.BranchMedia{},
+			Handovers:      []models.CoordinatorHandover{},
+			ChangeRequests: []models.BranchChangeRequest{},
+			Events:         []EventArchive{},
+			ChildBranches:  []BranchArchive{},
+		}
+	}
+	export := newArchiveExport()
+	for _, item := range media {
+		archives[item.BranchID].Media = append(archives[item.BranchID].Media, item)
+		export.MediaManifest = append(export.MediaManifest, ArchiveMediaEntry{
+			Source: "branch_media", MediaID: item.ID, OwnerID: item.BranchID, S3Key: item.S3Key,
+			OriginalFilename: item.OriginalFilename, FileType: item.FileType, Category: item.Category,
+		})
+	}
+	for _, handover := range handovers {
+		archives[handover.BranchID].Handovers = append(archives[handover.BranchID].Handovers, handover)
+	}
+	for _, request := range changeRequests {
+		archives[request.BranchID].ChangeRequests = append(archives[request.BranchID].ChangeRequests, request)
+	}
+	for _, event := range eventArchives {
+		archive := archives[*event.Event.BranchID]
+		archive.Events = append(archive.Events, event)
+		export.MediaManifest = append(export.MediaManifest, eventManifestEntries(event.Media)...)
+	}
+
+	// Nest child branches, deepest first so each child is complete when copied into its parent
+	for i := len(ids) - 1; i > 0; i-- {
+		parent := archives[parents[ids[i]]]
+		parent.ChildBranches = append([]BranchArchive{*archives[ids[i]]}, parent.ChildBranches...)
+	}
+	export.Branch = archives[root.ID]
+	presignArchiveManifest(ctx, export)
+	return export, nil
+}
+
+// ExportEventArchive returns the archive of a single event
+func ExportEventArchive(ctx context.Context, eventID uint) (*ArchiveExport, error) {
+	db := config.DB.WithContext(ctx).Unscoped()
+
+	var event models.EventDetails
+	if err := db.Preload("EventType").Preload("EventCategory").Preload("Branch").
+		First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	archives, err := loadEventArchives(db, []models.EventDetails{event})
+	if err != nil {
+		return nil, err
+	}
+
+	export := newArchiveExport()
+	export.Event = &archives[0]
+	export.MediaManifest = eventManifestEntries(export.Event.Media)
+	presignArchiveManifest(ctx, export)
+	return export, nil
+}
+
+func newArchiveExport() *ArchiveExport {
+	now := time.Now()
+	return &ArchiveExport{
+		ExportedAt:    now,
+		URLsExpireAt:  now.Add(archiveURLExpiration),
+		MediaManifest: []ArchiveMediaEntry{},
+	}
+}
+
+// loadEventArchives loads the child records of the events with one query per table
+func loadEventArchives(db *gorm.DB, events []models.EventDetails) ([]EventArchive, error) {
+	archives := make([]EventArchive, len(events))
+	if len(events) == 0 {
+		return archives, nil
+	}
+	ids := make([]uint, len(events))
+	index := make(map[uint]*EventArchive, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+		archives[i] = EventArchive{
+			Event:              event,
+			StatusHistory:      []models.EventStatusHistory{},
+			SpecialGuests:      []models.SpecialGuest{},
+			Volunteers:         []models.Volunteer{},
+			Donations:          []models.Donation{},
+			PromotionMaterials: []models.PromotionMaterialDetails{},
+			Media:              []models.EventMedia{},
+		}
+		index[event.ID] = &archives[i]
+	}
+
+	var recurrences []models.EventRecurrence
+	if err := db.Where("event_id IN ?", ids).Find(&recurrences).Error; err != nil {
+		return nil, err
+	}
+	for i := range recurrences {
+		index[recurrences[i].EventID].Recurrence = &recurrences[i]
+	}
+	var history []models.EventStatusHistory
+	if err := db.Where("event_id IN ?", ids).Order("event_id, id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range history {
+		index[row.EventID].StatusHistory = append(index[row.EventID].StatusHistory, row)
+	}
+	var guests []models.SpecialGuest
+	if err := db.Where("event_id IN ?", ids).Order("event_id, id").Find(&guests).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range guests {
+		index[row.EventID].SpecialGuests = append(index[row.EventID].SpecialGuests, row)
+	}
+	var volunteers []models.Volunteer
+	if err := db.Where("event_id IN ?", ids).Order("event_id, id").Find(&volunteers).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range volunteers {
+		index[row.EventID].Volunteers = append(index[row.EventID].Volunteers, row)
+	}
+	var donations []models.Donation
+	if err := db.Where("event_id IN ?", ids).Order("event_id, id").Find(&donations).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range donations {
+		index[row.EventID].Donations = append(index[row.EventID].Donations, row)
+	}
+	var materials []models.PromotionMaterialDetails
+	if err := db.Preload("PromotionMaterial").Where("event_id IN ?", ids).Order("event_id, id").Find(&materials).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range materials {
+		index[row.EventID].PromotionMaterials = append(index[row.EventID].PromotionMaterials, row)
+	}
+	var media []models.EventMedia
+	if err := db.Preload("MediaCoverageType").Where("event_id IN ?", ids).Order("event_id, sort_order, id").Find(&media).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range media {
+		index[row.EventID].Media = append(index[row.EventID].Media, row)
+	}
+	return archives, nil
+}
+
+func eventManifestEntries(media []models.EventMedia) []ArchiveMediaEntry {
+	entries := make([]ArchiveMediaEntry, 0, len(media))
+	for _, item := range media {
+		entries = append(entries, ArchiveMediaEntry{
+			Source: "event_media", MediaID: item.ID, OwnerID: item.EventID, S3Key: item.S3Key,
+			OriginalFilename: item.OriginalFilename, FileType: item.FileType, Category: item.Category,
+		})
+	}
+	return entries
+}
+
+// presignArchiveManifest fills in the manifest URLs. Presign failures leave URLs empty rather
+// than failing the export; the S3 keys are enough to fetch the files another way.
+func presignArchiveManifest(ctx context.Context, export *ArchiveExport) {
+	keys := make([]string, 0, len(export.MediaManifest))
+	for _, entry := range export.MediaManifest {
+		keys = append(keys, entry.S3Key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if _, err := GetStorage(); err != nil {
+		utils.Logger(ctx).Warn("Archive export without media URLs", zap.Error(err))
+		return
+	}
+	urls, err := PresignURLs(ctx, keys, archiveURLExpiration)
+	if err != nil {
+		utils.Logger(ctx).Warn("Failed to presign archive media", zap.Error(err))
+		return
+	}
+	for i := range export.MediaManifest {
+		export.MediaManifest[i].URL = urls[export.MediaManifest[i].S3Key]
+	}
+}
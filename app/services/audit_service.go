@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// Audited entity types
+const (
+	AuditEntityUser        = "user"
+	AuditEntityBranch      = "branch"
+	AuditEntityChildBranch = "child_branch"
+	AuditEntityEvent       = "event"
+	AuditEntityVolunteer   = "volunteer"
+	AuditEntityDonation    = "donation"
+	AuditEntityEventMedia  = "event_media"
+	AuditEntityBranchMedia = "branch_media"
+	AuditEntityFeatureFlag = "feature_flag"
+	AuditEntityPerson      = "person"
+	AuditEntityWebhook     = "webhook"
+	AuditEntityGuest       = "guest"
+)
+
+// auditModels maps an entity type to a constructor for its model
+var auditModels = map[string]func() interface{}{
+	AuditEntityUser:        func() interface{} { return &models.User{} },
+	AuditEntityBranch:      func() interface{} { return &models.Branch{} },
+	AuditEntityChildBranch: func() interface{} { return &models.Branch{} },
+	AuditEntityEvent:       func() interface{} { return &models.EventDetails{} },
+	AuditEntityVolunteer:   func() interface{} { return &models.Volunteer{} },
+	AuditEntityDonation:    func() interface{} { return &models.Donation{} },
+	AuditEntityEventMedia:  func() interface{} { return &models.EventMedia{} },
+	AuditEntityBranchMedia: func() interface{} { return &models.BranchMedia{} },
+	AuditEntityFeatureFlag: func() interface{} { return &models.FeatureFlag{} },
+	AuditEntityPerson:      func() interface{} { return &models.Person{} },
+	AuditEntityWebhook:     func() interface{} { return &models.WebhookSubscription{} },
+	AuditEntityGuest:       func() interface{} { return &models.Guest{} },
+}
+
+// auditIgnoredFields are never written to the audit log
+var auditIgnoredFields = map[string]bool{
+	"password":   true,
+	"token":      true,
+	"updated_on": true,
+	"url":        true,
+
+	// denormalized counters maintained by database triggers
+	"media_count":              true,
+	"member_count":             true,
+	"event_count":              true,
+	"special_guest_count":      true,
+	"volunteer_count":          true,
+	"donation_count":           true,
+	"promotion_material_count": true,
+	"last_activity_on":         true,
+}
+
+// LoadAuditSnapshot loads an entity and flattens it into a field map for diffing.
+// Relations (nested objects/arrays) and secrets are dropped. Returns nil if the entity does not exist.
+func LoadAuditSnapshot(entityType string, id uint) map[string]interface{} {
+	return loadAuditSnapshot(config.DB, entityType, id)
+}
+
+// loadAuditSnapshot is LoadAuditSnapshot against a specific connection or transaction
+func loadAuditSnapshot(db *gorm.DB, entityType string, id uint) map[string]interface{} {
+	newModel, ok := auditModels[entityType]
+	if !ok || id == 0 {
+		return nil
+	}
+
+	// Unscoped so restores of soft-deleted records are diffed too
+	model := newModel()
+	if err := db.Unscoped().First(model, id).Error; err != nil {
+		return nil
+	}
+	return auditSnapshotOf(model)
+}
+
+// auditSnapshotOf flattens an already loaded entity into a field map
+func auditSnapshotOf(model interface{}) map[string]interface{} {
+	raw, err := json.Marshal(model)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	snapshot := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+// DiffAuditSnapshots returns {"field": {"old": x, "new": y}} for every field that differs
+func DiffAuditSnapshots(before, after map[string]interface{}) models.JSONB {
+	changes := models.JSONB{}
+
+	for key, newValue := range after {
+		oldValue, existed := before[key]
+		if existed && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		change := map[string]interface{}{"new": newValue}
+		if existed {
+			change["old"] = oldValue
+		}
+		changes[key] = change
+	}
+	for key, oldValue := range before {
+		if _, stillThere := after[key]; !stillThere {
+			changes[key] = map[string]interface{}{"old": oldValue}
+		}
+	}
+
+	return changes
+}
+
+// IsAuditedEntity reports whether an entity type is known to the audit log
+func IsAuditedEntity(entityType string) bool {
+	_, ok := auditModels[entityType]
+	return ok
+}
+
+// RecordAuditLog persists an audit entry
+func RecordAuditLog(entry *models.AuditLog) error {
+	if entry.EntityType == "" || entry.Action == "" {
+		return errors.New("audit entry requires entity_type and action")
+	}
+	entry.CreatedOn = time.Now()
+	return config.DB.Create(entry).Error
+}
+
+// AuditActor identifies who made a change that is recorded by a service
+// rather than by the AuditTrail middleware (data fixes, bulk imports)
+type AuditActor struct {
+	UserID *uint
+	RoleID *uint
+	IP     string
+	Method string
+	Path   string
+}
+
+func (a AuditActor) auditEntry(entityType string, entityID uint, action string, changes models.JSONB, at time.Time) *models.AuditLog {
+	return &models.AuditLog{
+		EntityType:  entityType,
+		EntityID:    entityID,
+		Action:      action,
+		Changes:     changes,
+		ActorID:     a.UserID,
+		ActorRoleID: a.RoleID,
+		IP:          a.IP,
+		Method:      a.Method,
+		Path:        a.Path,
+		CreatedOn:   at,
+	}
+}
+
+// AuditLogFilter holds the supported filters for listing audit logs
+type AuditLogFilter struct {
+	EntityType string
+	EntityID   uint
+	ActorID    uint
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// auditLogQuery applies the filter conditions (not paging) to an audit log query
+func auditLogQuery(db *gorm.DB, filter AuditLogFilter) *gorm.DB {
+	query := db.Model(&models.AuditLog{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID > 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID > 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("created_on >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_on <= ?", *filter.To)
+	}
+	return query
+}
+
+// GetAuditLogs lists audit entries, newest first, together with the total match count
+func GetAuditLogs(filter AuditLogFilter) ([]models.AuditLog, int64, error) {
+	logs := []models.AuditLog{}
+	var total int64
+
+	query := auditLogQuery(config.DB, filter)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if err := query.Order("created_on DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// StreamAuditLogs iterates every matching audit entry, newest first, over a database cursor
+// and hands each one to fn without loading the whole result set. Limit and Offset are ignored.
+// Iteration stops at the first error returned by fn or when ctx is cancelled.
+func StreamAuditLogs(ctx context.Context, filter AuditLogFilter, fn func(*models.AuditLog) error) error {
+	db := config.DB.WithContext(ctx)
+	rows, err := auditLogQuery(db, filter).Order("created_on DESC, id DESC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
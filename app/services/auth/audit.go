@@ -22,6 +22,10 @@ const (
 	AuditEventPasswordChanged  AuditEventType = "password_changed"
 	AuditEventSessionRevoked   AuditEventType = "session_revoked"
 	AuditEventTokenRefreshed   AuditEventType = "token_refreshed"
+	AuditEventTOTPEnabled      AuditEventType = "totp_enabled"
+	AuditEventTOTPDisabled     AuditEventType = "totp_disabled"
+	AuditEventRecoveryCodes    AuditEventType = "recovery_codes_generated"
+	AuditEventRecoveryCodeUsed AuditEventType = "recovery_code_used"
 )
 
 // LogAuditEvent logs an authentication event for security auditing
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// expiredAuthRecords are the statements deleting auth rows that stopped mattering before $1.
+// Deleted sessions count as revoked (see IsSessionRevoked), so purging them is safe.
+var expiredAuthRecords = []struct {
+	table string
+	query string
+}{
+	{"sessions", `DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1`},
+	{"verification_tokens", `DELETE FROM verification_tokens WHERE expires_at < $1 OR used_at < $1`},
+	{"password_reset_tokens", `DELETE FROM password_reset_tokens WHERE expires_at < $1 OR used_at < $1`},
+	{"totp_challenges", `DELETE FROM totp_challenges WHERE expires_at < $1`},
+}
+
+// PurgeExpiredRecords deletes sessions, email verification and password reset tokens and
+// two-factor challenges that expired, were revoked or were used before cutoff. It returns the
+// number of rows deleted per table.
+func PurgeExpiredRecords(ctx context.Context, cutoff time.Time) (map[string]int64, error) {
+	deleted := make(map[string]int64, len(expiredAuthRecords))
+	for _, record := range expiredAuthRecords {
+		result, err := config.AuthDB.Exec(ctx, record.query, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to purge %s: %w", record.table, err)
+		}
+		deleted[record.table] = result.RowsAffected()
+	}
+	return deleted, nil
+}
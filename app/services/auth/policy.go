@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// passwordSymbols are the characters that satisfy the symbol rule
+const passwordSymbols = "!@#$%^&*()_+-=[]{};':\"\\|,.<>/?"
+
+// maxPasswordLength caps passwords well below anything that would make hashing expensive
+const maxPasswordLength = 255
+
+// temporaryPasswordLength is the minimum length of generated (admin issued) passwords
+const temporaryPasswordLength = 16
+
+// ErrPasswordReused is returned when a new password matches the current or a recent one
+var ErrPasswordReused = errors.New("password was used recently, choose a different one")
+
+// PasswordPolicyError lists every rule a rejected password violates
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy holds the complexity, history and expiry rules for passwords
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistorySize   int           // number of previous passwords (including the current one) that cannot be reused
+	MaxAge        time.Duration // 0 disables expiry
+}
+
+// CurrentPasswordPolicy returns the policy configured through the PASSWORD_* environment variables
+func CurrentPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     config.PasswordMinLength,
+		RequireUpper:  config.PasswordRequireUpper,
+		RequireLower:  config.PasswordRequireLower,
+		RequireDigit:  config.PasswordRequireDigit,
+		RequireSymbol: config.PasswordRequireSymbol,
+		HistorySize:   config.PasswordHistorySize,
+		MaxAge:        config.PasswordMaxAge,
+	}
+}
+
+// Validate checks the complexity rules and returns a *PasswordPolicyError listing all violations
+func (p PasswordPolicy) Validate(password string) error {
+	var violations []string
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if length > maxPasswordLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters long", maxPasswordLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case strings.ContainsRune(passwordSymbols, r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a special character")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckReuse returns ErrPasswordReused if password matches one of the given hashes
+func (p PasswordPolicy) CheckReuse(password string, previousHashes []string) error {
+	for _, hash := range previousHashes {
+		if hash == "" {
+			continue
+		}
+		if ok, err := VerifyPassword(password, hash); err == nil && ok {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// Expired reports whether a password changed at changedAt is past MaxAge. Users without
+// a recorded change time are not considered expired.
+func (p PasswordPolicy) Expired(changedAt *time.Time, now time.Time) bool {
+	if p.MaxAge <= 0 || changedAt == nil {
+		return false
+	}
+	return now.Sub(*changedAt) > p.MaxAge
+}
+
+// GenerateTemporaryPassword returns a random password that satisfies the policy, for
+// accounts created or reset by an administrator
+func (p PasswordPolicy) GenerateTemporaryPassword() (string, error) {
+	const (
+		uppers  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		lowers  = "abcdefghijkmnopqrstuvwxyz"
+		digits  = "23456789"
+		symbols = "!@#$%^&*-_=+?"
+	)
+	length := p.MinLength
+	if length < temporaryPasswordLength {
+		length = temporaryPasswordLength
+	}
+
+	// One character from every class, the rest from all of them, then shuffle
+	classes := []string{uppers, lowers, digits, symbols}
+	all := strings.Join(classes, "")
+	out := make([]byte, 0, length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, c)
+	}
+	for len(out) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, c)
+	}
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+	return string(out), nil
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
+
+// PasswordChangeRequired reports whether a user has to change their password before using
+// the API: the account carries a temporary password or the password has expired
+func PasswordChangeRequired(mustChange bool, changedAt *time.Time) bool {
+	return mustChange || CurrentPasswordPolicy().Expired(changedAt, time.Now())
+}
+
+// SetPassword validates newPassword against the policy and the user's password history and
+// stores it. temporary marks the password as one the user must change on next login.
+func SetPassword(ctx context.Context, userID int64, newPassword string, temporary bool) error {
+	tx, err := config.AuthDB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setPassword(ctx, tx, userID, newPassword, temporary); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// setPassword is SetPassword within an existing transaction
+func setPassword(ctx context.Context, tx pgx.Tx, userID int64, newPassword string, temporary bool) error {
+	policy := CurrentPasswordPolicy()
+	if err := policy.Validate(newPassword); err != nil {
+		return err
+	}
+
+	// Lock the user row so concurrent changes cannot both pass the history check
+	var current string
+	err := tx.QueryRow(ctx,
+		`SELECT password FROM users WHERE id = $1 AND is_deleted = false FOR UPDATE`,
+		userID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query user: %w", err)
+	}
+
+	if policy.HistorySize > 0 {
+		previous := []string{current}
+		rows, err := tx.Query(ctx,
+			`SELECT password_hash FROM password_history
+			 WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+			userID, policy.HistorySize)
+		if err != nil {
+			return fmt.Errorf("failed to query password history: %w", err)
+		}
+		hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to read password history: %w", err)
+		}
+		if err := policy.CheckReuse(newPassword, append(previous, hashes...)); err != nil {
+			return err
+		}
+	}
+
+	passwordHash, err := HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE users SET password = $1, must_change_password = $2, password_changed_at = NOW(), updated_on = NOW()
+		 WHERE id = $3`,
+		passwordHash, temporary, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return recordPasswordHistory(ctx, tx, userID, passwordHash, policy.HistorySize)
+}
+
+// execer is satisfied by both pgx.Tx and the connection pool
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// recordPasswordHistory appends a hash to the user's password history and prunes entries
+// beyond the configured history size
+func recordPasswordHistory(ctx context.Context, db execer, userID int64, passwordHash string, keep int) error {
+	_, err := db.Exec(ctx,
+		`INSERT INTO password_history (user_id, password_hash, created_at) VALUES ($1, $2, NOW())`,
+		userID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+	_, err = db.Exec(ctx,
+		`DELETE FROM password_history
+		 WHERE user_id = $1 AND id NOT IN (
+		     SELECT id FROM password_history WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+		 )`,
+		userID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	return nil
+}
+
+// RecordPasswordHistory stores the hash of a password set outside SetPassword (e.g. the
+// generated password of a newly created account)
+func RecordPasswordHistory(ctx context.Context, userID int64, passwordHash string) error {
+	return recordPasswordHistory(ctx, config.AuthDB, userID, passwordHash, CurrentPasswordPolicy().HistorySize)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jackc/pgx/v5"
+)
+
+// Access tokens carry their session ID ("sid"). Revoking a session (logout, DELETE
+// /auth/sessions/:id, forced logout, password reset) makes AuthMiddleware and AuthRequired
+// reject the session's access tokens before they expire, not just its refresh token.
+//
+// The sessions table is the revocation list. Sessions found active are cached for
+// sessionCheckTTL, so another instance's revocation takes effect within that time; this
+// instance's revocations take effect immediately.
+const sessionCheckTTL = 10 * time.Second
+
+type sessionCheck struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+var (
+	sessionChecks sync.Map // session ID -> sessionCheck
+	lastPrune     atomic.Int64
+)
+
+// IsSessionRevoked reports whether the session of an access token has been revoked. Unknown
+// sessions count as revoked.
+func IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if cached, ok := sessionChecks.Load(sessionID); ok {
+		check := cached.(sessionCheck)
+		if check.revoked || time.Since(check.checkedAt) < sessionCheckTTL {
+			return check.revoked, nil
+		}
+	}
+
+	var revokedAt *time.Time
+	err := config.AuthDB.QueryRow(ctx, `SELECT revoked_at FROM sessions WHERE id = $1`, sessionID).Scan(&revokedAt)
+	revoked := revokedAt != nil
+	if errors.Is(err, pgx.ErrNoRows) {
+		revoked = true
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	sessionChecks.Store(sessionID, sessionCheck{revoked: revoked, checkedAt: time.Now()})
+	pruneSessionChecks()
+	return revoked, nil
+}
+
+// pruneSessionChecks drops, once a minute, the checks older than the access token lifetime:
+// tokens of those sessions have expired or will be checked again
+func pruneSessionChecks() {
+	now := time.Now()
+	last := lastPrune.Load()
+	if now.UnixNano()-last < int64(time.Minute) || !lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	sessionChecks.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(sessionCheck).checkedAt) > config.JWTTTL {
+			sessionChecks.Delete(key)
+		}
+		return true
+	})
+}
+
+// forgetSessions drops cached checks after sessions were revoked: the given sessions are
+// marked revoked, or with none given (all sessions of a user) every cached check is dropped
+func forgetSessions(sessionIDs ...string) {
+	if len(sessionIDs) == 0 {
+		sessionChecks.Clear()
+		return
+	}
+	for _, id := range sessionIDs {
+		sessionChecks.Store(id, sessionCheck{revoked: true, checkedAt: time.Now()})
+	}
+}
+
+// ListSessions returns the active sessions of a user, most recent first, with the device
+// (user agent) and IP address they were started from
+func ListSessions(ctx context.Context, userID int64, currentSessionID string) ([]Session, error) {
+	rows, err := config.AuthDB.Query(ctx,
+		`SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, last_used_at, expires_at
+		 FROM sessions
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		 ORDER BY created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.IsCurrentSession = session.ID == currentSessionID
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeUserSession revokes one session of a user and reports whether it was active
+func RevokeUserSession(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	result, err := config.AuthDB.Exec(ctx,
+		`UPDATE sessions
+		 SET revoked_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	forgetSessions(sessionID)
+	_ = LogAuditEvent(ctx, AuditEventSessionRevoked, &userID, "", "", map[string]interface{}{"revoked_session_id": sessionID})
+	return true, nil
+}
@@ -42,6 +42,14 @@ type User struct {
 	PasswordHash    string
 	EmailVerifiedAt *time.Time
 	DisabledAt      *time.Time
+	// MustChangePassword is set for temporary or expired passwords; AuthMiddleware
+	// rejects other requests until the password is changed
+	MustChangePassword bool
+	// RoleID and BranchID scope the user's data access (see TokenScope)
+	RoleID   int64
+	BranchID *int64
+	// TOTPEnabled is set once two-factor authentication is enrolled (see totp.go)
+	TOTPEnabled bool
 }
 
 // Session represents a user session
@@ -58,6 +66,10 @@ type Session struct {
 
 // Register creates a new user account (unverified)
 func (s *AuthService) Register(ctx context.Context, email, password, name string, ip, userAgent string) error {
+	if err := CurrentPasswordPolicy().Validate(password); err != nil {
+		return err
+	}
+
 	// Hash password
 	passwordHash, err := HashPassword(password)
 	if err != nil {
@@ -77,13 +89,16 @@ func (s *AuthService) Register(ctx context.Context, email, password, name string
 	// Insert user
 	var userID int64
 	err = config.AuthDB.QueryRow(ctx,
-		`INSERT INTO users (email, password, name, email_verified_at, created_on, updated_on)
-		 VALUES ($1, $2, $3, NULL, NOW(), NOW())
+		`INSERT INTO users (email, password, name, email_verified_at, password_changed_at, created_on, updated_on)
+		 VALUES ($1, $2, $3, NULL, NOW(), NOW(), NOW())
 		 RETURNING id`,
 		email, passwordHash, name).Scan(&userID)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
+	if err := RecordPasswordHistory(ctx, userID, passwordHash); err != nil {
+		return err
+	}
 
 	// Generate verification token
 	token, err := GenerateRandomToken(32)
@@ -177,16 +192,21 @@ func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
 	return nil
 }
 
-// Login authenticates a user and creates a session
+// Login authenticates a user and creates a session. For accounts with two-factor
+// authentication it returns a *TOTPRequiredError instead: the session is created by
+// VerifyLoginTOTP once the code is checked.
 func (s *AuthService) Login(ctx context.Context, email, password, ip, userAgent string) (*User, string, string, error) {
 	// Get user
 	var user User
+	var passwordChangedAt *time.Time
 	err := config.AuthDB.QueryRow(ctx,
-		`SELECT id, email, name, password, email_verified_at, disabled_at
+		`SELECT id, email, name, password, email_verified_at, disabled_at, must_change_password, password_changed_at, role_id, branch_id,
+		        totp_enabled_at IS NOT NULL
 		 FROM users
 		 WHERE email = $1 AND is_deleted = false`,
 		email).Scan(&user.ID, &user.Email, &user.Name, &user.PasswordHash,
-		&user.EmailVerifiedAt, &user.DisabledAt)
+		&user.EmailVerifiedAt, &user.DisabledAt, &user.MustChangePassword, &passwordChangedAt, &user.RoleID, &user.BranchID,
+		&user.TOTPEnabled)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		// Generic error - don't reveal if user exists
@@ -219,11 +239,30 @@ func (s *AuthService) Login(ctx context.Context, email, password, ip, userAgent
 		_ = LogAuditEvent(ctx, AuditEventLoginFailed, &user.ID, ip, userAgent, map[string]interface{}{"reason": "email_not_verified"})
 		return nil, "", "", ErrEmailNotVerified
 	}
+	user.MustChangePassword = PasswordChangeRequired(user.MustChangePassword, passwordChangedAt)
 
+	// The password is right; the second factor is checked by VerifyLoginTOTP
+	if user.TOTPEnabled {
+		challenge, err := createTOTPChallenge(ctx, user.ID, ip, userAgent)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return nil, "", "", challenge
+	}
+
+	accessToken, refreshToken, err := createSession(ctx, &user, ip, userAgent)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return &user, accessToken, refreshToken, nil
+}
+
+// createSession starts a session for a signed-in user and returns its access and refresh tokens
+func createSession(ctx context.Context, user *User, ip, userAgent string) (string, string, error) {
 	// Generate refresh token
 	refreshToken, err := GenerateRandomToken(32) // 256 bits as hex
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	refreshTokenHash := HashRefreshToken(refreshToken)
@@ -236,19 +275,19 @@ func (s *AuthService) Login(ctx context.Context, email, password, ip, userAgent
 		 VALUES ($1, $2, $3, $4, $5, NOW(), NOW(), $6)`,
 		sessionID, user.ID, refreshTokenHash, userAgent, ip, expiresAt)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to create session: %w", err)
+		return "", "", fmt.Errorf("failed to create session: %w", err)
 	}
 
 	// Generate access token
-	accessToken, err := GenerateAccessToken(user.ID, sessionID)
+	accessToken, err := GenerateAccessToken(user.ID, sessionID, TokenScope{RoleID: user.RoleID, BranchID: user.BranchID})
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Log audit event
 	_ = LogAuditEvent(ctx, AuditEventLogin, &user.ID, ip, userAgent, map[string]interface{}{"session_id": sessionID})
 
-	return &user, accessToken, refreshToken, nil
+	return accessToken, refreshToken, nil
 }
 
 // RefreshToken refreshes an access token and rotates the refresh token
@@ -281,6 +320,14 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (st
 		return "", "", ErrSessionExpired
 	}
 
+	// The new access token carries the user's current role and branch
+	var scope TokenScope
+	err = config.AuthDB.QueryRow(ctx, `SELECT role_id, branch_id FROM users WHERE id = $1`, userID).
+		Scan(&scope.RoleID, &scope.BranchID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query user: %w", err)
+	}
+
 	// Generate new refresh token
 	newRefreshToken, err := GenerateRandomToken(32)
 	if err != nil {
@@ -308,7 +355,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (st
 	}
 
 	// Generate new access token
-	accessToken, err := GenerateAccessToken(userID, sessionID)
+	accessToken, err := GenerateAccessToken(userID, sessionID, scope)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -319,29 +366,32 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (st
 	return accessToken, newRefreshToken, nil
 }
 
-// Logout revokes a session
-func (s *AuthService) Logout(ctx context.Context, refreshToken string, userID int64) error {
-	if refreshToken == "" {
-		return nil // No session to revoke
-	}
-
-	refreshTokenHash := HashRefreshToken(refreshToken)
-
-	// Revoke session (must belong to user)
-	result, err := config.AuthDB.Exec(ctx,
-		`UPDATE sessions
-		 SET revoked_at = NOW()
-		 WHERE refresh_token_hash = $1 AND user_id = $2 AND revoked_at IS NULL`,
-		refreshTokenHash, userID)
-
-	if err != nil {
-		return fmt.Errorf("failed to revoke session: %w", err)
+// Logout revokes a session: the one of the refresh token and, when the request carried a
+// valid access token, the access token's session (sessionID, of user userID). The refresh
+// token alone is enough, so logging out works after the access token expired.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string, userID int64, sessionID string) error {
+	if refreshToken != "" {
+		var revokedID string
+		var revokedUserID int64
+		err := config.AuthDB.QueryRow(ctx,
+			`UPDATE sessions
+			 SET revoked_at = NOW()
+			 WHERE refresh_token_hash = $1 AND revoked_at IS NULL
+			 RETURNING id, user_id`,
+			HashRefreshToken(refreshToken)).Scan(&revokedID, &revokedUserID)
+		if err == nil {
+			forgetSessions(revokedID)
+			_ = LogAuditEvent(ctx, AuditEventLogout, &revokedUserID, "", "", map[string]interface{}{"session_id": revokedID})
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
 	}
 
-	if result.RowsAffected() > 0 {
-		_ = LogAuditEvent(ctx, AuditEventLogout, &userID, "", "", nil)
+	if sessionID != "" && userID != 0 {
+		if _, err := RevokeUserSession(ctx, userID, sessionID); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
@@ -364,6 +414,25 @@ func (s *AuthService) ForgotPassword(ctx context.Context, email, ip, userAgent s
 		return fmt.Errorf("failed to query user: %w", err)
 	}
 
+	// Per-account limit on top of the per-IP rate limiter, so one inbox cannot be flooded
+	// from many addresses. Silently drop the request like an unknown email.
+	var recent int
+	if err := config.AuthDB.QueryRow(ctx,
+		`SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at > $2`,
+		userID, time.Now().Add(-config.RateLimitWindow)).Scan(&recent); err != nil {
+		return fmt.Errorf("failed to count reset tokens: %w", err)
+	}
+	if recent >= config.RateLimitForgotPasswordPerEmail {
+		return nil
+	}
+
+	// Only the latest link works: invalidate outstanding tokens
+	if _, err := config.AuthDB.Exec(ctx,
+		`UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`,
+		userID); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %w", err)
+	}
+
 	// Generate reset token
 	token, err := GenerateRandomToken(32)
 	if err != nil {
@@ -395,14 +464,23 @@ func (s *AuthService) ForgotPassword(ctx context.Context, email, ip, userAgent s
 func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
 	tokenHash := HashToken(token)
 
+	// Update password, mark token as used, and revoke all sessions in a transaction
+	tx, err := config.AuthDB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	var userID int64
 	var expiresAt time.Time
 	var usedAt sql.NullTime
 
-	err := config.AuthDB.QueryRow(ctx,
+	// Lock the token so concurrent requests cannot both use it
+	err = tx.QueryRow(ctx,
 		`SELECT user_id, expires_at, used_at
 		 FROM password_reset_tokens
-		 WHERE token_hash = $1`,
+		 WHERE token_hash = $1
+		 FOR UPDATE`,
 		tokenHash).Scan(&userID, &expiresAt, &usedAt)
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -420,25 +498,9 @@ func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword stri
 		return ErrTokenExpired
 	}
 
-	// Hash new password
-	passwordHash, err := HashPassword(newPassword)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Update password, mark token as used, and revoke all sessions in a transaction
-	tx, err := config.AuthDB.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback(ctx)
-
-	// Update password
-	_, err = tx.Exec(ctx,
-		`UPDATE users SET password = $1, updated_on = NOW() WHERE id = $2`,
-		passwordHash, userID)
-	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Update password (checked against the policy and password history)
+	if err := setPassword(ctx, tx, userID, newPassword, false); err != nil {
+		return err
 	}
 
 	// Mark token as used
@@ -461,6 +523,8 @@ func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword stri
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	forgetSessions()
+
 	// Log audit event
 	_ = LogAuditEvent(ctx, AuditEventPasswordReset, &userID, "", "", nil)
 
@@ -491,18 +555,9 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int64, currentP
 		return ErrInvalidPassword
 	}
 
-	// Hash new password
-	newPasswordHash, err := HashPassword(newPassword)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Update password
-	_, err = config.AuthDB.Exec(ctx,
-		`UPDATE users SET password = $1, updated_on = NOW() WHERE id = $2`,
-		newPasswordHash, userID)
-	if err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Update password (checked against the policy and password history, clears must_change_password)
+	if err := SetPassword(ctx, userID, newPassword, false); err != nil {
+		return err
 	}
 
 	// Optionally revoke all other sessions (keeping current)
@@ -519,50 +574,41 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int64, currentP
 
 // GetSessions returns all active sessions for a user
 func (s *AuthService) GetSessions(ctx context.Context, userID int64, currentSessionID string) ([]Session, error) {
-	rows, err := config.AuthDB.Query(ctx,
-		`SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
-		 FROM sessions
-		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
-		 ORDER BY created_at DESC`,
-		userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query sessions: %w", err)
-	}
-	defer rows.Close()
-
-	var sessions []Session
-	for rows.Next() {
-		var session Session
-		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP,
-			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan session: %w", err)
-		}
-		session.IsCurrentSession = session.ID == currentSessionID
-		sessions = append(sessions, session)
-	}
-
-	return sessions, nil
+	return ListSessions(ctx, userID, currentSessionID)
 }
 
 // RevokeSession revokes a specific session
 func (s *AuthService) RevokeSession(ctx context.Context, userID int64, targetSessionID string) error {
-	// Ensure session belongs to user
-	result, err := config.AuthDB.Exec(ctx,
-		`UPDATE sessions
-		 SET revoked_at = NOW()
-		 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
-		targetSessionID, userID)
+	_, err := RevokeUserSession(ctx, userID, targetSessionID)
+	return err
+}
 
+// RevokeAllSessions signs a user out everywhere: all of their sessions are revoked and
+// access tokens issued until now are rejected by AuthMiddleware (users.tokens_revoked_at).
+// Returns the number of sessions revoked.
+func RevokeAllSessions(ctx context.Context, userID int64) (int64, error) {
+	tx, err := config.AuthDB.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to revoke session: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if result.RowsAffected() > 0 {
-		_ = LogAuditEvent(ctx, AuditEventSessionRevoked, &userID, "", "", map[string]interface{}{"revoked_session_id": targetSessionID})
+	result, err := tx.Exec(ctx,
+		`UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET tokens_revoked_at = NOW() WHERE id = $1`, userID); err != nil {
+		return 0, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	forgetSessions()
+	_ = LogAuditEvent(ctx, AuditEventSessionRevoked, &userID, "", "", map[string]interface{}{"revoked_sessions": result.RowsAffected(), "all": true})
+	return result.RowsAffected(), nil
 }
 
 
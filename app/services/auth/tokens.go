@@ -31,8 +31,16 @@ func HashRefreshToken(token string) []byte {
 	return h.Sum(nil)
 }
 
+// TokenScope is what the user may see, carried in access tokens so clients know it without
+// another request: the role ("role" claim) and, for non-admins, the branch whose data (with
+// its child branches) they are limited to ("bid" claim)
+type TokenScope struct {
+	RoleID   int64
+	BranchID *int64
+}
+
 // GenerateAccessToken generates a JWT access token
-func GenerateAccessToken(userID int64, sessionID string) (string, error) {
+func GenerateAccessToken(userID int64, sessionID string, scope TokenScope) (string, error) {
 	now := time.Now()
 	jti := uuid.New().String()
 
@@ -44,6 +52,10 @@ func GenerateAccessToken(userID int64, sessionID string) (string, error) {
 		"exp": now.Add(config.JWTTTL).Unix(), // Expiration
 		"iss": config.JWTIssuer,          // Issuer
 		"aud": config.JWTAudience,        // Audience
+		"role": scope.RoleID,             // Role ID
+	}
+	if scope.BranchID != nil {
+		claims["bid"] = *scope.BranchID // Branch the user's data access is scoped to
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -62,7 +74,7 @@ func VerifyAccessToken(tokenString string) (jwt.MapClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return config.JWTSecret, nil
+		return config.JWTVerificationKey(), nil
 	})
 
 	if err != nil {
@@ -106,6 +118,20 @@ func ParseSessionIDFromToken(claims jwt.MapClaims) (string, error) {
 	return sid, nil
 }
 
+// ParseScopeFromToken extracts the role and branch claims; tokens issued before they were
+// added have an empty scope
+func ParseScopeFromToken(claims jwt.MapClaims) TokenScope {
+	var scope TokenScope
+	if role, ok := claims["role"].(float64); ok {
+		scope.RoleID = int64(role)
+	}
+	if bid, ok := claims["bid"].(float64); ok {
+		branchID := int64(bid)
+		scope.BranchID = &branchID
+	}
+	return scope
+}
+
 // HashToken hashes a token (for verification/reset tokens) with pepper
 func HashToken(token string) []byte {
 	h := sha256.New()
@@ -0,0 +1,529 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Two-factor authentication with time-based one-time passwords (RFC 6238: 6 digits, 30 second
+// steps, HMAC-SHA1, as used by Google Authenticator, Authy, 1Password...).
+//
+// StartTOTPEnrollment stores a new secret, encrypted with a key derived from TOKEN_PEPPER; it
+// only takes effect once ConfirmTOTPEnrollment has checked a first code. From then on Login
+// answers with a *TOTPRequiredError carrying a short-lived challenge token, and the session is
+// created by VerifyLoginTOTP with the token and a code (or one of the recovery codes).
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTOTPNotEnrolled    = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTOTPCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge   = errors.New("invalid or expired two-factor challenge, sign in again")
+)
+
+const (
+	totpPeriod     = 30 // seconds per code
+	totpDigits     = 6
+	totpSkew       = 1  // steps accepted before and after the current one (clock drift)
+	totpSecretSize = 20 // bytes, the size of an HMAC-SHA1 key
+
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // no 0/o, 1/l/i
+
+	totpChallengeTTL         = 5 * time.Minute
+	totpChallengeMaxAttempts = 5
+)
+
+// TOTPRequiredError is returned by Login for accounts with two-factor authentication: the
+// password was right, and ChallengeToken is exchanged for the session by VerifyLoginTOTP
+type TOTPRequiredError struct {
+	ChallengeToken string
+	ExpiresAt      time.Time
+}
+
+func (e *TOTPRequiredError) Error() string { return "two-factor code required" }
+
+// TOTPEnrollment is the secret of a pending enrollment, for the user's authenticator app
+type TOTPEnrollment struct {
+	Secret string // base32, for manual entry
+	URI    string // otpauth:// URI, shown as a QR code by the client
+}
+
+// TOTPStatus describes the two-factor setup of a user
+type TOTPStatus struct {
+	Enabled           bool
+	EnabledAt         *time.Time
+	Pending           bool // enrollment started but not confirmed
+	RecoveryCodesLeft int
+}
+
+// StartTOTPEnrollment generates a new secret for the user. It replaces any pending enrollment
+// and is only used for sign-in once confirmed with ConfirmTOTPEnrollment.
+func (s *AuthService) StartTOTPEnrollment(ctx context.Context, userID int64) (*TOTPEnrollment, error) {
+	var email string
+	var enabledAt *time.Time
+	err := config.AuthDB.QueryRow(ctx,
+		`SELECT email, totp_enabled_at FROM users WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&email, &enabledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if enabledAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	encrypted, err := encryptTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := config.AuthDB.Exec(ctx,
+		`UPDATE users SET totp_secret = $2, totp_last_step = NULL, updated_on = NOW() WHERE id = $1`,
+		userID, encrypted); err != nil {
+		return nil, fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	return &TOTPEnrollment{Secret: encoded, URI: totpURI(email, encoded)}, nil
+}
+
+// totpURI returns the Key URI understood by authenticator apps
+func totpURI(email, secret string) string {
+	// Spaces are escaped as %20: some apps show a "+" literally
+	issuer := url.PathEscape(config.TOTPIssuer)
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&algorithm=SHA1&digits=%d&period=%d",
+		issuer, url.PathEscape(email), secret, issuer, totpDigits, totpPeriod)
+}
+
+// ConfirmTOTPEnrollment checks a code of the pending secret, turns two-factor authentication
+// on and returns the recovery codes. They are only shown this once.
+func (s *AuthService) ConfirmTOTPEnrollment(ctx context.Context, userID int64, code, ip, userAgent string) ([]string, error) {
+	var encrypted []byte
+	var enabledAt *time.Time
+	err := config.AuthDB.QueryRow(ctx,
+		`SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&encrypted, &enabledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if enabledAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if encrypted == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	secret, err := decryptTOTPSecret(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	tx, err := config.AuthDB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2, updated_on = NOW() WHERE id = $1`,
+		userID, step); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	_ = LogAuditEvent(ctx, AuditEventTOTPEnabled, &userID, ip, userAgent, nil)
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. The user confirms with their password and
+// a code (or a recovery code).
+func (s *AuthService) DisableTOTP(ctx context.Context, userID int64, password, code, ip, userAgent string) error {
+	var passwordHash string
+	var enabledAt *time.Time
+	err := config.AuthDB.QueryRow(ctx,
+		`SELECT password, totp_enabled_at FROM users WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&passwordHash, &enabledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query user: %w", err)
+	}
+	if enabledAt == nil {
+		return ErrTOTPNotEnabled
+	}
+
+	valid, err := VerifyPassword(password, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !valid {
+		return ErrInvalidPassword
+	}
+	if err := verifySecondFactor(ctx, userID, code, true); err != nil {
+		return err
+	}
+
+	if err := ResetTOTP(ctx, userID); err != nil {
+		return err
+	}
+	_ = LogAuditEvent(ctx, AuditEventTOTPDisabled, &userID, ip, userAgent, nil)
+	return nil
+}
+
+// ResetTOTP removes the two-factor setup of a user, e.g. by an admin for a user who lost
+// both their device and their recovery codes
+func ResetTOTP(ctx context.Context, userID int64) error {
+	tx, err := config.AuthDB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL, updated_on = NOW() WHERE id = $1`,
+		userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM totp_challenges WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete challenges: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user; the current authenticator
+// code is required, so a stolen recovery code cannot be used to mint new ones
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code, ip, userAgent string) ([]string, error) {
+	if err := verifySecondFactor(ctx, userID, code, false); err != nil {
+		return nil, err
+	}
+
+	tx, err := config.AuthDB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	_ = LogAuditEvent(ctx, AuditEventRecoveryCodes, &userID, ip, userAgent, nil)
+	return codes, nil
+}
+
+// GetTOTPStatus reports whether a user has two-factor authentication and how many recovery
+// codes are left
+func GetTOTPStatus(ctx context.Context, userID int64) (*TOTPStatus, error) {
+	var status TOTPStatus
+	err := config.AuthDB.QueryRow(ctx,
+		`SELECT totp_enabled_at, totp_secret IS NOT NULL,
+		        (SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+		 FROM users WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&status.EnabledAt, &status.Pending, &status.RecoveryCodesLeft)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	status.Enabled = status.EnabledAt != nil
+	status.Pending = status.Pending && !status.Enabled
+	return &status, nil
+}
+
+// createTOTPChallenge records that a user passed the password check and returns the token
+// with which the second step of the sign-in is made
+func createTOTPChallenge(ctx context.Context, userID int64, ip, userAgent string) (*TOTPRequiredError, error) {
+	token, err := GenerateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	expiresAt := time.Now().Add(totpChallengeTTL)
+	if _, err := config.AuthDB.Exec(ctx,
+		`INSERT INTO totp_challenges (id, user_id, token_hash, ip, user_agent, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW())`,
+		uuid.New().String(), userID, HashToken(token), ip, userAgent, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to create challenge: %w", err)
+	}
+	// Expired challenges are only kept for a day, for the audit trail of failed attempts
+	_, _ = config.AuthDB.Exec(ctx, `DELETE FROM totp_challenges WHERE expires_at < NOW() - INTERVAL '1 day'`)
+	return &TOTPRequiredError{ChallengeToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyLoginTOTP completes a sign-in started by Login: it checks the code (or a recovery
+// code) for the challenge and creates the session. A challenge is used once and allows
+// totpChallengeMaxAttempts wrong codes.
+func (s *AuthService) VerifyLoginTOTP(ctx context.Context, challengeToken, code, ip, userAgent string) (*User, string, string, error) {
+	var challengeID string
+	var userID int64
+	var attempts int
+	var expiresAt time.Time
+	var usedAt *time.Time
+	err := config.AuthDB.QueryRow(ctx,
+		`SELECT id, user_id, attempts, expires_at, used_at FROM totp_challenges WHERE token_hash = $1`,
+		HashToken(challengeToken)).Scan(&challengeID, &userID, &attempts, &expiresAt, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", "", ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to query challenge: %w", err)
+	}
+	if usedAt != nil || time.Now().After(expiresAt) || attempts >= totpChallengeMaxAttempts {
+		return nil, "", "", ErrInvalidChallenge
+	}
+
+	if err := verifySecondFactor(ctx, userID, code, true); err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			_, _ = config.AuthDB.Exec(ctx, `UPDATE totp_challenges SET attempts = attempts + 1 WHERE id = $1`, challengeID)
+			_ = LogAuditEvent(ctx, AuditEventLoginFailed, &userID, ip, userAgent, map[string]interface{}{"reason": "invalid_totp"})
+		}
+		return nil, "", "", err
+	}
+
+	// Use the challenge once, even if two requests race with valid codes
+	result, err := config.AuthDB.Exec(ctx,
+		`UPDATE totp_challenges SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, challengeID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to use challenge: %w", err)
+	}
+	if result.RowsAffected() != 1 {
+		return nil, "", "", ErrInvalidChallenge
+	}
+
+	// The account may have been deactivated since the password check
+	var user User
+	var passwordChangedAt *time.Time
+	err = config.AuthDB.QueryRow(ctx,
+		`SELECT id, email, name, disabled_at, must_change_password, password_changed_at, role_id, branch_id
+		 FROM users
+		 WHERE id = $1 AND is_deleted = false`,
+		userID).Scan(&user.ID, &user.Email, &user.Name, &user.DisabledAt, &user.MustChangePassword,
+		&passwordChangedAt, &user.RoleID, &user.BranchID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", "", ErrUserNotFound
+	}
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to query user: %w", err)
+	}
+	if user.DisabledAt != nil {
+		_ = LogAuditEvent(ctx, AuditEventLoginFailed, &user.ID, ip, userAgent, map[string]interface{}{"reason": "disabled"})
+		return nil, "", "", ErrUserDisabled
+	}
+	user.MustChangePassword = PasswordChangeRequired(user.MustChangePassword, passwordChangedAt)
+	user.TOTPEnabled = true
+
+	accessToken, refreshToken, err := createSession(ctx, &user, ip, userAgent)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return &user, accessToken, refreshToken, nil
+}
+
+// verifySecondFactor checks an authenticator code and, with allowRecovery, a recovery code
+// (which is used up). Each authenticator code is accepted once.
+func verifySecondFactor(ctx context.Context, userID int64, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		var encrypted []byte
+		var enabledAt *time.Time
+		err := config.AuthDB.QueryRow(ctx,
+			`SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1`, userID).Scan(&encrypted, &enabledAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query user: %w", err)
+		}
+		if enabledAt == nil || encrypted == nil {
+			return ErrTOTPNotEnabled
+		}
+		secret, err := decryptTOTPSecret(encrypted)
+		if err != nil {
+			return err
+		}
+		step, ok := matchTOTP(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+		// A code seen before (replayed, or shoulder-surfed within its 30 seconds) is rejected
+		result, err := config.AuthDB.Exec(ctx,
+			`UPDATE users SET totp_last_step = $2 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+			userID, step)
+		if err != nil {
+			return fmt.Errorf("failed to record code: %w", err)
+		}
+		if result.RowsAffected() != 1 {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return ErrInvalidTOTPCode
+	}
+	result, err := config.AuthDB.Exec(ctx,
+		`UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if result.RowsAffected() != 1 {
+		return ErrInvalidTOTPCode
+	}
+	_ = LogAuditEvent(ctx, AuditEventRecoveryCodeUsed, &userID, "", "", nil)
+	return nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores new ones (hashed)
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		var code strings.Builder
+		for j := 0; j < recoveryCodeLength; j++ {
+			if j == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+			c, err := randomChar(recoveryCodeAlphabet)
+			if err != nil {
+				return nil, err
+			}
+			code.WriteByte(c)
+		}
+		codes[i] = code.String()
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO user_recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, NOW())`,
+			uuid.New().String(), userID, HashToken(normalizeRecoveryCode(codes[i]))); err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTOTP returns the time step whose code matches, allowing totpSkew steps of drift
+func matchTOTP(secret []byte, code string, now time.Time) (int64, bool) {
+	if !isTOTPCode(code) {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode is the HOTP value (RFC 4226) of a time step
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000) // 10^totpDigits
+}
+
+// totpCipher is AES-256-GCM with a key derived from TOKEN_PEPPER; rotating the pepper makes
+// existing secrets unreadable, like it invalidates refresh tokens
+func totpCipher() (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte("totp-secret:"))
+	h.Write(config.TokenPepper)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptTOTPSecret(secret []byte) ([]byte, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, secret, nil), nil
+}
+
+func decryptTOTPSecret(encrypted []byte) ([]byte, error) {
+	aead, err := totpCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errors.New("invalid two-factor secret")
+	}
+	secret, err := aead.Open(nil, encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt two-factor secret: %w", err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 appendix B lists 8-digit codes; ours are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Secret, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", totpCode(rfc6238Secret, current), current, true},
+		{"previous step (clock drift)", totpCode(rfc6238Secret, current-1), current - 1, true},
+		{"next step (clock drift)", totpCode(rfc6238Secret, current+1), current + 1, true},
+		{"outside the skew", totpCode(rfc6238Secret, current-2), 0, false},
+		{"wrong code", "000000", 0, false},
+		{"8 digits", "14050471", 0, false},
+		{"not digits", "05047a", 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		step, ok := matchTOTP(rfc6238Secret, tt.code, now)
+		if ok != tt.wantOK || step != tt.wantStep {
+			t.Errorf("%s: matchTOTP(%q) = (%d, %v), want (%d, %v)", tt.name, tt.code, step, ok, tt.wantStep, tt.wantOK)
+		}
+	}
+}
+
+// TestVerifySecondFactorRejectsReusedStep needs the migrated database in TEST_DATABASE_URL
+func TestVerifySecondFactorRejectsReusedStep(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	previousDB, previousPepper := config.AuthDB, config.TokenPepper
+	config.AuthDB, config.TokenPepper = pool, []byte("totp-test-pepper")
+	t.Cleanup(func() {
+		config.AuthDB, config.TokenPepper = previousDB, previousPepper
+		pool.Close()
+	})
+
+	encrypted, err := encryptTOTPSecret(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+	var userID int64
+	err = pool.QueryRow(ctx,
+		`INSERT INTO users (name, email, password, role_id, totp_secret, totp_enabled_at)
+		 VALUES ('TOTP test', $1, 'x',
+		         (SELECT id FROM roles ORDER BY id LIMIT 1), $2, NOW())
+		 RETURNING id`,
+		fmt.Sprintf("totp-test-%d@example.com", time.Now().UnixNano()), encrypted).Scan(&userID)
+	if err != nil {
+		t.Fatalf("create user (the roles table needs a row): %v", err)
+	}
+	t.Cleanup(func() { _, _ = pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID) })
+
+	current := time.Now().Unix() / totpPeriod
+	if err := verifySecondFactor(ctx, userID, totpCode(rfc6238Secret, current), false); err != nil {
+		t.Fatalf("first use of the current code: %v", err)
+	}
+	if err := verifySecondFactor(ctx, userID, totpCode(rfc6238Secret, current), false); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("reused code: err = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+	// A code of an earlier step, still within the skew, is refused once a later step was used
+	if err := verifySecondFactor(ctx, userID, totpCode(rfc6238Secret, current-1), false); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("code of an earlier step: err = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+var (
+	ErrBranchChangeRequestNotFound = errors.New("branch change request not found")
+	ErrInvalidBranchChange         = errors.New("invalid branch change")
+	// ErrBranchChangeNotAllowed is returned to users who do not coordinate the branch
+	ErrBranchChangeNotAllowed = errors.New("only the branch's coordinator can request changes")
+	// ErrBranchChangeNotReviewer is returned to users who may not review the request
+	ErrBranchChangeNotReviewer = errors.New("only an admin or a manager of the branch's region can review this request")
+	ErrBranchChangeNotPending  = errors.New("the change request has already been reviewed or withdrawn")
+)
+
+// BranchChangeFields are the profile fields coordinators may change through a request.
+// Structure (parent, region, branch code, status) stays with admins via PUT /branches/:id.
+var BranchChangeFields = map[string]bool{
+	"name":             true,
+	"email":            true,
+	"coordinator_name": true,
+	"contact_number":   true,
+	"established_on":   true,
+	"aashram_area":     true,
+	"country_id":       true,
+	"state_id":         true,
+	"district_id":      true,
+	"city_id":          true,
+	"address":          true,
+	"pincode":          true,
+	"post_office":      true,
+	"police_station":   true,
+	"open_days":        true,
+	"daily_start_time": true,
+	"daily_end_time":   true,
+	"latitude":         true,
+	"longitude":        true,
+}
+
+// BranchChangeInput is a coordinator's proposed edit
+type BranchChangeInput struct {
+	Changes map[string]interface{}
+	Comment string
+}
+
+// BranchFieldChange is one row of the reviewer's diff view. Conflict is set when the branch
+// was changed by someone else after the request was made.
+type BranchFieldChange struct {
+	Field    string      `json:"field"`
+	Original interface{} `json:"original"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+	Conflict bool        `json:"conflict"`
+}
+
+// BranchChangeRequestDetail is a change request with its diff against the current branch
+type BranchChangeRequestDetail struct {
+	models.BranchChangeRequest
+	Diff        []BranchFieldChange `json:"diff"`
+	CanReview   bool                `json:"can_review"`
+	CanWithdraw bool                `json:"can_withdraw"`
+}
+
+// BranchChangeRequestFilter holds the supported filters for listing change requests
+type BranchChangeRequestFilter struct {
+	BranchID    uint
+	Status      string
+	RequestedBy *uint
+	Limit       int
+	Offset      int
+}
+
+// branchChangeUser loads the acting user with their branch and region assignment
+func branchChangeUser(actor AuditActor) (*models.User, error) {
+	if actor.UserID == nil {
+		return nil, ErrBranchChangeNotAllowed
+	}
+	var user models.User
+	if err := config.DB.Select("id", "role_id", "branch_id", "region_id").
+		Where("is_deleted = ?", false).Limit(1).Find(&user, *actor.UserID).Error; err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// coordinatesBranch reports whether the user coordinates the branch or its parent branch
+func coordinatesBranch(user *models.User, branch *models.Branch) bool {
+	if user.BranchID == nil {
+		return false
+	}
+	return *user.BranchID == branch.ID || (branch.ParentBranchID != nil && *branch.ParentBranchID == *user.BranchID)
+}
+
+// reviewsBranch reports whether the user may approve changes to the branch: admins always,
+// managers for the branches of their region
+func reviewsBranch(user *models.User, branch *models.Branch) bool {
+	if user.RoleID == models.RoleAdmin {
+		return true
+	}
+	return user.RoleID == models.RoleManager && user.RegionID != nil &&
+		branch.RegionID != nil && *branch.RegionID == *user.RegionID
+}
+
+// normalizeBranchChange validates a proposed value and converts it to the form stored in the request
+func normalizeBranchChange(field string, value interface{}) (interface{}, error) {
+	if value == nil {
+		switch field {
+		case "name", "contact_number":
+			return nil, fmt.Errorf("%w: %s cannot be empty", ErrInvalidBranchChange, field)
+		}
+		return nil, nil
+	}
+
+	switch field {
+	case "country_id", "state_id", "district_id", "city_id":
+		id, ok := value.(float64)
+		if !ok || id < 0 || id != float64(uint(id)) {
+			return nil, fmt.Errorf("%w: %s must be an ID or null", ErrInvalidBranchChange, field)
+		}
+		if id == 0 {
+			return nil, nil
+		}
+		return id, nil
+	case "aashram_area", "latitude", "longitude":
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s must be a number", ErrInvalidBranchChange, field)
+		}
+		switch {
+		case field == "aashram_area" && number < 0,
+			field == "latitude" && (number < -90 || number > 90),
+			field == "longitude" && (number < -180 || number > 180):
+			return nil, fmt.Errorf("%w: %s is out of range", ErrInvalidBranchChange, field)
+		}
+		return number, nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a string", ErrInvalidBranchChange, field)
+	}
+	text = strings.TrimSpace(text)
+	switch field {
+	case "name", "contact_number":
+		if text == "" {
+			return nil, fmt.Errorf("%w: %s cannot be empty", ErrInvalidBranchChange, field)
+		}
+	case "established_on":
+		if text == "" {
+			return nil, nil
+		}
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, fmt.Errorf("%w: established_on must be YYYY-MM-DD", ErrInvalidBranchChange)
+		}
+	}
+	return text, nil
+}
+
+// branchChangeValueEqual compares a proposed value with the branch's JSON snapshot value
+func branchChangeValueEqual(field string, snapshotValue, proposed interface{}) bool {
+	if field == "established_on" {
+		current, _ := snapshotValue.(string)
+		if len(current) >= len("2006-01-02") {
+			current = current[:len("2006-01-02")]
+		}
+		proposedDate, _ := proposed.(string)
+		return current == proposedDate
+	}
+	if snapshotValue == "" {
+		snapshotValue = nil
+	}
+	if proposed == "" {
+		proposed = nil
+	}
+	return reflect.DeepEqual(snapshotValue, proposed)
+}
+
+// SubmitBranchChangeRequest records a coordinator's proposed edit of their branch (or one of
+// its child branches). Fields whose value would not change are dropped.
+func SubmitBranchChangeRequest(branchID uint, input BranchChangeInput, actor AuditActor) (*models.BranchChangeRequest, error) {
+	user, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, err
+	}
+	var branch models.Branch
+	if err := config.DB.Limit(1).Find(&branch, branchID).Error; err != nil {
+		return nil, err
+	}
+	if branch.ID == 0 {
+		return nil, ErrBranchNotFound
+	}
+	if !coordinatesBranch(user, &branch) {
+		return nil, ErrBranchChangeNotAllowed
+	}
+
+	current := auditSnapshotOf(&branch)
+	changes, original := models.JSONB{}, models.JSONB{}
+	for field, value := range input.Changes {
+		if !BranchChangeFields[field] {
+			return nil, fmt.Errorf("%w: %s cannot be changed through a change request", ErrInvalidBranchChange, field)
+		}
+		proposed, err := normalizeBranchChange(field, value)
+		if err != nil {
+			return nil, err
+		}
+		if branchChangeValueEqual(field, current[field], proposed) {
+			continue
+		}
+		changes[field] = proposed
+		original[field] = current[field]
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%w: nothing would change", ErrInvalidBranchChange)
+	}
+
+	request := &models.BranchChangeRequest{
+		BranchID:    branch.ID,
+		Status:      models.BranchChangeStatusPending,
+		Changes:     changes,
+		Original:    original,
+		Comment:     strings.TrimSpace(input.Comment),
+		RequestedBy: user.ID,
+	}
+	if err := config.DB.Create(request).Error; err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListBranchChangeRequests returns the requests the user may see, newest first: admins see all,
+// regional managers those of their region's branches, everyone else the ones they submitted
+func ListBranchChangeRequests(filter BranchChangeRequestFilter, actor AuditActor) ([]models.BranchChangeRequest, int64, error) {
+	user, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.Model(&models.BranchChangeRequest{})
+	switch {
+	case user.RoleID == models.RoleAdmin:
+	case user.RoleID == models.RoleManager && user.RegionID != nil:
+		query = query.Where("requested_by = ? OR branch_id IN (?)", user.ID,
+			config.DB.Model(&models.Branch{}).Select("id").Where("region_id = ?", *user.RegionID))
+	default:
+		query = query.Where("requested_by = ?", user.ID)
+	}
+	if filter.BranchID != 0 {
+		query = query.Where("branch_id = ?", filter.BranchID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.RequestedBy != nil {
+		query = query.Where("requested_by = ?", *filter.RequestedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	requests := []models.BranchChangeRequest{}
+	err = query.Preload("Branch", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "name", "parent_branch_id", "region_id", "branch_code")
+	}).Order("created_on DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&requests).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return requests, total, nil
+}
+
+// loadBranchChangeRequest loads a request and its branch (including soft-deleted branches)
+func loadBranchChangeRequest(db *gorm.DB, id uint) (*models.BranchChangeRequest, error) {
+	var request models.BranchChangeRequest
+	if err := db.Preload("Branch", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Limit(1).Find(&request, id).Error; err != nil {
+		return nil, err
+	}
+	if request.ID == 0 || request.Branch == nil {
+		return nil, ErrBranchChangeRequestNotFound
+	}
+	return &request, nil
+}
+
+// GetBranchChangeRequest returns a request with the field-by-field diff for reviewers
+func GetBranchChangeRequest(id uint, actor AuditActor) (*BranchChangeRequestDetail, error) {
+	user, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, err
+	}
+	request, err := loadBranchChangeRequest(config.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	canReview := reviewsBranch(user, request.Branch)
+	if !canReview && request.RequestedBy != user.ID {
+		return nil, ErrBranchChangeRequestNotFound
+	}
+
+	pending := request.Status == models.BranchChangeStatusPending
+	detail := &BranchChangeRequestDetail{
+		BranchChangeRequest: *request,
+		Diff:                []BranchFieldChange{},
+		CanReview:           pending && canReview && request.RequestedBy != user.ID,
+		CanWithdraw:         pending && request.RequestedBy == user.ID,
+	}
+	current := auditSnapshotOf(request.Branch)
+	for field, proposed := range request.Changes {
+		change := BranchFieldChange{
+			Field:    field,
+			Original: request.Original[field],
+			Current:  current[field],
+			Proposed: proposed,
+		}
+		// Only pending requests can still conflict; reviewed ones show what was decided
+		change.Conflict = pending && !reflect.DeepEqual(change.Original, change.Current)
+		detail.Diff = append(detail.Diff, change)
+	}
+	sort.Slice(detail.Diff, func(i, j int) bool { return detail.Diff[i].Field < detail.Diff[j].Field })
+	detail.Branch = nil
+	return detail, nil
+}
+
+// ReviewBranchChangeRequest approves (applying the changes to the branch through UpdateBranch,
+// with its validation and an audit entry) or rejects a pending request. Reviewers cannot
+// decide on their own requests.
+func ReviewBranchChangeRequest(id uint, approve bool, comment string, actor AuditActor) (*models.BranchChangeRequest, error) {
+	user, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, err
+	}
+	request, err := loadBranchChangeRequest(config.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewsBranch(user, request.Branch) || request.RequestedBy == user.ID {
+		return nil, ErrBranchChangeNotReviewer
+	}
+	if request.Status != models.BranchChangeStatusPending {
+		return nil, ErrBranchChangeNotPending
+	}
+	if approve && request.Branch.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: the branch has been deleted", ErrInvalidBranchChange)
+	}
+
+	status := models.BranchChangeStatusRejected
+	if approve {
+		status = models.BranchChangeStatusApproved
+	}
+	now := time.Now()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the request first so two reviewers cannot both decide on it
+		result := tx.Model(&models.BranchChangeRequest{}).
+			Where("id = ? AND status = ?", id, models.BranchChangeStatusPending).
+			UpdateColumns(map[string]interface{}{
+				"status":         status,
+				"reviewed_by":    user.ID,
+				"reviewed_on":    now,
+				"review_comment": strings.TrimSpace(comment),
+				"updated_on":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBranchChangeNotPending
+		}
+		if approve {
+			return applyBranchChangeRequest(tx, request, actor, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err = loadBranchChangeRequest(config.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	request.Branch = nil
+	return request, nil
+}
+
+// applyBranchChangeRequest writes approved changes to the branch. UpdateBranch validates them
+// (uniqueness, location hierarchy) but is not transactional, so it runs last.
+func applyBranchChangeRequest(tx *gorm.DB, request *models.BranchChangeRequest, actor AuditActor, now time.Time) error {
+	entityType := AuditEntityBranch
+	if request.Branch.ParentBranchID != nil {
+		entityType = AuditEntityChildBranch
+	}
+	before := loadAuditSnapshot(tx, entityType, request.BranchID)
+
+	updates := make(map[string]interface{}, len(request.Changes))
+	for field, value := range request.Changes {
+		if field == "established_on" {
+			if date, ok := value.(string); ok {
+				parsed, err := time.Parse("2006-01-02", date)
+				if err != nil {
+					return fmt.Errorf("%w: established_on must be YYYY-MM-DD", ErrInvalidBranchChange)
+				}
+				value = &parsed
+			}
+		}
+		updates[field] = value
+	}
+	if err := UpdateBranch(request.BranchID, updates, nil); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBranchChange, err)
+	}
+
+	after := loadAuditSnapshot(config.DB, entityType, request.BranchID)
+	changes := DiffAuditSnapshots(before, after)
+	changes["change_request_id"] = request.ID
+	return tx.Create(actor.auditEntry(entityType, request.BranchID, models.AuditActionUpdate, changes, now)).Error
+}
+
+// WithdrawBranchChangeRequest lets the submitter take back a request that is still pending
+func WithdrawBranchChangeRequest(id uint, actor AuditActor) (*models.BranchChangeRequest, error) {
+	user, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, err
+	}
+	request, err := loadBranchChangeRequest(config.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequestedBy != user.ID {
+		return nil, ErrBranchChangeRequestNotFound
+	}
+
+	result := config.DB.Model(&models.BranchChangeRequest{}).
+		Where("id = ? AND status = ?", id, models.BranchChangeStatusPending).
+		UpdateColumns(map[string]interface{}{"status": models.BranchChangeStatusWithdrawn, "updated_on": time.Now()})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrBranchChangeNotPending
+	}
+	request.Status = models.BranchChangeStatusWithdrawn
+	request.Branch = nil
+	return request, nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGeocoderNotConfigured is returned when GEOCODER is unset
+	ErrGeocoderNotConfigured = errors.New("no geocoder is configured (set GEOCODER)")
+	// ErrInvalidNearbyQuery is returned for malformed nearby search parameters
+	ErrInvalidNearbyQuery = errors.New("invalid nearby query")
+)
+
+// Nearby search limits
+const (
+	DefaultNearbyRadiusKm = 25
+	MaxNearbyRadiusKm     = 500
+	DefaultNearbyLimit    = 50
+	MaxNearbyLimit        = 200
+)
+
+// GeoPoint is a resolved coordinate pair
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Geocoder resolves a postal address to coordinates. It reports found=false when the address
+// is unknown. NominatimGeocoder is built in; other services plug in through SetGeocoder.
+type Geocoder interface {
+	Name() string
+	Geocode(ctx context.Context, address string) (point GeoPoint, found bool, err error)
+}
+
+// NominatimGeocoder queries an OpenStreetMap Nominatim server. The public server allows one
+// request per second and requires an identifying User-Agent, so requests are serialized.
+type NominatimGeocoder struct {
+	BaseURL      string // default "https://nominatim.openstreetmap.org"
+	UserAgent    string
+	CountryCodes string // optional, e.g. "in"
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (g *NominatimGeocoder) Name() string { return "nominatim" }
+
+var geocoderHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Geocode returns the best match of the address
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) (GeoPoint, bool, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://nominatim.openstreetmap.org"
+	}
+	params := url.Values{"q": {address}, "format": {"jsonv2"}, "limit": {"1"}}
+	if g.CountryCodes != "" {
+		params.Set("countrycodes", g.CountryCodes)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return GeoPoint{}, false, err
+	}
+	req.Header.Set("User-Agent", g.UserAgent)
+
+	g.mu.Lock()
+	if wait := time.Second - time.Since(g.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	resp, err := geocoderHTTPClient.Do(req)
+	g.last = time.Now()
+	g.mu.Unlock()
+	if err != nil {
+		return GeoPoint{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return GeoPoint{}, false, fmt.Errorf("nominatim returned %s", resp.Status)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return GeoPoint{}, false, fmt.Errorf("decoding nominatim response: %w", err)
+	}
+	if len(results) == 0 {
+		return GeoPoint{}, false, nil
+	}
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return GeoPoint{}, false, err
+	}
+	lng, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return GeoPoint{}, false, err
+	}
+	return GeoPoint{Latitude: lat, Longitude: lng}, true, nil
+}
+
+var (
+	geocoder     Geocoder
+	geocoderOnce sync.Once
+)
+
+// SetGeocoder overrides the geocoder (e.g. a commercial API, or a fake in tests)
+func SetGeocoder(g Geocoder) {
+	geocoderOnce.Do(func() {})
+	geocoder = g
+}
+
+// getGeocoder returns the geocoder selected by GEOCODER ("nominatim", with NOMINATIM_URL,
+// GEOCODER_USER_AGENT and GEOCODER_COUNTRY_CODES)
+func getGeocoder() (Geocoder, error) {
+	geocoderOnce.Do(func() {
+		if settings := config.Geocoding; settings.Provider == "nominatim" {
+			geocoder = &NominatimGeocoder{
+				BaseURL:      settings.NominatimURL,
+				UserAgent:    settings.UserAgent,
+				CountryCodes: settings.CountryCodes,
+			}
+		}
+	})
+	if geocoder == nil {
+		return nil, ErrGeocoderNotConfigured
+	}
+	return geocoder, nil
+}
+
+// branchGeocodeAddress is the free-form address the geocoder is asked for, most specific part
+// first, and the source of the branch's current coordinates. The address is empty when the
+// branch has neither an address nor a city.
+func branchGeocodeAddress(db *gorm.DB, branchID uint) (address, source string, err error) {
+	var branch models.Branch
+	if err := db.Select("id", "address", "pincode", "country_id", "state_id", "district_id", "city_id", "location_source").
+		Preload("Country").Preload("State").Preload("District").Preload("City").
+		First(&branch, branchID).Error; err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(branch.Address) == "" && branch.City.Name == "" {
+		return "", branch.LocationSource, nil
+	}
+	var parts []string
+	for _, part := range []string{branch.Address, branch.City.Name, branch.District.Name, branch.State.Name, branch.Pincode, branch.Country.Name} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", "), branch.LocationSource, nil
+}
+
+// coordinateUpdates marks coordinates set in a branch update as entered by hand, so the
+// geocoder leaves them alone; clearing them hands the branch back to the geocoder
+func coordinateUpdates(updatedData map[string]interface{}) {
+	delete(updatedData, "location_source")
+	if latitude, ok := updatedData["latitude"]; ok {
+		if latitude == nil {
+			updatedData["location_source"] = nil
+		} else {
+			updatedData["location_source"] = models.LocationSourceManual
+		}
+	}
+}
+
+// geocodeAddressFields are the branch columns the geocoded address is built from
+var geocodeAddressFields = []string{"address", "pincode", "country_id", "state_id", "district_id", "city_id"}
+
+// geocodeBaseline reports whether a branch update may call for geocoding and, if so, the
+// address before it for queueBranchGeocode. Clearing the coordinates asks for geocoding even
+// when the address stays the same.
+func geocodeBaseline(branchID uint, updatedData map[string]interface{}) (string, bool) {
+	if _, err := getGeocoder(); err != nil {
+		return "", false
+	}
+	if latitude, ok := updatedData["latitude"]; ok {
+		return "", latitude == nil
+	}
+	for _, field := range geocodeAddressFields {
+		if _, ok := updatedData[field]; ok {
+			address, _, err := branchGeocodeAddress(config.DB, branchID)
+			return address, err == nil
+		}
+	}
+	return "", false
+}
+
+// queueBranchGeocode enqueues a geocoding job when a geocoder is configured, the branch's
+// coordinates were not entered by hand and its address differs from before (empty for new
+// branches). Failures are logged; they never fail the branch write.
+func queueBranchGeocode(branchID uint, previousAddress string) {
+	if _, err := getGeocoder(); err != nil {
+		return
+	}
+	logger := utils.BaseLogger().With(zap.Uint("branch_id", branchID))
+
+	address, source, err := branchGeocodeAddress(config.DB, branchID)
+	if err != nil {
+		logger.Warn("Failed to build branch address for geocoding", zap.Error(err))
+		return
+	}
+	if source == models.LocationSourceManual || address == "" || address == previousAddress {
+		return
+	}
+	if _, err := EnqueueJob(context.Background(), JobTypeGeocodeBranch, branchGeocodePayload{BranchID: branchID}, JobOptions{}); err != nil {
+		logger.Warn("Failed to queue branch geocoding", zap.Error(err))
+	}
+}
+
+type branchGeocodePayload struct {
+	BranchID uint `json:"branch_id"`
+}
+
+// runBranchGeocodeJob geocodes the current address of a branch. The address is read when the
+// job runs, so of several quick edits the last one wins, and coordinates entered by hand in the
+// meantime are kept.
+func runBranchGeocodeJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload branchGeocodePayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	g, err := getGeocoder()
+	if err != nil {
+		return nil, PermanentJobError(err)
+	}
+
+	address, _, err := branchGeocodeAddress(config.DB.WithContext(ctx), payload.BranchID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.JSONB{"skipped": "branch not found"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		return models.JSONB{"skipped": "branch has no address"}, nil
+	}
+
+	point, found, err := g.Geocode(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return models.JSONB{"found": false, "address": address}, nil
+	}
+
+	// Coordinates are not version-bumped: they do not conflict with edits of the branch form
+	result := config.DB.WithContext(ctx).Model(&models.Branch{}).
+		Where("id = ? AND location_source IS DISTINCT FROM ?", payload.BranchID, models.LocationSourceManual).
+		Updates(map[string]interface{}{
+			"latitude":        point.Latitude,
+			"longitude":       point.Longitude,
+			"location_source": models.LocationSourceGeocoder,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return models.JSONB{
+		"found":     true,
+		"address":   address,
+		"latitude":  point.Latitude,
+		"longitude": point.Longitude,
+		"updated":   result.RowsAffected > 0,
+		"geocoder":  g.Name(),
+	}, nil
+}
+
+// NearbyBranch is a branch in a radius search, with its distance from the search point
+type NearbyBranch struct {
+	ID             uint    `json:"id"`
+	PublicID       string  `json:"public_id"`
+	Name           string  `json:"name"`
+	ParentBranchID *uint   `json:"parent_branch_id,omitempty"`
+	Address        string  `json:"address,omitempty"`
+	Pincode        string  `json:"pincode,omitempty"`
+	ContactNumber  string  `json:"contact_number,omitempty"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	DistanceKm     float64 `json:"distance_km"`
+}
+
+// ParseNearbyQuery validates the parameters of a nearby search; radius and limit fall back to
+// their defaults when empty
+func ParseNearbyQuery(lat, lng, radiusKm, limit string) (point GeoPoint, radius float64, count int, err error) {
+	if point.Latitude, err = strconv.ParseFloat(lat, 64); err != nil || point.Latitude < -90 || point.Latitude > 90 {
+		return point, 0, 0, fmt.Errorf("%w: lat must be a number between -90 and 90", ErrInvalidNearbyQuery)
+	}
+	if point.Longitude, err = strconv.ParseFloat(lng, 64); err != nil || point.Longitude < -180 || point.Longitude > 180 {
+		return point, 0, 0, fmt.Errorf("%w: lng must be a number between -180 and 180", ErrInvalidNearbyQuery)
+	}
+	radius, count = DefaultNearbyRadiusKm, DefaultNearbyLimit
+	if radiusKm != "" {
+		if radius, err = strconv.ParseFloat(radiusKm, 64); err != nil || !(radius > 0 && radius <= MaxNearbyRadiusKm) {
+			return point, 0, 0, fmt.Errorf("%w: radius_km must be greater than 0 and at most %d", ErrInvalidNearbyQuery, MaxNearbyRadiusKm)
+		}
+	}
+	if limit != "" {
+		if count, err = strconv.Atoi(limit); err != nil || count < 1 || count > MaxNearbyLimit {
+			return point, 0, 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidNearbyQuery, MaxNearbyLimit)
+		}
+	}
+	return point, radius, count, nil
+}
+
+// GetNearbyBranches returns active branches and child branches within radiusKm of a point,
+// nearest first. The earth_box condition uses the GiST index; earth_distance then trims the
+// box corners to the exact radius. Only branches in the scope are returned.
+func GetNearbyBranches(point GeoPoint, radiusKm float64, limit int, scope *BranchScope) ([]NearbyBranch, error) {
+	meters := radiusKm * 1000
+	branches := []NearbyBranch{}
+	err := scope.Apply(config.DB.Model(&models.Branch{}), "id").
+		Select(`id, public_id::text AS public_id, name, parent_branch_id, address, pincode, contact_number,
+			latitude, longitude, earth_distance(ll_to_earth(?, ?), ll_to_earth(latitude, longitude)) / 1000 AS distance_km`,
+			point.Latitude, point.Longitude).
+		Where("status = ? AND is_sandbox = ? AND latitude IS NOT NULL AND longitude IS NOT NULL", true, false).
+		Where("earth_box(ll_to_earth(?, ?), ?) @> ll_to_earth(latitude, longitude)", point.Latitude, point.Longitude, meters).
+		Where("earth_distance(ll_to_earth(?, ?), ll_to_earth(latitude, longitude)) <= ?", point.Latitude, point.Longitude, meters).
+		Order("distance_km, id").
+		Limit(limit).
+		Scan(&branches).Error
+	return branches, err
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Branch image slots, see SetBranchImage
+const (
+	BranchImageCover            = "cover"
+	BranchImageCoordinatorPhoto = "coordinator_photo"
+)
+
+var branchImageColumns = map[string]string{
+	BranchImageCover:            "cover_media_id",
+	BranchImageCoordinatorPhoto: "coordinator_photo_media_id",
+}
+
+// ErrInvalidBranchImage is returned when the media cannot be used as a branch image
+var ErrInvalidBranchImage = errors.New("invalid branch image")
+
+// SetBranchImage marks one of the branch's own images as its cover image or coordinator photo.
+// A nil mediaID clears the slot. The change is audited against the branch (or child branch).
+func SetBranchImage(branchID uint, slot string, mediaID *uint, actor AuditActor) (*models.Branch, error) {
+	column, ok := branchImageColumns[slot]
+	if !ok {
+		return nil, fmt.Errorf("unknown branch image slot %q", slot)
+	}
+
+	var branch models.Branch
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&branch, branchID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBranchNotFound
+			}
+			return err
+		}
+
+		if mediaID != nil {
+			var media models.BranchMedia
+			if err := tx.Where("id = ? AND branch_id = ?", *mediaID, branchID).First(&media).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: media %d does not belong to branch %d", ErrInvalidBranchImage, *mediaID, branchID)
+				}
+				return err
+			}
+			if media.FileType != "image" {
+				return fmt.Errorf("%w: media %d is not an image", ErrInvalidBranchImage, *mediaID)
+			}
+		}
+
+		entityType := AuditEntityBranch
+		if branch.ParentBranchID != nil {
+			entityType = AuditEntityChildBranch
+		}
+		before := loadAuditSnapshot(tx, entityType, branch.ID)
+		now := time.Now()
+		if err := tx.Model(&branch).Updates(map[string]interface{}{
+			column:       mediaID,
+			"updated_on": &now,
+		}).Error; err != nil {
+			return err
+		}
+		changes := DiffAuditSnapshots(before, loadAuditSnapshot(tx, entityType, branch.ID))
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := tx.Create(actor.auditEntry(entityType, branch.ID, models.AuditActionUpdate, changes, now)).Error; err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.First(&branch, branchID).Error; err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// AttachBranchImages fills in CoverImage and CoordinatorPhoto on the given branches (and their
+// preloaded parent/children) with presigned URLs, so list pages do not have to fetch galleries.
+// Images are decorative: deleted media and presign failures only leave the image out.
+func AttachBranchImages(ctx context.Context, branches ...*models.Branch) {
+	var all []*models.Branch
+	var collect func(b *models.Branch)
+	collect = func(b *models.Branch) {
+		all = append(all, b)
+		if b.Parent != nil {
+			all = append(all, b.Parent)
+		}
+		for i := range b.Children {
+			collect(&b.Children[i])
+		}
+	}
+	for _, b := range branches {
+		if b != nil {
+			collect(b)
+		}
+	}
+
+	mediaIDs := make([]uint, 0, len(all))
+	for _, b := range all {
+		if b.CoverMediaID != nil {
+			mediaIDs = append(mediaIDs, *b.CoverMediaID)
+		}
+		if b.CoordinatorPhotoMediaID != nil {
+			mediaIDs = append(mediaIDs, *b.CoordinatorPhotoMediaID)
+		}
+	}
+	if len(mediaIDs) == 0 {
+		return
+	}
+
+	var mediaList []models.BranchMedia
+	if err := config.DB.Select("id", "s3_key", "thumbnail_s3_key", "thumbnail_medium_s3_key").
+		Where("id IN ?", mediaIDs).Find(&mediaList).Error; err != nil {
+		utils.Logger(ctx).Warn("Failed to load branch images", zap.Error(err))
+		return
+	}
+
+	// One key per image for the card (medium thumbnail, else the original) plus the small thumbnail
+	keys := make([]string, 0, len(mediaList)*2)
+	for _, media := range mediaList {
+		keys = append(keys, thumbnailKeys(media.ThumbnailS3Key, media.ThumbnailMediumS3Key)...)
+		if !hasThumbnail(media.ThumbnailMediumS3Key) && media.S3Key != "" {
+			keys = append(keys, media.S3Key)
+		}
+	}
+	urls, err := PresignURLs(ctx, keys, 15*time.Minute)
+	if err != nil {
+		utils.Logger(ctx).Warn("Failed to presign branch images", zap.Error(err))
+		return
+	}
+
+	images := make(map[uint]*models.BranchImage, len(mediaList))
+	for _, media := range mediaList {
+		url := thumbnailURL(urls, media.ThumbnailMediumS3Key)
+		if url == "" {
+			url = urls[media.S3Key]
+		}
+		if url == "" {
+			continue
+		}
+		images[media.ID] = &models.BranchImage{
+			MediaID:      media.ID,
+			URL:          url,
+			ThumbnailURL: thumbnailURL(urls, media.ThumbnailS3Key),
+		}
+	}
+
+	for _, b := range all {
+		if b.CoverMediaID != nil {
+			b.CoverImage = images[*b.CoverMediaID]
+		}
+		if b.CoordinatorPhotoMediaID != nil {
+			b.CoordinatorPhoto = images[*b.CoordinatorPhotoMediaID]
+		}
+	}
+}
+
+// AttachBranchListImages is AttachBranchImages for a slice of branches
+func AttachBranchListImages(ctx context.Context, branches []models.Branch) {
+	ptrs := make([]*models.Branch, len(branches))
+	for i := range branches {
+		ptrs[i] = &branches[i]
+	}
+	AttachBranchImages(ctx, ptrs...)
+}
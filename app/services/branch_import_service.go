@@ -0,0 +1,565 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// MaxBranchImportRows caps the number of data rows accepted in one import file
+const MaxBranchImportRows = 5000
+
+// branchImportBatchSize is the number of rows inserted per INSERT statement
+const branchImportBatchSize = 200
+
+var (
+	ErrUnsupportedImportFile = errors.New("unsupported file type (use .csv or .xlsx)")
+	ErrEmptyImportFile       = errors.New("import file has no data rows")
+)
+
+// BranchImportRowError is a validation problem on a single spreadsheet row.
+// Row is the 1-based row number as shown in the spreadsheet (the header is row 1).
+type BranchImportRowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// BranchImportResult summarizes an import. Nothing is written when DryRun is set
+// or when any row has errors.
+type BranchImportResult struct {
+	DryRun    bool                   `json:"dry_run"`
+	TotalRows int                    `json:"total_rows"`
+	ValidRows int                    `json:"valid_rows"`
+	Inserted  int                    `json:"inserted"`
+	Errors    []BranchImportRowError `json:"errors"`
+	BranchIDs []uint                 `json:"branch_ids,omitempty"`
+}
+
+// BranchImportRow is a spreadsheet row keyed by normalized header name
+type BranchImportRow struct {
+	number int
+	values map[string]string
+}
+
+func (r BranchImportRow) get(column string) string {
+	return strings.TrimSpace(r.values[column])
+}
+
+// ParseBranchImportFile reads a CSV or XLSX (first sheet) file. The first row must be
+// a header; header names are matched case-insensitively with spaces treated as underscores,
+// so both "contact_number" and "Contact Number" work.
+func ParseBranchImportFile(filename string, r io.Reader) ([]BranchImportRow, error) {
+	return parseImportFile(filename, r, MaxBranchImportRows)
+}
+
+func parseImportFile(filename string, r io.Reader, maxRows int) ([]BranchImportRow, error) {
+	var records [][]string
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		var err error
+		if records, err = reader.ReadAll(); err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+		defer f.Close()
+		if records, err = f.GetRows(f.GetSheetName(0)); err != nil {
+			return nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+	default:
+		return nil, ErrUnsupportedImportFile
+	}
+
+	if len(records) < 2 {
+		return nil, ErrEmptyImportFile
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		name = strings.TrimPrefix(name, "\ufeff") // Excel adds a BOM to UTF-8 CSVs
+		header[i] = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+	}
+
+	rows := make([]BranchImportRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := BranchImportRow{number: i + 2, values: make(map[string]string, len(header))}
+		empty := true
+		for col, value := range record {
+			if col < len(header) && header[col] != "" {
+				row.values[header[col]] = value
+				if strings.TrimSpace(value) != "" {
+					empty = false
+				}
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrEmptyImportFile
+	}
+	if len(rows) > maxRows {
+		return nil, fmt.Errorf("import file has %d rows, the limit is %d", len(rows), maxRows)
+	}
+	return rows, nil
+}
+
+// branchImportPayload is the JobTypeBranchImport payload; the file waits in storage
+type branchImportPayload struct {
+	FileKey   string       `json:"file_key"`
+	Filename  string       `json:"filename"`
+	Child     bool         `json:"child"`
+	DryRun    bool         `json:"dry_run"`
+	CreatedBy string       `json:"created_by"`
+	Scope     *BranchScope `json:"scope,omitempty"` // the importing user's branch scope, nil for admins
+	Actor     AuditActor   `json:"actor"`
+}
+
+// QueueBranchImport stores an import file and imports it in the background. The job result
+// is the BranchImportResult; row validation errors do not fail the job.
+func QueueBranchImport(ctx context.Context, filename string, data []byte, child, dryRun bool, createdBy string, scope *BranchScope, actor AuditActor) (*models.Job, error) {
+	if !child && scope != nil {
+		return nil, ErrOutOfBranchScope
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".xlsx":
+	default:
+		return nil, ErrUnsupportedImportFile
+	}
+
+	uploaded, err := UploadFile(ctx, data, filename, "application/octet-stream", "imports")
+	if err != nil {
+		return nil, err
+	}
+	payload := branchImportPayload{
+		FileKey:   uploaded.S3Key,
+		Filename:  filename,
+		Child:     child,
+		DryRun:    dryRun,
+		CreatedBy: createdBy,
+		Scope:     scope,
+		Actor:     actor,
+	}
+	job, err := EnqueueJob(ctx, JobTypeBranchImport, payload, JobOptions{CreatedBy: actor.UserID})
+	if err != nil {
+		QueueStorageCleanup(ctx, time.Time{}, uploaded.S3Key)
+		return nil, err
+	}
+	return job, nil
+}
+
+func runBranchImportJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload branchImportPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Get(ctx, payload.FileKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, PermanentJobError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ParseBranchImportFile(payload.Filename, body)
+	body.Close()
+	if err != nil {
+		QueueStorageCleanup(ctx, time.Time{}, payload.FileKey)
+		return nil, PermanentJobError(err)
+	}
+
+	run.SetProgress(20, fmt.Sprintf("Importing %d rows", len(rows)))
+	result, err := ImportBranches(rows, payload.Child, payload.DryRun, payload.CreatedBy, payload.Scope, payload.Actor)
+	if err != nil {
+		return nil, err
+	}
+	QueueStorageCleanup(ctx, time.Time{}, payload.FileKey)
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var data models.JSONB
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ImportBranches validates every row and, unless dryRun is set or a row failed,
+// inserts all branches in a single transaction. With child set, rows are imported as
+// child branches and must name their parent via parent_branch_id or parent_branch_code, a
+// branch within scope. Top-level branches are only imported by users without a branch scope
+// (ErrOutOfBranchScope), like CreateBranchHandler.
+func ImportBranches(rows []BranchImportRow, child, dryRun bool, createdBy string, scope *BranchScope, actor AuditActor) (*BranchImportResult, error) {
+	if !child && scope != nil {
+		return nil, ErrOutOfBranchScope
+	}
+	result := &BranchImportResult{DryRun: dryRun, TotalRows: len(rows), Errors: []BranchImportRowError{}}
+
+	locations := newLocationResolver()
+	parents := map[string]*models.Branch{}
+	seen := map[string]map[string]int{"email": {}, "contact_number": {}, "branch_code": {}}
+
+	branches := make([]models.Branch, 0, len(rows))
+	for _, row := range rows {
+		branch, rowErrors := buildImportedBranch(row, child, locations, parents)
+		if branch.ParentBranchID != nil && !scope.Allows(*branch.ParentBranchID) {
+			rowErrors = append(rowErrors, BranchImportRowError{Row: row.number, Column: "parent_branch_id", Error: ErrOutOfBranchScope.Error()})
+		}
+
+		// Duplicates inside the file
+		for column, value := range map[string]string{
+			"email":          strings.ToLower(branch.Email),
+			"contact_number": branch.ContactNumber,
+			"branch_code":    branch.BranchCode,
+		} {
+			if value == "" {
+				continue
+			}
+			if first, dup := seen[column][value]; dup {
+				rowErrors = append(rowErrors, BranchImportRowError{Row: row.number, Column: column, Error: fmt.Sprintf("duplicate of row %d", first)})
+				continue
+			}
+			seen[column][value] = row.number
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+		branch.CreatedBy = createdBy
+		branches = append(branches, branch)
+	}
+
+	// Duplicates against existing branches (including soft-deleted ones, the unique indexes still apply)
+	if err := checkExistingBranchValues(rows, branches, result); err != nil {
+		return nil, err
+	}
+
+	result.ValidRows = result.TotalRows - countErrorRows(result.Errors)
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	entityType := AuditEntityBranch
+	if child {
+		entityType = AuditEntityChildBranch
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&branches, branchImportBatchSize).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		entries := make([]*models.AuditLog, 0, len(branches))
+		for i := range branches {
+			changes := DiffAuditSnapshots(nil, auditSnapshotOf(&branches[i]))
+			entries = append(entries, actor.auditEntry(entityType, branches[i].ID, models.AuditActionCreate, changes, now))
+		}
+		return tx.CreateInBatches(entries, branchImportBatchSize).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Inserted = len(branches)
+	for _, b := range branches {
+		result.BranchIDs = append(result.BranchIDs, b.ID)
+	}
+	return result, nil
+}
+
+// buildImportedBranch converts a row into a branch, collecting every problem found
+func buildImportedBranch(row BranchImportRow, child bool, locations *locationResolver, parents map[string]*models.Branch) (models.Branch, []BranchImportRowError) {
+	var errs []BranchImportRowError
+	fail := func(column string, err error) {
+		errs = append(errs, BranchImportRowError{Row: row.number, Column: column, Error: err.Error()})
+	}
+
+	branch := models.Branch{
+		Name:            row.get("name"),
+		Email:           row.get("email"),
+		CoordinatorName: row.get("coordinator_name"),
+		ContactNumber:   row.get("contact_number"),
+		Address:         row.get("address"),
+		Pincode:         row.get("pincode"),
+		PostOffice:      row.get("post_office"),
+		PoliceStation:   row.get("police_station"),
+		OpenDays:        row.get("open_days"),
+		DailyStartTime:  row.get("daily_start_time"),
+		DailyEndTime:    row.get("daily_end_time"),
+		BranchCode:      row.get("branch_code"),
+		Status:          true,
+	}
+
+	if err := validators.ValidateBranchInput(branch.Name, branch.Email, branch.ContactNumber, branch.CoordinatorName); err != nil {
+		fail("", err)
+	}
+	if branch.Pincode != "" {
+		if _, err := strconv.Atoi(branch.Pincode); err != nil || (len(branch.Pincode) != 5 && len(branch.Pincode) != 6) {
+			fail("pincode", errors.New("pincode must be 5 or 6 digits"))
+		}
+	}
+	for _, column := range []string{"daily_start_time", "daily_end_time"} {
+		if err := validators.ValidateTimeFormat(row.get(column)); err != nil {
+			fail(column, err)
+		}
+	}
+	if len(branch.BranchCode) > 50 {
+		fail("branch_code", errors.New("branch code must not exceed 50 characters"))
+	}
+
+	if value := row.get("established_on"); value != "" {
+		if t, err := time.Parse("2006-01-02", value); err == nil {
+			branch.EstablishedOn = &t
+		} else {
+			fail("established_on", errors.New("use YYYY-MM-DD"))
+		}
+	}
+	if value := row.get("aashram_area"); value != "" {
+		if area, err := strconv.ParseFloat(value, 64); err == nil && area >= 0 {
+			branch.AashramArea = area
+		} else {
+			fail("aashram_area", errors.New("must be a non-negative number"))
+		}
+	}
+	if value := row.get("ncr"); value != "" {
+		if ncr, err := strconv.ParseBool(strings.ToLower(value)); err == nil {
+			branch.NCR = ncr
+		} else if strings.EqualFold(value, "yes") || strings.EqualFold(value, "no") {
+			branch.NCR = strings.EqualFold(value, "yes")
+		} else {
+			fail("ncr", errors.New("must be true/false or yes/no"))
+		}
+	}
+
+	if err := locations.resolve(row, &branch); err != nil {
+		fail("", err)
+	}
+
+	if child {
+		parent, column, err := resolveImportParent(row, parents)
+		if err != nil {
+			fail(column, err)
+		} else {
+			branch.ParentBranchID = &parent.ID
+			// Child branches always inherit the parent's coordinator (see CreateChildBranchHandler)
+			branch.CoordinatorName = parent.CoordinatorName
+		}
+	}
+
+	return branch, errs
+}
+
+// resolveImportParent finds the parent of a child branch row by ID or branch code
+func resolveImportParent(row BranchImportRow, cache map[string]*models.Branch) (*models.Branch, string, error) {
+	column, value := "parent_branch_id", row.get("parent_branch_id")
+	if value == "" {
+		column, value = "parent_branch_code", row.get("parent_branch_code")
+	}
+	if value == "" {
+		return nil, "parent_branch_id", errors.New("parent_branch_id or parent_branch_code is required")
+	}
+
+	key := column + ":" + value
+	if parent, ok := cache[key]; ok {
+		if parent == nil {
+			return nil, column, errors.New("parent branch not found")
+		}
+		return parent, column, nil
+	}
+
+	var parent models.Branch
+	query := config.DB.Select("id", "coordinator_name", "parent_branch_id")
+	var err error
+	if column == "parent_branch_id" {
+		id, convErr := strconv.ParseUint(value, 10, 64)
+		if convErr != nil {
+			return nil, column, errors.New("must be a number")
+		}
+		err = query.First(&parent, id).Error
+	} else {
+		err = query.Where("branch_code = ?", value).First(&parent).Error
+	}
+	if err != nil {
+		cache[key] = nil
+		return nil, column, errors.New("parent branch not found")
+	}
+	if parent.ParentBranchID != nil {
+		return nil, column, errors.New("parent must be a top-level branch")
+	}
+	cache[key] = &parent
+	return &parent, column, nil
+}
+
+// checkExistingBranchValues flags rows whose unique values are already taken in the database
+func checkExistingBranchValues(rows []BranchImportRow, branches []models.Branch, result *BranchImportResult) error {
+	rowByIndex := make(map[int]int, len(branches)) // index into branches -> spreadsheet row number
+	j := 0
+	failed := map[int]bool{}
+	for _, e := range result.Errors {
+		failed[e.Row] = true
+	}
+	for _, row := range rows {
+		if !failed[row.number] {
+			rowByIndex[j] = row.number
+			j++
+		}
+	}
+
+	for _, column := range []string{"email", "contact_number", "branch_code"} {
+		values := make([]string, 0, len(branches))
+		for _, b := range branches {
+			if v := importUniqueValue(b, column); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		var taken []string
+		if err := config.DB.Unscoped().Model(&models.Branch{}).Where(column+" IN ?", values).Pluck(column, &taken).Error; err != nil {
+			return err
+		}
+		takenSet := make(map[string]bool, len(taken))
+		for _, v := range taken {
+			takenSet[v] = true
+		}
+		for i, b := range branches {
+			if v := importUniqueValue(b, column); v != "" && takenSet[v] {
+				result.Errors = append(result.Errors, BranchImportRowError{Row: rowByIndex[i], Column: column, Error: "already exists"})
+			}
+		}
+	}
+	return nil
+}
+
+func importUniqueValue(b models.Branch, column string) string {
+	switch column {
+	case "email":
+		return b.Email
+	case "contact_number":
+		return b.ContactNumber
+	default:
+		return b.BranchCode
+	}
+}
+
+func countErrorRows(errs []BranchImportRowError) int {
+	rows := map[int]bool{}
+	for _, e := range errs {
+		rows[e.Row] = true
+	}
+	return len(rows)
+}
+
+// locationResolver maps country/state/district/city columns (IDs or names) to IDs,
+// caching lookups since import files tend to repeat the same few locations
+type locationResolver struct {
+	cache map[string]uint
+}
+
+func newLocationResolver() *locationResolver {
+	return &locationResolver{cache: map[string]uint{}}
+}
+
+func (l *locationResolver) resolve(row BranchImportRow, branch *models.Branch) error {
+	var countryID, stateID, districtID, cityID uint
+	var err error
+
+	if countryID, err = l.lookup(&models.Country{}, "country", row.get("country"), "", 0); err != nil {
+		return err
+	}
+	if stateID, err = l.lookup(&models.State{}, "state", row.get("state"), "country_id", countryID); err != nil {
+		return err
+	}
+	if districtID, err = l.lookup(&models.District{}, "district", row.get("district"), "state_id", stateID); err != nil {
+		return err
+	}
+	if cityID, err = l.lookup(&models.City{}, "city", row.get("city"), "state_id", stateID); err != nil {
+		return err
+	}
+
+	if countryID != 0 {
+		branch.CountryID = &countryID
+	}
+	if stateID != 0 {
+		branch.StateID = &stateID
+	}
+	if districtID != 0 {
+		branch.DistrictID = &districtID
+	}
+	if cityID != 0 {
+		branch.CityID = &cityID
+	}
+	return nil
+}
+
+// lookup resolves a numeric ID or a case-insensitive name, scoped to the parent location when known
+func (l *locationResolver) lookup(model interface{}, kind, value, parentColumn string, parentID uint) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	key := fmt.Sprintf("%s:%d:%s", kind, parentID, strings.ToLower(value))
+	if id, ok := l.cache[key]; ok {
+		if id == 0 {
+			return 0, fmt.Errorf("unknown %s %q", kind, value)
+		}
+		return id, nil
+	}
+
+	query := config.DB.Model(model)
+	if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("LOWER(name) = LOWER(?)", value)
+	}
+	if parentColumn != "" && parentID != 0 {
+		query = query.Where(parentColumn+" = ?", parentID)
+	}
+
+	var ids []uint
+	if err := query.Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		l.cache[key] = 0
+		if parentID != 0 {
+			return 0, fmt.Errorf("unknown %s %q for the given %s", kind, value, strings.TrimSuffix(parentColumn, "_id"))
+		}
+		return 0, fmt.Errorf("unknown %s %q", kind, value)
+	}
+	l.cache[key] = ids[0]
+	return ids[0], nil
+}
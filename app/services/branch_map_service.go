@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// branchMapCacheTTL is how long a clustered map tile is served from memory. Branch locations
+// change rarely, so a few minutes of staleness is acceptable for the public map.
+const branchMapCacheTTL = 5 * time.Minute
+
+// maxBranchMapCacheEntries bounds the in-memory map cache
+const maxBranchMapCacheEntries = 5000
+
+// maxBranchMapZoom is the zoom level from which every branch is returned as its own pin
+const maxBranchMapZoom = 16
+
+// ErrInvalidBoundingBox is returned for malformed bbox parameters
+var ErrInvalidBoundingBox = errors.New("invalid bbox")
+
+// BoundingBox is a map viewport in degrees
+type BoundingBox struct {
+	West, South, East, North float64
+}
+
+// ParseBoundingBox parses "west,south,east,north" (min longitude, min latitude, max longitude,
+// max latitude), the usual bbox order of web map libraries
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("%w: expected west,south,east,north", ErrInvalidBoundingBox)
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return BoundingBox{}, fmt.Errorf("%w: %q is not a number", ErrInvalidBoundingBox, part)
+		}
+		coords[i] = v
+	}
+	bbox := BoundingBox{West: coords[0], South: coords[1], East: coords[2], North: coords[3]}
+	switch {
+	case bbox.South < -90 || bbox.North > 90 || bbox.West < -180 || bbox.East > 180:
+		return BoundingBox{}, fmt.Errorf("%w: coordinates out of range", ErrInvalidBoundingBox)
+	case bbox.South >= bbox.North || bbox.West >= bbox.East:
+		return BoundingBox{}, fmt.Errorf("%w: west/south must be less than east/north", ErrInvalidBoundingBox)
+	}
+	return bbox, nil
+}
+
+// BranchMapPin is a cluster of branches, or a single branch when Count is 1
+type BranchMapPin struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int     `json:"count"`
+	// Set for single branches; fetch details from /public/api/branches/:public_id
+	PublicID string `json:"public_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	// Extent of a cluster, for zooming in on click
+	Bounds *BoundingBox `json:"bounds,omitempty"`
+}
+
+// BranchMap is the response of the public branch map
+type BranchMap struct {
+	Zoom     int            `json:"zoom"`
+	Branches int            `json:"branches"`
+	Pins     []BranchMapPin `json:"pins"`
+}
+
+type branchMapCacheEntry struct {
+	result    *BranchMap
+	expiresAt time.Time
+}
+
+var branchMapCache = struct {
+	sync.RWMutex
+	entries map[string]branchMapCacheEntry
+}{entries: map[string]branchMapCacheEntry{}}
+
+// GetBranchMap returns the active branches inside bbox, grouped into grid clusters sized for the
+// zoom level (0-16, web map zoom). The bbox is snapped outward to the grid so that nearby
+// viewports share cached results.
+func GetBranchMap(bbox BoundingBox, zoom int) (*BranchMap, error) {
+	if zoom < 0 {
+		zoom = 0
+	}
+	if zoom > maxBranchMapZoom {
+		zoom = maxBranchMapZoom
+	}
+
+	// Roughly four cells per 256px map tile
+	cell := 360 / math.Exp2(float64(zoom)) / 4
+	minX, maxX := math.Floor(bbox.West/cell), math.Ceil(bbox.East/cell)
+	minY, maxY := math.Floor(bbox.South/cell), math.Ceil(bbox.North/cell)
+	cacheKey := fmt.Sprintf("%d:%.0f:%.0f:%.0f:%.0f", zoom, minX, minY, maxX, maxY)
+
+	branchMapCache.RLock()
+	entry, ok := branchMapCache.entries[cacheKey]
+	branchMapCache.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.result, nil
+	}
+
+	type clusterRow struct {
+		Count                          int
+		Latitude, Longitude            float64
+		MinLat, MinLng, MaxLat, MaxLng float64
+		PublicID                       string
+		Name                           string
+	}
+	var rows []clusterRow
+	query := config.DB.Model(&models.Branch{}).
+		Where("status = ? AND is_sandbox = ? AND latitude IS NOT NULL AND longitude IS NOT NULL", true, false).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minY*cell, maxY*cell, minX*cell, maxX*cell)
+	if zoom == maxBranchMapZoom {
+		query = query.Select(`1 AS count, latitude, longitude, latitude AS min_lat, longitude AS min_lng,
+			latitude AS max_lat, longitude AS max_lng, public_id::text AS public_id, name`)
+	} else {
+		query = query.Select(`COUNT(*) AS count, AVG(latitude) AS latitude, AVG(longitude) AS longitude,
+			MIN(latitude) AS min_lat, MIN(longitude) AS min_lng, MAX(latitude) AS max_lat, MAX(longitude) AS max_lng,
+			MIN(public_id::text) AS public_id, MIN(name) AS name`).
+			Group(fmt.Sprintf("FLOOR(latitude / %[1]g), FLOOR(longitude / %[1]g)", cell))
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := &BranchMap{Zoom: zoom, Pins: make([]BranchMapPin, 0, len(rows))}
+	for _, row := range rows {
+		pin := BranchMapPin{Latitude: row.Latitude, Longitude: row.Longitude, Count: row.Count}
+		if row.Count == 1 {
+			pin.PublicID = row.PublicID
+			pin.Name = row.Name
+		} else {
+			pin.Bounds = &BoundingBox{West: row.MinLng, South: row.MinLat, East: row.MaxLng, North: row.MaxLat}
+		}
+		result.Branches += row.Count
+		result.Pins = append(result.Pins, pin)
+	}
+
+	branchMapCache.Lock()
+	if len(branchMapCache.entries) >= maxBranchMapCacheEntries {
+		branchMapCache.entries = map[string]branchMapCacheEntry{}
+	}
+	branchMapCache.entries[cacheKey] = branchMapCacheEntry{result: result, expiresAt: time.Now().Add(branchMapCacheTTL)}
+	branchMapCache.Unlock()
+
+	return result, nil
+}
+
+// PublicBranch is the public directory view of a branch
+type PublicBranch struct {
+	PublicID       string              `json:"public_id"`
+	Name           string              `json:"name"`
+	Address        string              `json:"address,omitempty"`
+	Pincode        string              `json:"pincode,omitempty"`
+	City           string              `json:"city,omitempty"`
+	District       string              `json:"district,omitempty"`
+	State          string              `json:"state,omitempty"`
+	Country        string              `json:"country,omitempty"`
+	ContactNumber  string              `json:"contact_number,omitempty"`
+	OpenDays       string              `json:"open_days,omitempty"`
+	DailyStartTime string              `json:"daily_start_time,omitempty"`
+	DailyEndTime   string              `json:"daily_end_time,omitempty"`
+	Latitude       *float64            `json:"latitude,omitempty"`
+	Longitude      *float64            `json:"longitude,omitempty"`
+	CoverImage     *models.BranchImage `json:"cover_image,omitempty"`
+}
+
+// GetPublicBranch returns the public details of an active branch by its public ID
+func GetPublicBranch(ctx context.Context, publicID string) (*PublicBranch, error) {
+	if _, err := uuid.Parse(publicID); err != nil {
+		return nil, ErrBranchNotFound
+	}
+
+	var branch models.Branch
+	if err := config.DB.
+		Preload("Country").
+		Preload("State").
+		Preload("District").
+		Preload("City").
+		Where("public_id = ? AND status = ? AND is_sandbox = ?", publicID, true, false).
+		First(&branch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBranchNotFound
+		}
+		return nil, err
+	}
+	AttachBranchImages(ctx, &branch)
+
+	return &PublicBranch{
+		PublicID:       branch.PublicID,
+		Name:           branch.Name,
+		Address:        branch.Address,
+		Pincode:        branch.Pincode,
+		City:           branch.City.Name,
+		District:       branch.District.Name,
+		State:          branch.State.Name,
+		Country:        branch.Country.Name,
+		ContactNumber:  branch.ContactNumber,
+		OpenDays:       branch.OpenDays,
+		DailyStartTime: branch.DailyStartTime,
+		DailyEndTime:   branch.DailyEndTime,
+		Latitude:       branch.Latitude,
+		Longitude:      branch.Longitude,
+		CoverImage:     branch.CoverImage,
+	}, nil
+}
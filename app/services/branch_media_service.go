@@ -3,12 +3,15 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
 	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // CreateBranchMedia creates a new BranchMedia record
@@ -16,10 +19,26 @@ func CreateBranchMedia(media *models.BranchMedia) error {
 	return config.DB.Create(media).Error
 }
 
-// GetAllBranchMedia retrieves all BranchMedia records
-func GetAllBranchMedia() ([]models.BranchMedia, error) {
+// BranchMediaListSchema is what GET /branch-media may be sorted and filtered by (see listquery).
+// Without a sort the newest media come first.
+var BranchMediaListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":          {Column: "id", Type: listquery.Int, Sortable: true},
+	"branch_id":   {Column: "branch_id", Type: listquery.Int},
+	"name":        {Column: "name", Type: listquery.String, Sortable: true},
+	"file_type":   {Column: "file_type", Type: listquery.String, Sortable: true},
+	"category":    {Column: "category", Type: listquery.String, Sortable: true},
+	"scan_status": {Column: "scan_status", Type: listquery.String},
+	"created_on":  {Column: "created_on", Type: listquery.Time, Sortable: true},
+	"updated_on":  {Column: "updated_on", Type: listquery.Time, Sortable: true},
+}, listquery.Sort{Field: "created_on", Desc: true})
+
+// GetAllBranchMedia retrieves all BranchMedia records, sorted and filtered by a query parsed with
+// BranchMediaListSchema
+// Soft-deleted records are excluded unless includeDeleted is set
+// Only media of the scope's branches are returned
+func GetAllBranchMedia(query *listquery.Query, includeDeleted bool, scope *BranchScope) ([]models.BranchMedia, error) {
 	var medias []models.BranchMedia
-	if err := config.DB.
+	if err := query.Apply(scope.Apply(withDeleted(config.DB, includeDeleted), "branch_id")).
 		Preload("Branch").
 		Find(&medias).Error; err != nil {
 		return nil, err
@@ -44,9 +63,34 @@ func UpdateBranchMedia(media *models.BranchMedia) error {
 	return config.DB.Save(media).Error
 }
 
-// DeleteBranchMedia deletes a BranchMedia record
-func DeleteBranchMedia(mediaID uint) error {
-	return config.DB.Delete(&models.BranchMedia{}, mediaID).Error
+// DeleteBranchMedia soft-deletes a BranchMedia record, leaving a tombstone.
+// Returns ErrLegalHold while the media is on legal hold.
+func DeleteBranchMedia(mediaID uint, actor AuditActor) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var media models.BranchMedia
+		if err := tx.First(&media, mediaID).Error; err != nil {
+			return err
+		}
+		if err := checkLegalHold(tx, models.LegalHoldEntityBranchMedia, media.ID); err != nil {
+			return err
+		}
+		if err := recordMediaTombstone(tx, &media, actor); err != nil {
+			return err
+		}
+		return tx.Delete(&media).Error
+	})
+}
+
+// RestoreBranchMedia restores a soft-deleted BranchMedia record and marks its tombstone restored
+func RestoreBranchMedia(mediaID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := restoreSoftDeleted(tx, &models.BranchMedia{}, mediaID); err != nil {
+			return err
+		}
+		return tx.Model(&models.MediaTombstone{}).
+			Where("media_type = ? AND media_id = ? AND restored_on IS NULL", models.LegalHoldEntityBranchMedia, mediaID).
+			Update("restored_on", time.Now()).Error
+	})
 }
 
 // GetBranchMediaByID retrieves a BranchMedia record by ID
@@ -63,28 +107,73 @@ func GetBranchMediaByID(mediaID uint) (*models.BranchMedia, error) {
 // All media access uses short-lived pre-signed URLs for security
 // Items with empty S3Key are skipped with a warning (instead of failing the entire request)
 func ConvertBranchMediaToPresignedURLs(ctx context.Context, mediaList []models.BranchMedia) ([]models.BranchMedia, error) {
+	return presignBranchMedia(ctx, mediaList, true)
+}
+
+// ConvertBranchMediaToGalleryURLs is ConvertBranchMediaToPresignedURLs for gallery listings:
+// items with thumbnails only get thumbnail_url/medium_url, the original is presigned
+// only when no thumbnail exists yet
+func ConvertBranchMediaToGalleryURLs(ctx context.Context, mediaList []models.BranchMedia) ([]models.BranchMedia, error) {
+	return presignBranchMedia(ctx, mediaList, false)
+}
+
+func presignBranchMedia(ctx context.Context, mediaList []models.BranchMedia, includeOriginal bool) ([]models.BranchMedia, error) {
+	// Presign the whole page in one batch; URLs come from the presign cache where possible
+	keys := make([]string, 0, len(mediaList)*3)
+	for _, media := range mediaList {
+		if MediaDownloadable(media.ScanStatus) != nil {
+			continue
+		}
+		keys = append(keys, thumbnailKeys(media.ThumbnailS3Key, media.ThumbnailMediumS3Key)...)
+		if includeOriginal || !hasThumbnail(media.ThumbnailS3Key) {
+			keys = append(keys, media.S3Key)
+			// Videos browsers cannot play are served as their transcoded rendition
+			if key := transcodedURLKey(media.S3Key, media.TranscodeStatus, media.TranscodedS3Key); key != media.S3Key {
+				keys = append(keys, key)
+			}
+		}
+	}
+	urls, err := PresignURLs(ctx, keys, 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
 	result := make([]models.BranchMedia, 0, len(mediaList))
 	
 	for _, media := range mediaList {
 		// Skip items with empty S3Key - log warning but don't fail the entire request
 		if media.S3Key == "" {
-			log.Printf("WARNING: Skipping branch media item ID %d (branch_id: %d) - empty S3Key. Run backfill migration to populate s3_key from file_url", media.ID, media.BranchID)
+			utils.Logger(ctx).Warn("Skipping branch media item with empty s3_key; run the backfill migration to populate s3_key from file_url",
+				zap.Uint("media_id", media.ID), zap.Uint("branch_id", media.BranchID))
 			continue
 		}
 		
 		mediaCopy := media
+
+		// Files pending or failing the scan are listed (with scan_status) but not presigned
+		if MediaDownloadable(mediaCopy.ScanStatus) != nil {
+			result = append(result, mediaCopy)
+			continue
+		}
+
+		// Presigned thumbnail URLs (optional - generated in the background after upload)
+		mediaCopy.ThumbnailURL = thumbnailURL(urls, mediaCopy.ThumbnailS3Key)
+		mediaCopy.MediumURL = thumbnailURL(urls, mediaCopy.ThumbnailMediumS3Key)
+		if !includeOriginal && mediaCopy.ThumbnailURL != "" {
+			result = append(result, mediaCopy)
+			continue
+		}
 		
-		// Generate short-lived presigned URL (15 minutes for gallery listing)
-		presignedURL, err := GetPresignedURL(ctx, mediaCopy.S3Key, 15*time.Minute)
-		if err != nil {
-			// Log error but skip this item instead of failing entire request
-			log.Printf("ERROR: Failed to generate presigned URL for branch media ID %d (s3_key: %s): %v", mediaCopy.ID, mediaCopy.S3Key, err)
+		// Short-lived presigned URL (15 minutes for gallery listing)
+		presignedURL, ok := urls[mediaCopy.S3Key]
+		if !ok {
+			// Presign failure was logged; skip this item instead of failing entire request
 			continue
 		}
 		
 		// Defensive check: ensure URL is presigned (contains X-Amz-Signature)
 		if !strings.Contains(presignedURL, "X-Amz-Signature") && !strings.Contains(presignedURL, "Signature=") {
-			log.Printf("ERROR: Generated URL for branch media ID %d does not contain presigned signature: %s", mediaCopy.ID, presignedURL)
+			utils.Logger(ctx).Error("Generated URL does not contain a presigned signature", zap.Uint("branch_media_id", mediaCopy.ID))
 			continue
 		}
 		
@@ -92,6 +181,12 @@ func ConvertBranchMediaToPresignedURLs(ctx context.Context, mediaList []models.B
 		// FileURL is internal and not serialized
 		mediaCopy.FileURL = presignedURL // Internal storage
 		mediaCopy.URL = presignedURL     // JSON response field
+		if key := transcodedURLKey(mediaCopy.S3Key, mediaCopy.TranscodeStatus, mediaCopy.TranscodedS3Key); key != mediaCopy.S3Key {
+			if transcodedURL, ok := urls[key]; ok {
+				mediaCopy.OriginalURL = presignedURL
+				mediaCopy.URL = transcodedURL
+			}
+		}
 		
 		result = append(result, mediaCopy)
 	}
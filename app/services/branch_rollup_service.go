@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// ErrInvalidRollupLevel is returned for location rollups by anything but state or country
+var ErrInvalidRollupLevel = errors.New("invalid rollup level")
+
+// Location rollup levels
+const (
+	RollupLevelState   = "state"
+	RollupLevelCountry = "country"
+)
+
+// BranchRollupTotals are the figures of a branch, or of several added up: the branch stats
+// plus the number of branches and their infrastructure counted by type
+type BranchRollupTotals struct {
+	BranchStatsTotals
+	Branches       int64            `json:"branches"`
+	Infrastructure map[string]int64 `json:"infrastructure"`
+}
+
+func (t *BranchRollupTotals) add(o BranchRollupTotals) {
+	t.BranchStatsTotals.add(o.BranchStatsTotals)
+	t.Branches += o.Branches
+	if t.Infrastructure == nil {
+		t.Infrastructure = map[string]int64{}
+	}
+	for kind, count := range o.Infrastructure {
+		t.Infrastructure[kind] += count
+	}
+}
+
+// BranchRollupNode is a branch of the rollup tree. Totals cover the branch and every branch
+// below it; Own only the branch itself.
+type BranchRollupNode struct {
+	BranchID  uint                `json:"branch_id"`
+	Name      string              `json:"name"`
+	StateID   *uint               `json:"state_id,omitempty"`
+	CountryID *uint               `json:"country_id,omitempty"`
+	Own       BranchRollupTotals  `json:"own"`
+	Totals    BranchRollupTotals  `json:"totals"`
+	Children  []*BranchRollupNode `json:"children,omitempty"`
+}
+
+// LocationRollup is the rollup of the branches located in one state or country. Branches count
+// where they are themselves, not where their parent is.
+type LocationRollup struct {
+	Level     string             `json:"level"`
+	ID        *uint              `json:"id"` // nil for branches without a location
+	Name      string             `json:"name"`
+	CountryID *uint              `json:"country_id,omitempty"` // of states
+	Totals    BranchRollupTotals `json:"totals"`
+}
+
+// rollupBranch is a branch with its location, as loaded for a rollup
+type rollupBranch struct {
+	ID             uint
+	Name           string
+	ParentBranchID *uint
+	StateID        *uint
+	CountryID      *uint
+}
+
+// GetBranchRollup returns the rollup tree of a branch, or with branchID 0 one tree per top
+// branch of the scope (every root branch without a scope). Sandbox branches are left out
+// unless the requested branch is one.
+func GetBranchRollup(branchID uint, scope *BranchScope) ([]*BranchRollupNode, error) {
+	branches, err := rollupBranches(branchID, scope)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := rollupNodes(branches)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*BranchRollupNode, len(nodes))
+	for _, node := range nodes {
+		byID[node.BranchID] = node
+	}
+	var roots []*BranchRollupNode
+	for i, branch := range branches {
+		node := nodes[i]
+		if parent, ok := byID[derefUint(branch.ParentBranchID)]; ok && branch.ID != branchID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, root := range roots {
+		sumRollupTree(root)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Name < roots[j].Name })
+	return roots, nil
+}
+
+// sumRollupTree sets the totals of node and the nodes below it
+func sumRollupTree(node *BranchRollupNode) {
+	node.Totals = BranchRollupTotals{}
+	node.Totals.add(node.Own)
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+	for _, child := range node.Children {
+		sumRollupTree(child)
+		node.Totals.add(child.Totals)
+	}
+}
+
+// GetLocationRollup adds up the branches of GetBranchRollup by state or country, largest by
+// events first
+func GetLocationRollup(level string, branchID uint, scope *BranchScope) ([]LocationRollup, error) {
+	if level != RollupLevelState && level != RollupLevelCountry {
+		return nil, fmt.Errorf("%w: level must be %s or %s", ErrInvalidRollupLevel, RollupLevelState, RollupLevelCountry)
+	}
+	branches, err := rollupBranches(branchID, scope)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := rollupNodes(branches)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[uint]*LocationRollup{}
+	var order []uint
+	for i, branch := range branches {
+		location := branch.StateID
+		if level == RollupLevelCountry {
+			location = branch.CountryID
+		}
+		key := derefUint(location)
+		group, ok := groups[key]
+		if !ok {
+			group = &LocationRollup{Level: level, ID: location}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.Totals.add(nodes[i].Own)
+	}
+
+	ids := make([]uint, 0, len(order))
+	for _, key := range order {
+		if key != 0 {
+			ids = append(ids, key)
+		}
+	}
+	if len(ids) > 0 {
+		if level == RollupLevelState {
+			var states []models.State
+			if err := config.DB.Where("id IN ?", ids).Find(&states).Error; err != nil {
+				return nil, err
+			}
+			for _, state := range states {
+				countryID := state.CountryID
+				groups[state.ID].Name, groups[state.ID].CountryID = state.Name, &countryID
+			}
+		} else {
+			var countries []models.Country
+			if err := config.DB.Where("id IN ?", ids).Find(&countries).Error; err != nil {
+				return nil, err
+			}
+			for _, country := range countries {
+				groups[country.ID].Name = country.Name
+			}
+		}
+	}
+	if group, ok := groups[0]; ok {
+		group.Name = "Unassigned"
+	}
+
+	rollups := make([]LocationRollup, 0, len(order))
+	for _, key := range order {
+		rollups = append(rollups, *groups[key])
+	}
+	sort.SliceStable(rollups, func(i, j int) bool {
+		if rollups[i].Totals.Events != rollups[j].Totals.Events {
+			return rollups[i].Totals.Events > rollups[j].Totals.Events
+		}
+		return rollups[i].Name < rollups[j].Name
+	})
+	return rollups, nil
+}
+
+// rollupBranches loads the branch and its descendants, or with branchID 0 every branch of the
+// scope
+func rollupBranches(branchID uint, scope *BranchScope) ([]rollupBranch, error) {
+	if branchID != 0 {
+		if err := scope.CheckBranch(branchID); err != nil {
+			return nil, err
+		}
+		var tree []BranchStatsRow
+		if err := config.DB.Raw(branchTreeSQL, map[string]interface{}{"id": branchID}).Scan(&tree).Error; err != nil {
+			return nil, err
+		}
+		if len(tree) == 0 {
+			return nil, ErrBranchNotFound
+		}
+		tree, err := withoutSandboxBranches(branchID, tree)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]uint, len(tree))
+		for i, row := range tree {
+			ids[i] = row.BranchID
+		}
+		var branches []rollupBranch
+		err = config.DB.Model(&models.Branch{}).
+			Select("id, name, parent_branch_id, state_id, country_id").
+			Where("id IN ?", ids).
+			Order("id").
+			Scan(&branches).Error
+		return branches, err
+	}
+
+	var branches []rollupBranch
+	err := scope.Apply(config.DB.Model(&models.Branch{}), "id").
+		Select("id, name, parent_branch_id, state_id, country_id").
+		Where("NOT is_sandbox").
+		Order("id").
+		Scan(&branches).Error
+	return branches, err
+}
+
+// rollupNodes computes the own figures of each branch, in the order of branches
+func rollupNodes(branches []rollupBranch) ([]*BranchRollupNode, error) {
+	rows := make([]BranchStatsRow, len(branches))
+	ids := make([]uint, len(branches))
+	for i, branch := range branches {
+		rows[i] = BranchStatsRow{BranchID: branch.ID, Name: branch.Name, ParentBranchID: branch.ParentBranchID}
+		ids[i] = branch.ID
+	}
+	if len(rows) > 0 {
+		if err := fillBranchStats(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	var infrastructure []struct {
+		BranchID uint
+		Type     string
+		Count    int64
+	}
+	if len(ids) > 0 {
+		err := config.DB.Model(&models.BranchInfrastructure{}).
+			Select("branch_id, type, SUM(count)::bigint AS count").
+			Where("branch_id IN ?", ids).
+			Group("branch_id, type").
+			Scan(&infrastructure).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodes := make([]*BranchRollupNode, len(branches))
+	byID := make(map[uint]*BranchRollupNode, len(branches))
+	for i, branch := range branches {
+		nodes[i] = &BranchRollupNode{
+			BranchID:  branch.ID,
+			Name:      branch.Name,
+			StateID:   branch.StateID,
+			CountryID: branch.CountryID,
+			Own: BranchRollupTotals{
+				BranchStatsTotals: rows[i].BranchStatsTotals,
+				Branches:          1,
+				Infrastructure:    map[string]int64{},
+			},
+		}
+		byID[branch.ID] = nodes[i]
+	}
+	for _, infra := range infrastructure {
+		byID[infra.BranchID].Own.Infrastructure[infra.Type] += infra.Count
+	}
+	return nodes, nil
+}
+
+func derefUint(value *uint) uint {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// ErrOutOfBranchScope is returned for records of branches the user may not access
+var ErrOutOfBranchScope = errors.New("record belongs to a branch outside your access")
+
+// Record kinds whose branch BranchScope.Check resolves
+const (
+	ScopeBranch       = "branch"
+	ScopeEvent        = "event"
+	ScopeEventMedia   = "event_media"
+	ScopeBranchMedia  = "branch_media"
+	ScopeBranchInfra  = "branch_infrastructure"
+	ScopeBranchMember = "branch_member"
+	ScopeDonation     = "donation"
+	ScopeVolunteer    = "volunteer"
+	ScopeSpecialGuest = "special_guest"
+	ScopePromotion    = "promotion_material_details"
+)
+
+// branchOfRecordSQL finds the branch of a record by ID, soft-deleted records included so
+// restores are scoped too
+var branchOfRecordSQL = map[string]string{
+	ScopeBranch:       "SELECT id FROM branches WHERE id = ?",
+	ScopeEvent:        "SELECT branch_id FROM event_details WHERE id = ?",
+	ScopeEventMedia:   "SELECT e.branch_id FROM event_media m JOIN event_details e ON e.id = m.event_id WHERE m.id = ?",
+	ScopeBranchMedia:  "SELECT branch_id FROM branch_media WHERE id = ?",
+	ScopeBranchInfra:  "SELECT branch_id FROM branch_infrastructure WHERE id = ?",
+	ScopeBranchMember: "SELECT branch_id FROM branch_member WHERE id = ?",
+	ScopeDonation:     "SELECT branch_id FROM donations WHERE id = ?",
+	ScopeVolunteer:    "SELECT branch_id FROM volunteers WHERE id = ?",
+	ScopeSpecialGuest: "SELECT e.branch_id FROM special_guests g JOIN event_details e ON e.id = g.event_id WHERE g.id = ?",
+	ScopePromotion:    "SELECT e.branch_id FROM promotion_material_details p JOIN event_details e ON e.id = p.event_id WHERE p.id = ?",
+}
+
+// BranchScope is the set of branches whose data a user may see and modify: their own branch
+// and all of its child branches, with their events and media. The nil scope (admins) is
+// unrestricted; non-admin users without a branch get an empty scope and see nothing.
+type BranchScope struct {
+	BranchIDs []uint
+}
+
+// BranchScopeFor returns the scope of a user with the given role and branch
+func BranchScopeFor(roleID uint, branchID *uint) (*BranchScope, error) {
+	if roleID == models.RoleAdmin {
+		return nil, nil
+	}
+	scope := &BranchScope{BranchIDs: []uint{}}
+	if branchID == nil {
+		return scope, nil
+	}
+	var rows []BranchStatsRow
+	if err := config.DB.Raw(branchTreeSQL, map[string]interface{}{"id": *branchID}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		scope.BranchIDs = append(scope.BranchIDs, row.BranchID)
+	}
+	return scope, nil
+}
+
+// Allows reports whether the scope covers the branch
+func (s *BranchScope) Allows(branchID uint) bool {
+	if s == nil {
+		return true
+	}
+	for _, id := range s.BranchIDs {
+		if id == branchID {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply limits a query to the scope's branches through column (e.g. "branch_id" or
+// "e.branch_id"). Rows without a branch are only visible to unrestricted scopes.
+func (s *BranchScope) Apply(query *gorm.DB, column string) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	if len(s.BranchIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(column+" IN ?", s.BranchIDs)
+}
+
+// ApplyToEvents limits a query to rows of events (eventColumn, e.g. "event_id") of the
+// scope's branches
+func (s *BranchScope) ApplyToEvents(query *gorm.DB, eventColumn string) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	events := s.Apply(config.DB.Model(&models.EventDetails{}).Select("id"), "branch_id")
+	return query.Where(eventColumn+" IN (?)", events)
+}
+
+// CheckBranch returns ErrOutOfBranchScope unless the scope covers the branch
+func (s *BranchScope) CheckBranch(branchID uint) error {
+	if !s.Allows(branchID) {
+		return ErrOutOfBranchScope
+	}
+	return nil
+}
+
+// Check returns ErrOutOfBranchScope when the record of the given kind (ScopeEvent, ...)
+// belongs to a branch outside the scope. Missing records pass, so callers report them as
+// not found as usual.
+func (s *BranchScope) Check(kind string, id uint) error {
+	if s == nil {
+		return nil
+	}
+	query, ok := branchOfRecordSQL[kind]
+	if !ok {
+		return fmt.Errorf("unknown record kind %q", kind)
+	}
+	var branchIDs []*uint
+	if err := config.DB.Raw(query, id).Scan(&branchIDs).Error; err != nil {
+		return err
+	}
+	if len(branchIDs) == 0 {
+		return nil
+	}
+	if branchIDs[0] == nil || !s.Allows(*branchIDs[0]) {
+		return ErrOutOfBranchScope
+	}
+	return nil
+}
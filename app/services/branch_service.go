@@ -1,12 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
 )
 
 // CreateBranch inserts a new branch record
@@ -89,95 +93,170 @@ func CreateBranch(branch *models.Branch) error {
 	if !branch.Status {
 		branch.Status = true
 	}
+	branch.LocationSource = ""
+	if branch.Latitude != nil {
+		branch.LocationSource = models.LocationSourceManual
+	}
 
 	if err := config.DB.Create(branch).Error; err != nil {
 		return err
 	}
+	queueBranchGeocode(branch.ID, "")
+	publishBranchUpdated(branch.ID)
 	return nil
 }
 
-// GetAllBranches fetches all parent branches only (branches with parent_branch_id IS NULL)
+// BranchListSchema is what GET /branches may be sorted and filtered by (see listquery). Without
+// a sort the newest branches come first.
+var BranchListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":               {Column: "id", Type: listquery.Int, Sortable: true},
+	"name":             {Column: "name", Type: listquery.String, Sortable: true},
+	"email":            {Column: "email", Type: listquery.String},
+	"coordinator_name": {Column: "coordinator_name", Type: listquery.String, Sortable: true},
+	"branch_code":      {Column: "branch_code", Type: listquery.String, Sortable: true},
+	"status":           {Column: "status", Type: listquery.Bool},
+	"ncr":              {Column: "ncr", Type: listquery.Bool},
+	"country_id":       {Column: "country_id", Type: listquery.Int},
+	"state_id":         {Column: "state_id", Type: listquery.Int},
+	"district_id":      {Column: "district_id", Type: listquery.Int},
+	"city_id":          {Column: "city_id", Type: listquery.Int},
+	"region_id":        {Column: "region_id", Type: listquery.Int},
+	"established_on":   {Column: "established_on", Type: listquery.Time, Sortable: true},
+	"created_on":       {Column: "created_on", Type: listquery.Time, Sortable: true},
+	"updated_on":       {Column: "updated_on", Type: listquery.Time, Sortable: true},
+	"event_count":      {Column: "event_count", Type: listquery.Int, Sortable: true},
+	"member_count":     {Column: "member_count", Type: listquery.Int, Sortable: true},
+	"media_count":      {Column: "media_count", Type: listquery.Int, Sortable: true},
+	"last_activity_on": {Column: "last_activity_on", Type: listquery.Time, Sortable: true},
+}, listquery.Sort{Field: "id", Desc: true})
+
+// branchListColumns are the columns of branch lists
+var branchListColumns = []string{"id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
+	"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
+	"address", "pincode", "post_office", "police_station", "open_days",
+	"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
+	"created_on", "updated_on", "created_by", "updated_by", "deleted_at", "version",
+	"media_count", "member_count", "event_count", "last_activity_on",
+	"cover_media_id", "coordinator_photo_media_id", "latitude", "longitude", "location_source", "public_id"}
+
+// BranchFieldSet is what the fields and include parameters of branch and child branch lists
+// may ask for (see listquery.FieldSet), e.g. ?fields=name,branch_code&include=city for a picker.
+// Fields are the list columns; cover_image and coordinator_photo are presigned by the handler.
+var BranchFieldSet = listquery.NewFieldSet([]string{"id"}, branchListFields(), map[string]listquery.Include{
+	"country":           {Preload: "Country", Columns: []string{"country_id"}},
+	"state":             {Preload: "State", Columns: []string{"state_id"}},
+	"district":          {Preload: "District", Columns: []string{"district_id"}},
+	"city":              {Preload: "City", Columns: []string{"city_id"}},
+	"parent":            {Preload: "Parent", Columns: []string{"parent_branch_id"}},
+	"children":          {Preload: "Children"},
+	"infrastructure":    {Preload: "Infrastructures"},
+	"branch_members":    {Preload: "Members"},
+	"cover_image":       {Columns: []string{"cover_media_id"}},
+	"coordinator_photo": {Columns: []string{"coordinator_photo_media_id"}},
+})
+
+func branchListFields() map[string]string {
+	fields := make(map[string]string, len(branchListColumns))
+	for _, column := range branchListColumns {
+		fields[column] = column
+	}
+	return fields
+}
+
+// GetAllBranches fetches all parent branches only (branches with parent_branch_id IS NULL),
+// sorted and filtered by a query parsed with BranchListSchema
 // Child branches are stored in the same table but should only be shown when expanding parent branches
-func GetAllBranches() ([]models.Branch, error) {
+// Soft-deleted branches are excluded unless includeDeleted is set
+// Users limited to a branch scope see the roots of their scope instead (see scopeBranchRoots)
+// A projection (see BranchFieldSet) limits the columns and associations loaded; nil loads them all.
+func GetAllBranches(query *listquery.Query, projection *listquery.Projection, includeDeleted bool, scope *BranchScope) ([]models.Branch, error) {
 	var branches []models.Branch
-	if err := config.DB.
-		Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
-			"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
-			"address", "pincode", "post_office", "police_station", "open_days",
-			"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
-			"created_on", "updated_on", "created_by", "updated_by").
-		Where("parent_branch_id IS NULL"). // Only return parent branches
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Preload("Children"). // Preload child branches for expand functionality
-		Order("id DESC"). // Order by ID descending to show newest first
+	if err := scopeBranchRoots(query.Apply(withDeleted(config.DB, includeDeleted)), scope).
+		Scopes(func(db *gorm.DB) *gorm.DB {
+			return projection.Select(db, func(db *gorm.DB) *gorm.DB {
+				return db.Select(branchListColumns).
+					Preload("Country").
+					Preload("State").
+					Preload("District").
+					Preload("City").
+					Preload("Children") // Preload child branches for expand functionality
+			})
+		}).
 		Find(&branches).Error; err != nil {
 		return nil, err
 	}
 	return branches, nil
 }
 
-// GetBranch fetches a branch by ID
+// GetBranch fetches a branch by ID, through the branch cache
 func GetBranch(branchID uint) (*models.Branch, error) {
-	var branch models.Branch
-	if err := config.DB.
-		Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
-			"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
-			"address", "pincode", "post_office", "police_station", "open_days",
-			"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
-			"created_on", "updated_on", "created_by", "updated_by").
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Preload("Parent").
-		Preload("Children").
-		Preload("Infrastructures").
-		Preload("Members").
-		First(&branch, branchID).Error; err != nil {
-		return nil, errors.New("branch not found")
-	}
-	return &branch, nil
+	return cached(context.Background(), CacheGroupBranches, "branch:" + strconv.FormatUint(uint64(branchID), 10), func() (*models.Branch, error) {
+		var branch models.Branch
+		if err := config.DB.
+			Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
+				"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
+				"address", "pincode", "post_office", "police_station", "open_days",
+				"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
+				"created_on", "updated_on", "created_by", "updated_by", "version",
+				"media_count", "member_count", "event_count", "last_activity_on",
+				"cover_media_id", "coordinator_photo_media_id", "latitude", "longitude", "location_source", "public_id").
+			Preload("Country").
+			Preload("State").
+			Preload("District").
+			Preload("City").
+			Preload("Parent").
+			Preload("Children").
+			Preload("Infrastructures").
+			Preload("Members").
+			First(&branch, branchID).Error; err != nil {
+			return nil, errors.New("branch not found")
+		}
+		return &branch, nil
+	})
 }
 
 // GetChildBranches fetches all child branches of a parent branch
 func GetChildBranches(parentBranchID uint) ([]models.Branch, error) {
-	var branches []models.Branch
-	if err := config.DB.
-		Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
-			"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
-			"address", "pincode", "post_office", "police_station", "open_days",
-			"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
-			"created_on", "updated_on", "created_by", "updated_by").
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Where("parent_branch_id = ?", parentBranchID).
-		Order("id DESC").
-		Find(&branches).Error; err != nil {
-		return nil, err
-	}
-	return branches, nil
+	return cached(context.Background(), CacheGroupBranches, "children:" + strconv.FormatUint(uint64(parentBranchID), 10), func() ([]models.Branch, error) {
+		var branches []models.Branch
+		if err := config.DB.
+			Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
+				"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
+				"address", "pincode", "post_office", "police_station", "open_days",
+				"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
+				"created_on", "updated_on", "created_by", "updated_by", "version",
+				"media_count", "member_count", "event_count", "last_activity_on",
+				"cover_media_id", "coordinator_photo_media_id", "latitude", "longitude", "location_source", "public_id").
+			Preload("Country").
+			Preload("State").
+			Preload("District").
+			Preload("City").
+			Where("parent_branch_id = ?", parentBranchID).
+			Order("id DESC").
+			Find(&branches).Error; err != nil {
+			return nil, err
+		}
+		return branches, nil
+	})
 }
 
 // GetBranchSearch fetches parent branches by name and/or coordinator name
 // Only returns parent branches (parent_branch_id IS NULL) to match GetAllBranches behavior
-func GetBranchSearch(branchName, coordinator string) ([]models.Branch, error) {
+func GetBranchSearch(branchName, coordinator string, scope *BranchScope) ([]models.Branch, error) {
 	var branches []models.Branch
 	db := config.DB.
 		Select("id", "name", "email", "coordinator_name", "contact_number", "established_on", "aashram_area",
 			"country_id", "state_id", "district_id", "city_id", "parent_branch_id",
 			"address", "pincode", "post_office", "police_station", "open_days",
 			"daily_start_time", "daily_end_time", "status", "ncr", "region_id", "branch_code",
-			"created_on", "updated_on", "created_by", "updated_by").
-		Where("parent_branch_id IS NULL"). // Only search parent branches
+			"created_on", "updated_on", "created_by", "updated_by", "version",
+			"media_count", "member_count", "event_count", "last_activity_on",
+			"cover_media_id", "coordinator_photo_media_id", "latitude", "longitude", "location_source", "public_id").
 		Preload("Country").
 		Preload("State").
 		Preload("District").
 		Preload("City")
+	db = scopeBranchRoots(db, scope) // Only search parent branches
 
 	// Apply filters dynamically - use OR logic if both are provided
 	if branchName != "" && coordinator != "" {
@@ -202,8 +281,9 @@ func GetBranchSearch(branchName, coordinator string) ([]models.Branch, error) {
 	return branches, nil
 }
 
-// UpdateBranch updates branch fields
-func UpdateBranch(branchID uint, updatedData map[string]interface{}) error {
+// UpdateBranch updates branch fields. version is the branch version the client last read; when
+// set the update fails with a VersionConflictError if the branch has been updated since.
+func UpdateBranch(branchID uint, updatedData map[string]interface{}, version *int) error {
 	var branch models.Branch
 	if err := config.DB.First(&branch, branchID).Error; err != nil {
 		return errors.New("branch not found")
@@ -461,14 +541,28 @@ func UpdateBranch(branchID uint, updatedData map[string]interface{}) error {
 
 	now := time.Now()
 	updatedData["updated_on"] = &now
+	coordinateUpdates(updatedData)
+	previousAddress, geocode := geocodeBaseline(branchID, updatedData)
 
-	if err := config.DB.Model(&branch).Updates(updatedData).Error; err != nil {
+	updated, err := updateVersioned(config.DB, &branch, version, updatedData)
+	if err != nil {
 		return err
 	}
+	if !updated {
+		current, err := GetBranch(branchID)
+		if err != nil {
+			return err
+		}
+		return &VersionConflictError{Version: current.Version, Current: current}
+	}
+	if geocode {
+		queueBranchGeocode(branchID, previousAddress)
+	}
+	publishBranchUpdated(branchID)
 	return nil
 }
 
-// DeleteBranch deletes a branch by ID
+// DeleteBranch soft-deletes a branch by ID
 func DeleteBranch(branchID uint) error {
 	if err := config.DB.Delete(&models.Branch{}, branchID).Error; err != nil {
 		return err
@@ -476,6 +570,11 @@ func DeleteBranch(branchID uint) error {
 	return nil
 }
 
+// RestoreBranch restores a soft-deleted branch
+func RestoreBranch(branchID uint) error {
+	return restoreSoftDeleted(config.DB, &models.Branch{}, branchID)
+}
+
 // *************************************** Branch Infrastructure ****************************************************** //
 
 // CreateBranchInfrastructure inserts a new record
@@ -612,3 +711,12 @@ func DeleteBranchMember(id uint) error {
 	}
 	return nil
 }
+
+// scopeBranchRoots limits a listing of parent branches to the roots of the scope: for users
+// limited to a child branch, their own branch stands in for the parent branches
+func scopeBranchRoots(query *gorm.DB, scope *BranchScope) *gorm.DB {
+	if scope == nil {
+		return query.Where("parent_branch_id IS NULL")
+	}
+	return scope.Apply(query, "id").Where("parent_branch_id IS NULL OR parent_branch_id NOT IN ?", scope.BranchIDs)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// ErrBranchNotFound is returned for unknown or soft-deleted branches
+var ErrBranchNotFound = errors.New("branch not found")
+
+// BranchStatsTotals are the aggregated figures of one branch (or a roll-up of several)
+type BranchStatsTotals struct {
+	Events           int64   `json:"events"`
+	EventsComplete   int64   `json:"events_complete"`
+	BeneficiaryMen   int64   `json:"beneficiary_men"`
+	BeneficiaryWomen int64   `json:"beneficiary_women"`
+	BeneficiaryChild int64   `json:"beneficiary_child"`
+	Beneficiaries    int64   `json:"beneficiaries"`
+	InitiationMen    int64   `json:"initiation_men"`
+	InitiationWomen  int64   `json:"initiation_women"`
+	InitiationChild  int64   `json:"initiation_child"`
+	Initiations      int64   `json:"initiations"`
+	Donations        int64   `json:"donations"`
+	DonationAmount   float64 `json:"donation_amount"`
+	Members          int64   `json:"members"`
+}
+
+// BranchStatsRow is one branch's contribution to a roll-up
+type BranchStatsRow struct {
+	BranchID       uint   `json:"branch_id"`
+	Name           string `json:"name"`
+	ParentBranchID *uint  `json:"parent_branch_id,omitempty"`
+	BranchStatsTotals
+}
+
+// BranchStats is the response of GET /api/branches/:id/stats. With include_children the
+// totals cover the branch and all of its descendants and Branches lists each one's figures.
+type BranchStats struct {
+	BranchID        uint `json:"branch_id"`
+	IncludeChildren bool `json:"include_children"`
+	// SnapshotDate is the date of the stored figures returned for ?as_of, see GetBranchStatsAsOf
+	SnapshotDate string            `json:"snapshot_date,omitempty"`
+	Totals       BranchStatsTotals `json:"totals"`
+	Branches     []BranchStatsRow  `json:"branches,omitempty"`
+}
+
+// branchTreeSQL selects the branch and its descendants at any depth. UNION (not UNION ALL)
+// stops the recursion if a parent_branch_id cycle ever slips in.
+const branchTreeSQL = `
+WITH RECURSIVE tree AS (
+    SELECT id, name, parent_branch_id FROM branches WHERE id = @id AND deleted_at IS NULL
+    UNION
+    SELECT b.id, b.name, b.parent_branch_id FROM branches b JOIN tree t ON b.parent_branch_id = t.id
+    WHERE b.deleted_at IS NULL
+)
+SELECT id AS branch_id, name, parent_branch_id FROM tree ORDER BY id`
+
+// GetBranchStats aggregates events (with beneficiaries and initiations), donations and members
+// for a branch, optionally rolled up over all of its child branches. Soft-deleted rows are excluded.
+// Events and donations of archived financial years are counted from the year's rollups.
+func GetBranchStats(branchID uint, includeChildren bool) (*BranchStats, error) {
+	query := "SELECT id AS branch_id, name, parent_branch_id FROM branches WHERE id = @id AND deleted_at IS NULL"
+	if includeChildren {
+		query = branchTreeSQL
+	}
+	var rows []BranchStatsRow
+	err := config.DB.Raw(query, map[string]interface{}{"id": branchID}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrBranchNotFound
+	}
+	if includeChildren {
+		if rows, err = withoutSandboxBranches(branchID, rows); err != nil {
+			return nil, err
+		}
+	}
+	if err := fillBranchStats(rows); err != nil {
+		return nil, err
+	}
+	return newBranchStats(branchID, includeChildren, rows), nil
+}
+
+// fillBranchStats sets the figures of each branch's own row
+func fillBranchStats(rows []BranchStatsRow) error {
+	ids := make([]uint, len(rows))
+	byID := make(map[uint]*BranchStatsRow, len(rows))
+	for i := range rows {
+		ids[i] = rows[i].BranchID
+		byID[rows[i].BranchID] = &rows[i]
+	}
+
+	var events []struct {
+		BranchID         uint
+		Events           int64
+		EventsComplete   int64
+		BeneficiaryMen   int64
+		BeneficiaryWomen int64
+		BeneficiaryChild int64
+		InitiationMen    int64
+		InitiationWomen  int64
+		InitiationChild  int64
+	}
+	err := config.DB.Model(&models.EventDetails{}).
+		Select(`branch_id, COUNT(*) AS events,
+			COUNT(*) FILTER (WHERE status = ?) AS events_complete,
+			COALESCE(SUM(beneficiary_men), 0) AS beneficiary_men,
+			COALESCE(SUM(beneficiary_women), 0) AS beneficiary_women,
+			COALESCE(SUM(beneficiary_child), 0) AS beneficiary_child,
+			COALESCE(SUM(initiation_men), 0) AS initiation_men,
+			COALESCE(SUM(initiation_women), 0) AS initiation_women,
+			COALESCE(SUM(initiation_child), 0) AS initiation_child`, "complete").
+		Where("branch_id IN ? AND NOT archived", ids).
+		Group("branch_id").
+		Scan(&events).Error
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		row := byID[e.BranchID]
+		row.Events, row.EventsComplete = e.Events, e.EventsComplete
+		row.BeneficiaryMen, row.BeneficiaryWomen, row.BeneficiaryChild = e.BeneficiaryMen, e.BeneficiaryWomen, e.BeneficiaryChild
+		row.InitiationMen, row.InitiationWomen, row.InitiationChild = e.InitiationMen, e.InitiationWomen, e.InitiationChild
+	}
+
+	var archived []BranchStatsRow
+	err = archivedYearRollups(ids).
+		Select(`r.branch_id, SUM(r.events)::bigint AS events, SUM(r.events_complete)::bigint AS events_complete,
+			SUM(r.beneficiary_men)::bigint AS beneficiary_men, SUM(r.beneficiary_women)::bigint AS beneficiary_women,
+			SUM(r.beneficiary_child)::bigint AS beneficiary_child, SUM(r.initiation_men)::bigint AS initiation_men,
+			SUM(r.initiation_women)::bigint AS initiation_women, SUM(r.initiation_child)::bigint AS initiation_child`).
+		Group("r.branch_id").
+		Scan(&archived).Error
+	if err != nil {
+		return err
+	}
+	for _, a := range archived {
+		byID[a.BranchID].BranchStatsTotals.add(a.BranchStatsTotals)
+	}
+
+	var donations []struct {
+		BranchID uint
+		Count    int64
+		Amount   float64
+	}
+	err = config.DB.Model(&models.Donation{}).
+		Select("branch_id, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("branch_id IN ? AND NOT archived", ids).
+		Group("branch_id").
+		Scan(&donations).Error
+	if err != nil {
+		return err
+	}
+	var archivedDonations []struct {
+		BranchID uint
+		Count    int64
+		Amount   float64
+	}
+	err = archivedYearBreakdowns(models.ArchivedBreakdownDonationType, ids).
+		Select("r.branch_id, SUM(r.count)::bigint AS count, SUM(r.amount)::float8 AS amount").
+		Group("r.branch_id").
+		Scan(&archivedDonations).Error
+	if err != nil {
+		return err
+	}
+	for _, d := range append(donations, archivedDonations...) {
+		byID[d.BranchID].Donations += d.Count
+		byID[d.BranchID].DonationAmount += d.Amount
+	}
+
+	var members []struct {
+		BranchID uint
+		Count    int64
+	}
+	err = config.DB.Model(&models.BranchMember{}).
+		Select("branch_id, COUNT(*) AS count").
+		Where("branch_id IN ?", ids).
+		Group("branch_id").
+		Scan(&members).Error
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		byID[m.BranchID].Members = m.Count
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.Beneficiaries = row.BeneficiaryMen + row.BeneficiaryWomen + row.BeneficiaryChild
+		row.Initiations = row.InitiationMen + row.InitiationWomen + row.InitiationChild
+	}
+	return nil
+}
+
+// newBranchStats totals the rows of a branch (and its descendants with includeChildren)
+func newBranchStats(branchID uint, includeChildren bool, rows []BranchStatsRow) *BranchStats {
+	stats := &BranchStats{BranchID: branchID, IncludeChildren: includeChildren}
+	for _, row := range rows {
+		stats.Totals.add(row.BranchStatsTotals)
+	}
+	if includeChildren {
+		stats.Branches = rows
+	}
+	return stats
+}
+
+func (t *BranchStatsTotals) add(o BranchStatsTotals) {
+	t.Events += o.Events
+	t.EventsComplete += o.EventsComplete
+	t.BeneficiaryMen += o.BeneficiaryMen
+	t.BeneficiaryWomen += o.BeneficiaryWomen
+	t.BeneficiaryChild += o.BeneficiaryChild
+	t.Beneficiaries += o.Beneficiaries
+	t.InitiationMen += o.InitiationMen
+	t.InitiationWomen += o.InitiationWomen
+	t.InitiationChild += o.InitiationChild
+	t.Initiations += o.Initiations
+	t.Donations += o.Donations
+	t.DonationAmount += o.DonationAmount
+	t.Members += o.Members
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// defaultBusinessMetricsInterval is how often the business gauges are refreshed
+const defaultBusinessMetricsInterval = time.Minute
+
+// StartBusinessMetrics refreshes the business gauges on /metrics (event submissions, pending
+// approvals, background jobs) every BUSINESS_METRICS_INTERVAL (a Go duration, default 1m; "0"
+// turns the refresh off).
+func StartBusinessMetrics() {
+	logger := utils.BaseLogger()
+	interval := config.Jobs.BusinessMetricsInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		refresh := func() {
+			if err := RefreshBusinessMetrics(); err != nil {
+				metrics.BusinessMetricsRefreshErrors.Inc()
+				logger.Warn("Failed to refresh business metrics", zap.Error(err))
+			}
+		}
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refresh()
+		}
+	}()
+}
+
+// RefreshBusinessMetrics sets the business gauges from the database. Sandbox branches are
+// left out of the event figures.
+func RefreshBusinessMetrics() error {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var submissions struct {
+		Today int64
+		Day   int64
+		Last  *time.Time
+	}
+	query := config.DB.Table("event_status_history h").
+		Select(`COUNT(*) FILTER (WHERE h.created_on >= ?) AS today,
+			COUNT(*) FILTER (WHERE h.created_on >= ?) AS day,
+			MAX(h.created_on) AS last`, midnight, now.Add(-24*time.Hour)).
+		Joins("JOIN event_details e ON e.id = h.event_id").
+		Where("h.to_status = ?", models.EventStatusSubmitted)
+	if err := excludeSandbox(query, "e.branch_id").Scan(&submissions).Error; err != nil {
+		return err
+	}
+	metrics.EventsSubmittedToday.Set(float64(submissions.Today))
+	metrics.EventsSubmitted24h.Set(float64(submissions.Day))
+	if submissions.Last != nil {
+		metrics.LastEventSubmitted.Set(float64(submissions.Last.Unix()))
+	}
+
+	pendingStatuses := []string{models.EventStatusSubmitted, models.EventStatusUnderReview}
+	var pending []struct {
+		Status string
+		Count  int64
+	}
+	query = config.DB.Model(&models.EventDetails{}).
+		Select("approval_status AS status, COUNT(*) AS count").
+		Where("approval_status IN ?", pendingStatuses).
+		Group("approval_status")
+	if err := excludeSandbox(query, "branch_id").Scan(&pending).Error; err != nil {
+		return err
+	}
+	for _, status := range pendingStatuses {
+		metrics.PendingApprovals.WithLabelValues(status).Set(0)
+	}
+	for _, row := range pending {
+		metrics.PendingApprovals.WithLabelValues(row.Status).Set(float64(row.Count))
+	}
+
+	var jobs []struct {
+		Type   string
+		Status string
+		Count  int64
+	}
+	if err := config.DB.Model(&models.Job{}).
+		Select("type, status, COUNT(*) AS count").
+		Where("status IN ?", []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Group("type, status").
+		Scan(&jobs).Error; err != nil {
+		return err
+	}
+	// Types without jobs left drop back to 0 rather than keeping their last value
+	metrics.Jobs.Reset()
+	for _, row := range jobs {
+		metrics.Jobs.WithLabelValues(row.Type, row.Status).Set(float64(row.Count))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+)
+
+// Hot read-mostly data (master data, branch lookups, dashboard aggregates) is cached in Redis
+// and shared by every instance. The cache is optional: without Redis, or while it is down,
+// every read goes to the database.
+//
+// Entries belong to a group, and the group's generation is part of every key. Writes through
+// GORM to a table a group is built from bump its generation (CacheInvalidationPlugin), so the
+// next read misses and the old entries expire on their own. Writes made with raw SQL call
+// InvalidateCache themselves; anything else (triggers, other tools) is bounded by the TTL.
+
+// Cache groups
+const (
+	CacheGroupMaster    = "master"    // event types and categories, locations, other dropdowns
+	CacheGroupBranches  = "branches"  // branch lookups with their associations
+	CacheGroupDashboard = "dashboard" // dashboard aggregates
+)
+
+// cacheGroupTTLs bound how stale an entry can get when a change bypasses invalidation
+var cacheGroupTTLs = map[string]time.Duration{
+	CacheGroupMaster:    time.Hour,
+	CacheGroupBranches:  10 * time.Minute,
+	CacheGroupDashboard: 5 * time.Minute,
+}
+
+// cacheGroupsByTable lists the groups invalidated by writes to each table. Branches carry
+// counters maintained by triggers on their media, members and events.
+var cacheGroupsByTable = map[string][]string{
+	"event_types":              {CacheGroupMaster},
+	"event_categories":         {CacheGroupMaster},
+	"event_sub_categories":     {CacheGroupMaster},
+	"promotion_material_type":  {CacheGroupMaster},
+	"languages":                {CacheGroupMaster},
+	"seva_types":               {CacheGroupMaster},
+	"themes":                   {CacheGroupMaster},
+	"roles":                    {CacheGroupMaster},
+	"countries":                {CacheGroupMaster, CacheGroupBranches},
+	"states":                   {CacheGroupMaster, CacheGroupBranches},
+	"districts":                {CacheGroupMaster, CacheGroupBranches},
+	"cities":                   {CacheGroupMaster, CacheGroupBranches},
+	"branches":                 {CacheGroupBranches, CacheGroupDashboard},
+	"branch_member":            {CacheGroupMaster, CacheGroupBranches},
+	"branch_infrastructure":    {CacheGroupBranches},
+	"branch_media":             {CacheGroupBranches, CacheGroupDashboard},
+	"event_details":            {CacheGroupBranches, CacheGroupDashboard},
+	"event_media":              {CacheGroupDashboard},
+	"donations":                {CacheGroupDashboard},
+	"volunteers":               {CacheGroupDashboard},
+	"archived_year_rollups":    {CacheGroupDashboard},
+	"archived_year_breakdowns": {CacheGroupDashboard},
+}
+
+// cacheInvalidationDelay is when writes made in a transaction invalidate a second time: the
+// first invalidation happens before the commit, and a read in between may cache the old rows
+const cacheInvalidationDelay = 2 * time.Second
+
+// cacheTimeout bounds each cache call, so a slow Redis costs little more than a miss
+const cacheTimeout = 200 * time.Millisecond
+
+// ErrCacheMiss is returned by Cache.Get for absent keys
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache stores encoded values shared by every instance
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr increments a counter and returns its new value
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+var sharedCache Cache
+
+// SetCache sets the cache used by this package. It is called at startup once Redis is up (see
+// dependencies.startupDependencies) or by tests; nil turns caching off.
+func SetCache(cache Cache) {
+	sharedCache = cache
+}
+
+// RedisCache is a Cache backed by Redis
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache returns a Cache storing its entries in client
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+	return value, err
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *RedisCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+// cached returns the value of key in group, calling load and caching its result on a miss.
+// Errors of the cache are logged and fall back to load.
+func cached[T any](ctx context.Context, group, key string, load func() (T, error)) (T, error) {
+	cache := sharedCache
+	if cache == nil {
+		return load()
+	}
+
+	getCtx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	generation, err := cacheGeneration(getCtx, cache, group)
+	if err != nil {
+		utils.Logger(ctx).Debug("Cache unavailable", zap.String("group", group), zap.Error(err))
+		return load()
+	}
+	cacheKey := "cache:" + group + ":" + generation + ":" + key
+	if data, err := cache.Get(getCtx, cacheKey); err == nil {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		setCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+		defer cancel()
+		if err := cache.Set(setCtx, cacheKey, data, cacheGroupTTLs[group]); err != nil {
+			utils.Logger(ctx).Debug("Failed to cache", zap.String("key", cacheKey), zap.Error(err))
+		}
+	}
+	return value, nil
+}
+
+func cacheGeneration(ctx context.Context, cache Cache, group string) (string, error) {
+	data, err := cache.Get(ctx, "cache:generation:"+group)
+	if errors.Is(err, ErrCacheMiss) {
+		return "0", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// InvalidateCache drops every cached entry of the groups
+func InvalidateCache(ctx context.Context, groups ...string) {
+	cache := sharedCache
+	if cache == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+	defer cancel()
+	for _, group := range groups {
+		if _, err := cache.Incr(ctx, "cache:generation:"+group); err != nil {
+			utils.Logger(ctx).Warn("Failed to invalidate cache", zap.String("group", group), zap.Error(err))
+		}
+	}
+}
+
+// CacheInvalidationPlugin invalidates the cache groups built from a table when GORM writes to
+// it. Register with config.DB.Use(services.CacheInvalidationPlugin{}).
+type CacheInvalidationPlugin struct{}
+
+func (CacheInvalidationPlugin) Name() string {
+	return "cache_invalidation"
+}
+
+func (CacheInvalidationPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Register("cache_invalidation:create", invalidateCacheOnWrite),
+		cb.Update().After("gorm:update").Register("cache_invalidation:update", invalidateCacheOnWrite),
+		cb.Delete().After("gorm:delete").Register("cache_invalidation:delete", invalidateCacheOnWrite),
+	)
+}
+
+func invalidateCacheOnWrite(tx *gorm.DB) {
+	if sharedCache == nil || tx.Error != nil || tx.Statement.RowsAffected == 0 {
+		return
+	}
+	groups := cacheGroupsByTable[tx.Statement.Table]
+	if len(groups) == 0 {
+		return
+	}
+	ctx := tx.Statement.Context
+	InvalidateCache(ctx, groups...)
+	if _, inTransaction := tx.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+		time.AfterFunc(cacheInvalidationDelay, func() {
+			InvalidateCache(context.WithoutCancel(ctx), groups...)
+		})
+	}
+}
+
+// cacheKeyIDs joins ids into a cache key part
+func cacheKeyIDs(ids []uint) string {
+	key := make([]byte, 0, len(ids)*4)
+	for i, id := range ids {
+		if i > 0 {
+			key = append(key, ',')
+		}
+		key = strconv.AppendUint(key, uint64(id), 10)
+	}
+	return string(key)
+}
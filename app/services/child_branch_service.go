@@ -1,11 +1,17 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CreateChildBranch creates a new child branch (now using Branch model with parent_branch_id)
@@ -22,71 +28,107 @@ func CreateChildBranch(childBranch *models.Branch) error {
 	if !childBranch.Status {
 		childBranch.Status = true
 	}
+	childBranch.LocationSource = ""
+	if childBranch.Latitude != nil {
+		childBranch.LocationSource = models.LocationSourceManual
+	}
 	
 	if err := config.DB.Create(childBranch).Error; err != nil {
 		return err
 	}
+	queueBranchGeocode(childBranch.ID, "")
+	publishBranchUpdated(childBranch.ID)
 	return nil
 }
 
-// GetAllChildBranches fetches all child branches (branches with parent_branch_id set)
-func GetAllChildBranches() ([]models.Branch, error) {
-	var childBranches []models.Branch
-	if err := config.DB.
-		Where("parent_branch_id IS NOT NULL").
-		Preload("Parent").
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Preload("Infrastructures").
-		Preload("Members").
-		Order("id DESC").
-		Find(&childBranches).Error; err != nil {
-		return nil, err
+// GetAllChildBranches fetches all child branches (branches with parent_branch_id set), sorted and
+// filtered by a query parsed with BranchListSchema
+// Soft-deleted child branches are excluded unless includeDeleted is set.
+// Members are not preloaded; the list shows the denormalized member_count instead.
+// Only child branches in the scope are returned. Lists are served through the branch cache.
+// A projection (see BranchFieldSet) limits the columns and associations loaded; nil loads them all.
+func GetAllChildBranches(query *listquery.Query, projection *listquery.Projection, includeDeleted bool, scope *BranchScope) ([]models.Branch, error) {
+	return cached(context.Background(), CacheGroupBranches, childBranchesCacheKey(query, projection, includeDeleted, scope), func() ([]models.Branch, error) {
+		var childBranches []models.Branch
+		if err := query.Apply(scope.Apply(withDeleted(config.DB, includeDeleted), "id")).
+			Where("parent_branch_id IS NOT NULL").
+			Scopes(func(db *gorm.DB) *gorm.DB {
+				return projection.Select(db, func(db *gorm.DB) *gorm.DB {
+					return db.
+						Preload("Parent").
+						Preload("Country").
+						Preload("State").
+						Preload("District").
+						Preload("City").
+						Preload("Infrastructures")
+				})
+			}).
+			Find(&childBranches).Error; err != nil {
+			return nil, err
+		}
+		return childBranches, nil
+	})
+}
+
+// childBranchesCacheKey identifies a GetAllChildBranches list
+func childBranchesCacheKey(query *listquery.Query, projection *listquery.Projection, includeDeleted bool, scope *BranchScope) string {
+	key := "child_branches:" + query.Key() + ":" + projection.Key() + ":" + strconv.FormatBool(includeDeleted) + ":"
+	if scope == nil {
+		return key + "all"
 	}
-	return childBranches, nil
+	return key + cacheKeyIDs(scope.BranchIDs)
 }
 
 // GetChildBranch fetches a child branch by ID (branch with parent_branch_id set)
 func GetChildBranch(childBranchID uint) (*models.Branch, error) {
-	var childBranch models.Branch
-	if err := config.DB.
-		Where("id = ? AND parent_branch_id IS NOT NULL", childBranchID).
-		Preload("Parent").
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Preload("Infrastructures").
-		Preload("Members").
-		First(&childBranch).Error; err != nil {
-		return nil, errors.New("child branch not found")
-	}
-	return &childBranch, nil
+	return cached(context.Background(), CacheGroupBranches, "child_branch:" + strconv.FormatUint(uint64(childBranchID), 10), func() (*models.Branch, error) {
+		var childBranch models.Branch
+		if err := config.DB.
+			Where("id = ? AND parent_branch_id IS NOT NULL", childBranchID).
+			Preload("Parent").
+			Preload("Country").
+			Preload("State").
+			Preload("District").
+			Preload("City").
+			Preload("Infrastructures").
+			Preload("Members").
+			First(&childBranch).Error; err != nil {
+			return nil, errors.New("child branch not found")
+		}
+		return &childBranch, nil
+	})
 }
 
-// GetChildBranchesByParent fetches all child branches of a parent branch
-func GetChildBranchesByParent(parentBranchID uint) ([]models.Branch, error) {
-	var childBranches []models.Branch
-	if err := config.DB.
-		Where("parent_branch_id = ?", parentBranchID).
-		Preload("Parent").
-		Preload("Country").
-		Preload("State").
-		Preload("District").
-		Preload("City").
-		Preload("Infrastructures").
-		Preload("Members").
-		Order("id DESC").
-		Find(&childBranches).Error; err != nil {
-		return nil, err
-	}
-	return childBranches, nil
+// GetChildBranchesByParent fetches all child branches of a parent branch, sorted and filtered by
+// a query parsed with BranchListSchema
+// A projection (see BranchFieldSet) limits the columns and associations loaded; nil loads them all.
+func GetChildBranchesByParent(parentBranchID uint, query *listquery.Query, projection *listquery.Projection) ([]models.Branch, error) {
+	return cached(context.Background(), CacheGroupBranches, "child_branches_by_parent:" + strconv.FormatUint(uint64(parentBranchID), 10) + ":" + query.Key() + ":" + projection.Key(), func() ([]models.Branch, error) {
+		var childBranches []models.Branch
+		if err := query.Apply(config.DB).
+			Where("parent_branch_id = ?", parentBranchID).
+			Scopes(func(db *gorm.DB) *gorm.DB {
+				return projection.Select(db, func(db *gorm.DB) *gorm.DB {
+					return db.
+						Preload("Parent").
+						Preload("Country").
+						Preload("State").
+						Preload("District").
+						Preload("City").
+						Preload("Infrastructures").
+						Preload("Members")
+				})
+			}).
+			Find(&childBranches).Error; err != nil {
+			return nil, err
+		}
+		return childBranches, nil
+	})
 }
 
-// UpdateChildBranch updates a child branch
-func UpdateChildBranch(childBranchID uint, updatedData map[string]interface{}) error {
+// UpdateChildBranch updates a child branch. version is the version the client last read; when set
+// the update fails with a VersionConflictError if the child branch has been updated since.
+func UpdateChildBranch(childBranchID uint, updatedData map[string]interface{}, version *int) error {
 	var childBranch models.Branch
 	if err := config.DB.Where("id = ? AND parent_branch_id IS NOT NULL", childBranchID).First(&childBranch).Error; err != nil {
 		return errors.New("child branch not found")
@@ -150,14 +192,28 @@ func UpdateChildBranch(childBranchID uint, updatedData map[string]interface{}) e
 
 	now := time.Now()
 	updatedData["updated_on"] = &now
+	coordinateUpdates(updatedData)
+	previousAddress, geocode := geocodeBaseline(childBranchID, updatedData)
 
-	if err := config.DB.Model(&childBranch).Updates(updatedData).Error; err != nil {
+	updated, err := updateVersioned(config.DB, &childBranch, version, updatedData)
+	if err != nil {
 		return err
 	}
+	if !updated {
+		current, err := GetChildBranch(childBranchID)
+		if err != nil {
+			return err
+		}
+		return &VersionConflictError{Version: current.Version, Current: current}
+	}
+	if geocode {
+		queueBranchGeocode(childBranchID, previousAddress)
+	}
+	publishBranchUpdated(childBranchID)
 	return nil
 }
 
-// DeleteChildBranch deletes a child branch by ID
+// DeleteChildBranch soft-deletes a child branch by ID
 func DeleteChildBranch(childBranchID uint) error {
 	// Only delete if it's actually a child branch (has parent_branch_id)
 	var childBranch models.Branch
@@ -170,6 +226,99 @@ func DeleteChildBranch(childBranchID uint) error {
 	return nil
 }
 
+// RestoreChildBranch restores a soft-deleted child branch
+func RestoreChildBranch(childBranchID uint) error {
+	var childBranch models.Branch
+	if err := config.DB.Unscoped().Where("id = ? AND parent_branch_id IS NOT NULL", childBranchID).First(&childBranch).Error; err != nil {
+		return errors.New("child branch not found")
+	}
+	return restoreSoftDeleted(config.DB, &models.Branch{}, childBranchID)
+}
+
+var (
+	// ErrChildBranchNotFound is returned for unknown or soft-deleted child branches
+	ErrChildBranchNotFound = errors.New("child branch not found")
+	// ErrInvalidTransferTarget is returned when the target cannot become the child branch's parent
+	ErrInvalidTransferTarget = errors.New("invalid target parent branch")
+)
+
+// ChildBranchTransfer describes moving a child branch under another parent branch
+type ChildBranchTransfer struct {
+	TargetParentID uint
+	// MoveMembers keeps the members on the child branch, so they move with it. When false they
+	// are reassigned to the previous parent and stay in the original organization.
+	MoveMembers bool
+}
+
+// TransferChildBranch moves a child branch to another parent branch. The target must be an active
+// top-level branch other than the current parent; the child re-inherits the new parent's
+// coordinator. The change (and any member reassignment) is audited in the same transaction.
+func TransferChildBranch(childBranchID uint, transfer ChildBranchTransfer, actor AuditActor) (*models.Branch, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var child models.Branch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND parent_branch_id IS NOT NULL", childBranchID).
+			First(&child).Error; err != nil {
+			return ErrChildBranchNotFound
+		}
+		oldParentID := *child.ParentBranchID
+
+		var target models.Branch
+		if err := tx.First(&target, transfer.TargetParentID).Error; err != nil {
+			return fmt.Errorf("%w: branch %d not found", ErrInvalidTransferTarget, transfer.TargetParentID)
+		}
+		switch {
+		case target.ID == child.ID:
+			return fmt.Errorf("%w: a branch cannot be its own parent", ErrInvalidTransferTarget)
+		case target.ID == oldParentID:
+			return fmt.Errorf("%w: branch %d is already the parent", ErrInvalidTransferTarget, target.ID)
+		case target.ParentBranchID != nil:
+			return fmt.Errorf("%w: branch %d is itself a child branch", ErrInvalidTransferTarget, target.ID)
+		case !target.Status:
+			return fmt.Errorf("%w: branch %d is inactive", ErrInvalidTransferTarget, target.ID)
+		}
+
+		before := loadAuditSnapshot(tx, AuditEntityChildBranch, child.ID)
+		now := time.Now()
+		if err := tx.Model(&child).Updates(map[string]interface{}{
+			"parent_branch_id": target.ID,
+			"coordinator_name": target.CoordinatorName,
+			"updated_on":       &now,
+		}).Error; err != nil {
+			return err
+		}
+		changes := DiffAuditSnapshots(before, loadAuditSnapshot(tx, AuditEntityChildBranch, child.ID))
+
+		var entries []*models.AuditLog
+		if !transfer.MoveMembers {
+			var memberIDs []uint
+			if err := tx.Model(&models.BranchMember{}).Where("branch_id = ?", child.ID).Order("id").Pluck("id", &memberIDs).Error; err != nil {
+				return err
+			}
+			if len(memberIDs) > 0 {
+				if err := tx.Model(&models.BranchMember{}).Where("id IN ?", memberIDs).
+					Updates(map[string]interface{}{"branch_id": oldParentID, "updated_on": &now}).Error; err != nil {
+					return err
+				}
+				changes["member_ids"] = map[string]interface{}{"old": memberIDs, "new": []uint{}}
+				entries = append(entries, actor.auditEntry(AuditEntityBranch, oldParentID, models.AuditActionUpdate,
+					models.JSONB{"transferred_member_ids": map[string]interface{}{"new": memberIDs}}, now))
+			}
+		}
+		entries = append(entries, actor.auditEntry(AuditEntityChildBranch, child.ID, models.AuditActionUpdate, changes, now))
+
+		if err := tx.Create(entries).Error; err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetChildBranch(childBranchID)
+}
+
 // *************************************** Child Branch Infrastructure ****************************************************** //
 // Note: Child branch infrastructure now uses BranchInfrastructure model with branch_id
 
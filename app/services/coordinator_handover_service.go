@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidHandover = errors.New("invalid coordinator handover")
+	// ErrHandoverNotAllowed is returned to users who may not change the branch's coordinator
+	ErrHandoverNotAllowed = errors.New("only an admin or a manager of the branch's region can hand over the branch")
+)
+
+// AuditActionHandover marks the audit entry of a branch whose coordinator was handed over
+const AuditActionHandover = "handover"
+
+// maxHandoverChain bounds how many consecutive handovers a review notification follows
+const maxHandoverChain = 10
+
+// CoordinatorHandoverInput names the new coordinator of a branch
+type CoordinatorHandoverInput struct {
+	ToUserID uint
+	// CoordinatorName is the branch's new coordinator_name; defaults to the new user's name
+	CoordinatorName string
+	Note            string
+}
+
+// HandOverBranch makes another user the coordinator of a top-level branch:
+//   - the previous coordinators (users whose branch_id is the branch) lose the branch and the
+//     new user gets it
+//   - coordinator_name is updated on the branch and on its child branches, which inherit it
+//   - pending change requests the previous coordinators submitted for the branch or its child
+//     branches are moved to the new coordinator
+//   - review outcomes of events the previous coordinators submitted are emailed to the new one
+//     (see coordinatorSuccessor)
+//
+// Everything happens in one transaction, with audit entries for the branch, the child branches
+// and the users.
+func HandOverBranch(branchID uint, input CoordinatorHandoverInput, actor AuditActor) (*models.CoordinatorHandover, error) {
+	reviewer, err := branchChangeUser(actor)
+	if err != nil {
+		return nil, ErrHandoverNotAllowed
+	}
+
+	var handover models.CoordinatorHandover
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var branch models.Branch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Limit(1).Find(&branch, branchID).Error; err != nil {
+			return err
+		}
+		if branch.ID == 0 {
+			return ErrBranchNotFound
+		}
+		if !reviewsBranch(reviewer, &branch) {
+			return ErrHandoverNotAllowed
+		}
+		if branch.ParentBranchID != nil {
+			return fmt.Errorf("%w: child branches inherit the coordinator of branch %d", ErrInvalidHandover, *branch.ParentBranchID)
+		}
+
+		var next models.User
+		if err := tx.Select("id", "name", "branch_id").Where("is_deleted = ?", false).
+			Limit(1).Find(&next, input.ToUserID).Error; err != nil {
+			return err
+		}
+		if next.ID == 0 {
+			return fmt.Errorf("%w: user %d not found", ErrInvalidHandover, input.ToUserID)
+		}
+		name := strings.TrimSpace(input.CoordinatorName)
+		if name == "" {
+			name = next.Name
+		}
+
+		var previous []uint
+		if err := tx.Model(&models.User{}).
+			Where("branch_id = ? AND id <> ? AND is_deleted = ?", branch.ID, next.ID, false).
+			Order("id").Pluck("id", &previous).Error; err != nil {
+			return err
+		}
+		if len(previous) == 0 && next.BranchID != nil && *next.BranchID == branch.ID && name == branch.CoordinatorName {
+			return fmt.Errorf("%w: user %d already coordinates the branch", ErrInvalidHandover, next.ID)
+		}
+
+		now := time.Now()
+		var entries []*models.AuditLog
+		if len(previous) > 0 {
+			if err := tx.Model(&models.User{}).Where("id IN ?", previous).
+				UpdateColumn("branch_id", nil).Error; err != nil {
+				return err
+			}
+			for _, id := range previous {
+				entries = append(entries, actor.auditEntry(AuditEntityUser, id, models.AuditActionUpdate,
+					models.JSONB{"branch_id": map[string]interface{}{"old": branch.ID, "new": nil}}, now))
+			}
+		}
+		if next.BranchID == nil || *next.BranchID != branch.ID {
+			if err := tx.Model(&models.User{}).Where("id = ?", next.ID).
+				UpdateColumn("branch_id", branch.ID).Error; err != nil {
+				return err
+			}
+			entries = append(entries, actor.auditEntry(AuditEntityUser, next.ID, models.AuditActionUpdate,
+				models.JSONB{"branch_id": map[string]interface{}{"old": next.BranchID, "new": branch.ID}}, now))
+		}
+
+		if err := tx.Model(&models.Branch{}).Where("id = ?", branch.ID).
+			Updates(map[string]interface{}{"coordinator_name": name, "updated_on": &now}).Error; err != nil {
+			return err
+		}
+		var children []uint
+		if err := tx.Model(&models.Branch{}).Where("parent_branch_id = ?", branch.ID).
+			Order("id").Pluck("id", &children).Error; err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			if err := tx.Model(&models.Branch{}).Where("id IN ?", children).
+				Updates(map[string]interface{}{"coordinator_name": name, "updated_on": &now}).Error; err != nil {
+				return err
+			}
+			for _, id := range children {
+				entries = append(entries, actor.auditEntry(AuditEntityChildBranch, id, models.AuditActionUpdate,
+					models.JSONB{"coordinator_name": map[string]interface{}{"new": name}}, now))
+			}
+		}
+
+		branchIDs := append([]uint{branch.ID}, children...)
+		var changeRequests, pendingEvents int64
+		if len(previous) > 0 {
+			result := tx.Model(&models.BranchChangeRequest{}).
+				Where("branch_id IN ? AND status = ? AND requested_by IN ?", branchIDs, models.BranchChangeStatusPending, previous).
+				UpdateColumns(map[string]interface{}{"requested_by": next.ID, "updated_on": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			changeRequests = result.RowsAffected
+
+			if err := tx.Model(&models.EventDetails{}).
+				Where("branch_id IN ? AND approval_status IN ?", branchIDs,
+					[]string{models.EventStatusSubmitted, models.EventStatusUnderReview}).
+				Count(&pendingEvents).Error; err != nil {
+				return err
+			}
+		}
+
+		handover = models.CoordinatorHandover{
+			BranchID:       branch.ID,
+			FromUserIDs:    previous,
+			ToUserID:       next.ID,
+			FromName:       branch.CoordinatorName,
+			ToName:         name,
+			Note:           strings.TrimSpace(input.Note),
+			ChildBranchIDs: children,
+			ChangeRequests: int(changeRequests),
+			PendingEvents:  int(pendingEvents),
+			CreatedBy:      actor.UserID,
+		}
+		if handover.FromUserIDs == nil {
+			handover.FromUserIDs = []uint{}
+		}
+		if handover.ChildBranchIDs == nil {
+			handover.ChildBranchIDs = []uint{}
+		}
+		if err := tx.Create(&handover).Error; err != nil {
+			return err
+		}
+
+		entries = append(entries, actor.auditEntry(AuditEntityBranch, branch.ID, AuditActionHandover, models.JSONB{
+			"coordinator_name": map[string]interface{}{"old": branch.CoordinatorName, "new": name},
+			"from_user_ids":    previous,
+			"to_user_id":       next.ID,
+			"handover_id":      handover.ID,
+			"change_requests":  changeRequests,
+		}, now))
+		if err := tx.Create(entries).Error; err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &handover, nil
+}
+
+// ListCoordinatorHandovers returns the handovers of a branch, newest first
+func ListCoordinatorHandovers(branchID uint) ([]models.CoordinatorHandover, error) {
+	var branch models.Branch
+	if err := config.DB.Select("id").Limit(1).Find(&branch, branchID).Error; err != nil {
+		return nil, err
+	}
+	if branch.ID == 0 {
+		return nil, ErrBranchNotFound
+	}
+	handovers := []models.CoordinatorHandover{}
+	if err := config.DB.Where("branch_id = ?", branchID).Order("created_on DESC, id DESC").Find(&handovers).Error; err != nil {
+		return nil, err
+	}
+	return handovers, nil
+}
+
+// coordinatorSuccessor follows the handovers of the branch (or its parent) made after since and
+// returns who took over from userID, or userID itself if the branch was not handed over
+func coordinatorSuccessor(userID uint, branchID *uint, since time.Time) uint {
+	if branchID == nil {
+		return userID
+	}
+	var branch models.Branch
+	if err := config.DB.Select("id", "parent_branch_id").Limit(1).Find(&branch, *branchID).Error; err != nil || branch.ID == 0 {
+		return userID
+	}
+	if branch.ParentBranchID != nil {
+		branch.ID = *branch.ParentBranchID
+	}
+
+	for i := 0; i < maxHandoverChain; i++ {
+		var handover models.CoordinatorHandover
+		if err := config.DB.Select("id", "to_user_id", "created_on").
+			Where("branch_id = ? AND created_on > ? AND from_user_ids @> ?::jsonb", branch.ID, since, fmt.Sprintf("[%d]", userID)).
+			Order("created_on, id").Limit(1).Find(&handover).Error; err != nil || handover.ID == 0 {
+			break
+		}
+		userID, since = handover.ToUserID, handover.CreatedOn
+	}
+	return userID
+}
@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// DashboardFilter narrows the dashboard figures. The date range applies to the event start
+// date (donations and event media follow their event); branch media uses its upload date.
+// Archived financial years are served from their rollups, which have monthly granularity:
+// for them the range covers whole months.
+type DashboardFilter struct {
+	From            *time.Time
+	To              *time.Time
+	BranchID        uint
+	IncludeChildren bool // with BranchID: roll up all child branches
+	Limit           int  // top branches, default 10, max 100
+	Scope           *BranchScope
+}
+
+// DashboardMonthEvents is the number of events starting in one month (YYYY-MM)
+type DashboardMonthEvents struct {
+	Month          string `json:"month"`
+	Events         int64  `json:"events"`
+	EventsComplete int64  `json:"events_complete"`
+}
+
+// DashboardMonthTrend is the beneficiaries and initiations reported for events of one month
+type DashboardMonthTrend struct {
+	Month            string `json:"month"`
+	BeneficiaryMen   int64  `json:"beneficiary_men"`
+	BeneficiaryWomen int64  `json:"beneficiary_women"`
+	BeneficiaryChild int64  `json:"beneficiary_child"`
+	Beneficiaries    int64  `json:"beneficiaries"`
+	InitiationMen    int64  `json:"initiation_men"`
+	InitiationWomen  int64  `json:"initiation_women"`
+	InitiationChild  int64  `json:"initiation_child"`
+	Initiations      int64  `json:"initiations"`
+}
+
+// DashboardBranchActivity is one entry of the top branches ranking
+type DashboardBranchActivity struct {
+	BranchID      uint    `json:"branch_id"`
+	Name          string  `json:"name"`
+	Events        int64   `json:"events"`
+	Beneficiaries int64   `json:"beneficiaries"`
+	Initiations   int64   `json:"initiations"`
+	Donations     int64   `json:"donations"`
+	DonationTotal float64 `json:"donation_total"`
+}
+
+// DashboardDonationType is the donation total of one donation type
+type DashboardDonationType struct {
+	DonationType string  `json:"donation_type"`
+	Count        int64   `json:"count"`
+	Amount       float64 `json:"amount"`
+}
+
+// DashboardFileTypeCount is the number of media files of one file type
+type DashboardFileTypeCount struct {
+	FileType string `json:"file_type"`
+	Count    int64  `json:"count"`
+}
+
+// DashboardMediaCounts are the event and branch media counts by file type
+type DashboardMediaCounts struct {
+	EventMedia  []DashboardFileTypeCount `json:"event_media"`
+	BranchMedia []DashboardFileTypeCount `json:"branch_media"`
+	Total       int64                    `json:"total"`
+}
+
+// Dashboard is the response of GET /api/dashboard: all sections in one call
+type Dashboard struct {
+	EventsByMonth []DashboardMonthEvents    `json:"events_by_month"`
+	Trends        []DashboardMonthTrend     `json:"trends"`
+	TopBranches   []DashboardBranchActivity `json:"top_branches"`
+	Donations     []DashboardDonationType   `json:"donations"`
+	Media         *DashboardMediaCounts     `json:"media"`
+}
+
+const (
+	dashboardMonthSQL        = "to_char(date_trunc('month', e.start_date), 'YYYY-MM')"
+	dashboardDonationTypeSQL = "COALESCE(NULLIF(d.donation_type, ''), 'unspecified')"
+	dashboardFileTypeSQL     = "COALESCE(NULLIF(m.file_type, ''), 'file')"
+)
+
+// GetDashboard computes every dashboard section for the filter
+func GetDashboard(filter DashboardFilter) (*Dashboard, error) {
+	var (
+		dashboard Dashboard
+		err       error
+	)
+	if dashboard.EventsByMonth, err = GetDashboardEventsByMonth(filter); err != nil {
+		return nil, err
+	}
+	if dashboard.Trends, err = GetDashboardTrends(filter); err != nil {
+		return nil, err
+	}
+	if dashboard.TopBranches, err = GetDashboardTopBranches(filter); err != nil {
+		return nil, err
+	}
+	if dashboard.Donations, err = GetDashboardDonations(filter); err != nil {
+		return nil, err
+	}
+	if dashboard.Media, err = GetDashboardMedia(filter); err != nil {
+		return nil, err
+	}
+	return &dashboard, nil
+}
+
+// CachedDashboard serves a dashboard section (or the whole dashboard) computed by load from the
+// dashboard cache. Entries are dropped when events, donations, media or branches change.
+func CachedDashboard[T any](filter DashboardFilter, section string, load func(DashboardFilter) (T, error)) (T, error) {
+	return cached(context.Background(), CacheGroupDashboard, section+":"+filter.cacheKey(), func() (T, error) {
+		return load(filter)
+	})
+}
+
+// cacheKey identifies the filter, including the scope of the user
+func (f DashboardFilter) cacheKey() string {
+	key := fmt.Sprintf("%d:%t:%d", f.BranchID, f.IncludeChildren, f.Limit)
+	for _, t := range []*time.Time{f.From, f.To} {
+		key += ":"
+		if t != nil {
+			key += t.Format(time.RFC3339)
+		}
+	}
+	if f.Scope == nil {
+		return key + ":all"
+	}
+	return key + ":" + cacheKeyIDs(f.Scope.BranchIDs)
+}
+
+// GetDashboardEventsByMonth counts events per month of their start date
+func GetDashboardEventsByMonth(filter DashboardFilter) ([]DashboardMonthEvents, error) {
+	query, err := dashboardEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := dashboardRollups(filter)
+	if err != nil {
+		return nil, err
+	}
+	live := query.
+		Select(dashboardMonthSQL+` AS month, COUNT(*) AS events,
+			COUNT(*) FILTER (WHERE e.status = ?) AS events_complete`, "complete").
+		Group("month")
+	archived := rollups.
+		Select("r.month, SUM(r.events) AS events, SUM(r.events_complete) AS events_complete").
+		Group("r.month")
+
+	rows := []DashboardMonthEvents{}
+	err = config.DB.Table("((?) UNION ALL (?)) AS t", live, archived).
+		Select("t.month, SUM(t.events)::bigint AS events, SUM(t.events_complete)::bigint AS events_complete").
+		Group("t.month").
+		Order("t.month").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetDashboardTrends sums beneficiaries and initiations per month of the event start date
+func GetDashboardTrends(filter DashboardFilter) ([]DashboardMonthTrend, error) {
+	query, err := dashboardEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := dashboardRollups(filter)
+	if err != nil {
+		return nil, err
+	}
+	live := query.
+		Select(dashboardMonthSQL + ` AS month,
+			COALESCE(SUM(e.beneficiary_men), 0) AS beneficiary_men,
+			COALESCE(SUM(e.beneficiary_women), 0) AS beneficiary_women,
+			COALESCE(SUM(e.beneficiary_child), 0) AS beneficiary_child,
+			COALESCE(SUM(e.initiation_men), 0) AS initiation_men,
+			COALESCE(SUM(e.initiation_women), 0) AS initiation_women,
+			COALESCE(SUM(e.initiation_child), 0) AS initiation_child`).
+		Group("month")
+	archived := rollups.
+		Select(`r.month, SUM(r.beneficiary_men) AS beneficiary_men, SUM(r.beneficiary_women) AS beneficiary_women,
+			SUM(r.beneficiary_child) AS beneficiary_child, SUM(r.initiation_men) AS initiation_men,
+			SUM(r.initiation_women) AS initiation_women, SUM(r.initiation_child) AS initiation_child`).
+		Group("r.month")
+
+	rows := []DashboardMonthTrend{}
+	err = config.DB.Table("((?) UNION ALL (?)) AS t", live, archived).
+		Select(`t.month, SUM(t.beneficiary_men)::bigint AS beneficiary_men, SUM(t.beneficiary_women)::bigint AS beneficiary_women,
+			SUM(t.beneficiary_child)::bigint AS beneficiary_child, SUM(t.initiation_men)::bigint AS initiation_men,
+			SUM(t.initiation_women)::bigint AS initiation_women, SUM(t.initiation_child)::bigint AS initiation_child`).
+		Group("t.month").
+		Order("t.month").
+		Scan(&rows).Error
+	for i := range rows {
+		row := &rows[i]
+		row.Beneficiaries = row.BeneficiaryMen + row.BeneficiaryWomen + row.BeneficiaryChild
+		row.Initiations = row.InitiationMen + row.InitiationWomen + row.InitiationChild
+	}
+	return rows, err
+}
+
+// GetDashboardTopBranches ranks branches by number of events, then beneficiaries
+func GetDashboardTopBranches(filter DashboardFilter) ([]DashboardBranchActivity, error) {
+	events, err := dashboardEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	donations, err := dashboardDonations(filter)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	rollups, err := dashboardRollups(filter)
+	if err != nil {
+		return nil, err
+	}
+	donationRollups, err := dashboardBreakdowns(filter, models.ArchivedBreakdownDonationType)
+	if err != nil {
+		return nil, err
+	}
+
+	events = events.
+		Select(`e.branch_id, COUNT(*) AS events,
+			COALESCE(SUM(e.beneficiary_men + e.beneficiary_women + e.beneficiary_child), 0) AS beneficiaries,
+			COALESCE(SUM(e.initiation_men + e.initiation_women + e.initiation_child), 0) AS initiations`).
+		Where("e.branch_id IS NOT NULL").
+		Group("e.branch_id")
+	rollups = rollups.
+		Select(`r.branch_id, SUM(r.events) AS events,
+			SUM(r.beneficiary_men + r.beneficiary_women + r.beneficiary_child) AS beneficiaries,
+			SUM(r.initiation_men + r.initiation_women + r.initiation_child) AS initiations`).
+		Where("r.branch_id IS NOT NULL").
+		Group("r.branch_id")
+	events = config.DB.Table("((?) UNION ALL (?)) AS u", events, rollups).
+		Select(`u.branch_id, SUM(u.events)::bigint AS events, SUM(u.beneficiaries)::bigint AS beneficiaries,
+			SUM(u.initiations)::bigint AS initiations`).
+		Group("u.branch_id")
+
+	donations = donations.
+		Select("d.branch_id, COUNT(*) AS donations, COALESCE(SUM(d.amount), 0) AS donation_total").
+		Group("d.branch_id")
+	donationRollups = donationRollups.
+		Select("r.branch_id, SUM(r.count) AS donations, SUM(r.amount) AS donation_total").
+		Group("r.branch_id")
+	donations = config.DB.Table("((?) UNION ALL (?)) AS u", donations, donationRollups).
+		Select("u.branch_id, SUM(u.donations)::bigint AS donations, SUM(u.donation_total)::float8 AS donation_total").
+		Group("u.branch_id")
+
+	rows := []DashboardBranchActivity{}
+	err = config.DB.Table("(?) AS ev", events).
+		Select(`ev.branch_id, b.name, ev.events, ev.beneficiaries, ev.initiations,
+			COALESCE(dn.donations, 0) AS donations, COALESCE(dn.donation_total, 0) AS donation_total`).
+		Joins("JOIN branches b ON b.id = ev.branch_id AND b.deleted_at IS NULL").
+		Joins("LEFT JOIN (?) AS dn ON dn.branch_id = ev.branch_id", donations).
+		Order("ev.events DESC, ev.beneficiaries DESC, ev.branch_id").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetDashboardDonations totals donations by donation type, largest amount first
+func GetDashboardDonations(filter DashboardFilter) ([]DashboardDonationType, error) {
+	query, err := dashboardDonations(filter)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := dashboardBreakdowns(filter, models.ArchivedBreakdownDonationType)
+	if err != nil {
+		return nil, err
+	}
+	live := query.
+		Select(dashboardDonationTypeSQL + ` AS donation_type,
+			COUNT(*) AS count, COALESCE(SUM(d.amount), 0) AS amount`).
+		Group(dashboardDonationTypeSQL)
+	archived := rollups.
+		Select("r.key AS donation_type, SUM(r.count) AS count, SUM(r.amount) AS amount").
+		Group("r.key")
+
+	rows := []DashboardDonationType{}
+	err = config.DB.Table("((?) UNION ALL (?)) AS t", live, archived).
+		Select("t.donation_type, SUM(t.count)::bigint AS count, SUM(t.amount)::float8 AS amount").
+		Group("t.donation_type").
+		Order("amount DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetDashboardMedia counts event and branch media by file type
+func GetDashboardMedia(filter DashboardFilter) (*DashboardMediaCounts, error) {
+	events, err := dashboardEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+	counts := &DashboardMediaCounts{
+		EventMedia:  []DashboardFileTypeCount{},
+		BranchMedia: []DashboardFileTypeCount{},
+	}
+	rollups, err := dashboardBreakdowns(filter, models.ArchivedBreakdownMediaFileType)
+	if err != nil {
+		return nil, err
+	}
+	live := events.
+		Select(dashboardFileTypeSQL + " AS file_type, COUNT(*) AS count").
+		Joins("JOIN event_media m ON m.event_id = e.id").
+		Group(dashboardFileTypeSQL)
+	archived := rollups.
+		Select("r.key AS file_type, SUM(r.count) AS count").
+		Group("r.key")
+	err = config.DB.Table("((?) UNION ALL (?)) AS t", live, archived).
+		Select("t.file_type, SUM(t.count)::bigint AS count").
+		Group("t.file_type").
+		Order("count DESC").
+		Scan(&counts.EventMedia).Error
+	if err != nil {
+		return nil, err
+	}
+
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := excludeSandbox(config.DB.Table("branch_media m").Where("m.deleted_at IS NULL"), "m.branch_id")
+	if branchIDs != nil {
+		query = query.Where("m.branch_id IN ?", branchIDs)
+	}
+	if filter.From != nil {
+		query = query.Where("m.created_on >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("m.created_on <= ?", *filter.To)
+	}
+	err = query.
+		Select(dashboardFileTypeSQL + " AS file_type, COUNT(*) AS count").
+		Group(dashboardFileTypeSQL).
+		Order("count DESC").
+		Scan(&counts.BranchMedia).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range counts.EventMedia {
+		counts.Total += c.Count
+	}
+	for _, c := range counts.BranchMedia {
+		counts.Total += c.Count
+	}
+	return counts, nil
+}
+
+// dashboardBranchIDs resolves the branch filter: nil for all branches, otherwise the branch
+// (and with IncludeChildren its descendants). Unknown branches return ErrBranchNotFound,
+// branches outside the filter's scope ErrOutOfBranchScope; without a branch a scoped filter
+// covers the scope's branches.
+func dashboardBranchIDs(filter DashboardFilter) ([]uint, error) {
+	if filter.BranchID == 0 {
+		if filter.Scope != nil {
+			return filter.Scope.BranchIDs, nil
+		}
+		return nil, nil
+	}
+	if err := filter.Scope.CheckBranch(filter.BranchID); err != nil {
+		return nil, err
+	}
+	query := "SELECT id AS branch_id, name, parent_branch_id FROM branches WHERE id = @id AND deleted_at IS NULL"
+	if filter.IncludeChildren {
+		query = branchTreeSQL
+	}
+	var rows []BranchStatsRow
+	if err := config.DB.Raw(query, map[string]interface{}{"id": filter.BranchID}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrBranchNotFound
+	}
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.BranchID
+	}
+	return ids, nil
+}
+
+// dashboardEvents selects the live events (alias e) of years that are not archived matching
+// the filter
+func dashboardEvents(filter DashboardFilter) (*gorm.DB, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := excludeSandbox(config.DB.Table("event_details e").Where("e.deleted_at IS NULL AND NOT e.archived"), "e.branch_id")
+	if branchIDs != nil {
+		query = query.Where("e.branch_id IN ?", branchIDs)
+	}
+	if filter.From != nil {
+		query = query.Where("e.start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("e.start_date <= ?", *filter.To)
+	}
+	return query, nil
+}
+
+// dashboardDonations selects the live donations (alias d) of live events of years that are not
+// archived matching the filter; voided receipts are left out
+func dashboardDonations(filter DashboardFilter) (*gorm.DB, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := config.DB.Table("donations d").
+		Joins("JOIN event_details e ON e.id = d.event_id AND e.deleted_at IS NULL AND NOT e.archived").
+		Where("d.deleted_at IS NULL AND d.voided_on IS NULL")
+	query = excludeSandbox(query, "d.branch_id")
+	if branchIDs != nil {
+		query = query.Where("d.branch_id IN ?", branchIDs)
+	}
+	if filter.From != nil {
+		query = query.Where("e.start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("e.start_date <= ?", *filter.To)
+	}
+	return query, nil
+}
+
+// dashboardRollups selects the event rollups (alias r) of archived years matching the filter
+func dashboardRollups(filter DashboardFilter) (*gorm.DB, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	return dashboardRollupMonths(excludeSandbox(archivedYearRollups(branchIDs), "r.branch_id"), filter), nil
+}
+
+// dashboardBreakdowns selects the breakdowns of one kind (alias r) of archived years matching
+// the filter
+func dashboardBreakdowns(filter DashboardFilter, kind string) (*gorm.DB, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	return dashboardRollupMonths(excludeSandbox(archivedYearBreakdowns(kind, branchIDs), "r.branch_id"), filter), nil
+}
+
+func dashboardRollupMonths(query *gorm.DB, filter DashboardFilter) *gorm.DB {
+	if filter.From != nil {
+		query = query.Where("r.month >= ?", filter.From.Format("2006-01"))
+	}
+	if filter.To != nil {
+		query = query.Where("r.month <= ?", filter.To.Format("2006-01"))
+	}
+	return query
+}
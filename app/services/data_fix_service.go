@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// Data fixes available from the admin console
+const (
+	DataFixReassignEventBranch = "reassign_event_branch"
+	DataFixSwapMediaOwner      = "swap_media_owner"
+	DataFixEventDates          = "event_dates"
+)
+
+// maxDataFixEvents caps the number of events a single bulk date fix may touch
+const maxDataFixEvents = 500
+
+var (
+	ErrDataFixNoChanges   = errors.New("data fix would not change any records")
+	ErrDataFixInvalidArgs = errors.New("invalid data fix arguments")
+	ErrDataFixNotFound    = errors.New("not found")
+)
+
+// errDataFixDryRun rolls back the transaction of a dry run
+var errDataFixDryRun = errors.New("dry run")
+
+// DataFixChange is the effect of a data fix on a single record
+type DataFixChange struct {
+	EntityType string       `json:"entity_type"`
+	EntityID   uint         `json:"entity_id"`
+	Changes    models.JSONB `json:"changes"`
+}
+
+// DataFixResult is returned by every data fix; in a dry run nothing has been written
+type DataFixResult struct {
+	Fix     string          `json:"fix"`
+	DryRun  bool            `json:"dry_run"`
+	Changes []DataFixChange `json:"changes"`
+}
+
+// dataFixTarget is a record touched by a fix, snapshotted before and after
+type dataFixTarget struct {
+	entityType string
+	id         uint
+}
+
+// runDataFix applies a fix inside a transaction and diffs every touched record.
+// Dry runs always roll back, so the preview shows exactly what would be written.
+// Real runs write one audit entry per changed record in the same transaction.
+func runDataFix(fix string, dryRun bool, actor AuditActor, targets []dataFixTarget, apply func(tx *gorm.DB) error) (*DataFixResult, error) {
+	result := &DataFixResult{Fix: fix, DryRun: dryRun, Changes: []DataFixChange{}}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		before := make([]map[string]interface{}, len(targets))
+		for i, t := range targets {
+			before[i] = loadAuditSnapshot(tx, t.entityType, t.id)
+		}
+
+		if err := apply(tx); err != nil {
+			return err
+		}
+
+		for i, t := range targets {
+			changes := DiffAuditSnapshots(before[i], loadAuditSnapshot(tx, t.entityType, t.id))
+			if len(changes) == 0 {
+				continue
+			}
+			result.Changes = append(result.Changes, DataFixChange{EntityType: t.entityType, EntityID: t.id, Changes: changes})
+		}
+		if len(result.Changes) == 0 {
+			return ErrDataFixNoChanges
+		}
+
+		if dryRun {
+			return errDataFixDryRun
+		}
+
+		now := time.Now()
+		for _, change := range result.Changes {
+			entry := actor.auditEntry(change.EntityType, change.EntityID, models.AuditActionUpdate, change.Changes, now)
+			if err := tx.Create(entry).Error; err != nil {
+				return fmt.Errorf("failed to write audit log: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDataFixDryRun) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReassignEventBranch moves an event to the correct branch. With includeRelated the
+// event's volunteers and donations are moved too.
+func ReassignEventBranch(eventID, branchID uint, includeRelated, dryRun bool, actor AuditActor) (*DataFixResult, error) {
+	if err := config.DB.Select("id").First(&models.EventDetails{}, eventID).Error; err != nil {
+		return nil, fmt.Errorf("event %d %w", eventID, ErrDataFixNotFound)
+	}
+	if err := config.DB.Select("id").First(&models.Branch{}, branchID).Error; err != nil {
+		return nil, fmt.Errorf("branch %d %w", branchID, ErrDataFixNotFound)
+	}
+
+	targets := []dataFixTarget{{AuditEntityEvent, eventID}}
+	if includeRelated {
+		var volunteerIDs, donationIDs []uint
+		if err := config.DB.Model(&models.Volunteer{}).Where("event_id = ?", eventID).Pluck("id", &volunteerIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := config.DB.Model(&models.Donation{}).Where("event_id = ?", eventID).Pluck("id", &donationIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range volunteerIDs {
+			targets = append(targets, dataFixTarget{AuditEntityVolunteer, id})
+		}
+		for _, id := range donationIDs {
+			targets = append(targets, dataFixTarget{AuditEntityDonation, id})
+		}
+	}
+
+	return runDataFix(DataFixReassignEventBranch, dryRun, actor, targets, func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.EventDetails{}).Where("id = ?", eventID).
+			Updates(map[string]interface{}{"branch_id": branchID, "updated_on": &now}).Error; err != nil {
+			return err
+		}
+		if !includeRelated {
+			return nil
+		}
+		if err := tx.Model(&models.Volunteer{}).Where("event_id = ?", eventID).
+			Updates(map[string]interface{}{"branch_id": branchID, "updated_on": &now}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Donation{}).Where("event_id = ?", eventID).
+			Update("branch_id", branchID).Error
+	})
+}
+
+// SwapMediaOwner moves an event media record to another event, or a branch media
+// record to another branch. mediaType is "event" or "branch".
+func SwapMediaOwner(mediaType string, mediaID, newOwnerID uint, dryRun bool, actor AuditActor) (*DataFixResult, error) {
+	switch mediaType {
+	case "event":
+		if err := config.DB.Select("id").First(&models.EventMedia{}, mediaID).Error; err != nil {
+			return nil, fmt.Errorf("event media %d %w", mediaID, ErrDataFixNotFound)
+		}
+		if err := config.DB.Select("id").First(&models.EventDetails{}, newOwnerID).Error; err != nil {
+			return nil, fmt.Errorf("event %d %w", newOwnerID, ErrDataFixNotFound)
+		}
+		return runDataFix(DataFixSwapMediaOwner, dryRun, actor, []dataFixTarget{{AuditEntityEventMedia, mediaID}}, func(tx *gorm.DB) error {
+			return tx.Model(&models.EventMedia{}).Where("id = ?", mediaID).Update("event_id", newOwnerID).Error
+		})
+	case "branch":
+		if err := config.DB.Select("id").First(&models.BranchMedia{}, mediaID).Error; err != nil {
+			return nil, fmt.Errorf("branch media %d %w", mediaID, ErrDataFixNotFound)
+		}
+		if err := config.DB.Select("id").First(&models.Branch{}, newOwnerID).Error; err != nil {
+			return nil, fmt.Errorf("branch %d %w", newOwnerID, ErrDataFixNotFound)
+		}
+		return runDataFix(DataFixSwapMediaOwner, dryRun, actor, []dataFixTarget{{AuditEntityBranchMedia, mediaID}}, func(tx *gorm.DB) error {
+			return tx.Model(&models.BranchMedia{}).Where("id = ?", mediaID).Update("branch_id", newOwnerID).Error
+		})
+	default:
+		return nil, fmt.Errorf("%w: media_type must be event or branch", ErrDataFixInvalidArgs)
+	}
+}
+
+// EventDateFix describes a bulk date correction: either set explicit dates
+// or shift the existing dates by a number of days
+type EventDateFix struct {
+	EventIDs  []uint
+	StartDate *time.Time
+	EndDate   *time.Time
+	ShiftDays int
+}
+
+// FixEventDates corrects start/end dates on a set of events
+func FixEventDates(fix EventDateFix, dryRun bool, actor AuditActor) (*DataFixResult, error) {
+	if len(fix.EventIDs) == 0 || len(fix.EventIDs) > maxDataFixEvents {
+		return nil, fmt.Errorf("%w: event_ids must contain between 1 and %d events", ErrDataFixInvalidArgs, maxDataFixEvents)
+	}
+	setDates := fix.StartDate != nil || fix.EndDate != nil
+	if setDates == (fix.ShiftDays != 0) {
+		return nil, fmt.Errorf("%w: provide either start_date/end_date or shift_days", ErrDataFixInvalidArgs)
+	}
+
+	var events []models.EventDetails
+	if err := config.DB.Select("id", "start_date", "end_date").Where("id IN ?", fix.EventIDs).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if len(events) != len(uniqueIDs(fix.EventIDs)) {
+		return nil, fmt.Errorf("%w: one or more events not found", ErrDataFixInvalidArgs)
+	}
+
+	targets := make([]dataFixTarget, 0, len(events))
+	for _, e := range events {
+		start, end := e.StartDate, e.EndDate
+		if setDates {
+			if fix.StartDate != nil {
+				start = *fix.StartDate
+			}
+			if fix.EndDate != nil {
+				end = *fix.EndDate
+			}
+		}
+		if !end.IsZero() && end.Before(start) {
+			return nil, fmt.Errorf("%w: end_date would be before start_date for event %d", ErrDataFixInvalidArgs, e.ID)
+		}
+		targets = append(targets, dataFixTarget{AuditEntityEvent, e.ID})
+	}
+
+	return runDataFix(DataFixEventDates, dryRun, actor, targets, func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Model(&models.EventDetails{}).Where("id IN ?", fix.EventIDs)
+		if fix.ShiftDays != 0 {
+			interval := fmt.Sprintf("%d days", fix.ShiftDays)
+			return query.Updates(map[string]interface{}{
+				"start_date": gorm.Expr("start_date + ?::interval", interval),
+				"end_date":   gorm.Expr("end_date + ?::interval", interval),
+				"updated_on": &now,
+			}).Error
+		}
+
+		updates := map[string]interface{}{"updated_on": &now}
+		if fix.StartDate != nil {
+			updates["start_date"] = *fix.StartDate
+		}
+		if fix.EndDate != nil {
+			updates["end_date"] = *fix.EndDate
+		}
+		return query.Updates(updates).Error
+	})
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
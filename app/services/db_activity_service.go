@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// ErrQueryNotRunning is returned when cancelling a backend that is not running a query on
+// this database (it finished in the meantime, or belongs to another database)
+var ErrQueryNotRunning = errors.New("no query running with this pid")
+
+// maxQueryText bounds the query text returned for running queries
+const maxQueryText = 2000
+
+// RunningQuery is a statement running on the database, from pg_stat_activity
+type RunningQuery struct {
+	PID             int       `json:"pid"`
+	User            string    `gorm:"column:usename" json:"user"`
+	ApplicationName string    `json:"application_name"`
+	ClientAddr      string    `json:"client_addr,omitempty"`
+	State           string    `json:"state"`
+	WaitEventType   string    `json:"wait_event_type,omitempty"`
+	WaitEvent       string    `json:"wait_event,omitempty"`
+	QueryStart      time.Time `json:"query_start"`
+	DurationMs      int64     `json:"duration_ms"`
+	Query           string    `json:"query"`
+}
+
+// ListRunningQueries returns the statements running on this database for at least
+// minDuration, longest first. The listing query itself is left out.
+func ListRunningQueries(minDuration time.Duration) ([]RunningQuery, error) {
+	queries := []RunningQuery{}
+	err := config.DB.Raw(`
+SELECT pid, COALESCE(usename, '') AS usename, COALESCE(application_name, '') AS application_name,
+       COALESCE(host(client_addr), '') AS client_addr, state,
+       COALESCE(wait_event_type, '') AS wait_event_type, COALESCE(wait_event, '') AS wait_event,
+       query_start, (EXTRACT(EPOCH FROM clock_timestamp() - query_start) * 1000)::bigint AS duration_ms,
+       LEFT(query, ?) AS query
+  FROM pg_stat_activity
+ WHERE datname = current_database()
+   AND pid <> pg_backend_pid()
+   AND state <> 'idle'
+   AND query_start IS NOT NULL
+   AND clock_timestamp() - query_start >= make_interval(secs => ?)
+ ORDER BY query_start`, maxQueryText, minDuration.Seconds()).
+		Scan(&queries).Error
+	return queries, err
+}
+
+// CancelQuery cancels the statement running on the backend pid (pg_cancel_backend). With
+// terminate the whole connection is closed instead (pg_terminate_backend), for sessions that
+// ignore the cancel, e.g. idle in a transaction holding locks.
+func CancelQuery(pid int, terminate bool) error {
+	fn := "pg_cancel_backend"
+	if terminate {
+		fn = "pg_terminate_backend"
+	}
+	var signalled []bool
+	err := config.DB.Raw(`
+SELECT `+fn+`(pid)
+  FROM pg_stat_activity
+ WHERE pid = ? AND datname = current_database() AND pid <> pg_backend_pid() AND state <> 'idle'`, pid).
+		Scan(&signalled).Error
+	if err != nil {
+		return err
+	}
+	if len(signalled) == 0 || !signalled[0] {
+		return ErrQueryNotRunning
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDonationNotFound = errors.New("donation not found")
+	// ErrDonationVoided is returned for changes to a donation whose receipt was cancelled
+	ErrDonationVoided = errors.New("donation receipt has been voided")
+	ErrInvalidVoid    = errors.New("invalid void request")
+)
+
+// AuditActionVoid marks the audit entry of a cancelled donation receipt
+const AuditActionVoid = "void"
+
+// maxVoidReasonLength bounds the reason printed on a voided receipt
+const maxVoidReasonLength = 500
+
+// defaultReceiptOrganization heads receipts unless RECEIPT_ORGANIZATION_NAME is set
+const defaultReceiptOrganization = "Divya Jyoti Jagrati Sansthan"
+
+// VoidDonationReceipt cancels the receipt of a donation. The receipt number stays allocated so
+// the branch's series has no gaps; the receipt is printed with a VOID stamp from then on and the
+// donation can no longer be edited. The void is audited against the donation.
+func VoidDonationReceipt(id uint, reason string, actor AuditActor) (*models.Donation, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidVoid)
+	}
+	if len([]rune(reason)) > maxVoidReasonLength {
+		return nil, fmt.Errorf("%w: reason must not exceed %d characters", ErrInvalidVoid, maxVoidReasonLength)
+	}
+
+	var donation models.Donation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&donation, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDonationNotFound
+			}
+			return err
+		}
+		if donation.VoidedOn != nil {
+			return ErrDonationVoided
+		}
+
+		before := loadAuditSnapshot(tx, AuditEntityDonation, donation.ID)
+		now := time.Now()
+		if err := tx.Model(&donation).Updates(map[string]interface{}{
+			"voided_on":   &now,
+			"voided_by":   actor.UserID,
+			"void_reason": reason,
+			"updated_on":  &now,
+		}).Error; err != nil {
+			return err
+		}
+		changes := DiffAuditSnapshots(before, loadAuditSnapshot(tx, AuditEntityDonation, donation.ID))
+		if err := tx.Create(actor.auditEntry(AuditEntityDonation, donation.ID, AuditActionVoid, changes, now)).Error; err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.First(&donation, id).Error; err != nil {
+		return nil, err
+	}
+	return &donation, nil
+}
+
+// GenerateDonationReceiptPDF renders the receipt of a donation. Donations recorded before
+// receipts were numbered get the next number of their branch's series for the financial year
+// they were recorded in.
+func GenerateDonationReceiptPDF(id uint) ([]byte, *models.Donation, error) {
+	donation, err := ensureReceiptNumber(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var event models.EventDetails
+	hasEvent := config.DB.Unscoped().Preload("EventType").First(&event, donation.EventID).Error == nil
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+
+	// Header: organization and branch
+	organization := config.Reporting.ReceiptOrganizationName
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(0, 9, tr(organization), "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(0, 6, tr(donation.Branch.Name), "", 1, "C", false, 0, "")
+	if donation.Branch.Address != "" {
+		pdf.SetTextColor(90, 90, 90)
+		pdf.MultiCell(0, 5, tr(joinNonEmpty(", ", donation.Branch.Address, donation.Branch.Pincode)), "", "C", false)
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Ln(4)
+	pdf.SetFont("Arial", "B", 13)
+	pdf.CellFormat(0, 8, "DONATION RECEIPT", "TB", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(85, 6, "Receipt No: "+donation.ReceiptNumber, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Date: "+donation.CreatedOn.Format("02 Jan 2006"), "", 1, "R", false, 0, "")
+	pdf.Ln(4)
+
+	// Donation details
+	amount := fmt.Sprintf("Rs. %.2f", donation.Amount)
+	if donation.Amount == 0 && donation.KindType != "" {
+		amount = "In kind"
+	}
+	eventName := ""
+	if hasEvent {
+		eventName = reportTitle(&event) + " (" + event.StartDate.Format("02 Jan 2006") + ")"
+	}
+	for _, field := range [][2]string{
+		{"Received From", donation.DonorName},
+		{"Donation Type", donation.DonationType},
+		{"Amount", amount},
+		{"In Kind", donation.KindType},
+		{"Event", eventName},
+		{"Remarks", donation.Remarks},
+	} {
+		reportField(pdf, tr, field[0], field[1])
+	}
+
+	if donation.VoidedOn != nil {
+		pdf.Ln(4)
+		reportField(pdf, tr, "Voided On", donation.VoidedOn.Format("02 Jan 2006 15:04"))
+		reportField(pdf, tr, "Void Reason", donation.VoidReason)
+
+		// Stamp across the receipt
+		pdf.SetFont("Arial", "B", 72)
+		pdf.SetTextColor(200, 30, 30)
+		pdf.SetAlpha(0.35, "Normal")
+		pdf.TransformBegin()
+		pdf.TransformRotate(30, 105, 110)
+		pdf.Text(60, 125, "VOID")
+		pdf.TransformEnd()
+		pdf.SetAlpha(1, "Normal")
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	pdf.SetY(-45)
+	pdf.SetFont("Arial", "", 9)
+	pdf.CellFormat(0, 6, "Authorised Signatory", "", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "I", 7)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.CellFormat(0, 5, "Generated on "+time.Now().Format("2006-01-02 15:04"), "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), donation, nil
+}
+
+// ensureReceiptNumber loads a donation with its branch, numbering it first if needed
+func ensureReceiptNumber(id uint) (*models.Donation, error) {
+	var donation models.Donation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&donation, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDonationNotFound
+			}
+			return err
+		}
+		if donation.ReceiptNumber != "" {
+			return nil
+		}
+		if err := donation.AssignReceiptNumber(tx, donation.CreatedOn); err != nil {
+			return err
+		}
+		return tx.Model(&donation).UpdateColumn("receipt_number", donation.ReceiptNumber).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.Unscoped().Select("id", "name", "address", "pincode").
+		First(&donation.Branch, donation.BranchID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &donation, nil
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// maxDonationReportRows caps the rows of a donation summary or reconciliation report
+const maxDonationReportRows = 5000
+
+// donationReconciliationTolerance absorbs rounding when comparing declared and itemized totals
+const donationReconciliationTolerance = 0.005
+
+// Reconciliation statuses
+const (
+	ReconciliationMatched     = "matched"
+	ReconciliationMismatch    = "mismatch"
+	ReconciliationNotDeclared = "not_declared" // itemized donations but no declared total
+)
+
+// ErrInvalidDonationReport is returned for unknown grouping or status filters
+var ErrInvalidDonationReport = errors.New("invalid donation report")
+
+// donationGroupings are the dimensions a donation summary can be grouped by
+var donationGroupings = map[string]struct {
+	selects []string
+	groups  []string
+	order   string
+}{
+	"event":  {[]string{"e.id AS event_id", "e.report_number"}, []string{"e.id", "e.report_number"}, "e.id"},
+	"branch": {[]string{"d.branch_id", "b.name AS branch_name"}, []string{"d.branch_id", "b.name"}, "b.name"},
+	"type":   {[]string{dashboardDonationTypeSQL + " AS donation_type"}, []string{dashboardDonationTypeSQL}, "donation_type"},
+	"month":  {[]string{dashboardMonthSQL + " AS month"}, []string{dashboardMonthSQL}, "month"},
+}
+
+// DonationSummaryRow is one group of a donation summary; only the grouped fields are set
+type DonationSummaryRow struct {
+	EventID      *uint   `json:"event_id,omitempty"`
+	ReportNumber *string `json:"report_number,omitempty"`
+	BranchID     *uint   `json:"branch_id,omitempty"`
+	BranchName   *string `json:"branch_name,omitempty"`
+	DonationType *string `json:"donation_type,omitempty"`
+	Month        *string `json:"month,omitempty"` // YYYY-MM of the event start date
+	Count        int64   `json:"count"`
+	Amount       float64 `json:"amount"`
+}
+
+// DonationSummary is the response of GET /donations/summary
+type DonationSummary struct {
+	GroupBy   []string             `json:"group_by"`
+	Rows      []DonationSummaryRow `json:"rows"`
+	Count     int64                `json:"count"`
+	Amount    float64              `json:"amount"`
+	Truncated bool                 `json:"truncated"` // more than maxDonationReportRows groups
+}
+
+// DonationReconciliationRow compares the declared donation total of an event with its
+// itemized donations
+type DonationReconciliationRow struct {
+	EventID       uint      `json:"event_id"`
+	ReportNumber  string    `json:"report_number,omitempty"`
+	BranchID      *uint     `json:"branch_id,omitempty"`
+	BranchName    string    `json:"branch_name,omitempty"`
+	StartDate     time.Time `json:"start_date"`
+	DeclaredTotal *float64  `json:"declared_total"`
+	ItemizedTotal float64   `json:"itemized_total"`
+	ItemizedCount int64     `json:"itemized_count"`
+	Difference    float64   `json:"difference"` // declared - itemized
+	Status        string    `json:"status"`
+}
+
+// DonationReconciliation is the response of GET /donations/reconciliation. The counts cover
+// the returned rows.
+type DonationReconciliation struct {
+	Rows        []DonationReconciliationRow `json:"rows"`
+	Matched     int                         `json:"matched"`
+	Mismatched  int                         `json:"mismatched"`
+	NotDeclared int                         `json:"not_declared"`
+	Truncated   bool                        `json:"truncated"`
+}
+
+// GetDonationSummary totals donations grouped by any of event, branch, type and month (of the
+// event start date), largest amount first. Voided receipts, soft-deleted donations and sandbox
+// branches are left out; archived financial years are included. donationType narrows the
+// donations to one type (case-insensitive).
+func GetDonationSummary(filter DashboardFilter, groupBy []string, donationType string) (*DonationSummary, error) {
+	query, err := donationReportQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	if donationType != "" {
+		query = query.Where("LOWER(d.donation_type) = LOWER(?)", donationType)
+	}
+
+	summary := &DonationSummary{GroupBy: []string{}, Rows: []DonationSummaryRow{}}
+	selects := []string{"COUNT(*) AS count", "COALESCE(SUM(d.amount), 0)::float8 AS amount"}
+	var groups, order []string
+	seen := map[string]bool{}
+	for _, name := range groupBy {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		grouping, ok := donationGroupings[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: group_by must be a list of event, branch, type, month", ErrInvalidDonationReport)
+		}
+		seen[name] = true
+		summary.GroupBy = append(summary.GroupBy, name)
+		selects = append(selects, grouping.selects...)
+		groups = append(groups, grouping.groups...)
+		order = append(order, grouping.order)
+	}
+	if seen["branch"] {
+		query = query.Joins("LEFT JOIN branches b ON b.id = d.branch_id")
+	}
+
+	query = query.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", ")).
+			Order("amount DESC, " + strings.Join(order, ", ")).
+			Limit(maxDonationReportRows + 1)
+	}
+	if err := query.Scan(&summary.Rows).Error; err != nil {
+		return nil, err
+	}
+	if len(summary.Rows) > maxDonationReportRows {
+		summary.Rows = summary.Rows[:maxDonationReportRows]
+		summary.Truncated = true
+	}
+	for _, row := range summary.Rows {
+		summary.Count += row.Count
+		summary.Amount += row.Amount
+	}
+	return summary, nil
+}
+
+// GetDonationReconciliation compares, per event, the declared donation total with the sum of
+// the itemized donations (voided receipts excluded). Events with neither are left out. status
+// narrows the rows to matched, mismatch or not_declared.
+func GetDonationReconciliation(filter DashboardFilter, status string) (*DonationReconciliation, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	const itemizedSQL = "COALESCE(SUM(d.amount), 0)"
+	query := config.DB.Table("event_details e").
+		Joins("LEFT JOIN donations d ON d.event_id = e.id AND d.deleted_at IS NULL AND d.voided_on IS NULL").
+		Joins("LEFT JOIN branches b ON b.id = e.branch_id").
+		Where("e.deleted_at IS NULL")
+	query = excludeSandbox(query, "e.branch_id")
+	if branchIDs != nil {
+		query = query.Where("e.branch_id IN ?", branchIDs)
+	}
+	if filter.From != nil {
+		query = query.Where("e.start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("e.start_date <= ?", *filter.To)
+	}
+	query = query.Group("e.id, b.name")
+	switch status {
+	case "":
+		query = query.Having("e.declared_donation_total IS NOT NULL OR COUNT(d.id) > 0")
+	case ReconciliationMatched:
+		query = query.Having("e.declared_donation_total IS NOT NULL AND ABS(e.declared_donation_total - "+itemizedSQL+") <= ?", donationReconciliationTolerance)
+	case ReconciliationMismatch:
+		query = query.Having("e.declared_donation_total IS NOT NULL AND ABS(e.declared_donation_total - "+itemizedSQL+") > ?", donationReconciliationTolerance)
+	case ReconciliationNotDeclared:
+		query = query.Having("e.declared_donation_total IS NULL AND COUNT(d.id) > 0")
+	default:
+		return nil, fmt.Errorf("%w: status must be matched, mismatch or not_declared", ErrInvalidDonationReport)
+	}
+
+	var rows []DonationReconciliationRow
+	if err := query.Select(`e.id AS event_id, e.report_number, e.branch_id, b.name AS branch_name, e.start_date,
+			e.declared_donation_total::float8 AS declared_total,
+			` + itemizedSQL + `::float8 AS itemized_total, COUNT(d.id) AS itemized_count`).
+		Order("e.start_date, e.id").
+		Limit(maxDonationReportRows + 1).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	report := &DonationReconciliation{Rows: []DonationReconciliationRow{}}
+	if len(rows) > maxDonationReportRows {
+		rows = rows[:maxDonationReportRows]
+		report.Truncated = true
+	}
+	for _, row := range rows {
+		switch {
+		case row.DeclaredTotal == nil:
+			row.Difference = -row.ItemizedTotal
+			row.Status = ReconciliationNotDeclared
+			report.NotDeclared++
+		default:
+			row.Difference = *row.DeclaredTotal - row.ItemizedTotal
+			if row.Difference <= donationReconciliationTolerance && row.Difference >= -donationReconciliationTolerance {
+				row.Status = ReconciliationMatched
+				report.Matched++
+			} else {
+				row.Status = ReconciliationMismatch
+				report.Mismatched++
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// donationReportQuery selects the donations (alias d) of live events (alias e) matching the
+// filter, archived financial years included
+func donationReportQuery(filter DashboardFilter) (*gorm.DB, error) {
+	branchIDs, err := dashboardBranchIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := config.DB.Table("donations d").
+		Joins("JOIN event_details e ON e.id = d.event_id AND e.deleted_at IS NULL").
+		Where("d.deleted_at IS NULL AND d.voided_on IS NULL")
+	query = excludeSandbox(query, "d.branch_id")
+	if branchIDs != nil {
+		query = query.Where("d.branch_id IN ?", branchIDs)
+	}
+	if filter.From != nil {
+		query = query.Where("e.start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("e.start_date <= ?", *filter.To)
+	}
+	return query, nil
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 )
 
@@ -18,10 +20,27 @@ func CreateDonation(donation *models.Donation) error {
 	return nil
 }
 
-// GetAllDonations retrieves all donation entries
-func GetAllDonations() ([]models.Donation, error) {
+// DonationListSchema is what GET /donations may be sorted and filtered by (see listquery).
+// Without a sort the newest donations come first.
+var DonationListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":             {Column: "id", Type: listquery.Int, Sortable: true},
+	"event_id":       {Column: "event_id", Type: listquery.Int},
+	"branch_id":      {Column: "branch_id", Type: listquery.Int},
+	"donation_type":  {Column: "donation_type", Type: listquery.String, Sortable: true},
+	"kind_type":      {Column: "kind_type", Type: listquery.String},
+	"amount":         {Column: "amount", Type: listquery.Int, Sortable: true},
+	"donor_name":     {Column: "donor_name", Type: listquery.String, Sortable: true},
+	"receipt_number": {Column: "receipt_number", Type: listquery.String, Sortable: true},
+	"voided_on":      {Column: "voided_on", Type: listquery.Time},
+	"created_on":     {Column: "created_on", Type: listquery.Time, Sortable: true},
+	"updated_on":     {Column: "updated_on", Type: listquery.Time, Sortable: true},
+}, listquery.Sort{Field: "created_on", Desc: true})
+
+// GetAllDonations retrieves the donation entries of the scope's branches, sorted and filtered by
+// a query parsed with DonationListSchema
+func GetAllDonations(query *listquery.Query, includeDeleted bool, scope *BranchScope) ([]models.Donation, error) {
 	var donations []models.Donation
-	if err := config.DB.Find(&donations).Error; err != nil {
+	if err := query.Apply(scope.Apply(withDeleted(config.DB, includeDeleted), "branch_id")).Find(&donations).Error; err != nil {
 		return nil, err
 	}
 	return donations, nil
@@ -47,9 +66,15 @@ func UpdateDonation(id uint, updateData map[string]interface{}) error {
 	if err := config.DB.First(&donation, id).Error; err != nil {
 		return errors.New("donation not found")
 	}
+	if donation.VoidedOn != nil {
+		return ErrDonationVoided
+	}
 
 	now := time.Now()
 	updateData["updated_on"] = &now
+	if donorName, ok := updateData["donor_name"].(string); ok {
+		updateData["donor_name_key"] = utils.NameKey(donorName)
+	}
 
 	if err := config.DB.Model(&donation).Updates(updateData).Error; err != nil {
 		return err
@@ -65,3 +90,48 @@ func DeleteDonation(id uint) error {
 	}
 	return nil
 }
+
+// RestoreDonation restores a soft-deleted donation
+func RestoreDonation(id uint) error {
+	return restoreSoftDeleted(config.DB, &models.Donation{}, id)
+}
+
+// AttachDonationReceipt stores the uploaded receipt key on a donation and clears stale OCR text
+func AttachDonationReceipt(id uint, s3Key string) error {
+	var donation models.Donation
+
+	if err := config.DB.First(&donation, id).Error; err != nil {
+		return errors.New("donation not found")
+	}
+
+	now := time.Now()
+	return config.DB.Model(&donation).Updates(map[string]interface{}{
+		"receipt_s3_key": s3Key,
+		"ocr_text":       "",
+		"updated_on":     &now,
+	}).Error
+}
+
+// SearchDonations searches the donations of the scope's branches by donor name, remarks and OCR
+// text extracted from receipts, e.g. "Sharma Caterers" matches a receipt whose scanned text
+// contains that name
+func SearchDonations(searchTerm string, scope *BranchScope) ([]models.Donation, error) {
+	var donations []models.Donation
+
+	like := "%" + searchTerm + "%"
+	matches := config.DB.Where(
+		"to_tsvector('simple', coalesce(remarks, '') || ' ' || coalesce(ocr_text, '')) @@ plainto_tsquery('simple', ?) "+
+			"OR remarks ILIKE ? OR ocr_text ILIKE ? OR donation_type ILIKE ? OR kind_type ILIKE ? OR donor_name ILIKE ?",
+		searchTerm, like, like, like, like, like,
+	)
+	if key := utils.NameKey(searchTerm); key != "" {
+		matches = matches.Or("donor_name_key LIKE ?", "%"+key+"%")
+	}
+	query := scope.Apply(config.DB.Where(matches), "branch_id")
+
+	if err := query.Order("created_on DESC").Limit(50).Find(&donations).Error; err != nil {
+		return nil, err
+	}
+
+	return donations, nil
+}
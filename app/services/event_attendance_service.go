@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidAttendance is returned for attendance outside the event's dates or with invalid
+	// counts
+	ErrInvalidAttendance = errors.New("invalid attendance")
+	// ErrAttendanceNotFound is returned when a day of an event has no attendance
+	ErrAttendanceNotFound = errors.New("no attendance recorded for the day")
+)
+
+// AttendanceBandInput is the attendance of one age band
+type AttendanceBandInput struct {
+	AgeBand string `json:"age_band" binding:"required"`
+	Male    int    `json:"male"`
+	Female  int    `json:"female"`
+	New     int    `json:"new"` // first-time attendees among male and female
+}
+
+// EventAttendanceDayInput is the body of PUT /events/:event_id/attendance/:day. It replaces the
+// attendance of the day: age bands left out are removed.
+type EventAttendanceDayInput struct {
+	Bands []AttendanceBandInput `json:"bands" binding:"required"`
+}
+
+// AttendanceTotals adds up attendance
+type AttendanceTotals struct {
+	Male      int            `json:"male"`
+	Female    int            `json:"female"`
+	Total     int            `json:"total"`
+	New       int            `json:"new"`
+	Returning int            `json:"returning"`
+	ByAgeBand map[string]int `json:"by_age_band"`
+}
+
+func (t *AttendanceTotals) add(row models.EventAttendance) {
+	if t.ByAgeBand == nil {
+		t.ByAgeBand = map[string]int{}
+	}
+	t.Male += row.Male
+	t.Female += row.Female
+	t.Total += row.Male + row.Female
+	t.New += row.New
+	t.Returning += row.Male + row.Female - row.New
+	t.ByAgeBand[row.AgeBand] += row.Male + row.Female
+}
+
+// EventAttendanceDay is the attendance of one day of an event
+type EventAttendanceDay struct {
+	Day    string                   `json:"day"` // YYYY-MM-DD
+	Bands  []models.EventAttendance `json:"bands"`
+	Totals AttendanceTotals         `json:"totals"`
+}
+
+// EventAttendanceSummary is the response of the attendance endpoints. Totals add up the days,
+// so someone attending three days of an event counts three times, as in the beneficiary figures.
+type EventAttendanceSummary struct {
+	EventID uint                 `json:"event_id"`
+	Days    []EventAttendanceDay `json:"days"`
+	Totals  AttendanceTotals     `json:"totals"`
+}
+
+// GetEventAttendance returns the attendance of an event by day, oldest first
+func GetEventAttendance(eventID uint) (*EventAttendanceSummary, error) {
+	var event models.EventDetails
+	if err := config.DB.Select("id").First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+
+	var rows []models.EventAttendance
+	if err := config.DB.Where("event_id = ?", eventID).Order("day").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	bandOrder := make(map[string]int, len(models.AgeBands))
+	for i, band := range models.AgeBands {
+		bandOrder[band] = i
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Day.Equal(rows[j].Day) {
+			return rows[i].Day.Before(rows[j].Day)
+		}
+		return bandOrder[rows[i].AgeBand] < bandOrder[rows[j].AgeBand]
+	})
+
+	summary := &EventAttendanceSummary{
+		EventID: eventID,
+		Days:    []EventAttendanceDay{},
+		Totals:  AttendanceTotals{ByAgeBand: map[string]int{}},
+	}
+	for _, row := range rows {
+		day := row.Day.Format(calendarDateLayout)
+		if n := len(summary.Days); n == 0 || summary.Days[n-1].Day != day {
+			summary.Days = append(summary.Days, EventAttendanceDay{Day: day, Totals: AttendanceTotals{ByAgeBand: map[string]int{}}})
+		}
+		current := &summary.Days[len(summary.Days)-1]
+		current.Bands = append(current.Bands, row)
+		current.Totals.add(row)
+		summary.Totals.add(row)
+	}
+	return summary, nil
+}
+
+// SetEventAttendanceDay records the attendance of one day of an event, which must fall within
+// the event's dates, and recomputes the event's beneficiary figures from all of its days: the
+// child band counts as children, the other bands as men and women.
+func SetEventAttendanceDay(eventID uint, date string, input EventAttendanceDayInput, userID *uint) (*EventAttendanceSummary, error) {
+	var event models.EventDetails
+	if err := config.DB.Select("id", "start_date", "end_date").First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	day, err := attendanceDay(date)
+	if err != nil {
+		return nil, err
+	}
+	start, end := calendarDate(event.StartDate), calendarDate(event.EndDate)
+	if end.Before(start) {
+		end = start
+	}
+	if day.Before(start) || day.After(end) {
+		return nil, fmt.Errorf("%w: day must be between the event's start date %s and end date %s",
+			ErrInvalidAttendance, start.Format(calendarDateLayout), end.Format(calendarDateLayout))
+	}
+
+	if len(input.Bands) == 0 {
+		return nil, fmt.Errorf("%w: at least one age band is required", ErrInvalidAttendance)
+	}
+	valid := make(map[string]bool, len(models.AgeBands))
+	for _, band := range models.AgeBands {
+		valid[band] = true
+	}
+	now := time.Now()
+	rows := make([]models.EventAttendance, len(input.Bands))
+	bands := make([]string, len(input.Bands))
+	seen := map[string]bool{}
+	for i, band := range input.Bands {
+		switch {
+		case !valid[band.AgeBand]:
+			return nil, fmt.Errorf("%w: age_band must be one of child, youth, adult, senior", ErrInvalidAttendance)
+		case seen[band.AgeBand]:
+			return nil, fmt.Errorf("%w: age band %s is given twice", ErrInvalidAttendance, band.AgeBand)
+		case band.Male < 0 || band.Female < 0 || band.New < 0:
+			return nil, fmt.Errorf("%w: counts must not be negative", ErrInvalidAttendance)
+		case band.New > band.Male+band.Female:
+			return nil, fmt.Errorf("%w: new attendees of %s exceed its attendance", ErrInvalidAttendance, band.AgeBand)
+		}
+		seen[band.AgeBand] = true
+		bands[i] = band.AgeBand
+		rows[i] = models.EventAttendance{
+			EventID:   eventID,
+			Day:       day,
+			AgeBand:   band.AgeBand,
+			Male:      band.Male,
+			Female:    band.Female,
+			New:       band.New,
+			CreatedBy: userID,
+			UpdatedOn: &now,
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND day = ? AND age_band NOT IN ?", eventID, day, bands).
+			Delete(&models.EventAttendance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "event_id"}, {Name: "day"}, {Name: "age_band"}},
+			DoUpdates: clause.AssignmentColumns([]string{"male", "female", "new_attendees", "updated_on"}),
+		}).Create(&rows).Error; err != nil {
+			return err
+		}
+		return totalEventAttendance(tx, eventID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetEventAttendance(eventID)
+}
+
+// DeleteEventAttendanceDay removes the attendance of one day of an event and recomputes the
+// event's beneficiary figures. Once no day is left, the figures are kept as they were.
+func DeleteEventAttendanceDay(eventID uint, date string) (*EventAttendanceSummary, error) {
+	day, err := attendanceDay(date)
+	if err != nil {
+		return nil, err
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("event_id = ? AND day = ?", eventID, day).Delete(&models.EventAttendance{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAttendanceNotFound
+		}
+		return totalEventAttendance(tx, eventID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetEventAttendance(eventID)
+}
+
+func attendanceDay(date string) (time.Time, error) {
+	day, err := time.Parse(calendarDateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: day must be a date (YYYY-MM-DD)", ErrInvalidAttendance)
+	}
+	return day, nil
+}
+
+// totalEventAttendance writes the attendance totals of an event to its beneficiary figures,
+// bumping its version so that edits based on the old figures conflict
+func totalEventAttendance(tx *gorm.DB, eventID uint) error {
+	var totals struct {
+		Bands int64
+		Men   int
+		Women int
+		Child int
+	}
+	err := tx.Model(&models.EventAttendance{}).
+		Select(`COUNT(*) AS bands,
+			COALESCE(SUM(male) FILTER (WHERE age_band <> ?), 0) AS men,
+			COALESCE(SUM(female) FILTER (WHERE age_band <> ?), 0) AS women,
+			COALESCE(SUM(male + female) FILTER (WHERE age_band = ?), 0) AS child`,
+			models.AgeBandChild, models.AgeBandChild, models.AgeBandChild).
+		Where("event_id = ?", eventID).
+		Scan(&totals).Error
+	if err != nil || totals.Bands == 0 {
+		return err
+	}
+	now := time.Now()
+	_, err = updateVersioned(tx, &models.EventDetails{ID: eventID}, nil, map[string]interface{}{
+		"beneficiary_men":   totals.Men,
+		"beneficiary_women": totals.Women,
+		"beneficiary_child": totals.Child,
+		"updated_on":        &now,
+	})
+	return err
+}
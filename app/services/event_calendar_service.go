@@ -0,0 +1,363 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxCalendarDays caps the window of one calendar request
+const MaxCalendarDays = 366
+
+// maxRecurrenceInterval caps the interval of a series (every n weeks or months)
+const maxRecurrenceInterval = 52
+
+const calendarDateLayout = "2006-01-02"
+
+var (
+	// ErrInvalidCalendarRange is returned for malformed or too long calendar windows
+	ErrInvalidCalendarRange = errors.New("invalid calendar range")
+	// ErrInvalidRecurrence is returned for invalid recurrence rules
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	// ErrRecurrenceNotFound is returned when an event does not repeat
+	ErrRecurrenceNotFound = errors.New("event has no recurrence")
+)
+
+// CalendarEvent is one occurrence of an event on the calendar. One-off events have a single
+// occurrence; recurring ones carry their series and the number of the occurrence in it.
+type CalendarEvent struct {
+	EventID        uint             `json:"event_id"`
+	Theme          string           `json:"theme,omitempty"`
+	EventType      string           `json:"event_type,omitempty"`
+	EventCategory  string           `json:"event_category,omitempty"`
+	BranchID       *uint            `json:"branch_id,omitempty"`
+	BranchName     string           `json:"branch_name,omitempty"`
+	Status         string           `json:"status,omitempty"`
+	ApprovalStatus string           `json:"approval_status,omitempty"`
+	StartDate      string           `json:"start_date"` // of this occurrence, YYYY-MM-DD
+	EndDate        string           `json:"end_date"`
+	DailyStartTime *models.TimeOnly `json:"daily_start_time,omitempty"`
+	DailyEndTime   *models.TimeOnly `json:"daily_end_time,omitempty"`
+	RecurrenceID   *uint            `json:"recurrence_id,omitempty"`
+	Occurrence     int              `json:"occurrence,omitempty"` // 1 for the event itself
+}
+
+// CalendarDay lists the occurrences taking place on a day; multi-day events appear on each
+// of their days
+type CalendarDay struct {
+	Date   string          `json:"date"`
+	Events []CalendarEvent `json:"events"`
+}
+
+// EventCalendar is the response of GET /events/calendar: the days of the window that have
+// events, in order
+type EventCalendar struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Days []CalendarDay `json:"days"`
+}
+
+// calendarDate drops the time of day, keeping the date as it was entered
+func calendarDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseCalendarRange parses the from/to dates (YYYY-MM-DD, inclusive) of a calendar request
+func ParseCalendarRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse(calendarDateLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a date (YYYY-MM-DD)", ErrInvalidCalendarRange)
+	}
+	end, err := time.Parse(calendarDateLayout, to)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a date (YYYY-MM-DD)", ErrInvalidCalendarRange)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must not be before from", ErrInvalidCalendarRange)
+	}
+	if end.Sub(start) >= MaxCalendarDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: at most %d days", ErrInvalidCalendarRange, MaxCalendarDays)
+	}
+	return start, end, nil
+}
+
+// eventSpan is the first and last day of an event; events without an end date last one day
+func eventSpan(event *models.EventDetails) (time.Time, time.Time) {
+	start := calendarDate(event.StartDate)
+	end := calendarDate(event.EndDate)
+	if end.Before(start) {
+		end = start
+	}
+	return start, end
+}
+
+// GetEventCalendar returns the events taking place between from and to (inclusive) bucketed by
+// day, with recurring series expanded into their occurrences. branchID optionally limits it to
+// one branch.
+func GetEventCalendar(from, to time.Time, branchID *uint, scope *BranchScope) (*EventCalendar, error) {
+	calendar := &EventCalendar{From: from.Format(calendarDateLayout), To: to.Format(calendarDateLayout), Days: []CalendarDay{}}
+	days := make(map[string][]CalendarEvent)
+	add := func(event *models.EventDetails, start, end time.Time, recurrence *models.EventRecurrence, occurrence int) {
+		entry := CalendarEvent{
+			EventID:        event.ID,
+			Theme:          event.Theme,
+			EventType:      event.EventType.Name,
+			EventCategory:  event.EventCategory.Name,
+			BranchID:       event.BranchID,
+			Status:         event.Status,
+			ApprovalStatus: event.ApprovalStatus,
+			StartDate:      start.Format(calendarDateLayout),
+			EndDate:        end.Format(calendarDateLayout),
+			DailyStartTime: event.DailyStartTime,
+			DailyEndTime:   event.DailyEndTime,
+		}
+		if event.Branch != nil {
+			entry.BranchName = event.Branch.Name
+		}
+		if recurrence != nil {
+			entry.RecurrenceID = &recurrence.ID
+			entry.Occurrence = occurrence
+		}
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			if !day.Before(from) && !day.After(to) {
+				key := day.Format(calendarDateLayout)
+				days[key] = append(days[key], entry)
+			}
+		}
+	}
+	branchName := func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }
+
+	// One-off events overlapping the window
+	var events []models.EventDetails
+	query := config.DB.Preload("EventType").Preload("EventCategory").Preload("Branch", branchName).
+		Where("start_date < ? AND (end_date >= ? OR start_date >= ?)", to.AddDate(0, 0, 1), from, from).
+		Where("id NOT IN (?)", config.DB.Model(&models.EventRecurrence{}).Select("event_id"))
+	if branchID != nil {
+		query = query.Where("branch_id = ?", *branchID)
+	}
+	query = scope.Apply(query, "branch_id")
+	if err := query.Order("start_date, id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for i := range events {
+		start, end := eventSpan(&events[i])
+		add(&events[i], start, end, nil, 0)
+	}
+
+	// Series that started before the window ends and have not ended before it starts (with a
+	// window of slack for multi-day occurrences that run into it)
+	var recurrences []models.EventRecurrence
+	query = config.DB.
+		Joins("JOIN event_details ON event_details.id = event_recurrences.event_id AND event_details.deleted_at IS NULL").
+		Where("event_details.start_date < ?", to.AddDate(0, 0, 1)).
+		Where("event_recurrences.until IS NULL OR event_recurrences.until >= ?", from.AddDate(0, 0, -MaxCalendarDays))
+	if branchID != nil {
+		query = query.Where("event_details.branch_id = ?", *branchID)
+	}
+	query = scope.Apply(query, "event_details.branch_id")
+	if err := query.Preload("Event").Preload("Event.EventType").Preload("Event.EventCategory").
+		Preload("Event.Branch", branchName).
+		Order("event_recurrences.id").Find(&recurrences).Error; err != nil {
+		return nil, err
+	}
+	for i := range recurrences {
+		recurrence := &recurrences[i]
+		if recurrence.Event == nil {
+			continue
+		}
+		start, end := eventSpan(recurrence.Event)
+		length := int(end.Sub(start).Hours() / 24)
+		expandRecurrence(recurrence, start, to, func(day time.Time, occurrence int) {
+			add(recurrence.Event, day, day.AddDate(0, 0, length), recurrence, occurrence)
+		})
+	}
+
+	for date, entries := range days {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].StartDate != entries[j].StartDate {
+				return entries[i].StartDate < entries[j].StartDate
+			}
+			return entries[i].EventID < entries[j].EventID
+		})
+		calendar.Days = append(calendar.Days, CalendarDay{Date: date, Events: entries})
+	}
+	sort.Slice(calendar.Days, func(i, j int) bool { return calendar.Days[i].Date < calendar.Days[j].Date })
+	return calendar, nil
+}
+
+// expandRecurrence calls fn with the start day and number of each occurrence of a series that
+// starts on first, up to and including last. Occurrences on except dates are skipped but still
+// count towards Count, so removing one date does not push the series end out.
+func expandRecurrence(r *models.EventRecurrence, first, last time.Time, fn func(day time.Time, occurrence int)) {
+	if r.Until != nil && calendarDate(*r.Until).Before(last) {
+		last = calendarDate(*r.Until)
+	}
+	except := make(map[string]bool, len(r.ExceptDates))
+	for _, date := range r.ExceptDates {
+		except[date] = true
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	occurrence := 0
+	emit := func(day time.Time) bool {
+		if day.Before(first) {
+			return true
+		}
+		if day.After(last) || (r.Count != nil && occurrence >= *r.Count) {
+			return false
+		}
+		occurrence++
+		if !except[day.Format(calendarDateLayout)] {
+			fn(day, occurrence)
+		}
+		return true
+	}
+
+	switch r.Frequency {
+	case models.RecurrenceWeekly:
+		weekdays := append([]int(nil), r.Weekdays...)
+		if len(weekdays) == 0 {
+			weekdays = []int{int(first.Weekday())}
+		}
+		sort.Ints(weekdays)
+		sunday := first.AddDate(0, 0, -int(first.Weekday()))
+		for week := sunday; !week.After(last); week = week.AddDate(0, 0, 7*interval) {
+			for _, weekday := range weekdays {
+				if !emit(week.AddDate(0, 0, weekday)) {
+					return
+				}
+			}
+		}
+	case models.RecurrenceMonthly:
+		for month := 0; ; month += interval {
+			day := time.Date(first.Year(), first.Month()+time.Month(month), first.Day(), 0, 0, 0, 0, time.UTC)
+			if day.Day() != first.Day() {
+				// The month is too short (e.g. the 31st); there is no occurrence in it
+				if day.After(last) {
+					return
+				}
+				continue
+			}
+			if !emit(day) {
+				return
+			}
+		}
+	}
+}
+
+// EventRecurrenceInput is the body of PUT /events/:event_id/recurrence
+type EventRecurrenceInput struct {
+	Frequency   string   `json:"frequency" binding:"required"`
+	Interval    int      `json:"interval"`
+	Weekdays    []int    `json:"weekdays"`
+	Until       string   `json:"until"` // YYYY-MM-DD
+	Count       *int     `json:"count"`
+	ExceptDates []string `json:"except_dates"`
+}
+
+// GetEventRecurrence returns the recurrence of an event
+func GetEventRecurrence(eventID uint) (*models.EventRecurrence, error) {
+	var recurrence models.EventRecurrence
+	if err := config.DB.Where("event_id = ?", eventID).First(&recurrence).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecurrenceNotFound
+		}
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+// SetEventRecurrence makes an event the first occurrence of a series, or replaces the rule of
+// its series
+func SetEventRecurrence(eventID uint, input EventRecurrenceInput, userID *uint) (*models.EventRecurrence, error) {
+	var event models.EventDetails
+	if err := config.DB.Select("id", "start_date", "end_date").First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+
+	recurrence := models.EventRecurrence{
+		EventID:     eventID,
+		Frequency:   input.Frequency,
+		Interval:    input.Interval,
+		Weekdays:    input.Weekdays,
+		Count:       input.Count,
+		ExceptDates: input.ExceptDates,
+		CreatedBy:   userID,
+	}
+	if recurrence.Interval == 0 {
+		recurrence.Interval = 1
+	}
+	if recurrence.Weekdays == nil {
+		recurrence.Weekdays = []int{}
+	}
+	if recurrence.ExceptDates == nil {
+		recurrence.ExceptDates = []string{}
+	}
+
+	switch {
+	case recurrence.Frequency != models.RecurrenceWeekly && recurrence.Frequency != models.RecurrenceMonthly:
+		return nil, fmt.Errorf("%w: frequency must be weekly or monthly", ErrInvalidRecurrence)
+	case recurrence.Interval < 1 || recurrence.Interval > maxRecurrenceInterval:
+		return nil, fmt.Errorf("%w: interval must be between 1 and %d", ErrInvalidRecurrence, maxRecurrenceInterval)
+	case recurrence.Frequency == models.RecurrenceMonthly && len(recurrence.Weekdays) > 0:
+		return nil, fmt.Errorf("%w: weekdays only apply to weekly series", ErrInvalidRecurrence)
+	case recurrence.Count != nil && *recurrence.Count < 1:
+		return nil, fmt.Errorf("%w: count must be at least 1", ErrInvalidRecurrence)
+	}
+	seen := make(map[int]bool, len(recurrence.Weekdays))
+	for _, weekday := range recurrence.Weekdays {
+		if weekday < 0 || weekday > 6 || seen[weekday] {
+			return nil, fmt.Errorf("%w: weekdays must be distinct days 0 (Sunday) to 6", ErrInvalidRecurrence)
+		}
+		seen[weekday] = true
+	}
+	for _, date := range recurrence.ExceptDates {
+		if _, err := time.Parse(calendarDateLayout, date); err != nil {
+			return nil, fmt.Errorf("%w: except_dates must be dates (YYYY-MM-DD)", ErrInvalidRecurrence)
+		}
+	}
+	if input.Until != "" {
+		until, err := time.Parse(calendarDateLayout, input.Until)
+		if err != nil {
+			return nil, fmt.Errorf("%w: until must be a date (YYYY-MM-DD)", ErrInvalidRecurrence)
+		}
+		if until.Before(calendarDate(event.StartDate)) {
+			return nil, fmt.Errorf("%w: until must not be before the event's start date", ErrInvalidRecurrence)
+		}
+		recurrence.Until = &until
+	}
+
+	now := time.Now()
+	recurrence.UpdatedOn = &now
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"frequency", "interval", "weekdays", "until", "count", "except_dates", "updated_on"}),
+	}).Create(&recurrence).Error; err != nil {
+		return nil, err
+	}
+	return GetEventRecurrence(eventID)
+}
+
+// DeleteEventRecurrence ends a series; the event itself stays as a one-off event
+func DeleteEventRecurrence(eventID uint) error {
+	result := config.DB.Where("event_id = ?", eventID).Delete(&models.EventRecurrence{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecurrenceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// MaxComparisonYears caps the financial years of one comparison
+const MaxComparisonYears = 10
+
+// defaultComparisonYears is how many financial years, up to the current one, are compared
+// when none are requested
+const defaultComparisonYears = 3
+
+// ErrInvalidComparison is returned for comparisons without an event type or category, or with
+// malformed years
+var ErrInvalidComparison = errors.New("invalid event comparison")
+
+// EventComparisonFilter selects the events compared year over year: those of an event type or
+// category (a recurring event is usually one type, optionally narrowed by theme) at a branch.
+// Archived years are included: their events are read-only, not gone.
+type EventComparisonFilter struct {
+	BranchID        uint // 0 for every branch of the scope
+	IncludeChildren bool
+	EventTypeID     uint
+	EventCategoryID uint
+	Theme           string   // case-insensitive substring of the event theme
+	Years           []string // financial years (e.g. "2024-25") in ascending order
+	Scope           *BranchScope
+}
+
+// ComparisonMetric is one figure of a year with its change from the previous compared year
+type ComparisonMetric struct {
+	Value float64 `json:"value"`
+	// Value minus the previous year's value; nil for the first year
+	Delta *float64 `json:"delta"`
+	// Delta as a percentage of the previous year's value, rounded to one decimal; nil for the
+	// first year and when the previous value is 0
+	Growth *float64 `json:"growth_pct"`
+}
+
+// EventComparisonYear holds the figures of the compared events of one financial year
+type EventComparisonYear struct {
+	FinancialYear string           `json:"financial_year"`
+	Events        ComparisonMetric `json:"events"`
+	Beneficiaries ComparisonMetric `json:"beneficiaries"`
+	Initiations   ComparisonMetric `json:"initiations"`
+	Volunteers    ComparisonMetric `json:"volunteers"`
+	Donations     ComparisonMetric `json:"donations"`
+	DonationTotal ComparisonMetric `json:"donation_total"` // excluding voided receipts
+	Media         ComparisonMetric `json:"media"`
+}
+
+// EventComparison is the response of GET /dashboard/year-over-year
+type EventComparison struct {
+	BranchID        uint                  `json:"branch_id,omitempty"`
+	IncludeChildren bool                  `json:"include_children"`
+	EventTypeID     uint                  `json:"event_type_id,omitempty"`
+	EventCategoryID uint                  `json:"event_category_id,omitempty"`
+	Theme           string                `json:"theme,omitempty"`
+	Years           []EventComparisonYear `json:"years"`
+}
+
+// ParseComparisonYears parses comma-separated financial years, e.g. "2023-24,2024-25", into
+// ascending order. An empty value gives the last three years up to the current one.
+func ParseComparisonYears(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		current, _, _ := sequence.FinancialYearRange(sequence.FinancialYear(time.Now()))
+		years := make([]string, defaultComparisonYears)
+		for i := range years {
+			years[i] = sequence.FinancialYear(current.AddDate(i-defaultComparisonYears+1, 0, 0))
+		}
+		return years, nil
+	}
+
+	starts := map[time.Time]string{}
+	var ordered []time.Time
+	for _, year := range strings.Split(value, ",") {
+		year = strings.TrimSpace(year)
+		start, _, err := sequence.FinancialYearRange(year)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidComparison, err)
+		}
+		if _, ok := starts[start]; !ok {
+			starts[start] = year
+			ordered = append(ordered, start)
+		}
+	}
+	if len(ordered) > MaxComparisonYears {
+		return nil, fmt.Errorf("%w: at most %d years", ErrInvalidComparison, MaxComparisonYears)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Before(ordered[j]) })
+	years := make([]string, len(ordered))
+	for i, start := range ordered {
+		years[i] = starts[start]
+	}
+	return years, nil
+}
+
+// comparisonRow is the figures of one financial year, keyed by the year it starts in
+type comparisonRow struct {
+	FYStart       int
+	Events        int64
+	Beneficiaries int64
+	Initiations   int64
+	Volunteers    int64
+	Donations     int64
+	DonationTotal float64
+	Media         int64
+}
+
+// GetEventComparison compares the filter's events across its financial years. Years without
+// matching events are listed with zeros. Comparisons are served through the dashboard cache.
+func GetEventComparison(filter EventComparisonFilter) (*EventComparison, error) {
+	if filter.EventTypeID == 0 && filter.EventCategoryID == 0 {
+		return nil, fmt.Errorf("%w: event_type_id or event_category_id is required", ErrInvalidComparison)
+	}
+	if len(filter.Years) == 0 {
+		return nil, fmt.Errorf("%w: no financial years", ErrInvalidComparison)
+	}
+	return cached(context.Background(), CacheGroupDashboard, "year_over_year:"+filter.cacheKey(), func() (*EventComparison, error) {
+		return computeEventComparison(filter)
+	})
+}
+
+func computeEventComparison(filter EventComparisonFilter) (*EventComparison, error) {
+	branchIDs, err := dashboardBranchIDs(DashboardFilter{BranchID: filter.BranchID, IncludeChildren: filter.IncludeChildren, Scope: filter.Scope})
+	if err != nil {
+		return nil, err
+	}
+
+	query := excludeSandbox(config.DB.Table("event_details e").Where("e.deleted_at IS NULL"), "e.branch_id")
+	if branchIDs != nil {
+		query = query.Where("e.branch_id IN ?", branchIDs)
+	}
+	if filter.EventTypeID != 0 {
+		query = query.Where("e.event_type_id = ?", filter.EventTypeID)
+	}
+	if filter.EventCategoryID != 0 {
+		query = query.Where("e.event_category_id = ?", filter.EventCategoryID)
+	}
+	if filter.Theme != "" {
+		query = query.Where("e.theme ILIKE ?", "%"+strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.Theme)+"%")
+	}
+
+	starts := make([]int, len(filter.Years))
+	for i, year := range filter.Years {
+		start, _, err := sequence.FinancialYearRange(year)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidComparison, err)
+		}
+		starts[i] = start.Year()
+	}
+
+	// Events belong to the financial year of their start date, as in archiving; subtracting
+	// three months maps April-March onto the calendar year the financial year starts in
+	const fyStartSQL = "EXTRACT(YEAR FROM COALESCE(e.start_date, e.created_on) - INTERVAL '3 months')::int"
+	var rows []comparisonRow
+	err = query.
+		Select(fyStartSQL+` AS fy_start, COUNT(*) AS events,
+			COALESCE(SUM(e.beneficiary_men + e.beneficiary_women + e.beneficiary_child), 0) AS beneficiaries,
+			COALESCE(SUM(e.initiation_men + e.initiation_women + e.initiation_child), 0) AS initiations,
+			COALESCE(SUM(e.volunteer_count), 0) AS volunteers,
+			COALESCE(SUM(e.donation_count), 0) AS donations,
+			COALESCE(SUM(e.donation_total), 0)::float8 AS donation_total,
+			COALESCE(SUM(e.media_count), 0) AS media`).
+		Where(fyStartSQL+" IN ?", starts).
+		Group("fy_start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	byStart := make(map[int]comparisonRow, len(rows))
+	for _, row := range rows {
+		byStart[row.FYStart] = row
+	}
+
+	comparison := &EventComparison{
+		BranchID:        filter.BranchID,
+		IncludeChildren: filter.IncludeChildren,
+		EventTypeID:     filter.EventTypeID,
+		EventCategoryID: filter.EventCategoryID,
+		Theme:           filter.Theme,
+		Years:           make([]EventComparisonYear, len(filter.Years)),
+	}
+	var previous *comparisonRow
+	for i, year := range filter.Years {
+		row := byStart[starts[i]]
+		comparison.Years[i] = EventComparisonYear{
+			FinancialYear: year,
+			Events:        compareMetric(float64(row.Events), previous, func(r *comparisonRow) float64 { return float64(r.Events) }),
+			Beneficiaries: compareMetric(float64(row.Beneficiaries), previous, func(r *comparisonRow) float64 { return float64(r.Beneficiaries) }),
+			Initiations:   compareMetric(float64(row.Initiations), previous, func(r *comparisonRow) float64 { return float64(r.Initiations) }),
+			Volunteers:    compareMetric(float64(row.Volunteers), previous, func(r *comparisonRow) float64 { return float64(r.Volunteers) }),
+			Donations:     compareMetric(float64(row.Donations), previous, func(r *comparisonRow) float64 { return float64(r.Donations) }),
+			DonationTotal: compareMetric(row.DonationTotal, previous, func(r *comparisonRow) float64 { return r.DonationTotal }),
+			Media:         compareMetric(float64(row.Media), previous, func(r *comparisonRow) float64 { return float64(r.Media) }),
+		}
+		previous = &row
+	}
+	return comparison, nil
+}
+
+// compareMetric is value with its change from the same figure of previous (nil for the first year)
+func compareMetric(value float64, previous *comparisonRow, figure func(*comparisonRow) float64) ComparisonMetric {
+	metric := ComparisonMetric{Value: value}
+	if previous == nil {
+		return metric
+	}
+	before := figure(previous)
+	delta := value - before
+	metric.Delta = &delta
+	if before != 0 {
+		growth := math.Round(delta/before*1000) / 10
+		metric.Growth = &growth
+	}
+	return metric
+}
+
+// cacheKey identifies the filter, including the scope of the user
+func (f EventComparisonFilter) cacheKey() string {
+	key := strings.Join([]string{
+		strconv.FormatUint(uint64(f.BranchID), 10),
+		strconv.FormatBool(f.IncludeChildren),
+		strconv.FormatUint(uint64(f.EventTypeID), 10),
+		strconv.FormatUint(uint64(f.EventCategoryID), 10),
+		strconv.Quote(f.Theme),
+		strings.Join(f.Years, ","),
+	}, ":")
+	if f.Scope == nil {
+		return key + ":all"
+	}
+	return key + ":" + cacheKeyIDs(f.Scope.BranchIDs)
+}
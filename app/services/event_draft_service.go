@@ -2,69 +2,182 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
-// SaveDraft saves or updates a draft for a specific step
-// Returns the draft ID
-func SaveDraft(draftID *uint, step string, data map[string]interface{}, userEmail string) (uint, error) {
-	var draft models.EventDraft
+var (
+	ErrDraftNotFound = errors.New("draft not found")
+	ErrInvalidDraft  = errors.New("invalid draft")
+)
+
+// DraftSteps are the steps of the event form saved in a draft
+var DraftSteps = []string{"generalDetails", "mediaPromotion", "specialGuests", "volunteers", "donations"}
+
+// defaultDraftRetentionDays is how long drafts are kept after their last save
+const defaultDraftRetentionDays = 30
+
+// DraftSave is one autosave of a draft step. Version is the draft version the client last
+// read; when set, the save fails with a DraftConflictError if the draft has moved on since. Without
+// it the save overwrites whatever is stored (clients from before versioning).
+type DraftSave struct {
+	DraftID   *uint
+	EventID   *uint // the existing event a draft of changes belongs to
+	Step      string
+	Data      map[string]interface{}
+	Version   *int
+	UserEmail string
+}
+
+// DraftConflict describes the stored draft a save conflicted with
+type DraftConflict struct {
+	DraftID   uint       `json:"draftId"`
+	Version   int        `json:"version"`
+	UpdatedOn *time.Time `json:"updatedOn,omitempty"`
+}
+
+// DraftConflictError is returned when a draft was saved elsewhere (another tab or device)
+// since the version the client last read
+type DraftConflictError struct {
+	Current DraftConflict
+}
+
+func (e *DraftConflictError) Error() string {
+	return "draft was changed since it was loaded"
+}
+
+// draftConflict reports the stored state of a draft as a DraftConflictError
+func draftConflict(draftID uint, userEmail string) error {
+	draft, err := GetDraft(draftID, userEmail)
+	if err != nil {
+		return err
+	}
+	return &DraftConflictError{Current: DraftConflict{DraftID: draft.ID, Version: draft.Version, UpdatedOn: draft.UpdatedOn}}
+}
+
+// DraftSummary lists a draft without its step data
+type DraftSummary struct {
+	DraftID   uint       `json:"draftId"`
+	EventID   *uint      `json:"eventId,omitempty"`
+	Version   int        `json:"version"`
+	Steps     []string   `json:"steps"` // steps with saved data
+	EventType string     `json:"eventType,omitempty"`
+	Category  string     `json:"eventCategory,omitempty"`
+	CreatedOn time.Time  `json:"createdOn"`
+	UpdatedOn *time.Time `json:"updatedOn,omitempty"`
+}
+
+// SaveDraft saves one step of a user's draft and returns the saved draft. Without a draft ID a
+// new draft is started, unless the user already has a draft for the same event, which is then
+// updated. Drafts of other users are not found.
+func SaveDraft(save DraftSave) (*models.EventDraft, error) {
+	column, ok := draftStepColumns[save.Step]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid step name", ErrInvalidDraft)
+	}
 
-	if draftID != nil && *draftID > 0 {
-		// Update existing draft
-		if err := config.DB.First(&draft, *draftID).Error; err != nil {
-			return 0, errors.New("draft not found")
+	var draft models.EventDraft
+	var err error
+	switch {
+	case save.DraftID != nil && *save.DraftID > 0:
+		err = draftQuery(save.UserEmail).Where("id = ?", *save.DraftID).First(&draft).Error
+	case save.EventID != nil:
+		err = draftQuery(save.UserEmail).Where("event_id = ?", *save.EventID).Order("id").First(&draft).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return createDraft(save)
 		}
-	} else {
-		// Create new draft
-		draft = models.EventDraft{
-			UserEmail: userEmail,
-			CreatedOn: time.Now(),
+	default:
+		return createDraft(save)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
 		}
+		return nil, err
 	}
 
-	// Update the appropriate step field based on the step name
-	switch step {
-	case "generalDetails":
-		draft.GeneralDetailsDraft = models.JSONB(data)
-	case "mediaPromotion":
-		draft.MediaPromotionDraft = models.JSONB(data)
-	case "specialGuests":
-		draft.SpecialGuestsDraft = models.JSONB(data)
-	case "volunteers":
-		draft.VolunteersDraft = models.JSONB(data)
-	case "donations":
-		draft.DonationsDraft = models.JSONB(data)
-	default:
-		return 0, errors.New("invalid step name")
+	expected := draft.Version
+	if save.Version != nil {
+		expected = *save.Version
 	}
+	// The version check and the bump happen in one statement, so of two concurrent saves of the
+	// same version only one succeeds
+	result := config.DB.Model(&models.EventDraft{}).
+		Where("id = ? AND version = ?", draft.ID, expected).
+		Updates(map[string]interface{}{
+			column:       models.JSONB(save.Data),
+			"version":    gorm.Expr("version + 1"),
+			"updated_on": time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, draftConflict(draft.ID, save.UserEmail)
+	}
+	return GetDraft(draft.ID, save.UserEmail)
+}
 
+func createDraft(save DraftSave) (*models.EventDraft, error) {
+	if save.EventID != nil {
+		if err := ensureEventExists(*save.EventID); err != nil {
+			return nil, err
+		}
+	}
 	now := time.Now()
-	draft.UpdatedOn = &now
+	draft := models.EventDraft{
+		EventID:   save.EventID,
+		UserEmail: save.UserEmail,
+		Version:   1,
+		CreatedOn: now,
+		UpdatedOn: &now,
+	}
+	*draftSteps(&draft)[save.Step] = models.JSONB(save.Data)
+	if err := config.DB.Create(&draft).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
 
-	if draftID != nil && *draftID > 0 {
-		// Update existing draft
-		if err := config.DB.Save(&draft).Error; err != nil {
-			return 0, err
-		}
-		return draft.ID, nil
-	} else {
-		// Create new draft
-		if err := config.DB.Create(&draft).Error; err != nil {
-			return 0, err
-		}
-		return draft.ID, nil
+// draftStepColumns maps the form steps to their draft columns
+var draftStepColumns = map[string]string{
+	"generalDetails": "general_details_draft",
+	"mediaPromotion": "media_promotion_draft",
+	"specialGuests":  "special_guests_draft",
+	"volunteers":     "volunteers_draft",
+	"donations":      "donations_draft",
+}
+
+// draftSteps returns the step fields of a draft by step name
+func draftSteps(draft *models.EventDraft) map[string]*models.JSONB {
+	return map[string]*models.JSONB{
+		"generalDetails": &draft.GeneralDetailsDraft,
+		"mediaPromotion": &draft.MediaPromotionDraft,
+		"specialGuests":  &draft.SpecialGuestsDraft,
+		"volunteers":     &draft.VolunteersDraft,
+		"donations":      &draft.DonationsDraft,
 	}
 }
 
-// GetDraft retrieves a draft by ID
-func GetDraft(draftID uint) (*models.EventDraft, error) {
+func draftQuery(userEmail string) *gorm.DB {
+	return config.DB.Where("user_email = ?", userEmail)
+}
+
+// GetDraft retrieves one of a user's drafts by ID
+func GetDraft(draftID uint, userEmail string) (*models.EventDraft, error) {
 	var draft models.EventDraft
-	if err := config.DB.First(&draft, draftID).Error; err != nil {
-		return nil, errors.New("draft not found")
+	if err := draftQuery(userEmail).Where("id = ?", draftID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
+		}
+		return nil, err
 	}
 	return &draft, nil
 }
@@ -72,14 +185,46 @@ func GetDraft(draftID uint) (*models.EventDraft, error) {
 // GetLatestDraftByUserEmail retrieves the latest draft for a user by email
 func GetLatestDraftByUserEmail(userEmail string) (*models.EventDraft, error) {
 	var draft models.EventDraft
-	if err := config.DB.Where("user_email = ?", userEmail).
-		Order("updated_on DESC, created_on DESC").
+	if err := draftQuery(userEmail).
+		Order("updated_on DESC NULLS LAST, created_on DESC").
 		First(&draft).Error; err != nil {
-		return nil, errors.New("draft not found")
+		return nil, ErrDraftNotFound
 	}
 	return &draft, nil
 }
 
+// ListDrafts returns a user's drafts, most recently saved first
+func ListDrafts(userEmail string) ([]DraftSummary, error) {
+	var drafts []models.EventDraft
+	if err := draftQuery(userEmail).
+		Order("updated_on DESC NULLS LAST, created_on DESC").
+		Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]DraftSummary, 0, len(drafts))
+	for _, draft := range drafts {
+		summary := DraftSummary{
+			DraftID:   draft.ID,
+			EventID:   draft.EventID,
+			Version:   draft.Version,
+			Steps:     []string{},
+			CreatedOn: draft.CreatedOn,
+			UpdatedOn: draft.UpdatedOn,
+		}
+		steps := draftSteps(&draft)
+		for _, step := range DraftSteps {
+			if len(*steps[step]) > 0 {
+				summary.Steps = append(summary.Steps, step)
+			}
+		}
+		summary.EventType, _ = draft.GeneralDetailsDraft["eventType"].(string)
+		summary.Category, _ = draft.GeneralDetailsDraft["eventCategory"].(string)
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
 // DeleteDraft deletes a draft by ID
 func DeleteDraft(draftID uint) error {
 	if err := config.DB.Delete(&models.EventDraft{}, draftID).Error; err != nil {
@@ -88,9 +233,120 @@ func DeleteDraft(draftID uint) error {
 	return nil
 }
 
+// DraftToPayload assembles the POST /events payload from the steps of a draft. The list steps
+// store their lists under the payload key: specialGuests.specialGuests, volunteers.volunteers,
+// donations.donationTypes and donations.materialTypes. Participant counts are read from
+// generalDetails.involvedParticipants, or from generalDetails itself.
+func DraftToPayload(draft *models.EventDraft, status string) EventPayload {
+	payload := EventPayload{
+		GeneralDetails:       draft.GeneralDetailsDraft,
+		MediaPromotion:       draft.MediaPromotionDraft,
+		InvolvedParticipants: draft.GeneralDetailsDraft,
+		SpecialGuests:        draftList(draft.SpecialGuestsDraft, "specialGuests"),
+		Volunteers:           draftList(draft.VolunteersDraft, "volunteers"),
+		DonationTypes:        draftList(draft.DonationsDraft, "donationTypes"),
+		MaterialTypes:        draftList(draft.DonationsDraft, "materialTypes"),
+		DraftID:              &draft.ID,
+		Status:               status,
+	}
+	if participants, ok := draft.GeneralDetailsDraft["involvedParticipants"].(map[string]interface{}); ok {
+		payload.InvolvedParticipants = participants
+	}
+	if files, ok := draft.MediaPromotionDraft["uploadedFiles"].(map[string]interface{}); ok {
+		payload.UploadedFiles = files
+	}
+	return payload
+}
+
+func draftList(data models.JSONB, key string) []interface{} {
+	list, _ := data[key].([]interface{})
+	return list
+}
+
+// ConvertDraftToEvent creates the event a draft describes and deletes the draft. The draft must
+// be complete enough to pass the same checks as POST /events, otherwise ErrInvalidDraft lists
+// what is missing. Drafts of changes to an existing event cannot be converted; they are
+// submitted with PUT /events/:id. version works as in SaveDraft. Like POST /events, the event's
+// branch must be within scope (ErrOutOfBranchScope); a nil scope is unrestricted.
+func ConvertDraftToEvent(draftID uint, userEmail, status string, version *int, scope *BranchScope) (*models.EventDetails, error) {
+	draft, err := GetDraft(draftID, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	if draft.EventID != nil {
+		return nil, fmt.Errorf("%w: the draft holds changes to event %d", ErrInvalidDraft, *draft.EventID)
+	}
+	if version != nil && *version != draft.Version {
+		return nil, draftConflict(draft.ID, userEmail)
+	}
 
+	payload := DraftToPayload(draft, status)
+	if len(payload.GeneralDetails) == 0 {
+		return nil, fmt.Errorf("%w: generalDetails has not been filled in", ErrInvalidDraft)
+	}
+	event, err := MapFrontendPayloadToEventWithStatus(payload.GeneralDetails, payload.InvolvedParticipants, status)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDraft, err.Error())
+	}
+	if err := validators.ValidateEventInput(event.EventTypeID, event.EventCategoryID, event.StartDate, event.EndDate); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDraft, err.Error())
+	}
+	if scope != nil && (event.BranchID == nil || !scope.Allows(*event.BranchID)) {
+		return nil, ErrOutOfBranchScope
+	}
 
+	// Claim the draft by bumping its version, so a double submit cannot create the event twice
+	result := config.DB.Model(&models.EventDraft{}).
+		Where("id = ? AND version = ?", draft.ID, draft.Version).
+		Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, draftConflict(draft.ID, userEmail)
+	}
 
+	if err := CreateEvent(event); err != nil {
+		return nil, err
+	}
+	if err := CreateEventRelatedData(event.ID, payload); err != nil {
+		// Same as POST /events: the event exists, related data can be added later
+		utils.BaseLogger().Warn("Failed to create related event data from draft",
+			zap.Uint("event_id", event.ID), zap.Uint("draft_id", draft.ID), zap.Error(err))
+	}
+	if err := DeleteDraft(draft.ID); err != nil {
+		utils.BaseLogger().Warn("Failed to delete converted draft", zap.Uint("draft_id", draft.ID), zap.Error(err))
+	}
+	return event, nil
+}
 
+// draftRetentionDays is EVENT_DRAFT_RETENTION_DAYS (see config.ReportingConfig)
+func draftRetentionDays() int {
+	return config.Reporting.DraftRetentionDays
+}
 
+// StartDraftCleanup deletes drafts not saved for EVENT_DRAFT_RETENTION_DAYS, once an hour
+func StartDraftCleanup() {
+	retention := time.Duration(draftRetentionDays()) * 24 * time.Hour
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := DeleteStaleDrafts(time.Now().Add(-retention)); err != nil {
+				utils.BaseLogger().Error("Failed to delete stale drafts", zap.Error(err))
+			}
+		}
+	}()
+}
 
+// DeleteStaleDrafts deletes drafts last saved before cutoff and returns how many were deleted
+func DeleteStaleDrafts(cutoff time.Time) (int64, error) {
+	result := config.DB.Where("COALESCE(updated_on, created_on) < ?", cutoff).Delete(&models.EventDraft{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		utils.BaseLogger().Info("Deleted stale drafts", zap.Int64("count", result.RowsAffected))
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrEventMediaNotFound is returned for media that does not exist or belongs to another event
+	ErrEventMediaNotFound = errors.New("event media not found")
+	// ErrInvalidEventMedia is returned for unusable media payloads (unknown or already linked S3 object)
+	ErrInvalidEventMedia = errors.New("invalid event media")
+)
+
+// EventMediaInput is a file already uploaded to S3 that should be attached to an event
+type EventMediaInput struct {
+	S3Key            string
+	OriginalFilename string
+	FileType         string
+	Category         string
+	Caption          string
+	SortOrder        *int // nil appends to the end of the gallery
+}
+
+// EventMediaUpdate holds the editable gallery fields; nil fields are left unchanged
+type EventMediaUpdate struct {
+	Category  *string
+	Caption   *string
+	SortOrder *int
+	IsPublic  *bool // publish or withdraw the content-addressed public URLs, see SetEventMediaPublic
+}
+
+// ListEventMedia returns the media of an event in gallery order, optionally for one category
+func ListEventMedia(eventID uint, category string) ([]models.EventMedia, error) {
+	if err := ensureEventExists(eventID); err != nil {
+		return nil, err
+	}
+
+	query := config.DB.Preload("MediaCoverageType").Where("event_id = ?", eventID)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	mediaList := []models.EventMedia{}
+	if err := query.Order("sort_order, id").Find(&mediaList).Error; err != nil {
+		return nil, err
+	}
+	return mediaList, nil
+}
+
+// GetEventMediaItem returns one media item of an event
+func GetEventMediaItem(eventID, mediaID uint) (*models.EventMedia, error) {
+	var media models.EventMedia
+	if err := config.DB.Preload("MediaCoverageType").
+		Where("id = ? AND event_id = ?", mediaID, eventID).
+		First(&media).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventMediaNotFound
+		}
+		return nil, err
+	}
+	return &media, nil
+}
+
+// CreateEventMediaItem attaches an uploaded S3 object to an event. The object must exist and
+// must not already be linked to other media, so one event cannot expose another's files.
+func CreateEventMediaItem(ctx context.Context, eventID uint, input EventMediaInput) (*models.EventMedia, error) {
+	if err := ensureEventExists(eventID); err != nil {
+		return nil, err
+	}
+
+	input.S3Key = strings.TrimSpace(input.S3Key)
+	if input.S3Key == "" {
+		return nil, fmt.Errorf("%w: s3_key is required", ErrInvalidEventMedia)
+	}
+	var linked int64
+	if err := config.DB.Model(&models.EventMedia{}).Where("s3_key = ?", input.S3Key).Count(&linked).Error; err != nil {
+		return nil, err
+	}
+	if linked == 0 {
+		if err := config.DB.Model(&models.BranchMedia{}).Unscoped().Where("s3_key = ?", input.S3Key).Count(&linked).Error; err != nil {
+			return nil, err
+		}
+	}
+	if linked > 0 {
+		return nil, fmt.Errorf("%w: s3_key is already in use", ErrInvalidEventMedia)
+	}
+	metadata, err := GetObjectMetadata(ctx, input.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: s3_key does not exist", ErrInvalidEventMedia)
+	}
+	if input.OriginalFilename == "" {
+		input.OriginalFilename = metadata["original-filename"]
+	}
+
+	media := models.EventMedia{
+		EventID:          eventID,
+		S3Key:            input.S3Key,
+		OriginalFilename: input.OriginalFilename,
+		FileType:         input.FileType,
+		Category:         input.Category,
+		Caption:          input.Caption,
+		// Press contact columns are NOT NULL; same placeholders as the file upload endpoint
+		CompanyName: input.OriginalFilename,
+		FirstName:   "Uploaded",
+		LastName:    "File",
+	}
+	var mediaType models.MediaCoverageType
+	if err := config.DB.First(&mediaType).Error; err == nil {
+		media.MediaCoverageTypeID = mediaType.ID
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if input.SortOrder != nil {
+			media.SortOrder = *input.SortOrder
+		} else {
+			var last *int
+			if err := tx.Model(&models.EventMedia{}).Where("event_id = ?", eventID).
+				Select("MAX(sort_order)").Scan(&last).Error; err != nil {
+				return err
+			}
+			if last != nil {
+				media.SortOrder = *last + 1
+			}
+		}
+		return tx.Create(&media).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	PublishMediaUploaded(ctx, ThumbnailTargetEventMedia, media.ID)
+	return &media, nil
+}
+
+// UpdateEventMediaItem updates the caption, category, position or visibility of an event's media item
+func UpdateEventMediaItem(ctx context.Context, eventID, mediaID uint, update EventMediaUpdate) (*models.EventMedia, error) {
+	media, err := GetEventMediaItem(eventID, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"updated_on": time.Now()}
+	if update.Category != nil {
+		updates["category"] = *update.Category
+	}
+	if update.Caption != nil {
+		updates["caption"] = *update.Caption
+	}
+	if update.SortOrder != nil {
+		updates["sort_order"] = *update.SortOrder
+	}
+	if err := config.DB.Model(media).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if update.IsPublic != nil && *update.IsPublic != media.IsPublic {
+		if err := SetEventMediaPublic(ctx, media, *update.IsPublic); err != nil {
+			return nil, err
+		}
+	}
+	return GetEventMediaItem(eventID, mediaID)
+}
+
+// DeleteEventMediaItem deletes one media item of an event, leaving a tombstone.
+// Returns ErrLegalHold while the media or its event is on legal hold.
+func DeleteEventMediaItem(eventID, mediaID uint, actor AuditActor) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var media models.EventMedia
+		if err := tx.Where("id = ? AND event_id = ?", mediaID, eventID).First(&media).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventMediaNotFound
+			}
+			return err
+		}
+		return deleteEventMediaRecord(tx, &media, actor)
+	})
+}
+
+// deleteEventMediaRecord checks legal holds, records the tombstone and deletes the row
+func deleteEventMediaRecord(tx *gorm.DB, media *models.EventMedia, actor AuditActor) error {
+	if err := checkLegalHold(tx, models.LegalHoldEntityEventMedia, media.ID); err != nil {
+		return err
+	}
+	if err := recordMediaTombstone(tx, media, actor); err != nil {
+		return err
+	}
+	return tx.Delete(media).Error
+}
+
+// ReorderEventMedia sets the gallery order: mediaIDs[i] gets sort_order i. Every ID must belong
+// to the event; media not listed keep their position after the listed ones.
+func ReorderEventMedia(eventID uint, mediaIDs []uint) ([]models.EventMedia, error) {
+	if err := ensureEventExists(eventID); err != nil {
+		return nil, err
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var owned int64
+		if err := tx.Model(&models.EventMedia{}).Where("event_id = ? AND id IN ?", eventID, mediaIDs).Count(&owned).Error; err != nil {
+			return err
+		}
+		if int(owned) != len(uniqueUints(mediaIDs)) {
+			return fmt.Errorf("%w: media_ids must be media of event %d", ErrInvalidEventMedia, eventID)
+		}
+
+		now := time.Now()
+		for i, id := range mediaIDs {
+			if err := tx.Model(&models.EventMedia{}).Where("id = ?", id).
+				Updates(map[string]interface{}{"sort_order": i, "updated_on": now}).Error; err != nil {
+				return err
+			}
+		}
+		// Unlisted media go after the listed ones, keeping their relative order
+		return tx.Exec(`UPDATE event_media em SET sort_order = ? + r.rn
+			FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY sort_order, id) - 1 AS rn
+			      FROM event_media WHERE event_id = ? AND id NOT IN ?) r
+			WHERE em.id = r.id`,
+			len(mediaIDs), eventID, mediaIDs).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ListEventMedia(eventID, "")
+}
+
+func ensureEventExists(eventID uint) error {
+	var count int64
+	if err := config.DB.Model(&models.EventDetails{}).Where("id = ?", eventID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+func uniqueUints(values []uint) map[uint]struct{} {
+	set := make(map[uint]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
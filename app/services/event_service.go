@@ -1,52 +1,134 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/validators"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"gorm.io/gorm"
 )
 
+// ErrInvalidEventPayload is returned by CreateFullEvent for incomplete or invalid payloads
+var ErrInvalidEventPayload = errors.New("invalid event payload")
+
 // Create a new event
 func CreateEvent(event *models.EventDetails) error {
+	return createEvent(config.DB, event)
+}
+
+func createEvent(db *gorm.DB, event *models.EventDetails) error {
 	event.CreatedOn = time.Now()
 	event.UpdatedOn = nil
 
-	if err := config.DB.Create(event).Error; err != nil {
+	if err := db.Create(event).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-// Get all events with type + category
-// statusFilter can be "complete", "incomplete", or empty string for all
-func GetAllEvents(statusFilter string) ([]models.EventDetails, error) {
-	var events []models.EventDetails
-
-	db := config.DB.
-		Preload("EventType").
-		Preload("EventCategory").
-		Preload("Branch")
-
-	// Apply status filter if provided
-	if statusFilter != "" {
-		db = db.Where("status = ?", statusFilter)
+// CreateFullEvent creates an event together with its media, promotion materials, special
+// guests, volunteers and donations in one transaction: either everything is saved or nothing
+// is. Unlike POST /events, items that would be skipped there (missing required fields,
+// unknown branch or material type) fail the whole payload with ErrInvalidEventPayload. A
+// draft named by DraftID is deleted with it when the event is complete. The event's branch
+// must be in the scope (ErrOutOfBranchScope).
+func CreateFullEvent(payload EventPayload, scope *BranchScope) (*models.EventDetails, error) {
+	event, err := MapFrontendPayloadToEventWithStatus(payload.GeneralDetails, payload.InvolvedParticipants, payload.Status)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEventPayload, err.Error())
+	}
+	if scope != nil && (event.BranchID == nil || !scope.Allows(*event.BranchID)) {
+		return nil, ErrOutOfBranchScope
+	}
+	if err := validators.ValidateEventInput(event.EventTypeID, event.EventCategoryID, event.StartDate, event.EndDate); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEventPayload, err.Error())
 	}
 
-	if err := db.Find(&events).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := createEvent(tx, event); err != nil {
+			return err
+		}
+		if err := createEventRelatedData(tx, event.ID, payload, true); err != nil {
+			return err
+		}
+		if payload.DraftID != nil && *payload.DraftID > 0 && event.Status == "complete" {
+			return tx.Delete(&models.EventDraft{}, *payload.DraftID).Error
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	// Reload for the relations and the counters maintained by triggers
+	return GetEventByID(event.ID)
+}
+
+// EventListSchema is what GET /events may be sorted and filtered by (see listquery). Without a
+// sort events are listed by ID.
+var EventListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":                {Column: "id", Type: listquery.Int, Sortable: true},
+	"theme":             {Column: "theme", Type: listquery.String, Sortable: true},
+	"scale":             {Column: "scale", Type: listquery.String, Sortable: true},
+	"language":          {Column: "language", Type: listquery.String},
+	"spiritual_orator":  {Column: "spiritual_orator", Type: listquery.String, Sortable: true},
+	"status":            {Column: "status", Type: listquery.String, Sortable: true},
+	"approval_status":   {Column: "approval_status", Type: listquery.String, Sortable: true},
+	"report_number":     {Column: "report_number", Type: listquery.String, Sortable: true},
+	"country":           {Column: "country", Type: listquery.String},
+	"state":             {Column: "state", Type: listquery.String},
+	"district":          {Column: "district", Type: listquery.String},
+	"city":              {Column: "city", Type: listquery.String, Sortable: true},
+	"branch_id":         {Column: "branch_id", Type: listquery.Int},
+	"event_type_id":     {Column: "event_type_id", Type: listquery.Int},
+	"event_category_id": {Column: "event_category_id", Type: listquery.Int},
+	"start_date":        {Column: "start_date", Type: listquery.Time, Sortable: true},
+	"end_date":          {Column: "end_date", Type: listquery.Time, Sortable: true},
+	"created_on":        {Column: "created_on", Type: listquery.Time, Sortable: true},
+	"updated_on":        {Column: "updated_on", Type: listquery.Time, Sortable: true},
+	"last_activity_on":  {Column: "last_activity_on", Type: listquery.Time, Sortable: true},
+}).WithDateRange("start_date", "end_date")
+
+// GetAllEvents lists events with type + category, sorted and filtered by a query parsed with
+// EventListSchema and limited to the scope's branches. Soft-deleted events are excluded unless
+// includeDeleted is set. Paged queries return one page and the number of matching events;
+// otherwise every match is returned and total is their count.
+func GetAllEvents(query *listquery.Query, includeDeleted bool, scope *BranchScope) (events []models.EventDetails, total int64, err error) {
+	db := scope.Apply(withDeleted(config.DB, includeDeleted).Model(&models.EventDetails{}), "branch_id")
+
+	if query.Paged() {
+		if err := query.Filter(db.Session(&gorm.Session{})).Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		db = query.Page(query.Apply(db))
+	} else {
+		db = query.Apply(db)
+	}
 
-	return events, nil
+	if err := db.
+		Preload("EventType").
+		Preload("EventCategory").
+		Preload("Branch").
+		Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	if !query.Paged() {
+		total = int64(len(events))
+	}
+	return events, total, nil
 }
 
-// Search events by type, category, or theme
-func SearchEvents(search string) ([]models.EventDetails, error) {
+// Search events by type, category, or theme within the scope's branches
+func SearchEvents(search string, scope *BranchScope) ([]models.EventDetails, error) {
 	var events []models.EventDetails
 
 	db := config.DB.Preload("EventType").Preload("EventCategory").Preload("Branch")
+	db = scope.Apply(db, "branch_id")
 
 	if search != "" {
 		db = db.Where(`
@@ -69,8 +151,9 @@ func SearchEvents(search string) ([]models.EventDetails, error) {
 
 var ErrEventNotFound = errors.New("event not found")
 
-// Update event
-func UpdateEvent(eventID uint, updatedData map[string]interface{}) error {
+// UpdateEvent updates event fields. version is the event version the client last read; when set
+// the update fails with a VersionConflictError if the event has been updated since.
+func UpdateEvent(eventID uint, updatedData map[string]interface{}, version *int) error {
 	var event models.EventDetails
 
 	if err := config.DB.First(&event, eventID).Error; err != nil {
@@ -83,65 +166,81 @@ func UpdateEvent(eventID uint, updatedData map[string]interface{}) error {
 	now := time.Now()
 	updatedData["updated_on"] = &now
 
-	if err := config.DB.Model(&event).Updates(updatedData).Error; err != nil {
+	updated, err := updateVersioned(config.DB, &event, version, updatedData)
+	if err != nil {
 		return err
 	}
+	if !updated {
+		current, err := GetEventByID(eventID)
+		if err != nil {
+			return err
+		}
+		return &VersionConflictError{Version: current.Version, Current: current}
+	}
 
 	return nil
 }
 
-// Delete event and all related data
+// DeleteEvent soft-deletes an event together with its volunteers and donations.
+// All rows share the same deleted_at so RestoreEvent can bring them back together.
+// Special guests, media, promotion materials and status history are left in place
+// and stay hidden behind the deleted event. Returns ErrLegalHold while the event or any of
+// its media is on legal hold.
 func DeleteEvent(eventID uint) error {
-	// Start a transaction to ensure all deletions succeed or none do
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkLegalHold(tx, models.LegalHoldEntityEvent, eventID); err != nil {
+			return err
 		}
-	}()
+		now := time.Now()
 
-	// Delete all special guests for this event
-	if err := tx.Where("event_id = ?", eventID).Delete(&models.SpecialGuest{}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete special guests: " + err.Error())
-	}
+		result := tx.Model(&models.EventDetails{}).Where("id = ?", eventID).Update("deleted_at", now)
+		if result.Error != nil {
+			return errors.New("failed to delete event: " + result.Error.Error())
+		}
+		if result.RowsAffected == 0 {
+			return ErrEventNotFound
+		}
 
-	// Delete all volunteers for this event
-	if err := tx.Where("event_id = ?", eventID).Delete(&models.Volunteer{}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete volunteers: " + err.Error())
-	}
+		if err := tx.Model(&models.Volunteer{}).Where("event_id = ?", eventID).Update("deleted_at", now).Error; err != nil {
+			return errors.New("failed to delete volunteers: " + err.Error())
+		}
 
-	// Delete all event media for this event
-	if err := tx.Where("event_id = ?", eventID).Delete(&models.EventMedia{}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete event media: " + err.Error())
-	}
+		if err := tx.Model(&models.Donation{}).Where("event_id = ?", eventID).Update("deleted_at", now).Error; err != nil {
+			return errors.New("failed to delete donations: " + err.Error())
+		}
 
-	// Delete all donations for this event
-	if err := tx.Where("event_id = ?", eventID).Delete(&models.Donation{}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete donations: " + err.Error())
-	}
+		return nil
+	})
+}
 
-	// Delete all promotion material details for this event
-	if err := tx.Where("event_id = ?", eventID).Delete(&models.PromotionMaterialDetails{}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete promotion materials: " + err.Error())
-	}
+// RestoreEvent restores a soft-deleted event and the volunteers and donations deleted with it
+func RestoreEvent(eventID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var event models.EventDetails
+		if err := tx.Unscoped().Select("id", "deleted_at").
+			Where("id = ? AND deleted_at IS NOT NULL", eventID).
+			First(&event).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotDeleted
+			}
+			return err
+		}
+		deletedAt := event.DeletedAt.Time
 
-	// Delete the event itself
-	if err := tx.Delete(&models.EventDetails{}, eventID).Error; err != nil {
-		tx.Rollback()
-		return errors.New("failed to delete event: " + err.Error())
-	}
+		if err := tx.Unscoped().Model(&models.Volunteer{}).
+			Where("event_id = ? AND deleted_at = ?", eventID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return errors.New("failed to restore volunteers: " + err.Error())
+		}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		return errors.New("failed to commit transaction: " + err.Error())
-	}
+		if err := tx.Unscoped().Model(&models.Donation{}).
+			Where("event_id = ? AND deleted_at = ?", eventID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return errors.New("failed to restore donations: " + err.Error())
+		}
 
-	return nil
+		return restoreSoftDeleted(tx, &models.EventDetails{}, eventID)
+	})
 }
 
 // GetEventByID retrieves an event by ID with all related data
@@ -162,6 +261,34 @@ func GetEventByID(eventID uint) (*models.EventDetails, error) {
 	return &event, nil
 }
 
+// GetEventFallbackBranch resolves the branch of a legacy event without branch_id
+// from its first volunteer, falling back to its first donation
+func GetEventFallbackBranch(eventID uint) (*models.Branch, error) {
+	var branchID uint
+	for _, table := range []string{"volunteers", "donations"} {
+		if err := config.DB.Table(table).
+			Select("branch_id").
+			Where("event_id = ? AND branch_id > 0 AND deleted_at IS NULL", eventID).
+			Order("id").
+			Limit(1).
+			Scan(&branchID).Error; err != nil {
+			return nil, err
+		}
+		if branchID != 0 {
+			break
+		}
+	}
+	if branchID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var branch models.Branch
+	if err := config.DB.Select("id", "name").First(&branch, branchID).Error; err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
 // UpdateEventStatus updates the status of an event
 func UpdateEventStatus(eventID uint, status string) error {
 	var event models.EventDetails
@@ -185,3 +312,198 @@ func UpdateEventStatus(eventID uint, status string) error {
 
 	return nil
 }
+
+// *************** Event Approval Workflow ****************** //
+
+var (
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	ErrTransitionNotPermitted  = errors.New("your role is not permitted to make this transition")
+	ErrCommentRequired         = errors.New("a reviewer comment is required when rejecting an event")
+	ErrStatusChanged           = errors.New("event status was changed by another user, please reload")
+)
+
+// eventStatusTransitions lists the allowed next statuses for each approval status
+var eventStatusTransitions = map[string][]string{
+	models.EventStatusDraft:       {models.EventStatusSubmitted},
+	models.EventStatusSubmitted:   {models.EventStatusUnderReview, models.EventStatusDraft},
+	models.EventStatusUnderReview: {models.EventStatusApproved, models.EventStatusRejected},
+	models.EventStatusRejected:    {models.EventStatusDraft, models.EventStatusSubmitted},
+	models.EventStatusApproved:    {models.EventStatusPublished},
+}
+
+// reviewerTransitionRoles restricts transitions into these statuses to reviewer roles.
+// Transitions into statuses not listed here (draft, submitted) are open to any authenticated user.
+var reviewerTransitionRoles = map[string][]uint{
+	models.EventStatusUnderReview: {models.RoleAdmin, models.RoleManager},
+	models.EventStatusApproved:    {models.RoleAdmin, models.RoleManager},
+	models.EventStatusRejected:    {models.RoleAdmin, models.RoleManager},
+	models.EventStatusPublished:   {models.RoleAdmin},
+}
+
+// CanTransitionEventStatus reports whether from → to is a valid workflow transition
+func CanTransitionEventStatus(from, to string) bool {
+	for _, next := range eventStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func roleCanTransitionTo(roleID uint, to string) bool {
+	roles, restricted := reviewerTransitionRoles[to]
+	if !restricted {
+		return true
+	}
+	for _, r := range roles {
+		if r == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingApprovalStatuses returns the statuses a role is expected to act on as a reviewer
+func PendingApprovalStatuses(roleID uint) []string {
+	var statuses []string
+	for _, from := range []string{
+		models.EventStatusSubmitted,
+		models.EventStatusUnderReview,
+		models.EventStatusApproved,
+	} {
+		for _, to := range eventStatusTransitions[from] {
+			if _, restricted := reviewerTransitionRoles[to]; restricted && roleCanTransitionTo(roleID, to) {
+				statuses = append(statuses, from)
+				break
+			}
+		}
+	}
+	return statuses
+}
+
+// eventStatusWebhookEvents are the webhook events published by approval transitions
+var eventStatusWebhookEvents = map[string]string{
+	models.EventStatusSubmitted: WebhookEventSubmitted,
+	models.EventStatusApproved:  WebhookEventApproved,
+	models.EventStatusRejected:  WebhookEventRejected,
+}
+
+// TransitionEventApprovalStatus validates and applies an approval status transition,
+// recording it in the status history table
+func TransitionEventApprovalStatus(eventID uint, toStatus, comment string, userID, roleID uint) (*models.EventDetails, error) {
+	var event models.EventDetails
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&event, eventID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+
+		fromStatus := event.ApprovalStatus
+		if fromStatus == "" {
+			fromStatus = models.EventStatusDraft
+		}
+
+		if !CanTransitionEventStatus(fromStatus, toStatus) {
+			return fmt.Errorf("%w: %s → %s", ErrInvalidStatusTransition, fromStatus, toStatus)
+		}
+		if !roleCanTransitionTo(roleID, toStatus) {
+			return ErrTransitionNotPermitted
+		}
+		comment = strings.TrimSpace(comment)
+		if toStatus == models.EventStatusRejected && comment == "" {
+			return ErrCommentRequired
+		}
+
+		// Guard on the current status so concurrent reviewers cannot both apply a transition
+		now := time.Now()
+		result := tx.Model(&models.EventDetails{}).
+			Where("id = ? AND COALESCE(approval_status, 'draft') = ?", eventID, fromStatus).
+			Updates(map[string]interface{}{
+				"approval_status": toStatus,
+				"updated_on":      &now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+
+		history := models.EventStatusHistory{
+			EventID:    eventID,
+			FromStatus: fromStatus,
+			ToStatus:   toStatus,
+			Comment:    comment,
+			ChangedBy:  userID,
+			RoleID:     roleID,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+
+		event.ApprovalStatus = toStatus
+		event.UpdatedOn = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyEventAwaitingReview(context.Background(), &event, toStatus, userID)
+	if toStatus == models.EventStatusApproved || toStatus == models.EventStatusRejected {
+		notifyEventReviewed(context.Background(), &event, toStatus, comment)
+	}
+	if eventType, ok := eventStatusWebhookEvents[toStatus]; ok {
+		data := map[string]interface{}{
+			"event_id":      event.ID,
+			"report_number": event.ReportNumber,
+			"branch_id":     event.BranchID,
+			"status":        toStatus,
+		}
+		if comment != "" {
+			data["comment"] = comment
+		}
+		PublishWebhookEvent(context.Background(), eventType, event.BranchID, data)
+	}
+
+	return &event, nil
+}
+
+// GetEventStatusHistory returns the approval history of an event, oldest first
+func GetEventStatusHistory(eventID uint) ([]models.EventStatusHistory, error) {
+	var history []models.EventStatusHistory
+
+	if err := config.DB.
+		Where("event_id = ?", eventID).
+		Order("created_on ASC, id ASC").
+		Find(&history).Error; err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GetEventsPendingApproval lists events of the scope's branches waiting on the given reviewer role
+func GetEventsPendingApproval(roleID uint, scope *BranchScope) ([]models.EventDetails, error) {
+	events := []models.EventDetails{}
+
+	statuses := PendingApprovalStatuses(roleID)
+	if len(statuses) == 0 {
+		return events, nil
+	}
+
+	if err := scope.Apply(config.DB, "branch_id").
+		Preload("EventType").
+		Preload("EventCategory").
+		Preload("Branch").
+		Where("approval_status IN ?", statuses).
+		Order("updated_on ASC NULLS FIRST, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -2,14 +2,16 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
 )
 
-// CreateEventRelatedData creates related data for an event (media, guests, volunteers, donations)
-func CreateEventRelatedData(eventID uint, payload struct {
+// EventPayload is the event form payload of POST /events and PUT /events/:id
+type EventPayload struct {
 	GeneralDetails       map[string]interface{} `json:"generalDetails"`
 	MediaPromotion       map[string]interface{} `json:"mediaPromotion"`
 	InvolvedParticipants map[string]interface{} `json:"involvedParticipants"`
@@ -20,7 +22,34 @@ func CreateEventRelatedData(eventID uint, payload struct {
 	UploadedFiles        map[string]interface{} `json:"uploadedFiles"`
 	DraftID              *uint                  `json:"draftId,omitempty"`
 	Status               string                 `json:"status,omitempty"`
-}) error {
+}
+
+// CreateEventRelatedData creates related data for an event (media, guests, volunteers, donations).
+// Items missing required fields are skipped and only failed donations are reported; see
+// CreateFullEvent for the all-or-nothing variant.
+func CreateEventRelatedData(eventID uint, payload EventPayload) error {
+	return createEventRelatedData(config.DB, eventID, payload, false)
+}
+
+// createEventRelatedData creates the related data with db. In strict mode every item must be
+// complete and every insert must succeed; the first problem is returned, with
+// ErrInvalidEventPayload for incomplete items.
+func createEventRelatedData(db *gorm.DB, eventID uint, payload EventPayload, strict bool) error {
+	// skip reports an incomplete item in strict mode and ignores it otherwise
+	skip := func(list string, i int, reason string) error {
+		if strict {
+			return fmt.Errorf("%w: %s[%d]: %s", ErrInvalidEventPayload, list, i, reason)
+		}
+		return nil
+	}
+	// create inserts an item; failed inserts are only reported in strict mode
+	create := func(value interface{}) error {
+		if err := db.Create(value).Error; err != nil && strict {
+			return err
+		}
+		return nil
+	}
+
 	// Create Event Media records
 	// Check both "eventMediaList" (from frontend) and "eventMedia" (legacy)
 	var eventMediaList []interface{}
@@ -31,7 +60,12 @@ func CreateEventRelatedData(eventID uint, payload struct {
 	}
 
 	if len(eventMediaList) > 0 {
-		for _, mediaItem := range eventMediaList {
+		for i, mediaItem := range eventMediaList {
+			if _, ok := mediaItem.(map[string]interface{}); !ok {
+				if err := skip("eventMediaList", i, "must be an object"); err != nil {
+					return err
+				}
+			}
 			if mediaMap, ok := mediaItem.(map[string]interface{}); ok {
 				media := models.EventMedia{
 					EventID: eventID,
@@ -40,7 +74,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				// Get media coverage type
 				if mediaTypeName, ok := mediaMap["mediaCoverageType"].(string); ok && mediaTypeName != "" {
 					var mediaType models.MediaCoverageType
-					if err := config.DB.Where("media_type = ?", mediaTypeName).First(&mediaType).Error; err == nil {
+					if err := db.Where("media_type = ?", mediaTypeName).First(&mediaType).Error; err == nil {
 						media.MediaCoverageTypeID = mediaType.ID
 					}
 				}
@@ -111,7 +145,11 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				}
 
 				if media.CompanyName != "" && media.FirstName != "" && media.LastName != "" {
-					_ = config.DB.Create(&media)
+					if err := create(&media); err != nil {
+						return err
+					}
+				} else if err := skip("eventMediaList", i, "companyName and the media person's firstName and lastName are required"); err != nil {
+					return err
 				}
 			}
 		}
@@ -127,7 +165,12 @@ func CreateEventRelatedData(eventID uint, payload struct {
 	}
 
 	if len(materialTypes) > 0 {
-		for _, materialItem := range materialTypes {
+		for i, materialItem := range materialTypes {
+			if _, ok := materialItem.(map[string]interface{}); !ok {
+				if err := skip("materialTypes", i, "must be an object"); err != nil {
+					return err
+				}
+			}
 			if materialMap, ok := materialItem.(map[string]interface{}); ok {
 				material := models.PromotionMaterialDetails{
 					EventID: eventID,
@@ -136,7 +179,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				// Get promotion material type
 				if materialTypeName, ok := materialMap["materialType"].(string); ok && materialTypeName != "" {
 					var promoType models.PromotionMaterial
-					if err := config.DB.Where("material_type = ?", materialTypeName).First(&promoType).Error; err == nil {
+					if err := db.Where("material_type = ?", materialTypeName).First(&promoType).Error; err == nil {
 						material.PromotionMaterialID = promoType.ID
 					}
 				}
@@ -164,14 +207,23 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				}
 
 				if material.PromotionMaterialID > 0 && material.Quantity > 0 {
-					_ = config.DB.Create(&material)
+					if err := create(&material); err != nil {
+						return err
+					}
+				} else if err := skip("materialTypes", i, "a known materialType and a positive quantity are required"); err != nil {
+					return err
 				}
 			}
 		}
 	}
 
 	// Create Special Guests
-	for _, guestItem := range payload.SpecialGuests {
+	for i, guestItem := range payload.SpecialGuests {
+		if _, ok := guestItem.(map[string]interface{}); !ok {
+			if err := skip("specialGuests", i, "must be an object"); err != nil {
+				return err
+			}
+		}
 		if guestMap, ok := guestItem.(map[string]interface{}); ok {
 			guest := models.SpecialGuest{
 				EventID: eventID,
@@ -227,13 +279,22 @@ func CreateEventRelatedData(eventID uint, payload struct {
 			}
 
 			if guest.Prefix != "" {
-				_ = config.DB.Create(&guest)
+				if err := create(&guest); err != nil {
+					return err
+				}
+			} else if err := skip("specialGuests", i, "prefix is required"); err != nil {
+				return err
 			}
 		}
 	}
 
 	// Create Volunteers
-	for _, volunteerItem := range payload.Volunteers {
+	for i, volunteerItem := range payload.Volunteers {
+		if _, ok := volunteerItem.(map[string]interface{}); !ok {
+			if err := skip("volunteers", i, "must be an object"); err != nil {
+				return err
+			}
+		}
 		if volMap, ok := volunteerItem.(map[string]interface{}); ok {
 			volunteer := models.Volunteer{
 				EventID: eventID,
@@ -247,7 +308,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				} else {
 					// If not numeric, treat as branch code and look it up
 					var branch models.Branch
-					if err := config.DB.Where("branch_code = ?", val).First(&branch).Error; err == nil {
+					if err := db.Where("branch_code = ?", val).First(&branch).Error; err == nil {
 						volunteer.BranchID = branch.ID
 					}
 				}
@@ -256,7 +317,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 			} else if val, ok := volMap["branch_code"].(string); ok && val != "" {
 				// Also check for branch_code field directly
 				var branch models.Branch
-				if err := config.DB.Where("branch_code = ?", val).First(&branch).Error; err == nil {
+				if err := db.Where("branch_code = ?", val).First(&branch).Error; err == nil {
 					volunteer.BranchID = branch.ID
 				}
 			}
@@ -285,7 +346,11 @@ func CreateEventRelatedData(eventID uint, payload struct {
 			}
 
 			if volunteer.BranchID > 0 && volunteer.VolunteerName != "" {
-				_ = config.DB.Create(&volunteer)
+				if err := create(&volunteer); err != nil {
+					return err
+				}
+			} else if err := skip("volunteers", i, "a known branchId and name are required"); err != nil {
+				return err
 			}
 		}
 	}
@@ -299,7 +364,12 @@ func CreateEventRelatedData(eventID uint, payload struct {
 		donations = donationList
 	}
 
-	for _, donationItem := range donations {
+	for i, donationItem := range donations {
+		if _, ok := donationItem.(map[string]interface{}); !ok {
+			if err := skip("donationTypes", i, "must be an object"); err != nil {
+				return err
+			}
+		}
 		if donationMap, ok := donationItem.(map[string]interface{}); ok {
 			donation := models.Donation{
 				EventID: eventID,
@@ -314,7 +384,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 				} else {
 					// If not numeric, treat as branch code and look it up
 					var branch models.Branch
-					if err := config.DB.Where("branch_code = ?", val).First(&branch).Error; err == nil {
+					if err := db.Where("branch_code = ?", val).First(&branch).Error; err == nil {
 						donation.BranchID = branch.ID
 					}
 				}
@@ -325,7 +395,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 			} else if val, ok := donationMap["branch_code"].(string); ok && val != "" {
 				// Also check for branch_code field directly
 				var branch models.Branch
-				if err := config.DB.Where("branch_code = ?", val).First(&branch).Error; err == nil {
+				if err := db.Where("branch_code = ?", val).First(&branch).Error; err == nil {
 					donation.BranchID = branch.ID
 				}
 			} else if branchIdVal, ok := payload.GeneralDetails["branchId"]; ok {
@@ -335,7 +405,7 @@ func CreateEventRelatedData(eventID uint, payload struct {
 						donation.BranchID = uint(branchID)
 					} else {
 						var branch models.Branch
-						if err := config.DB.Where("branch_code = ?", branchIdStr).First(&branch).Error; err == nil {
+						if err := db.Where("branch_code = ?", branchIdStr).First(&branch).Error; err == nil {
 							donation.BranchID = branch.ID
 						}
 					}
@@ -366,11 +436,13 @@ func CreateEventRelatedData(eventID uint, payload struct {
 
 			// Only create donation if we have required fields
 			if donation.DonationType != "" && donation.BranchID > 0 {
-				if err := config.DB.Create(&donation).Error; err != nil {
+				if err := db.Create(&donation).Error; err != nil {
 					// Log error but continue processing other donations
 					// Return error will be logged by caller
 					return err
 				}
+			} else if err := skip("donationTypes", i, "type and a known branchId are required"); err != nil {
+				return err
 			}
 		}
 	}
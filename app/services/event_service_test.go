@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+)
+
+func TestCanTransitionEventStatus(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{models.EventStatusDraft, models.EventStatusSubmitted}:       true,
+		{models.EventStatusSubmitted, models.EventStatusUnderReview}: true,
+		{models.EventStatusSubmitted, models.EventStatusDraft}:       true,
+		{models.EventStatusUnderReview, models.EventStatusApproved}:  true,
+		{models.EventStatusUnderReview, models.EventStatusRejected}:  true,
+		{models.EventStatusRejected, models.EventStatusDraft}:        true,
+		{models.EventStatusRejected, models.EventStatusSubmitted}:    true,
+		{models.EventStatusApproved, models.EventStatusPublished}:    true,
+	}
+	statuses := []string{
+		models.EventStatusDraft,
+		models.EventStatusSubmitted,
+		models.EventStatusUnderReview,
+		models.EventStatusApproved,
+		models.EventStatusRejected,
+		models.EventStatusPublished,
+		"",
+		"unknown",
+	}
+
+	// Every pair not listed above, including staying in the same status, leaving published
+	// and unknown statuses, is refused
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransitionEventStatus(from, to); got != want {
+				t.Errorf("CanTransitionEventStatus(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/xuri/excelize/v2"
+)
+
+// EventExportFilter narrows the events included in an export
+type EventExportFilter struct {
+	From        *time.Time // events starting on or after this date
+	To          *time.Time // events starting on or before this date
+	BranchID    uint
+	EventTypeID uint
+	Scale       string
+	MaskPII     bool // hide contact numbers and donation amounts (non-privileged roles)
+	Scope       *BranchScope
+}
+
+// GetEventsForExport returns the events matching the export filter, oldest first
+func GetEventsForExport(filter EventExportFilter) ([]models.EventDetails, error) {
+	var events []models.EventDetails
+
+	db := config.DB.
+		Preload("EventType").
+		Preload("EventCategory").
+		Preload("Branch")
+
+	db = excludeSandbox(db, "branch_id")
+	db = filter.Scope.Apply(db, "branch_id")
+	if filter.From != nil {
+		db = db.Where("start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("start_date <= ?", *filter.To)
+	}
+	if filter.BranchID != 0 {
+		db = db.Where("branch_id = ?", filter.BranchID)
+	}
+	if filter.EventTypeID != 0 {
+		db = db.Where("event_type_id = ?", filter.EventTypeID)
+	}
+	if filter.Scale != "" {
+		db = db.Where("scale = ?", filter.Scale)
+	}
+
+	if err := db.Order("start_date ASC, id ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GenerateEventsXLSX builds a workbook with one sheet each for events, beneficiaries,
+// initiations, donations and volunteers of the filtered events
+func GenerateEventsXLSX(filter EventExportFilter) ([]byte, error) {
+	events, err := GetEventsForExport(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	eventIDs := make([]uint, 0, len(events))
+	eventsByID := make(map[uint]*models.EventDetails, len(events))
+	for i := range events {
+		eventIDs = append(eventIDs, events[i].ID)
+		eventsByID[events[i].ID] = &events[i]
+	}
+
+	var donations []models.Donation
+	var volunteers []models.Volunteer
+	if len(eventIDs) > 0 {
+		if err := config.DB.Where("event_id IN ?", eventIDs).Order("event_id, id").Find(&donations).Error; err != nil {
+			return nil, err
+		}
+		if err := config.DB.Where("event_id IN ?", eventIDs).Order("event_id, id").Find(&volunteers).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	header, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"F0F0F0"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Events
+	rows := make([][]interface{}, 0, len(events))
+	for _, e := range events {
+		rows = append(rows, []interface{}{
+			e.ID, e.ReportNumber, e.EventType.Name, e.EventCategory.Name, e.Scale, e.Theme,
+			exportBranchName(e.Branch), exportDate(e.StartDate), exportDate(e.EndDate),
+			e.City, e.State, e.Country, e.Status, e.ApprovalStatus,
+		})
+	}
+	if err := writeExportSheet(f, "Events", header, []string{
+		"Event ID", "Report No", "Event Type", "Category", "Scale", "Theme",
+		"Branch", "Start Date", "End Date", "City", "State", "Country", "Status", "Approval Status",
+	}, rows); err != nil {
+		return nil, err
+	}
+
+	// Beneficiaries and initiations share the same layout
+	var beneficiaries, initiations [][]interface{}
+	var bTotals, iTotals [3]int
+	for _, e := range events {
+		prefix := []interface{}{e.ID, e.ReportNumber, e.EventType.Name, exportBranchName(e.Branch), exportDate(e.StartDate)}
+		beneficiaries = append(beneficiaries, append(append([]interface{}{}, prefix...),
+			e.BeneficiaryMen, e.BeneficiaryWomen, e.BeneficiaryChild,
+			e.BeneficiaryMen+e.BeneficiaryWomen+e.BeneficiaryChild))
+		initiations = append(initiations, append(append([]interface{}{}, prefix...),
+			e.InitiationMen, e.InitiationWomen, e.InitiationChild,
+			e.InitiationMen+e.InitiationWomen+e.InitiationChild))
+		bTotals[0] += e.BeneficiaryMen
+		bTotals[1] += e.BeneficiaryWomen
+		bTotals[2] += e.BeneficiaryChild
+		iTotals[0] += e.InitiationMen
+		iTotals[1] += e.InitiationWomen
+		iTotals[2] += e.InitiationChild
+	}
+	countHeader := []string{"Event ID", "Report No", "Event Type", "Branch", "Start Date", "Men", "Women", "Children", "Total"}
+	for _, sheet := range []struct {
+		name   string
+		rows   [][]interface{}
+		totals [3]int
+	}{
+		{"Beneficiaries", beneficiaries, bTotals},
+		{"Initiations", initiations, iTotals},
+	} {
+		totalRow := []interface{}{"Total", "", "", "", "", sheet.totals[0], sheet.totals[1], sheet.totals[2],
+			sheet.totals[0] + sheet.totals[1] + sheet.totals[2]}
+		if err := writeExportSheet(f, sheet.name, header, countHeader, append(sheet.rows, totalRow)); err != nil {
+			return nil, err
+		}
+		if err := styleLastRow(f, sheet.name, len(sheet.rows)+2, len(countHeader), bold); err != nil {
+			return nil, err
+		}
+	}
+
+	// Donations
+	rows = make([][]interface{}, 0, len(donations)+1)
+	var donationTotal float64
+	for _, d := range donations {
+		var amount interface{} = d.Amount
+		if filter.MaskPII {
+			amount = ""
+		}
+		donationTotal += d.Amount
+		rows = append(rows, []interface{}{
+			d.EventID, exportReportNumber(eventsByID, d.EventID), d.ReceiptNumber, d.DonorName,
+			d.DonationType, d.KindType, amount, d.Remarks, exportDate(d.CreatedOn),
+		})
+	}
+	donationHeader := []string{"Event ID", "Report No", "Receipt No", "Donor", "Donation Type", "Kind", "Amount", "Remarks", "Recorded On"}
+	if !filter.MaskPII {
+		rows = append(rows, []interface{}{"Total", "", "", "", "", "", donationTotal})
+	}
+	if err := writeExportSheet(f, "Donations", header, donationHeader, rows); err != nil {
+		return nil, err
+	}
+	if !filter.MaskPII {
+		if err := styleLastRow(f, "Donations", len(rows)+1, len(donationHeader), bold); err != nil {
+			return nil, err
+		}
+	}
+
+	// Volunteers
+	rows = make([][]interface{}, 0, len(volunteers))
+	for _, v := range volunteers {
+		contact := v.Contact
+		if filter.MaskPII {
+			contact = utils.MaskPhone(contact)
+		}
+		rows = append(rows, []interface{}{
+			v.EventID, exportReportNumber(eventsByID, v.EventID), v.VolunteerName, contact,
+			v.NumberOfDays, v.SevaInvolved, v.MentionSeva,
+		})
+	}
+	if err := writeExportSheet(f, "Volunteers", header, []string{
+		"Event ID", "Report No", "Volunteer Name", "Contact", "Number of Days", "Seva Involved", "Mention Seva",
+	}, rows); err != nil {
+		return nil, err
+	}
+
+	// excelize creates "Sheet1" by default; drop it once the real sheets exist
+	if err := f.DeleteSheet("Sheet1"); err != nil {
+		return nil, err
+	}
+	if idx, err := f.GetSheetIndex("Events"); err == nil {
+		f.SetActiveSheet(idx)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeExportSheet creates a sheet with a styled header row followed by the data rows
+func writeExportSheet(f *excelize.File, name string, headerStyle int, header []string, rows [][]interface{}) error {
+	if _, err := f.NewSheet(name); err != nil {
+		return err
+	}
+
+	headerRow := make([]interface{}, len(header))
+	for i, h := range header {
+		headerRow[i] = h
+	}
+	if err := f.SetSheetRow(name, "A1", &headerRow); err != nil {
+		return err
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	if err := f.SetCellStyle(name, "A1", lastCol+"1", headerStyle); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(name, "A", lastCol, 16); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(name, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	return f.SetPanes(name, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+}
+
+func styleLastRow(f *excelize.File, sheet string, row, cols, style int) error {
+	lastCol, _ := excelize.ColumnNumberToName(cols)
+	return f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("%s%d", lastCol, row), style)
+}
+
+func exportBranchName(branch *models.Branch) string {
+	if branch == nil {
+		return ""
+	}
+	return branch.Name
+}
+
+func exportReportNumber(events map[uint]*models.EventDetails, eventID uint) string {
+	if e, ok := events[eventID]; ok {
+		return e.ReportNumber
+	}
+	return ""
+}
+
+func exportDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
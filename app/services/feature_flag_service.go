@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// featureFlagCacheTTL bounds how long an instance may act on a stale flag value.
+// During a blue/green rollout both colors pick up a flipped flag within this window.
+const featureFlagCacheTTL = 15 * time.Second
+
+var featureFlagKeyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// ErrInvalidFeatureFlagKey is returned for keys that are not lowercase dotted names
+var ErrInvalidFeatureFlagKey = errors.New("feature flag key must be lowercase letters, digits and underscores separated by dots")
+
+var featureFlagCache = struct {
+	sync.RWMutex
+	flags    map[string]bool
+	loadedAt time.Time
+}{}
+
+// IsFeatureEnabled reports whether a flag is on. Unknown flags are off.
+// Flags are read from a cache that is refreshed every featureFlagCacheTTL; if the
+// refresh fails the last known values are kept.
+func IsFeatureEnabled(key string) bool {
+	featureFlagCache.RLock()
+	fresh := featureFlagCache.flags != nil && time.Since(featureFlagCache.loadedAt) < featureFlagCacheTTL
+	enabled := featureFlagCache.flags[key]
+	featureFlagCache.RUnlock()
+	if fresh {
+		return enabled
+	}
+
+	refreshFeatureFlags()
+
+	featureFlagCache.RLock()
+	defer featureFlagCache.RUnlock()
+	return featureFlagCache.flags[key]
+}
+
+func refreshFeatureFlags() {
+	var flags []models.FeatureFlag
+	err := config.DB.Select("key", "enabled").Find(&flags).Error
+
+	featureFlagCache.Lock()
+	defer featureFlagCache.Unlock()
+	featureFlagCache.loadedAt = time.Now()
+	if err != nil {
+		// keep serving the previous values; retry after the TTL
+		if featureFlagCache.flags == nil {
+			featureFlagCache.flags = map[string]bool{}
+		}
+		return
+	}
+
+	featureFlagCache.flags = make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		featureFlagCache.flags[flag.Key] = flag.Enabled
+	}
+}
+
+// invalidateFeatureFlags makes the next IsFeatureEnabled call reload from the database
+func invalidateFeatureFlags() {
+	featureFlagCache.Lock()
+	featureFlagCache.loadedAt = time.Time{}
+	featureFlagCache.Unlock()
+}
+
+// GetFeatureFlags lists all flags ordered by key
+func GetFeatureFlags() ([]models.FeatureFlag, error) {
+	var flags []models.FeatureFlag
+	err := config.DB.Order("key").Find(&flags).Error
+	return flags, err
+}
+
+// SetFeatureFlag creates or updates a flag and records the change in the audit log.
+// An empty description keeps the existing one.
+func SetFeatureFlag(key string, enabled bool, description string, actor AuditActor) (*models.FeatureFlag, error) {
+	if !featureFlagKeyPattern.MatchString(key) {
+		return nil, ErrInvalidFeatureFlagKey
+	}
+
+	var flag models.FeatureFlag
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		action := models.AuditActionUpdate
+		var before map[string]interface{}
+
+		err := tx.Where("key = ?", key).First(&flag).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			action = models.AuditActionCreate
+			flag = models.FeatureFlag{Key: key}
+		case err != nil:
+			return err
+		default:
+			before = auditSnapshotOf(&flag)
+		}
+
+		flag.Enabled = enabled
+		if description != "" {
+			flag.Description = description
+		}
+		flag.UpdatedBy = actor.UserID
+		if err := tx.Save(&flag).Error; err != nil {
+			return err
+		}
+
+		changes := DiffAuditSnapshots(before, auditSnapshotOf(&flag))
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(actor.auditEntry(AuditEntityFeatureFlag, flag.ID, action, changes, time.Now())).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateFeatureFlags()
+	return &flag, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/sequence"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Archived financial years are read-only. The database enforces it (see
+// add_financial_year_archival in migrations/00001_baseline.sql): writes to an event of an archived year, or
+// to its media, special guests, volunteers, donations or promotion materials, fail with
+// SQLSTATE DJ001, which ArchivedYearPlugin turns into ErrArchivedYear. Stats of archived years
+// come from the rollups written when the year was archived, see archivedYearRollups.
+
+var (
+	// ErrArchivedYear is returned for writes to data of an archived financial year
+	ErrArchivedYear = errors.New("data of archived financial years is read-only")
+	// ErrInvalidFinancialYear is returned for malformed years and years that cannot be archived yet
+	ErrInvalidFinancialYear = errors.New("invalid financial year")
+	// ErrFinancialYearArchived is returned when archiving a year twice
+	ErrFinancialYearArchived = errors.New("financial year is already archived")
+	// ErrFinancialYearNotArchived is returned when unarchiving a year that is not archived
+	ErrFinancialYearNotArchived = errors.New("financial year is not archived")
+)
+
+// archivedYearSQLState is raised by the archived-year triggers; the detail is the year
+const archivedYearSQLState = "DJ001"
+
+// archivedYearEventsSQL selects the events (including soft-deleted ones) of a financial year
+const archivedYearEventsSQL = "SELECT id FROM event_details WHERE COALESCE(start_date, created_on)::date BETWEEN ? AND ?"
+
+// archivedYearMonthSQL is the month (YYYY-MM) rollups are keyed by
+const archivedYearMonthSQL = "to_char(COALESCE(e.start_date, e.created_on), 'YYYY-MM')"
+
+// archivedChildTables carry an archived flag that keeps their rows out of the hot indexes
+var archivedChildTables = []string{"event_media", "special_guests", "volunteers", "donations"}
+
+// ArchivedYearPlugin reports writes rejected by the archived-year triggers as ErrArchivedYear.
+// Register with config.DB.Use(services.ArchivedYearPlugin{}).
+type ArchivedYearPlugin struct{}
+
+func (ArchivedYearPlugin) Name() string {
+	return "archived_year"
+}
+
+func (ArchivedYearPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Register("archived_year:create", translateArchivedYearError),
+		cb.Update().After("gorm:update").Register("archived_year:update", translateArchivedYearError),
+		cb.Delete().After("gorm:delete").Register("archived_year:delete", translateArchivedYearError),
+		cb.Raw().After("gorm:raw").Register("archived_year:raw", translateArchivedYearError),
+		cb.Row().After("gorm:row").Register("archived_year:row", translateArchivedYearError),
+	)
+}
+
+func translateArchivedYearError(tx *gorm.DB) {
+	var pgErr *pgconn.PgError
+	if tx.Error != nil && errors.As(tx.Error, &pgErr) && pgErr.Code == archivedYearSQLState {
+		tx.Error = fmt.Errorf("%w (financial year %s)", ErrArchivedYear, pgErr.Detail)
+	}
+}
+
+// ArchiveFinancialYear makes a past financial year read-only. In one transaction the year's
+// event figures, donations and media are rolled up per branch and month, the year's rows are
+// flagged so they drop out of the hot indexes, and the year is recorded as archived. Years
+// with events still awaiting review cannot be archived.
+func ArchiveFinancialYear(financialYear, note string, actor AuditActor) (*models.ArchivedFinancialYear, error) {
+	start, end, err := sequence.FinancialYearRange(financialYear)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFinancialYear, err)
+	}
+	if current := sequence.FinancialYear(time.Now()); financialYear >= current {
+		return nil, fmt.Errorf("%w: only years before %s can be archived", ErrInvalidFinancialYear, current)
+	}
+
+	year := &models.ArchivedFinancialYear{
+		FinancialYear: financialYear,
+		StartsOn:      start,
+		EndsOn:        end,
+		Note:          note,
+		ArchivedBy:    actor.UserID,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var archived int64
+		if err := tx.Model(&models.ArchivedFinancialYear{}).Where("financial_year = ?", financialYear).
+			Count(&archived).Error; err != nil {
+			return err
+		}
+		if archived > 0 {
+			return ErrFinancialYearArchived
+		}
+		var pending int64
+		if err := tx.Model(&models.EventDetails{}).
+			Where("id IN ("+archivedYearEventsSQL+")", start, end).
+			Where("approval_status IN ?", []string{models.EventStatusSubmitted, models.EventStatusUnderReview}).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: %d event(s) of %s are still awaiting review", ErrInvalidFinancialYear, pending, financialYear)
+		}
+
+		// Flag the rows first: once the year is recorded the triggers reject every write to them
+		if err := tx.Exec("UPDATE event_details SET archived = true WHERE id IN ("+archivedYearEventsSQL+")", start, end).Error; err != nil {
+			return err
+		}
+		for _, table := range archivedChildTables {
+			if err := tx.Exec("UPDATE "+table+" SET archived = true WHERE event_id IN ("+archivedYearEventsSQL+")", start, end).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(year).Error; err != nil {
+			return err
+		}
+		return writeArchivedYearRollups(tx, financialYear, start, end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The dashboard now reads the year from its rollups
+	InvalidateCache(context.Background(), CacheGroupDashboard)
+	utils.BaseLogger().Info("Financial year archived", zap.String("financial_year", financialYear), zap.Uintp("by", actor.UserID))
+	years := []models.ArchivedFinancialYear{*year}
+	if err := fillArchivedYearTotals(years); err != nil {
+		return nil, err
+	}
+	return &years[0], nil
+}
+
+// writeArchivedYearRollups stores the figures the dashboard and branch stats serve for an
+// archived year. Like the live queries they only count rows that are not soft-deleted.
+func writeArchivedYearRollups(tx *gorm.DB, financialYear string, start, end time.Time) error {
+	err := tx.Exec(`INSERT INTO archived_year_rollups (financial_year, branch_id, month, events, events_complete,
+			beneficiary_men, beneficiary_women, beneficiary_child, initiation_men, initiation_women, initiation_child)
+		SELECT ?, e.branch_id, `+archivedYearMonthSQL+` AS month, COUNT(*), COUNT(*) FILTER (WHERE e.status = 'complete'),
+			COALESCE(SUM(e.beneficiary_men), 0), COALESCE(SUM(e.beneficiary_women), 0), COALESCE(SUM(e.beneficiary_child), 0),
+			COALESCE(SUM(e.initiation_men), 0), COALESCE(SUM(e.initiation_women), 0), COALESCE(SUM(e.initiation_child), 0)
+		FROM event_details e
+		WHERE e.deleted_at IS NULL AND e.id IN (`+archivedYearEventsSQL+`)
+		GROUP BY e.branch_id, month`, financialYear, start, end).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`INSERT INTO archived_year_breakdowns (financial_year, branch_id, month, kind, key, count, amount)
+		SELECT ?, d.branch_id, `+archivedYearMonthSQL+` AS month, ?, `+dashboardDonationTypeSQL+` AS key,
+			COUNT(*), COALESCE(SUM(d.amount), 0)
+		FROM donations d
+		JOIN event_details e ON e.id = d.event_id AND e.deleted_at IS NULL
+		WHERE d.deleted_at IS NULL AND e.id IN (`+archivedYearEventsSQL+`)
+		GROUP BY d.branch_id, month, key`, financialYear, models.ArchivedBreakdownDonationType, start, end).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO archived_year_breakdowns (financial_year, branch_id, month, kind, key, count, amount)
+		SELECT ?, e.branch_id, `+archivedYearMonthSQL+` AS month, ?, `+dashboardFileTypeSQL+` AS key, COUNT(*), 0
+		FROM event_media m
+		JOIN event_details e ON e.id = m.event_id AND e.deleted_at IS NULL
+		WHERE e.id IN (`+archivedYearEventsSQL+`)
+		GROUP BY e.branch_id, month, key`, financialYear, models.ArchivedBreakdownMediaFileType, start, end).Error
+}
+
+// UnarchiveFinancialYear makes an archived year writable again and drops its rollups; stats
+// are computed live from its rows again
+func UnarchiveFinancialYear(financialYear string, actor AuditActor) error {
+	start, end, err := sequence.FinancialYearRange(financialYear)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFinancialYear, err)
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Removing the year (and with it the rollups) first lifts the triggers for the updates below
+		result := tx.Where("financial_year = ?", financialYear).Delete(&models.ArchivedFinancialYear{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFinancialYearNotArchived
+		}
+		if err := tx.Exec("UPDATE event_details SET archived = false WHERE id IN ("+archivedYearEventsSQL+")", start, end).Error; err != nil {
+			return err
+		}
+		for _, table := range archivedChildTables {
+			if err := tx.Exec("UPDATE "+table+" SET archived = false WHERE event_id IN ("+archivedYearEventsSQL+")", start, end).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	InvalidateCache(context.Background(), CacheGroupDashboard)
+	utils.BaseLogger().Info("Financial year unarchived", zap.String("financial_year", financialYear), zap.Uintp("by", actor.UserID))
+	return nil
+}
+
+// ListArchivedFinancialYears returns the archived years, newest first, with their rollup totals
+func ListArchivedFinancialYears() ([]models.ArchivedFinancialYear, error) {
+	years := []models.ArchivedFinancialYear{}
+	if err := config.DB.Order("financial_year DESC").Find(&years).Error; err != nil {
+		return nil, err
+	}
+	if err := fillArchivedYearTotals(years); err != nil {
+		return nil, err
+	}
+	return years, nil
+}
+
+func fillArchivedYearTotals(years []models.ArchivedFinancialYear) error {
+	if len(years) == 0 {
+		return nil
+	}
+	names := make([]string, len(years))
+	for i, year := range years {
+		names[i] = year.FinancialYear
+	}
+	var events []struct {
+		FinancialYear string
+		Events        int64
+	}
+	if err := config.DB.Model(&models.ArchivedYearRollup{}).
+		Select("financial_year, SUM(events)::bigint AS events").
+		Where("financial_year IN ?", names).
+		Group("financial_year").
+		Scan(&events).Error; err != nil {
+		return err
+	}
+	var donations []struct {
+		FinancialYear string
+		Count         int64
+		Amount        float64
+	}
+	if err := config.DB.Model(&models.ArchivedYearBreakdown{}).
+		Select("financial_year, SUM(count)::bigint AS count, SUM(amount)::float8 AS amount").
+		Where("financial_year IN ? AND kind = ?", names, models.ArchivedBreakdownDonationType).
+		Group("financial_year").
+		Scan(&donations).Error; err != nil {
+		return err
+	}
+	for i := range years {
+		for _, e := range events {
+			if e.FinancialYear == years[i].FinancialYear {
+				years[i].Events = e.Events
+			}
+		}
+		for _, d := range donations {
+			if d.FinancialYear == years[i].FinancialYear {
+				years[i].Donations, years[i].DonationTotal = d.Count, d.Amount
+			}
+		}
+	}
+	return nil
+}
+
+// archivedYearRollups selects the rollups (alias r) of the given branches, all branches if nil
+func archivedYearRollups(branchIDs []uint) *gorm.DB {
+	query := config.DB.Table("archived_year_rollups r")
+	if branchIDs != nil {
+		query = query.Where("r.branch_id IN ?", branchIDs)
+	}
+	return query
+}
+
+// archivedYearBreakdowns selects the breakdowns (alias r) of one kind, see archivedYearRollups
+func archivedYearBreakdowns(kind string, branchIDs []uint) *gorm.DB {
+	query := config.DB.Table("archived_year_breakdowns r").Where("r.kind = ?", kind)
+	if branchIDs != nil {
+		query = query.Where("r.branch_id IN ?", branchIDs)
+	}
+	return query
+}
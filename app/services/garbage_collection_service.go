@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Garbage collection removes rows nobody needs any more: expired sessions and auth tokens,
+// drafts not saved for EVENT_DRAFT_RETENTION_DAYS (also deleted hourly by StartDraftCleanup),
+// expired upload sessions (also aborted hourly by StartUploadSessionCleanup) and finished jobs
+// older than JOB_RETENTION_DAYS. It runs as a job, started by operators (POST /api/admin/gc,
+// djjsctl trigger-gc).
+
+// ErrGarbageCollectionRunning is returned while a garbage collection is queued or running
+var ErrGarbageCollectionRunning = errors.New("a garbage collection is already running")
+
+const (
+	// authRecordRetention keeps expired sessions and tokens for a day, for the audit of
+	// failed sign-ins and resets
+	authRecordRetention     = 24 * time.Hour
+	defaultJobRetentionDays = 30
+)
+
+// jobRetentionDays is JOB_RETENTION_DAYS (see config.JobsConfig)
+func jobRetentionDays() int {
+	return config.Jobs.RetentionDays
+}
+
+// StartGarbageCollection queues a garbage collection. Only one runs at a time.
+func StartGarbageCollection(ctx context.Context, createdBy *uint) (*models.Job, error) {
+	var running int64
+	if err := config.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", JobTypeGarbageCollection, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrGarbageCollectionRunning
+	}
+	return EnqueueJob(ctx, JobTypeGarbageCollection, struct{}{}, JobOptions{CreatedBy: createdBy, MaxAttempts: 1})
+}
+
+func runGarbageCollectionJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	now := time.Now()
+	result := models.JSONB{}
+
+	run.SetProgress(10, "Purging expired sessions and tokens")
+	purged, err := auth.PurgeExpiredRecords(ctx, now.Add(-authRecordRetention))
+	if err != nil {
+		return nil, err
+	}
+	for table, count := range purged {
+		result[table] = count
+	}
+
+	run.SetProgress(50, "Deleting stale drafts")
+	drafts, err := DeleteStaleDrafts(now.AddDate(0, 0, -draftRetentionDays()))
+	if err != nil {
+		return nil, err
+	}
+	result["event_drafts"] = drafts
+
+	run.SetProgress(60, "Aborting expired uploads")
+	uploads, err := DeleteExpiredUploadSessions(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	result["upload_sessions"] = uploads
+
+	run.SetProgress(75, "Deleting old jobs")
+	jobs := config.DB.WithContext(ctx).
+		Where("status IN ? AND finished_on < ?", []string{models.JobStatusSucceeded, models.JobStatusFailed}, now.AddDate(0, 0, -jobRetentionDays())).
+		Delete(&models.Job{})
+	if jobs.Error != nil {
+		return nil, jobs.Error
+	}
+	result["jobs"] = jobs.RowsAffected
+
+	utils.Logger(ctx).Info("Garbage collection finished", zap.Any("deleted", result))
+	return models.JSONB{"deleted": result}, nil
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// geoCacheTTL bounds how long the location tables are served from memory. Imports reload them
+// right away; the TTL only matters for edits made directly in the database or on another instance.
+const geoCacheTTL = time.Hour
+
+// MaxLocationImportRows caps the number of data rows accepted in one location import file
+const MaxLocationImportRows = 100000
+
+// locationImportBatchSize is the number of rows inserted per INSERT statement
+const locationImportBatchSize = 1000
+
+// geoTables is an in-memory copy of countries, states, districts and cities, indexed by parent
+// for the cascading dropdowns. Every list is ordered by name.
+type geoTables struct {
+	countries        []models.Country
+	states           []models.State
+	districts        []models.District
+	cities           []models.City
+	statesByCountry  map[uint][]models.State
+	districtsByState map[uint][]models.District
+	citiesByState    map[uint][]models.City
+	citiesByDistrict map[uint][]models.City
+	loadedAt         time.Time
+}
+
+var geoCache = struct {
+	sync.Mutex
+	tables *geoTables
+}{}
+
+// geoLocations returns the cached location tables, reloading them once they are older than
+// geoCacheTTL. If a reload fails the previous tables keep being served.
+func geoLocations() (*geoTables, error) {
+	geoCache.Lock()
+	defer geoCache.Unlock()
+	if geoCache.tables != nil && time.Since(geoCache.tables.loadedAt) < geoCacheTTL {
+		return geoCache.tables, nil
+	}
+
+	// Instances share the tables through the cache, so a restart does not reload them all
+	lists, err := cached(context.Background(), CacheGroupMaster, "locations", func() (geoLists, error) {
+		return loadGeoLists(config.DB)
+	})
+	if err != nil {
+		if geoCache.tables != nil {
+			utils.BaseLogger().Warn("Failed to reload location tables, serving cached ones", zap.Error(err))
+			return geoCache.tables, nil
+		}
+		return nil, err
+	}
+	tables := indexGeoTables(lists)
+	geoCache.tables = tables
+	return tables, nil
+}
+
+// InvalidateGeoCache makes the next location lookup reload from the database, on this instance
+// and (through the shared cache) the next reload of the others
+func InvalidateGeoCache() {
+	geoCache.Lock()
+	geoCache.tables = nil
+	geoCache.Unlock()
+	InvalidateCache(context.Background(), CacheGroupMaster, CacheGroupBranches)
+}
+
+// geoLists are the location tables as stored in the shared cache
+type geoLists struct {
+	Countries []models.Country  `json:"countries"`
+	States    []models.State    `json:"states"`
+	Districts []models.District `json:"districts"`
+	Cities    []models.City     `json:"cities"`
+}
+
+func loadGeoLists(db *gorm.DB) (geoLists, error) {
+	var lists geoLists
+	if err := db.Order("name, id").Find(&lists.Countries).Error; err != nil {
+		return lists, err
+	}
+	if err := db.Order("name, id").Find(&lists.States).Error; err != nil {
+		return lists, err
+	}
+	if err := db.Order("name, id").Find(&lists.Districts).Error; err != nil {
+		return lists, err
+	}
+	if err := db.Order("name, id").Find(&lists.Cities).Error; err != nil {
+		return lists, err
+	}
+	return lists, nil
+}
+
+func loadGeoTables(db *gorm.DB) (*geoTables, error) {
+	lists, err := loadGeoLists(db)
+	if err != nil {
+		return nil, err
+	}
+	return indexGeoTables(lists), nil
+}
+
+// indexGeoTables indexes the location lists by parent
+func indexGeoTables(lists geoLists) *geoTables {
+	t := &geoTables{
+		countries: lists.Countries,
+		states:    lists.States,
+		districts: lists.Districts,
+		cities:    lists.Cities,
+		loadedAt:  time.Now(),
+	}
+
+	t.statesByCountry = make(map[uint][]models.State)
+	for _, state := range t.states {
+		t.statesByCountry[state.CountryID] = append(t.statesByCountry[state.CountryID], state)
+	}
+	t.districtsByState = make(map[uint][]models.District)
+	for _, district := range t.districts {
+		t.districtsByState[district.StateID] = append(t.districtsByState[district.StateID], district)
+	}
+	t.citiesByState = make(map[uint][]models.City)
+	t.citiesByDistrict = make(map[uint][]models.City)
+	for _, city := range t.cities {
+		t.citiesByState[city.StateID] = append(t.citiesByState[city.StateID], city)
+		if city.DistrictID != nil {
+			t.citiesByDistrict[*city.DistrictID] = append(t.citiesByDistrict[*city.DistrictID], city)
+		}
+	}
+	return t
+}
+
+// orEmpty keeps lookups without matches serializing as [] rather than null
+func orEmpty[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+// GetDistrictsByStateService returns the districts of a state
+func GetDistrictsByStateService(stateID uint) ([]models.District, error) {
+	tables, err := geoLocations()
+	if err != nil {
+		return nil, err
+	}
+	return orEmpty(tables.districtsByState[stateID]), nil
+}
+
+// GetCitiesByDistrictService returns the cities of a district. Cities not linked to a district
+// yet are only listed by state.
+func GetCitiesByDistrictService(districtID uint) ([]models.City, error) {
+	tables, err := geoLocations()
+	if err != nil {
+		return nil, err
+	}
+	return orEmpty(tables.citiesByDistrict[districtID]), nil
+}
+
+// LocationImportResult summarizes a location import: the number of locations created per level
+// and of existing cities linked to their district. Nothing is written when DryRun is set or when
+// any row has errors.
+type LocationImportResult struct {
+	DryRun       bool                   `json:"dry_run"`
+	TotalRows    int                    `json:"total_rows"`
+	Countries    int                    `json:"countries"`
+	States       int                    `json:"states"`
+	Districts    int                    `json:"districts"`
+	Cities       int                    `json:"cities"`
+	CitiesLinked int                    `json:"cities_linked"`
+	Errors       []BranchImportRowError `json:"errors"`
+}
+
+// ParseLocationImportFile reads a CSV or XLSX location dataset with the columns country, state,
+// district and city, one path of the hierarchy per row
+func ParseLocationImportFile(filename string, r io.Reader) ([]BranchImportRow, error) {
+	return parseImportFile(filename, r, MaxLocationImportRows)
+}
+
+// locationPath is the lowercased name path of a row's location at the given level. Cities are
+// unique per state, so their path skips the district.
+func locationPath(row BranchImportRow, level string) string {
+	path := strings.ToLower(row.get("country"))
+	if level == "country" {
+		return path
+	}
+	for _, column := range []string{"state", "district", "city"} {
+		if column == "district" && level == "city" {
+			continue
+		}
+		path += "/" + strings.ToLower(row.get(column))
+		if column == level {
+			break
+		}
+	}
+	return path
+}
+
+// ImportLocations loads an official location dataset. Each row names a country and optionally a
+// state, district and city below it; locations are matched by case-insensitive name under their
+// parent and created when missing, so importing the same dataset twice changes nothing. A city
+// without a district is kept under its state only; an existing city found under a district is
+// linked to it. Levels are inserted in batches, in one transaction.
+func ImportLocations(rows []BranchImportRow, dryRun bool) (*LocationImportResult, error) {
+	result := &LocationImportResult{DryRun: dryRun, TotalRows: len(rows), Errors: []BranchImportRowError{}}
+
+	for _, row := range rows {
+		for _, column := range []string{"country", "state", "district", "city"} {
+			if utf8.RuneCountInString(row.get(column)) > 100 {
+				result.Errors = append(result.Errors, BranchImportRowError{Row: row.number, Column: column, Error: "must be at most 100 characters"})
+			}
+		}
+		switch {
+		case row.get("country") == "":
+			result.Errors = append(result.Errors, BranchImportRowError{Row: row.number, Column: "country", Error: "is required"})
+		case row.get("state") == "" && (row.get("district") != "" || row.get("city") != ""):
+			result.Errors = append(result.Errors, BranchImportRowError{Row: row.number, Column: "state", Error: "is required for a district or city"})
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := loadGeoTables(tx)
+		if err != nil {
+			return err
+		}
+
+		// Locations are keyed by their lowercased name path ("india/punjab/ludhiana"), so rows can
+		// refer to locations that are only created by this import. New ones map to 0 until their
+		// level is inserted (and stay 0 in a dry run).
+		countryIDs := make(map[string]uint)
+		countryPaths := make(map[uint]string)
+		for _, c := range existing.countries {
+			path := strings.ToLower(c.Name)
+			countryIDs[path], countryPaths[c.ID] = c.ID, path
+		}
+		stateIDs := make(map[string]uint)
+		statePaths := make(map[uint]string)
+		for _, s := range existing.states {
+			path := countryPaths[s.CountryID] + "/" + strings.ToLower(s.Name)
+			stateIDs[path], statePaths[s.ID] = s.ID, path
+		}
+		districtIDs := make(map[string]uint)
+		for _, d := range existing.districts {
+			districtIDs[statePaths[d.StateID]+"/"+strings.ToLower(d.Name)] = d.ID
+		}
+		cities := make(map[string]models.City) // cities are unique per state
+		for _, c := range existing.cities {
+			cities[statePaths[c.StateID]+"/"+strings.ToLower(c.Name)] = c
+		}
+
+		var newCountries []models.Country
+		var countryNew []string
+		for _, row := range rows {
+			path := locationPath(row, "country")
+			if _, ok := countryIDs[path]; !ok {
+				countryIDs[path] = 0
+				countryNew = append(countryNew, path)
+				newCountries = append(newCountries, models.Country{Name: row.get("country")})
+			}
+		}
+		result.Countries = len(newCountries)
+		if !dryRun && len(newCountries) > 0 {
+			if err := tx.CreateInBatches(&newCountries, locationImportBatchSize).Error; err != nil {
+				return err
+			}
+			for i, c := range newCountries {
+				countryIDs[countryNew[i]] = c.ID
+			}
+		}
+
+		var newStates []models.State
+		var stateNew []string
+		for _, row := range rows {
+			path := locationPath(row, "state")
+			if row.get("state") == "" {
+				continue
+			}
+			if _, ok := stateIDs[path]; !ok {
+				stateIDs[path] = 0
+				stateNew = append(stateNew, path)
+				newStates = append(newStates, models.State{Name: row.get("state"), CountryID: countryIDs[locationPath(row, "country")]})
+			}
+		}
+		result.States = len(newStates)
+		if !dryRun && len(newStates) > 0 {
+			if err := tx.CreateInBatches(&newStates, locationImportBatchSize).Error; err != nil {
+				return err
+			}
+			for i, s := range newStates {
+				stateIDs[stateNew[i]] = s.ID
+			}
+		}
+
+		var newDistricts []models.District
+		var districtNew []string
+		for _, row := range rows {
+			path := locationPath(row, "district")
+			if row.get("district") == "" {
+				continue
+			}
+			if _, ok := districtIDs[path]; !ok {
+				districtIDs[path] = 0
+				districtNew = append(districtNew, path)
+				newDistricts = append(newDistricts, models.District{
+					Name:      row.get("district"),
+					StateID:   stateIDs[locationPath(row, "state")],
+					CountryID: countryIDs[locationPath(row, "country")],
+				})
+			}
+		}
+		result.Districts = len(newDistricts)
+		if !dryRun && len(newDistricts) > 0 {
+			if err := tx.CreateInBatches(&newDistricts, locationImportBatchSize).Error; err != nil {
+				return err
+			}
+			for i, d := range newDistricts {
+				districtIDs[districtNew[i]] = d.ID
+			}
+		}
+
+		var newCities []models.City
+		links := make(map[uint]uint) // existing city without a district -> district
+		for _, row := range rows {
+			if row.get("city") == "" {
+				continue
+			}
+			var districtID *uint
+			if id := districtIDs[locationPath(row, "district")]; row.get("district") != "" && id != 0 {
+				districtID = &id
+			}
+			path := locationPath(row, "city")
+			city, ok := cities[path]
+			if !ok {
+				city = models.City{Name: row.get("city"), StateID: stateIDs[locationPath(row, "state")], DistrictID: districtID}
+				cities[path] = city
+				newCities = append(newCities, city)
+				continue
+			}
+			if _, linked := links[city.ID]; city.ID != 0 && city.DistrictID == nil && districtID != nil && !linked {
+				links[city.ID] = *districtID
+			}
+		}
+		result.Cities = len(newCities)
+		result.CitiesLinked = len(links)
+		if dryRun {
+			return nil
+		}
+		if len(newCities) > 0 {
+			if err := tx.CreateInBatches(&newCities, locationImportBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		cityIDs := make([]uint, 0, len(links))
+		for id := range links {
+			cityIDs = append(cityIDs, id)
+		}
+		sort.Slice(cityIDs, func(i, j int) bool { return cityIDs[i] < cityIDs[j] })
+		for _, id := range cityIDs {
+			if err := tx.Model(&models.City{}).Where("id = ? AND district_id IS NULL", id).
+				Update("district_id", links[id]).Error; err != nil {
+				return fmt.Errorf("linking city %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		InvalidateGeoCache()
+		utils.BaseLogger().Info("Locations imported",
+			zap.Int("rows", result.TotalRows),
+			zap.Int("countries", result.Countries),
+			zap.Int("states", result.States),
+			zap.Int("districts", result.Districts),
+			zap.Int("cities", result.Cities),
+			zap.Int("cities_linked", result.CitiesLinked))
+	}
+	return result, nil
+}
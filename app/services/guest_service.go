@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGuestNotFound is returned for unknown guest master IDs
+	ErrGuestNotFound = errors.New("guest not found")
+	// ErrInvalidGuestFollowUp is returned for follow-up dates in the future
+	ErrInvalidGuestFollowUp = errors.New("invalid follow-up")
+)
+
+// maxGuestFollowUps caps the appearances listed by GetGuestFollowUps
+const maxGuestFollowUps = 2000
+
+// GuestSummary is a guest master record with the number of events they appeared at
+type GuestSummary struct {
+	models.Guest
+	Appearances    int64      `json:"appearances"`
+	LastAppearance *time.Time `json:"last_appearance,omitempty"`
+}
+
+// GuestAppearance is an appearance of a guest at an event, with its follow-up
+type GuestAppearance struct {
+	SpecialGuestID     uint       `json:"special_guest_id"`
+	GuestID            *uint      `json:"guest_id,omitempty"`
+	Name               string     `json:"name"`
+	Prefix             string     `json:"prefix,omitempty"`
+	FirstName          string     `json:"-"`
+	MiddleName         string     `json:"-"`
+	LastName           string     `json:"-"`
+	Designation        string     `json:"designation,omitempty"`
+	Organization       string     `json:"organization,omitempty"`
+	Email              string     `json:"email,omitempty"`
+	PersonalNumber     string     `json:"personal_number,omitempty"`
+	EventID            uint       `json:"event_id"`
+	ReportNumber       string     `json:"report_number,omitempty"`
+	EventType          string     `json:"event_type,omitempty"`
+	Theme              string     `json:"theme,omitempty"`
+	StartDate          time.Time  `json:"start_date"`
+	BranchID           *uint      `json:"branch_id,omitempty"`
+	BranchName         string     `json:"branch_name,omitempty"`
+	LetterSentOn       *time.Time `json:"letter_sent_on,omitempty"`
+	FeedbackReceivedOn *time.Time `json:"feedback_received_on,omitempty"`
+	Feedback           string     `json:"feedback,omitempty"`
+}
+
+// GuestProfile is a guest master record with all their appearances, most recent first
+type GuestProfile struct {
+	models.Guest
+	Appearances []GuestAppearance `json:"appearances"`
+}
+
+// GuestFollowUpFilter selects the appearances listed for protocol follow-up. Dates filter
+// on the event start date; LetterSent and FeedbackReceived, when set, keep appearances with
+// (true) or without (false) a letter sent or feedback received.
+type GuestFollowUpFilter struct {
+	From             *time.Time
+	To               *time.Time
+	BranchID         uint
+	LetterSent       *bool
+	FeedbackReceived *bool
+	Scope            *BranchScope // nil is unrestricted
+}
+
+// GuestFollowUpInput sets the follow-up of an appearance; nil fields are left unchanged and
+// ClearLetter/ClearFeedback reset them
+type GuestFollowUpInput struct {
+	LetterSentOn       *time.Time
+	FeedbackReceivedOn *time.Time
+	Feedback           *string
+	ClearLetter        bool
+	ClearFeedback      bool
+}
+
+// BackfillGuests links special guest appearances recorded before the guests table existed to
+// master records. Only appearances without a guest are touched.
+func BackfillGuests() {
+	var appearances []models.SpecialGuest
+	result := config.DB.Where("guest_id IS NULL").
+		FindInBatches(&appearances, 500, func(tx *gorm.DB, batch int) error {
+			for i := range appearances {
+				if appearances[i].FullName() == "" {
+					continue
+				}
+				guestID, err := models.LinkGuest(config.DB, &appearances[i])
+				if err != nil {
+					return err
+				}
+				if err := config.DB.Model(&models.SpecialGuest{}).Where("id = ? AND guest_id IS NULL", appearances[i].ID).
+					UpdateColumn("guest_id", guestID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill special guest masters", zap.Error(result.Error))
+	}
+}
+
+// SearchGuests lists guest master records by name (across scripts/spellings), organization,
+// designation, email or contact number, with their number of appearances. Guests are shared
+// across branches: a scoped user sees the guests who appeared at events of their branches,
+// with those appearances only.
+func SearchGuests(search string, limit, offset int, scope *BranchScope) ([]GuestSummary, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	join := "LEFT JOIN"
+	if scope != nil {
+		join = "JOIN"
+	}
+	query := config.DB.Table("guests g").
+		Select("g.*, COUNT(sg.id) AS appearances, MAX(e.start_date) AS last_appearance").
+		Joins(join + " special_guests sg ON sg.guest_id = g.id").
+		Joins(join + " event_details e ON e.id = sg.event_id AND e.deleted_at IS NULL").
+		Group("g.id")
+	query = scope.Apply(query, "e.branch_id")
+	if search = strings.TrimSpace(search); search != "" {
+		like := "%" + search + "%"
+		conditions := config.DB.Where("CONCAT_WS(' ', g.first_name, g.middle_name, g.last_name) ILIKE ?", like).
+			Or("g.organization ILIKE ?", like).
+			Or("g.designation ILIKE ?", like).
+			Or("g.email ILIKE ?", like)
+		if contact := models.NormalizeContact(search); len(contact) >= 4 {
+			conditions = conditions.Or("g.contact LIKE ?", "%"+contact+"%")
+		}
+		if key := utils.NameKey(search); key != "" {
+			conditions = conditions.Or("g.name_key LIKE ?", "%"+key+"%")
+		}
+		query = query.Where(conditions)
+	}
+
+	guests := []GuestSummary{}
+	if err := query.Order("g.first_name, g.last_name, g.id").Limit(limit).Offset(offset).Scan(&guests).Error; err != nil {
+		return nil, err
+	}
+	return guests, nil
+}
+
+// GetGuestProfile returns a guest master record with all their appearances, or for a scoped
+// user their appearances at events of the scope's branches (ErrOutOfBranchScope when there
+// are none)
+func GetGuestProfile(id uint, scope *BranchScope) (*GuestProfile, error) {
+	var guest models.Guest
+	if err := config.DB.First(&guest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGuestNotFound
+		}
+		return nil, err
+	}
+
+	profile := &GuestProfile{Guest: guest}
+	query := scope.Apply(guestAppearanceQuery().Where("sg.guest_id = ?", guest.ID), "e.branch_id")
+	appearances, err := scanGuestAppearances(query.Order("e.start_date DESC, sg.id DESC"), 0)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil && len(appearances) == 0 {
+		return nil, ErrOutOfBranchScope
+	}
+	profile.Appearances = appearances
+	return profile, nil
+}
+
+// UpdateGuest updates the details of a guest master record. Appearances keep the details
+// recorded at the time of the event. Scoped users may only update guests who appeared at an
+// event of their branches.
+func UpdateGuest(id uint, updates map[string]interface{}, scope *BranchScope) (*models.Guest, error) {
+	var guest models.Guest
+	if err := config.DB.First(&guest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGuestNotFound
+		}
+		return nil, err
+	}
+	if scope != nil {
+		var appearances int64
+		err := scope.Apply(config.DB.Table("special_guests sg").
+			Joins("JOIN event_details e ON e.id = sg.event_id AND e.deleted_at IS NULL").
+			Where("sg.guest_id = ?", id), "e.branch_id").Count(&appearances).Error
+		if err != nil {
+			return nil, err
+		}
+		if appearances == 0 {
+			return nil, ErrOutOfBranchScope
+		}
+	}
+
+	nameChanged := false
+	for field, target := range map[string]*string{
+		"first_name":  &guest.FirstName,
+		"middle_name": &guest.MiddleName,
+		"last_name":   &guest.LastName,
+	} {
+		if value, ok := updates[field].(string); ok {
+			*target = value
+			nameChanged = true
+		}
+	}
+	if nameChanged {
+		updates["name_key"] = utils.NameKey(guest.FullName())
+	}
+	if email, ok := updates["email"].(string); ok {
+		updates["email"] = strings.ToLower(strings.TrimSpace(email))
+	}
+	if contact, ok := updates["contact"].(string); ok {
+		updates["contact"] = models.NormalizeContact(contact)
+	}
+
+	if err := config.DB.Model(&guest).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := config.DB.First(&guest, id).Error; err != nil {
+		return nil, err
+	}
+	return &guest, nil
+}
+
+// GetGuestFollowUps lists the guest appearances at events in the filter's date range for
+// protocol follow-up, oldest event first. truncated is set when more than maxGuestFollowUps
+// appearances match.
+func GetGuestFollowUps(filter GuestFollowUpFilter) (appearances []GuestAppearance, truncated bool, err error) {
+	query := excludeSandbox(guestAppearanceQuery(), "e.branch_id")
+	if filter.From != nil {
+		query = query.Where("e.start_date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("e.start_date <= ?", *filter.To)
+	}
+	if filter.BranchID > 0 {
+		query = query.Where("e.branch_id = ?", filter.BranchID)
+	}
+	query = filter.Scope.Apply(query, "e.branch_id")
+	if filter.LetterSent != nil {
+		query = query.Where(nullCondition("sg.letter_sent_on", *filter.LetterSent))
+	}
+	if filter.FeedbackReceived != nil {
+		query = query.Where(nullCondition("sg.feedback_received_on", *filter.FeedbackReceived))
+	}
+
+	appearances, err = scanGuestAppearances(query.Order("e.start_date, sg.id"), maxGuestFollowUps+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(appearances) > maxGuestFollowUps {
+		return appearances[:maxGuestFollowUps], true, nil
+	}
+	return appearances, false, nil
+}
+
+// UpdateGuestFollowUp records the follow-up of a special guest appearance: the date the
+// thank-you letter was sent and the date and content of the feedback received
+func UpdateGuestFollowUp(specialGuestID uint, input GuestFollowUpInput) (*GuestAppearance, error) {
+	var appearance models.SpecialGuest
+	if err := config.DB.First(&appearance, specialGuestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSpecialGuestNotFound
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if input.LetterSentOn != nil && input.LetterSentOn.After(now) {
+		return nil, fmt.Errorf("%w: letter_sent_on must not be in the future", ErrInvalidGuestFollowUp)
+	}
+	if input.FeedbackReceivedOn != nil && input.FeedbackReceivedOn.After(now) {
+		return nil, fmt.Errorf("%w: feedback_received_on must not be in the future", ErrInvalidGuestFollowUp)
+	}
+
+	updates := map[string]interface{}{"updated_on": &now}
+	switch {
+	case input.ClearLetter:
+		updates["letter_sent_on"] = nil
+	case input.LetterSentOn != nil:
+		updates["letter_sent_on"] = *input.LetterSentOn
+	}
+	switch {
+	case input.ClearFeedback:
+		updates["feedback_received_on"] = nil
+		updates["feedback"] = ""
+	default:
+		if input.FeedbackReceivedOn != nil {
+			updates["feedback_received_on"] = *input.FeedbackReceivedOn
+		}
+		if input.Feedback != nil {
+			updates["feedback"] = strings.TrimSpace(*input.Feedback)
+		}
+	}
+	if err := config.DB.Model(&appearance).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	appearances, err := scanGuestAppearances(guestAppearanceQuery().Where("sg.id = ?", appearance.ID), 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(appearances) == 0 {
+		return nil, ErrSpecialGuestNotFound
+	}
+	return &appearances[0], nil
+}
+
+// guestAppearanceQuery selects special guest appearances (alias sg) at live events (alias e)
+func guestAppearanceQuery() *gorm.DB {
+	return config.DB.Table("special_guests sg").
+		Select(`sg.id AS special_guest_id, sg.guest_id, sg.prefix, sg.first_name, sg.middle_name, sg.last_name,
+			sg.designation, sg.organization, sg.email, sg.personal_number, sg.event_id, e.report_number,
+			et.name AS event_type, e.theme, e.start_date, e.branch_id, b.name AS branch_name,
+			sg.letter_sent_on, sg.feedback_received_on, sg.feedback`).
+		Joins("JOIN event_details e ON e.id = sg.event_id AND e.deleted_at IS NULL").
+		Joins("LEFT JOIN event_types et ON et.id = e.event_type_id").
+		Joins("LEFT JOIN branches b ON b.id = e.branch_id")
+}
+
+func scanGuestAppearances(query *gorm.DB, limit int) ([]GuestAppearance, error) {
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	appearances := []GuestAppearance{}
+	if err := query.Scan(&appearances).Error; err != nil {
+		return nil, err
+	}
+	for i := range appearances {
+		a := &appearances[i]
+		a.Name = joinNonEmpty(" ", a.FirstName, a.MiddleName, a.LastName)
+	}
+	return appearances, nil
+}
+
+// nullCondition is "column IS NOT NULL" when set, else "column IS NULL"
+func nullCondition(column string, set bool) string {
+	if set {
+		return column + " IS NOT NULL"
+	}
+	return column + " IS NULL"
+}
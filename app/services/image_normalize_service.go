@@ -0,0 +1,464 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+var (
+	// ErrHEICNotSupported is returned for HEIC/HEIF uploads when no converter is configured
+	ErrHEICNotSupported = errors.New("HEIC images are not supported (set MEDIA_HEIC_CONVERTER)")
+	// ErrInvalidImage is returned for images whose structure cannot be parsed
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+// NormalizedImage is an uploaded image as it is stored
+type NormalizedImage struct {
+	Data        []byte
+	ContentType string
+	Filename    string
+}
+
+// imageNormalizeJPEGQuality is used when a photo has to be re-encoded (rotation, resizing)
+const imageNormalizeJPEGQuality = 90
+
+// imageNormalizeEnabled reports whether uploads are normalized (MEDIA_IMAGE_NORMALIZE, default true)
+func imageNormalizeEnabled() bool {
+	return config.Media.ImageNormalize
+}
+
+// imageMaxSide returns MEDIA_IMAGE_MAX_SIDE, the longest side photos are scaled down to
+// (0, the default, keeps the original dimensions)
+func imageMaxSide() int {
+	return config.Media.ImageMaxSide
+}
+
+// IsHEIC reports whether the content type is a HEIC/HEIF photo (the iPhone camera default)
+func IsHEIC(contentType string) bool {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "image/heic", "image/heif", "image/heic-sequence", "image/heif-sequence":
+		return true
+	}
+	return false
+}
+
+// NormalizeImage prepares an uploaded photo for storage: HEIC is converted to JPEG, EXIF/XMP/IPTC
+// metadata (GPS position, camera serial numbers) is removed, the EXIF orientation is applied to
+// the pixels, and photos larger than MEDIA_IMAGE_MAX_SIDE are scaled down. JPEGs and PNGs that
+// need neither rotation nor scaling are not re-encoded, only their metadata segments are
+// dropped. Other files (and GIF/WebP images) are returned unchanged; set
+// MEDIA_IMAGE_NORMALIZE=false to store uploads as they are.
+func NormalizeImage(ctx context.Context, data []byte, contentType, filename string) (*NormalizedImage, error) {
+	result := &NormalizedImage{Data: data, ContentType: contentType, Filename: filename}
+	if !imageNormalizeEnabled() {
+		return result, nil
+	}
+
+	if IsHEIC(contentType) {
+		converter, err := getHEICConverter()
+		if err != nil {
+			return nil, err
+		}
+		converted, err := converter.Convert(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: HEIC conversion failed: %v", ErrInvalidImage, err)
+		}
+		result.Data = converted
+		result.ContentType = "image/jpeg"
+		result.Filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".jpg"
+	}
+
+	var err error
+	switch strings.ToLower(strings.TrimSpace(strings.Split(result.ContentType, ";")[0])) {
+	case "image/jpeg", "image/jpg":
+		result.Data, err = normalizeJPEG(result.Data, imageMaxSide())
+	case "image/png":
+		result.Data, err = normalizePNG(result.Data, imageMaxSide())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeJPEG strips metadata from a JPEG, re-encoding it only to apply the orientation or
+// to scale it down. Re-encoded photos lose their ICC profile and are stored as sRGB.
+func normalizeJPEG(data []byte, maxSide int) ([]byte, error) {
+	stripped, orientation, err := stripJPEGMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	tooLarge := maxSide > 0 && (cfg.Width > maxSide || cfg.Height > maxSide)
+	if orientation <= 1 && !tooLarge {
+		return stripped, nil
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("%w: image too large (%dx%d)", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	src, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	img := scaleToMaxSide(orientImage(src, orientation), maxSide)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageNormalizeJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizePNG drops the metadata chunks of a PNG, re-encoding it only to scale it down
+func normalizePNG(data []byte, maxSide int) ([]byte, error) {
+	stripped, err := stripPNGMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	if maxSide <= 0 {
+		return stripped, nil
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width <= maxSide && cfg.Height <= maxSide {
+		return stripped, nil
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("%w: image too large (%dx%d)", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	src, err := png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleToMaxSide(src, maxSide)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JPEG markers
+const (
+	jpegSOI  = 0xD8
+	jpegEOI  = 0xD9
+	jpegSOS  = 0xDA
+	jpegAPP1 = 0xE1 // EXIF, XMP
+	jpegAPP2 = 0xE2 // ICC profile, multi-picture index
+	jpegAPPD = 0xED // IPTC (Photoshop)
+	jpegCOM  = 0xFE
+)
+
+// stripJPEGMetadata removes the EXIF/XMP, IPTC and comment segments of a JPEG, and the extra
+// images phones append after it (depth maps, previews, each with its own EXIF), and returns the
+// EXIF orientation (1-8, 0 when absent). The compressed image data is copied unchanged.
+func stripJPEGMetadata(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return nil, 0, fmt.Errorf("%w: not a JPEG file", ErrInvalidImage)
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, jpegSOI)
+	orientation := 0
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, 0, fmt.Errorf("%w: corrupt JPEG segment", ErrInvalidImage)
+		}
+		// Markers may be padded with any number of 0xFF bytes
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+		marker := data[pos]
+		pos++
+
+		if marker == jpegEOI || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			out = append(out, 0xFF, marker)
+			if marker == jpegEOI {
+				break
+			}
+			continue
+		}
+		if pos+2 > len(data) {
+			return nil, 0, fmt.Errorf("%w: truncated JPEG", ErrInvalidImage)
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, 0, fmt.Errorf("%w: truncated JPEG", ErrInvalidImage)
+		}
+		segment := data[pos+2 : pos+length]
+
+		switch marker {
+		case jpegSOS:
+			// Copy the scan header and its entropy-coded data, which ends at the next marker
+			// (0xFF00 is an escaped data byte, 0xFFD0-D7 are restart markers)
+			end := pos + length
+			for end+1 < len(data) && (data[end] != 0xFF || data[end+1] == 0 || (data[end+1] >= 0xD0 && data[end+1] <= 0xD7)) {
+				end++
+			}
+			if end+1 >= len(data) {
+				end = len(data)
+			}
+			out = append(out, 0xFF, marker)
+			out = append(out, data[pos:end]...)
+			pos = end
+			continue
+		case jpegAPP1:
+			if orientation == 0 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(segment[6:])
+			}
+		case jpegAPP2:
+			// Multi-picture index of the images appended after EOI (which are dropped); ICC
+			// profiles are kept
+			if !bytes.HasPrefix(segment, []byte("MPF\x00")) {
+				out = append(out, 0xFF, marker)
+				out = append(out, data[pos:pos+length]...)
+			}
+		case jpegAPPD, jpegCOM:
+		default:
+			out = append(out, 0xFF, marker)
+			out = append(out, data[pos:pos+length]...)
+		}
+		pos += length
+	}
+	return out, orientation, nil
+}
+
+// exifOrientation reads the Orientation tag (0x0112) of IFD0 from a TIFF structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// pngMetadataChunks can carry EXIF (including GPS), free text and timestamps
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "iTXt": true, "zTXt": true, "tIME": true}
+
+// stripPNGMetadata removes the metadata chunks of a PNG
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, fmt.Errorf("%w: not a PNG file", ErrInvalidImage)
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	pos := len(signature)
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("%w: truncated PNG", ErrInvalidImage)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("%w: truncated PNG", ErrInvalidImage)
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if crc32.ChecksumIEEE(data[pos+4:pos+8+length]) != binary.BigEndian.Uint32(data[pos+8+length:]) {
+			return nil, fmt.Errorf("%w: corrupt PNG chunk %s", ErrInvalidImage, chunkType)
+		}
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+// orientImage applies an EXIF orientation (2-8) so the image is upright with orientation 1
+func orientImage(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// 5-8 swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // needs a 90° clockwise rotation
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs a 90° counter-clockwise rotation
+				dx, dy = y, w-1-x
+			}
+			si := rgba.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], rgba.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// scaleToMaxSide scales an image down so its longer side is at most maxSide (0 keeps it)
+func scaleToMaxSide(src image.Image, maxSide int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSide <= 0 || (width <= maxSide && height <= maxSide) {
+		return src
+	}
+	if width >= height {
+		height = max(height*maxSide/width, 1)
+		width = maxSide
+	} else {
+		width = max(width*maxSide/height, 1)
+		height = maxSide
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// HEICConverter turns HEIC/HEIF photos into JPEG. Go has no HEIC decoder, so the built-in
+// CommandHEICConverter runs an external tool; a conversion service can plug in through
+// SetHEICConverter.
+type HEICConverter interface {
+	Name() string
+	Convert(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// CommandHEICConverter runs "<Command> <input.heic> <output.jpg>", which fits libheif's
+// heif-convert and ImageMagick's magick. The output must be upright; any EXIF orientation it
+// keeps is applied by NormalizeImage.
+type CommandHEICConverter struct {
+	Command string
+}
+
+func (c CommandHEICConverter) Name() string { return filepath.Base(c.Command) }
+
+// heicConvertTimeout bounds one conversion
+const heicConvertTimeout = 30 * time.Second
+
+// Convert writes the photo to a temporary directory and runs the command on it
+func (c CommandHEICConverter) Convert(ctx context.Context, data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "heic-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.heic")
+	output := filepath.Join(dir, "output.jpg")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, heicConvertTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, c.Command, input, output).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", c.Name(), err, strings.TrimSpace(string(out)))
+	}
+	return os.ReadFile(output)
+}
+
+var (
+	heicConverter     HEICConverter
+	heicConverterOnce sync.Once
+)
+
+// SetHEICConverter overrides the converter (e.g. a conversion service, or a fake in tests)
+func SetHEICConverter(converter HEICConverter) {
+	heicConverterOnce.Do(func() {})
+	heicConverter = converter
+}
+
+// getHEICConverter returns the converter command named by MEDIA_HEIC_CONVERTER (e.g.
+// "heif-convert"); without it HEIC uploads are rejected
+func getHEICConverter() (HEICConverter, error) {
+	heicConverterOnce.Do(func() {
+		command := config.Media.HEICConverter
+		if command == "" {
+			return
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			utils.BaseLogger().Warn("MEDIA_HEIC_CONVERTER not found, HEIC uploads disabled", zap.String("command", command), zap.Error(err))
+			return
+		}
+		heicConverter = CommandHEICConverter{Command: command}
+	})
+	if heicConverter == nil {
+		return nil, ErrHEICNotSupported
+	}
+	return heicConverter, nil
+}
+
+// HEICSupported reports whether HEIC/HEIF uploads can be converted, and are thus accepted
+func HEICSupported() bool {
+	_, err := getHEICConverter()
+	return err == nil && imageNormalizeEnabled()
+}
@@ -0,0 +1,591 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Job types, see jobHandlerFor
+const (
+	JobTypeThumbnails        = "thumbnails"
+	JobTypeOCR               = "ocr"
+	JobTypeEventReport       = "event_report"
+	JobTypeBranchImport      = "branch_import"
+	JobTypeEmail             = "email"
+	JobTypeMessage           = "message"
+	JobTypeCredentials       = "credentials"
+	JobTypeStorageCleanup    = "storage_cleanup"
+	JobTypeMediaScan         = "media_scan_backfill"
+	JobTypeMediaUploadScan   = "media_upload_scan"
+	JobTypeMediaExport       = "media_export"
+	JobTypeMediaImport       = "media_import"
+	JobTypeApprovalDigest    = "approval_digest"
+	JobTypeGeocodeBranch     = "branch_geocode"
+	JobTypeGarbageCollection = "garbage_collection"
+	JobTypeTranscode         = "media_transcode"
+	JobTypeStorageReconcile  = "storage_reconcile"
+	JobTypeStorageUsage      = "storage_usage"
+	JobTypeWebhookDelivery   = "webhook_delivery"
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrUnknownJobType   = errors.New("unknown job type")
+	ErrJobNotRetryable  = errors.New("only failed jobs can be retried")
+	errJobHandlerPanics = errors.New("job handler panicked")
+)
+
+const (
+	defaultJobWorkers = 2
+	jobPollInterval   = 5 * time.Second
+	jobTimeout        = 10 * time.Minute
+	// jobStaleAfter is how long a running job may go without a progress update before the
+	// reaper assumes its worker died. Jobs longer than this must call SetProgress regularly.
+	jobStaleAfter      = 15 * time.Minute
+	jobRetryBaseDelay  = 30 * time.Second
+	jobReaperInterval  = time.Minute
+	defaultMaxAttempts = 3
+	// jobFileRetention is how long files produced by jobs (reports) are kept
+	jobFileRetention = 7 * 24 * time.Hour
+	// jobFileURLExpiry is the lifetime of the download_url added to job results
+	jobFileURLExpiry = time.Hour
+)
+
+// JobHandler runs one job. The returned result is stored on the job for the frontend;
+// returning an error schedules a retry until MaxAttempts is reached.
+type JobHandler func(ctx context.Context, run *JobRun) (models.JSONB, error)
+
+// jobHandlerFor maps job types to their handlers. A switch rather than a map so handlers
+// can enqueue follow-up jobs without an initialization cycle.
+func jobHandlerFor(jobType string) (JobHandler, bool) {
+	switch jobType {
+	case JobTypeThumbnails:
+		return runThumbnailJob, true
+	case JobTypeOCR:
+		return runOCRJob, true
+	case JobTypeEventReport:
+		return runEventReportJob, true
+	case JobTypeBranchImport:
+		return runBranchImportJob, true
+	case JobTypeEmail:
+		return runEmailJob, true
+	case JobTypeMessage:
+		return runMessageJob, true
+	case JobTypeCredentials:
+		return runCredentialsJob, true
+	case JobTypeStorageCleanup:
+		return runStorageCleanupJob, true
+	case JobTypeMediaScan:
+		return runMediaScanBackfillJob, true
+	case JobTypeMediaUploadScan:
+		return runMediaUploadScanJob, true
+	case JobTypeMediaExport:
+		return runMediaExportJob, true
+	case JobTypeMediaImport:
+		return runMediaImportJob, true
+	case JobTypeApprovalDigest:
+		return runApprovalDigestJob, true
+	case JobTypeGeocodeBranch:
+		return runBranchGeocodeJob, true
+	case JobTypeGarbageCollection:
+		return runGarbageCollectionJob, true
+	case JobTypeTranscode:
+		return runTranscodeJob, true
+	case JobTypeStorageReconcile:
+		return runStorageReconcileJob, true
+	case JobTypeStorageUsage:
+		return runStorageUsageJob, true
+	case JobTypeWebhookDelivery:
+		return runWebhookDeliveryJob, true
+	}
+	return nil, false
+}
+
+// jobTimeoutFor is how long a job of the type may run per attempt
+func jobTimeoutFor(jobType string) time.Duration {
+	switch jobType {
+	case JobTypeMediaScan:
+		return mediaScanBackfillTimeout
+	case JobTypeMediaExport, JobTypeMediaImport:
+		return mediaTransferTimeout
+	case JobTypeTranscode:
+		return transcodeJobTimeout
+	case JobTypeStorageReconcile:
+		return storageReconcileTimeout
+	case JobTypeStorageUsage:
+		return storageUsageTimeout
+	}
+	return jobTimeout
+}
+
+// permanentJobError marks a failure that a retry cannot fix (bad input, deleted records)
+type permanentJobError struct{ err error }
+
+func (e permanentJobError) Error() string { return e.err.Error() }
+func (e permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError makes a JobHandler error fail the job without further attempts
+func PermanentJobError(err error) error {
+	return permanentJobError{err: err}
+}
+
+// JobRun is passed to a JobHandler
+type JobRun struct {
+	Job *models.Job
+}
+
+// Decode unmarshals the job payload into v
+func (r *JobRun) Decode(v interface{}) error {
+	raw, err := json.Marshal(r.Job.Payload)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return PermanentJobError(fmt.Errorf("invalid %s payload: %w", r.Job.Type, err))
+	}
+	return nil
+}
+
+// SetProgress records how far the job has got (0-100) for clients polling the job
+func (r *JobRun) SetProgress(percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	r.Job.Progress, r.Job.ProgressMessage = percent, message
+	err := config.DB.Model(&models.Job{}).Where("id = ?", r.Job.ID).
+		UpdateColumns(map[string]interface{}{"progress": percent, "progress_message": message, "updated_on": time.Now()}).Error
+	if err != nil {
+		utils.BaseLogger().Warn("Failed to record job progress", zap.Uint("job_id", r.Job.ID), zap.Error(err))
+	}
+}
+
+// SaveCheckpoint replaces the stored payload, so a retry after a failure or crash resumes
+// from where this attempt got to instead of starting over
+func (r *JobRun) SaveCheckpoint(payload interface{}) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var data models.JSONB
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	r.Job.Payload = data
+	return config.DB.Model(&models.Job{}).Where("id = ?", r.Job.ID).
+		UpdateColumns(map[string]interface{}{"payload": data, "updated_on": time.Now()}).Error
+}
+
+// JobOptions are optional settings for EnqueueJob
+type JobOptions struct {
+	CreatedBy   *uint
+	MaxAttempts int       // default 3
+	RunAt       time.Time // default now
+}
+
+// jobWake lets EnqueueJob wake an idle worker in this process instead of waiting for the next poll
+var jobWake = make(chan struct{}, 1)
+
+// EnqueueJob stores a job for the workers. The payload is stored as JSON in the jobs table,
+// so it must not hold secrets (passwords, reset tokens) or large binary data.
+func EnqueueJob(ctx context.Context, jobType string, payload interface{}, opts JobOptions) (*models.Job, error) {
+	if _, ok := jobHandlerFor(jobType); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var data models.JSONB
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("job payload must be a JSON object: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Status:      models.JobStatusQueued,
+		Payload:     data,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+		CreatedBy:   opts.CreatedBy,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if err := config.DB.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+var (
+	jobWorkersOnce sync.Once
+	jobWorkersStop = make(chan struct{})
+	stopJobsOnce   sync.Once
+	jobWorkersWG   sync.WaitGroup
+	// jobsCtx is cancelled when running jobs outlast the shutdown timeout
+	jobsCtx, cancelJobs = context.WithCancel(context.Background())
+)
+
+// StartJobWorkers starts JOB_WORKERS workers (default 2) and a reaper for jobs left running by
+// a crashed process. JOB_WORKERS=0 runs no workers, e.g. on API-only instances; jobs are then
+// picked up by whichever instances do run them.
+func StartJobWorkers() {
+	jobWorkersOnce.Do(func() {
+		workers := config.Jobs.Workers
+		if workers == 0 {
+			utils.BaseLogger().Info("Job workers disabled (JOB_WORKERS=0)")
+			return
+		}
+
+		hostname, _ := os.Hostname()
+		for i := 1; i <= workers; i++ {
+			jobWorkersWG.Add(1)
+			go runJobWorker(fmt.Sprintf("%s/%d/%d", hostname, os.Getpid(), i))
+		}
+		go func() {
+			ticker := time.NewTicker(jobReaperInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-jobWorkersStop:
+					return
+				case <-ticker.C:
+				}
+				if err := requeueStaleJobs(); err != nil {
+					utils.BaseLogger().Error("Failed to requeue stale jobs", zap.Error(err))
+				}
+			}
+		}()
+		utils.BaseLogger().Info("Job workers started", zap.Int("workers", workers))
+	})
+}
+
+// StopJobWorkers stops claiming jobs and waits for the running ones to finish. Jobs still
+// running when ctx is done are cancelled and queued again without using up an attempt, so
+// another instance picks them up.
+func StopJobWorkers(ctx context.Context) error {
+	stopJobsOnce.Do(func() { close(jobWorkersStop) })
+	done := make(chan struct{})
+	go func() {
+		jobWorkersWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	cancelJobs()
+	// Give the cancelled jobs a moment to record that they were interrupted
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+	}
+	return ctx.Err()
+}
+
+func runJobWorker(workerID string) {
+	defer jobWorkersWG.Done()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain the queue before going back to sleep
+		for {
+			select {
+			case <-jobWorkersStop:
+				return
+			default:
+			}
+			job, err := claimJob(workerID)
+			if err != nil {
+				utils.BaseLogger().Error("Failed to claim job", zap.String("worker", workerID), zap.Error(err))
+				break
+			}
+			if job == nil {
+				break
+			}
+			processJob(job)
+		}
+
+		select {
+		case <-jobWorkersStop:
+			return
+		case <-jobWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimJob marks the oldest due job as running. SKIP LOCKED lets several workers and
+// instances poll the same table without handing out a job twice.
+func claimJob(workerID string) (*models.Job, error) {
+	var job models.Job
+	err := config.DB.Raw(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, locked_by = ?, started_on = NOW(), updated_on = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_at <= NOW()
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, models.JobStatusRunning, workerID, models.JobStatusQueued).Scan(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func processJob(job *models.Job) {
+	logger := utils.BaseLogger().With(zap.Uint("job_id", job.ID), zap.String("type", job.Type), zap.Int("attempt", job.Attempts))
+
+	ctx, cancel := context.WithTimeout(jobsCtx, jobTimeoutFor(job.Type))
+	defer cancel()
+
+	started := time.Now()
+	result, err := runJobHandler(ctx, job)
+	now := time.Now()
+
+	updates := map[string]interface{}{"locked_by": "", "updated_on": now}
+	switch {
+	case err != nil && jobsCtx.Err() != nil:
+		// Interrupted by a shutdown; the attempt does not count
+		updates["status"] = models.JobStatusQueued
+		updates["run_at"] = now
+		updates["attempts"] = max(job.Attempts-1, 0)
+		logger.Warn("Job interrupted by shutdown, queued again", zap.Error(err))
+	case err == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["progress"] = 100
+		updates["result"] = result
+		updates["error"] = ""
+		updates["finished_on"] = now
+		logger.Info("Job succeeded", zap.Duration("duration", now.Sub(started)))
+	case job.Attempts < job.MaxAttempts && !errors.As(err, &permanentJobError{}):
+		delay := jobRetryDelay(job.Attempts)
+		updates["status"] = models.JobStatusQueued
+		updates["run_at"] = now.Add(delay)
+		updates["error"] = err.Error()
+		logger.Warn("Job failed, will retry", zap.Duration("retry_in", delay), zap.Error(err))
+	default:
+		updates["status"] = models.JobStatusFailed
+		updates["error"] = err.Error()
+		updates["finished_on"] = now
+		metrics.JobsFailed.WithLabelValues(job.Type).Inc()
+		logger.Error("Job failed", zap.Error(err))
+	}
+
+	if dbErr := config.DB.Model(&models.Job{}).Where("id = ?", job.ID).UpdateColumns(updates).Error; dbErr != nil {
+		logger.Error("Failed to record job outcome", zap.Error(dbErr))
+	}
+	if status := updates["status"]; status == models.JobStatusSucceeded || status == models.JobStatusFailed {
+		notifyJobFinished(context.Background(), job, err)
+	}
+}
+
+// jobRetryDelay is the exponential backoff after a failed attempt: 30s, 1m, 2m, ...
+func jobRetryDelay(attempts int) time.Duration {
+	return jobRetryBaseDelay << (attempts - 1)
+}
+
+func runJobHandler(ctx context.Context, job *models.Job) (result models.JSONB, err error) {
+	handler, ok := jobHandlerFor(job.Type)
+	if !ok {
+		return nil, PermanentJobError(fmt.Errorf("%w: %s", ErrUnknownJobType, job.Type))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errJobHandlerPanics, r)
+		}
+	}()
+	return handler(ctx, &JobRun{Job: job})
+}
+
+// requeueStaleJobs puts back jobs whose worker died mid-run (no update for jobStaleAfter).
+// Jobs that used up their attempts are failed instead.
+func requeueStaleJobs() error {
+	cutoff := time.Now().Add(-jobStaleAfter)
+	if err := config.DB.Model(&models.Job{}).
+		Where("status = ? AND updated_on < ? AND attempts >= max_attempts", models.JobStatusRunning, cutoff).
+		UpdateColumns(map[string]interface{}{
+			"status": models.JobStatusFailed, "error": "worker stopped while running the job",
+			"finished_on": time.Now(), "locked_by": "", "updated_on": time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+	result := config.DB.Model(&models.Job{}).
+		Where("status = ? AND updated_on < ?", models.JobStatusRunning, cutoff).
+		UpdateColumns(map[string]interface{}{
+			"status": models.JobStatusQueued, "run_at": time.Now(), "locked_by": "", "updated_on": time.Now(),
+		})
+	if result.RowsAffected > 0 {
+		utils.BaseLogger().Warn("Requeued stale jobs", zap.Int64("count", result.RowsAffected))
+	}
+	return result.Error
+}
+
+// GetJob returns a job with a fresh download_url for jobs that produced a file
+func GetJob(ctx context.Context, id uint) (*models.Job, error) {
+	var job models.Job
+	if err := config.DB.WithContext(ctx).Limit(1).Find(&job, id).Error; err != nil {
+		return nil, err
+	}
+	if job.ID == 0 {
+		return nil, ErrJobNotFound
+	}
+	addJobDownloadURL(ctx, &job)
+	return &job, nil
+}
+
+// addJobDownloadURL presigns the file_key of a job result. The URL is not stored because it
+// expires long before the file does.
+func addJobDownloadURL(ctx context.Context, job *models.Job) {
+	key, _ := job.Result["file_key"].(string)
+	if key == "" || job.Status != models.JobStatusSucceeded {
+		return
+	}
+	url, err := GetPresignedURL(ctx, key, jobFileURLExpiry)
+	if err != nil {
+		utils.Logger(ctx).Warn("Failed to presign job file", zap.Uint("job_id", job.ID), zap.Error(err))
+		return
+	}
+	job.Result["download_url"] = url
+}
+
+// JobFilter holds the supported filters for listing jobs
+type JobFilter struct {
+	Type      string
+	Status    string
+	CreatedBy *uint
+	Limit     int
+	Offset    int
+}
+
+// ListJobs returns jobs newest first
+func ListJobs(ctx context.Context, filter JobFilter) ([]models.Job, int64, error) {
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.WithContext(ctx).Model(&models.Job{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedBy != nil {
+		query = query.Where("created_by = ?", *filter.CreatedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	jobs := []models.Job{}
+	if err := query.Order("created_on DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range jobs {
+		addJobDownloadURL(ctx, &jobs[i])
+	}
+	return jobs, total, nil
+}
+
+// RetryJob queues a failed job again with a fresh set of attempts
+func RetryJob(ctx context.Context, id uint) (*models.Job, error) {
+	job, err := GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusFailed {
+		return nil, ErrJobNotRetryable
+	}
+
+	err = config.DB.WithContext(ctx).Model(&models.Job{}).Where("id = ? AND status = ?", id, models.JobStatusFailed).
+		UpdateColumns(map[string]interface{}{
+			"status": models.JobStatusQueued, "attempts": 0, "run_at": time.Now(), "progress": 0,
+			"progress_message": "", "finished_on": nil, "updated_on": time.Now(),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+	return GetJob(ctx, id)
+}
+
+// storageCleanupPayload lists storage objects to delete
+type storageCleanupPayload struct {
+	Keys []string `json:"keys"`
+}
+
+// QueueStorageCleanup deletes storage objects in the background, optionally not before
+// runAt (zero means now). Keys that cannot be queued are left for the orphan sweep.
+func QueueStorageCleanup(ctx context.Context, runAt time.Time, keys ...string) {
+	payload := storageCleanupPayload{}
+	for _, key := range keys {
+		if key != "" {
+			payload.Keys = append(payload.Keys, key)
+		}
+	}
+	if len(payload.Keys) == 0 {
+		return
+	}
+	if _, err := EnqueueJob(ctx, JobTypeStorageCleanup, payload, JobOptions{RunAt: runAt}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue storage cleanup", zap.Strings("s3_keys", payload.Keys), zap.Error(err))
+	}
+}
+
+func runStorageCleanupJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload storageCleanupPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	var failed []string
+	for _, key := range payload.Keys {
+		if err := DeleteFile(ctx, key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			utils.Logger(ctx).Warn("Failed to delete file", zap.String("s3_key", key), zap.Error(err))
+			failed = append(failed, key)
+		}
+	}
+	if len(failed) > 0 {
+		// Retry only what is left
+		if err := run.SaveCheckpoint(storageCleanupPayload{Keys: failed}); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to delete %d of %d files", len(failed), len(payload.Keys))
+	}
+	return models.JSONB{"deleted": len(payload.Keys)}, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLegalHold is returned when deleting an event or media that is on legal hold
+	ErrLegalHold = errors.New("deletion blocked by a legal hold")
+	// ErrLegalHoldNotFound is returned for unknown legal holds
+	ErrLegalHoldNotFound = errors.New("legal hold not found")
+	// ErrInvalidLegalHold is returned for unknown entity types, missing reasons or unknown entities
+	ErrInvalidLegalHold = errors.New("invalid legal hold")
+	// ErrLegalHoldExists is returned when the entity already has an active hold
+	ErrLegalHoldExists = errors.New("entity is already on legal hold")
+	// ErrLegalHoldReleased is returned when releasing a hold twice
+	ErrLegalHoldReleased = errors.New("legal hold already released")
+)
+
+// Audit actions recorded on the held entity
+const (
+	auditActionLegalHold        = "legal_hold"
+	auditActionLegalHoldRelease = "legal_hold_release"
+)
+
+// LegalHoldInput places a hold on an event (covering all of its media) or a media record
+type LegalHoldInput struct {
+	EntityType string // models.LegalHoldEntity*
+	EntityID   uint
+	Reason     string
+}
+
+// LegalHoldFilter narrows GET /api/admin/legal-holds
+type LegalHoldFilter struct {
+	EntityType string
+	EntityID   uint
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
+
+// MediaTombstoneFilter narrows GET /api/admin/media-tombstones
+type MediaTombstoneFilter struct {
+	MediaType string
+	MediaID   uint
+	EventID   uint
+	BranchID  uint
+	DeletedBy *uint
+	Limit     int
+	Offset    int
+}
+
+// PlaceLegalHold blocks deletion of the entity until the hold is released
+func PlaceLegalHold(input LegalHoldInput, actor AuditActor) (*models.LegalHold, error) {
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidLegalHold)
+	}
+	var model interface{}
+	switch input.EntityType {
+	case models.LegalHoldEntityEvent:
+		model = &models.EventDetails{}
+	case models.LegalHoldEntityEventMedia:
+		model = &models.EventMedia{}
+	case models.LegalHoldEntityBranchMedia:
+		model = &models.BranchMedia{}
+	default:
+		return nil, fmt.Errorf("%w: entity_type must be event, event_media or branch_media", ErrInvalidLegalHold)
+	}
+
+	hold := &models.LegalHold{
+		EntityType: input.EntityType,
+		EntityID:   input.EntityID,
+		Reason:     input.Reason,
+		PlacedBy:   actor.UserID,
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Soft-deleted events and branch media can still be restored, so they can be held too
+		if err := tx.Unscoped().Select("id").First(model, input.EntityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s %d not found", ErrInvalidLegalHold, input.EntityType, input.EntityID)
+			}
+			return err
+		}
+		var active int64
+		if err := activeLegalHolds(tx.Model(&models.LegalHold{})).
+			Where("entity_type = ? AND entity_id = ?", input.EntityType, input.EntityID).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrLegalHoldExists
+		}
+		if err := tx.Create(hold).Error; err != nil {
+			return err
+		}
+		entry := actor.auditEntry(input.EntityType, input.EntityID, auditActionLegalHold, models.JSONB{
+			"legal_hold_id": hold.ID,
+			"reason":        hold.Reason,
+		}, hold.PlacedOn)
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	hold.Active = true
+	return hold, nil
+}
+
+// ReleaseLegalHold lifts a hold; the released hold stays on record
+func ReleaseLegalHold(id uint, actor AuditActor) (*models.LegalHold, error) {
+	var hold models.LegalHold
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&hold, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLegalHoldNotFound
+			}
+			return err
+		}
+		if hold.ReleasedOn != nil {
+			return ErrLegalHoldReleased
+		}
+		now := time.Now()
+		result := tx.Model(&hold).Where("released_on IS NULL").Updates(map[string]interface{}{
+			"released_by": actor.UserID,
+			"released_on": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLegalHoldReleased
+		}
+		hold.ReleasedBy, hold.ReleasedOn = actor.UserID, &now
+		entry := actor.auditEntry(hold.EntityType, hold.EntityID, auditActionLegalHoldRelease, models.JSONB{
+			"legal_hold_id": hold.ID,
+		}, now)
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ListLegalHolds returns legal holds, newest first
+func ListLegalHolds(filter LegalHoldFilter) ([]models.LegalHold, int64, error) {
+	query := config.DB.Model(&models.LegalHold{})
+	if filter.ActiveOnly {
+		query = activeLegalHolds(query)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	holds := []models.LegalHold{}
+	if err := query.Order("placed_on DESC, id DESC").
+		Limit(filter.Limit).Offset(filter.Offset).
+		Find(&holds).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range holds {
+		holds[i].Active = holds[i].ReleasedOn == nil
+	}
+	return holds, total, nil
+}
+
+// EnsureNoLegalHold returns ErrLegalHold if deleting the entity is blocked: an event is blocked
+// by a hold on itself or any of its media, event media by a hold on itself or its event.
+func EnsureNoLegalHold(entityType string, id uint) error {
+	return checkLegalHold(config.DB, entityType, id)
+}
+
+func checkLegalHold(db *gorm.DB, entityType string, id uint) error {
+	query := activeLegalHolds(db.Model(&models.LegalHold{}))
+	switch entityType {
+	case models.LegalHoldEntityEvent:
+		query = query.Where(`(entity_type = 'event' AND entity_id = ?)
+			OR (entity_type = 'event_media' AND entity_id IN (SELECT id FROM event_media WHERE event_id = ?))`, id, id)
+	case models.LegalHoldEntityEventMedia:
+		query = query.Where(`(entity_type = 'event_media' AND entity_id = ?)
+			OR (entity_type = 'event' AND entity_id = (SELECT event_id FROM event_media WHERE id = ?))`, id, id)
+	case models.LegalHoldEntityBranchMedia:
+		query = query.Where("entity_type = 'branch_media' AND entity_id = ?", id)
+	default:
+		return nil
+	}
+	var held int64
+	if err := query.Count(&held).Error; err != nil {
+		return err
+	}
+	if held > 0 {
+		return ErrLegalHold
+	}
+	return nil
+}
+
+func activeLegalHolds(db *gorm.DB) *gorm.DB {
+	return db.Where("released_on IS NULL")
+}
+
+// recordMediaTombstone keeps who deleted a media record, when, and the record itself
+func recordMediaTombstone(tx *gorm.DB, media interface{}, actor AuditActor) error {
+	tombstone := models.MediaTombstone{
+		Metadata:        models.JSONB(auditSnapshotOf(media)),
+		DeletedBy:       actor.UserID,
+		DeletedByRoleID: actor.RoleID,
+		IP:              actor.IP,
+		Path:            actor.Path,
+	}
+	switch m := media.(type) {
+	case *models.EventMedia:
+		eventID := m.EventID
+		tombstone.MediaType, tombstone.MediaID, tombstone.EventID = models.LegalHoldEntityEventMedia, m.ID, &eventID
+		tombstone.S3Key, tombstone.OriginalFilename = m.S3Key, m.OriginalFilename
+		tombstone.FileType, tombstone.Category = m.FileType, m.Category
+	case *models.BranchMedia:
+		branchID := m.BranchID
+		tombstone.MediaType, tombstone.MediaID, tombstone.BranchID = models.LegalHoldEntityBranchMedia, m.ID, &branchID
+		tombstone.S3Key, tombstone.OriginalFilename = m.S3Key, m.OriginalFilename
+		tombstone.FileType, tombstone.Category = m.FileType, m.Category
+	default:
+		return errors.New("unsupported media type for tombstone")
+	}
+	return tx.Create(&tombstone).Error
+}
+
+// ListMediaTombstones returns tombstones of deleted media, most recent deletion first
+func ListMediaTombstones(filter MediaTombstoneFilter) ([]models.MediaTombstone, int64, error) {
+	query := config.DB.Model(&models.MediaTombstone{})
+	if filter.MediaType != "" {
+		query = query.Where("media_type = ?", filter.MediaType)
+	}
+	if filter.MediaID != 0 {
+		query = query.Where("media_id = ?", filter.MediaID)
+	}
+	if filter.EventID != 0 {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+	if filter.BranchID != 0 {
+		query = query.Where("branch_id = ?", filter.BranchID)
+	}
+	if filter.DeletedBy != nil {
+		query = query.Where("deleted_by = ?", *filter.DeletedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	tombstones := []models.MediaTombstone{}
+	if err := query.Order("deleted_on DESC, id DESC").
+		Limit(filter.Limit).Offset(filter.Offset).
+		Find(&tombstones).Error; err != nil {
+		return nil, 0, err
+	}
+	return tombstones, total, nil
+}
@@ -0,0 +1,423 @@
+// Package listquery parses sort and filter query parameters of list endpoints against a
+// per-entity allowlist and applies them as parameterized GORM conditions. Column names only ever
+// come from the Schema, never from the request, so handlers cannot interpolate user input into
+// WHERE or ORDER BY clauses.
+//
+// Query parameters:
+//
+//	sort=-start_date,theme          sort by up to MaxSortFields fields, "-" for descending
+//	status=complete                 equality
+//	start_date[gte]=2025-04-01      operator filters: eq ne lt lte gt gte in contains null
+//	event_type_id[in]=1,2,3         comma-separated values
+//	theme[contains]=satsang         case-insensitive substring
+//	district_id[null]=true          IS NULL / IS NOT NULL
+//	from=2025-04-01&to=2025-06-30   rows whose date range overlaps the period (WithDateRange)
+//	limit=50&offset=100             one page of at most MaxLimit rows (Paged, Page)
+package listquery
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidQuery is returned for unknown fields, disallowed operators and malformed values
+var ErrInvalidQuery = errors.New("invalid list query")
+
+const (
+	// MaxSortFields caps the number of sort fields of one request
+	MaxSortFields = 3
+	// MaxInValues caps the number of values of one in filter
+	MaxInValues = 100
+	// DefaultLimit is the page size of paged requests without a limit
+	DefaultLimit = 50
+	// MaxLimit caps the page size
+	MaxLimit = 200
+)
+
+// Type is the value type of a field; filter values are parsed into it before reaching the query
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Time // YYYY-MM-DD or RFC 3339
+	Bool
+)
+
+// Operators
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpIn       = "in"
+	OpContains = "contains"
+	OpNull     = "null"
+)
+
+var sqlOperators = map[string]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpLt:  "<",
+	OpLte: "<=",
+	OpGt:  ">",
+	OpGte: ">=",
+}
+
+// defaultOperators are the operators a field of each type allows unless it lists its own
+var defaultOperators = map[Type][]string{
+	String: {OpEq, OpNe, OpIn, OpContains, OpNull},
+	Int:    {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn, OpNull},
+	Time:   {OpEq, OpLt, OpLte, OpGt, OpGte, OpNull},
+	Bool:   {OpEq, OpNull},
+}
+
+// columnPattern is what a Field column may look like: a plain or table-qualified identifier
+var columnPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// Field is a column clients may sort or filter a list by
+type Field struct {
+	Column    string   // SQL column, optionally table-qualified
+	Type      Type     // value type of filters
+	Sortable  bool     // may be used in sort
+	Operators []string // allowed filter operators; nil for the defaults of Type, empty to disallow filtering
+}
+
+// Sort is one validated sort term
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Schema is the allowlist of one list endpoint, keyed by the public field name
+type Schema struct {
+	fields      map[string]Field
+	defaultSort []Sort
+	tieBreaker  string
+
+	// Time fields holding the start and end of each row's period, for from and to
+	rangeStart, rangeEnd string
+}
+
+// NewSchema builds a schema. tieBreaker is a unique column (usually the primary key) appended to
+// every sort so pages are stable; defaultSort applies when the request has no sort. Schemas are
+// package-level values, so invalid column names or default sort fields panic at startup.
+func NewSchema(tieBreaker string, fields map[string]Field, defaultSort ...Sort) *Schema {
+	if !columnPattern.MatchString(tieBreaker) {
+		panic(fmt.Sprintf("listquery: invalid tie-breaker column %q", tieBreaker))
+	}
+	for name, field := range fields {
+		if !columnPattern.MatchString(field.Column) {
+			panic(fmt.Sprintf("listquery: invalid column %q of field %q", field.Column, name))
+		}
+		if field.Operators == nil {
+			field.Operators = defaultOperators[field.Type]
+			fields[name] = field
+		}
+	}
+	for _, s := range defaultSort {
+		if !fields[s.Field].Sortable {
+			panic(fmt.Sprintf("listquery: default sort field %q is not sortable", s.Field))
+		}
+	}
+	return &Schema{fields: fields, defaultSort: defaultSort, tieBreaker: tieBreaker}
+}
+
+// WithDateRange lets the schema's lists be filtered with from and to to the rows whose period,
+// from the start field to the end field, overlaps the requested one. Rows without an end are
+// taken to end on their start. Both must be Time fields.
+func (s *Schema) WithDateRange(startField, endField string) *Schema {
+	for _, name := range []string{startField, endField} {
+		if field, ok := s.fields[name]; !ok || field.Type != Time {
+			panic(fmt.Sprintf("listquery: date range field %q is not a time field", name))
+		}
+	}
+	s.rangeStart, s.rangeEnd = startField, endField
+	return s
+}
+
+// SortFields lists the sortable field names, for error messages and docs
+func (s *Schema) SortFields() []string {
+	var names []string
+	for name, field := range s.fields {
+		if field.Sortable {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type filter struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+// Query is a validated sort and filter request. It is only built by Schema.Parse, so every
+// column it touches comes from the schema.
+type Query struct {
+	schema  *Schema
+	sorts   []Sort
+	filters []filter
+
+	paged         bool
+	limit, offset int
+}
+
+// Parse validates the sort and filter parameters of a request. Plain parameters that are not
+// schema fields (limit, cursor, ...) are left to the handler; bracketed ones must name a field.
+func (s *Schema) Parse(values url.Values) (*Query, error) {
+	q := &Query{schema: s}
+
+	if raw := strings.TrimSpace(values.Get("sort")); raw != "" {
+		terms := strings.Split(raw, ",")
+		if len(terms) > MaxSortFields {
+			return nil, fmt.Errorf("%w: at most %d sort fields", ErrInvalidQuery, MaxSortFields)
+		}
+		for _, term := range terms {
+			term = strings.TrimSpace(term)
+			desc := strings.HasPrefix(term, "-")
+			name := strings.TrimPrefix(term, "-")
+			if !s.fields[name].Sortable {
+				return nil, fmt.Errorf("%w: cannot sort by %q (sortable: %s)", ErrInvalidQuery, name, strings.Join(s.SortFields(), ", "))
+			}
+			q.sorts = append(q.sorts, Sort{Field: name, Desc: desc})
+		}
+	}
+
+	if s.rangeStart != "" {
+		start, end := s.fields[s.rangeStart].Column, s.fields[s.rangeEnd].Column
+		for _, bound := range []struct {
+			param, column, op string
+		}{
+			{"from", "COALESCE(" + end + ", " + start + ")", OpGte},
+			{"to", start, OpLte},
+		} {
+			raw := strings.TrimSpace(values.Get(bound.param))
+			if raw == "" {
+				continue
+			}
+			value, err := parseScalar(Time, raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, bound.param, err)
+			}
+			q.filters = append(q.filters, filter{column: bound.column, op: bound.op, value: value})
+		}
+	}
+
+	if err := q.parsePage(values); err != nil {
+		return nil, err
+	}
+
+	// Sorted so that errors and the generated SQL do not depend on map order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name, op := key, OpEq
+		if open := strings.IndexByte(key, '['); open > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:open], key[open+1:len(key)-1]
+		}
+		field, ok := s.fields[name]
+		if !ok {
+			if name != key {
+				return nil, fmt.Errorf("%w: cannot filter by %q", ErrInvalidQuery, name)
+			}
+			continue
+		}
+		if !allowed(field.Operators, op) {
+			return nil, fmt.Errorf("%w: operator %q is not allowed on %q", ErrInvalidQuery, op, name)
+		}
+		for _, raw := range values[key] {
+			if op == OpEq && strings.TrimSpace(raw) == "" {
+				continue // ?status= means no filter, as before the allowlists
+			}
+			value, err := parseValue(field.Type, op, raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, key, err)
+			}
+			q.filters = append(q.filters, filter{column: field.Column, op: op, value: value})
+		}
+	}
+	return q, nil
+}
+
+// parsePage reads limit and offset; either one makes the request paged
+func (q *Query) parsePage(values url.Values) error {
+	q.limit = DefaultLimit
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxLimit)
+		}
+		q.limit, q.paged = limit, true
+	}
+	if raw := strings.TrimSpace(values.Get("offset")); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return fmt.Errorf("%w: offset must be a non-negative integer", ErrInvalidQuery)
+		}
+		q.offset, q.paged = offset, true
+	}
+	return nil
+}
+
+func allowed(operators []string, op string) bool {
+	for _, o := range operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseValue(t Type, op, raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	switch op {
+	case OpNull:
+		return strconv.ParseBool(raw)
+	case OpContains:
+		if raw == "" {
+			return nil, errors.New("value is required")
+		}
+		return "%" + escapeLike(raw) + "%", nil
+	case OpIn:
+		parts := strings.Split(raw, ",")
+		if len(parts) > MaxInValues {
+			return nil, fmt.Errorf("at most %d values", MaxInValues)
+		}
+		list := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			value, err := parseScalar(t, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+	return parseScalar(t, raw)
+}
+
+func parseScalar(t Type, raw string) (interface{}, error) {
+	switch t {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		if value, err := time.Parse("2006-01-02", raw); err == nil {
+			return value, nil
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, errors.New("expected YYYY-MM-DD or an RFC 3339 timestamp")
+		}
+		return value, nil
+	}
+	return raw, nil
+}
+
+// escapeLike makes LIKE wildcards in user input match literally (backslash is the default
+// escape character in Postgres)
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Apply adds the filters and the ORDER BY to a query: the requested sort (or the schema
+// default) followed by the tie-breaker in the direction of the first sort field, unless the
+// sort already includes it
+func (q *Query) Apply(db *gorm.DB) *gorm.DB {
+	db = q.Filter(db)
+
+	sorts := q.sorts
+	if len(sorts) == 0 {
+		sorts = q.schema.defaultSort
+	}
+	unique := false
+	for _, s := range sorts {
+		column := q.schema.fields[s.Field].Column
+		unique = unique || column == q.schema.tieBreaker
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column, Raw: true}, Desc: s.Desc})
+	}
+	if unique {
+		return db
+	}
+	return db.Order(clause.OrderByColumn{Column: clause.Column{Name: q.schema.tieBreaker, Raw: true}, Desc: len(sorts) > 0 && sorts[0].Desc})
+}
+
+// Paged reports whether the request asked for a page (limit or offset). Lists that were not
+// paged before keep returning every row otherwise.
+func (q *Query) Paged() bool {
+	return q.paged
+}
+
+// Limit is the page size
+func (q *Query) Limit() int {
+	return q.limit
+}
+
+// Offset is the number of rows skipped
+func (q *Query) Offset() int {
+	return q.offset
+}
+
+// Page adds the LIMIT and OFFSET of the requested page. Apply first, so pages follow the sort.
+func (q *Query) Page(db *gorm.DB) *gorm.DB {
+	return db.Limit(q.limit).Offset(q.offset)
+}
+
+// Key identifies the query in cache keys: two queries with the same key select the same rows
+// in the same order
+func (q *Query) Key() string {
+	var key strings.Builder
+	for _, s := range q.sorts {
+		if s.Desc {
+			key.WriteByte('-')
+		}
+		key.WriteString(s.Field + ",")
+	}
+	for _, f := range q.filters {
+		fmt.Fprintf(&key, "|%s[%s]=%v", f.column, f.op, f.value)
+	}
+	if q.paged {
+		fmt.Fprintf(&key, "|%d+%d", q.offset, q.limit)
+	}
+	return key.String()
+}
+
+// Filter adds only the filters, for counts and aggregates over the same rows
+func (q *Query) Filter(db *gorm.DB) *gorm.DB {
+	for _, f := range q.filters {
+		switch f.op {
+		case OpNull:
+			if f.value.(bool) {
+				db = db.Where(f.column + " IS NULL")
+			} else {
+				db = db.Where(f.column + " IS NOT NULL")
+			}
+		case OpIn:
+			db = db.Where(f.column+" IN ?", f.value)
+		case OpContains:
+			db = db.Where(f.column+" ILIKE ?", f.value)
+		default:
+			db = db.Where(f.column+" "+sqlOperators[f.op]+" ?", f.value)
+		}
+	}
+	return db
+}
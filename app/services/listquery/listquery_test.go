@@ -0,0 +1,100 @@
+package listquery
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func testSchema() *Schema {
+	return NewSchema("e.id", map[string]Field{
+		"id":         {Column: "e.id", Type: Int, Sortable: true},
+		"start_date": {Column: "e.start_date", Type: Time, Sortable: true},
+		"end_date":   {Column: "e.end_date", Type: Time},
+		"theme":      {Column: "e.theme", Type: String, Sortable: true},
+		"status":     {Column: "e.status", Type: String, Operators: []string{OpEq, OpIn}},
+		"notes":      {Column: "e.notes", Type: String, Operators: []string{}},
+	}, Sort{Field: "start_date", Desc: true}).WithDateRange("start_date", "end_date")
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		// Sort keys and directions
+		{"unknown sort field", "sort=password"},
+		{"filter-only sort field", "sort=status"},
+		{"column name instead of field", "sort=e.theme"},
+		{"SQL in sort", "sort=theme%3BDROP+TABLE+users"},
+		{"plus direction", "sort=%2Btheme"},
+		{"double minus", "sort=--theme"},
+		{"suffix direction", "sort=theme:desc"},
+		{"empty sort term", "sort=theme,"},
+		{"too many sort fields", "sort=theme,-start_date,id,-theme"},
+		// Filter keys, operators and values
+		{"unknown bracketed filter", "password[eq]=x"},
+		{"unknown operator", "theme[like]=x"},
+		{"operator not allowed on field", "status[contains]=x"},
+		{"filtering disallowed", "notes=x"},
+		{"bad int", "id=abc"},
+		{"bad int in list", "id[in]=1,x,3"},
+		{"bad date", "start_date[gte]=01-04-2025"},
+		{"bad null", "theme[null]=maybe"},
+		{"empty contains", "theme[contains]="},
+		{"bad from", "from=yesterday"},
+		// Paging
+		{"zero limit", "limit=0"},
+		{"negative limit", "limit=-1"},
+		{"limit above max", "limit=201"},
+		{"non-numeric limit", "limit=ten"},
+		{"negative offset", "offset=-5"},
+		{"non-numeric offset", "offset=1e3"},
+	}
+	schema := testSchema()
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if _, err := schema.Parse(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: Parse(%q) error = %v, want ErrInvalidQuery", tt.name, tt.query, err)
+		}
+	}
+}
+
+func TestParseAccepts(t *testing.T) {
+	tests := []struct {
+		query  string
+		key    string
+		paged  bool
+		limit  int
+		offset int
+	}{
+		{"", "", false, DefaultLimit, 0},
+		{"sort=-start_date,theme", "-start_date,theme,", false, DefaultLimit, 0},
+		{"status=", "", false, DefaultLimit, 0},
+		{"status[in]=draft,submitted", "|e.status[in]=[draft submitted]", false, DefaultLimit, 0},
+		{"theme[contains]=100%25", `|e.theme[contains]=%100\%%`, false, DefaultLimit, 0},
+		// Plain parameters that are not fields are left to the handler
+		{"cursor=abc&search=x", "", false, DefaultLimit, 0},
+		{"limit=200", "|0+200", true, MaxLimit, 0},
+		{"offset=100", "|100+50", true, DefaultLimit, 100},
+	}
+	schema := testSchema()
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		q, err := schema.Parse(values)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if q.Key() != tt.key || q.Paged() != tt.paged || q.Limit() != tt.limit || q.Offset() != tt.offset {
+			t.Errorf("Parse(%q) = key %q paged %v limit %d offset %d, want key %q paged %v limit %d offset %d",
+				tt.query, q.Key(), q.Paged(), q.Limit(), q.Offset(), tt.key, tt.paged, tt.limit, tt.offset)
+		}
+	}
+}
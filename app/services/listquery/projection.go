@@ -0,0 +1,216 @@
+package listquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Field selection of list endpoints:
+//
+//	fields=id,name,branch_code      columns to return (the key columns are always included)
+//	include=parent,city             associations to load with each row
+//
+// Without either parameter the list keeps its full shape. With one of them, rows are returned
+// as list items holding only what was asked for: fields defaults to every field and include to
+// no association, so ?include= alone gives the plain rows without their associations.
+
+// Include is an association clients may ask for with include
+type Include struct {
+	Preload string   // GORM association loaded for it; empty when the caller computes it
+	Columns []string // columns it needs selected, usually its foreign key
+}
+
+// FieldSet is the fields and include allowlist of one list endpoint. Field and include names are
+// the JSON keys of the rows, so list items keep the keys of the full shape.
+type FieldSet struct {
+	keys     []string
+	fields   map[string]string // name -> column
+	includes map[string]Include
+}
+
+// NewFieldSet builds a field set. keys are the columns every row carries (the primary key and
+// what the endpoint itself relies on); fields map the public names to their columns. Field sets
+// are package-level values, so invalid column names panic at startup.
+func NewFieldSet(keys []string, fields map[string]string, includes map[string]Include) *FieldSet {
+	for name, column := range fields {
+		if !columnPattern.MatchString(column) {
+			panic(fmt.Sprintf("listquery: invalid column %q of field %q", column, name))
+		}
+	}
+	for name, include := range includes {
+		for _, column := range include.Columns {
+			if !columnPattern.MatchString(column) {
+				panic(fmt.Sprintf("listquery: invalid column %q of include %q", column, name))
+			}
+		}
+	}
+	for _, column := range keys {
+		if !columnPattern.MatchString(column) {
+			panic(fmt.Sprintf("listquery: invalid key column %q", column))
+		}
+	}
+	return &FieldSet{keys: keys, fields: fields, includes: includes}
+}
+
+// Projection is a validated fields and include request. A nil Projection stands for the full
+// shape: it selects every column and includes everything.
+type Projection struct {
+	set      *FieldSet
+	fields   []string
+	includes []string
+}
+
+// Parse validates the fields and include parameters of a request. It returns nil when neither
+// is set.
+func (s *FieldSet) Parse(values url.Values) (*Projection, error) {
+	_, hasFields := values["fields"]
+	_, hasInclude := values["include"]
+	if !hasFields && !hasInclude {
+		return nil, nil
+	}
+
+	p := &Projection{set: s}
+	if names := splitList(values["fields"]); len(names) > 0 {
+		for _, name := range names {
+			if _, ok := s.fields[name]; !ok {
+				return nil, fmt.Errorf("%w: unknown field %q (fields: %s)", ErrInvalidQuery, name, strings.Join(sortedKeys(s.fields), ", "))
+			}
+		}
+		p.fields = names
+	} else {
+		p.fields = sortedKeys(s.fields)
+	}
+	for _, name := range splitList(values["include"]) {
+		if _, ok := s.includes[name]; !ok {
+			return nil, fmt.Errorf("%w: cannot include %q (include: %s)", ErrInvalidQuery, name, strings.Join(sortedKeys(s.includes), ", "))
+		}
+		p.includes = append(p.includes, name)
+	}
+	sort.Strings(p.fields)
+	sort.Strings(p.includes)
+	return p, nil
+}
+
+// splitList splits comma-separated values, dropping blanks and duplicates
+func splitList(values []string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Includes reports whether any of the associations is included
+func (p *Projection) Includes(names ...string) bool {
+	if p == nil {
+		return true
+	}
+	for _, name := range names {
+		for _, include := range p.includes {
+			if include == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Select selects the requested columns and preloads the included associations. preload loads
+// the full shape's associations when p is nil, and is not called otherwise.
+func (p *Projection) Select(db *gorm.DB, preload func(*gorm.DB) *gorm.DB) *gorm.DB {
+	if p == nil {
+		return preload(db)
+	}
+	seen := map[string]bool{}
+	var columns []string
+	add := func(column string) {
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, column := range p.set.keys {
+		add(column)
+	}
+	for _, name := range p.fields {
+		add(p.set.fields[name])
+	}
+	for _, name := range p.includes {
+		include := p.set.includes[name]
+		for _, column := range include.Columns {
+			add(column)
+		}
+		if include.Preload != "" {
+			db = db.Preload(include.Preload)
+		}
+	}
+	return db.Select(columns)
+}
+
+// Key identifies the projection in cache keys
+func (p *Projection) Key() string {
+	if p == nil {
+		return "full"
+	}
+	return strings.Join(p.fields, ",") + "+" + strings.Join(p.includes, ",")
+}
+
+// ListItem is a row reduced to the requested fields and included associations
+type ListItem map[string]json.RawMessage
+
+// Items reduces rows to list items, or returns them as they are for the full shape
+func Items[T any](p *Projection, rows []T) (interface{}, error) {
+	if p == nil {
+		return rows, nil
+	}
+	keep := map[string]bool{}
+	for _, column := range p.set.keys {
+		keep[column] = true
+	}
+	for _, name := range p.fields {
+		keep[name] = true
+	}
+	for _, name := range p.includes {
+		keep[name] = true
+	}
+
+	items := make([]ListItem, len(rows))
+	for i := range rows {
+		data, err := json.Marshal(rows[i])
+		if err != nil {
+			return nil, err
+		}
+		var item ListItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		for key := range item {
+			if !keep[key] {
+				delete(item, key)
+			}
+		}
+		items[i] = item
+	}
+	return items, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalFilesRoute is where LocalStorage objects are served, see handlers.ServeLocalFileHandler
+const LocalFilesRoute = "/api/v1/files/local"
+
+// localMetaDir holds a JSON sidecar (content type, ETag, metadata) per object
+const localMetaDir = ".meta"
+
+var (
+	// ErrInvalidObjectKey is returned for keys that would escape the storage directory
+	ErrInvalidObjectKey = errors.New("invalid object key")
+	// ErrInvalidFileSignature is returned for expired or tampered local file URLs
+	ErrInvalidFileSignature = errors.New("invalid or expired file link")
+)
+
+// LocalStorage keeps objects on disk for small deployments and local development. Presigned
+// URLs point at LocalFilesRoute and carry an HMAC signature and expiry instead of AWS credentials.
+type LocalStorage struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+type localObjectMeta struct {
+	ContentType string            `json:"content_type"`
+	ETag        string            `json:"etag"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// NewLocalStorage stores objects under dir. baseURL is the public origin of this API
+// (e.g. https://api.example.org); presigned URLs are relative to the API host when it is empty.
+func NewLocalStorage(dir, baseURL string, signingKey []byte) (*LocalStorage, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("local storage needs STORAGE_SIGNING_KEY (or JWT_SECRET) to sign file links")
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(absDir, localMetaDir), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory %s: %w", absDir, err)
+	}
+	return &LocalStorage{dir: absDir, baseURL: strings.TrimRight(baseURL, "/"), signingKey: signingKey}, nil
+}
+
+// localPath maps a key to its file, rejecting keys that leave the storage directory
+func (l *LocalStorage) localPath(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || cleaned != key || cleaned == localMetaDir || strings.HasPrefix(cleaned, localMetaDir+"/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidObjectKey, key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (l *LocalStorage) metaPath(key string) string {
+	return filepath.Join(l.dir, localMetaDir, filepath.FromSlash(key)+".json")
+}
+
+func (l *LocalStorage) Bucket() string {
+	return "local:" + l.dir
+}
+
+func (l *LocalStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	target, err := l.localPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename, so readers never see partial objects
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(localObjectMeta{
+		ContentType: contentType,
+		ETag:        hex.EncodeToString(hash.Sum(nil)),
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return err
+	}
+	metaPath := l.metaPath(key)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath, meta, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (l *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := l.localPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return file, err
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	target, err := l.localPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(l.metaPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Presign returns a LocalFilesRoute URL that is valid until expiration
+func (l *LocalStorage) Presign(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	if _, err := l.localPath(key); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(expiration).Unix()
+	escaped := make([]string, 0, strings.Count(key, "/")+1)
+	for _, segment := range strings.Split(key, "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	return fmt.Sprintf("%s%s/%s?expires=%d&signature=%s",
+		l.baseURL, LocalFilesRoute, strings.Join(escaped, "/"), expires, l.sign(key, expires)), nil
+}
+
+func (l *LocalStorage) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a presigned URL's expires and signature parameters for key
+func (l *LocalStorage) VerifySignature(key, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidFileSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(l.sign(key, expiresAt))) {
+		return ErrInvalidFileSignature
+	}
+	return nil
+}
+
+func (l *LocalStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	target, err := l.localPath(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ObjectInfo{Key: key, Size: stat.Size(), LastModified: stat.ModTime().UTC(), Metadata: map[string]string{}}
+	if raw, err := os.ReadFile(l.metaPath(key)); err == nil {
+		var meta localObjectMeta
+		if json.Unmarshal(raw, &meta) == nil {
+			info.ContentType = meta.ContentType
+			info.ETag = meta.ETag
+			for k, v := range meta.Metadata {
+				info.Metadata[k] = v
+			}
+		}
+	}
+	return info, nil
+}
+
+// List walks the storage directory; keys come back in lexical order like S3
+func (l *LocalStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(l.dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if key == localMetaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Skip in-progress uploads and verification files
+		if !strings.HasPrefix(key, prefix) || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		info, err := l.Head(ctx, key)
+		if err != nil {
+			return err
+		}
+		return fn(*info)
+	})
+}
+
+// Verify checks that the storage directory is writable
+func (l *LocalStorage) Verify(ctx context.Context) error {
+	file, err := os.CreateTemp(l.dir, ".verify-*")
+	if err != nil {
+		return fmt.Errorf("storage directory %s is not writable: %w", l.dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
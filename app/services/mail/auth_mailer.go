@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// AuthMailer sends the emails of the auth service (implements auth.Mailer): verification and
+// password reset links pointing at the frontend's /verify-email and /reset-password pages.
+// Emails go out in the background so response times do not reveal whether an account exists.
+type AuthMailer struct{}
+
+func NewAuthMailer() *AuthMailer {
+	return &AuthMailer{}
+}
+
+func (m *AuthMailer) SendVerification(email, token string) error {
+	SendAsync(context.Background(), Notification{
+		To:       email,
+		Template: TemplateVerifyEmail,
+		Data: map[string]interface{}{
+			"Link":     Link("/verify-email?token=" + url.QueryEscape(token)),
+			"ValidFor": config.VerificationTTL.String(),
+		},
+	})
+	return nil
+}
+
+func (m *AuthMailer) SendPasswordReset(email, token string) error {
+	SendAsync(context.Background(), Notification{
+		To:       email,
+		Template: TemplatePasswordReset,
+		Data: map[string]interface{}{
+			"Link":     Link("/reset-password?token=" + url.QueryEscape(token)),
+			"ValidFor": config.PasswordResetTTL.String(),
+		},
+	})
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package mail renders and delivers the application's email notifications (account
+// provisioning, password resets, event review results, reviewer digests) over SMTP or
+// Amazon SES, and records every attempt in the notification_logs table.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// Templates (see templates/*.tmpl)
+const (
+	TemplateWelcome           = "welcome"
+	TemplateTemporaryPassword = "temporary_password"
+	TemplatePasswordReset     = "password_reset"
+	TemplateVerifyEmail       = "verify_email"
+	TemplateEventApproved     = "event_approved"
+	TemplateEventRejected     = "event_rejected"
+	TemplateApprovalDigest    = "approval_digest"
+)
+
+// sendTimeout bounds a single delivery attempt
+const sendTimeout = 30 * time.Second
+
+// ErrNotConfigured is returned by Send when no mail driver is configured: the notification
+// is logged but not delivered
+var ErrNotConfigured = errors.New("email delivery is not configured")
+
+// Message is a rendered email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers rendered messages
+type Sender interface {
+	// Send delivers msg from the configured sender address and returns the provider's message ID
+	Send(ctx context.Context, msg Message) (string, error)
+	// Name identifies the provider in the notification log
+	Name() string
+}
+
+// sender is the active delivery backend, replaced by Init
+var sender Sender = logSender{}
+
+// Init selects the delivery backend configured by config.LoadMailConfig
+func Init() error {
+	switch config.MailDriver {
+	case "smtp":
+		sender = newSMTPSender()
+	case "ses":
+		s, err := newSESSender()
+		if err != nil {
+			return err
+		}
+		sender = s
+	default:
+		sender = logSender{}
+	}
+	utils.BaseLogger().Info("Mail delivery configured", zap.String("driver", sender.Name()))
+	return nil
+}
+
+// Enabled reports whether notifications are actually delivered
+func Enabled() bool {
+	_, logOnly := sender.(logSender)
+	return !logOnly
+}
+
+// Notification is one templated email to send
+type Notification struct {
+	To         string
+	Template   string
+	Data       map[string]interface{}
+	UserID     *uint  // the recipient's account, if any
+	EntityType string // what the notification is about (for the log)
+	EntityID   *uint
+}
+
+// Send renders and delivers a notification and records the attempt in notification_logs.
+// Without a configured driver it returns ErrNotConfigured.
+func Send(ctx context.Context, n Notification) error {
+	entry := &models.NotificationLog{
+		Channel:    "email",
+		Template:   n.Template,
+		Recipient:  n.To,
+		Provider:   sender.Name(),
+		UserID:     n.UserID,
+		EntityType: n.EntityType,
+		EntityID:   n.EntityID,
+	}
+	err := send(ctx, n, entry)
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		entry.Status = models.NotificationStatusLogged
+	case err != nil:
+		entry.Status = models.NotificationStatusFailed
+		entry.Error = err.Error()
+	default:
+		entry.Status = models.NotificationStatusSent
+	}
+
+	logger := utils.Logger(ctx).With(zap.String("template", n.Template), zap.String("recipient", n.To), zap.String("provider", entry.Provider))
+	if err != nil && !errors.Is(err, ErrNotConfigured) {
+		logger.Warn("Failed to send notification", zap.Error(err))
+	} else {
+		logger.Info("Notification processed", zap.String("status", entry.Status))
+	}
+	if dbErr := config.DB.WithContext(context.WithoutCancel(ctx)).Create(entry).Error; dbErr != nil {
+		logger.Warn("Failed to record notification", zap.Error(dbErr))
+	}
+	return err
+}
+
+func send(ctx context.Context, n Notification, entry *models.NotificationLog) error {
+	addr, err := netmail.ParseAddress(n.To)
+	if err != nil || strings.ContainsAny(n.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", n.To)
+	}
+	msg, err := render(n.Template, n.Data)
+	if err != nil {
+		return err
+	}
+	msg.To = addr.Address
+	entry.Subject = msg.Subject
+
+	if !Enabled() {
+		return ErrNotConfigured
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	id, err := sender.Send(ctx, msg)
+	entry.ProviderMessageID = id
+	return err
+}
+
+// SendAsync sends a notification in the background so the request does not wait on the mail
+// server. Failures are logged and recorded in notification_logs.
+func SendAsync(ctx context.Context, n Notification) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_ = Send(ctx, n)
+	}()
+}
+
+// Link builds an absolute frontend URL for email links (config.MailLinkBaseURL + path).
+// It returns "" when no base URL is configured.
+func Link(path string) string {
+	if config.MailLinkBaseURL == "" {
+		return ""
+	}
+	return config.MailLinkBaseURL + path
+}
+
+// logSender is used when no driver is configured. Nothing is delivered and message contents
+// are never logged (they can hold passwords and reset links).
+type logSender struct{}
+
+func (logSender) Name() string { return "log" }
+
+func (logSender) Send(ctx context.Context, msg Message) (string, error) {
+	return "", ErrNotConfigured
+}
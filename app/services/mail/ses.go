@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// sesSender delivers through Amazon SES (v2 API). It uses the same AWS_ACCESS_KEY_ID /
+// AWS_SECRET_ACCESS_KEY as S3 when they are set, otherwise the default credential chain.
+type sesSender struct {
+	client *sesv2.Client
+	from   string
+}
+
+func newSESSender() (*sesSender, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(config.SESRegion)}
+	if key, secret := config.SESAccessKeyID, config.SESSecretAccessKey; key != "" && secret != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(key, secret, "")))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for SES: %w", err)
+	}
+	return &sesSender{client: sesv2.NewFromConfig(cfg), from: config.MailFrom}, nil
+}
+
+func (s *sesSender) Name() string { return "ses" }
+
+func (s *sesSender) Send(ctx context.Context, msg Message) (string, error) {
+	out, err := s.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from),
+		Destination:      &types.Destination{ToAddresses: []string{msg.To}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(msg.Subject), Charset: aws.String("UTF-8")},
+				Body: &types.Body{
+					Text: &types.Content{Data: aws.String(msg.Text), Charset: aws.String("UTF-8")},
+					Html: &types.Content{Data: aws.String(msg.HTML), Charset: aws.String("UTF-8")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("ses send: %w", err)
+	}
+	return aws.ToString(out.MessageId), nil
+}
@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/google/uuid"
+)
+
+// smtpSender delivers through an SMTP relay. Port 465 uses implicit TLS; on other ports
+// STARTTLS is used whenever the server offers it.
+type smtpSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func newSMTPSender() *smtpSender {
+	return &smtpSender{
+		host:     config.SMTPHost,
+		port:     config.SMTPPort,
+		username: config.SMTPUsername,
+		password: config.SMTPPassword,
+		from:     config.MailFrom,
+	}
+}
+
+func (s *smtpSender) Name() string { return "smtp" }
+
+func (s *smtpSender) Send(ctx context.Context, msg Message) (string, error) {
+	messageID := fmt.Sprintf("<%s@%s>", uuid.NewString(), s.host)
+	raw, err := buildMIME(s.from, msg, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if s.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("smtp connect: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && s.port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return "", fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return "", fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(envelopeAddress(s.from)); err != nil {
+		return "", fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return "", fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("smtp data: %w", err)
+	}
+	return messageID, client.Quit()
+}
+
+// envelopeAddress returns the bare address of a From value like "DJJS <no-reply@example.org>"
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
+
+// buildMIME assembles a multipart/alternative message with text and HTML parts
+func buildMIME(from string, msg Message, messageID string) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+body.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// emailTemplate is one templates/<name>.tmpl file. Every file defines the same blocks:
+// "subject" and "text" (plain text) and "html" (escaped), so each gets its own template set.
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var emailTemplates = loadTemplates()
+
+func loadTemplates() map[string]emailTemplate {
+	files, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	templates := make(map[string]emailTemplate, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		templates[name] = emailTemplate{
+			text: texttemplate.Must(texttemplate.New(name).Option("missingkey=zero").ParseFS(templateFS, file)),
+			html: htmltemplate.Must(htmltemplate.New(name).Option("missingkey=zero").ParseFS(templateFS, file)),
+		}
+	}
+	return templates
+}
+
+// render executes the named template with data
+func render(name string, data map[string]interface{}) (Message, error) {
+	t, ok := emailTemplates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("email template %s: %w", name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("email template %s: %w", name, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, fmt.Errorf("email template %s: %w", name, err)
+	}
+
+	return Message{
+		// Subjects end up in a header: keep them to one line
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
@@ -1,204 +1,231 @@
 package services
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 )
 
 // ===================== Services =====================
+//
+// Master data is served through the shared cache (see cache.go); writes to the tables
+// invalidate it.
 
 func GetAllEventTypesService() ([]models.EventType, error) {
-	var list []models.EventType
-	if err := config.DB.Find(&list).Error; err != nil {
-		return nil, err
-	}
-	return list, nil
+	return cached(context.Background(), CacheGroupMaster, "event_types", func() ([]models.EventType, error) {
+		var list []models.EventType
+		if err := config.DB.Find(&list).Error; err != nil {
+			return nil, err
+		}
+		return list, nil
+	})
 }
 
 func GetAllEventCategoriesService() ([]models.EventCategory, error) {
-	var list []models.EventCategory
-	if err := config.DB.Preload("EventType").Find(&list).Error; err != nil {
-		return nil, err
-	}
-	return list, nil
+	return cached(context.Background(), CacheGroupMaster, "event_categories", func() ([]models.EventCategory, error) {
+		var list []models.EventCategory
+		if err := config.DB.Preload("EventType").Find(&list).Error; err != nil {
+			return nil, err
+		}
+		return list, nil
+	})
 }
 
-// GetAllCountriesService returns all countries
+// GetAllCountriesService returns all countries (from the location cache, see geo_service.go)
 func GetAllCountriesService() ([]models.Country, error) {
-	var countries []models.Country
-	if err := config.DB.Find(&countries).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-	return countries, nil
+	return orEmpty(tables.countries), nil
 }
 
 // GetAllStatesService returns all states
 func GetAllStatesService() ([]models.State, error) {
-	var states []models.State
-	if err := config.DB.Find(&states).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-	return states, nil
+	return orEmpty(tables.states), nil
 }
 
 // GetStatesByCountryService returns states filtered by country ID
 func GetStatesByCountryService(countryID uint) ([]models.State, error) {
-	var states []models.State
-	if err := config.DB.Where("country_id = ?", countryID).Find(&states).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-	return states, nil
+	return orEmpty(tables.statesByCountry[countryID]), nil
 }
 
 // GetAllCitiesService returns all cities
 func GetAllCitiesService() ([]models.City, error) {
-	var cities []models.City
-	if err := config.DB.Find(&cities).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-	return cities, nil
+	return orEmpty(tables.cities), nil
 }
 
 // GetCitiesByStateService returns cities filtered by state only
 func GetCitiesByStateService(stateID uint) ([]models.City, error) {
-	var cities []models.City
-	db := config.DB
-
-	if stateID != 0 {
-		db = db.Where("state_id = ?", stateID)
-	}
-
-	if err := db.Find(&cities).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-	return cities, nil
+	if stateID == 0 {
+		return orEmpty(tables.cities), nil
+	}
+	return orEmpty(tables.citiesByState[stateID]), nil
 }
 
 // GetAllDistricts fetches all districts without filter
 func GetAllDistricts() ([]models.District, error) {
-	var districts []models.District
-
-	if err := config.DB.Find(&districts).Error; err != nil {
+	tables, err := geoLocations()
+	if err != nil {
 		return nil, err
 	}
-
-	return districts, nil
+	return orEmpty(tables.districts), nil
 }
 
 // GetDistrictsByStateCountry fetches districts filtered by state and/or country
 func GetDistrictsByStateCountry(stateID, countryID uint) ([]models.District, error) {
-	var districts []models.District
-
-	db := config.DB
+	tables, err := geoLocations()
+	if err != nil {
+		return nil, err
+	}
 
+	districts := tables.districts
 	if stateID != 0 {
-		db = db.Where("state_id = ?", stateID)
+		districts = tables.districtsByState[stateID]
 	}
-	if countryID != 0 {
-		db = db.Where("country_id = ?", countryID)
+	if countryID == 0 {
+		return orEmpty(districts), nil
 	}
 
-	if err := db.Find(&districts).Error; err != nil {
-		return nil, err
+	filtered := []models.District{}
+	for _, district := range districts {
+		if district.CountryID == countryID {
+			filtered = append(filtered, district)
+		}
 	}
-
-	return districts, nil
+	return filtered, nil
 }
 
 func GetAllPromotionMaterialTypesService() ([]models.PromotionMaterial, error) {
-	var list []models.PromotionMaterial
-	if err := config.DB.Find(&list).Error; err != nil {
-		return nil, err
-	}
-	return list, nil
+	return cached(context.Background(), CacheGroupMaster, "promotion_material_types", func() ([]models.PromotionMaterial, error) {
+		var list []models.PromotionMaterial
+		if err := config.DB.Find(&list).Error; err != nil {
+			return nil, err
+		}
+		return list, nil
+	})
 }
 
 // GetCoordinatorsDropdownService
 func GetCoordinatorDropdownService() ([]models.BranchMember, error) {
-	var list []models.BranchMember
+	return cached(context.Background(), CacheGroupMaster, "coordinators", func() ([]models.BranchMember, error) {
+		var list []models.BranchMember
 
-	err := config.DB.
-		Model(&models.BranchMember{}).
-		Select("id, name").
-		Where("branch_role = ?", "Coordinator").
-		Order("name ASC").
-		Find(&list).Error
+		err := config.DB.
+			Model(&models.BranchMember{}).
+			Select("id, name").
+			Where("branch_role = ?", "Coordinator").
+			Order("name ASC").
+			Find(&list).Error
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return nil, err
+		}
 
-	return list, nil
+		return list, nil
+	})
 }
 
 // GetOratorDropdownService
 func GetOratorDropdownService() ([]models.BranchMember, error) {
-	var list []models.BranchMember
+	return cached(context.Background(), CacheGroupMaster, "orators", func() ([]models.BranchMember, error) {
+		var list []models.BranchMember
 
-	err := config.DB.
-		Model(&models.BranchMember{}).
-		Select("id, name").
-		Where("branch_role IN ?", []string{"Coordinator", "Preacher"}).
-		Order("name ASC").
-		Find(&list).Error
+		err := config.DB.
+			Model(&models.BranchMember{}).
+			Select("id, name").
+			Where("branch_role IN ?", []string{"Coordinator", "Preacher"}).
+			Order("name ASC").
+			Find(&list).Error
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return nil, err
+		}
 
-	return list, nil
+		return list, nil
+	})
 }
 
 // GetAllLanguagesService returns all languages
 func GetAllLanguagesService() ([]models.Language, error) {
-	var languages []models.Language
-	if err := config.DB.Order("name ASC").Find(&languages).Error; err != nil {
-		return nil, err
-	}
-	return languages, nil
+	return cached(context.Background(), CacheGroupMaster, "languages", func() ([]models.Language, error) {
+		var languages []models.Language
+		if err := config.DB.Order("name ASC").Find(&languages).Error; err != nil {
+			return nil, err
+		}
+		return languages, nil
+	})
 }
 
 // GetAllSevaTypesService returns all seva types
 func GetAllSevaTypesService() ([]models.SevaType, error) {
-	var sevaTypes []models.SevaType
-	if err := config.DB.Order("name ASC").Find(&sevaTypes).Error; err != nil {
-		return nil, err
-	}
-	return sevaTypes, nil
+	return cached(context.Background(), CacheGroupMaster, "seva_types", func() ([]models.SevaType, error) {
+		var sevaTypes []models.SevaType
+		if err := config.DB.Order("name ASC").Find(&sevaTypes).Error; err != nil {
+			return nil, err
+		}
+		return sevaTypes, nil
+	})
 }
 
 // GetAllEventSubCategoriesService returns all event sub categories
 func GetAllEventSubCategoriesService() ([]models.EventSubCategory, error) {
-	var subCategories []models.EventSubCategory
-	if err := config.DB.Preload("EventCategory").Preload("EventCategory.EventType").Order("name ASC").Find(&subCategories).Error; err != nil {
-		return nil, err
-	}
-	return subCategories, nil
+	return cached(context.Background(), CacheGroupMaster, "event_sub_categories", func() ([]models.EventSubCategory, error) {
+		var subCategories []models.EventSubCategory
+		if err := config.DB.Preload("EventCategory").Preload("EventCategory.EventType").Order("name ASC").Find(&subCategories).Error; err != nil {
+			return nil, err
+		}
+		return subCategories, nil
+	})
 }
 
 // GetEventSubCategoriesByCategoryService returns event sub categories filtered by category ID
 func GetEventSubCategoriesByCategoryService(categoryID uint) ([]models.EventSubCategory, error) {
-	var subCategories []models.EventSubCategory
-	if err := config.DB.Where("event_category_id = ?", categoryID).Preload("EventCategory").Preload("EventCategory.EventType").Order("name ASC").Find(&subCategories).Error; err != nil {
-		return nil, err
-	}
-	return subCategories, nil
+	return cached(context.Background(), CacheGroupMaster, "event_sub_categories:" + strconv.FormatUint(uint64(categoryID), 10), func() ([]models.EventSubCategory, error) {
+		var subCategories []models.EventSubCategory
+		if err := config.DB.Where("event_category_id = ?", categoryID).Preload("EventCategory").Preload("EventCategory.EventType").Order("name ASC").Find(&subCategories).Error; err != nil {
+			return nil, err
+		}
+		return subCategories, nil
+	})
 }
 
 // GetAllRolesService returns all roles
 func GetAllRolesService() ([]models.Role, error) {
-	var roles []models.Role
-	if err := config.DB.Order("name ASC").Find(&roles).Error; err != nil {
-		return nil, err
-	}
-	return roles, nil
+	return cached(context.Background(), CacheGroupMaster, "roles", func() ([]models.Role, error) {
+		var roles []models.Role
+		if err := config.DB.Order("name ASC").Find(&roles).Error; err != nil {
+			return nil, err
+		}
+		return roles, nil
+	})
 }
 
 // GetAllThemesService returns all themes
 func GetAllThemesService() ([]models.Theme, error) {
-	var themes []models.Theme
-	if err := config.DB.Order("name ASC").Find(&themes).Error; err != nil {
-		return nil, err
-	}
-	return themes, nil
+	return cached(context.Background(), CacheGroupMaster, "themes", func() ([]models.Theme, error) {
+		var themes []models.Theme
+		if err := config.DB.Order("name ASC").Find(&themes).Error; err != nil {
+			return nil, err
+		}
+		return themes, nil
+	})
 }
\ No newline at end of file
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// ErrImageHashingDisabled is returned when MEDIA_IMAGE_HASHER is "off"
+var ErrImageHashingDisabled = errors.New("image hashing is disabled")
+
+// ImageHasher computes a 64-bit perceptual hash: visually similar images get hashes that
+// differ in few bits. PerceptualHasher (pHash) is built in; other algorithms (dHash, a
+// hashing service) plug in through SetImageHasher.
+type ImageHasher interface {
+	Name() string
+	Hash(img image.Image) (uint64, error)
+}
+
+// PerceptualHasher is the DCT based pHash: the image is reduced to 32x32 grayscale, and each
+// bit tells whether one of the 8x8 lowest frequencies is above their median. It survives
+// rescaling, recompression and small exposure changes, but not crops or rotation.
+type PerceptualHasher struct{}
+
+func (PerceptualHasher) Name() string { return "phash" }
+
+const (
+	phashSize    = 32 // side of the reduced image
+	phashLowFreq = 8  // side of the block of low frequencies kept
+)
+
+// Hash returns the pHash of img
+func (PerceptualHasher) Hash(img image.Image) (uint64, error) {
+	if img.Bounds().Empty() {
+		return 0, errors.New("empty image")
+	}
+	gray := image.NewGray(image.Rect(0, 0, phashSize, phashSize))
+	draw.CatmullRom.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var pixels [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			pixels[y][x] = float64(gray.GrayAt(x, y).Y)
+		}
+	}
+	coefficients := lowFrequencyDCT(&pixels)
+
+	// The DC term (overall brightness) would dominate the median, so it is left out of it
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+// lowFrequencyDCT returns the phashLowFreq x phashLowFreq lowest frequencies of the 2D DCT-II
+// of pixels, row by row. Scale factors are omitted; only their order matters.
+func lowFrequencyDCT(pixels *[phashSize][phashSize]float64) []float64 {
+	var cosines [phashLowFreq][phashSize]float64
+	for u := 0; u < phashLowFreq; u++ {
+		for x := 0; x < phashSize; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * phashSize))
+		}
+	}
+
+	// Rows first, then columns
+	var rows [phashSize][phashLowFreq]float64
+	for y := 0; y < phashSize; y++ {
+		for u := 0; u < phashLowFreq; u++ {
+			var sum float64
+			for x := 0; x < phashSize; x++ {
+				sum += pixels[y][x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, phashLowFreq*phashLowFreq)
+	for v := 0; v < phashLowFreq; v++ {
+		for u := 0; u < phashLowFreq; u++ {
+			var sum float64
+			for y := 0; y < phashSize; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+	return coefficients
+}
+
+var (
+	imageHasher     ImageHasher
+	imageHasherOnce sync.Once
+)
+
+// SetImageHasher overrides the hasher (e.g. another algorithm, or a fake in tests). Hashes
+// of different hashers cannot be compared, so existing hashes must be cleared when switching.
+func SetImageHasher(hasher ImageHasher) {
+	imageHasherOnce.Do(func() {})
+	imageHasher = hasher
+}
+
+// getImageHasher returns the hasher selected by MEDIA_IMAGE_HASHER ("phash" by default, "off"
+// to disable duplicate detection)
+func getImageHasher() (ImageHasher, error) {
+	imageHasherOnce.Do(func() {
+		if config.Media.ImageHasher == "phash" {
+			imageHasher = PerceptualHasher{}
+		}
+	})
+	if imageHasher == nil {
+		return nil, ErrImageHashingDisabled
+	}
+	return imageHasher, nil
+}
+
+// duplicateDistance is the number of differing hash bits up to which two images are reported
+// as near-duplicates (MEDIA_DUPLICATE_DISTANCE)
+func duplicateDistance() int {
+	return config.Media.DuplicateDistance
+}
+
+// HashImage decodes an uploaded image and returns its perceptual hash, stored as BIGINT
+func HashImage(data []byte, contentType string) (int64, error) {
+	if !IsThumbnailSupported(contentType) {
+		return 0, fmt.Errorf("cannot hash %s files", contentType)
+	}
+	hasher, err := getImageHasher()
+	if err != nil {
+		return 0, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("unsupported image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return 0, fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	hash, err := hasher.Hash(img)
+	return int64(hash), err
+}
+
+// MediaDuplicate is an existing image that looks like the one being uploaded
+type MediaDuplicate struct {
+	MediaID          uint      `json:"media_id"`
+	OriginalFilename string    `json:"original_filename,omitempty"`
+	Distance         int       `json:"distance"` // differing hash bits, 0 = visually identical
+	CreatedOn        time.Time `json:"created_on"`
+}
+
+type mediaHashRow struct {
+	ID               uint
+	OriginalFilename string
+	PHash            int64 `gorm:"column:phash"`
+	CreatedOn        time.Time
+}
+
+// FindMediaDuplicates returns the media of the same event (target event_media) or branch
+// (branch_media) whose image is within MEDIA_DUPLICATE_DISTANCE of hash, closest first.
+// excludeID leaves out the record being replaced.
+func FindMediaDuplicates(ctx context.Context, target string, ownerID, excludeID uint, hash int64) ([]MediaDuplicate, error) {
+	query := config.DB.WithContext(ctx)
+	switch target {
+	case ThumbnailTargetEventMedia:
+		query = query.Model(&models.EventMedia{}).Where("event_id = ?", ownerID)
+	case ThumbnailTargetBranchMedia:
+		query = query.Model(&models.BranchMedia{}).Where("branch_id = ?", ownerID)
+	default:
+		return nil, errors.New("unknown media target")
+	}
+
+	var rows []mediaHashRow
+	if err := query.Select("id", "original_filename", "phash", "created_on").
+		Where("phash IS NOT NULL AND id <> ?", excludeID).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	maxDistance := duplicateDistance()
+	duplicates := []MediaDuplicate{}
+	for _, row := range rows {
+		distance := bits.OnesCount64(uint64(row.PHash) ^ uint64(hash))
+		if distance <= maxDistance {
+			duplicates = append(duplicates, MediaDuplicate{
+				MediaID:          row.ID,
+				OriginalFilename: row.OriginalFilename,
+				Distance:         distance,
+				CreatedOn:        row.CreatedOn,
+			})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Distance < duplicates[j].Distance })
+	return duplicates, nil
+}
+
+// CheckMediaDuplicates hashes an uploaded image and looks for near-duplicates among the media
+// of its event or branch. Like QueueThumbnails it never fails the upload: files that are not
+// images, or cannot be hashed, return a nil hash and no duplicates.
+func CheckMediaDuplicates(ctx context.Context, target string, ownerID, excludeID uint, data []byte, contentType string) (*int64, []MediaDuplicate) {
+	if !IsThumbnailSupported(contentType) {
+		return nil, nil
+	}
+	hash, err := HashImage(data, contentType)
+	if err != nil {
+		if !errors.Is(err, ErrImageHashingDisabled) {
+			utils.Logger(ctx).Warn("Image hashing failed", zap.String("target", target), zap.Error(err))
+		}
+		return nil, nil
+	}
+	duplicates, err := FindMediaDuplicates(ctx, target, ownerID, excludeID, hash)
+	if err != nil {
+		utils.Logger(ctx).Warn("Duplicate lookup failed", zap.String("target", target), zap.Uint("owner_id", ownerID), zap.Error(err))
+		return &hash, nil
+	}
+	return &hash, duplicates
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Moving a regional deployment into the central one happens in two phases. The export job
+// writes every event/branch media row (all columns, soft-deleted rows included) with the ETag
+// and size of its objects as NDJSON chunks under exports/media/<export id>/, plus an
+// index.json listing each chunk with its SHA-256. After the objects and the export folder have
+// been copied into the central bucket (e.g. with aws s3 sync), the import job verifies every
+// chunk against the index and re-registers the rows, remapping event and branch IDs.
+
+const (
+	mediaExportPrefix       = "exports/media/"
+	mediaExportVersion      = 1
+	defaultMediaExportChunk = 5000
+	maxMediaExportChunk     = 50000
+	mediaTransferTimeout    = 12 * time.Hour
+)
+
+var (
+	// ErrInvalidMediaExport is returned for unknown media kinds
+	ErrInvalidMediaExport = errors.New("invalid media export")
+	// ErrInvalidMediaImport is returned when the export index cannot be read or is malformed
+	ErrInvalidMediaImport = errors.New("invalid media import")
+	// ErrMediaImportRunning is returned while another import is queued or running
+	ErrMediaImportRunning = errors.New("a media import is already queued or running")
+)
+
+// mediaExportKinds maps exportable kinds to their tables and parent column
+var mediaExportKinds = map[string]struct {
+	table  string
+	parent string // event_id or branch_id, remapped on import
+}{
+	ManifestKindEventMedia:  {"event_media", "event_id"},
+	ManifestKindBranchMedia: {"branch_media", "branch_id"},
+}
+
+// mediaObjectColumns are the columns of a media row that reference stored objects
+var mediaObjectColumns = []string{"s3_key", "thumbnail_s3_key", "thumbnail_medium_s3_key"}
+
+// MediaExportOptions configures an export
+type MediaExportOptions struct {
+	Kinds     []string `json:"kinds"`      // event_media and/or branch_media (default both)
+	ChunkSize int      `json:"chunk_size"` // rows per NDJSON file (default 5000, max 50000)
+}
+
+// MediaExportChunk is one NDJSON file of an export
+type MediaExportChunk struct {
+	Key     string `json:"key"`
+	Kind    string `json:"kind"`
+	Records int    `json:"records"`
+	FirstID uint   `json:"first_id"`
+	LastID  uint   `json:"last_id"`
+	SHA256  string `json:"sha256"`
+}
+
+// MediaExportIndex is index.json of an export
+type MediaExportIndex struct {
+	Version        int                `json:"version"`
+	ExportID       string             `json:"export_id"`
+	GeneratedAt    time.Time          `json:"generated_at"`
+	SourceBucket   string             `json:"source_bucket"`
+	Kinds          []string           `json:"kinds"`
+	Records        int                `json:"records"`
+	MissingObjects int                `json:"missing_objects"` // referenced objects absent from the source bucket
+	Chunks         []MediaExportChunk `json:"chunks"`
+}
+
+// MediaExportObject is an object referenced by an exported row
+type MediaExportObject struct {
+	Key     string `json:"key"`
+	ETag    string `json:"etag,omitempty"`
+	Size    int64  `json:"size"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// MediaExportRecord is one NDJSON line: the full row and the checksums of its objects
+type MediaExportRecord struct {
+	Kind    string                 `json:"kind"`
+	ID      uint                   `json:"id"`
+	Record  map[string]interface{} `json:"record"`
+	Objects []MediaExportObject    `json:"objects,omitempty"`
+}
+
+// mediaExportPayload is the JobTypeMediaExport payload; AfterIDs, Chunks and the counters are the checkpoint
+type mediaExportPayload struct {
+	MediaExportOptions
+	ExportID       string             `json:"export_id"`
+	AfterIDs       map[string]uint    `json:"after_ids"`
+	Chunks         []MediaExportChunk `json:"chunks"`
+	Records        int                `json:"records"`
+	MissingObjects int                `json:"missing_objects"`
+	Total          int64              `json:"total"`
+}
+
+// StartMediaExport queues an export of all event and/or branch media metadata
+func StartMediaExport(ctx context.Context, opts MediaExportOptions, createdBy *uint) (*models.Job, error) {
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = []string{ManifestKindEventMedia, ManifestKindBranchMedia}
+	}
+	for _, kind := range opts.Kinds {
+		if _, ok := mediaExportKinds[kind]; !ok {
+			return nil, fmt.Errorf("%w: unknown kind %q (use event_media or branch_media)", ErrInvalidMediaExport, kind)
+		}
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultMediaExportChunk
+	} else if opts.ChunkSize > maxMediaExportChunk {
+		opts.ChunkSize = maxMediaExportChunk
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	payload := mediaExportPayload{
+		MediaExportOptions: opts,
+		ExportID:           time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(id),
+		AfterIDs:           map[string]uint{},
+	}
+	for _, kind := range opts.Kinds {
+		var count int64
+		if err := config.DB.WithContext(ctx).Table(mediaExportKinds[kind].table).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		payload.Total += count
+	}
+	return EnqueueJob(ctx, JobTypeMediaExport, payload, JobOptions{CreatedBy: createdBy})
+}
+
+func runMediaExportJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload mediaExportPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload.AfterIDs == nil {
+		payload.AfterIDs = map[string]uint{}
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	prefix := mediaExportPrefix + payload.ExportID + "/"
+
+	run.SetProgress(0, "Listing stored objects")
+	objects, err := listBucketObjects(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kind := range payload.Kinds {
+		table := mediaExportKinds[kind].table
+		for {
+			var rows []map[string]interface{}
+			if err := config.DB.WithContext(ctx).Table(table).
+				Where("id > ?", payload.AfterIDs[kind]).
+				Order("id").Limit(payload.ChunkSize).
+				Find(&rows).Error; err != nil {
+				return nil, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			chunk := MediaExportChunk{
+				Key:  fmt.Sprintf("%s%s-%05d.ndjson", prefix, kind, len(payload.Chunks)+1),
+				Kind: kind,
+			}
+			for _, row := range rows {
+				record := MediaExportRecord{Kind: kind, ID: mediaRowID(row), Record: row}
+				for _, column := range mediaObjectColumns {
+					key, _ := row[column].(string)
+					if key == "" {
+						continue
+					}
+					object := MediaExportObject{Key: key}
+					if info, ok := objects[key]; ok {
+						object.ETag, object.Size = info.ETag, info.Size
+					} else {
+						object.Missing = true
+						payload.MissingObjects++
+					}
+					record.Objects = append(record.Objects, object)
+				}
+				if err := encoder.Encode(record); err != nil {
+					return nil, err
+				}
+				if chunk.FirstID == 0 {
+					chunk.FirstID = record.ID
+				}
+				chunk.LastID = record.ID
+				chunk.Records++
+			}
+			digest := sha256.Sum256(buf.Bytes())
+			chunk.SHA256 = hex.EncodeToString(digest[:])
+			if err := storage.Upload(ctx, chunk.Key, bytes.NewReader(buf.Bytes()), "application/x-ndjson", nil); err != nil {
+				return nil, fmt.Errorf("failed to store export chunk %s: %w", chunk.Key, err)
+			}
+
+			payload.Chunks = append(payload.Chunks, chunk)
+			payload.Records += chunk.Records
+			payload.AfterIDs[kind] = chunk.LastID
+			if err := run.SaveCheckpoint(payload); err != nil {
+				return nil, err
+			}
+			percent := 99
+			if payload.Total > 0 && int64(payload.Records) < payload.Total {
+				percent = int(int64(payload.Records) * 100 / payload.Total)
+			}
+			run.SetProgress(percent, fmt.Sprintf("Exported %d of %d records", payload.Records, payload.Total))
+		}
+	}
+
+	index := MediaExportIndex{
+		Version:        mediaExportVersion,
+		ExportID:       payload.ExportID,
+		GeneratedAt:    time.Now().UTC(),
+		SourceBucket:   storage.Bucket(),
+		Kinds:          payload.Kinds,
+		Records:        payload.Records,
+		MissingObjects: payload.MissingObjects,
+		Chunks:         payload.Chunks,
+	}
+	body, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	indexKey := prefix + "index.json"
+	if err := storage.Upload(ctx, indexKey, bytes.NewReader(body), "application/json", nil); err != nil {
+		return nil, fmt.Errorf("failed to store export index: %w", err)
+	}
+	digest := sha256.Sum256(body)
+
+	utils.BaseLogger().Info("Media export written",
+		zap.String("export_id", payload.ExportID),
+		zap.Int("records", payload.Records),
+		zap.Int("chunks", len(payload.Chunks)),
+		zap.Int("missing_objects", payload.MissingObjects))
+	return models.JSONB{
+		"export_id":       payload.ExportID,
+		"index_key":       indexKey,
+		"index_sha256":    hex.EncodeToString(digest[:]),
+		"records":         payload.Records,
+		"chunks":          len(payload.Chunks),
+		"missing_objects": payload.MissingObjects,
+		"file_key":        indexKey,
+		"filename":        "media-export-" + payload.ExportID + ".json",
+	}, nil
+}
+
+// MediaImportOptions configures the import of an export copied into this deployment's bucket
+type MediaImportOptions struct {
+	IndexKey string `json:"index_key" binding:"required"` // e.g. exports/media/<export id>/index.json
+	// Source -> target IDs for parents whose ID differs in this deployment; unmapped IDs are kept
+	EventIDMap  map[string]uint `json:"event_id_map"`
+	BranchIDMap map[string]uint `json:"branch_id_map"`
+	// VerifyObjects skips rows whose objects are missing here or differ from the exported checksums
+	VerifyObjects bool `json:"verify_objects"`
+	DryRun        bool `json:"dry_run"`
+}
+
+// mediaImportPayload is the JobTypeMediaImport payload; NextChunk and Counts are the checkpoint
+type mediaImportPayload struct {
+	MediaImportOptions
+	NextChunk int            `json:"next_chunk"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// Media import outcomes per row
+const (
+	mediaImportImported      = "imported"
+	mediaImportDuplicate     = "skipped_duplicate"      // a row with the same s3_key exists already
+	mediaImportMissingParent = "skipped_missing_parent" // the (mapped) event or branch does not exist
+	mediaImportObjectInvalid = "skipped_object_invalid" // verify_objects: object missing or changed
+)
+
+// StartMediaImport checks the export index and queues the import. Only one import runs at a time
+// so the duplicate check on s3_key cannot race.
+func StartMediaImport(ctx context.Context, opts MediaImportOptions, createdBy *uint) (*models.Job, error) {
+	if _, err := readMediaExportIndex(ctx, opts.IndexKey); err != nil {
+		return nil, err
+	}
+
+	var running int64
+	if err := config.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", JobTypeMediaImport, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrMediaImportRunning
+	}
+
+	payload := mediaImportPayload{MediaImportOptions: opts, Counts: map[string]int{}}
+	return EnqueueJob(ctx, JobTypeMediaImport, payload, JobOptions{CreatedBy: createdBy})
+}
+
+func readMediaExportIndex(ctx context.Context, key string) (*MediaExportIndex, error) {
+	if !strings.HasPrefix(key, mediaExportPrefix) || !strings.HasSuffix(key, "/index.json") {
+		return nil, fmt.Errorf("%w: index_key must look like %s<export id>/index.json", ErrInvalidMediaImport, mediaExportPrefix)
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: %s not found in bucket %s", ErrInvalidMediaImport, key, storage.Bucket())
+		}
+		return nil, err
+	}
+	defer body.Close()
+
+	var index MediaExportIndex
+	if err := json.NewDecoder(body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("%w: %s is not an export index: %v", ErrInvalidMediaImport, key, err)
+	}
+	if index.Version != mediaExportVersion {
+		return nil, fmt.Errorf("%w: unsupported export version %d", ErrInvalidMediaImport, index.Version)
+	}
+	for _, chunk := range index.Chunks {
+		if _, ok := mediaExportKinds[chunk.Kind]; !ok {
+			return nil, fmt.Errorf("%w: unknown kind %q in chunk %s", ErrInvalidMediaImport, chunk.Kind, chunk.Key)
+		}
+	}
+	return &index, nil
+}
+
+func runMediaImportJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload mediaImportPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload.Counts == nil {
+		payload.Counts = map[string]int{}
+	}
+	index, err := readMediaExportIndex(ctx, payload.IndexKey)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMediaImport) {
+			return nil, PermanentJobError(err)
+		}
+		return nil, err
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	for payload.NextChunk < len(index.Chunks) {
+		chunk := index.Chunks[payload.NextChunk]
+		records, err := readMediaExportChunk(ctx, storage, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for _, record := range records {
+				outcome, err := importMediaRecord(ctx, tx, storage, record, payload.MediaImportOptions)
+				if err != nil {
+					return fmt.Errorf("importing %s %d from %s: %w", record.Kind, record.ID, chunk.Key, err)
+				}
+				payload.Counts[outcome]++
+			}
+			if payload.DryRun {
+				return errMediaImportDryRun
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errMediaImportDryRun) {
+			return nil, err
+		}
+
+		payload.NextChunk++
+		if err := run.SaveCheckpoint(payload); err != nil {
+			return nil, err
+		}
+		run.SetProgress(payload.NextChunk*100/len(index.Chunks),
+			fmt.Sprintf("Imported %d of %d chunks", payload.NextChunk, len(index.Chunks)))
+	}
+
+	result := models.JSONB{
+		"export_id": index.ExportID,
+		"chunks":    len(index.Chunks),
+		"records":   index.Records,
+		"dry_run":   payload.DryRun,
+	}
+	for outcome, count := range payload.Counts {
+		result[outcome] = count
+	}
+	utils.BaseLogger().Info("Media import finished", zap.String("export_id", index.ExportID), zap.Any("counts", payload.Counts))
+	return result, nil
+}
+
+// errMediaImportDryRun rolls back a chunk's transaction on dry runs
+var errMediaImportDryRun = errors.New("dry run")
+
+// readMediaExportChunk downloads a chunk and checks it against the SHA-256 in the index
+func readMediaExportChunk(ctx context.Context, storage Storage, chunk MediaExportChunk) ([]MediaExportRecord, error) {
+	body, err := storage.Get(ctx, chunk.Key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, PermanentJobError(fmt.Errorf("export chunk %s is missing", chunk.Key))
+		}
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != chunk.SHA256 {
+		return nil, PermanentJobError(fmt.Errorf("export chunk %s does not match its checksum", chunk.Key))
+	}
+
+	var records []MediaExportRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		var record MediaExportRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, PermanentJobError(fmt.Errorf("export chunk %s: %w", chunk.Key, err))
+		}
+		if record.Kind != chunk.Kind {
+			return nil, PermanentJobError(fmt.Errorf("export chunk %s holds a %s record", chunk.Key, record.Kind))
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, PermanentJobError(fmt.Errorf("export chunk %s: %w", chunk.Key, err))
+	}
+	return records, nil
+}
+
+// importMediaRecord re-registers one exported row under a new ID
+func importMediaRecord(ctx context.Context, tx *gorm.DB, storage Storage, record MediaExportRecord, opts MediaImportOptions) (string, error) {
+	kind := mediaExportKinds[record.Kind]
+	row := make(map[string]interface{}, len(record.Record))
+	for column, value := range record.Record {
+		row[column] = importColumnValue(value)
+	}
+	delete(row, "id")
+
+	idMap, parentTable := opts.EventIDMap, "event_details"
+	if kind.parent == "branch_id" {
+		idMap, parentTable = opts.BranchIDMap, "branches"
+	}
+	parentID, ok := row[kind.parent].(int64)
+	if !ok {
+		return mediaImportMissingParent, nil
+	}
+	if mapped, ok := idMap[strconv.FormatInt(parentID, 10)]; ok {
+		parentID = int64(mapped)
+	}
+	row[kind.parent] = parentID
+
+	var parents int64
+	if err := tx.Table(parentTable).Where("id = ?", parentID).Count(&parents).Error; err != nil {
+		return "", err
+	}
+	if parents == 0 {
+		return mediaImportMissingParent, nil
+	}
+
+	if key, _ := row["s3_key"].(string); key != "" {
+		var existing int64
+		if err := tx.Table(kind.table).Where("s3_key = ?", key).Count(&existing).Error; err != nil {
+			return "", err
+		}
+		if existing > 0 {
+			return mediaImportDuplicate, nil
+		}
+	}
+
+	if opts.VerifyObjects {
+		for _, object := range record.Objects {
+			if object.Missing {
+				continue
+			}
+			info, err := storage.Head(ctx, object.Key)
+			if err != nil {
+				if errors.Is(err, ErrObjectNotFound) {
+					return mediaImportObjectInvalid, nil
+				}
+				return "", err
+			}
+			if info.Size != object.Size || (object.ETag != "" && info.ETag != object.ETag) {
+				return mediaImportObjectInvalid, nil
+			}
+		}
+	}
+
+	if err := tx.Table(kind.table).Create(row).Error; err != nil {
+		return "", err
+	}
+	return mediaImportImported, nil
+}
+
+// importColumnValue turns decoded JSON numbers back into integers where possible so they bind
+// to integer columns
+func importColumnValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	f, _ := number.Float64()
+	return f
+}
+
+func mediaRowID(row map[string]interface{}) uint {
+	switch id := row["id"].(type) {
+	case int64:
+		return uint(id)
+	case int32:
+		return uint(id)
+	case int:
+		return uint(id)
+	case uint:
+		return id
+	}
+	return 0
+}
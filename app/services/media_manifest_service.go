@@ -0,0 +1,524 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Uploaded documents are covered by periodic signed manifests (media ID -> S3 key -> ETag/size).
+// Each manifest is HMAC-signed with MEDIA_MANIFEST_SIGNING_KEY, optionally encrypted with
+// MEDIA_MANIFEST_ENCRYPTION_KEY (AES-256-GCM), and written to MEDIA_MANIFEST_BUCKET (default: the
+// media bucket under manifests/media/). The payload digest is also kept in media_manifests and every
+// manifest carries the digest of the previous one, so edits to the bucket, to a manifest or to the
+// manifest history all show up in VerifyMediaManifest.
+
+const (
+	mediaManifestPrefix  = "manifests/media/"
+	mediaManifestVersion = 1
+
+	// ManifestSourceScheduled and ManifestSourceManual record what produced a manifest
+	ManifestSourceScheduled = "scheduled"
+	ManifestSourceManual    = "manual"
+
+	// Manifest entry kinds
+	ManifestKindEventMedia      = "event_media"
+	ManifestKindBranchMedia     = "branch_media"
+	ManifestKindDonationReceipt = "donation_receipt"
+
+	// mediaManifestLockKey is the advisory lock that keeps instances from building manifests concurrently
+	mediaManifestLockKey = 73510001
+
+	// maxVerificationIssues caps the mismatches listed in a verification result (counts are exact)
+	maxVerificationIssues = 1000
+)
+
+var (
+	// ErrManifestSigningKeyMissing is returned when MEDIA_MANIFEST_SIGNING_KEY is not configured
+	ErrManifestSigningKeyMissing = errors.New("MEDIA_MANIFEST_SIGNING_KEY is not configured")
+	// ErrManifestBusy is returned when another instance is building a manifest
+	ErrManifestBusy = errors.New("a media manifest is already being generated")
+	// ErrManifestNotFound is returned for unknown manifest IDs
+	ErrManifestNotFound = errors.New("media manifest not found")
+)
+
+// ManifestEntry is one uploaded object covered by a manifest
+type ManifestEntry struct {
+	Kind  string `json:"kind"`
+	ID    uint   `json:"id"`
+	S3Key string `json:"s3_key"`
+	ETag  string `json:"etag,omitempty"`
+	Size  int64  `json:"size"`
+}
+
+// mediaManifestPayload is the signed content of a manifest
+type mediaManifestPayload struct {
+	Version        int             `json:"version"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	Bucket         string          `json:"bucket"`
+	PreviousSHA256 string          `json:"previous_sha256,omitempty"`
+	Entries        []ManifestEntry `json:"entries"`
+	Missing        []ManifestEntry `json:"missing,omitempty"` // rows whose object was already absent
+}
+
+// mediaManifestEnvelope is what is stored in S3. Signature is the hex HMAC-SHA256 of the
+// plaintext payload; Payload is the (possibly encrypted) payload JSON.
+type mediaManifestEnvelope struct {
+	Version   int    `json:"version"`
+	Algorithm string `json:"algorithm"`
+	Signature string `json:"signature"`
+	Encrypted bool   `json:"encrypted"`
+	Nonce     []byte `json:"nonce,omitempty"`
+	Payload   []byte `json:"payload"`
+}
+
+// ManifestMismatch is a manifest entry whose object changed in the bucket
+type ManifestMismatch struct {
+	ManifestEntry
+	CurrentETag string `json:"current_etag"`
+	CurrentSize int64  `json:"current_size"`
+}
+
+// ManifestVerification is the result of checking a manifest and the bucket against each other
+type ManifestVerification struct {
+	ManifestID     uint               `json:"manifest_id"`
+	VerifiedAt     time.Time          `json:"verified_at"`
+	SignatureValid bool               `json:"signature_valid"`
+	DigestMatches  bool               `json:"digest_matches"` // payload digest equals the one recorded in the database
+	ChainValid     bool               `json:"chain_valid"`    // previous_sha256 matches the preceding manifest
+	Checked        int                `json:"checked"`
+	Intact         int                `json:"intact"`
+	MissingCount   int                `json:"missing_count"`
+	ModifiedCount  int                `json:"modified_count"`
+	Missing        []ManifestEntry    `json:"missing,omitempty"`
+	Modified       []ManifestMismatch `json:"modified,omitempty"`
+	Valid          bool               `json:"valid"`
+	Error          string             `json:"error,omitempty"` // why the manifest itself could not be read or trusted
+}
+
+// mediaManifestBucket returns the bucket manifests are written to. A separate bucket (ideally
+// with object lock) keeps manifests out of reach of credentials that can modify media.
+func mediaManifestBucket() string {
+	if bucket := config.Media.ManifestBucket; bucket != "" {
+		return bucket
+	}
+	if fileStorage == nil {
+		return ""
+	}
+	return fileStorage.Bucket()
+}
+
+func mediaManifestSigningKey() ([]byte, error) {
+	key := config.Media.ManifestSigningKey
+	if key == "" {
+		return nil, ErrManifestSigningKeyMissing
+	}
+	return []byte(key), nil
+}
+
+// mediaManifestCipher returns the AES-256-GCM cipher derived from MEDIA_MANIFEST_ENCRYPTION_KEY,
+// or nil when manifests are stored unencrypted
+func mediaManifestCipher() (cipher.AEAD, error) {
+	secret := config.Media.ManifestEncryptionKey
+	if secret == "" {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func signManifest(key, payload []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateMediaManifest builds, signs and stores a manifest of all uploaded documents, including
+// soft-deleted rows whose objects are still kept. Only one instance builds a manifest at a time.
+func GenerateMediaManifest(ctx context.Context, source string, createdBy *uint) (*models.MediaManifest, error) {
+	return generateMediaManifest(ctx, source, createdBy, 0)
+}
+
+// generateMediaManifest builds a manifest under the advisory lock. With notOlderThan > 0 it
+// returns (nil, nil) when another instance stored a manifest within that window.
+func generateMediaManifest(ctx context.Context, source string, createdBy *uint, notOlderThan time.Duration) (*models.MediaManifest, error) {
+	signingKey, err := mediaManifestSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest *models.MediaManifest
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", mediaManifestLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return ErrManifestBusy
+		}
+		if notOlderThan > 0 && !mediaManifestDue(tx, notOlderThan) {
+			return nil
+		}
+
+		var err error
+		manifest, err = buildMediaManifest(ctx, tx, storage, signingKey, source, createdBy)
+		return err
+	})
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+
+	utils.Logger(ctx).Info("Media manifest stored",
+		zap.Uint("manifest_id", manifest.ID),
+		zap.String("s3_key", manifest.S3Key),
+		zap.Int("entries", manifest.EntryCount),
+		zap.Int("missing", manifest.MissingCount))
+	return manifest, nil
+}
+
+func buildMediaManifest(ctx context.Context, tx *gorm.DB, storage Storage, signingKey []byte, source string, createdBy *uint) (*models.MediaManifest, error) {
+	rows, err := loadManifestRows(tx)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := listBucketObjects(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous models.MediaManifest
+	previousSHA := ""
+	if err := tx.Order("id DESC").Limit(1).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	if previous.ID != 0 {
+		previousSHA = previous.PayloadSHA256
+	}
+
+	now := time.Now().UTC()
+	payload := mediaManifestPayload{
+		Version:        mediaManifestVersion,
+		GeneratedAt:    now,
+		Bucket:         storage.Bucket(),
+		PreviousSHA256: previousSHA,
+		Entries:        make([]ManifestEntry, 0, len(rows)),
+	}
+	for _, entry := range rows {
+		object, ok := objects[entry.S3Key]
+		if !ok {
+			payload.Missing = append(payload.Missing, entry)
+			continue
+		}
+		entry.ETag = object.ETag
+		entry.Size = object.Size
+		payload.Entries = append(payload.Entries, entry)
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(plaintext)
+
+	envelope := mediaManifestEnvelope{
+		Version:   mediaManifestVersion,
+		Algorithm: "HMAC-SHA256",
+		Signature: signManifest(signingKey, plaintext),
+		Payload:   plaintext,
+	}
+	aead, err := mediaManifestCipher()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest encryption key: %w", err)
+	}
+	if aead != nil {
+		envelope.Nonce = make([]byte, aead.NonceSize())
+		if _, err := rand.Read(envelope.Nonce); err != nil {
+			return nil, err
+		}
+		envelope.Payload = aead.Seal(nil, envelope.Nonce, plaintext, nil)
+		envelope.Encrypted = true
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &models.MediaManifest{
+		Bucket:         mediaManifestBucket(),
+		S3Key:          mediaManifestPrefix + now.Format("20060102T150405Z") + ".json",
+		EntryCount:     len(payload.Entries),
+		MissingCount:   len(payload.Missing),
+		PayloadSHA256:  hex.EncodeToString(digest[:]),
+		PreviousSHA256: previousSHA,
+		Encrypted:      envelope.Encrypted,
+		Source:         source,
+		CreatedBy:      createdBy,
+	}
+	manifestStorage, err := storageForBucket(manifest.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifestStorage.Upload(ctx, manifest.S3Key, bytes.NewReader(body), "application/json", nil); err != nil {
+		return nil, fmt.Errorf("failed to store manifest (bucket: %s, key: %s): %w", manifest.Bucket, manifest.S3Key, err)
+	}
+
+	if err := tx.Create(manifest).Error; err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// loadManifestRows returns every row that references an uploaded object, ordered by kind and ID
+func loadManifestRows(db *gorm.DB) ([]ManifestEntry, error) {
+	sources := []struct {
+		kind   string
+		model  interface{}
+		column string
+	}{
+		{ManifestKindEventMedia, &models.EventMedia{}, "s3_key"},
+		{ManifestKindBranchMedia, &models.BranchMedia{}, "s3_key"},
+		{ManifestKindDonationReceipt, &models.Donation{}, "receipt_s3_key"},
+	}
+
+	var entries []ManifestEntry
+	for _, src := range sources {
+		var rows []struct {
+			ID    uint
+			S3Key string
+		}
+		err := db.Unscoped().Model(src.model).
+			Select("id, " + src.column + " AS s3_key").
+			Where(src.column + " IS NOT NULL AND " + src.column + " <> ''").
+			Order("id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("loading %s keys: %w", src.kind, err)
+		}
+		for _, row := range rows {
+			entries = append(entries, ManifestEntry{Kind: src.kind, ID: row.ID, S3Key: row.S3Key})
+		}
+	}
+	return entries, nil
+}
+
+type bucketObject struct {
+	ETag string
+	Size int64
+}
+
+// listBucketObjects lists the bucket once (1000 keys per request on S3) rather than issuing a
+// HeadObject per entry. Manifests stored in the same bucket are skipped.
+func listBucketObjects(ctx context.Context, storage Storage) (map[string]bucketObject, error) {
+	objects := map[string]bucketObject{}
+	err := storage.List(ctx, "", func(object ObjectInfo) error {
+		if !strings.HasPrefix(object.Key, mediaManifestPrefix) {
+			objects[object.Key] = bucketObject{ETag: object.ETag, Size: object.Size}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// GetMediaManifests lists manifests, newest first
+func GetMediaManifests(limit int) ([]models.MediaManifest, error) {
+	var manifests []models.MediaManifest
+	err := config.DB.Order("id DESC").Limit(limit).Find(&manifests).Error
+	return manifests, err
+}
+
+// VerifyMediaManifest checks a manifest's signature, digest and place in the hash chain, then
+// compares every entry with the current bucket contents. Problems with the manifest itself are
+// reported in the result (Valid=false) rather than as an error.
+func VerifyMediaManifest(ctx context.Context, id uint) (*ManifestVerification, error) {
+	var manifest models.MediaManifest
+	if err := config.DB.First(&manifest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrManifestNotFound
+		}
+		return nil, err
+	}
+	signingKey, err := mediaManifestSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetStorage(); err != nil {
+		return nil, err
+	}
+
+	result := &ManifestVerification{ManifestID: manifest.ID, VerifiedAt: time.Now().UTC()}
+	payload, err := readMediaManifest(ctx, manifest, signingKey, result)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	var previous models.MediaManifest
+	if err := config.DB.Where("id < ?", manifest.ID).Order("id DESC").Limit(1).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	result.ChainValid = payload.PreviousSHA256 == manifest.PreviousSHA256 && payload.PreviousSHA256 == previous.PayloadSHA256
+
+	mediaStorage, err := storageForBucket(payload.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := listBucketObjects(ctx, mediaStorage)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range payload.Entries {
+		result.Checked++
+		object, ok := objects[entry.S3Key]
+		switch {
+		case !ok:
+			result.MissingCount++
+			if len(result.Missing) < maxVerificationIssues {
+				result.Missing = append(result.Missing, entry)
+			}
+		case object.ETag != entry.ETag || object.Size != entry.Size:
+			result.ModifiedCount++
+			if len(result.Modified) < maxVerificationIssues {
+				result.Modified = append(result.Modified, ManifestMismatch{ManifestEntry: entry, CurrentETag: object.ETag, CurrentSize: object.Size})
+			}
+		default:
+			result.Intact++
+		}
+	}
+
+	result.Valid = result.SignatureValid && result.DigestMatches && result.ChainValid &&
+		result.MissingCount == 0 && result.ModifiedCount == 0
+	if !result.Valid {
+		utils.Logger(ctx).Warn("Media manifest verification failed",
+			zap.Uint("manifest_id", manifest.ID),
+			zap.Bool("signature_valid", result.SignatureValid),
+			zap.Bool("digest_matches", result.DigestMatches),
+			zap.Bool("chain_valid", result.ChainValid),
+			zap.Int("missing", result.MissingCount),
+			zap.Int("modified", result.ModifiedCount))
+	}
+	return result, nil
+}
+
+// readMediaManifest downloads, decrypts and authenticates a manifest, recording the signature and
+// digest checks in result
+func readMediaManifest(ctx context.Context, manifest models.MediaManifest, signingKey []byte, result *ManifestVerification) (*mediaManifestPayload, error) {
+	manifestStorage, err := storageForBucket(manifest.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	object, err := manifestStorage.Get(ctx, manifest.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest (bucket: %s, key: %s): %w", manifest.Bucket, manifest.S3Key, err)
+	}
+	defer object.Close()
+	body, err := io.ReadAll(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope mediaManifestEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("manifest is not valid JSON: %w", err)
+	}
+
+	plaintext := envelope.Payload
+	if envelope.Encrypted {
+		aead, err := mediaManifestCipher()
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest encryption key: %w", err)
+		}
+		if aead == nil {
+			return nil, errors.New("manifest is encrypted but MEDIA_MANIFEST_ENCRYPTION_KEY is not configured")
+		}
+		if plaintext, err = aead.Open(nil, envelope.Nonce, envelope.Payload, nil); err != nil {
+			return nil, errors.New("manifest could not be decrypted (wrong key or tampered ciphertext)")
+		}
+	}
+
+	result.SignatureValid = hmac.Equal([]byte(signManifest(signingKey, plaintext)), []byte(envelope.Signature))
+	digest := sha256.Sum256(plaintext)
+	result.DigestMatches = hex.EncodeToString(digest[:]) == manifest.PayloadSHA256
+
+	var payload mediaManifestPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("manifest payload is not valid JSON: %w", err)
+	}
+	return &payload, nil
+}
+
+// StartMediaManifestScheduler generates a manifest every MEDIA_MANIFEST_INTERVAL (Go duration,
+// default 24h; "0" turns scheduling off). It is a no-op when no signing key is configured.
+// Instances check regularly and only build a manifest when the latest one is older than the
+// interval, so several instances produce one manifest per interval between them.
+func StartMediaManifestScheduler() {
+	logger := utils.BaseLogger()
+	if _, err := mediaManifestSigningKey(); err != nil {
+		logger.Info("Media manifests disabled: MEDIA_MANIFEST_SIGNING_KEY is not set")
+		return
+	}
+
+	interval := config.Media.ManifestInterval
+	if interval <= 0 {
+		return
+	}
+
+	check := interval / 4
+	if check > time.Hour {
+		check = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !mediaManifestDue(config.DB, interval) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			_, err := generateMediaManifest(ctx, ManifestSourceScheduled, nil, interval)
+			cancel()
+			if err != nil && !errors.Is(err, ErrManifestBusy) {
+				logger.Error("Scheduled media manifest failed", zap.Error(err))
+			}
+		}
+	}()
+	logger.Info("Media manifest scheduler started", zap.Duration("interval", interval))
+}
+
+// mediaManifestDue reports whether the latest manifest is older than interval
+func mediaManifestDue(db *gorm.DB, interval time.Duration) bool {
+	var latest models.MediaManifest
+	if err := db.Order("id DESC").Limit(1).Find(&latest).Error; err != nil {
+		utils.BaseLogger().Error("Failed to check latest media manifest", zap.Error(err))
+		return false
+	}
+	return latest.ID == 0 || time.Since(latest.CreatedOn) >= interval
+}
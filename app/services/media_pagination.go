@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// Media galleries are paged by keyset on (created_on, id), newest first, so a page costs the
+// same however deep the client scrolls and only the returned page gets presigned. The indexes
+// are in migrations/00001_baseline.sql (add_media_keyset_indexes).
+
+// ErrInvalidCursor is returned for cursor tokens that were not produced by a previous page
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultMediaPageSize = 20
+	maxMediaPageSize     = 100
+)
+
+// PaginationCursor represents a cursor for pagination: the last item of the previous page
+type PaginationCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// Token encodes the cursor as the opaque next_cursor clients pass back as ?cursor=
+func (c PaginationCursor) Token() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + "." + strconv.FormatUint(uint64(c.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParsePaginationCursor decodes a next_cursor token
+func ParsePaginationCursor(token string) (*PaginationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursorID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// Postgres keeps microseconds, so the cursor matches the row it was taken from exactly
+	return &PaginationCursor{CreatedAt: time.UnixMicro(createdAt).UTC(), ID: uint(cursorID)}, nil
+}
+
+// PaginatedEventMediaResult contains paginated results
+type PaginatedEventMediaResult struct {
+	Data       []models.EventMedia `json:"data"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// PaginatedBranchMediaResult is one page of branch media
+type PaginatedBranchMediaResult struct {
+	Data       []models.BranchMedia `json:"data"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+// GetEventMediaByEventIDPaginated retrieves EventMedia records with cursor-based pagination
+// Uses (created_on, id) as the cursor to avoid OFFSET pagination issues
+func GetEventMediaByEventIDPaginated(eventID uint, limit int, cursor *PaginationCursor) (*PaginatedEventMediaResult, error) {
+	return pageEventMedia(config.DB.Where("event_id = ?", eventID), limit, cursor)
+}
+
+// GetAllEventMediaPaginated pages through the media of all events
+func GetAllEventMediaPaginated(limit int, cursor *PaginationCursor, scope *BranchScope) (*PaginatedEventMediaResult, error) {
+	return pageEventMedia(scope.ApplyToEvents(config.DB, "event_id"), limit, cursor)
+}
+
+// GetBranchMediaPaginated pages through the media of a branch, or of all branches when branchID is 0.
+// Soft-deleted records are excluded unless includeDeleted is set.
+func GetBranchMediaPaginated(branchID uint, includeDeleted bool, limit int, cursor *PaginationCursor, scope *BranchScope) (*PaginatedBranchMediaResult, error) {
+	limit = mediaPageSize(limit)
+	query := scope.Apply(withDeleted(config.DB, includeDeleted).Preload("Branch"), "branch_id")
+	if branchID != 0 {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var mediaList []models.BranchMedia
+	if err := keysetPage(query, limit, cursor).Find(&mediaList).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch branch media: %w", err)
+	}
+	result := &PaginatedBranchMediaResult{Data: mediaList, HasMore: len(mediaList) > limit}
+	if result.HasMore {
+		result.Data = mediaList[:limit]
+		last := result.Data[limit-1]
+		result.NextCursor = PaginationCursor{CreatedAt: last.CreatedOn, ID: last.ID}.Token()
+	}
+	return result, nil
+}
+
+func pageEventMedia(query *gorm.DB, limit int, cursor *PaginationCursor) (*PaginatedEventMediaResult, error) {
+	limit = mediaPageSize(limit)
+	query = query.Preload("Event").Preload("MediaCoverageType")
+
+	var mediaList []models.EventMedia
+	if err := keysetPage(query, limit, cursor).Find(&mediaList).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch event media: %w", err)
+	}
+	result := &PaginatedEventMediaResult{Data: mediaList, HasMore: len(mediaList) > limit}
+	if result.HasMore {
+		result.Data = mediaList[:limit]
+		last := result.Data[limit-1]
+		result.NextCursor = PaginationCursor{CreatedAt: last.CreatedOn, ID: last.ID}.Token()
+	}
+	return result, nil
+}
+
+// keysetPage orders newest first, starts after the cursor and fetches one extra row to tell
+// whether there is another page
+func keysetPage(query *gorm.DB, limit int, cursor *PaginationCursor) *gorm.DB {
+	if cursor != nil {
+		// Row comparison keeps the order stable when several uploads share a timestamp
+		query = query.Where("(created_on, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	return query.Order("created_on DESC, id DESC").Limit(limit + 1)
+}
+
+func mediaPageSize(limit int) int {
+	if limit <= 0 {
+		return defaultMediaPageSize
+	}
+	if limit > maxMediaPageSize {
+		return maxMediaPageSize
+	}
+	return limit
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+var (
+	// ErrMediaScannerNotConfigured is returned when MEDIA_SCANNER is unset
+	ErrMediaScannerNotConfigured = errors.New("no media scanner is configured (set MEDIA_SCANNER)")
+	// ErrMediaScanRunning is returned when a backfill is already queued or running
+	ErrMediaScanRunning = errors.New("a media scan backfill is already queued or running")
+	// ErrInvalidMediaScanTarget is returned for unknown backfill targets
+	ErrInvalidMediaScanTarget = errors.New("target must be event_media, branch_media or all")
+
+	// Reasons MediaDownloadable refuses a file
+	ErrMediaScanPending = errors.New("the file is still being scanned")
+	ErrMediaQuarantined = errors.New("the file failed the scan and is quarantined")
+	ErrMediaScanFailed  = errors.New("the file could not be scanned")
+)
+
+// MediaScanResult is the verdict of a MediaScanner; Status is one of models.MediaScanStatus*
+type MediaScanResult struct {
+	Status string
+	Detail string // virus signature or moderation reason
+}
+
+// MediaScanner checks an uploaded file for malware or content that must not be published.
+// ClamAVScanner is built in; moderation services plug in through SetMediaScanner.
+type MediaScanner interface {
+	Name() string
+	Scan(ctx context.Context, body io.Reader, contentType string) (MediaScanResult, error)
+}
+
+// ClamAVScanner streams files to a clamd daemon over TCP (INSTREAM command)
+type ClamAVScanner struct {
+	Address string // host:port of clamd (default "localhost:3310")
+}
+
+func (s ClamAVScanner) Name() string { return "clamav" }
+
+// clamAVChunkSize is the size of the INSTREAM chunks sent to clamd
+const clamAVChunkSize = 64 << 10
+
+// Scan sends the file to clamd and parses its "stream: OK" / "stream: <signature> FOUND" reply
+func (s ClamAVScanner) Scan(ctx context.Context, body io.Reader, contentType string) (MediaScanResult, error) {
+	address := s.Address
+	if address == "" {
+		address = "localhost:3310"
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return MediaScanResult{}, fmt.Errorf("clamd unreachable: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return MediaScanResult{}, err
+	}
+	buf := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return MediaScanResult{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				// clamd closes the connection once StreamMaxLength is exceeded; read its reply
+				break
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return MediaScanResult{}, readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	conn.Write(size)
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return MediaScanResult{}, fmt.Errorf("no reply from clamd: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case reply == "OK":
+		return MediaScanResult{Status: models.MediaScanStatusClean}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return MediaScanResult{Status: models.MediaScanStatusInfected, Detail: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		// e.g. "INSTREAM size limit exceeded. ERROR"
+		return MediaScanResult{Status: models.MediaScanStatusError, Detail: reply}, nil
+	}
+}
+
+var (
+	mediaScanner     MediaScanner
+	mediaScannerOnce sync.Once
+)
+
+// SetMediaScanner overrides the scanner (e.g. a moderation API, or a fake in tests)
+func SetMediaScanner(scanner MediaScanner) {
+	mediaScannerOnce.Do(func() {})
+	mediaScanner = scanner
+}
+
+// getMediaScanner returns the scanner selected by MEDIA_SCANNER ("clamav", with CLAMAV_ADDRESS)
+func getMediaScanner() (MediaScanner, error) {
+	mediaScannerOnce.Do(func() {
+		if config.Media.Scanner == "clamav" {
+			mediaScanner = ClamAVScanner{Address: config.Media.ClamAVAddress}
+		}
+	})
+	if mediaScanner == nil {
+		return nil, ErrMediaScannerNotConfigured
+	}
+	return mediaScanner, nil
+}
+
+// ScanMediaObject scans a stored file. Missing objects are reported as MediaScanStatusError
+// rather than an error, so one broken record does not stop a backfill.
+func ScanMediaObject(ctx context.Context, scanner MediaScanner, s3Key string) (MediaScanResult, error) {
+	storage, err := GetStorage()
+	if err != nil {
+		return MediaScanResult{}, err
+	}
+	info, err := storage.Head(ctx, s3Key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return MediaScanResult{Status: models.MediaScanStatusError, Detail: "file missing from storage"}, nil
+	}
+	if err != nil {
+		return MediaScanResult{}, err
+	}
+	body, err := storage.Get(ctx, s3Key)
+	if err != nil {
+		return MediaScanResult{}, err
+	}
+	defer body.Close()
+	return scanner.Scan(ctx, body, info.ContentType)
+}
+
+// MediaDownloadable tells whether a media file may be served (download proxy, presigned URLs).
+// Files uploaded while a scanner is configured are only served once they scanned clean; files
+// that were never scanned (uploaded before scanning was enabled) stay available.
+func MediaDownloadable(scanStatus *string) error {
+	if scanStatus == nil {
+		return nil
+	}
+	switch *scanStatus {
+	case models.MediaScanStatusClean:
+		return nil
+	case models.MediaScanStatusPending:
+		return ErrMediaScanPending
+	case models.MediaScanStatusInfected, models.MediaScanStatusFlagged:
+		return ErrMediaQuarantined
+	}
+	return ErrMediaScanFailed
+}
+
+// UploadScanStatus is the scan status new uploads are stored with: pending while a scanner is
+// configured (QueueMediaScan then scans them), nil otherwise
+func UploadScanStatus() *string {
+	if _, err := getMediaScanner(); err != nil {
+		return nil
+	}
+	status := models.MediaScanStatusPending
+	return &status
+}
+
+// mediaUploadScanPayload is the JobTypeMediaUploadScan payload
+type mediaUploadScanPayload struct {
+	Target string `json:"target"` // event_media or branch_media
+	ID     uint   `json:"id"`
+	S3Key  string `json:"s3_key"`
+}
+
+// QueueMediaScan schedules the scan of an uploaded event or branch media file, stored with
+// UploadScanStatus. Like QueueThumbnails it never fails the upload; if the job cannot be
+// queued the file stays pending until the next backfill (POST /api/admin/media-scan/backfill).
+func QueueMediaScan(ctx context.Context, target string, id uint, s3Key string) {
+	if _, err := getMediaScanner(); err != nil {
+		return
+	}
+	payload := mediaUploadScanPayload{Target: target, ID: id, S3Key: s3Key}
+	if _, err := EnqueueJob(ctx, JobTypeMediaUploadScan, payload, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue media scan", zap.String("target", target), zap.Uint("id", id), zap.Error(err))
+	}
+}
+
+func runMediaUploadScanJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var job mediaUploadScanPayload
+	if err := run.Decode(&job); err != nil {
+		return nil, err
+	}
+	scanner, err := getMediaScanner()
+	if err != nil {
+		return nil, PermanentJobError(err)
+	}
+
+	// Skip media that was deleted or replaced since; a newer job covers the replacement
+	currentKey, err := thumbnailSourceKey(job.Target, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if currentKey == "" || currentKey != job.S3Key {
+		return models.JSONB{"skipped": "media was deleted or replaced"}, nil
+	}
+
+	result, err := ScanMediaObject(ctx, scanner, job.S3Key)
+	if err != nil {
+		// Scanner or storage outage: the file stays pending and the job is retried
+		return nil, fmt.Errorf("scanning %s %d: %w", job.Target, job.ID, err)
+	}
+	if err := applyMediaScanResult(ctx, scanner, job.Target, job.ID, job.S3Key, result); err != nil {
+		return nil, err
+	}
+	return models.JSONB{"scanner": scanner.Name(), "status": result.Status}, nil
+}
+
+// mediaQuarantinePrefix is where files that failed the scan are moved. Nothing presigns keys
+// under it; admins can still inspect the files in the bucket.
+const mediaQuarantinePrefix = "quarantine/"
+
+// applyMediaScanResult stores a scan verdict on a media record. Infected and flagged files are
+// quarantined: the object is moved under quarantine/, its thumbnails and video rendition are
+// deleted and public event media is withdrawn.
+func applyMediaScanResult(ctx context.Context, scanner MediaScanner, table string, id uint, s3Key string, result MediaScanResult) error {
+	updates := map[string]interface{}{
+		"scan_status": result.Status,
+		"scan_detail": result.Detail,
+		"scanned_on":  time.Now(),
+	}
+	quarantine := result.Status == models.MediaScanStatusInfected || result.Status == models.MediaScanStatusFlagged
+	var thumbnails []*string
+	if quarantine {
+		utils.Logger(ctx).Warn("Media scan found a problem, quarantining the file", zap.String("table", table), zap.Uint("id", id),
+			zap.String("status", result.Status), zap.String("detail", result.Detail), zap.String("scanner", scanner.Name()))
+
+		var row struct {
+			ThumbnailS3Key       *string
+			ThumbnailMediumS3Key *string
+			TranscodedS3Key      *string
+		}
+		if err := config.DB.WithContext(ctx).Table(table).Select("thumbnail_s3_key", "thumbnail_medium_s3_key", "transcoded_s3_key").
+			Where("id = ?", id).Scan(&row).Error; err != nil {
+			return err
+		}
+		thumbnails = []*string{row.ThumbnailS3Key, row.ThumbnailMediumS3Key, row.TranscodedS3Key}
+
+		if !strings.HasPrefix(s3Key, mediaQuarantinePrefix) {
+			quarantineKey, err := quarantineObject(ctx, s3Key)
+			if err != nil {
+				return fmt.Errorf("quarantining %s %d: %w", table, id, err)
+			}
+			updates["s3_key"] = quarantineKey
+		}
+		updates["thumbnail_s3_key"] = nil
+		updates["thumbnail_medium_s3_key"] = nil
+		updates["transcoded_s3_key"] = nil
+	}
+
+	// The key guards against a replacement uploaded while the file was being scanned
+	if err := config.DB.WithContext(ctx).Table(table).Where("id = ? AND s3_key = ?", id, s3Key).
+		UpdateColumns(updates).Error; err != nil {
+		return err
+	}
+	if !quarantine {
+		return nil
+	}
+	DeleteThumbnails(ctx, thumbnails...)
+	if table == MediaScanTargetEventMedia {
+		var media models.EventMedia
+		if err := config.DB.WithContext(ctx).Where("id = ?", id).First(&media).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if media.IsPublic {
+			return SetEventMediaPublic(ctx, &media, false)
+		}
+	}
+	return nil
+}
+
+// quarantineObject moves a stored file under mediaQuarantinePrefix and returns its new key
+func quarantineObject(ctx context.Context, s3Key string) (string, error) {
+	storage, err := GetStorage()
+	if err != nil {
+		return "", err
+	}
+	info, err := storage.Head(ctx, s3Key)
+	if errors.Is(err, ErrObjectNotFound) {
+		// Nothing left to move; the record still points at the quarantine key
+		return mediaQuarantinePrefix + s3Key, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	body, err := storage.Get(ctx, s3Key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	quarantineKey := mediaQuarantinePrefix + s3Key
+	if err := storage.Upload(ctx, quarantineKey, body, info.ContentType, info.Metadata); err != nil {
+		return "", err
+	}
+	if err := storage.Delete(ctx, s3Key); err != nil {
+		return "", err
+	}
+	return quarantineKey, nil
+}
+
+// Media scan backfill targets
+const (
+	MediaScanTargetEventMedia  = "event_media"
+	MediaScanTargetBranchMedia = "branch_media"
+	MediaScanTargetAll         = "all"
+)
+
+const (
+	defaultMediaScanBatchSize = 50
+	maxMediaScanBatchSize     = 500
+	defaultMediaScanThrottle  = 200 * time.Millisecond
+	mediaScanBackfillTimeout  = 12 * time.Hour
+)
+
+// MediaScanBackfillOptions configures a backlog scan
+type MediaScanBackfillOptions struct {
+	Target       string `json:"target"`        // event_media, branch_media or all (default)
+	BatchSize    int    `json:"batch_size"`    // records per batch (default 50, max 500)
+	ThrottleMS   int    `json:"throttle_ms"`   // pause after each file (default 200, -1 for none)
+	RescanErrors bool   `json:"rescan_errors"` // also retry records whose last scan failed
+}
+
+// mediaScanPayload is the JobTypeMediaScan payload; AfterIDs and Counts are the checkpoint
+type mediaScanPayload struct {
+	MediaScanBackfillOptions
+	AfterIDs map[string]uint `json:"after_ids"`
+	Counts   map[string]int  `json:"counts"`
+	Total    int64           `json:"total"`
+}
+
+func (o MediaScanBackfillOptions) tables() []string {
+	switch o.Target {
+	case MediaScanTargetEventMedia, MediaScanTargetBranchMedia:
+		return []string{o.Target}
+	}
+	return []string{MediaScanTargetEventMedia, MediaScanTargetBranchMedia}
+}
+
+// unscannedMedia selects the backlog of a media table, including uploads whose scan job was
+// lost (still pending). branch_media is read without the soft-delete scope: deleted files are
+// kept for restore, so they are scanned too.
+func (o MediaScanBackfillOptions) unscannedMedia(table string) *gorm.DB {
+	query := config.DB.Table(table).Where("s3_key IS NOT NULL AND s3_key <> ''")
+	if o.RescanErrors {
+		return query.Where("scan_status IS NULL OR scan_status IN ?", []string{models.MediaScanStatusPending, models.MediaScanStatusError})
+	}
+	return query.Where("scan_status IS NULL OR scan_status = ?", models.MediaScanStatusPending)
+}
+
+// StartMediaScanBackfill queues a scan of every media record that has not been scanned yet,
+// in id order and in batches. Only one backfill runs at a time.
+func StartMediaScanBackfill(ctx context.Context, opts MediaScanBackfillOptions, createdBy *uint) (*models.Job, error) {
+	if _, err := getMediaScanner(); err != nil {
+		return nil, err
+	}
+	switch opts.Target {
+	case "":
+		opts.Target = MediaScanTargetAll
+	case MediaScanTargetAll, MediaScanTargetEventMedia, MediaScanTargetBranchMedia:
+	default:
+		return nil, ErrInvalidMediaScanTarget
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultMediaScanBatchSize
+	} else if opts.BatchSize > maxMediaScanBatchSize {
+		opts.BatchSize = maxMediaScanBatchSize
+	}
+	if opts.ThrottleMS < 0 {
+		opts.ThrottleMS = 0
+	} else if opts.ThrottleMS == 0 {
+		opts.ThrottleMS = int(defaultMediaScanThrottle / time.Millisecond)
+	}
+
+	var running int64
+	if err := config.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", JobTypeMediaScan, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrMediaScanRunning
+	}
+
+	payload := mediaScanPayload{MediaScanBackfillOptions: opts, AfterIDs: map[string]uint{}, Counts: map[string]int{}}
+	for _, table := range opts.tables() {
+		var count int64
+		if err := opts.unscannedMedia(table).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		payload.Total += count
+	}
+	return EnqueueJob(ctx, JobTypeMediaScan, payload, JobOptions{CreatedBy: createdBy})
+}
+
+type mediaScanRow struct {
+	ID    uint
+	S3Key string
+}
+
+func runMediaScanBackfillJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload mediaScanPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload.AfterIDs == nil {
+		payload.AfterIDs = map[string]uint{}
+	}
+	if payload.Counts == nil {
+		payload.Counts = map[string]int{}
+	}
+	scanner, err := getMediaScanner()
+	if err != nil {
+		return nil, PermanentJobError(err)
+	}
+	throttle := time.Duration(payload.ThrottleMS) * time.Millisecond
+
+	for _, table := range payload.tables() {
+		for {
+			var rows []mediaScanRow
+			if err := payload.unscannedMedia(table).WithContext(ctx).
+				Select("id", "s3_key").
+				Where("id > ?", payload.AfterIDs[table]).
+				Order("id").Limit(payload.BatchSize).
+				Scan(&rows).Error; err != nil {
+				return nil, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+
+			for _, row := range rows {
+				result, err := ScanMediaObject(ctx, scanner, row.S3Key)
+				if err != nil {
+					// Scanner or storage outage: retry the job later from the checkpoint
+					return nil, fmt.Errorf("scanning %s %d: %w", table, row.ID, err)
+				}
+				if err := applyMediaScanResult(ctx, scanner, table, row.ID, row.S3Key, result); err != nil {
+					return nil, err
+				}
+				payload.Counts[result.Status]++
+				payload.AfterIDs[table] = row.ID
+
+				select {
+				case <-ctx.Done():
+					run.SaveCheckpoint(payload)
+					return nil, ctx.Err()
+				case <-time.After(throttle):
+				}
+			}
+
+			if err := run.SaveCheckpoint(payload); err != nil {
+				return nil, err
+			}
+			scanned := 0
+			for _, count := range payload.Counts {
+				scanned += count
+			}
+			percent := 99
+			if payload.Total > 0 && int64(scanned) < payload.Total {
+				percent = int(int64(scanned) * 100 / payload.Total)
+			}
+			run.SetProgress(percent, fmt.Sprintf("Scanned %d of %d files", scanned, payload.Total))
+		}
+	}
+
+	result := models.JSONB{"scanner": scanner.Name(), "total": payload.Total}
+	for status, count := range payload.Counts {
+		result[status] = count
+	}
+	return result, nil
+}
+
+// MediaScanSummary counts media records per scan status ("unscanned" for the backlog)
+type MediaScanSummary struct {
+	Scanner      string                    `json:"scanner,omitempty"`
+	Tables       map[string]map[string]int `json:"tables"`
+	LatestJob    *models.Job               `json:"latest_job,omitempty"`
+	ScannerError string                    `json:"scanner_error,omitempty"`
+}
+
+// GetMediaScanSummary reports scan progress over all media and the latest backfill job
+func GetMediaScanSummary(ctx context.Context) (*MediaScanSummary, error) {
+	summary := &MediaScanSummary{Tables: map[string]map[string]int{}}
+	if scanner, err := getMediaScanner(); err != nil {
+		summary.ScannerError = err.Error()
+	} else {
+		summary.Scanner = scanner.Name()
+	}
+
+	for _, table := range []string{MediaScanTargetEventMedia, MediaScanTargetBranchMedia} {
+		var rows []struct {
+			Status string
+			Count  int
+		}
+		if err := config.DB.WithContext(ctx).Table(table).
+			Select("COALESCE(scan_status, 'unscanned') AS status, COUNT(*) AS count").
+			Where("s3_key IS NOT NULL AND s3_key <> ''").
+			Group("COALESCE(scan_status, 'unscanned')").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		counts := map[string]int{}
+		for _, row := range rows {
+			counts[row.Status] = row.Count
+		}
+		summary.Tables[table] = counts
+	}
+
+	var job models.Job
+	if err := config.DB.WithContext(ctx).Where("type = ?", JobTypeMediaScan).Order("id DESC").Limit(1).Find(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.ID != 0 {
+		summary.LatestJob = &job
+	}
+	return summary, nil
+}
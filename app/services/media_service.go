@@ -3,13 +3,15 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
 	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // CreateEventMedia creates a new EventMedia record
@@ -17,10 +19,26 @@ func CreateEventMedia(media *models.EventMedia) error {
 	return config.DB.Create(media).Error
 }
 
-// GetAllEventMedia retrieves all EventMedia records with related Event and MediaCoverageType
-func GetAllEventMedia() ([]models.EventMedia, error) {
+// EventMediaListSchema is what GET /event-media may be sorted and filtered by (see listquery).
+// Without a sort the newest media come first.
+var EventMediaListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":                     {Column: "id", Type: listquery.Int, Sortable: true},
+	"event_id":               {Column: "event_id", Type: listquery.Int},
+	"media_coverage_type_id": {Column: "media_coverage_type_id", Type: listquery.Int},
+	"company_name":           {Column: "company_name", Type: listquery.String, Sortable: true},
+	"file_type":              {Column: "file_type", Type: listquery.String, Sortable: true},
+	"category":               {Column: "category", Type: listquery.String, Sortable: true},
+	"scan_status":            {Column: "scan_status", Type: listquery.String},
+	"is_public":              {Column: "is_public", Type: listquery.Bool},
+	"sort_order":             {Column: "sort_order", Type: listquery.Int, Sortable: true},
+	"created_on":             {Column: "created_on", Type: listquery.Time, Sortable: true},
+}, listquery.Sort{Field: "created_on", Desc: true})
+
+// GetAllEventMedia retrieves all EventMedia records of the scope's events with related Event and MediaCoverageType,
+// sorted and filtered by a query parsed with EventMediaListSchema
+func GetAllEventMedia(query *listquery.Query, scope *BranchScope) ([]models.EventMedia, error) {
 	var medias []models.EventMedia
-	if err := config.DB.
+	if err := query.Apply(scope.ApplyToEvents(config.DB, "event_id")).
 		Preload("Event").
 		Preload("MediaCoverageType").
 		Find(&medias).Error; err != nil {
@@ -44,76 +62,22 @@ func GetEventMediaByEventID(eventID uint) ([]models.EventMedia, error) {
 	return mediaList, nil
 }
 
-// PaginationCursor represents a cursor for pagination
-type PaginationCursor struct {
-	CreatedAt time.Time
-	ID        uint
-}
-
-// PaginatedEventMediaResult contains paginated results
-type PaginatedEventMediaResult struct {
-	Data       []models.EventMedia `json:"data"`
-	NextCursor *PaginationCursor   `json:"next_cursor,omitempty"`
-	HasMore    bool                `json:"has_more"`
-}
-
-// GetEventMediaByEventIDPaginated retrieves EventMedia records with cursor-based pagination
-// Uses (created_at, id) as the cursor to avoid OFFSET pagination issues
-func GetEventMediaByEventIDPaginated(eventID uint, limit int, cursor *PaginationCursor) (*PaginatedEventMediaResult, error) {
-	if limit <= 0 {
-		limit = 20 // Default limit
-	}
-	if limit > 100 {
-		limit = 100 // Max limit
-	}
-
+// SearchEventMedia searches event media by company/contact name and OCR text of uploaded clippings
+func SearchEventMedia(searchTerm string, scope *BranchScope) ([]models.EventMedia, error) {
 	var mediaList []models.EventMedia
-	query := config.DB.
-		Preload("Event").
-		Preload("MediaCoverageType").
-		Where("event_id = ?", eventID)
-
-	// Apply cursor if provided
-	if cursor != nil {
-		// Use (created_at, id) tuple for cursor-based pagination
-		// This ensures stable ordering even with duplicate timestamps
-		query = query.Where(
-			"(created_on, id) < (?, ?)",
-			cursor.CreatedAt,
-			cursor.ID,
-		)
-	}
 
-	// Order by created_at DESC, id DESC for consistent pagination
-	// Fetch one extra to check if there's more
-	err := query.
+	like := "%" + searchTerm + "%"
+	if err := scope.ApplyToEvents(config.DB, "event_id").
+		Preload("MediaCoverageType").
+		Where("to_tsvector('simple', coalesce(ocr_text, '')) @@ plainto_tsquery('simple', ?) "+
+			"OR ocr_text ILIKE ? OR company_name ILIKE ? OR original_filename ILIKE ?",
+			searchTerm, like, like, like).
 		Order("created_on DESC, id DESC").
-		Limit(limit + 1).
-		Find(&mediaList).Error
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch event media: %w", err)
-	}
-
-	hasMore := len(mediaList) > limit
-	if hasMore {
-		mediaList = mediaList[:limit] // Remove the extra item
-	}
-
-	var nextCursor *PaginationCursor
-	if hasMore && len(mediaList) > 0 {
-		lastItem := mediaList[len(mediaList)-1]
-		nextCursor = &PaginationCursor{
-			CreatedAt: lastItem.CreatedOn,
-			ID:        lastItem.ID,
-		}
+		Limit(50).
+		Find(&mediaList).Error; err != nil {
+		return nil, err
 	}
-
-	return &PaginatedEventMediaResult{
-		Data:       mediaList,
-		NextCursor: nextCursor,
-		HasMore:    hasMore,
-	}, nil
+	return mediaList, nil
 }
 
 // UpdateEventMedia updates an existing EventMedia record
@@ -184,6 +148,12 @@ func UpdateEventMedia(media *models.EventMedia) error {
 	if media.FileType != "" {
 		updates["file_type"] = media.FileType
 	}
+	if media.Category != "" {
+		updates["category"] = media.Category
+	}
+	if media.Caption != "" {
+		updates["caption"] = media.Caption
+	}
 	if media.UpdatedBy != "" {
 		updates["updated_by"] = media.UpdatedBy
 	}
@@ -192,13 +162,19 @@ func UpdateEventMedia(media *models.EventMedia) error {
 	return config.DB.Model(&existing).Updates(updates).Error
 }
 
-// DeleteEventMedia deletes an EventMedia record by ID
-func DeleteEventMedia(id uint) error {
-	result := config.DB.Delete(&models.EventMedia{}, id)
-	if result.RowsAffected == 0 {
-		return errors.New("record not found")
-	}
-	return result.Error
+// DeleteEventMedia deletes an EventMedia record by ID, leaving a tombstone.
+// Returns ErrLegalHold while the media or its event is on legal hold.
+func DeleteEventMedia(id uint, actor AuditActor) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var media models.EventMedia
+		if err := tx.First(&media, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("record not found")
+			}
+			return err
+		}
+		return deleteEventMediaRecord(tx, &media, actor)
+	})
 }
 
 // ConvertEventMediaToPresignedURLs converts EventMedia items to include presigned URLs
@@ -206,34 +182,81 @@ func DeleteEventMedia(id uint) error {
 // All media access uses short-lived pre-signed URLs for security
 // Items with empty S3Key are skipped with a warning (instead of failing the entire request)
 func ConvertEventMediaToPresignedURLs(ctx context.Context, mediaList []models.EventMedia) ([]models.EventMedia, error) {
+	return presignEventMedia(ctx, mediaList, true)
+}
+
+// ConvertEventMediaToGalleryURLs is ConvertEventMediaToPresignedURLs for gallery listings:
+// items with thumbnails only get thumbnail_url/medium_url, the original is presigned
+// only when no thumbnail exists yet
+func ConvertEventMediaToGalleryURLs(ctx context.Context, mediaList []models.EventMedia) ([]models.EventMedia, error) {
+	return presignEventMedia(ctx, mediaList, false)
+}
+
+func presignEventMedia(ctx context.Context, mediaList []models.EventMedia, includeOriginal bool) ([]models.EventMedia, error) {
+	// Presign the whole page in one batch; URLs come from the presign cache where possible
+	keys := make([]string, 0, len(mediaList)*3)
+	for _, media := range mediaList {
+		if MediaDownloadable(media.ScanStatus) != nil {
+			continue
+		}
+		keys = append(keys, thumbnailKeys(media.ThumbnailS3Key, media.ThumbnailMediumS3Key)...)
+		if includeOriginal || !hasThumbnail(media.ThumbnailS3Key) {
+			keys = append(keys, media.S3Key)
+			// Videos browsers cannot play are served as their transcoded rendition
+			if key := transcodedURLKey(media.S3Key, media.TranscodeStatus, media.TranscodedS3Key); key != media.S3Key {
+				keys = append(keys, key)
+			}
+		}
+	}
+	urls, err := PresignURLs(ctx, keys, 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	attachPublicAssetURLs(ctx, mediaList)
+
 	result := make([]models.EventMedia, 0, len(mediaList))
 	
 	for _, media := range mediaList {
 		// Skip items with empty S3Key - log warning but don't fail the entire request
 		if media.S3Key == "" {
-			log.Printf("WARNING: Skipping media item ID %d (event_id: %d) - empty S3Key. Run backfill migration to populate s3_key from file_url", media.ID, media.EventID)
+			utils.Logger(ctx).Warn("Skipping media item with empty s3_key; run the backfill migration to populate s3_key from file_url",
+				zap.Uint("media_id", media.ID), zap.Uint("event_id", media.EventID))
 			continue
 		}
 		
 		mediaCopy := media
+
+		// Files pending or failing the scan are listed (with scan_status) but not presigned
+		if MediaDownloadable(mediaCopy.ScanStatus) != nil {
+			result = append(result, mediaCopy)
+			continue
+		}
+
+		// Presigned thumbnail URLs (optional - generated in the background after upload)
+		mediaCopy.ThumbnailURL = thumbnailURL(urls, mediaCopy.ThumbnailS3Key)
+		mediaCopy.MediumURL = thumbnailURL(urls, mediaCopy.ThumbnailMediumS3Key)
+		if !includeOriginal && mediaCopy.ThumbnailURL != "" {
+			result = append(result, mediaCopy)
+			continue
+		}
 		
-		// Generate short-lived presigned URL (15 minutes for gallery listing)
-		presignedURL, err := GetPresignedURL(ctx, mediaCopy.S3Key, 15*time.Minute)
-		if err != nil {
-			// Log error but skip this item instead of failing entire request
-			log.Printf("ERROR: Failed to generate presigned URL for media ID %d (s3_key: %s): %v", mediaCopy.ID, mediaCopy.S3Key, err)
+		// Short-lived presigned URL (15 minutes for gallery listing)
+		presignedURL, ok := urls[mediaCopy.S3Key]
+		if !ok {
+			// Presign failure was logged; skip this item instead of failing entire request
 			continue
 		}
 		
 		// Defensive check: ensure URL is presigned (contains X-Amz-Signature)
 		if !strings.Contains(presignedURL, "X-Amz-Signature") && !strings.Contains(presignedURL, "Signature=") {
-			log.Printf("ERROR: Generated URL for media ID %d does not contain presigned signature: %s", mediaCopy.ID, presignedURL)
+			utils.Logger(ctx).Error("Generated URL does not contain a presigned signature", zap.Uint("media_id", mediaCopy.ID))
 			continue
 		}
 		
 		// Validate URL length (presigned URLs can be long - typically 500-1000 chars)
 		if len(presignedURL) < 100 {
-			log.Printf("ERROR: Generated URL for media ID %d appears truncated (length: %d): %s", mediaCopy.ID, len(presignedURL), presignedURL)
+			utils.Logger(ctx).Error("Generated URL appears truncated", zap.Uint("media_id", mediaCopy.ID), zap.Int("length", len(presignedURL)))
 			continue
 		}
 		
@@ -241,17 +264,10 @@ func ConvertEventMediaToPresignedURLs(ctx context.Context, mediaList []models.Ev
 		// FileURL is internal and not serialized
 		mediaCopy.FileURL = presignedURL // Internal storage
 		mediaCopy.URL = presignedURL     // JSON response field
-		
-		// Generate thumbnail presigned URL if thumbnail exists
-		if mediaCopy.ThumbnailS3Key != nil && *mediaCopy.ThumbnailS3Key != "" {
-			thumbnailURL, err := GetPresignedURL(ctx, *mediaCopy.ThumbnailS3Key, 15*time.Minute)
-			if err != nil {
-				// Log error but don't fail - thumbnail is optional
-				log.Printf("WARNING: Failed to generate presigned URL for thumbnail of media ID %d (thumbnail_s3_key: %s): %v", mediaCopy.ID, *mediaCopy.ThumbnailS3Key, err)
-			} else {
-				// Store thumbnail URL in FileURL field temporarily (frontend can use this)
-				// Note: In future, consider adding separate thumbnail_url field to response DTO
-				_ = thumbnailURL // Placeholder for future thumbnail URL handling
+		if key := transcodedURLKey(mediaCopy.S3Key, mediaCopy.TranscodeStatus, mediaCopy.TranscodedS3Key); key != mediaCopy.S3Key {
+			if transcodedURL, ok := urls[key]; ok {
+				mediaCopy.OriginalURL = presignedURL
+				mediaCopy.URL = transcodedURL
 			}
 		}
 		
@@ -260,3 +276,27 @@ func ConvertEventMediaToPresignedURLs(ctx context.Context, mediaList []models.Ev
 	
 	return result, nil
 }
+
+// thumbnailKeys returns the non-empty thumbnail keys of a media record
+func thumbnailKeys(keys ...*string) []string {
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if hasThumbnail(key) {
+			result = append(result, *key)
+		}
+	}
+	return result
+}
+
+func hasThumbnail(key *string) bool {
+	return key != nil && *key != ""
+}
+
+// thumbnailURL returns the presigned URL of a thumbnail key, or "" if there is none.
+// Thumbnails are optional, so a failed presign only drops the thumbnail.
+func thumbnailURL(urls map[string]string, key *string) string {
+	if !hasThumbnail(key) {
+		return ""
+	}
+	return urls[*key]
+}
@@ -0,0 +1,209 @@
+// Package messaging delivers the application's critical alerts (account credentials, event
+// review results) as SMS or WhatsApp messages to users who chose that channel, through
+// Twilio, MSG91 or the WhatsApp Business Cloud API. Like emails, every attempt is recorded in
+// the notification_logs table.
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// Channels
+const (
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+)
+
+// Templates (see templates/*.tmpl); they share the names of the matching email templates
+const (
+	TemplateWelcome           = "welcome"
+	TemplateTemporaryPassword = "temporary_password"
+	TemplateEventApproved     = "event_approved"
+	TemplateEventRejected     = "event_rejected"
+)
+
+// sendTimeout bounds a single delivery attempt
+const sendTimeout = 30 * time.Second
+
+var (
+	// ErrNotConfigured is returned by Send when no driver is configured for the channel: the
+	// message is logged but not delivered
+	ErrNotConfigured = errors.New("message delivery is not configured")
+	// ErrInvalidRecipient is returned for phone numbers that cannot be turned into an
+	// international number
+	ErrInvalidRecipient = errors.New("invalid phone number")
+)
+
+// Message is a rendered message
+type Message struct {
+	To       string // international number, e.g. +919876543210
+	Template string
+	// Params are the template data in the order of the template's placeholders, for providers
+	// that only send pre-approved templates (MSG91, WhatsApp Business)
+	Params []string
+	Text   string
+}
+
+// Sender delivers rendered messages
+type Sender interface {
+	// Send delivers msg and returns the provider's message ID
+	Send(ctx context.Context, msg Message) (string, error)
+	// Name identifies the provider in the notification log
+	Name() string
+}
+
+// senders are the delivery backends by channel, replaced by Init
+var senders = map[string]Sender{ChannelSMS: logSender{}, ChannelWhatsApp: logSender{}}
+
+// Init selects the delivery backends configured by config.LoadMessagingConfig
+func Init() error {
+	sms, whatsapp := Sender(logSender{}), Sender(logSender{})
+	switch config.SMSDriver {
+	case "twilio":
+		sms = newTwilioSender(config.TwilioSMSFrom, "")
+	case "msg91":
+		sms = newMSG91Sender()
+	}
+	switch config.WhatsAppDriver {
+	case "twilio":
+		whatsapp = newTwilioSender(config.TwilioWhatsAppFrom, "whatsapp:")
+	case "meta":
+		whatsapp = newMetaSender()
+	}
+	senders = map[string]Sender{ChannelSMS: sms, ChannelWhatsApp: whatsapp}
+	utils.BaseLogger().Info("Message delivery configured", zap.String("sms", sms.Name()), zap.String("whatsapp", whatsapp.Name()))
+	return nil
+}
+
+// Enabled reports whether messages of the channel are actually delivered
+func Enabled(channel string) bool {
+	sender, ok := senders[channel]
+	if !ok {
+		return false
+	}
+	_, logOnly := sender.(logSender)
+	return !logOnly
+}
+
+// Notification is one templated message to send
+type Notification struct {
+	Channel    string // sms or whatsapp
+	To         string // phone number as entered; numbers without a country code get MESSAGING_COUNTRY_CODE
+	Template   string
+	Data       map[string]interface{}
+	UserID     *uint  // the recipient's account, if any
+	EntityType string // what the notification is about (for the log)
+	EntityID   *uint
+}
+
+// Send renders and delivers a notification and records the attempt in notification_logs.
+// Without a configured driver for the channel it returns ErrNotConfigured.
+func Send(ctx context.Context, n Notification) error {
+	sender, ok := senders[n.Channel]
+	if !ok {
+		return fmt.Errorf("unknown message channel %q", n.Channel)
+	}
+	entry := &models.NotificationLog{
+		Channel:    n.Channel,
+		Template:   n.Template,
+		Recipient:  n.To,
+		Provider:   sender.Name(),
+		UserID:     n.UserID,
+		EntityType: n.EntityType,
+		EntityID:   n.EntityID,
+	}
+	err := send(ctx, sender, n, entry)
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		entry.Status = models.NotificationStatusLogged
+	case err != nil:
+		entry.Status = models.NotificationStatusFailed
+		entry.Error = err.Error()
+	default:
+		entry.Status = models.NotificationStatusSent
+	}
+
+	logger := utils.Logger(ctx).With(zap.String("channel", n.Channel), zap.String("template", n.Template),
+		zap.String("recipient", entry.Recipient), zap.String("provider", entry.Provider))
+	if err != nil && !errors.Is(err, ErrNotConfigured) {
+		logger.Warn("Failed to send message", zap.Error(err))
+	} else {
+		logger.Info("Message processed", zap.String("status", entry.Status))
+	}
+	if dbErr := config.DB.WithContext(context.WithoutCancel(ctx)).Create(entry).Error; dbErr != nil {
+		logger.Warn("Failed to record message", zap.Error(dbErr))
+	}
+	return err
+}
+
+func send(ctx context.Context, sender Sender, n Notification, entry *models.NotificationLog) error {
+	to, err := NormalizePhone(n.To)
+	if err != nil {
+		return err
+	}
+	entry.Recipient = to
+	msg, err := render(n.Template, n.Data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	if _, logOnly := sender.(logSender); logOnly {
+		return ErrNotConfigured
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	id, err := sender.Send(ctx, msg)
+	entry.ProviderMessageID = id
+	return err
+}
+
+// SendAsync sends a notification in the background so the request does not wait on the
+// provider. Failures are logged and recorded in notification_logs.
+func SendAsync(ctx context.Context, n Notification) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_ = Send(ctx, n)
+	}()
+}
+
+var phoneFormatting = regexp.MustCompile(`[\s\-().]`)
+
+// NormalizePhone turns a contact number into an international number (+<country code><number>).
+// Numbers without a country code, like the 10-digit numbers users enter, get
+// MESSAGING_COUNTRY_CODE; a leading trunk 0 is dropped.
+func NormalizePhone(number string) (string, error) {
+	cleaned := phoneFormatting.ReplaceAllString(strings.TrimSpace(number), "")
+	switch {
+	case strings.HasPrefix(cleaned, "+"):
+		cleaned = cleaned[1:]
+	case strings.HasPrefix(cleaned, "00"):
+		cleaned = cleaned[2:]
+	default:
+		cleaned = config.MessagingCountryCode + strings.TrimPrefix(cleaned, "0")
+	}
+	if len(cleaned) < 8 || len(cleaned) > 15 || strings.Trim(cleaned, "0123456789") != "" || cleaned[0] == '0' {
+		return "", fmt.Errorf("%w %q", ErrInvalidRecipient, number)
+	}
+	return "+" + cleaned, nil
+}
+
+// logSender is used when no driver is configured. Nothing is delivered and message contents
+// are never logged (they can hold passwords).
+type logSender struct{}
+
+func (logSender) Name() string { return "log" }
+
+func (logSender) Send(ctx context.Context, msg Message) (string, error) {
+	return "", ErrNotConfigured
+}
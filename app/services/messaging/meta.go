@@ -0,0 +1,82 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// metaGraphAPIBase is the Graph API of the WhatsApp Business Cloud API
+const metaGraphAPIBase = "https://graph.facebook.com/v19.0"
+
+// metaSender delivers WhatsApp messages through the WhatsApp Business Cloud API. Business
+// initiated messages must use approved templates: each message template needs one of the same
+// name in WHATSAPP_TEMPLATE_LANGUAGE, with {{1}}, {{2}}, ... for the template's data in the
+// order of templateParams.
+type metaSender struct {
+	phoneNumberID string
+	language      string
+}
+
+func newMetaSender() *metaSender {
+	return &metaSender{phoneNumberID: config.WhatsAppPhoneNumberID, language: config.WhatsAppLanguage}
+}
+
+func (s *metaSender) Name() string { return "meta" }
+
+func (s *metaSender) Send(ctx context.Context, msg Message) (string, error) {
+	parameters := make([]map[string]string, len(msg.Params))
+	for i, param := range msg.Params {
+		// Empty parameters are rejected
+		if strings.TrimSpace(param) == "" {
+			param = "-"
+		}
+		parameters[i] = map[string]string{"type": "text", "text": param}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                strings.TrimPrefix(msg.To, "+"),
+		"type":              "template",
+		"template": map[string]interface{}{
+			"name":       msg.Template,
+			"language":   map[string]string{"code": s.language},
+			"components": []map[string]interface{}{{"type": "body", "parameters": parameters}},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("%s/%s/messages", metaGraphAPIBase, s.phoneNumberID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.WhatsAppAccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whatsapp send: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+		Error struct {
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode >= 300 || len(body.Messages) == 0 {
+		return "", fmt.Errorf("whatsapp send: status %d: %s (code %d)", resp.StatusCode, body.Error.Message, body.Error.Code)
+	}
+	return body.Messages[0].ID, nil
+}
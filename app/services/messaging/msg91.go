@@ -0,0 +1,71 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// msg91FlowURL is MSG91's Flow API, which sends DLT-registered SMS templates
+const msg91FlowURL = "https://control.msg91.com/api/v5/flow/"
+
+// msg91Sender delivers SMS through MSG91. Indian operators only deliver registered (DLT)
+// templates, so each message template needs a flow in MSG91 whose text uses ##var1##,
+// ##var2##, ... for the template's data in the order of templateParams; MSG91_TEMPLATE_IDS
+// maps the templates to their flow IDs.
+type msg91Sender struct {
+	templateIDs map[string]string
+}
+
+func newMSG91Sender() *msg91Sender {
+	return &msg91Sender{templateIDs: config.MSG91TemplateIDs}
+}
+
+func (s *msg91Sender) Name() string { return "msg91" }
+
+func (s *msg91Sender) Send(ctx context.Context, msg Message) (string, error) {
+	flowID, ok := s.templateIDs[msg.Template]
+	if !ok {
+		return "", fmt.Errorf("msg91 send: no flow configured for template %s (MSG91_TEMPLATE_IDS)", msg.Template)
+	}
+	recipient := map[string]string{"mobiles": strings.TrimPrefix(msg.To, "+")}
+	for i, param := range msg.Params {
+		recipient[fmt.Sprintf("var%d", i+1)] = param
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"template_id": flowID,
+		"short_url":   "0",
+		"recipients":  []map[string]string{recipient},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg91FlowURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("authkey", config.MSG91AuthKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("msg91 send: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Type    string `json:"type"`
+		Message string `json:"message"` // the request ID on success
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode >= 300 || body.Type != "success" {
+		return "", fmt.Errorf("msg91 send: status %d: %s", resp.StatusCode, body.Message)
+	}
+	return body.Message, nil
+}
@@ -0,0 +1,65 @@
+package messaging
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var messageTemplates = loadTemplates()
+
+// templateParams lists the data of each template in the order of the placeholders ({{1}},
+// {{2}}, ... on WhatsApp, ##var1##, ##var2##, ... on MSG91) of the templates registered with
+// the providers that only send pre-approved templates
+var templateParams = map[string][]string{
+	TemplateWelcome:           {"Name", "Email", "Password", "LoginURL"},
+	TemplateTemporaryPassword: {"Name", "Password", "LoginURL"},
+	TemplateEventApproved:     {"Name", "ReportNumber", "Theme", "Comment", "Link"},
+	TemplateEventRejected:     {"Name", "ReportNumber", "Theme", "Comment", "Link"},
+}
+
+func loadTemplates() map[string]*template.Template {
+	files, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	templates := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		templates[name] = template.Must(template.New(path.Base(file)).Option("missingkey=zero").ParseFS(templateFS, file))
+	}
+	return templates
+}
+
+// render executes the named template with data
+func render(name string, data map[string]interface{}) (Message, error) {
+	t, ok := messageTemplates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown message template %q", name)
+	}
+	var text bytes.Buffer
+	if err := t.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("message template %s: %w", name, err)
+	}
+
+	params := make([]string, len(templateParams[name]))
+	for i, key := range templateParams[name] {
+		if value, ok := data[key]; ok && value != nil {
+			// Providers reject line breaks and runs of spaces in parameters
+			params[i] = strings.Join(strings.Fields(fmt.Sprint(value)), " ")
+		}
+	}
+	return Message{
+		Template: name,
+		Params:   params,
+		// Messages are one paragraph: line breaks in the data would split them
+		Text: strings.Join(strings.Fields(text.String()), " "),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// twilioAPIBase is the Twilio REST API
+const twilioAPIBase = "https://api.twilio.com/2010-04-01"
+
+// httpClient is shared by the providers; each attempt is also bounded by sendTimeout
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// twilioSender delivers SMS and WhatsApp messages through Twilio's Messages API. For
+// WhatsApp, the addresses are prefixed with "whatsapp:"; outside a conversation the text must
+// match a template approved for the sender.
+type twilioSender struct {
+	from   string
+	prefix string // "whatsapp:" for WhatsApp
+}
+
+func newTwilioSender(from, prefix string) *twilioSender {
+	return &twilioSender{from: from, prefix: prefix}
+}
+
+func (s *twilioSender) Name() string { return "twilio" }
+
+func (s *twilioSender) Send(ctx context.Context, msg Message) (string, error) {
+	form := url.Values{"To": {s.prefix + msg.To}, "Body": {msg.Text}}
+	// A messaging service SID (MG...) picks the sender number itself
+	if strings.HasPrefix(s.from, "MG") {
+		form.Set("MessagingServiceSid", s.from)
+	} else {
+		form.Set("From", s.prefix+s.from)
+	}
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", twilioAPIBase, url.PathEscape(config.TwilioAccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(config.TwilioAccountSID, config.TwilioAuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("twilio send: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		SID     string `json:"sid"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("twilio send: status %d: %s (code %d)", resp.StatusCode, body.Message, body.Code)
+	}
+	return body.SID, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// BackfillNameKeys computes transliteration keys for volunteers, special guests and
+// donors created before name keys existed. Only rows with an empty key are touched.
+func BackfillNameKeys() {
+	var volunteers []models.Volunteer
+	result := config.DB.Where("name_key IS NULL OR name_key = ''").
+		FindInBatches(&volunteers, 500, func(tx *gorm.DB, batch int) error {
+			for _, v := range volunteers {
+				if err := config.DB.Model(&models.Volunteer{}).Where("id = ?", v.ID).
+					UpdateColumn("name_key", utils.NameKey(v.VolunteerName)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill volunteer name keys", zap.Error(result.Error))
+	}
+
+	var guests []models.SpecialGuest
+	result = config.DB.Where("name_key IS NULL OR name_key = ''").
+		FindInBatches(&guests, 500, func(tx *gorm.DB, batch int) error {
+			for _, g := range guests {
+				if err := config.DB.Model(&models.SpecialGuest{}).Where("id = ?", g.ID).
+					UpdateColumn("name_key", utils.NameKey(g.FullName())).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill special guest name keys", zap.Error(result.Error))
+	}
+
+	var donations []models.Donation
+	result = config.DB.Where("donor_name <> '' AND (donor_name_key IS NULL OR donor_name_key = '')").
+		FindInBatches(&donations, 500, func(tx *gorm.DB, batch int) error {
+			for _, d := range donations {
+				if err := config.DB.Model(&models.Donation{}).Where("id = ?", d.ID).
+					UpdateColumn("donor_name_key", utils.NameKey(d.DonorName)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill donor name keys", zap.Error(result.Error))
+	}
+}
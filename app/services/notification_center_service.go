@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// The notification center lists what needs a user's attention. Notifications are created by
+// the services where things happen: approval transitions (notifyEventAwaitingReview,
+// notifyEventReviewed), finished background jobs (notifyJobFinished) and, when the user reads
+// their notifications, an expiring password (notifyPasswordExpiring).
+
+// passwordExpiryNotice is how long before their password expires users are told
+const passwordExpiryNotice = 7 * 24 * time.Hour
+
+// ErrNotificationNotFound is returned for unknown notifications or those of another user
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// jobNotificationLabels names the background jobs whose creator is told when they finish
+var jobNotificationLabels = map[string]string{
+	JobTypeBranchImport:      "Branch import",
+	JobTypeMediaImport:       "Media import",
+	JobTypeMediaExport:       "Media export",
+	JobTypeEventReport:       "Event report",
+	JobTypeMediaScan:         "Media scan",
+	JobTypeStorageReconcile:  "Storage reconciliation",
+	JobTypeStorageUsage:      "Storage usage recount",
+	JobTypeGarbageCollection: "Garbage collection",
+}
+
+// notify creates the notification for each user. Users who already have a notification with
+// its DedupKey are skipped. Failures are logged: notifications never fail the change behind them.
+func notify(ctx context.Context, userIDs []uint, notification models.Notification) {
+	if len(userIDs) == 0 {
+		return
+	}
+	rows := make([]models.Notification, len(userIDs))
+	for i, userID := range userIDs {
+		rows[i] = notification
+		rows[i].UserID = userID
+	}
+	err := config.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(&rows).Error
+	if err != nil {
+		utils.Logger(ctx).Warn("Failed to create notifications", zap.String("type", notification.Type), zap.Error(err))
+	}
+}
+
+// notifyEventAwaitingReview tells the reviewers who act on an event in status that it is
+// waiting for them; managers with a region only hear about their region's branches. Earlier
+// notifications of the event are marked read, since whoever moved it on dealt with them.
+func notifyEventAwaitingReview(ctx context.Context, event *models.EventDetails, status string, actorID uint) {
+	if err := config.DB.WithContext(ctx).Model(&models.Notification{}).
+		Where("type = ? AND entity_type = ? AND entity_id = ? AND read_on IS NULL",
+			models.NotificationEventPendingApproval, AuditEntityEvent, event.ID).
+		Update("read_on", time.Now()).Error; err != nil {
+		utils.Logger(ctx).Warn("Failed to resolve event notifications", zap.Uint("event_id", event.ID), zap.Error(err))
+	}
+
+	var branch models.Branch
+	if event.BranchID != nil {
+		if err := config.DB.WithContext(ctx).Select("id", "name", "region_id", "is_sandbox").
+			Limit(1).Find(&branch, *event.BranchID).Error; err != nil || branch.IsSandbox {
+			return
+		}
+	}
+	var reviewers []models.User
+	if err := config.DB.WithContext(ctx).Select("id", "role_id", "region_id").
+		Where("is_deleted = ? AND disabled_at IS NULL AND role_id IN ?", false, []uint{models.RoleAdmin, models.RoleManager}).
+		Find(&reviewers).Error; err != nil {
+		utils.Logger(ctx).Warn("Failed to load reviewers", zap.Uint("event_id", event.ID), zap.Error(err))
+		return
+	}
+	var userIDs []uint
+	for _, reviewer := range reviewers {
+		if reviewer.ID == actorID || !containsString(PendingApprovalStatuses(reviewer.RoleID), status) {
+			continue
+		}
+		if reviewer.RoleID == models.RoleManager && reviewer.RegionID != nil &&
+			(branch.RegionID == nil || *branch.RegionID != *reviewer.RegionID) {
+			continue
+		}
+		userIDs = append(userIDs, reviewer.ID)
+	}
+
+	body := strings.TrimSpace(event.Theme)
+	if branch.Name != "" {
+		body = strings.TrimPrefix(body+" · "+branch.Name, " · ")
+	}
+	notify(ctx, userIDs, models.Notification{
+		Type:       models.NotificationEventPendingApproval,
+		Title:      fmt.Sprintf("Event %s is awaiting your review (%s)", eventReportLabel(event), strings.ReplaceAll(status, "_", " ")),
+		Body:       body,
+		Link:       fmt.Sprintf("/events/%d", event.ID),
+		EntityType: AuditEntityEvent,
+		EntityID:   &event.ID,
+	})
+}
+
+// eventReportLabel is the report number of an event, or its ID before it has one
+func eventReportLabel(event *models.EventDetails) string {
+	if event.ReportNumber != "" {
+		return event.ReportNumber
+	}
+	return fmt.Sprintf("#%d", event.ID)
+}
+
+// notifyJobFinished tells the creator of a job of jobNotificationLabels that it succeeded or,
+// after its last attempt, failed
+func notifyJobFinished(ctx context.Context, job *models.Job, jobErr error) {
+	label, ok := jobNotificationLabels[job.Type]
+	if !ok || job.CreatedBy == nil {
+		return
+	}
+	notification := models.Notification{
+		Type:       models.NotificationJobFinished,
+		Title:      label + " completed",
+		Link:       fmt.Sprintf("/jobs/%d", job.ID),
+		EntityType: "job",
+		EntityID:   &job.ID,
+	}
+	if jobErr != nil {
+		notification.Title = label + " failed"
+		notification.Body = jobErr.Error()
+	}
+	notify(ctx, []uint{*job.CreatedBy}, notification)
+}
+
+// notifyPasswordExpiring tells a user that their password expires within passwordExpiryNotice,
+// once per password
+func notifyPasswordExpiring(ctx context.Context, userID uint) {
+	policy := auth.CurrentPasswordPolicy()
+	if policy.MaxAge <= 0 {
+		return
+	}
+	var user models.User
+	if err := config.DB.WithContext(ctx).Select("id", "password_changed_at").Limit(1).Find(&user, userID).Error; err != nil ||
+		user.PasswordChangedAt == nil {
+		return
+	}
+	expires := user.PasswordChangedAt.Add(policy.MaxAge)
+	if time.Until(expires) > passwordExpiryNotice {
+		return
+	}
+	key := models.NotificationPasswordExpiring + ":" + strconv.FormatInt(user.PasswordChangedAt.Unix(), 10)
+	notify(ctx, []uint{userID}, models.Notification{
+		Type:     models.NotificationPasswordExpiring,
+		Title:    "Your password expires on " + expires.Format("2 Jan 2006"),
+		Body:     "Change it before then to keep access to your account.",
+		DedupKey: &key,
+	})
+}
+
+// NotificationFilter holds the supported filters for listing a user's notifications
+type NotificationFilter struct {
+	UserID uint
+	Unread bool
+	Limit  int
+	Offset int
+}
+
+// GetNotifications lists a user's notifications, newest first, with the total match count
+func GetNotifications(ctx context.Context, filter NotificationFilter) ([]models.Notification, int64, error) {
+	notifyPasswordExpiring(ctx, filter.UserID)
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", filter.UserID)
+	if filter.Unread {
+		query = query.Where("read_on IS NULL")
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	notifications := []models.Notification{}
+	if err := query.Order("created_on DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// CountUnreadNotifications returns how many notifications of a user are unread
+func CountUnreadNotifications(ctx context.Context, userID uint) (int64, error) {
+	notifyPasswordExpiring(ctx, userID)
+	var unread int64
+	err := config.DB.WithContext(ctx).Model(&models.Notification{}).
+		Where("user_id = ? AND read_on IS NULL", userID).
+		Count(&unread).Error
+	return unread, err
+}
+
+// MarkNotificationRead marks a notification of a user read. Marking it again keeps the first time.
+func MarkNotificationRead(ctx context.Context, userID, id uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := config.DB.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&notification, id).Error; err != nil {
+		return nil, err
+	}
+	if notification.ID == 0 {
+		return nil, ErrNotificationNotFound
+	}
+	if notification.ReadOn == nil {
+		now := time.Now()
+		if err := config.DB.WithContext(ctx).Model(&notification).Update("read_on", now).Error; err != nil {
+			return nil, err
+		}
+		notification.ReadOn = &now
+	}
+	return &notification, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user read and returns how many
+// there were
+func MarkAllNotificationsRead(ctx context.Context, userID uint) (int64, error) {
+	result := config.DB.WithContext(ctx).Model(&models.Notification{}).
+		Where("user_id = ? AND read_on IS NULL", userID).
+		Update("read_on", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/mail"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/messaging"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// SendWelcomeEmail emails a newly created user their login email and temporary password, and
+// sends them as a message too when the user's alerts go by SMS or WhatsApp. It returns an error
+// (mail.ErrNotConfigured when email delivery is not set up) unless one of them was delivered.
+func SendWelcomeEmail(ctx context.Context, user *models.User, temporaryPassword string) error {
+	return sendCredentials(ctx, user, mail.TemplateWelcome, messaging.TemplateWelcome, map[string]interface{}{
+		"Name":     user.Name,
+		"Email":    user.Email,
+		"Password": temporaryPassword,
+		"LoginURL": mail.Link("/login"),
+	})
+}
+
+// SendTemporaryPasswordEmail emails a user the temporary password set by an administrator, and
+// sends it as a message too when the user's alerts go by SMS or WhatsApp. It returns an error
+// (mail.ErrNotConfigured when email delivery is not set up) unless one of them was delivered.
+func SendTemporaryPasswordEmail(ctx context.Context, userID uint, temporaryPassword string) error {
+	var user models.User
+	if err := config.DB.Select("id", "name", "email", "contact_number", "notification_channel").First(&user, userID).Error; err != nil {
+		return err
+	}
+	return sendCredentials(ctx, &user, mail.TemplateTemporaryPassword, messaging.TemplateTemporaryPassword, map[string]interface{}{
+		"Name":     user.Name,
+		"Password": temporaryPassword,
+		"LoginURL": mail.Link("/login"),
+	})
+}
+
+// sendCredentials delivers a password right away: job payloads are stored in the database, so
+// credentials never go through the job queue
+func sendCredentials(ctx context.Context, user *models.User, emailTemplate, messageTemplate string, data map[string]interface{}) error {
+	err := mail.Send(ctx, mail.Notification{
+		To:       user.Email,
+		Template: emailTemplate,
+		Data:     data,
+		UserID:   &user.ID,
+	})
+	if channel := messageChannel(user); channel != "" {
+		if msgErr := messaging.Send(ctx, messaging.Notification{
+			Channel:  channel,
+			To:       user.ContactNumber,
+			Template: messageTemplate,
+			Data:     data,
+			UserID:   &user.ID,
+		}); msgErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ErrCredentialsUndeliverable is returned when a user could not receive a password: email is
+// not configured and their alerts do not go by a configured SMS or WhatsApp channel
+var ErrCredentialsUndeliverable = errors.New("login details cannot be delivered: email is not configured and the user has no configured SMS or WhatsApp channel")
+
+// CanDeliverCredentials reports whether a password can reach the user by email or by the
+// channel of their alerts
+func CanDeliverCredentials(user *models.User) bool {
+	if mail.Enabled() {
+		return true
+	}
+	channel := messageChannel(user)
+	return channel != "" && messaging.Enabled(channel)
+}
+
+// credentialsJob is the payload of credentials jobs. It holds no password, see QueueCredentials.
+type credentialsJob struct {
+	UserID   uint   `json:"user_id"`
+	Template string `json:"template"` // mail.TemplateWelcome or mail.TemplateTemporaryPassword
+}
+
+// QueueCredentials retries delivering a user's login details through the job queue after
+// SendWelcomeEmail or SendTemporaryPasswordEmail failed. Job payloads are stored in the
+// database, so the job does not carry the password: each attempt sets a new temporary password
+// and sends that one, and the password that never arrived stops working.
+func QueueCredentials(ctx context.Context, userID uint, template string) error {
+	_, err := EnqueueJob(ctx, JobTypeCredentials, credentialsJob{UserID: userID, Template: template}, JobOptions{})
+	return err
+}
+
+func runCredentialsJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload credentialsJob
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := config.DB.Select("id", "name", "email", "contact_number", "notification_channel").
+		Where("is_deleted = ?", false).Limit(1).Find(&user, payload.UserID).Error; err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, PermanentJobError(ErrUserNotFound)
+	}
+	if !CanDeliverCredentials(&user) {
+		return nil, PermanentJobError(ErrCredentialsUndeliverable)
+	}
+
+	password, err := ResetPassword(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Template == mail.TemplateWelcome {
+		err = SendWelcomeEmail(ctx, &user, password)
+	} else {
+		err = SendTemporaryPasswordEmail(ctx, user.ID, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.JSONB{"status": models.NotificationStatusSent, "user_id": user.ID}, nil
+}
+
+// messageChannel is the channel of a user's critical alerts besides email: sms, whatsapp, or ""
+// when they only get emails (or have no contact number)
+func messageChannel(user *models.User) string {
+	switch user.NotificationChannel {
+	case models.NotificationChannelSMS, models.NotificationChannelWhatsApp:
+		if user.ContactNumber != "" {
+			return user.NotificationChannel
+		}
+	}
+	return ""
+}
+
+// notifyEventReviewed tells the user who last submitted the event (or the coordinator they
+// handed the branch over to) that it was approved or rejected, by email, in the notification
+// center and, when their alerts go by SMS or WhatsApp, as a message. Delivery happens in the
+// background; events without a known submitter are skipped.
+func notifyEventReviewed(ctx context.Context, event *models.EventDetails, status, comment string) {
+	template := mail.TemplateEventApproved
+	if status == models.EventStatusRejected {
+		template = mail.TemplateEventRejected
+	}
+
+	var submission models.EventStatusHistory
+	err := config.DB.Where("event_id = ? AND to_status = ?", event.ID, models.EventStatusSubmitted).
+		Order("created_on DESC, id DESC").
+		Limit(1).Find(&submission).Error
+	if err != nil || submission.ID == 0 || submission.ChangedBy == 0 {
+		if err != nil {
+			utils.Logger(ctx).Warn("Failed to look up event submitter", zap.Uint("event_id", event.ID), zap.Error(err))
+		}
+		return
+	}
+	// A submitter who handed the branch over since is replaced by their successor
+	recipient := coordinatorSuccessor(submission.ChangedBy, event.BranchID, submission.CreatedOn)
+	var user models.User
+	if err := config.DB.Select("id", "name", "email", "contact_number", "notification_channel").Where("is_deleted = ?", false).Limit(1).Find(&user, recipient).Error; err != nil || user.ID == 0 {
+		return
+	}
+
+	reportNumber := eventReportLabel(event)
+	notify(ctx, []uint{user.ID}, models.Notification{
+		Type:       models.NotificationEventReviewed,
+		Title:      fmt.Sprintf("Event %s was %s", reportNumber, status),
+		Body:       comment,
+		Link:       fmt.Sprintf("/events/%d", event.ID),
+		EntityType: AuditEntityEvent,
+		EntityID:   &event.ID,
+	})
+	data := map[string]interface{}{
+		"Name":         user.Name,
+		"ReportNumber": reportNumber,
+		"Theme":        strings.TrimSpace(event.Theme),
+		"Comment":      comment,
+		"Link":         mail.Link(fmt.Sprintf("/events/%d", event.ID)),
+	}
+	QueueEmail(ctx, mail.Notification{
+		To:         user.Email,
+		Template:   template,
+		Data:       data,
+		UserID:     &user.ID,
+		EntityType: AuditEntityEvent,
+		EntityID:   &event.ID,
+	})
+	if channel := messageChannel(&user); channel != "" {
+		// The message templates share the names of the email templates
+		QueueMessage(ctx, messaging.Notification{
+			Channel:    channel,
+			To:         user.ContactNumber,
+			Template:   template,
+			Data:       data,
+			UserID:     &user.ID,
+			EntityType: AuditEntityEvent,
+			EntityID:   &event.ID,
+		})
+	}
+}
+
+// QueueEmail sends a notification through the job queue, so it is retried when the mail
+// server is down. Job payloads are stored in the database: notifications carrying passwords
+// or tokens must use mail.Send or mail.SendAsync instead.
+func QueueEmail(ctx context.Context, n mail.Notification) {
+	if _, err := EnqueueJob(ctx, JobTypeEmail, n, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue email, sending directly", zap.String("template", n.Template), zap.Error(err))
+		mail.SendAsync(ctx, n)
+	}
+}
+
+func runEmailJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var n mail.Notification
+	if err := run.Decode(&n); err != nil {
+		return nil, err
+	}
+	err := mail.Send(ctx, n)
+	if errors.Is(err, mail.ErrNotConfigured) {
+		return models.JSONB{"status": models.NotificationStatusLogged}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.JSONB{"status": models.NotificationStatusSent}, nil
+}
+
+// QueueMessage sends an SMS or WhatsApp message through the job queue, so it is retried when
+// the provider is down. Like QueueEmail, it must not be used for messages carrying passwords.
+func QueueMessage(ctx context.Context, n messaging.Notification) {
+	if _, err := EnqueueJob(ctx, JobTypeMessage, n, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue message, sending directly", zap.String("template", n.Template), zap.Error(err))
+		messaging.SendAsync(ctx, n)
+	}
+}
+
+func runMessageJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var n messaging.Notification
+	if err := run.Decode(&n); err != nil {
+		return nil, err
+	}
+	err := messaging.Send(ctx, n)
+	switch {
+	case errors.Is(err, messaging.ErrNotConfigured):
+		return models.JSONB{"status": models.NotificationStatusLogged}, nil
+	case errors.Is(err, messaging.ErrInvalidRecipient):
+		return nil, PermanentJobError(err)
+	case err != nil:
+		return nil, err
+	}
+	return models.JSONB{"status": models.NotificationStatusSent}, nil
+}
+
+// NotificationLogFilter holds the supported filters for listing notification logs
+type NotificationLogFilter struct {
+	Channel   string
+	Recipient string
+	Template  string
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+// GetNotificationLogs lists outgoing notifications, newest first, with the total match count
+func GetNotificationLogs(filter NotificationLogFilter) ([]models.NotificationLog, int64, error) {
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.Model(&models.NotificationLog{})
+	if filter.Channel != "" {
+		query = query.Where("channel = ?", filter.Channel)
+	}
+	if filter.Recipient != "" {
+		query = query.Where("LOWER(recipient) = ?", strings.ToLower(strings.TrimSpace(filter.Recipient)))
+	}
+	if filter.Template != "" {
+		query = query.Where("template = ?", filter.Template)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	logs := []models.NotificationLog{}
+	if err := query.Order("created_on DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// OCRExtractor extracts plain text from an uploaded document (receipt, press clipping)
+type OCRExtractor interface {
+	ExtractText(ctx context.Context, data []byte, contentType string) (string, error)
+}
+
+// TesseractExtractor runs the tesseract CLI against the file contents
+type TesseractExtractor struct {
+	Binary    string // path to tesseract binary (default: "tesseract")
+	Languages string // tesseract language list, e.g. "eng+hin"
+}
+
+// ExtractText pipes the file into tesseract and returns stdout
+func (t TesseractExtractor) ExtractText(ctx context.Context, data []byte, contentType string) (string, error) {
+	binary := t.Binary
+	if binary == "" {
+		binary = "tesseract"
+	}
+
+	args := []string{"stdin", "stdout"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// OCR job targets
+const (
+	OCRTargetDonation   = "donation"
+	OCRTargetEventMedia = "event_media"
+)
+
+// maxOCRSourceBytes caps how much of the original is read back from storage
+const maxOCRSourceBytes = 50 << 20
+
+// ocrTimeout bounds one tesseract run
+const ocrTimeout = 2 * time.Minute
+
+// ocrPayload is the JobTypeOCR payload. It holds the S3 key rather than the file: job payloads
+// are stored in the database, and the worker reads the original back from storage.
+type ocrPayload struct {
+	Target      string `json:"target"`
+	ID          uint   `json:"id"`
+	S3Key       string `json:"s3_key"`
+	ContentType string `json:"content_type"`
+}
+
+var (
+	ocrExtractor OCRExtractor
+	ocrOnce      sync.Once
+)
+
+// SetOCRExtractor overrides the extractor used by the OCR jobs (e.g. a Textract-backed implementation)
+func SetOCRExtractor(extractor OCRExtractor) {
+	ocrExtractor = extractor
+}
+
+// getOCRExtractor returns the configured extractor, nil when OCR is disabled.
+// OCR is enabled with OCR_PROVIDER=tesseract.
+func getOCRExtractor() OCRExtractor {
+	ocrOnce.Do(func() {
+		if ocrExtractor == nil && config.Media.OCRProvider == "tesseract" {
+			ocrExtractor = TesseractExtractor{
+				Binary:    config.Media.TesseractPath,
+				Languages: config.Media.OCRLanguages,
+			}
+		}
+	})
+	return ocrExtractor
+}
+
+// IsOCRSupported reports whether the content type can be sent through OCR.
+// Only raster images are supported (tesseract does not read PDF or SVG).
+func IsOCRSupported(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "image/") && contentType != "image/svg+xml"
+}
+
+// QueueOCR schedules text extraction for a donation receipt or event media clipping stored
+// under s3Key. Like QueueThumbnails it never fails the upload request: if OCR is disabled or
+// the job cannot be queued the text is not extracted. The job workers run it, so it survives
+// restarts and is retried when the extraction fails.
+func QueueOCR(ctx context.Context, target string, id uint, s3Key, contentType string) {
+	if !IsOCRSupported(contentType) || getOCRExtractor() == nil {
+		return
+	}
+
+	payload := ocrPayload{Target: target, ID: id, S3Key: s3Key, ContentType: contentType}
+	if _, err := EnqueueJob(ctx, JobTypeOCR, payload, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue OCR", zap.String("target", target), zap.Uint("id", id), zap.Error(err))
+	}
+}
+
+func runOCRJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var job ocrPayload
+	if err := run.Decode(&job); err != nil {
+		return nil, err
+	}
+	extractor := getOCRExtractor()
+	if extractor == nil {
+		return models.JSONB{"skipped": "OCR is disabled"}, nil
+	}
+
+	// Skip files that were deleted or replaced since; a newer job covers the replacement
+	currentKey, err := ocrSourceKey(job.Target, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if currentKey == "" || currentKey != job.S3Key {
+		return models.JSONB{"skipped": "file was deleted or replaced"}, nil
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Get(ctx, job.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxOCRSourceBytes))
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+
+	ocrCtx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+	text, err := extractor.ExtractText(ocrCtx, data, job.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("OCR failed: %w", err)
+	}
+
+	if err := SaveOCRText(job.Target, job.ID, text); err != nil {
+		return nil, fmt.Errorf("failed to store OCR text: %w", err)
+	}
+	return models.JSONB{"characters": len(text)}, nil
+}
+
+// ocrSourceKey returns the current S3 key of an OCR target, "" if it no longer exists
+func ocrSourceKey(target string, id uint) (string, error) {
+	var keys []string
+	var err error
+	switch target {
+	case OCRTargetDonation:
+		err = config.DB.Model(&models.Donation{}).Where("id = ?", id).Pluck("receipt_s3_key", &keys).Error
+	case OCRTargetEventMedia:
+		err = config.DB.Model(&models.EventMedia{}).Where("id = ?", id).Pluck("s3_key", &keys).Error
+	default:
+		return "", PermanentJobError(errors.New("unknown OCR target: " + target))
+	}
+	if err != nil || len(keys) == 0 {
+		return "", err
+	}
+	return keys[0], nil
+}
+
+// SaveOCRText stores extracted text on the target record
+func SaveOCRText(target string, id uint, text string) error {
+	var model interface{}
+	switch target {
+	case OCRTargetDonation:
+		model = &models.Donation{}
+	case OCRTargetEventMedia:
+		model = &models.EventMedia{}
+	default:
+		return errors.New("unknown OCR target: " + target)
+	}
+
+	return config.DB.Model(model).Where("id = ?", id).Update("ocr_text", text).Error
+}
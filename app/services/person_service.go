@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPersonNotFound is returned for unknown person IDs
+	ErrPersonNotFound = errors.New("person not found")
+	// ErrInvalidPersonMerge is returned when the merge sources are empty, include the target or were already merged
+	ErrInvalidPersonMerge = errors.New("invalid merge: sources must be other, unmerged persons")
+)
+
+// personTables are the tables whose rows reference a person through person_id
+var personTables = []string{"users", "branch_member", "volunteers", "donations"}
+
+// PersonUserAccount is a login account of a person (without credentials)
+type PersonUserAccount struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	RoleID   uint   `json:"role_id"`
+	RoleName string `json:"role_name"`
+}
+
+// PersonProfile is a person together with every record that refers to them
+type PersonProfile struct {
+	models.Person
+	Roles        []string              `json:"roles"` // user, member, volunteer, donor
+	Users        []PersonUserAccount   `json:"users"`
+	Memberships  []models.BranchMember `json:"memberships"`
+	Volunteering []models.Volunteer    `json:"volunteering"`
+	Donations    []models.Donation     `json:"donations"`
+}
+
+// PersonDuplicateGroup is a set of unmerged persons sharing a name key (same name across scripts/spellings)
+type PersonDuplicateGroup struct {
+	NameKey string          `json:"name_key"`
+	Persons []models.Person `json:"persons"`
+}
+
+// BackfillPersons links users, branch members, volunteers and donations created before the
+// persons table existed. Only rows without a person are touched.
+func BackfillPersons() {
+	var users []models.User
+	result := config.DB.Where("person_id IS NULL AND is_deleted = ?", false).
+		FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+			for _, u := range users {
+				if err := backfillPersonID(&models.User{}, u.ID, models.Person{Name: u.Name, Email: u.Email, Contact: u.ContactNumber}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill user persons", zap.Error(result.Error))
+	}
+
+	var members []models.BranchMember
+	result = config.DB.Where("person_id IS NULL").
+		FindInBatches(&members, 500, func(tx *gorm.DB, batch int) error {
+			for _, m := range members {
+				if err := backfillPersonID(&models.BranchMember{}, m.ID, models.Person{Name: m.Name, DateOfBirth: m.DateOfBirth}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill member persons", zap.Error(result.Error))
+	}
+
+	var volunteers []models.Volunteer
+	result = config.DB.Where("person_id IS NULL").
+		FindInBatches(&volunteers, 500, func(tx *gorm.DB, batch int) error {
+			for _, v := range volunteers {
+				if err := backfillPersonID(&models.Volunteer{}, v.ID, models.Person{Name: v.VolunteerName, Contact: v.Contact}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill volunteer persons", zap.Error(result.Error))
+	}
+
+	var donations []models.Donation
+	result = config.DB.Where("person_id IS NULL AND donor_name <> ''").
+		FindInBatches(&donations, 500, func(tx *gorm.DB, batch int) error {
+			for _, d := range donations {
+				if err := backfillPersonID(&models.Donation{}, d.ID, models.Person{Name: d.DonorName}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		utils.BaseLogger().Warn("Failed to backfill donor persons", zap.Error(result.Error))
+	}
+}
+
+func backfillPersonID(model interface{}, id uint, candidate models.Person) error {
+	if strings.TrimSpace(candidate.Name) == "" {
+		return nil
+	}
+	personID, err := models.LinkPerson(config.DB, candidate)
+	if err != nil {
+		return err
+	}
+	return config.DB.Model(model).Where("id = ?", id).UpdateColumn("person_id", personID).Error
+}
+
+// SearchPersons finds unmerged persons by name (across scripts/spellings), email or contact
+// number. A scoped user only finds persons with a user account, membership, volunteer record
+// or donation in one of their branches.
+func SearchPersons(search string, limit, offset int, scope *BranchScope) ([]models.Person, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := config.DB.Where("merged_into_id IS NULL")
+	if scope != nil {
+		query = query.Where("id IN (?) OR id IN (?) OR id IN (?) OR id IN (?)",
+			scope.Apply(config.DB.Table("users").Select("person_id"), "branch_id"),
+			scope.Apply(config.DB.Model(&models.BranchMember{}).Select("person_id"), "branch_id"),
+			scope.Apply(config.DB.Model(&models.Volunteer{}).Select("person_id"), "branch_id"),
+			scope.Apply(config.DB.Model(&models.Donation{}).Select("person_id"), "branch_id"))
+	}
+	if search = strings.TrimSpace(search); search != "" {
+		like := "%" + search + "%"
+		conditions := config.DB.Where("name ILIKE ? OR email ILIKE ?", like, like)
+		if key := utils.NameKey(search); key != "" {
+			conditions = conditions.Or("name_key = ?", key)
+		}
+		if contact := models.NormalizeContact(search); len(contact) >= 6 {
+			conditions = conditions.Or("contact LIKE ?", "%"+contact+"%")
+		}
+		query = query.Where(conditions)
+	}
+
+	persons := []models.Person{}
+	if err := query.Order("name ASC, id ASC").Limit(limit).Offset(offset).Find(&persons).Error; err != nil {
+		return nil, err
+	}
+	return persons, nil
+}
+
+// GetPersonProfile returns a person with all their user accounts, memberships, volunteer
+// records and donations. The ID of a merged person resolves to the person it was merged into.
+// A scoped user only gets the records of their branches, and ErrOutOfBranchScope for a person
+// with none.
+func GetPersonProfile(id uint, scope *BranchScope) (*PersonProfile, error) {
+	var person models.Person
+	for hops := 0; ; hops++ {
+		if err := config.DB.First(&person, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrPersonNotFound
+			}
+			return nil, err
+		}
+		if person.MergedIntoID == nil || hops >= 10 {
+			break
+		}
+		id = *person.MergedIntoID
+	}
+
+	profile := &PersonProfile{
+		Person:       person,
+		Roles:        []string{},
+		Users:        []PersonUserAccount{},
+		Memberships:  []models.BranchMember{},
+		Volunteering: []models.Volunteer{},
+		Donations:    []models.Donation{},
+	}
+	err := scope.Apply(config.DB.Table("users").
+		Select("users.id, users.email, users.role_id, roles.name AS role_name").
+		Joins("LEFT JOIN roles ON roles.id = users.role_id").
+		Where("users.person_id = ? AND users.is_deleted = ?", person.ID, false), "users.branch_id").
+		Order("users.id").Scan(&profile.Users).Error
+	if err != nil {
+		return nil, err
+	}
+	records := scope.Apply(config.DB.Where("person_id = ?", person.ID), "branch_id").Order("id")
+	if err := records.Session(&gorm.Session{}).Find(&profile.Memberships).Error; err != nil {
+		return nil, err
+	}
+	if err := records.Session(&gorm.Session{}).Find(&profile.Volunteering).Error; err != nil {
+		return nil, err
+	}
+	if err := records.Session(&gorm.Session{}).Find(&profile.Donations).Error; err != nil {
+		return nil, err
+	}
+	if scope != nil && len(profile.Users)+len(profile.Memberships)+len(profile.Volunteering)+len(profile.Donations) == 0 {
+		return nil, ErrOutOfBranchScope
+	}
+
+	if len(profile.Users) > 0 {
+		profile.Roles = append(profile.Roles, "user")
+	}
+	if len(profile.Memberships) > 0 {
+		profile.Roles = append(profile.Roles, "member")
+	}
+	if len(profile.Volunteering) > 0 {
+		profile.Roles = append(profile.Roles, "volunteer")
+	}
+	if len(profile.Donations) > 0 {
+		profile.Roles = append(profile.Roles, "donor")
+	}
+	return profile, nil
+}
+
+// FindPersonDuplicates returns groups of unmerged persons with the same name key, the
+// candidates for a merge. Groups with the most persons come first.
+func FindPersonDuplicates(limit int) ([]PersonDuplicateGroup, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var keys []string
+	err := config.DB.Model(&models.Person{}).
+		Where("merged_into_id IS NULL AND name_key <> ''").
+		Group("name_key").
+		Having("COUNT(*) > 1").
+		Order("COUNT(*) DESC, name_key").
+		Limit(limit).
+		Pluck("name_key", &keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]PersonDuplicateGroup, 0, len(keys))
+	if len(keys) == 0 {
+		return groups, nil
+	}
+	var persons []models.Person
+	if err := config.DB.Where("merged_into_id IS NULL AND name_key IN ?", keys).Order("id").Find(&persons).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string][]models.Person, len(keys))
+	for _, p := range persons {
+		byKey[p.NameKey] = append(byKey[p.NameKey], p)
+	}
+	for _, key := range keys {
+		groups = append(groups, PersonDuplicateGroup{NameKey: key, Persons: byKey[key]})
+	}
+	return groups, nil
+}
+
+// MergePersons merges duplicate persons into targetID: every user, member, volunteer and
+// donation of the sources is re-pointed to the target, details missing on the target are
+// copied over and the sources are marked as merged. The merge is audit logged.
+func MergePersons(targetID uint, sourceIDs []uint, actor AuditActor) (*PersonProfile, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return mergePersons(tx, targetID, sourceIDs, actor, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPersonProfile(targetID, nil)
+}
+
+// mergePersons is MergePersons within tx
+func mergePersons(tx *gorm.DB, targetID uint, sourceIDs []uint, actor AuditActor, now time.Time) error {
+	var target models.Person
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPersonNotFound
+		}
+		return err
+	}
+	if target.MergedIntoID != nil {
+		return ErrInvalidPersonMerge
+	}
+
+	seen := map[uint]bool{}
+	ids := make([]uint, 0, len(sourceIDs))
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return ErrInvalidPersonMerge
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ErrInvalidPersonMerge
+	}
+	var sources []models.Person
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&sources).Error; err != nil {
+		return err
+	}
+	if len(sources) != len(ids) {
+		return ErrPersonNotFound
+	}
+	for _, s := range sources {
+		if s.MergedIntoID != nil {
+			return ErrInvalidPersonMerge
+		}
+	}
+
+	before := loadAuditSnapshot(tx, AuditEntityPerson, target.ID)
+	updates := map[string]interface{}{}
+	for _, s := range sources {
+		if target.Email == "" && s.Email != "" {
+			target.Email, updates["email"] = s.Email, s.Email
+		}
+		if target.Contact == "" && s.Contact != "" {
+			target.Contact, updates["contact"] = s.Contact, s.Contact
+		}
+		if target.DateOfBirth == nil && s.DateOfBirth != nil {
+			target.DateOfBirth, updates["date_of_birth"] = s.DateOfBirth, s.DateOfBirth
+		}
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Person{}).Where("id = ?", target.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	// Re-point the records (soft-deleted ones included) and earlier merges of the sources
+	for _, table := range personTables {
+		if err := tx.Table(table).Where("person_id IN ?", ids).UpdateColumn("person_id", target.ID).Error; err != nil {
+			return fmt.Errorf("failed to re-point %s: %w", table, err)
+		}
+	}
+	if err := tx.Model(&models.Person{}).Where("merged_into_id IN ?", ids).UpdateColumn("merged_into_id", target.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Person{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"merged_into_id": target.ID, "updated_on": now}).Error; err != nil {
+		return err
+	}
+
+	changes := DiffAuditSnapshots(before, loadAuditSnapshot(tx, AuditEntityPerson, target.ID))
+	changes["merged_person_ids"] = map[string]interface{}{"new": ids}
+	entries := []*models.AuditLog{actor.auditEntry(AuditEntityPerson, target.ID, models.AuditActionUpdate, changes, now)}
+	for _, id := range ids {
+		entries = append(entries, actor.auditEntry(AuditEntityPerson, id, models.AuditActionUpdate,
+			models.JSONB{"merged_into_id": map[string]interface{}{"old": nil, "new": target.ID}}, now))
+	}
+	if err := tx.Create(entries).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
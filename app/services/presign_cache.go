@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// presignCacheMargin is how much validity a cached URL must still have when it is handed out.
+// Entries are cached for (expiration - margin), so clients never get a URL about to expire.
+const presignCacheMargin = 2 * time.Minute
+
+// maxPresignCacheEntries bounds the in-memory cache (~1KB per URL)
+const maxPresignCacheEntries = 50000
+
+// presignConcurrency limits parallel presign calls for one batch
+const presignConcurrency = 8
+
+type presignCacheEntry struct {
+	url       string
+	expiresAt time.Time
+}
+
+var presignCache = struct {
+	sync.RWMutex
+	entries map[string]presignCacheEntry
+}{entries: map[string]presignCacheEntry{}}
+
+// PresignURLs returns presigned GET URLs for a batch of S3 keys, keyed by S3 key.
+// URLs are served from a cache (in memory, shared through Redis when configured) and only
+// missing keys are presigned, in parallel. Keys that fail to presign are logged and left out.
+func PresignURLs(ctx context.Context, keys []string, expiration time.Duration) (map[string]string, error) {
+	urls := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return urls, nil
+	}
+
+	missing := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if url, ok := cachedPresignedURL(key, expiration); ok {
+			urls[key] = url
+		} else {
+			missing = append(missing, key)
+		}
+	}
+
+	missing = loadPresignedURLsFromRedis(ctx, missing, expiration, urls)
+	if len(missing) == 0 {
+		return urls, nil
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		fresh   = make(map[string]string, len(missing))
+		workers = make(chan struct{}, presignConcurrency)
+	)
+	for _, key := range missing {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			url, err := storage.Presign(ctx, key, expiration)
+			if err != nil {
+				utils.Logger(ctx).Error("Failed to generate presigned URL", zap.String("s3_key", key), zap.Error(err))
+				return
+			}
+			mu.Lock()
+			fresh[key] = url
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	for key, url := range fresh {
+		urls[key] = url
+		storePresignedURL(key, url, expiration)
+	}
+	storePresignedURLsInRedis(ctx, fresh, expiration)
+
+	return urls, nil
+}
+
+// GetCachedPresignedURL is PresignURLs for a single key
+func GetCachedPresignedURL(ctx context.Context, s3Key string, expiration time.Duration) (string, error) {
+	if s3Key == "" {
+		return "", fmt.Errorf("S3 key cannot be empty")
+	}
+	urls, err := PresignURLs(ctx, []string{s3Key}, expiration)
+	if err != nil {
+		return "", err
+	}
+	url, ok := urls[s3Key]
+	if !ok {
+		return "", fmt.Errorf("failed to generate presigned URL for key %s", s3Key)
+	}
+	return url, nil
+}
+
+// InvalidatePresignedURL drops cached URLs of an object (call after deleting or replacing it)
+func InvalidatePresignedURL(ctx context.Context, s3Key string) {
+	presignCache.Lock()
+	for cacheKey := range presignCache.entries {
+		if strings.HasSuffix(cacheKey, ":"+s3Key) {
+			delete(presignCache.entries, cacheKey)
+		}
+	}
+	presignCache.Unlock()
+
+	if config.RedisClient != nil {
+		var keys []string
+		iter := config.RedisClient.Scan(ctx, 0, "presign:*:"+s3Key, 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if len(keys) > 0 {
+			config.RedisClient.Del(ctx, keys...)
+		}
+	}
+}
+
+// presignCacheTTL is how long a URL with the given expiration may be reused (0 = not cached)
+func presignCacheTTL(expiration time.Duration) time.Duration {
+	return expiration - presignCacheMargin
+}
+
+// presignCacheKey includes the expiration so a 5-minute URL is never served for a 15-minute request
+func presignCacheKey(s3Key string, expiration time.Duration) string {
+	return fmt.Sprintf("presign:%d:%s", int64(expiration.Seconds()), s3Key)
+}
+
+func cachedPresignedURL(s3Key string, expiration time.Duration) (string, bool) {
+	presignCache.RLock()
+	entry, ok := presignCache.entries[presignCacheKey(s3Key, expiration)]
+	presignCache.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.url, true
+}
+
+func storePresignedURL(s3Key, url string, expiration time.Duration) {
+	ttl := presignCacheTTL(expiration)
+	if ttl <= 0 {
+		return
+	}
+
+	presignCache.Lock()
+	defer presignCache.Unlock()
+	if len(presignCache.entries) >= maxPresignCacheEntries {
+		now := time.Now()
+		for key, entry := range presignCache.entries {
+			if now.After(entry.expiresAt) {
+				delete(presignCache.entries, key)
+			}
+		}
+		if len(presignCache.entries) >= maxPresignCacheEntries {
+			presignCache.entries = map[string]presignCacheEntry{}
+		}
+	}
+	presignCache.entries[presignCacheKey(s3Key, expiration)] = presignCacheEntry{url: url, expiresAt: time.Now().Add(ttl)}
+}
+
+// loadPresignedURLsFromRedis fills urls from Redis and returns the keys still missing
+func loadPresignedURLsFromRedis(ctx context.Context, keys []string, expiration time.Duration, urls map[string]string) []string {
+	if config.RedisClient == nil || len(keys) == 0 || presignCacheTTL(expiration) <= 0 {
+		return keys
+	}
+
+	cacheKeys := make([]string, len(keys))
+	for i, key := range keys {
+		cacheKeys[i] = presignCacheKey(key, expiration)
+	}
+	values, err := config.RedisClient.MGet(ctx, cacheKeys...).Result()
+	if err != nil {
+		// Redis is optional - fall back to presigning
+		return keys
+	}
+
+	// Redis only knows the entry's remaining TTL, not its age, so the local copy
+	// expires no later than the Redis one
+	ttls := config.RedisClient.Pipeline()
+	ttlCmds := make(map[int]*redis.DurationCmd, len(keys))
+	for i, value := range values {
+		if _, ok := value.(string); ok {
+			ttlCmds[i] = ttls.PTTL(ctx, cacheKeys[i])
+		}
+	}
+	if len(ttlCmds) > 0 {
+		ttls.Exec(ctx)
+	}
+
+	missing := keys[:0:0]
+	for i, value := range values {
+		url, ok := value.(string)
+		if !ok {
+			missing = append(missing, keys[i])
+			continue
+		}
+		urls[keys[i]] = url
+		if ttl := ttlCmds[i].Val(); ttl > 0 {
+			presignCache.Lock()
+			if len(presignCache.entries) < maxPresignCacheEntries {
+				presignCache.entries[cacheKeys[i]] = presignCacheEntry{url: url, expiresAt: time.Now().Add(ttl)}
+			}
+			presignCache.Unlock()
+		}
+	}
+	return missing
+}
+
+func storePresignedURLsInRedis(ctx context.Context, urls map[string]string, expiration time.Duration) {
+	ttl := presignCacheTTL(expiration)
+	if config.RedisClient == nil || len(urls) == 0 || ttl <= 0 {
+		return
+	}
+
+	pipe := config.RedisClient.Pipeline()
+	for key, url := range urls {
+		pipe.Set(ctx, presignCacheKey(key, expiration), url, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		utils.Logger(ctx).Warn("Failed to cache presigned URLs in Redis", zap.Error(err))
+	}
+}
@@ -13,10 +13,10 @@ func CreatePromotionMaterialDetails(detail *models.PromotionMaterialDetails) err
 	return config.DB.Create(detail).Error
 }
 
-// Get all PromotionMaterialDetails records
-func GetAllPromotionMaterialDetails() ([]models.PromotionMaterialDetails, error) {
+// Get the PromotionMaterialDetails records of events of the scope's branches
+func GetAllPromotionMaterialDetails(scope *BranchScope) ([]models.PromotionMaterialDetails, error) {
 	var details []models.PromotionMaterialDetails
-	if err := config.DB.
+	if err := scope.ApplyToEvents(config.DB, "event_id").
 		Preload("Event").
 		Find(&details).Error; err != nil {
 		return nil, err
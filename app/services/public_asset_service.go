@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Event media marked public (posters for the website) are served from stable URLs keyed by the
+// SHA-256 of their content, /public/assets/<sha256>.<ext>. The content behind such a URL can
+// never change, so it is served with immutable cache headers and can sit behind a CDN: set
+// PUBLIC_ASSET_BASE_URL to the CDN origin (it defaults to STORAGE_PUBLIC_URL, the API origin).
+
+// PublicAssetsRoute is where public assets are served, see handlers.ServePublicAssetHandler
+const PublicAssetsRoute = "/public/assets"
+
+// Public asset variants: the original and the ThumbnailSizes names
+const PublicAssetOriginal = "original"
+
+// ErrPublicAssetNotFound is returned for hashes that are not (or no longer) public
+var ErrPublicAssetNotFound = errors.New("asset not found")
+
+var publicAssetHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// publicAssetExtensions gives asset URLs a file extension browsers and CDNs recognise
+var publicAssetExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// PublicAssetURL returns the public URL of an asset
+func PublicAssetURL(asset models.PublicAsset) string {
+	return strings.TrimRight(config.Media.PublicAssetBaseURL, "/") + PublicAssetsRoute + "/" + asset.SHA256 + publicAssetExtensions[asset.ContentType]
+}
+
+// GetPublicAsset looks up a public asset by the file name of its URL (the hash, optionally with
+// an extension). Assets of media that were made private again, deleted, or whose file has been
+// replaced are not found.
+func GetPublicAsset(name string) (*models.PublicAsset, error) {
+	hash, _, _ := strings.Cut(strings.ToLower(name), ".")
+	if !publicAssetHash.MatchString(hash) {
+		return nil, ErrPublicAssetNotFound
+	}
+	var asset models.PublicAsset
+	err := config.DB.Table("public_assets a").
+		Select("a.*").
+		Joins("JOIN event_media m ON m.id = a.event_media_id").
+		Where("a.sha256 = ? AND m.is_public", hash).
+		Where("a.s3_key IN (m.s3_key, m.thumbnail_s3_key, m.thumbnail_medium_s3_key)").
+		Order("a.id").
+		Take(&asset).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPublicAssetNotFound
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// SetEventMediaPublic publishes an event image as public assets, or withdraws it. Only clean
+// images of events outside the sandbox can be published.
+func SetEventMediaPublic(ctx context.Context, media *models.EventMedia, public bool) error {
+	if public {
+		if media.FileType != "image" {
+			return fmt.Errorf("%w: only images can be made public", ErrInvalidEventMedia)
+		}
+		if media.ScanStatus != nil && *media.ScanStatus != models.MediaScanStatusClean {
+			return fmt.Errorf("%w: media failed the %s scan", ErrInvalidEventMedia, *media.ScanStatus)
+		}
+		var outsideSandbox int64
+		if err := excludeSandbox(config.DB.Model(&models.EventDetails{}), "branch_id").
+			Where("id = ?", media.EventID).Count(&outsideSandbox).Error; err != nil {
+			return err
+		}
+		if outsideSandbox == 0 {
+			return fmt.Errorf("%w: media of sandbox events cannot be made public", ErrInvalidEventMedia)
+		}
+	}
+	// Hash the variants before the flag is set, so a failed upload never leaves broken public URLs
+	media.IsPublic = public
+	if public {
+		if err := syncPublicAssets(ctx, media); err != nil {
+			return err
+		}
+	}
+	// is_public is read-only on the model
+	if err := config.DB.Table("event_media").Where("id = ?", media.ID).Update("is_public", public).Error; err != nil {
+		return err
+	}
+	if !public {
+		return syncPublicAssets(ctx, media)
+	}
+	return nil
+}
+
+// refreshPublicAssets re-hashes a public media item after its file or thumbnails changed
+func refreshPublicAssets(ctx context.Context, mediaID uint) error {
+	var media models.EventMedia
+	if err := config.DB.Where("id = ?", mediaID).First(&media).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !media.IsPublic {
+		return nil
+	}
+	return syncPublicAssets(ctx, &media)
+}
+
+// syncPublicAssets makes the asset rows of a media item match its current objects: one per
+// variant while the media is public, none otherwise. Unchanged objects are not hashed again.
+func syncPublicAssets(ctx context.Context, media *models.EventMedia) error {
+	if !media.IsPublic {
+		return config.DB.Where("event_media_id = ?", media.ID).Delete(&models.PublicAsset{}).Error
+	}
+
+	variants := map[string]string{PublicAssetOriginal: media.S3Key}
+	for _, size := range ThumbnailSizes {
+		key := media.ThumbnailS3Key
+		if size.Column == "thumbnail_medium_s3_key" {
+			key = media.ThumbnailMediumS3Key
+		}
+		if hasThumbnail(key) {
+			variants[size.Name] = *key
+		}
+	}
+
+	var existing []models.PublicAsset
+	if err := config.DB.Where("event_media_id = ?", media.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	current := make(map[string]models.PublicAsset, len(existing))
+	for _, asset := range existing {
+		current[asset.Variant] = asset
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return err
+	}
+	for variant, key := range variants {
+		if asset, ok := current[variant]; ok && asset.S3Key == key {
+			continue
+		}
+		asset, err := hashPublicAsset(ctx, storage, key)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", variant, err)
+		}
+		asset.EventMediaID = media.ID
+		asset.Variant = variant
+		if old, ok := current[variant]; ok {
+			asset.ID = old.ID
+		}
+		if err := config.DB.Save(asset).Error; err != nil {
+			return err
+		}
+	}
+	for variant, asset := range current {
+		if _, ok := variants[variant]; !ok {
+			if err := config.DB.Delete(&asset).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hashPublicAsset(ctx context.Context, storage Storage, key string) (*models.PublicAsset, error) {
+	info, err := storage.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return nil, err
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &models.PublicAsset{
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		S3Key:       key,
+		ContentType: contentType,
+		Size:        size,
+	}, nil
+}
+
+// attachPublicAssetURLs fills in the public URLs of the public media in mediaList
+func attachPublicAssetURLs(ctx context.Context, mediaList []models.EventMedia) {
+	ids := make([]uint, 0, len(mediaList))
+	for _, media := range mediaList {
+		if media.IsPublic {
+			ids = append(ids, media.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var assets []models.PublicAsset
+	if err := config.DB.Where("event_media_id IN ?", ids).Find(&assets).Error; err != nil {
+		utils.Logger(ctx).Warn("Failed to load public assets", zap.Error(err))
+		return
+	}
+	byMedia := make(map[uint]map[string]models.PublicAsset, len(ids))
+	for _, asset := range assets {
+		if byMedia[asset.EventMediaID] == nil {
+			byMedia[asset.EventMediaID] = map[string]models.PublicAsset{}
+		}
+		byMedia[asset.EventMediaID][asset.Variant] = asset
+	}
+	for i := range mediaList {
+		variants, ok := byMedia[mediaList[i].ID]
+		if !ok {
+			continue
+		}
+		if asset, ok := variants[PublicAssetOriginal]; ok {
+			mediaList[i].PublicURL = PublicAssetURL(asset)
+		}
+		if asset, ok := variants["medium"]; ok {
+			mediaList[i].PublicMediumURL = PublicAssetURL(asset)
+		}
+		if asset, ok := variants["small"]; ok {
+			mediaList[i].PublicThumbnailURL = PublicAssetURL(asset)
+		}
+	}
+}
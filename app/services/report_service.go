@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/jung-kurt/gofpdf"
+	"go.uber.org/zap"
+)
+
+const (
+	reportMaxThumbnails     = 12
+	reportMaxThumbnailBytes = 5 << 20 // 5MB per image
+	reportThumbnailsPerRow  = 4
+)
+
+// reportHTTPClient downloads thumbnails from presigned URLs
+var reportHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// reportThumbnail is an image downloaded for embedding in the event report
+type reportThumbnail struct {
+	Name      string
+	ImageType string // gofpdf image type: JPG, PNG or GIF
+	Data      []byte
+}
+
+// eventReportPayload is the JobTypeEventReport payload
+type eventReportPayload struct {
+	EventID uint `json:"event_id"`
+	MaskPII bool `json:"mask_pii"`
+}
+
+// QueueEventReportPDF renders the event report in the background, for events whose photos
+// make the synchronous download slow. The job result links to the PDF for jobFileRetention.
+func QueueEventReportPDF(ctx context.Context, eventID uint, maskPII bool, createdBy *uint) (*models.Job, error) {
+	if _, err := GetEventByID(eventID); err != nil {
+		return nil, err
+	}
+	return EnqueueJob(ctx, JobTypeEventReport, eventReportPayload{EventID: eventID, MaskPII: maskPII}, JobOptions{CreatedBy: createdBy})
+}
+
+func runEventReportJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload eventReportPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	run.SetProgress(10, "Rendering report")
+	pdfBytes, err := GenerateEventReportPDF(ctx, payload.EventID, payload.MaskPII)
+	if errors.Is(err, ErrEventNotFound) {
+		return nil, PermanentJobError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run.SetProgress(80, "Uploading report")
+	filename := fmt.Sprintf("event_report_%d.pdf", payload.EventID)
+	uploaded, err := UploadFile(ctx, pdfBytes, filename, "application/pdf", "reports")
+	if err != nil {
+		return nil, err
+	}
+	QueueStorageCleanup(ctx, time.Now().Add(jobFileRetention), uploaded.S3Key)
+
+	return models.JSONB{"file_key": uploaded.S3Key, "filename": filename, "size": len(pdfBytes)}, nil
+}
+
+// GenerateEventReportPDF renders the formatted event report: details, beneficiary and
+// initiation counts, guest list, donation totals and photo thumbnails.
+// When maskPII is set, donation amounts are left out (non-privileged roles).
+func GenerateEventReportPDF(ctx context.Context, eventID uint, maskPII bool) ([]byte, error) {
+	event, err := GetEventByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	guests, err := GetSpecialGuestByEventID(eventID)
+	if err != nil && err != ErrSpecialGuestNotFound {
+		return nil, err
+	}
+	donations, err := GetDonationsByEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	mediaList, err := GetEventMediaByEventID(eventID)
+	if err != nil {
+		mediaList = []models.EventMedia{}
+	}
+	thumbnails := fetchReportThumbnails(ctx, mediaList)
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Arial", "I", 7)
+		pdf.CellFormat(0, 6, tr(fmt.Sprintf("%s  |  Generated on %s  |  Page %d of {nb}",
+			reportTitle(event), time.Now().Format("2006-01-02 15:04"), pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Title block
+	pdf.SetFont("Arial", "B", 18)
+	pdf.CellFormat(0, 10, "Event Report", "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.CellFormat(0, 6, tr(reportTitle(event)), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	// Event details
+	reportSection(pdf, "Event Details")
+	branchName := ""
+	if event.Branch != nil {
+		branchName = event.Branch.Name
+	}
+	dates := event.StartDate.Format("02 Jan 2006")
+	if !event.EndDate.IsZero() && !event.EndDate.Equal(event.StartDate) {
+		dates += " - " + event.EndDate.Format("02 Jan 2006")
+	}
+	venue := joinNonEmpty(", ", event.Address, event.City, event.District, event.State, event.Country, event.Pincode)
+	for _, field := range [][2]string{
+		{"Event Type", event.EventType.Name},
+		{"Category", event.EventCategory.Name},
+		{"Scale", event.Scale},
+		{"Theme", event.Theme},
+		{"Dates", dates},
+		{"Branch", branchName},
+		{"Venue", venue},
+		{"Spiritual Orator", event.SpiritualOrator},
+		{"Language", event.Language},
+		{"Status", joinNonEmpty(" / ", event.Status, event.ApprovalStatus)},
+	} {
+		reportField(pdf, tr, field[0], field[1])
+	}
+	pdf.Ln(4)
+
+	// Beneficiary and initiation counts
+	reportSection(pdf, "Beneficiaries & Initiations")
+	countWidths := []float64{50, 32, 32, 32, 34}
+	reportTableHeader(pdf, []string{"", "Men", "Women", "Children", "Total"}, countWidths)
+	pdf.SetFont("Arial", "", 9)
+	for _, row := range []struct {
+		label                string
+		men, women, children int
+	}{
+		{"Beneficiaries", event.BeneficiaryMen, event.BeneficiaryWomen, event.BeneficiaryChild},
+		{"Initiations", event.InitiationMen, event.InitiationWomen, event.InitiationChild},
+	} {
+		pdf.CellFormat(countWidths[0], 7, row.label, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(countWidths[1], 7, fmt.Sprint(row.men), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(countWidths[2], 7, fmt.Sprint(row.women), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(countWidths[3], 7, fmt.Sprint(row.children), "1", 0, "R", false, 0, "")
+		pdf.SetFont("Arial", "B", 9)
+		pdf.CellFormat(countWidths[4], 7, fmt.Sprint(row.men+row.women+row.children), "1", 1, "R", false, 0, "")
+		pdf.SetFont("Arial", "", 9)
+	}
+	pdf.Ln(6)
+
+	// Guest list
+	reportSection(pdf, fmt.Sprintf("Special Guests (%d)", len(guests)))
+	if len(guests) == 0 {
+		reportEmpty(pdf, "No special guests recorded.")
+	} else {
+		guestWidths := []float64{55, 45, 50, 30}
+		reportTableHeader(pdf, []string{"Name", "Designation", "Organization", "City"}, guestWidths)
+		pdf.SetFont("Arial", "", 8)
+		for _, guest := range guests {
+			name := joinNonEmpty(" ", guest.Prefix, guest.FirstName, guest.MiddleName, guest.LastName)
+			for i, cell := range []string{name, guest.Designation, guest.Organization, guest.City} {
+				ln := 0
+				if i == len(guestWidths)-1 {
+					ln = 1
+				}
+				pdf.CellFormat(guestWidths[i], 6, tr(truncateReportText(cell, guestWidths[i])), "1", ln, "L", false, 0, "")
+			}
+		}
+	}
+	pdf.Ln(6)
+
+	// Donation totals by type
+	reportSection(pdf, fmt.Sprintf("Donations (%d)", len(donations)))
+	if len(donations) == 0 {
+		reportEmpty(pdf, "No donations recorded.")
+	} else {
+		type donationTotal struct {
+			count  int
+			amount float64
+		}
+		totals := map[string]*donationTotal{}
+		var grandTotal float64
+		for _, d := range donations {
+			key := d.DonationType
+			if key == "" {
+				key = "Other"
+			}
+			if totals[key] == nil {
+				totals[key] = &donationTotal{}
+			}
+			totals[key].count++
+			totals[key].amount += d.Amount
+			grandTotal += d.Amount
+		}
+		types := make([]string, 0, len(totals))
+		for t := range totals {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		donationWidths := []float64{100, 30, 50}
+		reportTableHeader(pdf, []string{"Donation Type", "Entries", "Amount (Rs.)"}, donationWidths)
+		pdf.SetFont("Arial", "", 9)
+		for _, t := range types {
+			pdf.CellFormat(donationWidths[0], 7, tr(t), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(donationWidths[1], 7, fmt.Sprint(totals[t].count), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(donationWidths[2], 7, reportAmount(totals[t].amount, maskPII), "1", 1, "R", false, 0, "")
+		}
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(240, 240, 240)
+		pdf.CellFormat(donationWidths[0], 7, "Total", "1", 0, "R", true, 0, "")
+		pdf.CellFormat(donationWidths[1], 7, fmt.Sprint(len(donations)), "1", 0, "R", true, 0, "")
+		pdf.CellFormat(donationWidths[2], 7, reportAmount(grandTotal, maskPII), "1", 1, "R", true, 0, "")
+		pdf.SetFillColor(255, 255, 255)
+	}
+	pdf.Ln(6)
+
+	// Photo thumbnails
+	if len(thumbnails) > 0 {
+		reportSection(pdf, fmt.Sprintf("Photos (%d)", len(thumbnails)))
+		left, _, right, _ := pdf.GetMargins()
+		pageWidth, pageHeight := pdf.GetPageSize()
+		gap := 4.0
+		cellWidth := (pageWidth - left - right - gap*(reportThumbnailsPerRow-1)) / reportThumbnailsPerRow
+		cellHeight := cellWidth * 0.75
+
+		for i, thumb := range thumbnails {
+			col := i % reportThumbnailsPerRow
+			if col == 0 {
+				if i > 0 {
+					pdf.Ln(cellHeight + gap)
+				}
+				if pdf.GetY()+cellHeight > pageHeight-25 {
+					pdf.AddPage()
+				}
+			}
+			x := left + float64(col)*(cellWidth+gap)
+			y := pdf.GetY()
+
+			info := pdf.RegisterImageOptionsReader(thumb.Name, gofpdf.ImageOptions{ImageType: thumb.ImageType}, bytes.NewReader(thumb.Data))
+			if info == nil || pdf.Err() {
+				// Skip images gofpdf cannot decode (e.g. progressive/CMYK JPEGs) rather than failing the report
+				utils.Logger(ctx).Warn("Skipping report thumbnail", zap.String("name", thumb.Name), zap.Error(pdf.Error()))
+				pdf.ClearError()
+				continue
+			}
+
+			// Fit inside the cell, preserving aspect ratio
+			w, h := cellWidth, cellWidth*info.Height()/info.Width()
+			if h > cellHeight {
+				h = cellHeight
+				w = cellHeight * info.Width() / info.Height()
+			}
+			pdf.ImageOptions(thumb.Name, x+(cellWidth-w)/2, y+(cellHeight-h)/2, w, h, false,
+				gofpdf.ImageOptions{ImageType: thumb.ImageType}, 0, "")
+			pdf.SetXY(left, y)
+		}
+		pdf.Ln(cellHeight + gap)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fetchReportThumbnails downloads up to reportMaxThumbnails event photos through presigned URLs.
+// Thumbnails are preferred over originals when available. Failures are logged and skipped.
+func fetchReportThumbnails(ctx context.Context, mediaList []models.EventMedia) []reportThumbnail {
+	var thumbnails []reportThumbnail
+	for _, media := range mediaList {
+		if len(thumbnails) >= reportMaxThumbnails {
+			break
+		}
+		if media.FileType != "image" || MediaDownloadable(media.ScanStatus) != nil {
+			continue
+		}
+		key := media.S3Key
+		if media.ThumbnailS3Key != nil && *media.ThumbnailS3Key != "" {
+			key = *media.ThumbnailS3Key
+		}
+		if key == "" {
+			continue
+		}
+
+		url, err := GetPresignedURL(ctx, key, 5*time.Minute)
+		if err != nil {
+			utils.Logger(ctx).Warn("Report thumbnail presign failed", zap.Uint("media_id", media.ID), zap.Error(err))
+			continue
+		}
+		data, err := downloadReportImage(ctx, url)
+		if err != nil {
+			utils.Logger(ctx).Warn("Report thumbnail download failed", zap.Uint("media_id", media.ID), zap.Error(err))
+			continue
+		}
+
+		var imageType string
+		switch http.DetectContentType(data) {
+		case "image/jpeg":
+			imageType = "JPG"
+		case "image/png":
+			imageType = "PNG"
+		case "image/gif":
+			imageType = "GIF"
+		default:
+			continue // webp, heic etc. are not supported by gofpdf
+		}
+
+		thumbnails = append(thumbnails, reportThumbnail{
+			Name:      fmt.Sprintf("media-%d", media.ID),
+			ImageType: imageType,
+			Data:      data,
+		})
+	}
+	return thumbnails
+}
+
+func downloadReportImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := reportHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, reportMaxThumbnailBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > reportMaxThumbnailBytes {
+		return nil, fmt.Errorf("image exceeds %d bytes", reportMaxThumbnailBytes)
+	}
+	return data, nil
+}
+
+func reportTitle(event *models.EventDetails) string {
+	title := fmt.Sprintf("Event #%d", event.ID)
+	if event.ReportNumber != "" {
+		title = event.ReportNumber
+	}
+	if event.EventType.Name != "" {
+		title += " - " + event.EventType.Name
+	}
+	return title
+}
+
+func reportSection(pdf *gofpdf.Fpdf, title string) {
+	_, pageHeight := pdf.GetPageSize()
+	if pdf.GetY() > pageHeight-50 {
+		pdf.AddPage()
+	}
+	pdf.SetFont("Arial", "B", 12)
+	pdf.SetFillColor(230, 236, 245)
+	pdf.CellFormat(0, 8, title, "", 1, "L", true, 0, "")
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Ln(2)
+}
+
+func reportField(pdf *gofpdf.Fpdf, tr func(string) string, label, value string) {
+	if value == "" {
+		return
+	}
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(40, 6, label, "", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	pdf.MultiCell(0, 6, tr(value), "", "L", false)
+}
+
+func reportTableHeader(pdf *gofpdf.Fpdf, headers []string, widths []float64) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(220, 220, 220)
+	for i, header := range headers {
+		ln := 0
+		if i == len(headers)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(widths[i], 7, header, "1", ln, "C", true, 0, "")
+	}
+	pdf.SetFillColor(255, 255, 255)
+}
+
+func reportEmpty(pdf *gofpdf.Fpdf, text string) {
+	pdf.SetFont("Arial", "I", 9)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.CellFormat(0, 6, text, "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+func reportAmount(amount float64, masked bool) string {
+	if masked {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// truncateReportText shortens text to roughly fit a table cell of the given width (mm)
+func truncateReportText(text string, width float64) string {
+	maxChars := int(width / 1.6)
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars-3]) + "..."
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
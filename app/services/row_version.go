@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// VersionConflictError is returned when an update carries the version the client last read
+// and the record has been updated since. Current is the stored record, so the client can show
+// what changed instead of silently overwriting it.
+type VersionConflictError struct {
+	Version int
+	Current interface{}
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("record was changed since it was loaded (current version %d)", e.Version)
+}
+
+// updateVersioned applies updates to a loaded row and bumps its version. With an expected
+// version the check and the bump happen in one statement, so of two concurrent updates from the
+// same version only one applies; it reports false for the other. Without one the update
+// overwrites whatever is stored (clients from before versioning).
+func updateVersioned(db *gorm.DB, model interface{}, expected *int, updates map[string]interface{}) (bool, error) {
+	updates["version"] = gorm.Expr("version + 1")
+	query := db.Model(model)
+	if expected != nil {
+		query = query.Where("version = ?", *expected)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return expected == nil || result.RowsAffected > 0, nil
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+)
+
+// S3Storage stores objects in one S3 bucket
+type S3Storage struct {
+	client    *s3.Client
+	uploader  *manager.Uploader
+	presigner *s3.PresignClient
+	bucket    string
+	region    string
+}
+
+// NewS3Storage wraps an S3 client for the given bucket
+func NewS3Storage(client *s3.Client, bucket, region string) *S3Storage {
+	return &S3Storage{
+		client:    client,
+		uploader:  manager.NewUploader(client),
+		presigner: s3.NewPresignClient(client),
+		bucket:    bucket,
+		region:    region,
+	}
+}
+
+// NewS3StorageFromConfig creates the S3 storage from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_S3_BUCKET_NAME and AWS_REGION (see config.S3Config) and verifies bucket access. For MinIO
+// and other S3-compatible servers set AWS_S3_ENDPOINT (e.g. http://localhost:9000); path-style
+// addressing is then used unless AWS_S3_FORCE_PATH_STYLE=false, and AWS_REGION defaults to
+// us-east-1.
+// This function forces the use of static credentials from .env and prevents
+// fallback to IAM role credentials (which would use temporary ASIA keys)
+func NewS3StorageFromConfig(ctx context.Context, s3Config config.S3Config) (*S3Storage, error) {
+	accessKeyID := s3Config.AccessKeyID
+	secretAccessKey := s3Config.SecretAccessKey
+	bucketName := s3Config.Bucket
+	region := s3Config.Region
+	endpoint := s3Config.Endpoint
+	usePathStyle := s3Config.ForcePathStyle
+
+	// Validate required settings
+	if accessKeyID == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID environment variable is required")
+	}
+	if secretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_SECRET_ACCESS_KEY environment variable is required")
+	}
+	if bucketName == "" {
+		return nil, fmt.Errorf("AWS_S3_BUCKET_NAME environment variable is required")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION environment variable is required")
+	}
+
+	// Create static credentials provider - explicitly force .env credentials. An explicit
+	// provider takes precedence over the SDK's credential chain (IAM roles, web identity,
+	// AWS_SESSION_TOKEN), which is verified below.
+	credsProvider := credentials.NewStaticCredentialsProvider(
+		accessKeyID,
+		secretAccessKey,
+		"", // Explicitly empty session token - ensures permanent credentials
+	)
+
+	// Create AWS config with static credentials provider
+	// WithCredentialsProvider should prioritize our static credentials
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(region),
+		awsconfig.WithCredentialsProvider(credsProvider),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	// Count and time every S3 call for /metrics
+	cfg.APIOptions = append(cfg.APIOptions, metrics.S3APIOption)
+
+	// CRITICAL: Verify which credentials are actually being used
+	// This ensures we catch any credential chain fallback issues
+	actualCreds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+
+	// Mask credentials for logging (show first 8 characters only)
+	maskKey := func(key string) string {
+		if len(key) > 8 {
+			return key[:8] + "***"
+		}
+		return key + "***"
+	}
+
+	expectedMasked := maskKey(accessKeyID)
+	actualMasked := maskKey(actualCreds.AccessKeyID)
+
+	// Log credential verification for debugging
+	logger := utils.Logger(ctx)
+	logger.Info("S3 credentials verification",
+		zap.String("expected_key", expectedMasked), zap.String("actual_key", actualMasked), zap.String("source", actualCreds.Source))
+
+	// Verify access key matches exactly - this is the critical check
+	// Allow both AKIA (permanent) and ASIA (temporary) credentials if explicitly set in environment
+	if actualCreds.AccessKeyID != accessKeyID {
+		logger.Error("S3 access key mismatch", zap.String("expected_key", expectedMasked), zap.String("actual_key", actualMasked))
+		return nil, fmt.Errorf("credentials mismatch: SDK is using %s instead of %s from .env", actualMasked, expectedMasked)
+	}
+
+	// Warn if using temporary credentials (ASIA) - but allow them if explicitly set in environment
+	// (S3-compatible servers have their own key formats)
+	if endpoint == "" && !strings.HasPrefix(actualCreds.AccessKeyID, "AKIA") {
+		logger.Warn("S3 is using temporary credentials (ASIA prefix): they expire and may cause authentication failures, "+
+			"consider permanent credentials (AKIA prefix) for production", zap.String("key", actualMasked))
+		// Don't return error - allow temporary credentials if explicitly set in environment
+	}
+
+	// Credentials verified - create S3 client
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = usePathStyle
+	})
+	storage := NewS3Storage(client, bucketName, region)
+
+	logger.Info("S3 initialized",
+		zap.String("bucket", bucketName), zap.String("region", region), zap.String("endpoint", endpoint),
+		zap.Bool("path_style", usePathStyle), zap.String("key", expectedMasked))
+
+	// Verify bucket access and permissions
+	if err := storage.Verify(ctx); err != nil {
+		return nil, fmt.Errorf("S3 bucket verification failed: %w", err)
+	}
+
+	logger.Info("S3 bucket verification passed", zap.String("bucket", bucketName))
+
+	return storage, nil
+}
+
+// WithBucket returns a storage for another bucket that shares this storage's client
+func (s *S3Storage) WithBucket(bucket string) *S3Storage {
+	other := *s
+	other.bucket = bucket
+	return &other
+}
+
+func (s *S3Storage) Bucket() string {
+	return s.bucket
+}
+
+// Region is the AWS region of the bucket
+func (s *S3Storage) Region() string {
+	return s.region
+}
+
+func (s *S3Storage) Upload(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	// Note: ACL is not set because the bucket has ACLs disabled
+	// Public access should be configured via bucket policy instead
+	// All access should use presigned URLs for security
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		Body:         body,
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClassStandard, // immediate access
+		Metadata:     metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("S3 upload failed (bucket: %s, key: %s): %w", s.bucket, key, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to read object (bucket: %s, key: %s): %w", s.bucket, key, err)
+	}
+	return object.Body, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %w", err)
+	}
+	return nil
+}
+
+// Presign signs a GET request locally; it makes no network round trip
+func (s *S3Storage) Presign(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	request, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		// Add response headers for CORS support
+		ResponseCacheControl: aws.String("public, max-age=3600"),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expiration
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL (bucket: %s, key: %s): %w. Check AWS IAM permissions for s3:GetObject", s.bucket, key, err)
+	}
+	return request.URL, nil
+}
+
+func (s *S3Storage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get object metadata: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		ContentType:  aws.ToString(result.ContentType),
+		LastModified: aws.ToTime(result.LastModified),
+		Metadata:     make(map[string]string, len(result.Metadata)),
+	}
+	for k, v := range result.Metadata {
+		info.Metadata[k] = v
+	}
+	return info, nil
+}
+
+// List pages through the bucket 1000 keys per request
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list bucket %s: %w", s.bucket, err)
+		}
+		for _, object := range page.Contents {
+			err := fn(ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) CreateMultipartUpload(ctx context.Context, key, contentType string, metadata map[string]string) (string, error) {
+	result, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClassStandard,
+		Metadata:     metadata,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload (bucket: %s, key: %s): %w", s.bucket, key, err)
+	}
+	return aws.ToString(result.UploadId), nil
+}
+
+func (s *S3Storage) UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (string, error) {
+	result, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(number),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d (key: %s): %w", number, key, err)
+	}
+	return strings.Trim(aws.ToString(result.ETag), `"`), nil
+}
+
+func (s *S3Storage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = types.CompletedPart{ETag: aws.String(`"` + part.ETag + `"`), PartNumber: aws.Int32(part.Number)}
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload (key: %s): %w", key, err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards the uploaded parts; uploads S3 no longer knows are ignored
+func (s *S3Storage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	var noSuchUpload *types.NoSuchUpload
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		return fmt.Errorf("failed to abort multipart upload (key: %s): %w", key, err)
+	}
+	return nil
+}
+
+// Verify checks that the bucket is accessible and has correct permissions
+func (s *S3Storage) Verify(ctx context.Context) error {
+	logger := utils.Logger(ctx).With(zap.String("bucket", s.bucket))
+
+	// Test 1: Check if bucket exists and is accessible (HeadBucket)
+	logger.Debug("Verifying S3 bucket access")
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot access bucket %s: %w. Check bucket name, region, and IAM permissions (s3:ListBucket)", s.bucket, err)
+	}
+	logger.Debug("S3 bucket exists and is accessible")
+
+	// Test 2: Verify we can list objects (tests s3:ListBucket permission)
+	_, err = s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		MaxKeys: aws.Int32(1), // Only list 1 object to test permission
+	})
+	if err != nil {
+		return fmt.Errorf("cannot list objects in bucket %s: %w. Check IAM permissions (s3:ListBucket)", s.bucket, err)
+	}
+	logger.Debug("S3 permission verified", zap.String("permission", "s3:ListBucket"))
+
+	// Test 3: Verify we can generate presigned URLs (tests s3:GetObject permission)
+	// Use a test key that might not exist - we're just testing permission, not object existence
+	testKey := "test-permission-check-" + fmt.Sprintf("%d", time.Now().Unix())
+	if _, err := s.Presign(ctx, testKey, time.Minute); err != nil {
+		return fmt.Errorf("cannot generate presigned URLs: %w", err)
+	}
+	logger.Debug("S3 permission verified", zap.String("permission", "s3:GetObject (presign)"))
+
+	// Test 4: Verify we can upload (tests s3:PutObject permission)
+	// Create a minimal test upload to verify write permissions
+	testUploadKey := "test-upload-permission-" + fmt.Sprintf("%d", time.Now().Unix()) + ".txt"
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(testUploadKey),
+		Body:        bytes.NewReader([]byte("test")),
+		ContentType: aws.String("text/plain"),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot upload to bucket %s: %w. Check IAM permissions (s3:PutObject)", s.bucket, err)
+	}
+	logger.Debug("S3 permission verified", zap.String("permission", "s3:PutObject"))
+
+	// Clean up test file
+	if err := s.Delete(ctx, testUploadKey); err != nil {
+		logger.Warn("Failed to delete S3 permission test file", zap.String("key", testUploadKey), zap.Error(err))
+		// Don't fail verification if cleanup fails
+	} else {
+		logger.Debug("S3 permission verified", zap.String("permission", "s3:DeleteObject"))
+	}
+
+	return nil
+}
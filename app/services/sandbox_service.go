@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// Sandbox branches (branches.is_sandbox) let new branches practise data entry during training.
+// Their events, donations and media are real rows, so every list and form works as usual, but
+// excludeSandbox keeps them out of stats and exports, and WipeSandbox deletes them afterwards.
+
+// sandboxBranchesSQL selects the IDs of all sandbox branches, soft-deleted ones included
+const sandboxBranchesSQL = "SELECT id FROM branches WHERE is_sandbox"
+
+// Audit actions recorded on sandbox branches
+const (
+	auditActionSandboxOn   = "sandbox_on"
+	auditActionSandboxOff  = "sandbox_off"
+	auditActionSandboxWipe = "sandbox_wipe"
+)
+
+var (
+	// ErrInvalidSandbox is returned when a wipe would leave a real branch under a deleted sandbox branch
+	ErrInvalidSandbox = errors.New("invalid sandbox operation")
+	// errSandboxDryRun rolls back the transaction of a dry run
+	errSandboxDryRun = errors.New("dry run")
+)
+
+// excludeSandbox leaves out rows whose branch column points at a sandbox branch. Rows without
+// a branch are kept.
+func excludeSandbox(db *gorm.DB, branchColumn string) *gorm.DB {
+	return db.Where("(" + branchColumn + " IS NULL OR " + branchColumn + " NOT IN (" + sandboxBranchesSQL + "))")
+}
+
+// withoutSandboxBranches drops sandbox branches from a roll-up of a real branch. A sandbox
+// branch's own roll-up keeps them, so trainees still see their figures.
+func withoutSandboxBranches(rootID uint, rows []BranchStatsRow) ([]BranchStatsRow, error) {
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.BranchID
+	}
+	var sandbox []uint
+	if err := config.DB.Model(&models.Branch{}).Where("id IN ? AND is_sandbox", ids).Pluck("id", &sandbox).Error; err != nil {
+		return nil, err
+	}
+	isSandbox := make(map[uint]bool, len(sandbox))
+	for _, id := range sandbox {
+		isSandbox[id] = true
+	}
+	if len(sandbox) == 0 || isSandbox[rootID] {
+		return rows, nil
+	}
+	kept := rows[:0]
+	for _, row := range rows {
+		if !isSandbox[row.BranchID] {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+// SandboxBranch is a branch in sandbox mode with the practice data entered so far
+type SandboxBranch struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	ParentBranchID *uint  `json:"parent_branch_id,omitempty"`
+	Events         int64  `json:"events"`
+	BranchMedia    int64  `json:"branch_media"`
+}
+
+// SandboxSummary lists the sandbox branches
+type SandboxSummary struct {
+	Branches []SandboxBranch `json:"branches"`
+	Events   int64           `json:"events"`
+}
+
+// GetSandboxSummary lists the sandbox branches with their event and media counts
+func GetSandboxSummary() (*SandboxSummary, error) {
+	summary := &SandboxSummary{Branches: []SandboxBranch{}}
+	err := config.DB.Table("branches b").
+		Select(`b.id, b.name, b.parent_branch_id,
+			(SELECT COUNT(*) FROM event_details e WHERE e.branch_id = b.id) AS events,
+			(SELECT COUNT(*) FROM branch_media m WHERE m.branch_id = b.id) AS branch_media`).
+		Where("b.is_sandbox AND b.deleted_at IS NULL").
+		Order("b.name, b.id").
+		Scan(&summary.Branches).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range summary.Branches {
+		summary.Events += branch.Events
+	}
+	return summary, nil
+}
+
+// SetBranchSandbox puts a branch and all of its child branches into or out of sandbox mode.
+// Taking a branch out of sandbox mode turns its practice data into real data: wipe it first.
+func SetBranchSandbox(branchID uint, sandbox bool, actor AuditActor) ([]uint, error) {
+	var rows []BranchStatsRow
+	if err := config.DB.Raw(branchTreeSQL, map[string]interface{}{"id": branchID}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrBranchNotFound
+	}
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.BranchID
+	}
+
+	action := auditActionSandboxOff
+	if sandbox {
+		action = auditActionSandboxOn
+	}
+	var changed []uint
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Branch{}).Where("id IN ? AND is_sandbox <> ?", ids, sandbox).Pluck("id", &changed).Error; err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		// is_sandbox is read-only on the model, so write it through the table
+		if err := tx.Table("branches").Where("id IN ?", changed).Update("is_sandbox", sandbox).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, id := range changed {
+			entry := actor.auditEntry(AuditEntityBranch, id, action, models.JSONB{
+				"is_sandbox": map[string]interface{}{"old": !sandbox, "new": sandbox},
+			}, now)
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changed == nil {
+		changed = []uint{}
+	}
+	return changed, nil
+}
+
+// SandboxWipeOptions configures WipeSandbox
+type SandboxWipeOptions struct {
+	DryRun bool `json:"dry_run"`
+	// IncludeBranches also deletes the sandbox branches themselves (with members and infrastructure)
+	IncludeBranches bool `json:"include_branches"`
+}
+
+// SandboxWipeResult counts what a wipe deleted (or would delete in a dry run)
+type SandboxWipeResult struct {
+	DryRun             bool  `json:"dry_run"`
+	Branches           int64 `json:"branches"`
+	Events             int64 `json:"events"`
+	EventMedia         int64 `json:"event_media"`
+	SpecialGuests      int64 `json:"special_guests"`
+	Volunteers         int64 `json:"volunteers"`
+	Donations          int64 `json:"donations"`
+	PromotionMaterials int64 `json:"promotion_materials"`
+	BranchMedia        int64 `json:"branch_media"`
+	StorageObjects     int   `json:"storage_objects"`
+}
+
+// WipeSandbox permanently deletes the events (with their guests, volunteers, donations, media
+// and promotion materials) and the branch media of all sandbox branches, soft-deleted rows
+// included. Stored files are removed by a storage cleanup job after the commit. Nothing is
+// deleted while a legal hold covers any of it.
+func WipeSandbox(ctx context.Context, opts SandboxWipeOptions, actor AuditActor) (*SandboxWipeResult, error) {
+	result := &SandboxWipeResult{DryRun: opts.DryRun}
+	var keys []string
+
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var branchIDs []uint
+		if err := tx.Table("branches").Where("is_sandbox").Order("id").Pluck("id", &branchIDs).Error; err != nil {
+			return err
+		}
+		if len(branchIDs) == 0 {
+			return nil
+		}
+		var events []struct {
+			ID       uint
+			BranchID uint
+		}
+		if err := tx.Table("event_details").Select("id, branch_id").Where("branch_id IN ?", branchIDs).Scan(&events).Error; err != nil {
+			return err
+		}
+		eventIDs := make([]uint, len(events))
+		eventsPerBranch := map[uint]int{}
+		for i, e := range events {
+			eventIDs[i] = e.ID
+			eventsPerBranch[e.BranchID]++
+		}
+
+		var held int64
+		if err := activeLegalHolds(tx.Model(&models.LegalHold{})).
+			Where(`(entity_type = 'event' AND entity_id IN (SELECT id FROM event_details WHERE branch_id IN ?))
+				OR (entity_type = 'event_media' AND entity_id IN (SELECT m.id FROM event_media m JOIN event_details e ON e.id = m.event_id WHERE e.branch_id IN ?))
+				OR (entity_type = 'branch_media' AND entity_id IN (SELECT id FROM branch_media WHERE branch_id IN ?))`,
+				branchIDs, branchIDs, branchIDs).
+			Count(&held).Error; err != nil {
+			return err
+		}
+		if held > 0 {
+			return fmt.Errorf("%w: %d legal hold(s) cover sandbox data", ErrLegalHold, held)
+		}
+		if opts.IncludeBranches {
+			var realChildren int64
+			if err := tx.Table("branches").Where("parent_branch_id IN ? AND NOT is_sandbox", branchIDs).Count(&realChildren).Error; err != nil {
+				return err
+			}
+			if realChildren > 0 {
+				return fmt.Errorf("%w: %d branch(es) outside the sandbox have a sandbox parent branch", ErrInvalidSandbox, realChildren)
+			}
+		}
+
+		keyColumns := []struct{ table, column, where string }{
+			{"event_media", "s3_key", "event_id IN ?"},
+			{"event_media", "thumbnail_s3_key", "event_id IN ?"},
+			{"event_media", "thumbnail_medium_s3_key", "event_id IN ?"},
+			{"donations", "receipt_s3_key", "event_id IN ?"},
+			{"branch_media", "s3_key", "branch_id IN ?"},
+			{"branch_media", "thumbnail_s3_key", "branch_id IN ?"},
+			{"branch_media", "thumbnail_medium_s3_key", "branch_id IN ?"},
+		}
+		for _, kc := range keyColumns {
+			ids := eventIDs
+			if kc.table == "branch_media" {
+				ids = branchIDs
+			}
+			if len(ids) == 0 {
+				continue
+			}
+			var columnKeys []string
+			if err := tx.Table(kc.table).Where(kc.where, ids).
+				Where(kc.column+" IS NOT NULL AND "+kc.column+" <> ''").
+				Pluck(kc.column, &columnKeys).Error; err != nil {
+				return err
+			}
+			keys = append(keys, columnKeys...)
+		}
+
+		if len(eventIDs) > 0 {
+			for _, child := range []struct {
+				table string
+				count *int64
+			}{
+				{"event_media", &result.EventMedia},
+				{"special_guests", &result.SpecialGuests},
+				{"volunteers", &result.Volunteers},
+				{"donations", &result.Donations},
+				{"promotion_material_details", &result.PromotionMaterials},
+			} {
+				deleted := tx.Exec("DELETE FROM "+child.table+" WHERE event_id IN ?", eventIDs)
+				if deleted.Error != nil {
+					return fmt.Errorf("deleting sandbox %s: %w", child.table, deleted.Error)
+				}
+				*child.count = deleted.RowsAffected
+			}
+			// Status history and drafts go with the event (ON DELETE CASCADE)
+			deleted := tx.Exec("DELETE FROM event_details WHERE id IN ?", eventIDs)
+			if deleted.Error != nil {
+				return fmt.Errorf("deleting sandbox events: %w", deleted.Error)
+			}
+			result.Events = deleted.RowsAffected
+		}
+
+		// Cover images and coordinator photos are cleared by ON DELETE SET NULL
+		deleted := tx.Exec("DELETE FROM branch_media WHERE branch_id IN ?", branchIDs)
+		if deleted.Error != nil {
+			return fmt.Errorf("deleting sandbox branch media: %w", deleted.Error)
+		}
+		result.BranchMedia = deleted.RowsAffected
+
+		if opts.IncludeBranches {
+			// Members, infrastructure and change requests cascade; users keep their account
+			if err := tx.Exec("UPDATE branches SET parent_branch_id = NULL WHERE id IN ?", branchIDs).Error; err != nil {
+				return err
+			}
+			deleted := tx.Exec("DELETE FROM branches WHERE id IN ?", branchIDs)
+			if deleted.Error != nil {
+				return fmt.Errorf("deleting sandbox branches: %w", deleted.Error)
+			}
+			result.Branches = deleted.RowsAffected
+		}
+		result.StorageObjects = len(keys)
+
+		now := time.Now()
+		for _, id := range branchIDs {
+			entry := actor.auditEntry(AuditEntityBranch, id, auditActionSandboxWipe, models.JSONB{
+				"events":         eventsPerBranch[id],
+				"branch_deleted": opts.IncludeBranches,
+			}, now)
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errSandboxDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSandboxDryRun) {
+		return nil, err
+	}
+	if !opts.DryRun && len(keys) > 0 {
+		QueueStorageCleanup(ctx, time.Now(), keys...)
+	}
+	return result, nil
+}
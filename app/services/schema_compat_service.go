@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Migrations (package migrations) are applied at startup, or during deploys with
+// "djjsctl db-migrate" when MIGRATE_ON_STARTUP=false, so a new binary can still meet a
+// database that lacks the columns it queries and answer every request touching them with a 500.
+// VerifySchema runs at startup and compares the database with the migrations this binary
+// needs, recognized by the tables and columns they create. What happens on a mismatch is
+// chosen with SCHEMA_CHECK:
+//
+//   - enforce (default): the API refuses to start and logs the migrations to apply
+//   - read-only: the API starts but rejects writes with 503 (middleware.SchemaReadOnly) and
+//     runs no backfills, schedulers or job workers
+//   - warn: the mismatch is only logged
+//   - off: no check
+
+// Schema check modes (SCHEMA_CHECK)
+const (
+	SchemaCheckEnforce  = "enforce"
+	SchemaCheckReadOnly = "read-only"
+	SchemaCheckWarn     = "warn"
+	SchemaCheckOff      = "off"
+)
+
+// SchemaRequirement is a migration this binary depends on and the columns that show it ran.
+// Migrations that only add indexes or triggers cannot be recognized and are not listed.
+type SchemaRequirement struct {
+	Migration string              // migration file, or section of migrations/00001_baseline.sql
+	Columns   map[string][]string // table -> columns
+}
+
+// requiredSchema lists the migrations the code relies on. Add the migration of a new table
+// or column here in the same change that starts reading it.
+var requiredSchema = []SchemaRequirement{
+	{"001_create_auth_tables.sql", map[string][]string{
+		"users":                 {"email_verified_at", "disabled_at"},
+		"sessions":              {"id", "refresh_token_hash", "revoked_at", "expires_at"},
+		"verification_tokens":   {"token_hash", "expires_at", "used_at"},
+		"password_reset_tokens": {"token_hash", "expires_at", "used_at"},
+		"auth_audit_events":     {"type", "metadata"},
+	}},
+	{"add_password_policy.sql", map[string][]string{
+		"users":            {"must_change_password", "password_changed_at"},
+		"password_history": {"user_id"},
+	}},
+	{"add_two_factor_auth.sql", map[string][]string{
+		"users":               {"totp_secret", "totp_enabled_at", "totp_last_step"},
+		"user_recovery_codes": {"user_id"},
+		"totp_challenges":     {"token_hash", "expires_at"},
+	}},
+	{"add_user_deactivation.sql", map[string][]string{"users": {"tokens_revoked_at"}}},
+	{"add_user_digest_preferences.sql", map[string][]string{"users": {"digest_frequency", "digest_sent_on"}}},
+	{"create_persons_table.sql", map[string][]string{
+		"persons":       {"id"},
+		"users":         {"person_id"},
+		"branch_member": {"person_id"},
+		"volunteers":    {"person_id"},
+		"donations":     {"person_id"},
+	}},
+	{"create_branch_change_requests_table.sql", map[string][]string{
+		"users":                  {"branch_id", "region_id"},
+		"branch_change_requests": {"id"},
+	}},
+	{"add_row_versions.sql", map[string][]string{
+		"branches":      {"version"},
+		"event_details": {"version"},
+		"users":         {"version"},
+	}},
+	{"add_soft_delete_columns.sql", map[string][]string{
+		"branches":      {"deleted_at"},
+		"event_details": {"deleted_at"},
+		"volunteers":    {"deleted_at"},
+		"donations":     {"deleted_at"},
+		"branch_media":  {"deleted_at"},
+	}},
+	{"add_branch_images.sql", map[string][]string{"branches": {"cover_media_id", "coordinator_photo_media_id"}}},
+	{"add_branch_locations.sql", map[string][]string{"branches": {"latitude", "longitude", "public_id"}}},
+	{"add_branch_nearby.sql", map[string][]string{"branches": {"location_source"}}},
+	{"add_sandbox_branches.sql", map[string][]string{"branches": {"is_sandbox"}}},
+	{"add_city_districts.sql", map[string][]string{"cities": {"district_id"}}},
+	{"add_denormalized_counters.sql", map[string][]string{
+		"branches":      {"media_count", "member_count", "event_count", "last_activity_on"},
+		"event_details": {"media_count", "special_guest_count", "volunteer_count", "donation_count", "promotion_material_count", "last_activity_on"},
+	}},
+	{"add_event_status.sql", map[string][]string{"event_details": {"status"}}},
+	{"add_event_approval_workflow.sql", map[string][]string{
+		"event_details":        {"approval_status"},
+		"event_status_history": {"event_id"},
+	}},
+	{"add_event_donation_total.sql", map[string][]string{"event_details": {"donation_total"}}},
+	{"add_declared_donation_total.sql", map[string][]string{"event_details": {"declared_donation_total"}}},
+	{"create_sequence_counters.sql", map[string][]string{
+		"sequence_counters": {"scope", "financial_year", "last_value"},
+		"donations":         {"receipt_number"},
+		"event_details":     {"report_number"},
+		"branch_member":     {"membership_id"},
+	}},
+	{"add_financial_year_archival.sql", map[string][]string{
+		"archived_financial_years": {"financial_year"},
+		"event_details":            {"archived"},
+		"event_media":              {"archived"},
+		"special_guests":           {"archived"},
+		"volunteers":               {"archived"},
+		"donations":                {"archived"},
+	}},
+	{"create_event_drafts_table.sql", map[string][]string{"event_drafts": {"id"}}},
+	{"add_event_draft_versions.sql", map[string][]string{"event_drafts": {"user_email", "donations_draft", "version"}}},
+	{"create_event_recurrences_table.sql", map[string][]string{"event_recurrences": {"id"}}},
+	{"add_event_media_gallery_fields.sql", map[string][]string{"event_media": {"category", "caption", "sort_order"}}},
+	{"add_media_thumbnails.sql", map[string][]string{
+		"event_media":  {"thumbnail_s3_key", "thumbnail_medium_s3_key"},
+		"branch_media": {"thumbnail_s3_key", "thumbnail_medium_s3_key"},
+	}},
+	{"add_media_phash.sql", map[string][]string{"event_media": {"phash"}, "branch_media": {"phash"}}},
+	{"add_media_scan_status.sql", map[string][]string{
+		"event_media":  {"scan_status", "scan_detail", "scanned_on"},
+		"branch_media": {"scan_status", "scan_detail", "scanned_on"},
+	}},
+	{"add_ocr_text_columns.sql", map[string][]string{
+		"donations":   {"remarks", "receipt_s3_key", "ocr_text"},
+		"event_media": {"ocr_text"},
+	}},
+	{"add_media_transcoding.sql", map[string][]string{
+		"event_media":  {"transcode_status", "transcode_detail", "transcoded_s3_key"},
+		"branch_media": {"transcode_status", "transcode_detail", "transcoded_s3_key"},
+	}},
+	{"add_public_assets.sql", map[string][]string{
+		"event_media":   {"is_public"},
+		"public_assets": {"id"},
+	}},
+	{"add_name_keys.sql", map[string][]string{
+		"volunteers":     {"name_key"},
+		"special_guests": {"name_key"},
+		"donations":      {"donor_name", "donor_name_key"},
+	}},
+	{"add_donation_receipt_void.sql", map[string][]string{"donations": {"voided_on", "voided_by", "void_reason"}}},
+	{"create_guests_table.sql", map[string][]string{
+		"guests":         {"id"},
+		"special_guests": {"guest_id", "letter_sent_on", "feedback_received_on", "feedback"},
+	}},
+	{"create_audit_logs_table.sql", map[string][]string{"audit_logs": {"id"}}},
+	{"create_feature_flags_table.sql", map[string][]string{"feature_flags": {"key"}}},
+	{"create_jobs_table.sql", map[string][]string{"jobs": {"id", "status", "run_at"}}},
+	{"create_api_usage_daily_table.sql", map[string][]string{"api_usage_daily": {"day"}}},
+	{"create_notification_logs_table.sql", map[string][]string{"notification_logs": {"id"}}},
+	{"create_media_manifests_table.sql", map[string][]string{"media_manifests": {"id"}}},
+	{"create_media_tombstones_and_legal_holds.sql", map[string][]string{
+		"media_tombstones": {"id"},
+		"legal_holds":      {"id"},
+	}},
+	{"create_coordinator_handovers_table.sql", map[string][]string{"coordinator_handovers": {"id"}}},
+	{"create_stats_snapshots_table.sql", map[string][]string{"stats_snapshots": {"id"}}},
+	{"create_upload_sessions_table.sql", map[string][]string{"upload_sessions": {"id", "received_bytes", "storage_upload_id", "parts"}}},
+	{"create_branch_storage_usage_tables.sql", map[string][]string{
+		"branch_storage_usage":  {"branch_id", "category", "bytes"},
+		"branch_storage_quotas": {"branch_id", "quota_bytes"},
+	}},
+	{"create_webhook_subscriptions_table.sql", map[string][]string{"webhook_subscriptions": {"id"}}},
+	{"00003_event_attendance.sql", map[string][]string{"event_attendance": {"id", "new_attendees"}}},
+	{"00004_webhook_deliveries.sql", map[string][]string{"webhook_deliveries": {"id", "delivery_id", "next_attempt_on"}}},
+	{"00005_notifications.sql", map[string][]string{"notifications": {"id", "dedup_key", "read_on"}}},
+	{"00006_user_notification_channel.sql", map[string][]string{"users": {"notification_channel"}}},
+}
+
+// SchemaGap is a required migration whose tables or columns are missing
+type SchemaGap struct {
+	Migration string   `json:"migration"`
+	Missing   []string `json:"missing"` // "table.column", or "table" when the table is missing
+}
+
+// SchemaMismatchError reports the migrations the database lacks
+type SchemaMismatchError struct {
+	Gaps []SchemaGap
+}
+
+func (e *SchemaMismatchError) Error() string {
+	parts := make([]string, len(e.Gaps))
+	for i, gap := range e.Gaps {
+		parts[i] = fmt.Sprintf("%s (missing %s)", gap.Migration, strings.Join(gap.Missing, ", "))
+	}
+	return "database schema is behind this binary, apply the migrations (djjsctl db-migrate): " + strings.Join(parts, "; ")
+}
+
+// schemaReadOnlyReason is set while the API serves read-only because of a schema mismatch
+var schemaReadOnlyReason atomic.Pointer[string]
+
+// SchemaReadOnly returns why the API is read-only, or "" when writes are allowed
+func SchemaReadOnly() string {
+	if reason := schemaReadOnlyReason.Load(); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// CheckSchema compares the database with requiredSchema and returns a *SchemaMismatchError
+// listing the migrations that have not been applied
+func CheckSchema(ctx context.Context) error {
+	tables := make([]string, 0, len(requiredSchema))
+	seen := map[string]bool{}
+	for _, requirement := range requiredSchema {
+		for table := range requirement.Columns {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	var rows []struct {
+		TableName  string
+		ColumnName string
+	}
+	if err := config.DB.WithContext(ctx).Raw(
+		`SELECT table_name, column_name FROM information_schema.columns
+		 WHERE table_schema = current_schema() AND table_name IN ?`, tables,
+	).Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to read the database schema: %w", err)
+	}
+	existing := map[string]map[string]bool{}
+	for _, row := range rows {
+		if existing[row.TableName] == nil {
+			existing[row.TableName] = map[string]bool{}
+		}
+		existing[row.TableName][row.ColumnName] = true
+	}
+
+	var mismatch SchemaMismatchError
+	for _, requirement := range requiredSchema {
+		var missing []string
+		for table, columns := range requirement.Columns {
+			if existing[table] == nil {
+				missing = append(missing, table)
+				continue
+			}
+			for _, column := range columns {
+				if !existing[table][column] {
+					missing = append(missing, table+"."+column)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			mismatch.Gaps = append(mismatch.Gaps, SchemaGap{Migration: requirement.Migration, Missing: missing})
+		}
+	}
+	if len(mismatch.Gaps) > 0 {
+		return &mismatch
+	}
+	return nil
+}
+
+// VerifySchema runs the startup schema check in the given mode (SCHEMA_CHECK, see
+// config.DatabaseConfig). It only returns an error when the API must not start.
+func VerifySchema(ctx context.Context, mode string) error {
+	if mode == SchemaCheckOff {
+		return nil
+	}
+	err := CheckSchema(ctx)
+	var mismatch *SchemaMismatchError
+	if err == nil || !errors.As(err, &mismatch) {
+		return err
+	}
+
+	logger := utils.BaseLogger()
+	for _, gap := range mismatch.Gaps {
+		logger.Error("Required migration not applied", zap.String("migration", gap.Migration), zap.Strings("missing", gap.Missing))
+	}
+	switch mode {
+	case SchemaCheckReadOnly:
+		reason := mismatch.Error()
+		schemaReadOnlyReason.Store(&reason)
+		logger.Error("Serving read-only until the migrations are applied and the API is restarted (SCHEMA_CHECK=read-only)")
+		return nil
+	case SchemaCheckWarn:
+		logger.Warn("Serving with an incompatible schema (SCHEMA_CHECK=warn), requests using the missing columns will fail")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Expand/contract (blue/green safe) schema migrations.
+//
+// A column change ships in phases so old and new application versions can run side by side:
+//
+//  1. expand     - add the new column (nullable, nothing reads it yet)
+//  2. dual-write - install a trigger that keeps the new column in sync on every insert/update,
+//     including writes from instances that do not know about the new column
+//  3. backfill   - fill the new column for existing rows in small batches
+//  4. cutover    - turn on the read_new flag; code reads the new column via ReadColumn
+//  5. contract   - once every instance reads the new column, drop the trigger and the old column
+//
+// Each phase is recorded as a feature flag "migration.<name>.<phase>", so the state is shared
+// by every instance and visible at /api/admin/feature-flags. Migrations are registered in code
+// with RegisterExpandContractMigration and driven through /api/admin/migrations.
+
+// Expand/contract phases
+const (
+	MigrationPhaseExpand    = "expand"
+	MigrationPhaseDualWrite = "dual_write"
+	MigrationPhaseBackfill  = "backfill"
+	MigrationPhaseCutover   = "read_new"
+	MigrationPhaseContract  = "contract"
+)
+
+const defaultMigrationBatchSize = 1000
+
+var (
+	ErrMigrationNotFound     = errors.New("migration not found")
+	ErrMigrationPhaseOrder   = errors.New("migration phase cannot run yet")
+	ErrMigrationBackfillBusy = errors.New("backfill already running")
+)
+
+var sqlIdentifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ExpandContractMigration describes moving data from OldColumn to NewColumn on Table
+type ExpandContractMigration struct {
+	Name        string // short identifier, used in flag keys and trigger names
+	Description string
+	Table       string
+	OldColumn   string
+	NewColumn   string
+	ColumnType  string // SQL type of the new column, e.g. "TEXT" or "VARCHAR(20)"
+	// Expression computing the new value from a row's columns, e.g. "lower(trim(name))".
+	// Used by both the dual-write trigger and the backfill. Defaults to OldColumn.
+	Expression string
+	BatchSize  int           // rows per backfill batch, default 1000
+	BatchPause time.Duration // pause between backfill batches to limit lock and replication pressure
+}
+
+// MigrationBackfillStatus is the progress of a running or finished backfill
+type MigrationBackfillStatus struct {
+	Running    bool       `json:"running"`
+	Updated    int64      `json:"updated"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// MigrationStatus is the current state of an expand/contract migration
+type MigrationStatus struct {
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description,omitempty"`
+	Table           string                  `json:"table"`
+	OldColumn       string                  `json:"old_column"`
+	NewColumn       string                  `json:"new_column"`
+	Phases          map[string]bool         `json:"phases"`
+	NewColumnExists bool                    `json:"new_column_exists"`
+	OldColumnExists bool                    `json:"old_column_exists"`
+	TriggerExists   bool                    `json:"trigger_exists"`
+	PendingRows     int64                   `json:"pending_rows"` // rows the backfill still has to touch
+	Backfill        MigrationBackfillStatus `json:"backfill"`
+}
+
+var (
+	expandContractMigrations = map[string]*ExpandContractMigration{}
+
+	migrationBackfills   = map[string]*MigrationBackfillStatus{}
+	migrationBackfillsMu sync.Mutex
+)
+
+// RegisterExpandContractMigration makes a migration available to the admin endpoints.
+// Call it from an init function next to the code that will read the new column.
+func RegisterExpandContractMigration(m ExpandContractMigration) {
+	for _, ident := range []string{m.Name, m.Table, m.OldColumn, m.NewColumn} {
+		if !sqlIdentifierPattern.MatchString(ident) {
+			panic("expand/contract migration: invalid identifier " + ident)
+		}
+	}
+	if m.ColumnType == "" {
+		panic("expand/contract migration " + m.Name + ": ColumnType is required")
+	}
+	if m.Expression == "" {
+		m.Expression = m.OldColumn
+	}
+	if m.BatchSize <= 0 {
+		m.BatchSize = defaultMigrationBatchSize
+	}
+	expandContractMigrations[m.Name] = &m
+}
+
+// GetExpandContractMigration returns a registered migration by name
+func GetExpandContractMigration(name string) (*ExpandContractMigration, error) {
+	m, ok := expandContractMigrations[name]
+	if !ok {
+		return nil, ErrMigrationNotFound
+	}
+	return m, nil
+}
+
+// ListExpandContractMigrations returns the status of every registered migration
+func ListExpandContractMigrations() ([]MigrationStatus, error) {
+	names := make([]string, 0, len(expandContractMigrations))
+	for name := range expandContractMigrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]MigrationStatus, 0, len(names))
+	for _, name := range names {
+		status, err := expandContractMigrations[name].Status()
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// FlagKey is the feature flag recording that a phase has been reached
+func (m *ExpandContractMigration) FlagKey(phase string) string {
+	return "migration." + m.Name + "." + phase
+}
+
+// ReadNew reports whether reads should use the new column
+func (m *ExpandContractMigration) ReadNew() bool {
+	return IsFeatureEnabled(m.FlagKey(MigrationPhaseCutover))
+}
+
+// ReadColumn returns the column application code should read right now
+func (m *ExpandContractMigration) ReadColumn() string {
+	if m.ReadNew() {
+		return m.NewColumn
+	}
+	return m.OldColumn
+}
+
+func (m *ExpandContractMigration) triggerName() string {
+	return "trg_" + m.Name + "_dual_write"
+}
+
+func (m *ExpandContractMigration) functionName() string {
+	return m.Name + "_dual_write"
+}
+
+// Status inspects the schema and flags of the migration
+func (m *ExpandContractMigration) Status() (*MigrationStatus, error) {
+	status := &MigrationStatus{
+		Name:        m.Name,
+		Description: m.Description,
+		Table:       m.Table,
+		OldColumn:   m.OldColumn,
+		NewColumn:   m.NewColumn,
+		Phases:      map[string]bool{},
+	}
+	for _, phase := range []string{MigrationPhaseExpand, MigrationPhaseDualWrite, MigrationPhaseBackfill, MigrationPhaseCutover, MigrationPhaseContract} {
+		status.Phases[phase] = IsFeatureEnabled(m.FlagKey(phase))
+	}
+
+	migrator := config.DB.Migrator()
+	status.NewColumnExists = migrator.HasColumn(m.Table, m.NewColumn)
+	status.OldColumnExists = migrator.HasColumn(m.Table, m.OldColumn)
+
+	if err := config.DB.Raw(
+		"SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = ? AND tgrelid = ?::regclass)",
+		m.triggerName(), m.Table,
+	).Scan(&status.TriggerExists).Error; err != nil {
+		return nil, err
+	}
+
+	if status.NewColumnExists && status.OldColumnExists {
+		pending, err := m.pendingRows(config.DB)
+		if err != nil {
+			return nil, err
+		}
+		status.PendingRows = pending
+	}
+
+	migrationBackfillsMu.Lock()
+	if backfill, ok := migrationBackfills[m.Name]; ok {
+		status.Backfill = *backfill
+	}
+	migrationBackfillsMu.Unlock()
+
+	return status, nil
+}
+
+// pendingWhere matches rows whose new column is not yet in sync with the expression
+func (m *ExpandContractMigration) pendingWhere() string {
+	return fmt.Sprintf("%s IS DISTINCT FROM (%s)", m.NewColumn, m.Expression)
+}
+
+func (m *ExpandContractMigration) pendingRows(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Table(m.Table).Where(m.pendingWhere()).Count(&count).Error
+	return count, err
+}
+
+// Expand adds the new column. It is nullable so running instances are unaffected.
+func (m *ExpandContractMigration) Expand(actor AuditActor) error {
+	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", m.Table, m.NewColumn, m.ColumnType)
+	if err := config.DB.Exec(sql).Error; err != nil {
+		return err
+	}
+	return m.setPhase(MigrationPhaseExpand, true, actor)
+}
+
+// EnableDualWrite installs a trigger that recomputes the new column on every write.
+// Being a database trigger, it also covers writes from instances running the old code.
+func (m *ExpandContractMigration) EnableDualWrite(actor AuditActor) error {
+	if !IsFeatureEnabled(m.FlagKey(MigrationPhaseExpand)) {
+		return fmt.Errorf("%w: run expand first", ErrMigrationPhaseOrder)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		function := fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$
+BEGIN
+    SELECT (%s) INTO NEW.%s FROM (SELECT NEW.*) AS src;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`, m.functionName(), m.Expression, m.NewColumn)
+		if err := tx.Exec(function).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", m.triggerName(), m.Table)).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf(
+			"CREATE TRIGGER %s BEFORE INSERT OR UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+			m.triggerName(), m.Table, m.functionName(),
+		)).Error
+	})
+	if err != nil {
+		return err
+	}
+	return m.setPhase(MigrationPhaseDualWrite, true, actor)
+}
+
+// StartBackfill fills the new column for existing rows in the background.
+// Batches are small, separate statements, so the backfill can be stopped and resumed safely.
+func (m *ExpandContractMigration) StartBackfill(actor AuditActor) error {
+	if !IsFeatureEnabled(m.FlagKey(MigrationPhaseDualWrite)) {
+		// without dual-write, rows written during the backfill would be missed
+		return fmt.Errorf("%w: enable dual-write first", ErrMigrationPhaseOrder)
+	}
+
+	migrationBackfillsMu.Lock()
+	if current, ok := migrationBackfills[m.Name]; ok && current.Running {
+		migrationBackfillsMu.Unlock()
+		return ErrMigrationBackfillBusy
+	}
+	now := time.Now()
+	status := &MigrationBackfillStatus{Running: true, StartedAt: &now}
+	migrationBackfills[m.Name] = status
+	migrationBackfillsMu.Unlock()
+
+	go m.runBackfill(status, actor)
+	return nil
+}
+
+func (m *ExpandContractMigration) runBackfill(status *MigrationBackfillStatus, actor AuditActor) {
+	ctx := context.Background()
+	batch := fmt.Sprintf(
+		"UPDATE %[1]s SET %[2]s = (%[3]s) WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[4]s LIMIT %[5]d)",
+		m.Table, m.NewColumn, m.Expression, m.pendingWhere(), m.BatchSize,
+	)
+
+	var err error
+	for {
+		result := config.DB.WithContext(ctx).Exec(batch)
+		if result.Error != nil {
+			err = result.Error
+			break
+		}
+
+		migrationBackfillsMu.Lock()
+		status.Updated += result.RowsAffected
+		migrationBackfillsMu.Unlock()
+
+		if result.RowsAffected < int64(m.BatchSize) {
+			break
+		}
+		time.Sleep(m.BatchPause)
+	}
+
+	if err == nil {
+		err = m.setPhase(MigrationPhaseBackfill, true, actor)
+	}
+
+	migrationBackfillsMu.Lock()
+	finished := time.Now()
+	status.Running = false
+	status.FinishedAt = &finished
+	if err != nil {
+		status.Error = err.Error()
+	}
+	migrationBackfillsMu.Unlock()
+
+	if err != nil {
+		utils.BaseLogger().Error("Migration backfill failed", zap.String("migration", m.Name), zap.Error(err))
+	} else {
+		utils.BaseLogger().Info("Migration backfill finished", zap.String("migration", m.Name), zap.Int64("rows", status.Updated))
+	}
+}
+
+// Cutover switches reads to the new column. It is refused while rows are still out of sync.
+func (m *ExpandContractMigration) Cutover(actor AuditActor) error {
+	if !IsFeatureEnabled(m.FlagKey(MigrationPhaseBackfill)) {
+		return fmt.Errorf("%w: backfill has not completed", ErrMigrationPhaseOrder)
+	}
+	pending, err := m.pendingRows(config.DB)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d rows are not backfilled", ErrMigrationPhaseOrder, pending)
+	}
+	return m.setPhase(MigrationPhaseCutover, true, actor)
+}
+
+// Rollback switches reads back to the old column. The dual-write trigger keeps both in sync,
+// so this is safe at any point before contract.
+func (m *ExpandContractMigration) Rollback(actor AuditActor) error {
+	if IsFeatureEnabled(m.FlagKey(MigrationPhaseContract)) {
+		return fmt.Errorf("%w: the old column has already been dropped", ErrMigrationPhaseOrder)
+	}
+	return m.setPhase(MigrationPhaseCutover, false, actor)
+}
+
+// Contract drops the dual-write trigger and the old column. Only run it once every
+// deployed version reads the new column; this step cannot be undone.
+func (m *ExpandContractMigration) Contract(actor AuditActor) error {
+	if !IsFeatureEnabled(m.FlagKey(MigrationPhaseCutover)) {
+		return fmt.Errorf("%w: cut over to the new column first", ErrMigrationPhaseOrder)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", m.triggerName(), m.Table),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", m.functionName()),
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", m.Table, m.OldColumn),
+		} {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return m.setPhase(MigrationPhaseContract, true, actor)
+}
+
+func (m *ExpandContractMigration) setPhase(phase string, enabled bool, actor AuditActor) error {
+	description := fmt.Sprintf("Expand/contract migration %s: %s phase", m.Name, phase)
+	_, err := SetFeatureFlag(m.FlagKey(phase), enabled, description, actor)
+	return err
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSearch is returned for queries without any searchable word or unknown result types
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Search result types (the "types" filter of GET /api/search)
+const (
+	SearchTypeEvents        = "events"
+	SearchTypeBranches      = "branches"
+	SearchTypeSpecialGuests = "special_guests"
+	SearchTypeVolunteers    = "volunteers"
+)
+
+// SearchTypes lists every searchable result type
+var SearchTypes = []string{SearchTypeEvents, SearchTypeBranches, SearchTypeSpecialGuests, SearchTypeVolunteers}
+
+// Full-text documents per entity. The expressions must match the GIN indexes in
+// migrations/00001_baseline.sql (add_search_indexes), otherwise Postgres falls back to a sequential scan.
+// The event, special guest and volunteer indexes only cover rows of years that are not
+// archived (add_financial_year_archival.sql), so those searches need "NOT archived" to use them.
+const (
+	searchEventDocument     = "to_tsvector('simple', coalesce(e.theme, '') || ' ' || coalesce(e.spiritual_orator, '') || ' ' || coalesce(e.city, ''))"
+	searchBranchDocument    = "to_tsvector('simple', coalesce(b.name, '') || ' ' || coalesce(b.coordinator_name, ''))"
+	searchGuestDocument     = "to_tsvector('simple', coalesce(g.first_name, '') || ' ' || coalesce(g.middle_name, '') || ' ' || coalesce(g.last_name, '') || ' ' || coalesce(g.organization, '') || ' ' || coalesce(g.designation, ''))"
+	searchVolunteerDocument = "to_tsvector('simple', coalesce(v.volunteer_name, ''))"
+)
+
+// archivedAlias is the table alias carrying the archived flag per result type
+var archivedAlias = map[string]string{
+	SearchTypeEvents:        "e",
+	SearchTypeSpecialGuests: "g",
+	SearchTypeVolunteers:    "v",
+}
+
+// SearchHit is one search result
+type SearchHit struct {
+	Type     string  `json:"type"` // event, branch, special_guest, volunteer
+	ID       uint    `json:"id"`
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle,omitempty"`
+	EventID  *uint   `json:"event_id,omitempty"`
+	BranchID *uint   `json:"branch_id,omitempty"`
+	Rank     float64 `json:"rank"`
+}
+
+// SearchResults groups the hits by type, best matches first
+type SearchResults struct {
+	Query         string      `json:"query"`
+	Events        []SearchHit `json:"events"`
+	Branches      []SearchHit `json:"branches"`
+	SpecialGuests []SearchHit `json:"special_guests"`
+	Volunteers    []SearchHit `json:"volunteers"`
+	Total         int         `json:"total"`
+}
+
+// SearchOptions narrows a search
+type SearchOptions struct {
+	Types []string // empty for all of SearchTypes
+	Limit int      // per type, default 10, max 50
+	// IncludeArchived also searches events, special guests and volunteers of archived
+	// financial years. These are not in the full-text indexes, so the search is slower.
+	IncludeArchived bool
+	// Scope limits the hits to the user's branches (nil for everything)
+	Scope *BranchScope
+}
+
+// Search runs a prefix full-text search over event themes, orators and cities, branch and
+// coordinator names, special guests and volunteers. Every word must match ("vikas del"
+// finds "Vikas Delhi"). Guest and volunteer names also match across scripts via utils.NameKey.
+// Archived financial years are left out unless opts.IncludeArchived is set.
+func Search(q string, opts SearchOptions) (*SearchResults, error) {
+	tsQuery := searchTSQuery(q)
+	if tsQuery == "" {
+		return nil, fmt.Errorf("%w: q must contain at least one letter or digit", ErrInvalidSearch)
+	}
+	types := opts.Types
+	if len(types) == 0 {
+		types = SearchTypes
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	results := &SearchResults{
+		Query:         strings.TrimSpace(q),
+		Events:        []SearchHit{},
+		Branches:      []SearchHit{},
+		SpecialGuests: []SearchHit{},
+		Volunteers:    []SearchHit{},
+	}
+	nameKey := utils.NameKey(q)
+	for _, t := range types {
+		var (
+			hits *[]SearchHit
+			db   *gorm.DB
+		)
+		switch t {
+		case SearchTypeEvents:
+			hits = &results.Events
+			db = config.DB.Table("event_details e").
+				Select(`'event' AS type, e.id, COALESCE(NULLIF(e.theme, ''), e.report_number, 'Event') AS title,
+					CONCAT_WS(' · ', NULLIF(e.spiritual_orator, ''), NULLIF(e.city, ''), to_char(e.start_date, 'YYYY-MM-DD')) AS subtitle,
+					e.id AS event_id, e.branch_id, ts_rank(`+searchEventDocument+`, to_tsquery('simple', ?)) AS rank`, tsQuery).
+				Where("e.deleted_at IS NULL AND "+searchEventDocument+" @@ to_tsquery('simple', ?)", tsQuery)
+		case SearchTypeBranches:
+			hits = &results.Branches
+			db = config.DB.Table("branches b").
+				Select(`CASE WHEN b.parent_branch_id IS NULL THEN 'branch' ELSE 'child_branch' END AS type, b.id, b.name AS title,
+					b.coordinator_name AS subtitle, b.id AS branch_id, ts_rank(`+searchBranchDocument+`, to_tsquery('simple', ?)) AS rank`, tsQuery).
+				Where("b.deleted_at IS NULL AND "+searchBranchDocument+" @@ to_tsquery('simple', ?)", tsQuery)
+		case SearchTypeSpecialGuests:
+			hits = &results.SpecialGuests
+			match, args := searchGuestDocument+" @@ to_tsquery('simple', ?)", []interface{}{tsQuery}
+			if nameKey != "" {
+				match, args = "("+match+" OR g.name_key LIKE ?)", append(args, "%"+nameKey+"%")
+			}
+			db = config.DB.Table("special_guests g").
+				Joins("JOIN event_details e ON e.id = g.event_id AND e.deleted_at IS NULL").
+				Select(`'special_guest' AS type, g.id,
+					CONCAT_WS(' ', NULLIF(g.prefix, ''), NULLIF(g.first_name, ''), NULLIF(g.middle_name, ''), NULLIF(g.last_name, '')) AS title,
+					CONCAT_WS(', ', NULLIF(g.designation, ''), NULLIF(g.organization, '')) AS subtitle,
+					g.event_id, e.branch_id, ts_rank(`+searchGuestDocument+`, to_tsquery('simple', ?)) AS rank`, tsQuery).
+				Where(match, args...)
+		case SearchTypeVolunteers:
+			hits = &results.Volunteers
+			match, args := searchVolunteerDocument+" @@ to_tsquery('simple', ?)", []interface{}{tsQuery}
+			if nameKey != "" {
+				match, args = "("+match+" OR v.name_key LIKE ?)", append(args, "%"+nameKey+"%")
+			}
+			db = config.DB.Table("volunteers v").
+				Joins("JOIN event_details e ON e.id = v.event_id AND e.deleted_at IS NULL").
+				Select(`'volunteer' AS type, v.id, v.volunteer_name AS title, NULLIF(v.seva_involved, '') AS subtitle,
+					v.event_id, v.branch_id, ts_rank(`+searchVolunteerDocument+`, to_tsquery('simple', ?)) AS rank`, tsQuery).
+				Where("v.deleted_at IS NULL").
+				Where(match, args...)
+		default:
+			return nil, fmt.Errorf("%w: unknown type %q (use %s)", ErrInvalidSearch, t, strings.Join(SearchTypes, ", "))
+		}
+
+		if !opts.IncludeArchived && t != SearchTypeBranches {
+			db = db.Where("NOT " + archivedAlias[t] + ".archived")
+		}
+		if t == SearchTypeBranches {
+			db = opts.Scope.Apply(db, "b.id")
+		} else {
+			db = opts.Scope.Apply(db, "e.branch_id")
+		}
+		if err := db.Order("rank DESC, id").Limit(limit).Scan(hits).Error; err != nil {
+			return nil, err
+		}
+		results.Total += len(*hits)
+	}
+	return results, nil
+}
+
+// searchTSQuery turns free text into a prefix tsquery: "Vikas  del!" -> "vikas:* & del:*".
+// Everything but letters, marks and digits is dropped so user input cannot break the syntax.
+func searchTSQuery(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
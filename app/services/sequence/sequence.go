@@ -0,0 +1,89 @@
+// Package sequence allocates gap-free, per-scope, per-financial-year numbers
+// (donation receipts, event report numbers, membership IDs).
+package sequence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Well-known scopes
+const (
+	ScopeDonationReceipt = "donation_receipt"
+	ScopeEventReport     = "event_report"
+	ScopeMembership      = "membership"
+)
+
+// FinancialYear returns the Indian financial year (April–March) containing t, e.g. "2025-26"
+func FinancialYear(t time.Time) string {
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// FinancialYearRange returns the first and last day of a financial year written as by
+// FinancialYear, e.g. "2025-26" -> 2025-04-01, 2026-03-31
+func FinancialYearRange(financialYear string) (time.Time, time.Time, error) {
+	var start int
+	fmt.Sscanf(financialYear, "%4d", &start)
+	first := time.Date(start, time.April, 1, 0, 0, 0, 0, time.UTC)
+	if start < 1000 || FinancialYear(first) != financialYear {
+		return time.Time{}, time.Time{}, fmt.Errorf("financial year %q must look like 2025-26", financialYear)
+	}
+	return first, first.AddDate(1, 0, -1), nil
+}
+
+// Next allocates the next value for scope/financialYear.
+//
+// The counter row is updated with INSERT ... ON CONFLICT DO UPDATE, which takes a row
+// lock held until the surrounding transaction ends. Concurrent callers therefore
+// serialize on the counter, and if the caller's transaction rolls back the increment
+// is rolled back with it, so numbers are never duplicated or skipped. Always call Next
+// inside the same transaction that stores the number.
+func Next(tx *gorm.DB, scope, financialYear string) (int64, error) {
+	if scope == "" || financialYear == "" {
+		return 0, errors.New("sequence scope and financial year are required")
+	}
+
+	var value int64
+	err := tx.Raw(`
+		INSERT INTO sequence_counters (scope, financial_year, last_value, updated_on)
+		VALUES (?, ?, 1, NOW())
+		ON CONFLICT (scope, financial_year)
+		DO UPDATE SET last_value = sequence_counters.last_value + 1, updated_on = NOW()
+		RETURNING last_value`,
+		scope, financialYear,
+	).Scan(&value).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %s sequence: %w", scope, err)
+	}
+
+	return value, nil
+}
+
+// Current returns the last allocated value for scope/financialYear (0 if none)
+func Current(db *gorm.DB, scope, financialYear string) (int64, error) {
+	var value int64
+	err := db.Raw(
+		`SELECT COALESCE(MAX(last_value), 0) FROM sequence_counters WHERE scope = ? AND financial_year = ?`,
+		scope, financialYear,
+	).Scan(&value).Error
+	return value, err
+}
+
+// NextNumber allocates the next value and formats it as "<prefix>/<financial year>/<000001>"
+func NextNumber(tx *gorm.DB, scope, prefix string, at time.Time) (string, error) {
+	financialYear := FinancialYear(at)
+
+	value, err := Next(tx, scope, financialYear)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s/%06d", prefix, financialYear, value), nil
+}
@@ -0,0 +1,68 @@
+package sequence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinancialYear(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2026, time.March, 31, 23, 59, 59, 999999999, time.UTC), "2025-26"},
+		{time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), "2026-27"},
+		{time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), "2025-26"},
+		{time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC), "2025-26"},
+		// The year follows the calendar date in t's own location
+		{time.Date(2026, time.April, 1, 0, 30, 0, 0, ist), "2026-27"},
+		{time.Date(2026, time.March, 31, 23, 59, 0, 0, ist), "2025-26"},
+		{time.Date(2026, time.April, 1, 0, 30, 0, 0, ist).UTC(), "2025-26"},
+		// Century rollover
+		{time.Date(2100, time.March, 31, 12, 0, 0, 0, time.UTC), "2099-00"},
+		{time.Date(2100, time.April, 1, 12, 0, 0, 0, time.UTC), "2100-01"},
+	}
+	for _, tt := range tests {
+		if got := FinancialYear(tt.at); got != tt.want {
+			t.Errorf("FinancialYear(%s) = %q, want %q", tt.at.Format(time.RFC3339Nano), got, tt.want)
+		}
+	}
+}
+
+func TestFinancialYearRange(t *testing.T) {
+	tests := []struct {
+		financialYear string
+		first, last   string
+	}{
+		{"2025-26", "2025-04-01", "2026-03-31"},
+		{"2023-24", "2023-04-01", "2024-03-31"}, // ends before a leap day
+		{"2099-00", "2099-04-01", "2100-03-31"},
+	}
+	for _, tt := range tests {
+		first, last, err := FinancialYearRange(tt.financialYear)
+		if err != nil {
+			t.Errorf("FinancialYearRange(%q): %v", tt.financialYear, err)
+			continue
+		}
+		if got := first.Format("2006-01-02"); got != tt.first {
+			t.Errorf("FinancialYearRange(%q) first = %s, want %s", tt.financialYear, got, tt.first)
+		}
+		if got := last.Format("2006-01-02"); got != tt.last {
+			t.Errorf("FinancialYearRange(%q) last = %s, want %s", tt.financialYear, got, tt.last)
+		}
+		// Both ends of the range belong to the financial year, the days around them do not
+		if FinancialYear(first) != tt.financialYear || FinancialYear(last) != tt.financialYear {
+			t.Errorf("FinancialYearRange(%q) = %s..%s, outside the year", tt.financialYear, first, last)
+		}
+		if FinancialYear(first.AddDate(0, 0, -1)) == tt.financialYear || FinancialYear(last.AddDate(0, 0, 1)) == tt.financialYear {
+			t.Errorf("FinancialYearRange(%q) = %s..%s does not cover the whole year", tt.financialYear, first, last)
+		}
+	}
+
+	for _, invalid := range []string{"", "2025", "2025-27", "2025-2026", "25-26", "abcd-ef", "0999-00"} {
+		if _, _, err := FinancialYearRange(invalid); err == nil {
+			t.Errorf("FinancialYearRange(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+)
+
+//go:embed templates/share_text/*.tmpl
+var shareTextFS embed.FS
+
+// ErrUnsupportedShareLanguage is returned for a lang without a share text template
+var ErrUnsupportedShareLanguage = errors.New("unsupported share text language")
+
+// DefaultShareLanguage is used when no lang is requested
+const DefaultShareLanguage = "en"
+
+// maxShareGuests is the number of special guests named in a share text; the rest are counted
+const maxShareGuests = 5
+
+// shareTextTemplates holds templates/share_text/<lang>.tmpl by language code
+var shareTextTemplates = loadShareTextTemplates()
+
+// shareMonths are the month names used in share text dates, for languages other than English
+var shareMonths = map[string][12]string{
+	"hi": {"जनवरी", "फ़रवरी", "मार्च", "अप्रैल", "मई", "जून", "जुलाई", "अगस्त", "सितंबर", "अक्टूबर", "नवंबर", "दिसंबर"},
+}
+
+// blankLines collapses the empty lines left by omitted template sections
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func loadShareTextTemplates() map[string]*template.Template {
+	files, err := fs.Glob(shareTextFS, "templates/share_text/*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	funcs := template.FuncMap{"join": strings.Join}
+	templates := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		lang := strings.TrimSuffix(path.Base(file), ".tmpl")
+		templates[lang] = template.Must(template.New(path.Base(file)).Funcs(funcs).Option("missingkey=zero").ParseFS(shareTextFS, file))
+	}
+	return templates
+}
+
+// ShareText is an event summary formatted for pasting into WhatsApp
+type ShareText struct {
+	EventID  uint   `json:"event_id"`
+	Language string `json:"lang"`
+	Text     string `json:"text"`
+}
+
+// shareCounts are the men/women/children counts of a share text
+type shareCounts struct {
+	Men, Women, Children, Total int
+}
+
+// shareTextData is the data the share text templates are rendered with
+type shareTextData struct {
+	Title          string
+	Theme          string
+	Dates          string
+	Venue          string
+	Branch         string
+	ReportNumber   string
+	Beneficiaries  shareCounts
+	Initiations    shareCounts
+	Highlights     bool
+	Orator         string
+	Guests         []string
+	MoreGuests     int
+	Volunteers     int
+	Donations      int
+	DonationAmount string // empty when masked
+	Photos         int
+}
+
+// ShareTextLanguages lists the languages share text can be rendered in
+func ShareTextLanguages() []string {
+	langs := make([]string, 0, len(shareTextTemplates))
+	for lang := range shareTextTemplates {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// GenerateEventShareText renders the summary of an event (theme, dates, beneficiaries and
+// highlights) from the same data as the event report PDF, in the template of lang. When
+// maskPII is set, the donation amount is left out.
+func GenerateEventShareText(eventID uint, lang string, maskPII bool) (*ShareText, error) {
+	if lang == "" {
+		lang = DefaultShareLanguage
+	}
+	tmpl, ok := shareTextTemplates[lang]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (use one of %s)", ErrUnsupportedShareLanguage, lang, strings.Join(ShareTextLanguages(), ", "))
+	}
+
+	event, err := GetEventByID(eventID)
+	if err != nil {
+		return nil, err
+	}
+	guests, err := GetSpecialGuestByEventID(eventID)
+	if err != nil && err != ErrSpecialGuestNotFound {
+		return nil, err
+	}
+	donations, err := GetDonationsByEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := shareTextData{
+		Title:        event.EventType.Name,
+		Theme:        event.Theme,
+		Dates:        shareDates(event, lang),
+		Venue:        joinNonEmpty(", ", event.City, event.District, event.State),
+		ReportNumber: event.ReportNumber,
+		Beneficiaries: shareCounts{event.BeneficiaryMen, event.BeneficiaryWomen, event.BeneficiaryChild,
+			event.BeneficiaryMen + event.BeneficiaryWomen + event.BeneficiaryChild},
+		Initiations: shareCounts{event.InitiationMen, event.InitiationWomen, event.InitiationChild,
+			event.InitiationMen + event.InitiationWomen + event.InitiationChild},
+		Orator:     event.SpiritualOrator,
+		Volunteers: event.VolunteerCount,
+		Photos:     event.MediaCount,
+	}
+	if data.Title == "" {
+		data.Title = reportTitle(event)
+	}
+	if event.Branch != nil {
+		data.Branch = event.Branch.Name
+	}
+	for _, guest := range guests {
+		if len(data.Guests) == maxShareGuests {
+			data.MoreGuests++
+			continue
+		}
+		if name := joinNonEmpty(" ", guest.Prefix, guest.FirstName, guest.MiddleName, guest.LastName); name != "" {
+			data.Guests = append(data.Guests, name)
+		}
+	}
+	var amount float64
+	for _, donation := range donations {
+		if donation.VoidedOn == nil {
+			data.Donations++
+			amount += donation.Amount
+		}
+	}
+	if !maskPII && amount > 0 {
+		data.DonationAmount = shareAmount(amount)
+	}
+	data.Highlights = data.Orator != "" || len(data.Guests) > 0 || data.Volunteers > 0 || data.Donations > 0 || data.Photos > 0
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("share text template %s: %w", lang, err)
+	}
+	text := blankLines.ReplaceAllString(strings.TrimSpace(buf.String()), "\n\n")
+	return &ShareText{EventID: event.ID, Language: lang, Text: text}, nil
+}
+
+// shareDates formats the event dates with the month names of lang
+func shareDates(event *models.EventDetails, lang string) string {
+	date := func(t time.Time) string {
+		if months, ok := shareMonths[lang]; ok {
+			return fmt.Sprintf("%d %s %d", t.Day(), months[t.Month()-1], t.Year())
+		}
+		return t.Format("2 Jan 2006")
+	}
+	dates := date(event.StartDate)
+	if !event.EndDate.IsZero() && !event.EndDate.Equal(event.StartDate) {
+		dates += " - " + date(event.EndDate)
+	}
+	return dates
+}
+
+// shareAmount formats an amount with Indian digit grouping (12,34,567), without paise when whole
+func shareAmount(amount float64) string {
+	text := fmt.Sprintf("%.2f", amount)
+	whole, paise := text[:len(text)-3], text[len(text)-2:]
+	if len(whole) > 3 {
+		head, tail := whole[:len(whole)-3], whole[len(whole)-3:]
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		whole = strings.Join(append(append([]string{head}, groups...), tail), ",")
+	}
+	if paise == "00" {
+		return whole
+	}
+	return whole + "." + paise
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotDeleted is returned when restoring a record that is not soft-deleted (or does not exist)
+var ErrNotDeleted = errors.New("record not found or not deleted")
+
+// withDeleted includes soft-deleted rows in the query when includeDeleted is set
+func withDeleted(db *gorm.DB, includeDeleted bool) *gorm.DB {
+	if includeDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// restoreSoftDeleted clears deleted_at on a soft-deleted row
+func restoreSoftDeleted(db *gorm.DB, model interface{}, id uint) error {
+	result := db.Unscoped().Model(model).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotDeleted
+	}
+	return nil
+}
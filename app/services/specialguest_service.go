@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"gorm.io/gorm"
 )
@@ -23,10 +24,10 @@ func CreateSpecialGuest(sg *models.SpecialGuest) error {
 	return nil
 }
 
-// GetAllSpecialGuests fetches all special guests
-func GetAllSpecialGuests() ([]models.SpecialGuest, error) {
+// GetAllSpecialGuests fetches the special guests of events of the scope's branches
+func GetAllSpecialGuests(scope *BranchScope) ([]models.SpecialGuest, error) {
 	var guests []models.SpecialGuest
-	if err := config.DB.Find(&guests).Error; err != nil {
+	if err := scope.ApplyToEvents(config.DB, "event_id").Find(&guests).Error; err != nil {
 		return nil, err
 	}
 	return guests, nil
@@ -59,6 +60,22 @@ func UpdateSpecialGuest(sgID uint, updatedData map[string]interface{}) error {
 	now := time.Now()
 	updatedData["updated_on"] = &now
 
+	// Recompute the name key when any part of the name changes
+	nameChanged := false
+	for field, target := range map[string]*string{
+		"first_name":  &guest.FirstName,
+		"middle_name": &guest.MiddleName,
+		"last_name":   &guest.LastName,
+	} {
+		if value, ok := updatedData[field].(string); ok {
+			*target = value
+			nameChanged = true
+		}
+	}
+	if nameChanged {
+		updatedData["name_key"] = utils.NameKey(guest.FullName())
+	}
+
 	if err := config.DB.Model(&guest).Updates(updatedData).Error; err != nil {
 		return err
 	}
@@ -76,3 +93,43 @@ func DeleteSpecialGuest(sgID uint) error {
 	}
 	return nil
 }
+
+// SearchSpecialGuests searches the special guests of events of the scope's branches by name,
+// organization or contact. Names are also matched on their transliterated key, so "Vikas"
+// finds "विकास".
+func SearchSpecialGuests(searchTerm string, scope *BranchScope) ([]models.SpecialGuest, error) {
+	var guests []models.SpecialGuest
+
+	like := "%" + searchTerm + "%"
+	matches := config.DB.Where(
+		"CONCAT_WS(' ', first_name, middle_name, last_name) ILIKE ? OR organization ILIKE ? OR personal_number ILIKE ? OR email ILIKE ?",
+		like, like, like, like,
+	)
+	if key := utils.NameKey(searchTerm); key != "" {
+		matches = matches.Or("name_key LIKE ?", "%"+key+"%")
+	}
+	query := scope.ApplyToEvents(config.DB.Where(matches), "event_id")
+
+	if err := query.Limit(20).Find(&guests).Error; err != nil {
+		return nil, err
+	}
+
+	return guests, nil
+}
+
+// FindSpecialGuestDuplicates returns special guests of events of the scope's branches whose
+// name matches across scripts/spellings
+func FindSpecialGuestDuplicates(name string, scope *BranchScope) ([]models.SpecialGuest, error) {
+	guests := []models.SpecialGuest{}
+
+	key := utils.NameKey(name)
+	if key == "" {
+		return guests, nil
+	}
+
+	if err := scope.ApplyToEvents(config.DB.Where("name_key = ?", key), "event_id").Order("id ASC").Limit(50).Find(&guests).Error; err != nil {
+		return nil, err
+	}
+
+	return guests, nil
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoStatsSnapshot is returned for as-of dates before the first snapshot of the branch
+var ErrNoStatsSnapshot = errors.New("no statistics snapshot on or before the date")
+
+// StatsSnapshotDay is a date statistics were snapshotted on
+type StatsSnapshotDay struct {
+	SnapshotDate string    `json:"snapshot_date"`
+	Branches     int64     `json:"branches"`
+	TakenOn      time.Time `json:"taken_on"`
+}
+
+// StatsChange is a figure that differs between the reported and the current statistics
+type StatsChange struct {
+	Metric     string  `json:"metric"`
+	Reported   float64 `json:"reported"`
+	Current    float64 `json:"current"`
+	Difference float64 `json:"difference"`
+}
+
+// BranchRestatement lists the restated figures of one branch's own row
+type BranchRestatement struct {
+	BranchID uint          `json:"branch_id"`
+	Name     string        `json:"name"`
+	Changes  []StatsChange `json:"changes"`
+}
+
+// BranchStatsRestatements compares the figures reported on a date with the current figures
+// (or those of a later snapshot). Branches only lists branches whose own figures changed,
+// including branches added to or removed from the tree since.
+type BranchStatsRestatements struct {
+	BranchID        uint                `json:"branch_id"`
+	IncludeChildren bool                `json:"include_children"`
+	ReportedOn      string              `json:"reported_on"`
+	ComparedWith    string              `json:"compared_with"` // "live" or a snapshot date
+	Reported        BranchStatsTotals   `json:"reported"`
+	Current         BranchStatsTotals   `json:"current"`
+	Changes         []StatsChange       `json:"changes"`
+	Branches        []BranchRestatement `json:"branches,omitempty"`
+}
+
+// StartStatsSnapshotScheduler snapshots the statistics of every branch once a day, after
+// STATS_SNAPSHOT_HOUR (0-23, default 23 so the snapshot holds the day's final figures; "off"
+// disables it)
+func StartStatsSnapshotScheduler() {
+	logger := utils.BaseLogger()
+	hour := config.Jobs.StatsSnapshotHour
+	if hour < 0 {
+		logger.Info("Statistics snapshots disabled by STATS_SNAPSHOT_HOUR")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			now := time.Now()
+			if now.Hour() < hour {
+				continue
+			}
+			var taken int64
+			if err := config.DB.Model(&models.StatsSnapshot{}).
+				Where("snapshot_date = ?", now.Format("2006-01-02")).
+				Count(&taken).Error; err != nil {
+				logger.Error("Failed to check statistics snapshots", zap.Error(err))
+				continue
+			}
+			if taken > 0 {
+				continue
+			}
+			if _, err := TakeStatsSnapshot(now); err != nil {
+				logger.Error("Failed to snapshot statistics", zap.Error(err))
+			}
+		}
+	}()
+	logger.Info("Statistics snapshot scheduler started", zap.Int("hour", hour))
+}
+
+// TakeStatsSnapshot stores the current figures of every branch as the snapshot of date's day,
+// replacing a snapshot already taken that day. Returns the number of branches snapshotted.
+func TakeStatsSnapshot(date time.Time) (int, error) {
+	var rows []BranchStatsRow
+	err := config.DB.Model(&models.Branch{}).
+		Select("id AS branch_id, name, parent_branch_id").
+		Order("id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if err := fillBranchStats(rows); err != nil {
+		return 0, err
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	snapshots := make([]models.StatsSnapshot, len(rows))
+	for i, row := range rows {
+		snapshots[i] = models.StatsSnapshot{
+			SnapshotDate:     day,
+			BranchID:         row.BranchID,
+			ParentBranchID:   row.ParentBranchID,
+			Name:             row.Name,
+			Events:           row.Events,
+			EventsComplete:   row.EventsComplete,
+			BeneficiaryMen:   row.BeneficiaryMen,
+			BeneficiaryWomen: row.BeneficiaryWomen,
+			BeneficiaryChild: row.BeneficiaryChild,
+			InitiationMen:    row.InitiationMen,
+			InitiationWomen:  row.InitiationWomen,
+			InitiationChild:  row.InitiationChild,
+			Donations:        row.Donations,
+			DonationAmount:   row.DonationAmount,
+			Members:          row.Members,
+		}
+	}
+	err = config.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "snapshot_date"}, {Name: "branch_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"parent_branch_id", "name", "events", "events_complete",
+			"beneficiary_men", "beneficiary_women", "beneficiary_child",
+			"initiation_men", "initiation_women", "initiation_child",
+			"donations", "donation_amount", "members", "created_on",
+		}),
+	}).CreateInBatches(snapshots, 500).Error
+	if err != nil {
+		return 0, err
+	}
+	utils.BaseLogger().Info("Statistics snapshot taken",
+		zap.String("date", day.Format("2006-01-02")), zap.Int("branches", len(snapshots)))
+	return len(snapshots), nil
+}
+
+// GetStatsSnapshotDays lists the snapshot dates, most recent first
+func GetStatsSnapshotDays(limit int) ([]StatsSnapshotDay, error) {
+	if limit <= 0 || limit > 366 {
+		limit = 60
+	}
+	var days []StatsSnapshotDay
+	err := config.DB.Model(&models.StatsSnapshot{}).
+		Select("to_char(snapshot_date, 'YYYY-MM-DD') AS snapshot_date, COUNT(*) AS branches, MAX(created_on) AS taken_on").
+		Group("snapshot_date").
+		Order("stats_snapshots.snapshot_date DESC").
+		Limit(limit).
+		Scan(&days).Error
+	return days, err
+}
+
+// GetBranchStatsAsOf returns the statistics of a branch as they were reported on asOf: the
+// figures of the latest snapshot on or before the date, rolled up over the branch tree of
+// that snapshot with includeChildren. Branches in the training sandbox are left out as in
+// GetBranchStats.
+func GetBranchStatsAsOf(branchID uint, includeChildren bool, asOf time.Time) (*BranchStats, error) {
+	date, rows, err := loadSnapshotRows(branchID, includeChildren, asOf)
+	if err != nil {
+		return nil, err
+	}
+	stats := newBranchStats(branchID, includeChildren, rows)
+	stats.SnapshotDate = date
+	return stats, nil
+}
+
+// GetBranchStatsRestatements compares the statistics reported on asOf with the current
+// figures, or with those reported on against when it is set
+func GetBranchStatsRestatements(branchID uint, includeChildren bool, asOf time.Time, against *time.Time) (*BranchStatsRestatements, error) {
+	reportedOn, reported, err := loadSnapshotRows(branchID, includeChildren, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	comparedWith := "live"
+	var current []BranchStatsRow
+	if against != nil {
+		if comparedWith, current, err = loadSnapshotRows(branchID, includeChildren, *against); err != nil {
+			return nil, err
+		}
+	} else {
+		stats, err := GetBranchStats(branchID, includeChildren)
+		if err != nil {
+			return nil, err
+		}
+		current = stats.Branches
+		if !includeChildren {
+			current = []BranchStatsRow{{BranchID: branchID, BranchStatsTotals: stats.Totals}}
+		}
+	}
+
+	result := &BranchStatsRestatements{
+		BranchID:        branchID,
+		IncludeChildren: includeChildren,
+		ReportedOn:      reportedOn,
+		ComparedWith:    comparedWith,
+		Reported:        newBranchStats(branchID, false, reported).Totals,
+		Current:         newBranchStats(branchID, false, current).Totals,
+	}
+	result.Changes = statsChanges(result.Reported, result.Current)
+	if !includeChildren {
+		return result, nil
+	}
+
+	byID := make(map[uint]BranchStatsRow, len(current))
+	for _, row := range current {
+		byID[row.BranchID] = row
+	}
+	for _, row := range reported {
+		now, ok := byID[row.BranchID]
+		delete(byID, row.BranchID)
+		if !ok {
+			now = BranchStatsRow{BranchID: row.BranchID, Name: row.Name}
+		}
+		if changes := statsChanges(row.BranchStatsTotals, now.BranchStatsTotals); len(changes) > 0 {
+			result.Branches = append(result.Branches, BranchRestatement{BranchID: row.BranchID, Name: row.Name, Changes: changes})
+		}
+	}
+	// Branches added to the tree after the reported date
+	for _, row := range current {
+		if _, added := byID[row.BranchID]; !added {
+			continue
+		}
+		if changes := statsChanges(BranchStatsTotals{}, row.BranchStatsTotals); len(changes) > 0 {
+			result.Branches = append(result.Branches, BranchRestatement{BranchID: row.BranchID, Name: row.Name, Changes: changes})
+		}
+	}
+	return result, nil
+}
+
+// loadSnapshotRows returns the date of the latest snapshot of the branch on or before asOf and
+// the snapshot rows of the branch (and its descendants on that date with includeChildren)
+func loadSnapshotRows(branchID uint, includeChildren bool, asOf time.Time) (string, []BranchStatsRow, error) {
+	var dates []string
+	err := config.DB.Model(&models.StatsSnapshot{}).
+		Select("to_char(snapshot_date, 'YYYY-MM-DD')").
+		Where("branch_id = ? AND snapshot_date <= ?", branchID, asOf.Format("2006-01-02")).
+		Order("snapshot_date DESC").
+		Limit(1).
+		Pluck("snapshot_date", &dates).Error
+	if err != nil {
+		return "", nil, err
+	}
+	if len(dates) == 0 {
+		return "", nil, fmt.Errorf("%w (%s)", ErrNoStatsSnapshot, asOf.Format("2006-01-02"))
+	}
+	date := dates[0]
+
+	query := config.DB.Model(&models.StatsSnapshot{}).Where("snapshot_date = ?", date).Order("branch_id")
+	if !includeChildren {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	var snapshots []models.StatsSnapshot
+	if err := query.Find(&snapshots).Error; err != nil {
+		return "", nil, err
+	}
+
+	children := map[uint][]uint{}
+	byID := make(map[uint]models.StatsSnapshot, len(snapshots))
+	for _, s := range snapshots {
+		byID[s.BranchID] = s
+		if s.ParentBranchID != nil {
+			children[*s.ParentBranchID] = append(children[*s.ParentBranchID], s.BranchID)
+		}
+	}
+	// Walk the tree of that day; visited guards against parent_branch_id cycles
+	var rows []BranchStatsRow
+	visited := map[uint]bool{}
+	queue := []uint{branchID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		s, ok := byID[id]
+		if !ok || visited[id] {
+			continue
+		}
+		visited[id] = true
+		rows = append(rows, snapshotRow(s))
+		if includeChildren {
+			queue = append(queue, children[id]...)
+		}
+	}
+	if includeChildren {
+		if rows, err = withoutSandboxBranches(branchID, rows); err != nil {
+			return "", nil, err
+		}
+	}
+	return date, rows, nil
+}
+
+func snapshotRow(s models.StatsSnapshot) BranchStatsRow {
+	row := BranchStatsRow{
+		BranchID:       s.BranchID,
+		Name:           s.Name,
+		ParentBranchID: s.ParentBranchID,
+		BranchStatsTotals: BranchStatsTotals{
+			Events:           s.Events,
+			EventsComplete:   s.EventsComplete,
+			BeneficiaryMen:   s.BeneficiaryMen,
+			BeneficiaryWomen: s.BeneficiaryWomen,
+			BeneficiaryChild: s.BeneficiaryChild,
+			InitiationMen:    s.InitiationMen,
+			InitiationWomen:  s.InitiationWomen,
+			InitiationChild:  s.InitiationChild,
+			Donations:        s.Donations,
+			DonationAmount:   s.DonationAmount,
+			Members:          s.Members,
+		},
+	}
+	row.Beneficiaries = row.BeneficiaryMen + row.BeneficiaryWomen + row.BeneficiaryChild
+	row.Initiations = row.InitiationMen + row.InitiationWomen + row.InitiationChild
+	return row
+}
+
+// statsChanges lists the figures that differ between reported and current
+func statsChanges(reported, current BranchStatsTotals) []StatsChange {
+	metrics := []struct {
+		name              string
+		reported, current float64
+	}{
+		{"events", float64(reported.Events), float64(current.Events)},
+		{"events_complete", float64(reported.EventsComplete), float64(current.EventsComplete)},
+		{"beneficiary_men", float64(reported.BeneficiaryMen), float64(current.BeneficiaryMen)},
+		{"beneficiary_women", float64(reported.BeneficiaryWomen), float64(current.BeneficiaryWomen)},
+		{"beneficiary_child", float64(reported.BeneficiaryChild), float64(current.BeneficiaryChild)},
+		{"beneficiaries", float64(reported.Beneficiaries), float64(current.Beneficiaries)},
+		{"initiation_men", float64(reported.InitiationMen), float64(current.InitiationMen)},
+		{"initiation_women", float64(reported.InitiationWomen), float64(current.InitiationWomen)},
+		{"initiation_child", float64(reported.InitiationChild), float64(current.InitiationChild)},
+		{"initiations", float64(reported.Initiations), float64(current.Initiations)},
+		{"donations", float64(reported.Donations), float64(current.Donations)},
+		// Rounded to paise: sums of amounts differ in the last float digits
+		{"donation_amount", math.Round(reported.DonationAmount*100) / 100, math.Round(current.DonationAmount*100) / 100},
+		{"members", float64(reported.Members), float64(current.Members)},
+	}
+	changes := []StatsChange{}
+	for _, m := range metrics {
+		if m.reported != m.current {
+			changes = append(changes, StatsChange{Metric: m.name, Reported: m.reported, Current: m.current, Difference: m.current - m.reported})
+		}
+	}
+	return changes
+}
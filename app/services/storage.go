@@ -0,0 +1,359 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+var (
+	// ErrStorageNotConfigured is returned when no storage backend has been set up, see SetStorage
+	ErrStorageNotConfigured = errors.New("file storage is not configured")
+	// ErrObjectNotFound is returned by Storage implementations for keys that do not exist
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string // without quotes; the MD5 of the content for single-part uploads
+	ContentType  string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// Storage is where uploaded files live. S3Storage is used in production (also for MinIO and other
+// S3-compatible servers), LocalStorage keeps files on disk for small deployments and MemoryStorage
+// keeps them in memory for tests.
+type Storage interface {
+	// Bucket names the bucket (or equivalent) objects are stored in
+	Bucket() string
+	Upload(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error
+	// Get opens an object for reading; the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// Presign returns a URL that downloads the object without credentials until expiration
+	Presign(ctx context.Context, key string, expiration time.Duration) (string, error)
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	// List calls fn for every object whose key starts with prefix, in key order
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// CompletedPart is a part of a multipart upload, see MultipartStorage
+type CompletedPart struct {
+	Number int32  `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// MultipartStorage assembles one object from parts uploaded separately (resumable uploads).
+// Parts are numbered from 1; every part but the last must be at least 5 MiB on S3.
+// S3Storage uses S3 multipart uploads; other backends get partObjectStorage, see
+// multipartStorageFor.
+type MultipartStorage interface {
+	CreateMultipartUpload(ctx context.Context, key, contentType string, metadata map[string]string) (uploadID string, err error)
+	UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (etag string, err error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// storageVerifier is implemented by backends that can check their credentials and permissions
+type storageVerifier interface {
+	Verify(ctx context.Context) error
+}
+
+var fileStorage Storage
+
+// SetStorage sets the backend used by every upload, download and presign in this package.
+// It is called once at startup (see dependencies.InitializeStorage) or by tests.
+func SetStorage(storage Storage) {
+	fileStorage = storage
+}
+
+// GetStorage returns the configured storage backend
+func GetStorage() (Storage, error) {
+	if fileStorage == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	return fileStorage, nil
+}
+
+// NewStorageFromConfig builds the backend selected by STORAGE_DRIVER:
+//   - "s3" (default): AWS S3, or an S3-compatible server such as MinIO when AWS_S3_ENDPOINT is set
+//   - "local": files under STORAGE_LOCAL_DIR (default ./storage), served by /api/files/local
+//     through links signed with STORAGE_SIGNING_KEY (default JWT_SECRET); STORAGE_PUBLIC_URL is
+//     the API origin used in those links
+//   - "memory": in-process only, for tests
+func NewStorageFromConfig(ctx context.Context, cfg config.StorageConfig) (Storage, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Driver {
+	case "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.PublicURL, []byte(cfg.SigningKey))
+	case "memory":
+		return NewMemoryStorage("memory"), nil
+	default:
+		return NewS3StorageFromConfig(ctx, cfg.S3)
+	}
+}
+
+// VerifyStorage checks that the storage backend is reachable and writable
+func VerifyStorage(ctx context.Context) error {
+	storage, err := GetStorage()
+	if err != nil {
+		return err
+	}
+	if verifier, ok := storage.(storageVerifier); ok {
+		return verifier.Verify(ctx)
+	}
+	return nil
+}
+
+// storageForBucket returns the configured storage, or for another bucket (MEDIA_MANIFEST_BUCKET)
+// an S3 storage sharing its client. Other backends only have their own bucket.
+func storageForBucket(bucket string) (Storage, error) {
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	if bucket == "" || bucket == storage.Bucket() {
+		return storage, nil
+	}
+	s3Storage, ok := storage.(*S3Storage)
+	if !ok {
+		return nil, fmt.Errorf("bucket %s is not available: the storage backend only has %s", bucket, storage.Bucket())
+	}
+	return s3Storage.WithBucket(bucket), nil
+}
+
+// multipartStorageFor returns the multipart uploads of a backend
+func multipartStorageFor(storage Storage) MultipartStorage {
+	if multipart, ok := storage.(MultipartStorage); ok {
+		return multipart
+	}
+	return partObjectStorage{storage: storage}
+}
+
+// partObjectsPrefix holds the parts of multipart uploads of backends without native support
+const partObjectsPrefix = "uploads/parts/"
+
+// partObjectStorage emulates multipart uploads on any backend: parts are stored as objects
+// under uploads/parts/<upload id>/ and concatenated into the final object on completion. The
+// content type and metadata of the final object are kept on an empty marker object there.
+type partObjectStorage struct {
+	storage Storage
+}
+
+func (p partObjectStorage) partKey(uploadID string, number int32) string {
+	return fmt.Sprintf("%s%s/%05d", partObjectsPrefix, uploadID, number)
+}
+
+func (p partObjectStorage) markerKey(uploadID string) string {
+	return partObjectsPrefix + uploadID + "/upload"
+}
+
+func (p partObjectStorage) CreateMultipartUpload(ctx context.Context, key, contentType string, metadata map[string]string) (string, error) {
+	uploadID := uuid.New().String()
+	if err := p.storage.Upload(ctx, p.markerKey(uploadID), bytes.NewReader(nil), contentType, metadata); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (p partObjectStorage) UploadPart(ctx context.Context, key, uploadID string, number int32, body io.Reader, size int64) (string, error) {
+	partKey := p.partKey(uploadID, number)
+	if err := p.storage.Upload(ctx, partKey, body, "application/octet-stream", nil); err != nil {
+		return "", err
+	}
+	info, err := p.storage.Head(ctx, partKey)
+	if err != nil {
+		return "", err
+	}
+	return info.ETag, nil
+}
+
+func (p partObjectStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	marker, err := p.storage.Head(ctx, p.markerKey(uploadID))
+	if err != nil {
+		return fmt.Errorf("multipart upload %s: %w", uploadID, err)
+	}
+	keys := make([]string, len(parts))
+	for i, part := range parts {
+		keys[i] = p.partKey(uploadID, part.Number)
+	}
+	body := &sequentialObjectReader{ctx: ctx, storage: p.storage, keys: keys}
+	defer body.Close()
+	if err := p.storage.Upload(ctx, key, body, marker.ContentType, marker.Metadata); err != nil {
+		return err
+	}
+	return p.AbortMultipartUpload(ctx, key, uploadID)
+}
+
+// AbortMultipartUpload deletes the stored parts
+func (p partObjectStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	var keys []string
+	if err := p.storage.List(ctx, partObjectsPrefix+uploadID+"/", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, partKey := range keys {
+		if err := p.storage.Delete(ctx, partKey); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// sequentialObjectReader reads objects one after the other, opening each only when the
+// previous one is exhausted
+type sequentialObjectReader struct {
+	ctx     context.Context
+	storage Storage
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *sequentialObjectReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			body, err := r.storage.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.keys = body, r.keys[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *sequentialObjectReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+// MemoryStorage keeps objects in memory. Presigned URLs use a memory:// scheme and cannot be
+// opened by a browser.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	bucket  string
+	objects map[string]memoryObject
+}
+
+// NewMemoryStorage returns an empty in-memory storage
+func NewMemoryStorage(bucket string) *MemoryStorage {
+	return &MemoryStorage{bucket: bucket, objects: map[string]memoryObject{}}
+}
+
+func (m *MemoryStorage) Bucket() string {
+	return m.bucket
+}
+
+func (m *MemoryStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string, metadata map[string]string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(data)
+	info := ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		ETag:         hex.EncodeToString(sum[:]),
+		ContentType:  contentType,
+		LastModified: time.Now().UTC(),
+		Metadata:     make(map[string]string, len(metadata)),
+	}
+	for k, v := range metadata {
+		info.Metadata[k] = v
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: data, info: info}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	object, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStorage) Presign(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	return fmt.Sprintf("memory://%s/%s?expires=%d", m.bucket, url.PathEscape(key), time.Now().Add(expiration).Unix()), nil
+}
+
+func (m *MemoryStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	m.mu.RLock()
+	object, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	info := object.info
+	return &info, nil
+}
+
+func (m *MemoryStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	m.mu.RLock()
+	infos := make([]ObjectInfo, 0, len(m.objects))
+	for key, object := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, object.info)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
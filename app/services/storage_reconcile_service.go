@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Storage reconciliation compares the bucket with the database. Objects no row points at
+// (uploads whose record was never created, files left behind when a cleanup job could not be
+// queued) are orphans; they are reported and, when asked, deleted. Rows whose object is gone
+// are reported as missing so the media can be re-uploaded. It runs as a job started by admins
+// (POST /api/admin/storage/reconcile).
+
+// ErrStorageReconcileRunning is returned while a reconciliation is queued or running
+var ErrStorageReconcileRunning = errors.New("a storage reconciliation is already running")
+
+const (
+	storageReconcileTimeout = 2 * time.Hour
+	// defaultOrphanGraceHours leaves objects younger than this alone: their row may not be
+	// committed yet (uploads in flight, thumbnails being generated)
+	defaultOrphanGraceHours = 24
+	// storageReconcileListLimit caps the keys listed in the job result; the counts are exact
+	storageReconcileListLimit = 1000
+	// storageReconcileDeleteBatch is the number of keys per storage cleanup job
+	storageReconcileDeleteBatch = 500
+)
+
+// storageReconcileSkipPrefixes are owned by other jobs that expire them on their own (job
+// files, exports, manifests, parts of resumable uploads) and are never orphans
+var storageReconcileSkipPrefixes = []string{
+	"reports/",
+	"imports/",
+	mediaExportPrefix,
+	mediaManifestPrefix,
+	partObjectsPrefix,
+}
+
+// StorageReconcileOptions configures a reconciliation
+type StorageReconcileOptions struct {
+	DeleteOrphans bool `json:"delete_orphans"` // delete orphans instead of only reporting them
+	GraceHours    int  `json:"grace_hours"`    // minimum age of an orphan (default 24)
+}
+
+// StorageReference is a row pointing at a storage object
+type StorageReference struct {
+	Table  string `json:"table"`
+	ID     uint   `json:"id"`
+	Column string `json:"column"`
+	S3Key  string `json:"s3_key"`
+}
+
+// storageReferenceColumns lists every column holding object keys
+var storageReferenceColumns = []struct {
+	table   string
+	columns []string
+	// softDeleted tables keep deleted rows, which can be restored; their objects are
+	// referenced but may be missing
+	softDeleted bool
+}{
+	{"event_media", []string{"s3_key", "thumbnail_s3_key", "thumbnail_medium_s3_key", "transcoded_s3_key"}, false},
+	{"branch_media", []string{"s3_key", "thumbnail_s3_key", "thumbnail_medium_s3_key", "transcoded_s3_key"}, true},
+	{"donations", []string{"receipt_s3_key"}, true},
+	{"public_assets", []string{"s3_key"}, false},
+	{"media_tombstones", []string{"s3_key"}, false},
+	{"upload_sessions", []string{"s3_key"}, false},
+}
+
+// StartStorageReconcile queues a reconciliation. Only one runs at a time.
+func StartStorageReconcile(ctx context.Context, opts StorageReconcileOptions, createdBy *uint) (*models.Job, error) {
+	if opts.GraceHours <= 0 {
+		opts.GraceHours = defaultOrphanGraceHours
+	}
+	if _, err := GetStorage(); err != nil {
+		return nil, err
+	}
+	var running int64
+	if err := config.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", JobTypeStorageReconcile, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrStorageReconcileRunning
+	}
+	return EnqueueJob(ctx, JobTypeStorageReconcile, opts, JobOptions{CreatedBy: createdBy, MaxAttempts: 1})
+}
+
+func runStorageReconcileJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var opts StorageReconcileOptions
+	if err := run.Decode(&opts); err != nil {
+		return nil, err
+	}
+	if opts.GraceHours <= 0 {
+		opts.GraceHours = defaultOrphanGraceHours
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, PermanentJobError(err)
+	}
+
+	// References are loaded before listing, so an object uploaded in between is at worst
+	// younger than the grace period rather than wrongly orphaned
+	run.SetProgress(5, "Loading object keys from the database")
+	referenced, live, err := loadStorageReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	run.SetProgress(20, "Listing the bucket")
+	present := map[string]bool{}
+	var orphans []string
+	var orphanBytes int64
+	cutoff := time.Now().Add(-time.Duration(opts.GraceHours) * time.Hour)
+	err = storage.List(ctx, "", func(object ObjectInfo) error {
+		present[object.Key] = true
+		if len(present)%10000 == 0 {
+			run.SetProgress(20, fmt.Sprintf("Listing the bucket (%d objects)", len(present)))
+		}
+		if referenced[object.Key] || skipStorageReconcile(object.Key) {
+			return nil
+		}
+		if !object.LastModified.IsZero() && object.LastModified.After(cutoff) {
+			return nil
+		}
+		orphans = append(orphans, object.Key)
+		orphanBytes += object.Size
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing bucket: %w", err)
+	}
+
+	run.SetProgress(80, "Checking database rows")
+	var missing []StorageReference
+	for _, ref := range live {
+		if !present[ref.S3Key] {
+			missing = append(missing, ref)
+		}
+	}
+
+	result := models.JSONB{
+		"objects":        len(present),
+		"references":     len(referenced),
+		"orphans":        len(orphans),
+		"orphan_bytes":   orphanBytes,
+		"missing":        len(missing),
+		"orphan_keys":    truncateList(orphans, storageReconcileListLimit),
+		"missing_rows":   truncateList(missing, storageReconcileListLimit),
+		"grace_hours":    opts.GraceHours,
+		"deleted_queued": 0,
+	}
+	if opts.DeleteOrphans && len(orphans) > 0 {
+		run.SetProgress(90, "Queueing deletion of orphans")
+		for start := 0; start < len(orphans); start += storageReconcileDeleteBatch {
+			end := min(start+storageReconcileDeleteBatch, len(orphans))
+			QueueStorageCleanup(ctx, time.Time{}, orphans[start:end]...)
+		}
+		result["deleted_queued"] = len(orphans)
+	}
+
+	utils.Logger(ctx).Info("Storage reconciliation finished", zap.Int("objects", len(present)),
+		zap.Int("orphans", len(orphans)), zap.Int("missing", len(missing)), zap.Bool("delete_orphans", opts.DeleteOrphans))
+	return result, nil
+}
+
+// loadStorageReferences returns every key referenced by a row, deleted or not, and the
+// references of live rows, whose objects must exist
+func loadStorageReferences(ctx context.Context) (map[string]bool, []StorageReference, error) {
+	referenced := map[string]bool{}
+	var live []StorageReference
+	for _, src := range storageReferenceColumns {
+		for _, column := range src.columns {
+			var rows []struct {
+				ID        uint
+				S3Key     string
+				IsDeleted bool
+			}
+			selectDeleted := "false AS is_deleted"
+			if src.softDeleted {
+				selectDeleted = "(deleted_at IS NOT NULL) AS is_deleted"
+			}
+			err := config.DB.WithContext(ctx).Table(src.table).
+				Select("id, " + column + " AS s3_key, " + selectDeleted).
+				Where(column + " IS NOT NULL AND " + column + " <> ''").
+				Scan(&rows).Error
+			if err != nil {
+				return nil, nil, fmt.Errorf("loading %s.%s: %w", src.table, column, err)
+			}
+			for _, row := range rows {
+				referenced[row.S3Key] = true
+				// Tombstones and upload sessions may point at objects deleted on purpose
+				if row.IsDeleted || src.table == "media_tombstones" || src.table == "upload_sessions" {
+					continue
+				}
+				live = append(live, StorageReference{Table: src.table, ID: row.ID, Column: column, S3Key: row.S3Key})
+			}
+		}
+	}
+
+	// Old media rows only stored the object URL
+	for _, table := range []string{"event_media", "branch_media"} {
+		var urls []string
+		if err := config.DB.WithContext(ctx).Table(table).
+			Where("(s3_key IS NULL OR s3_key = '') AND file_url <> ''").
+			Pluck("file_url", &urls).Error; err != nil {
+			return nil, nil, fmt.Errorf("loading %s.file_url: %w", table, err)
+		}
+		for _, url := range urls {
+			if key := GetS3KeyFromURL(url); key != "" {
+				referenced[key] = true
+			}
+		}
+	}
+	return referenced, live, nil
+}
+
+func skipStorageReconcile(key string) bool {
+	for _, prefix := range storageReconcileSkipPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateList[T any](items []T, limit int) []T {
+	if len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
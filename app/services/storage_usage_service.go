@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Storage usage attributes every stored file to a branch: event media, donation receipts and
+// public copies through the event's branch, branch media directly. The storage_usage job lists
+// the bucket for the sizes (the database does not record them) and replaces the
+// branch_storage_usage rows; it runs every STORAGE_USAGE_INTERVAL and on demand
+// (POST /api/admin/storage/usage/refresh). Branches with a quota stop accepting uploads once
+// their computed usage reaches it, so usage may overshoot the quota by what is uploaded between
+// two runs.
+
+var (
+	// ErrStorageUsageRunning is returned while a usage computation is queued or running
+	ErrStorageUsageRunning = errors.New("storage usage is already being computed")
+	// ErrStorageQuotaExceeded is returned for uploads to a branch over its storage quota
+	ErrStorageQuotaExceeded = errors.New("branch storage quota exceeded")
+	// ErrInvalidStorageQuota is returned for quotas that are not positive
+	ErrInvalidStorageQuota = errors.New("quota_bytes must be positive")
+)
+
+const (
+	storageUsageTimeout = time.Hour
+	// donationReceiptsCategory groups receipts next to the media categories
+	donationReceiptsCategory = "Donation Receipts"
+	// uncategorizedMedia is the category of media stored without one
+	uncategorizedMedia = "Other"
+)
+
+// StorageCategoryUsage is the storage used by one media category of a branch
+type StorageCategoryUsage struct {
+	Category string `json:"category"`
+	Objects  int64  `json:"objects"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// BranchStorageSummary is the storage used by a branch and its quota
+type BranchStorageSummary struct {
+	BranchID   uint                   `json:"branch_id"`
+	Name       string                 `json:"name"`
+	Objects    int64                  `json:"objects"`
+	Bytes      int64                  `json:"bytes"`
+	QuotaBytes *int64                 `json:"quota_bytes,omitempty"`
+	QuotaUsed  *float64               `json:"quota_used_percent,omitempty"`
+	OverQuota  bool                   `json:"over_quota"`
+	Categories []StorageCategoryUsage `json:"categories"`
+	ComputedOn *time.Time             `json:"computed_on,omitempty"`
+}
+
+// StorageUsageReport lists branches by storage used, largest first
+type StorageUsageReport struct {
+	ComputedOn *time.Time             `json:"computed_on,omitempty"` // nil until the first computation
+	Objects    int64                  `json:"objects"`
+	Bytes      int64                  `json:"bytes"`
+	Branches   []BranchStorageSummary `json:"branches"`
+}
+
+// GetBranchStorageUsage returns the last computed usage of every branch holding files or having
+// a quota
+func GetBranchStorageUsage(ctx context.Context) (*StorageUsageReport, error) {
+	var usage []models.BranchStorageUsage
+	if err := config.DB.WithContext(ctx).Order("branch_id, category").Find(&usage).Error; err != nil {
+		return nil, err
+	}
+	var quotas []models.BranchStorageQuota
+	if err := config.DB.WithContext(ctx).Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+
+	report := &StorageUsageReport{Branches: []BranchStorageSummary{}}
+	byBranch := map[uint]*BranchStorageSummary{}
+	summary := func(branchID uint) *BranchStorageSummary {
+		if s, ok := byBranch[branchID]; ok {
+			return s
+		}
+		s := &BranchStorageSummary{BranchID: branchID, Categories: []StorageCategoryUsage{}}
+		byBranch[branchID] = s
+		return s
+	}
+	for _, row := range usage {
+		s := summary(row.BranchID)
+		s.Objects += row.Objects
+		s.Bytes += row.Bytes
+		s.Categories = append(s.Categories, StorageCategoryUsage{Category: row.Category, Objects: row.Objects, Bytes: row.Bytes})
+		computedOn := row.ComputedOn
+		s.ComputedOn = &computedOn
+		if report.ComputedOn == nil || computedOn.After(*report.ComputedOn) {
+			report.ComputedOn = &computedOn
+		}
+		report.Objects += row.Objects
+		report.Bytes += row.Bytes
+	}
+	for _, quota := range quotas {
+		s := summary(quota.BranchID)
+		quotaBytes := quota.QuotaBytes
+		used := float64(s.Bytes) * 100 / float64(quotaBytes)
+		s.QuotaBytes = &quotaBytes
+		s.QuotaUsed = &used
+		s.OverQuota = s.Bytes >= quotaBytes
+	}
+
+	ids := make([]uint, 0, len(byBranch))
+	for id := range byBranch {
+		ids = append(ids, id)
+	}
+	var branches []models.Branch
+	if len(ids) > 0 {
+		if err := config.DB.WithContext(ctx).Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&branches).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, branch := range branches {
+		byBranch[branch.ID].Name = branch.Name
+	}
+
+	for _, s := range byBranch {
+		sort.Slice(s.Categories, func(i, j int) bool { return s.Categories[i].Bytes > s.Categories[j].Bytes })
+		report.Branches = append(report.Branches, *s)
+	}
+	sort.Slice(report.Branches, func(i, j int) bool {
+		a, b := report.Branches[i], report.Branches[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.BranchID < b.BranchID
+	})
+	return report, nil
+}
+
+// SetBranchStorageQuota sets the storage quota of a branch; nil removes it
+func SetBranchStorageQuota(ctx context.Context, branchID uint, quotaBytes *int64, updatedBy *uint) error {
+	var count int64
+	if err := config.DB.WithContext(ctx).Model(&models.Branch{}).Where("id = ?", branchID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrBranchNotFound
+	}
+	if quotaBytes == nil {
+		return config.DB.WithContext(ctx).Delete(&models.BranchStorageQuota{}, branchID).Error
+	}
+	if *quotaBytes <= 0 {
+		return ErrInvalidStorageQuota
+	}
+	quota := models.BranchStorageQuota{BranchID: branchID, QuotaBytes: *quotaBytes, UpdatedBy: updatedBy}
+	return config.DB.WithContext(ctx).Save(&quota).Error
+}
+
+// CheckBranchStorageQuota returns ErrStorageQuotaExceeded when the branch has used up its quota
+func CheckBranchStorageQuota(branchID uint) error {
+	var row struct {
+		QuotaBytes int64
+		Bytes      int64
+	}
+	err := config.DB.Raw(`SELECT q.quota_bytes,
+			(SELECT COALESCE(SUM(u.bytes), 0) FROM branch_storage_usage u WHERE u.branch_id = q.branch_id) AS bytes
+		FROM branch_storage_quotas q WHERE q.branch_id = ?`, branchID).Scan(&row).Error
+	if err != nil {
+		return err
+	}
+	if row.QuotaBytes > 0 && row.Bytes >= row.QuotaBytes {
+		return fmt.Errorf("%w (%d of %d bytes used)", ErrStorageQuotaExceeded, row.Bytes, row.QuotaBytes)
+	}
+	return nil
+}
+
+// CheckEventStorageQuota checks the quota of the branch an event belongs to
+func CheckEventStorageQuota(eventID uint) error {
+	var event struct{ BranchID *uint }
+	if err := config.DB.Model(&models.EventDetails{}).Select("branch_id").Where("id = ?", eventID).Scan(&event).Error; err != nil {
+		return err
+	}
+	if event.BranchID == nil {
+		return nil
+	}
+	return CheckBranchStorageQuota(*event.BranchID)
+}
+
+// StartStorageUsageRefresh queues a computation of the storage usage. Only one runs at a time.
+func StartStorageUsageRefresh(ctx context.Context, createdBy *uint) (*models.Job, error) {
+	if _, err := GetStorage(); err != nil {
+		return nil, err
+	}
+	var running int64
+	if err := config.DB.WithContext(ctx).Model(&models.Job{}).
+		Where("type = ? AND status IN ?", JobTypeStorageUsage, []string{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrStorageUsageRunning
+	}
+	return EnqueueJob(ctx, JobTypeStorageUsage, struct{}{}, JobOptions{CreatedBy: createdBy, MaxAttempts: 1})
+}
+
+// StartStorageUsageScheduler recomputes the storage usage every STORAGE_USAGE_INTERVAL (Go
+// duration, default 24h; "0" turns it off). Like the manifest scheduler, instances check hourly
+// and only queue a computation when the last one is older than the interval.
+func StartStorageUsageScheduler() {
+	logger := utils.BaseLogger()
+	interval := config.Jobs.StorageUsageInterval
+	if interval <= 0 {
+		return
+	}
+
+	check := min(interval/4, time.Hour)
+	go func() {
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for range ticker.C {
+			var last *time.Time
+			if err := config.DB.Model(&models.BranchStorageUsage{}).Select("MAX(computed_on)").Scan(&last).Error; err != nil {
+				logger.Error("Failed to check storage usage age", zap.Error(err))
+				continue
+			}
+			if last != nil && time.Since(*last) < interval {
+				continue
+			}
+			_, err := StartStorageUsageRefresh(context.Background(), nil)
+			if err != nil && !errors.Is(err, ErrStorageUsageRunning) && !errors.Is(err, ErrStorageNotConfigured) {
+				logger.Error("Failed to queue storage usage computation", zap.Error(err))
+			}
+		}
+	}()
+	logger.Info("Storage usage scheduler started", zap.Duration("interval", interval))
+}
+
+// storedFile is a file attributed to a branch
+type storedFile struct {
+	BranchID uint
+	Category string
+	Keys     []string
+}
+
+func runStorageUsageJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, PermanentJobError(err)
+	}
+
+	run.SetProgress(5, "Listing the bucket")
+	sizes := map[string]int64{}
+	err = storage.List(ctx, "", func(object ObjectInfo) error {
+		sizes[object.Key] = object.Size
+		if len(sizes)%10000 == 0 {
+			run.SetProgress(5, fmt.Sprintf("Listing the bucket (%d objects)", len(sizes)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing bucket: %w", err)
+	}
+
+	run.SetProgress(60, "Attributing files to branches")
+	files, err := loadStoredFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type usageKey struct {
+		branchID uint
+		category string
+	}
+	totals := map[usageKey]*models.BranchStorageUsage{}
+	counted := map[string]bool{}
+	now := time.Now()
+	for _, file := range files {
+		for _, key := range file.Keys {
+			size, ok := sizes[key]
+			if key == "" || !ok || counted[key] {
+				continue
+			}
+			counted[key] = true
+			category := file.Category
+			if category == "" {
+				category = uncategorizedMedia
+			}
+			k := usageKey{file.BranchID, category}
+			if totals[k] == nil {
+				totals[k] = &models.BranchStorageUsage{BranchID: file.BranchID, Category: category, ComputedOn: now}
+			}
+			totals[k].Objects++
+			totals[k].Bytes += size
+		}
+	}
+
+	run.SetProgress(90, "Saving usage")
+	rows := make([]models.BranchStorageUsage, 0, len(totals))
+	var bytes int64
+	for _, usage := range totals {
+		rows = append(rows, *usage)
+		bytes += usage.Bytes
+	}
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.BranchStorageUsage{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger(ctx).Info("Storage usage computed", zap.Int("rows", len(rows)), zap.Int("objects", len(counted)), zap.Int64("bytes", bytes))
+	return models.JSONB{
+		"objects":      len(sizes),
+		"attributed":   len(counted),
+		"unattributed": len(sizes) - len(counted),
+		"bytes":        bytes,
+		"rows":         len(rows),
+	}, nil
+}
+
+// loadStoredFiles returns the object keys of every file with the branch it counts against.
+// Soft-deleted rows are included: their files are kept for restores and still take space.
+func loadStoredFiles(ctx context.Context) ([]storedFile, error) {
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{"event media", `SELECT e.branch_id, m.category, m.s3_key, m.thumbnail_s3_key, m.thumbnail_medium_s3_key, m.transcoded_s3_key
+			FROM event_media m JOIN event_details e ON e.id = m.event_id WHERE e.branch_id IS NOT NULL`},
+		{"branch media", `SELECT m.branch_id, m.category, m.s3_key, m.thumbnail_s3_key, m.thumbnail_medium_s3_key, m.transcoded_s3_key
+			FROM branch_media m`},
+		{"public assets", `SELECT e.branch_id, m.category, p.s3_key, NULL, NULL, NULL
+			FROM public_assets p JOIN event_media m ON m.id = p.event_media_id JOIN event_details e ON e.id = m.event_id
+			WHERE e.branch_id IS NOT NULL`},
+		{"donation receipts", `SELECT e.branch_id, '` + donationReceiptsCategory + `', d.receipt_s3_key, NULL, NULL, NULL
+			FROM donations d JOIN event_details e ON e.id = d.event_id
+			WHERE e.branch_id IS NOT NULL AND d.receipt_s3_key IS NOT NULL AND d.receipt_s3_key <> ''`},
+	}
+
+	var files []storedFile
+	for _, q := range queries {
+		rows, err := config.DB.WithContext(ctx).Raw(q.query).Rows()
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", q.name, err)
+		}
+		for rows.Next() {
+			var (
+				branchID uint
+				category *string
+				keys     [4]*string
+			)
+			if err := rows.Scan(&branchID, &category, &keys[0], &keys[1], &keys[2], &keys[3]); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("loading %s: %w", q.name, err)
+			}
+			file := storedFile{BranchID: branchID}
+			if category != nil {
+				file.Category = *category
+			}
+			for _, key := range keys {
+				if key != nil && *key != "" {
+					file.Keys = append(file.Keys, *key)
+				}
+			}
+			files = append(files, file)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", q.name, err)
+		}
+	}
+	return files, nil
+}
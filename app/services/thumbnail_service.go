@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Decoders registered for image.Decode
+	_ "image/gif"
+	_ "image/png"
+
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Thumbnail job targets
+const (
+	ThumbnailTargetEventMedia  = "event_media"
+	ThumbnailTargetBranchMedia = "branch_media"
+)
+
+// ThumbnailSize is a generated rendition; images are scaled so the longer side fits MaxSide
+type ThumbnailSize struct {
+	Name    string
+	MaxSide int
+	Column  string // column holding the S3 key on the media tables
+}
+
+// ThumbnailSizes are generated for every uploaded image, under thumbnails/{name}/
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", MaxSide: 320, Column: "thumbnail_s3_key"},
+	{Name: "medium", MaxSide: 1024, Column: "thumbnail_medium_s3_key"},
+}
+
+// maxThumbnailPixels guards against decompression bombs (a tiny file declaring a huge canvas)
+const maxThumbnailPixels = 50_000_000
+
+// maxThumbnailSourceBytes caps how much of the original is read back from storage
+const maxThumbnailSourceBytes = 100 << 20
+
+// thumbnailPayload is the JobTypeThumbnails payload
+type thumbnailPayload struct {
+	Target   string `json:"target"`
+	ID       uint   `json:"id"`
+	S3Key    string `json:"s3_key"`
+	Filename string `json:"filename"`
+}
+
+// IsThumbnailSupported reports whether thumbnails can be generated for the content type
+func IsThumbnailSupported(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// QueueThumbnails schedules thumbnail generation for an uploaded event or branch image.
+// Like QueueOCR it never fails the upload request: if the job cannot be queued galleries
+// fall back to the original. Thumbnails are generated by the job workers from the stored
+// original; set THUMBNAILS_ENABLED=false to turn them off (e.g. when a separate image worker
+// handles the thumbnails/ prefix).
+func QueueThumbnails(ctx context.Context, target string, id uint, s3Key, filename, contentType string) {
+	if !IsThumbnailSupported(contentType) || !config.Media.Thumbnails {
+		return
+	}
+
+	payload := thumbnailPayload{Target: target, ID: id, S3Key: s3Key, Filename: filename}
+	if _, err := EnqueueJob(ctx, JobTypeThumbnails, payload, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue thumbnails", zap.String("target", target), zap.Uint("id", id), zap.Error(err))
+	}
+}
+
+func runThumbnailJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var job thumbnailPayload
+	if err := run.Decode(&job); err != nil {
+		return nil, err
+	}
+
+	// Skip media that was deleted or replaced since; a newer job covers the replacement
+	currentKey, err := thumbnailSourceKey(job.Target, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if currentKey == "" || currentKey != job.S3Key {
+		return models.JSONB{"skipped": "media was deleted or replaced"}, nil
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Get(ctx, job.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+	original, err := io.ReadAll(io.LimitReader(body, maxThumbnailSourceBytes))
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original: %w", err)
+	}
+
+	updates := map[string]interface{}{}
+	result := models.JSONB{}
+	for _, size := range ThumbnailSizes {
+		data, err := GenerateThumbnail(original, size.MaxSide)
+		if err != nil {
+			// Undecodable images will not get better on retry
+			utils.Logger(ctx).Warn("Thumbnail generation failed", zap.String("target", job.Target), zap.Uint("id", job.ID), zap.Error(err))
+			return models.JSONB{"skipped": err.Error()}, nil
+		}
+
+		name := strings.TrimSuffix(job.Filename, filepath.Ext(job.Filename)) + ".jpg"
+		uploaded, err := UploadFile(ctx, data, name, "image/jpeg", "thumbnails/"+size.Name)
+		if err != nil {
+			return nil, fmt.Errorf("thumbnail upload failed: %w", err)
+		}
+		updates[size.Column] = uploaded.S3Key
+		result[size.Name] = uploaded.S3Key
+	}
+
+	if err := SaveThumbnailKeys(job.Target, job.ID, updates); err != nil {
+		return nil, fmt.Errorf("failed to store thumbnail keys: %w", err)
+	}
+	if job.Target == ThumbnailTargetEventMedia {
+		if err := refreshPublicAssets(ctx, job.ID); err != nil {
+			return nil, fmt.Errorf("failed to publish thumbnails: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// thumbnailSourceKey returns the current S3 key of a media record, "" if it no longer exists
+func thumbnailSourceKey(target string, id uint) (string, error) {
+	var keys []string
+	var err error
+	switch target {
+	case ThumbnailTargetEventMedia:
+		err = config.DB.Model(&models.EventMedia{}).Where("id = ?", id).Pluck("s3_key", &keys).Error
+	case ThumbnailTargetBranchMedia:
+		err = config.DB.Model(&models.BranchMedia{}).Where("id = ?", id).Pluck("s3_key", &keys).Error
+	default:
+		return "", errors.New("unknown thumbnail target")
+	}
+	if err != nil || len(keys) == 0 {
+		return "", err
+	}
+	return keys[0], nil
+}
+
+// GenerateThumbnail decodes an image and returns a JPEG scaled so its longer side is at
+// most maxSide pixels. Smaller images are re-encoded without upscaling.
+func GenerateThumbnail(data []byte, maxSide int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = height * maxSide / width
+			width = maxSide
+		} else {
+			width = width * maxSide / height
+			height = maxSide
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	// JPEG has no alpha; paint transparent areas white instead of black
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveThumbnailKeys records generated thumbnail keys on a media record
+func SaveThumbnailKeys(target string, id uint, keys map[string]interface{}) error {
+	switch target {
+	case ThumbnailTargetEventMedia:
+		return config.DB.Model(&models.EventMedia{}).Where("id = ?", id).UpdateColumns(keys).Error
+	case ThumbnailTargetBranchMedia:
+		return config.DB.Model(&models.BranchMedia{}).Where("id = ?", id).UpdateColumns(keys).Error
+	default:
+		return errors.New("unknown thumbnail target")
+	}
+}
+
+// DeleteThumbnails removes the thumbnail objects (and video rendition) of a media record from
+// S3 in the background
+func DeleteThumbnails(ctx context.Context, keys ...*string) {
+	var s3Keys []string
+	for _, key := range keys {
+		if key != nil && *key != "" {
+			s3Keys = append(s3Keys, *key)
+		}
+	}
+	QueueStorageCleanup(ctx, time.Time{}, s3Keys...)
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+)
+
+// FeatureRequireTwoFactor is the feature flag (PUT /api/admin/feature-flags/auth.require_two_factor)
+// that makes two-factor authentication mandatory for admins and managers. Until they enroll,
+// AuthMiddleware rejects their requests with code "two_factor_setup_required".
+const FeatureRequireTwoFactor = "auth.require_two_factor"
+
+// TwoFactorRequired reports whether users of the role must use two-factor authentication
+func TwoFactorRequired(roleID uint) bool {
+	if roleID != models.RoleAdmin && roleID != models.RoleManager {
+		return false
+	}
+	return IsFeatureEnabled(FeatureRequireTwoFactor)
+}
+
+// TwoFactorRequiredForUser reports whether the user's role must use two-factor authentication
+func TwoFactorRequiredForUser(userID uint) (bool, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return TwoFactorRequired(user.RoleID), nil
+}
+
+// ResetUserTwoFactor removes the two-factor setup of a user who lost their authenticator and
+// recovery codes. If their role requires it they are asked to enroll again at the next sign-in.
+func ResetUserTwoFactor(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.ResetTOTP(ctx, int64(userID)); err != nil {
+		return nil, err
+	}
+	user.TOTPEnabledAt = nil
+	user.Password = ""
+	return user, nil
+}
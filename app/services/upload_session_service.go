@@ -0,0 +1,384 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Resumable uploads let volunteers on flaky connections upload large videos in chunks: a
+// session is created with the size of the file, chunks are sent in order at the offset the
+// session reports (and resent from there after a dropped connection), and completing the
+// session turns the collected parts into the stored object and its media record.
+//
+//	UPLOAD_CHUNK_BYTES   size of every chunk but the last (default 8 MiB, at least 5 MiB as S3 requires)
+//	UPLOAD_SESSION_TTL   how long a session lives after its last chunk (default 24h)
+//
+// Photos are not accepted: they are normalized (EXIF stripped, HEIC converted) in memory by
+// the regular upload endpoints.
+
+var (
+	ErrUploadSessionNotFound  = errors.New("upload session not found")
+	ErrUploadSessionCompleted = errors.New("upload session is already completed")
+	ErrUploadNotAllowed       = errors.New("file type not allowed for resumable uploads")
+	ErrUploadChunkSize        = errors.New("invalid chunk size")
+	ErrUploadIncomplete       = errors.New("upload is incomplete")
+	ErrUploadTooLarge         = errors.New("file is too large")
+)
+
+// UploadOffsetError is returned for a chunk that does not start where the session continues;
+// the client resumes from Offset
+type UploadOffsetError struct {
+	Offset int64
+}
+
+func (e *UploadOffsetError) Error() string {
+	return fmt.Sprintf("chunk must start at offset %d", e.Offset)
+}
+
+// UploadChunkBytes is UPLOAD_CHUNK_BYTES (see config.MediaConfig)
+func UploadChunkBytes() int64 {
+	return config.Media.UploadChunkBytes
+}
+
+// uploadSessionTTL is UPLOAD_SESSION_TTL (see config.MediaConfig)
+func uploadSessionTTL() time.Duration {
+	return config.Media.UploadSessionTTL
+}
+
+// UploadSessionInput describes the file of a new session. Owner, category and filename are
+// validated by the caller.
+type UploadSessionInput struct {
+	UserID      uint
+	Target      string // ThumbnailTargetEventMedia or ThumbnailTargetBranchMedia
+	OwnerID     uint   // event or branch ID
+	Category    string
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+// CreateUploadSession starts a resumable upload
+func CreateUploadSession(ctx context.Context, input UploadSessionInput) (*models.UploadSession, error) {
+	if input.Target != ThumbnailTargetEventMedia && input.Target != ThumbnailTargetBranchMedia {
+		return nil, errors.New("unknown upload target")
+	}
+	input.ContentType = strings.ToLower(strings.TrimSpace(strings.Split(input.ContentType, ";")[0]))
+	fileType := GetFileTypeFromContentType(input.ContentType)
+	if fileType == "image" || !ValidateFileType(input.ContentType) {
+		return nil, ErrUploadNotAllowed
+	}
+	if input.Size <= 0 {
+		return nil, fmt.Errorf("%w: size must be positive", ErrUploadChunkSize)
+	}
+	if err := ValidateFileSize(input.Size, fileType); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadTooLarge, err)
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s%s", GetFolderFromFileType(fileType), uuid.New().String(), filepath.Ext(input.Filename))
+	uploadID, err := multipartStorageFor(storage).CreateMultipartUpload(ctx, key, input.ContentType, map[string]string{
+		"original-filename": input.Filename,
+		"upload-date":       time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.UploadSession{
+		ID:              uuid.New().String(),
+		UserID:          input.UserID,
+		Target:          input.Target,
+		OwnerID:         input.OwnerID,
+		Category:        input.Category,
+		Filename:        input.Filename,
+		ContentType:     input.ContentType,
+		Size:            input.Size,
+		ChunkSize:       UploadChunkBytes(),
+		S3Key:           key,
+		StorageUploadID: uploadID,
+		Parts:           models.JSONB{},
+		ExpiresAt:       time.Now().Add(uploadSessionTTL()),
+	}
+	if err := config.DB.WithContext(ctx).Create(session).Error; err != nil {
+		_ = multipartStorageFor(storage).AbortMultipartUpload(ctx, key, uploadID)
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetUploadSession returns a session of the user; expired sessions are not found
+func GetUploadSession(ctx context.Context, id string, userID uint) (*models.UploadSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadSessionNotFound
+	}
+	var session models.UploadSession
+	err := config.DB.WithContext(ctx).Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, time.Now()).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// WriteUploadChunk stores the chunk starting at offset. Every chunk but the last must be
+// exactly ChunkSize bytes; a chunk at another offset than the session's is rejected with an
+// *UploadOffsetError so the client can resume from the right place.
+func WriteUploadChunk(ctx context.Context, session *models.UploadSession, offset int64, body io.Reader) (*models.UploadSession, error) {
+	if session.CompletedOn != nil {
+		return nil, ErrUploadSessionCompleted
+	}
+	if offset != session.ReceivedBytes {
+		return nil, &UploadOffsetError{Offset: session.ReceivedBytes}
+	}
+	expected := session.ChunkSize
+	if remaining := session.Size - offset; remaining < expected {
+		expected = remaining
+	}
+	if expected <= 0 {
+		return nil, fmt.Errorf("%w: the whole file has been received", ErrUploadChunkSize)
+	}
+
+	// One chunk is buffered so its size is known before it is stored
+	data, err := io.ReadAll(io.LimitReader(body, expected+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != expected {
+		return nil, fmt.Errorf("%w: expected %d bytes at offset %d, got %d", ErrUploadChunkSize, expected, offset, len(data))
+	}
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	number := int32(offset/session.ChunkSize) + 1
+	etag, err := multipartStorageFor(storage).UploadPart(ctx, session.S3Key, session.StorageUploadID, number, bytes.NewReader(data), expected)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := models.JSONB{}
+	for k, v := range session.Parts {
+		parts[k] = v
+	}
+	parts[strconv.Itoa(int(number))] = etag
+	updates := map[string]interface{}{
+		"received_bytes": offset + expected,
+		"parts":          parts,
+		"expires_at":     time.Now().Add(uploadSessionTTL()),
+		"updated_on":     time.Now(),
+	}
+	// The offset guards against two clients sending the same chunk at once
+	result := config.DB.WithContext(ctx).Model(&models.UploadSession{}).
+		Where("id = ? AND received_bytes = ? AND completed_on IS NULL", session.ID, offset).UpdateColumns(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		current, err := GetUploadSession(ctx, session.ID, session.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &UploadOffsetError{Offset: current.ReceivedBytes}
+	}
+	return GetUploadSession(ctx, session.ID, session.UserID)
+}
+
+// UploadCompletion is the media record a completed session created
+type UploadCompletion struct {
+	MediaID    uint    `json:"media_id"`
+	S3Key      string  `json:"s3_key"`
+	FileType   string  `json:"file_type"`
+	Category   string  `json:"category,omitempty"`
+	ScanStatus *string `json:"scan_status,omitempty"`
+}
+
+// CompleteUploadSession assembles the uploaded parts into the stored object and creates the
+// event or branch media record, queueing the scan and transcoding like the upload endpoints.
+// Completing a completed session returns its media again.
+func CompleteUploadSession(ctx context.Context, session *models.UploadSession) (*UploadCompletion, error) {
+	fileType := GetFileTypeFromContentType(session.ContentType)
+	if session.CompletedOn != nil && session.MediaID != nil {
+		completion := &UploadCompletion{MediaID: *session.MediaID, S3Key: session.S3Key, FileType: fileType, Category: session.Category}
+		return completion, nil
+	}
+	if session.ReceivedBytes != session.Size {
+		return nil, fmt.Errorf("%w: %d of %d bytes received", ErrUploadIncomplete, session.ReceivedBytes, session.Size)
+	}
+
+	parts := make([]CompletedPart, 0, len(session.Parts))
+	for number, etag := range session.Parts {
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("invalid part %q", number)
+		}
+		parts = append(parts, CompletedPart{Number: int32(n), ETag: fmt.Sprint(etag)})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	storage, err := GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	if err := multipartStorageFor(storage).CompleteMultipartUpload(ctx, session.S3Key, session.StorageUploadID, parts); err != nil {
+		return nil, err
+	}
+
+	completion := &UploadCompletion{S3Key: session.S3Key, FileType: fileType, Category: session.Category, ScanStatus: UploadScanStatus()}
+	created := false
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A concurrent completion of the same session may have created the media already
+		var locked models.UploadSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", session.ID).Error; err != nil {
+			return err
+		}
+		if locked.MediaID != nil {
+			completion.MediaID = *locked.MediaID
+			return nil
+		}
+		created = true
+		switch session.Target {
+		case ThumbnailTargetEventMedia:
+			media := models.EventMedia{
+				EventID:          session.OwnerID,
+				S3Key:            session.S3Key,
+				OriginalFilename: session.Filename,
+				FileType:         fileType,
+				Category:         session.Category,
+				CompanyName:      session.Filename, // Keep for backward compatibility
+				FirstName:        "Uploaded",
+				LastName:         "File",
+				ScanStatus:       completion.ScanStatus,
+				TranscodeStatus:  UploadTranscodeStatus(session.ContentType),
+			}
+			var mediaType models.MediaCoverageType
+			if err := tx.First(&mediaType).Error; err == nil {
+				media.MediaCoverageTypeID = mediaType.ID
+			}
+			if err := tx.Create(&media).Error; err != nil {
+				return err
+			}
+			completion.MediaID = media.ID
+		default:
+			media := models.BranchMedia{
+				BranchID:         session.OwnerID,
+				S3Key:            session.S3Key,
+				OriginalFilename: session.Filename,
+				FileType:         fileType,
+				Name:             session.Filename,
+				Category:         session.Category,
+				ScanStatus:       completion.ScanStatus,
+				TranscodeStatus:  UploadTranscodeStatus(session.ContentType),
+			}
+			if err := tx.Create(&media).Error; err != nil {
+				return err
+			}
+			completion.MediaID = media.ID
+		}
+		now := time.Now()
+		return tx.Model(&models.UploadSession{}).Where("id = ?", session.ID).
+			UpdateColumns(map[string]interface{}{"media_id": completion.MediaID, "completed_on": now, "updated_on": now}).Error
+	})
+	if err != nil {
+		// The object has no record; the storage cleanup removes it
+		QueueStorageCleanup(ctx, time.Time{}, session.S3Key)
+		return nil, err
+	}
+	if !created {
+		return completion, nil
+	}
+
+	scanTarget := MediaScanTargetEventMedia
+	if session.Target == ThumbnailTargetBranchMedia {
+		scanTarget = MediaScanTargetBranchMedia
+	}
+	QueueMediaScan(ctx, scanTarget, completion.MediaID, session.S3Key)
+	QueueTranscode(ctx, session.Target, completion.MediaID, session.S3Key, session.ContentType)
+	PublishMediaUploaded(ctx, session.Target, completion.MediaID)
+	return completion, nil
+}
+
+// AbortUploadSession discards a session that is not completed and its uploaded parts
+func AbortUploadSession(ctx context.Context, session *models.UploadSession) error {
+	if session.CompletedOn != nil {
+		return ErrUploadSessionCompleted
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return err
+	}
+	if err := multipartStorageFor(storage).AbortMultipartUpload(ctx, session.S3Key, session.StorageUploadID); err != nil {
+		return err
+	}
+	return config.DB.WithContext(ctx).Delete(&models.UploadSession{}, "id = ?", session.ID).Error
+}
+
+// StartUploadSessionCleanup aborts expired upload sessions every hour
+func StartUploadSessionCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := DeleteExpiredUploadSessions(context.Background(), time.Now()); err != nil {
+				utils.BaseLogger().Error("Failed to delete expired upload sessions", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// DeleteExpiredUploadSessions aborts the multipart uploads of sessions that expired before
+// cutoff, deletes the sessions and returns how many were deleted. Sessions whose upload
+// cannot be aborted are kept for the next run.
+func DeleteExpiredUploadSessions(ctx context.Context, cutoff time.Time) (int64, error) {
+	var sessions []models.UploadSession
+	if err := config.DB.WithContext(ctx).Where("expires_at < ?", cutoff).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+	storage, err := GetStorage()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, session := range sessions {
+		if session.CompletedOn == nil {
+			if err := multipartStorageFor(storage).AbortMultipartUpload(ctx, session.S3Key, session.StorageUploadID); err != nil {
+				utils.BaseLogger().Warn("Failed to abort expired upload", zap.String("session", session.ID), zap.Error(err))
+				continue
+			}
+		}
+		if err := config.DB.WithContext(ctx).Delete(&models.UploadSession{}, "id = ?", session.ID).Error; err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		utils.BaseLogger().Info("Deleted expired upload sessions", zap.Int64("count", deleted))
+	}
+	return deleted, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// Errors of the admin user management endpoints
+var (
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrOwnAccountChange = errors.New("admins cannot deactivate their own account or remove their own admin role")
+	ErrInvalidUserList  = errors.New("invalid user list filter")
+)
+
+// User statuses of UserListFilter.Status
+const (
+	UserStatusActive      = "active"
+	UserStatusDeactivated = "deactivated"
+)
+
+// UserListFilter holds the filters of the admin user list
+type UserListFilter struct {
+	RoleID   uint
+	BranchID uint
+	Status   string // active or deactivated, empty for both
+	Search   string // name or email
+	Limit    int
+	Offset   int
+}
+
+// ListUsers returns a page of users (excluding deleted) and the total number matching the
+// filter, ordered by name. Password hashes are not returned.
+func ListUsers(filter UserListFilter) ([]models.User, int64, error) {
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.Model(&models.User{}).Where("is_deleted = ?", false)
+	if filter.RoleID != 0 {
+		query = query.Where("role_id = ?", filter.RoleID)
+	}
+	if filter.BranchID != 0 {
+		query = query.Where("branch_id = ?", filter.BranchID)
+	}
+	switch filter.Status {
+	case "":
+	case UserStatusActive:
+		query = query.Where("disabled_at IS NULL")
+	case UserStatusDeactivated:
+		query = query.Where("disabled_at IS NOT NULL")
+	default:
+		return nil, 0, fmt.Errorf("%w: status must be %s or %s", ErrInvalidUserList, UserStatusActive, UserStatusDeactivated)
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := []models.User{}
+	if err := query.Preload("Role").Order("name, id").Limit(filter.Limit).Offset(filter.Offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range users {
+		users[i].Password = ""
+		users[i].Token = ""
+	}
+	return users, total, nil
+}
+
+// DeactivateUser blocks a user from signing in and signs them out everywhere. The account
+// and its data are kept (unlike DeleteUser) so it can be reactivated. actorID is the admin.
+func DeactivateUser(ctx context.Context, userID, actorID uint) (*models.User, error) {
+	if userID == actorID {
+		return nil, ErrOwnAccountChange
+	}
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt == nil {
+		now := time.Now()
+		if err := config.DB.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"disabled_at": now, "updated_on": now}).Error; err != nil {
+			return nil, err
+		}
+		user.DisabledAt = &now
+	}
+	if _, err := auth.RevokeAllSessions(ctx, int64(userID)); err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// ReactivateUser lets a deactivated user sign in again
+func ReactivateUser(userID uint) (*models.User, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		if err := config.DB.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"disabled_at": nil, "updated_on": time.Now()}).Error; err != nil {
+			return nil, err
+		}
+		user.DisabledAt = nil
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// AssignUserRole gives a user the role. Admins cannot take the admin role from themselves,
+// so there is always an admin left. The new role applies from the user's next request.
+func AssignUserRole(userID, roleID, actorID uint) (*models.User, error) {
+	if userID == actorID && roleID != models.RoleAdmin {
+		return nil, ErrOwnAccountChange
+	}
+	var role models.Role
+	if err := config.DB.First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.RoleID != roleID {
+		if err := config.DB.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"role_id": roleID, "updated_on": time.Now()}).Error; err != nil {
+			return nil, err
+		}
+		user.RoleID = roleID
+	}
+	user.Role = role
+	user.Password = ""
+	return user, nil
+}
+
+// AssignUserBranch sets the branch a user coordinates and the region whose requests they
+// review (nil clears either). The branch decides the user's branch scope (see BranchScopeFor),
+// so only admins change it; the new scope applies from the user's next request.
+func AssignUserBranch(userID uint, branchID, regionID *uint) (*models.User, error) {
+	if branchID != nil {
+		var count int64
+		if err := config.DB.Model(&models.Branch{}).Where("id = ?", *branchID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrBranchNotFound
+		}
+	}
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"branch_id": branchID, "region_id": regionID, "updated_on": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+	user.BranchID = branchID
+	user.RegionID = regionID
+	user.Password = ""
+	return user, nil
+}
+
+// RemoveUserRole takes the user's role away, leaving them the read-only user role
+func RemoveUserRole(userID, actorID uint) (*models.User, error) {
+	return AssignUserRole(userID, models.RoleUser, actorID)
+}
+
+// ForceLogoutUser revokes all sessions and access tokens of a user and returns the number
+// of sessions revoked
+func ForceLogoutUser(ctx context.Context, userID uint) (int64, error) {
+	if _, err := GetUserByID(userID); err != nil {
+		return 0, err
+	}
+	return auth.RevokeAllSessions(ctx, int64(userID))
+}
+
+// ListUserSessions returns the active sessions (signed-in devices) of a user
+func ListUserSessions(ctx context.Context, userID uint) ([]auth.Session, error) {
+	if _, err := GetUserByID(userID); err != nil {
+		return nil, err
+	}
+	return auth.ListSessions(ctx, int64(userID), "")
+}
+
+// RevokeUserSession signs a user out of one session; its access tokens are rejected from
+// then on
+func RevokeUserSession(ctx context.Context, userID uint, sessionID string) error {
+	revoked, err := auth.RevokeUserSession(ctx, int64(userID), sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return auth.ErrSessionNotFound
+	}
+	return nil
+}
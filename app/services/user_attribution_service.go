@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"gorm.io/gorm"
+)
+
+// AuditActionReassign marks the audit entry of an attribution reassignment
+const AuditActionReassign = "reassign"
+
+// attributionTextColumns hold free-text created_by/updated_by values, usually the user's name
+// or email as sent by the client
+var attributionTextColumns = []attributionColumn{
+	{"users", "created_by"}, {"users", "updated_by"},
+	{"branches", "created_by"}, {"branches", "updated_by"},
+	{"branch_infrastructure", "created_by"}, {"branch_infrastructure", "updated_by"},
+	{"branch_member", "created_by"}, {"branch_member", "updated_by"},
+	{"areas", "created_by"}, {"areas", "updated_by"},
+	{"event_details", "created_by"}, {"event_details", "updated_by"},
+	{"special_guests", "created_by"}, {"special_guests", "updated_by"},
+	{"volunteers", "created_by"}, {"volunteers", "updated_by"},
+	{"donations", "created_by"}, {"donations", "updated_by"},
+	{"promotion_material_details", "created_by"}, {"promotion_material_details", "updated_by"},
+	{"event_media", "created_by"}, {"event_media", "updated_by"},
+	{"branch_media", "created_by"}, {"branch_media", "updated_by"},
+}
+
+// attributionIDColumns hold the user ID of whoever created, changed or reviewed a record
+var attributionIDColumns = []attributionColumn{
+	{"audit_logs", "actor_id"},
+	{"event_status_history", "changed_by"},
+	{"branch_change_requests", "requested_by"}, {"branch_change_requests", "reviewed_by"},
+	{"coordinator_handovers", "created_by"},
+	{"event_recurrences", "created_by"},
+	{"donations", "voided_by"},
+	{"feature_flags", "updated_by"},
+	{"jobs", "created_by"},
+	{"media_manifests", "created_by"},
+	{"media_tombstones", "deleted_by"},
+	{"legal_holds", "placed_by"}, {"legal_holds", "released_by"},
+	{"webhook_subscriptions", "created_by"},
+}
+
+type attributionColumn struct {
+	table  string
+	column string
+}
+
+// AttributionChange is the number of rows of one column that are (or would be) re-pointed
+type AttributionChange struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Rows   int64  `json:"rows"`
+}
+
+// AttributionResult is the outcome of a reassignment; in a dry run nothing has been written
+type AttributionResult struct {
+	DryRun       bool                `json:"dry_run"`
+	FromUserID   uint                `json:"from_user_id"`
+	ToUserID     uint                `json:"to_user_id"`
+	Replacements map[string]string   `json:"replacements"` // old created_by/updated_by text -> new
+	Changes      []AttributionChange `json:"changes"`
+	TotalRows    int64               `json:"total_rows"`
+}
+
+// ReassignUserAttribution re-points the records attributed to one user account to another, e.g.
+// after duplicate accounts were merged: created_by/updated_by and the other user ID columns that
+// attribute a record to a user, audit log actors included, in a single transaction. Text columns
+// matching the old account's email are set to the new email; matching its name or one of
+// fromNames (e.g. its name before a rename), to the new name, case-insensitively. With
+// fromUserID == toUserID only the text columns are rewritten, for a renamed user. Dry runs roll
+// back and report the counts. The reassignment itself is audited against the target user.
+func ReassignUserAttribution(fromUserID, toUserID uint, fromNames []string, dryRun bool, actor AuditActor) (*AttributionResult, error) {
+	if fromUserID == toUserID && len(fromNames) == 0 {
+		return nil, fmt.Errorf("%w: from_names is required when reassigning a user to itself", ErrDataFixInvalidArgs)
+	}
+
+	// The old account may already be deleted by the merge
+	var from, to models.User
+	if err := config.DB.Unscoped().First(&from, fromUserID).Error; err != nil {
+		return nil, fmt.Errorf("user %d %w", fromUserID, ErrDataFixNotFound)
+	}
+	if err := config.DB.First(&to, toUserID).Error; err != nil {
+		return nil, fmt.Errorf("user %d %w", toUserID, ErrDataFixNotFound)
+	}
+
+	// Spellings of the old account, grouped by replacement
+	replacements := map[string]string{}
+	addSpelling := func(spelling, replacement string) {
+		key := strings.ToLower(strings.TrimSpace(spelling))
+		if key == "" || replacement == "" || strings.EqualFold(key, replacement) {
+			return
+		}
+		if _, ok := replacements[key]; !ok {
+			replacements[key] = replacement
+		}
+	}
+	addSpelling(from.Email, to.Email)
+	addSpelling(from.Name, to.Name)
+	for _, name := range fromNames {
+		addSpelling(name, to.Name)
+	}
+	byReplacement := map[string][]string{}
+	for spelling, replacement := range replacements {
+		byReplacement[replacement] = append(byReplacement[replacement], spelling)
+	}
+
+	result := &AttributionResult{
+		DryRun:       dryRun,
+		FromUserID:   from.ID,
+		ToUserID:     to.ID,
+		Replacements: replacements,
+		Changes:      []AttributionChange{},
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, target := range attributionTextColumns {
+			var rows int64
+			for replacement, spellings := range byReplacement {
+				update := tx.Table(target.table).
+					Where(fmt.Sprintf("LOWER(BTRIM(%s)) IN ?", target.column), spellings).
+					UpdateColumn(target.column, replacement)
+				if update.Error != nil {
+					return fmt.Errorf("%s.%s: %w", target.table, target.column, update.Error)
+				}
+				rows += update.RowsAffected
+			}
+			result.add(target, rows)
+		}
+		if from.ID != to.ID {
+			for _, target := range attributionIDColumns {
+				update := tx.Table(target.table).Where(target.column+" = ?", from.ID).UpdateColumn(target.column, to.ID)
+				if update.Error != nil {
+					return fmt.Errorf("%s.%s: %w", target.table, target.column, update.Error)
+				}
+				result.add(target, update.RowsAffected)
+			}
+		}
+		if result.TotalRows == 0 {
+			return ErrDataFixNoChanges
+		}
+		if dryRun {
+			return errDataFixDryRun
+		}
+
+		changes := models.JSONB{
+			"from_user_id": from.ID,
+			"to_user_id":   to.ID,
+			"replacements": replacements,
+			"rows":         result.TotalRows,
+		}
+		for _, change := range result.Changes {
+			changes[change.Table+"."+change.Column] = change.Rows
+		}
+		if err := tx.Create(actor.auditEntry(AuditEntityUser, to.ID, AuditActionReassign, changes, time.Now())).Error; err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDataFixDryRun) {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *AttributionResult) add(target attributionColumn, rows int64) {
+	if rows == 0 {
+		return
+	}
+	r.Changes = append(r.Changes, AttributionChange{Table: target.table, Column: target.column, Rows: rows})
+	r.TotalRows += rows
+}
@@ -1,25 +1,21 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/services/listquery"
 	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"gorm.io/gorm"
 )
 
-// Helper: Generate random 8-character alphanumeric password
-func generateRandomPassword() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, 8)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
-	}
-	return string(b)
+// Helper: Generate a random temporary password that satisfies the password policy
+func generateRandomPassword() (string, error) {
+	return auth.CurrentPasswordPolicy().GenerateTemporaryPassword()
 }
 
 // HashPassword hashes a password using Argon2id (same as auth service)
@@ -49,7 +45,10 @@ func CreateUser(user *models.User) error {
 		return errors.New("email already exists")
 	}
 
-	plainPassword := generateRandomPassword()
+	plainPassword, err := generateRandomPassword()
+	if err != nil {
+		return err
+	}
 	hashedPassword, err := HashPassword(plainPassword)
 	if err != nil {
 		return err
@@ -59,11 +58,17 @@ func CreateUser(user *models.User) error {
 	user.CreatedOn = time.Now()
 	now := time.Now()
 	user.UpdatedOn = &now
+	// The generated password is temporary: the user has to pick their own on first login
+	user.MustChangePassword = true
+	user.PasswordChangedAt = &now
 
 	// Create user record using GORM
 	if err := config.DB.Create(user).Error; err != nil {
 		return err
 	}
+	if err := config.DB.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: hashedPassword}).Error; err != nil {
+		return err
+	}
 
 	// Set email_verified_at for auth system compatibility
 	// Admin-created users should be automatically verified so they can login immediately
@@ -72,15 +77,36 @@ func CreateUser(user *models.User) error {
 		UPDATE users SET email_verified_at = NOW() WHERE id = ? AND (email_verified_at IS NULL OR email_verified_at = '1970-01-01'::timestamp)
 	`, user.ID)
 
-	// Return the plain password to the caller for display
+	PublishWebhookEvent(context.Background(), WebhookEventUserCreated, nil, map[string]interface{}{
+		"user_id": user.ID,
+		"name":    user.Name,
+		"email":   user.Email,
+		"role_id": user.RoleID,
+	})
+
+	// Hand the plain password back so the caller can send it to the user
 	user.Password = plainPassword
 	return nil
 }
 
-// GetAllUsers fetches all users (excluding deleted)
-func GetAllUsers() ([]models.User, error) {
+// UserListSchema is what GET /users may be sorted and filtered by (see listquery). Without a
+// sort the newest users come first.
+var UserListSchema = listquery.NewSchema("id", map[string]listquery.Field{
+	"id":            {Column: "id", Type: listquery.Int, Sortable: true},
+	"name":          {Column: "name", Type: listquery.String, Sortable: true},
+	"email":         {Column: "email", Type: listquery.String, Sortable: true},
+	"role_id":       {Column: "role_id", Type: listquery.Int},
+	"branch_id":     {Column: "branch_id", Type: listquery.Int},
+	"region_id":     {Column: "region_id", Type: listquery.Int},
+	"created_on":    {Column: "created_on", Type: listquery.Time, Sortable: true},
+	"last_login_on": {Column: "last_login_on", Type: listquery.Time, Sortable: true},
+}, listquery.Sort{Field: "id", Desc: true})
+
+// GetAllUsers fetches all users (excluding deleted), sorted and filtered by a query parsed with
+// UserListSchema
+func GetAllUsers(query *listquery.Query) ([]models.User, error) {
 	var users []models.User
-	if err := config.DB.Preload("Role").Where("is_deleted = ?", false).Find(&users).Error; err != nil {
+	if err := query.Apply(config.DB).Preload("Role").Where("is_deleted = ?", false).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -129,8 +155,20 @@ func GetUserByID(userID uint) (*models.User, error) {
 
 var ErrUserNotFound = errors.New("user not found")
 
-// UpdateUser updates user details
-func UpdateUser(userID uint, updatedData map[string]interface{}) error {
+// ErrTwoFactorColumns is returned when an update touches the totp_* columns: only two-factor
+// enrollment, DisableTOTP and ResetUserTwoFactor write them, so a second factor cannot be
+// switched off by a profile update
+var ErrTwoFactorColumns = errors.New("two-factor settings cannot be changed by a user update")
+
+// UpdateUser updates user details. version is the user version the client last read; when set
+// the update fails with a VersionConflictError if the user has been updated since.
+func UpdateUser(userID uint, updatedData map[string]interface{}, version *int) error {
+	for column := range updatedData {
+		if strings.HasPrefix(column, "totp_") {
+			return ErrTwoFactorColumns
+		}
+	}
+
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -155,9 +193,19 @@ func UpdateUser(userID uint, updatedData map[string]interface{}) error {
 	now := time.Now()
 	updatedData["updated_on"] = &now
 
-	if err := config.DB.Model(&user).Updates(updatedData).Error; err != nil {
+	updated, err := updateVersioned(config.DB, &user, version, updatedData)
+	if err != nil {
 		return err
 	}
+	if !updated {
+		current, err := GetUserByID(userID)
+		if err != nil {
+			return err
+		}
+		current.Password = ""
+		current.Token = ""
+		return &VersionConflictError{Version: current.Version, Current: current}
+	}
 	return nil
 }
 
@@ -196,25 +244,13 @@ func ChangePassword(userID uint, oldPassword, newPassword string) error {
 		return errors.New("old password is incorrect")
 	}
 
-	// Hash new password
-	hashedPassword, err := HashPassword(newPassword)
-	if err != nil {
-		return err
-	}
-
-	// Update password
-	now := time.Now()
-	if err := config.DB.Model(&user).Updates(map[string]interface{}{
-		"password":   hashedPassword,
-		"updated_on": &now,
-	}).Error; err != nil {
-		return err
-	}
-
-	return nil
+	// Update password (checked against the policy and password history, clears must_change_password)
+	return auth.SetPassword(context.Background(), int64(userID), newPassword, false)
 }
 
-// ResetPassword resets a user's password (admin only, generates new password)
+// ResetPassword resets a user's password (admin only, generates a temporary password the
+// user must change on next login). It fails with ErrCredentialsUndeliverable, keeping the
+// current password, when the new one could not be sent to the user.
 func ResetPassword(userID uint) (string, error) {
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
@@ -223,19 +259,16 @@ func ResetPassword(userID uint) (string, error) {
 		}
 		return "", err
 	}
+	// Keep the current password when the new one could not reach the user
+	if !CanDeliverCredentials(&user) {
+		return "", ErrCredentialsUndeliverable
+	}
 
-	plainPassword := generateRandomPassword()
-	hashedPassword, err := HashPassword(plainPassword)
+	plainPassword, err := generateRandomPassword()
 	if err != nil {
 		return "", err
 	}
-
-	// Update password
-	now := time.Now()
-	if err := config.DB.Model(&user).Updates(map[string]interface{}{
-		"password":   hashedPassword,
-		"updated_on": &now,
-	}).Error; err != nil {
+	if err := auth.SetPassword(context.Background(), int64(user.ID), plainPassword, true); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+)
+
+// Videos recorded on phones and cameras (MOV, MKV, AVI, WMV) often do not play in browsers.
+// When a transcoder is configured they are converted after upload into an H.264/AAC MP4 stored
+// under transcoded/, and listings serve that rendition as url (the upload stays available as
+// original_url and through the download endpoint). MEDIA_TRANSCODER names the ffmpeg binary
+// (e.g. "ffmpeg"); a hosted service such as AWS MediaConvert can be plugged in with
+// SetVideoTranscoder.
+
+// ErrTranscodeFailed is returned by transcoders for videos that cannot be converted; retrying
+// does not help
+var ErrTranscodeFailed = errors.New("video could not be transcoded")
+
+const (
+	transcodedPrefix = "transcoded"
+	// transcodeJobTimeout bounds one attempt; long recordings take a while on small workers
+	transcodeJobTimeout = 2 * time.Hour
+	// transcodeHeartbeat keeps the job from being reaped as stale while the transcoder runs
+	transcodeHeartbeat = time.Minute
+)
+
+// VideoTranscoder converts a stored video into a browser-playable MP4 stored next to it and
+// returns the key of the rendition
+type VideoTranscoder interface {
+	Name() string
+	Transcode(ctx context.Context, sourceKey string) (string, error)
+}
+
+// FFmpegTranscoder downloads the video to a temporary directory, converts it with ffmpeg and
+// uploads the result
+type FFmpegTranscoder struct {
+	Command string
+}
+
+func (t FFmpegTranscoder) Name() string { return filepath.Base(t.Command) }
+
+func (t FFmpegTranscoder) Transcode(ctx context.Context, sourceKey string) (string, error) {
+	storage, err := GetStorage()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "transcode-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input"+filepath.Ext(sourceKey))
+	if err := downloadObject(ctx, storage, sourceKey, input); err != nil {
+		return "", fmt.Errorf("failed to read original: %w", err)
+	}
+
+	output := filepath.Join(dir, "output.mp4")
+	cmd := exec.CommandContext(ctx, t.Command,
+		"-hide_banner", "-loglevel", "error", "-nostdin", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		// H.264 needs even dimensions; yuv420p is the only pixel format every browser decodes
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2", "-pix_fmt", "yuv420p",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		"-c:a", "aac", "-b:a", "128k",
+		// Index at the front so playback starts before the whole file is downloaded
+		"-movflags", "+faststart",
+		output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("%w: %s: %v: %s", ErrTranscodeFailed, t.Name(), err, strings.TrimSpace(string(out)))
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	key := fmt.Sprintf("%s/%s.mp4", transcodedPrefix, uuid.New().String())
+	if err := storage.Upload(ctx, key, file, "video/mp4", map[string]string{
+		"source-key":  sourceKey,
+		"upload-date": time.Now().Format(time.RFC3339),
+	}); err != nil {
+		return "", fmt.Errorf("rendition upload failed: %w", err)
+	}
+	return key, nil
+}
+
+// downloadObject copies a stored object into a local file
+func downloadObject(ctx context.Context, storage Storage, key, path string) error {
+	body, err := storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+var (
+	videoTranscoder     VideoTranscoder
+	videoTranscoderOnce sync.Once
+)
+
+// SetVideoTranscoder overrides the transcoder (e.g. a MediaConvert integration, or a fake in tests)
+func SetVideoTranscoder(transcoder VideoTranscoder) {
+	videoTranscoderOnce.Do(func() {})
+	videoTranscoder = transcoder
+}
+
+// getVideoTranscoder returns the ffmpeg transcoder named by MEDIA_TRANSCODER, nil when videos
+// are served as uploaded
+func getVideoTranscoder() VideoTranscoder {
+	videoTranscoderOnce.Do(func() {
+		command := config.Media.Transcoder
+		if command == "" {
+			return
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			utils.BaseLogger().Warn("MEDIA_TRANSCODER not found, videos are served as uploaded", zap.String("command", command), zap.Error(err))
+			return
+		}
+		videoTranscoder = FFmpegTranscoder{Command: command}
+	})
+	return videoTranscoder
+}
+
+// NeedsTranscode reports whether a video of the content type has to be transcoded to play in
+// browsers. MP4 and WebM play as uploaded.
+func NeedsTranscode(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch contentType {
+	case "video/mp4", "video/webm":
+		return false
+	}
+	return strings.HasPrefix(contentType, "video/")
+}
+
+// UploadTranscodeStatus is the transcode status new uploads are stored with: pending for
+// videos QueueTranscode will convert, nil otherwise
+func UploadTranscodeStatus(contentType string) *string {
+	if !NeedsTranscode(contentType) || getVideoTranscoder() == nil {
+		return nil
+	}
+	status := models.MediaTranscodeStatusPending
+	return &status
+}
+
+// transcodePayload is the JobTypeTranscode payload
+type transcodePayload struct {
+	Target string `json:"target"` // event_media or branch_media
+	ID     uint   `json:"id"`
+	S3Key  string `json:"s3_key"`
+}
+
+// QueueTranscode schedules the conversion of an uploaded video stored with
+// UploadTranscodeStatus. Like QueueThumbnails it never fails the upload; if the job cannot be
+// queued the original keeps being served.
+func QueueTranscode(ctx context.Context, target string, id uint, s3Key, contentType string) {
+	if UploadTranscodeStatus(contentType) == nil {
+		return
+	}
+	payload := transcodePayload{Target: target, ID: id, S3Key: s3Key}
+	if _, err := EnqueueJob(ctx, JobTypeTranscode, payload, JobOptions{}); err != nil {
+		utils.Logger(ctx).Warn("Failed to queue video transcode", zap.String("target", target), zap.Uint("id", id), zap.Error(err))
+		saveTranscodeResult(ctx, target, id, s3Key, nil, err)
+	}
+}
+
+func runTranscodeJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var job transcodePayload
+	if err := run.Decode(&job); err != nil {
+		return nil, err
+	}
+	transcoder := getVideoTranscoder()
+	if transcoder == nil {
+		err := errors.New("no video transcoder configured")
+		saveTranscodeResult(ctx, job.Target, job.ID, job.S3Key, nil, err)
+		return nil, PermanentJobError(err)
+	}
+
+	// Skip media that was deleted or replaced since; a newer job covers the replacement
+	currentKey, err := thumbnailSourceKey(job.Target, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if currentKey == "" || currentKey != job.S3Key {
+		return models.JSONB{"skipped": "media was deleted or replaced"}, nil
+	}
+
+	run.SetProgress(0, "Transcoding with "+transcoder.Name())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(transcodeHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				run.SetProgress(0, "Transcoding with "+transcoder.Name())
+			}
+		}
+	}()
+
+	key, err := transcoder.Transcode(ctx, job.S3Key)
+	if err != nil {
+		// Broken videos fail at once, other errors once the attempts are used up; the
+		// original is served either way
+		if errors.Is(err, ErrTranscodeFailed) || run.Job.Attempts >= run.Job.MaxAttempts {
+			utils.Logger(ctx).Warn("Video transcode failed", zap.String("target", job.Target), zap.Uint("id", job.ID), zap.Error(err))
+			saveTranscodeResult(ctx, job.Target, job.ID, job.S3Key, nil, err)
+		}
+		if errors.Is(err, ErrTranscodeFailed) {
+			return models.JSONB{"skipped": err.Error()}, nil
+		}
+		return nil, err
+	}
+
+	if !saveTranscodeResult(ctx, job.Target, job.ID, job.S3Key, &key, nil) {
+		QueueStorageCleanup(ctx, time.Time{}, key)
+		return models.JSONB{"skipped": "media was deleted or replaced"}, nil
+	}
+	return models.JSONB{"transcoded_s3_key": key}, nil
+}
+
+// saveTranscodeResult records the rendition (or the failure) on a media record, unless its file
+// was replaced in the meantime. It reports whether the record was updated.
+func saveTranscodeResult(ctx context.Context, target string, id uint, s3Key string, renditionKey *string, transcodeErr error) bool {
+	updates := map[string]interface{}{
+		"transcode_status":  models.MediaTranscodeStatusReady,
+		"transcode_detail":  "",
+		"transcoded_s3_key": renditionKey,
+	}
+	if transcodeErr != nil {
+		detail := transcodeErr.Error()
+		if len(detail) > 500 {
+			detail = detail[:500]
+		}
+		updates["transcode_status"] = models.MediaTranscodeStatusFailed
+		updates["transcode_detail"] = detail
+	}
+
+	var model interface{}
+	switch target {
+	case ThumbnailTargetEventMedia:
+		model = &models.EventMedia{}
+	case ThumbnailTargetBranchMedia:
+		model = &models.BranchMedia{}
+	default:
+		return false
+	}
+	result := config.DB.WithContext(ctx).Model(model).Where("id = ? AND s3_key = ?", id, s3Key).UpdateColumns(updates)
+	if result.Error != nil {
+		utils.Logger(ctx).Error("Failed to store transcode result", zap.String("target", target), zap.Uint("id", id), zap.Error(result.Error))
+		return false
+	}
+	return result.RowsAffected > 0
+}
+
+// transcodedURLKey returns the key to serve as the playable URL of a media file: the rendition
+// once transcoding succeeded, the original otherwise
+func transcodedURLKey(s3Key string, status, renditionKey *string) string {
+	if status != nil && *status == models.MediaTranscodeStatusReady && renditionKey != nil && *renditionKey != "" {
+		return *renditionKey
+	}
+	return s3Key
+}
@@ -2,9 +2,12 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
 	"github.com/followCode/djjs-event-reporting-backend/config"
 	"gorm.io/gorm"
 )
@@ -35,10 +38,10 @@ func CreateVolunteer(volunteer *models.Volunteer) error {
 	return nil
 }
 
-// GetAllVolunteers returns all volunteers
-func GetAllVolunteers() ([]models.Volunteer, error) {
+// GetAllVolunteers returns the volunteers of the scope's branches
+func GetAllVolunteers(includeDeleted bool, scope *BranchScope) ([]models.Volunteer, error) {
 	var volunteers []models.Volunteer
-	if err := config.DB.Preload("Branch").Find(&volunteers).Error; err != nil {
+	if err := scope.Apply(withDeleted(config.DB, includeDeleted), "branch_id").Preload("Branch").Find(&volunteers).Error; err != nil {
 		return nil, err
 	}
 	return volunteers, nil
@@ -74,6 +77,9 @@ func UpdateVolunteer(id uint, updates map[string]interface{}) error {
 
 	now := time.Now()
 	updates["updated_on"] = &now
+	if name, ok := updates["volunteer_name"].(string); ok {
+		updates["name_key"] = utils.NameKey(name)
+	}
 
 	if err := config.DB.Model(&volunteer).Updates(updates).Error; err != nil {
 		return err
@@ -93,16 +99,26 @@ func DeleteVolunteer(id uint) error {
 	return nil
 }
 
-// SearchVolunteers searches volunteers by name or contact number
-func SearchVolunteers(searchTerm string) ([]models.Volunteer, error) {
+// RestoreVolunteer restores a soft-deleted volunteer
+func RestoreVolunteer(id uint) error {
+	return restoreSoftDeleted(config.DB, &models.Volunteer{}, id)
+}
+
+// SearchVolunteers searches the volunteers of the scope's branches by name or contact number.
+// Names are also matched on their transliterated key, so "Vikas" finds "विकास".
+func SearchVolunteers(searchTerm string, scope *BranchScope) ([]models.Volunteer, error) {
 	var volunteers []models.Volunteer
 	
-	// Search in volunteer_name or contact fields
-	query := config.DB.Where(
+	// Search in volunteer_name, name_key or contact fields
+	matches := config.DB.Where(
 		"volunteer_name ILIKE ? OR contact ILIKE ?",
 		"%"+searchTerm+"%",
 		"%"+searchTerm+"%",
-	).Preload("Branch")
+	)
+	if key := utils.NameKey(searchTerm); key != "" {
+		matches = matches.Or("name_key LIKE ?", "%"+key+"%")
+	}
+	query := scope.Apply(config.DB.Where(matches), "branch_id").Preload("Branch")
 	
 	// Limit results to 20 for autocomplete suggestions
 	if err := query.Limit(20).Find(&volunteers).Error; err != nil {
@@ -110,4 +126,190 @@ func SearchVolunteers(searchTerm string) ([]models.Volunteer, error) {
 	}
 	
 	return volunteers, nil
-}
\ No newline at end of file
+}
+
+// FindVolunteerDuplicates returns volunteers of a branch (any branch of the scope when 0) whose
+// name matches across scripts/spellings
+func FindVolunteerDuplicates(name string, branchID uint, scope *BranchScope) ([]models.Volunteer, error) {
+	volunteers := []models.Volunteer{}
+
+	key := utils.NameKey(name)
+	if key == "" {
+		return volunteers, nil
+	}
+
+	query := scope.Apply(config.DB.Where("name_key = ?", key), "branch_id")
+	if branchID > 0 {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	if err := query.Order("id ASC").Limit(50).Find(&volunteers).Error; err != nil {
+		return nil, err
+	}
+
+	return volunteers, nil
+}
+
+// ErrInvalidVolunteerMerge is returned when the merge sources are empty or include the target
+var ErrInvalidVolunteerMerge = errors.New("invalid merge: sources must be other volunteers")
+
+// VolunteerParticipation is one event a volunteer served at
+type VolunteerParticipation struct {
+	VolunteerID  uint      `json:"volunteer_id"`
+	EventID      uint      `json:"event_id"`
+	ReportNumber string    `json:"report_number,omitempty"`
+	EventType    string    `json:"event_type,omitempty"`
+	Theme        string    `json:"theme,omitempty"`
+	StartDate    time.Time `json:"start_date"`
+	EndDate      time.Time `json:"end_date"`
+	BranchID     uint      `json:"branch_id"`
+	BranchName   string    `json:"branch_name,omitempty"`
+	NumberOfDays int       `json:"number_of_days"`
+	SevaInvolved string    `json:"seva_involved,omitempty"`
+	MentionSeva  string    `json:"mention_seva,omitempty"`
+}
+
+// VolunteerHistory is every event a volunteer served at, across the records of the
+// registered person they are linked to
+type VolunteerHistory struct {
+	PersonID       *uint                    `json:"person_id,omitempty"`
+	Name           string                   `json:"name"`
+	Contact        string                   `json:"contact,omitempty"`
+	Events         int                      `json:"events"`
+	TotalDays      int                      `json:"total_days"`
+	Seva           []string                 `json:"seva"` // distinct seva performed, most recent first
+	Participations []VolunteerParticipation `json:"participations"`
+}
+
+// GetVolunteerHistory returns all events and seva of the person a volunteer record belongs to,
+// most recent event first. A record not linked to a person only has its own event.
+func GetVolunteerHistory(id uint) (*VolunteerHistory, error) {
+	var volunteer models.Volunteer
+	if err := config.DB.First(&volunteer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVolunteerNotFound
+		}
+		return nil, err
+	}
+
+	history := &VolunteerHistory{
+		PersonID:       volunteer.PersonID,
+		Name:           volunteer.VolunteerName,
+		Contact:        volunteer.Contact,
+		Seva:           []string{},
+		Participations: []VolunteerParticipation{},
+	}
+	query := config.DB.Table("volunteers v").
+		Select(`v.id AS volunteer_id, v.event_id, e.report_number, et.name AS event_type, e.theme,
+			e.start_date, e.end_date, v.branch_id, b.name AS branch_name, v.number_of_days, v.seva_involved, v.mention_seva`).
+		Joins("JOIN event_details e ON e.id = v.event_id AND e.deleted_at IS NULL").
+		Joins("LEFT JOIN event_types et ON et.id = e.event_type_id").
+		Joins("LEFT JOIN branches b ON b.id = v.branch_id").
+		Where("v.deleted_at IS NULL")
+	if volunteer.PersonID != nil {
+		var person models.Person
+		if err := config.DB.First(&person, *volunteer.PersonID).Error; err == nil {
+			history.Name = person.Name
+			if person.Contact != "" {
+				history.Contact = person.Contact
+			}
+		}
+		query = query.Where("v.person_id = ?", *volunteer.PersonID)
+	} else {
+		query = query.Where("v.id = ?", volunteer.ID)
+	}
+	if err := query.Order("e.start_date DESC, v.id DESC").Scan(&history.Participations).Error; err != nil {
+		return nil, err
+	}
+
+	events := map[uint]bool{}
+	seva := map[string]bool{}
+	for _, p := range history.Participations {
+		events[p.EventID] = true
+		history.TotalDays += p.NumberOfDays
+		for _, s := range []string{p.SevaInvolved, p.MentionSeva} {
+			if s = strings.TrimSpace(s); s != "" && !seva[strings.ToLower(s)] {
+				seva[strings.ToLower(s)] = true
+				history.Seva = append(history.Seva, s)
+			}
+		}
+	}
+	history.Events = len(events)
+	return history, nil
+}
+
+// MergeVolunteers merges the persons of duplicate volunteer records into the person of
+// targetID (see MergePersons), so their participations show up in one history. Records not
+// linked to a person are linked to the target's. The merge is audit logged.
+func MergeVolunteers(targetID uint, sourceIDs []uint, actor AuditActor) (*VolunteerHistory, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var target models.Volunteer
+		if err := tx.First(&target, targetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVolunteerNotFound
+			}
+			return err
+		}
+		if len(sourceIDs) == 0 {
+			return ErrInvalidVolunteerMerge
+		}
+		for _, id := range sourceIDs {
+			if id == targetID {
+				return ErrInvalidVolunteerMerge
+			}
+		}
+		var sources []models.Volunteer
+		if err := tx.Where("id IN ?", sourceIDs).Find(&sources).Error; err != nil {
+			return err
+		}
+		if len(sources) != len(uniqueIDs(sourceIDs)) {
+			return ErrVolunteerNotFound
+		}
+
+		now := time.Now()
+		if target.PersonID == nil {
+			personID, err := models.LinkPerson(tx, models.Person{Name: target.VolunteerName, Contact: target.Contact})
+			if err != nil {
+				return err
+			}
+			target.PersonID = &personID
+			if err := tx.Model(&target).UpdateColumn("person_id", personID).Error; err != nil {
+				return err
+			}
+		}
+
+		var personIDs, unlinked []uint
+		seen := map[uint]bool{*target.PersonID: true}
+		for _, source := range sources {
+			switch {
+			case source.PersonID == nil:
+				unlinked = append(unlinked, source.ID)
+			case !seen[*source.PersonID]:
+				seen[*source.PersonID] = true
+				personIDs = append(personIDs, *source.PersonID)
+			}
+		}
+		if len(unlinked) > 0 {
+			if err := tx.Model(&models.Volunteer{}).Where("id IN ?", unlinked).UpdateColumn("person_id", *target.PersonID).Error; err != nil {
+				return err
+			}
+			var entries []*models.AuditLog
+			for _, id := range unlinked {
+				entries = append(entries, actor.auditEntry(AuditEntityVolunteer, id, models.AuditActionUpdate,
+					models.JSONB{"person_id": map[string]interface{}{"old": nil, "new": *target.PersonID}}, now))
+			}
+			if err := tx.Create(entries).Error; err != nil {
+				return fmt.Errorf("failed to write audit log: %w", err)
+			}
+		}
+		if len(personIDs) > 0 {
+			return mergePersons(tx, *target.PersonID, personIDs, actor, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetVolunteerHistory(targetID)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/jung-kurt/gofpdf"
+	"go.uber.org/zap"
+)
+
+// startupWarmupTimeout bounds the warm-up before the server starts listening
+const startupWarmupTimeout = 30 * time.Second
+
+// warmupDatabaseConnections matches the idle connection limit of config.ConnectDB
+const warmupDatabaseConnections = 10
+
+// maxWarmupBranchImages caps the branches whose cover images are presigned by a warm-up
+const maxWarmupBranchImages = 1000
+
+// WarmupStep is the outcome of one warm-up step
+type WarmupStep struct {
+	Name       string `json:"name"`
+	Items      int    `json:"items"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WarmupResult is the outcome of a warm-up; steps that fail do not stop the others
+type WarmupResult struct {
+	DurationMs int64        `json:"duration_ms"`
+	Steps      []WarmupStep `json:"steps"`
+}
+
+// WarmUp fills the caches the first requests after a deploy would otherwise fill themselves:
+// it opens the idle database connections, loads feature flags and the location tables, renders
+// a throwaway PDF so the report code paths and fonts are initialized (email templates are parsed
+// when the program starts) and presigns the cover images and coordinator photos of the branch
+// directory. It is safe to run at any time; already warm caches are kept.
+func WarmUp(ctx context.Context) *WarmupResult {
+	started := time.Now()
+	result := &WarmupResult{}
+	for _, step := range []struct {
+		name string
+		run  func(ctx context.Context) (int, error)
+	}{
+		{"database_pool", warmDatabasePool},
+		{"feature_flags", warmFeatureFlags},
+		{"locations", warmLocations},
+		{"report_templates", warmReportTemplates},
+		{"branch_images", warmBranchImages},
+	} {
+		stepStarted := time.Now()
+		items, err := step.run(ctx)
+		outcome := WarmupStep{Name: step.name, Items: items, DurationMs: time.Since(stepStarted).Milliseconds()}
+		if err != nil {
+			outcome.Error = err.Error()
+			utils.Logger(ctx).Warn("Warm-up step failed", zap.String("step", step.name), zap.Error(err))
+		}
+		result.Steps = append(result.Steps, outcome)
+	}
+	result.DurationMs = time.Since(started).Milliseconds()
+	utils.Logger(ctx).Info("Caches warmed up", zap.Int64("duration_ms", result.DurationMs), zap.Any("steps", result.Steps))
+	return result
+}
+
+// WarmUpOnStartup runs WarmUp before the server starts listening, bounded by
+// startupWarmupTimeout. WARMUP_ON_STARTUP=false skips it (e.g. for local development).
+func WarmUpOnStartup() {
+	if !config.Jobs.WarmUpOnStartup {
+		utils.BaseLogger().Info("Startup warm-up disabled by WARMUP_ON_STARTUP")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupWarmupTimeout)
+	defer cancel()
+	WarmUp(ctx)
+}
+
+// warmDatabasePool opens as many connections as the pool keeps idle, so the first concurrent
+// requests do not each pay for a TLS handshake and authentication
+func warmDatabasePool(ctx context.Context) (int, error) {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return 0, err
+	}
+	conns := make([]*sql.Conn, 0, warmupDatabaseConnections)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < warmupDatabaseConnections; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return len(conns), err
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return len(conns), err
+		}
+	}
+	return len(conns), nil
+}
+
+func warmFeatureFlags(ctx context.Context) (int, error) {
+	refreshFeatureFlags()
+	featureFlagCache.RLock()
+	defer featureFlagCache.RUnlock()
+	return len(featureFlagCache.flags), nil
+}
+
+func warmLocations(ctx context.Context) (int, error) {
+	tables, err := geoLocations()
+	if err != nil {
+		return 0, err
+	}
+	return len(tables.countries) + len(tables.states) + len(tables.districts) + len(tables.cities), nil
+}
+
+// warmReportTemplates renders a one-page PDF with the fonts of the event report
+func warmReportTemplates(ctx context.Context) (int, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	for _, style := range []string{"", "B"} {
+		pdf.SetFont("Arial", style, 10)
+		pdf.CellFormat(0, 6, "Warm-up", "", 1, "L", false, 0, "")
+	}
+	if err := pdf.Output(io.Discard); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// warmBranchImages presigns the directory images of active branches with the same expiration
+// as AttachBranchImages, so list pages are served from the presigned URL cache
+func warmBranchImages(ctx context.Context) (int, error) {
+	var branches []models.Branch
+	if err := config.DB.WithContext(ctx).
+		Select("id", "cover_media_id", "coordinator_photo_media_id").
+		Where("status = ? AND is_sandbox = ?", true, false).
+		Where("cover_media_id IS NOT NULL OR coordinator_photo_media_id IS NOT NULL").
+		Order("id DESC").
+		Limit(maxWarmupBranchImages).
+		Find(&branches).Error; err != nil {
+		return 0, err
+	}
+	if _, err := GetStorage(); err != nil {
+		return 0, err
+	}
+	AttachBranchListImages(ctx, branches)
+	return len(branches), nil
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/app/utils"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// webhookMaxAttempts is how often a delivery is tried. With the job backoff the last attempt
+// comes about four hours after the first, long enough to ride out a receiver's deploy or outage.
+const webhookMaxAttempts = 10
+
+var (
+	// ErrWebhookDeliveryNotFound is returned for unknown deliveries of a subscription
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrWebhookDeliveryPending is returned when redelivering a delivery that is still being tried
+	ErrWebhookDeliveryPending = errors.New("webhook delivery is still pending")
+)
+
+// webhookDeliveryPayload is the JobTypeWebhookDelivery payload. The event data stays on the
+// delivery row.
+type webhookDeliveryPayload struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// PublishWebhookEvent queues the event for every active subscription that wants it. branchID
+// is the branch of branch-scoped events, nil for the others. Failures are logged; they never
+// fail the change the event reports.
+func PublishWebhookEvent(ctx context.Context, eventType string, branchID *uint, data map[string]interface{}) {
+	logger := utils.Logger(ctx).With(zap.String("event_type", eventType))
+
+	scope := WebhookScope{BranchID: branchID}
+	if branchID != nil {
+		var branch models.Branch
+		if err := config.DB.WithContext(ctx).Unscoped().Select("id", "parent_branch_id", "region_id").
+			Limit(1).Find(&branch, *branchID).Error; err != nil {
+			logger.Warn("Failed to load webhook event branch", zap.Uint("branch_id", *branchID), zap.Error(err))
+			return
+		}
+		scope.ParentBranchID, scope.RegionID = branch.ParentBranchID, branch.RegionID
+	}
+	subscriptions, err := MatchingWebhookSubscriptions(eventType, scope)
+	if err != nil {
+		logger.Warn("Failed to load webhook subscriptions", zap.Error(err))
+		return
+	}
+
+	occurredAt := time.Now().UTC()
+	for _, subscription := range subscriptions {
+		delivery := models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			DeliveryID:     uuid.NewString(),
+			EventType:      eventType,
+			OccurredAt:     occurredAt,
+			Data:           models.JSONB(data),
+			Status:         models.WebhookDeliveryPending,
+		}
+		if err := config.DB.WithContext(ctx).Create(&delivery).Error; err != nil {
+			logger.Warn("Failed to record webhook delivery", zap.Uint("subscription_id", subscription.ID), zap.Error(err))
+			continue
+		}
+		if err := queueWebhookDelivery(ctx, &delivery); err != nil {
+			logger.Warn("Failed to queue webhook delivery", zap.String("delivery_id", delivery.DeliveryID), zap.Error(err))
+		}
+	}
+}
+
+// PublishMediaUploaded publishes media.uploaded for a new event or branch media file (target
+// event_media or branch_media)
+func PublishMediaUploaded(ctx context.Context, target string, id uint) {
+	data := map[string]interface{}{"media_id": id}
+	var branchID *uint
+	if target == ThumbnailTargetBranchMedia {
+		var media models.BranchMedia
+		if err := config.DB.WithContext(ctx).Select("id", "branch_id", "file_type", "original_filename").Limit(1).Find(&media, id).Error; err != nil || media.ID == 0 {
+			return
+		}
+		branchID = &media.BranchID
+		data["file_type"], data["original_filename"] = media.FileType, media.OriginalFilename
+	} else {
+		var media models.EventMedia
+		if err := config.DB.WithContext(ctx).Select("id", "event_id", "file_type", "original_filename").Limit(1).Find(&media, id).Error; err != nil || media.ID == 0 {
+			return
+		}
+		var event models.EventDetails
+		if err := config.DB.WithContext(ctx).Select("id", "branch_id").Limit(1).Find(&event, media.EventID).Error; err != nil {
+			return
+		}
+		branchID = event.BranchID
+		data["event_id"], data["file_type"], data["original_filename"] = media.EventID, media.FileType, media.OriginalFilename
+	}
+	data["branch_id"] = branchID
+	PublishWebhookEvent(ctx, WebhookEventMediaUploaded, branchID, data)
+}
+
+// publishBranchUpdated publishes branch.updated for a created or changed branch
+func publishBranchUpdated(branchID uint) {
+	var branch models.Branch
+	if err := config.DB.Select("id", "name", "parent_branch_id").Limit(1).Find(&branch, branchID).Error; err != nil || branch.ID == 0 {
+		return
+	}
+	PublishWebhookEvent(context.Background(), WebhookEventBranchUpdated, &branch.ID, map[string]interface{}{
+		"branch_id":        branch.ID,
+		"name":             branch.Name,
+		"parent_branch_id": branch.ParentBranchID,
+	})
+}
+
+func queueWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	job, err := EnqueueJob(ctx, JobTypeWebhookDelivery, webhookDeliveryPayload{DeliveryID: delivery.ID}, JobOptions{MaxAttempts: webhookMaxAttempts})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	delivery.JobID, delivery.NextAttemptOn = &job.ID, &now
+	return config.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).
+		UpdateColumns(map[string]interface{}{"job_id": job.ID, "next_attempt_on": now}).Error
+}
+
+// runWebhookDeliveryJob makes one attempt of a delivery and records its outcome on the
+// delivery. A failed attempt fails the job, which retries it with backoff.
+func runWebhookDeliveryJob(ctx context.Context, run *JobRun) (models.JSONB, error) {
+	var payload webhookDeliveryPayload
+	if err := run.Decode(&payload); err != nil {
+		return nil, err
+	}
+	var delivery models.WebhookDelivery
+	if err := config.DB.WithContext(ctx).Limit(1).Find(&delivery, payload.DeliveryID).Error; err != nil {
+		return nil, err
+	}
+	if delivery.ID == 0 {
+		// Removed together with its subscription
+		return models.JSONB{"skipped": "delivery not found"}, nil
+	}
+	var subscription models.WebhookSubscription
+	if err := config.DB.WithContext(ctx).Limit(1).Find(&subscription, delivery.SubscriptionID).Error; err != nil {
+		return nil, err
+	}
+	if subscription.ID == 0 || !subscription.Active {
+		err := errors.New("webhook subscription is inactive")
+		if dbErr := config.DB.Model(&delivery).UpdateColumns(map[string]interface{}{
+			"status": models.WebhookDeliveryFailed, "error": err.Error(), "next_attempt_on": nil,
+		}).Error; dbErr != nil {
+			return nil, dbErr
+		}
+		return nil, PermanentJobError(err)
+	}
+
+	result := deliverWebhook(ctx, &subscription, WebhookPayload{
+		ID:         delivery.DeliveryID,
+		Type:       delivery.EventType,
+		OccurredAt: delivery.OccurredAt,
+		Data:       delivery.Data,
+	})
+	if !result.Success && ctx.Err() != nil {
+		// Interrupted (shutdown or timeout); the job is run again and records the outcome then
+		return nil, ctx.Err()
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_attempt_on": now,
+		"status_code":     result.StatusCode,
+		"duration_ms":     result.DurationMs,
+		"response_body":   result.ResponseBody,
+		"error":           result.Error,
+		"next_attempt_on": nil,
+	}
+	switch {
+	case result.Success:
+		updates["status"] = models.WebhookDeliverySucceeded
+	case run.Job.Attempts < run.Job.MaxAttempts:
+		updates["status"] = models.WebhookDeliveryPending
+		updates["next_attempt_on"] = now.Add(jobRetryDelay(run.Job.Attempts))
+	default:
+		updates["status"] = models.WebhookDeliveryFailed
+	}
+	if err := config.DB.Model(&delivery).UpdateColumns(updates).Error; err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Error)
+	}
+	return models.JSONB{"delivery_id": delivery.DeliveryID, "status_code": result.StatusCode}, nil
+}
+
+// WebhookDeliveryFilter holds the supported filters for listing the deliveries of a subscription
+type WebhookDeliveryFilter struct {
+	SubscriptionID uint
+	EventType      string
+	Status         string
+	Limit          int
+	Offset         int
+}
+
+// GetWebhookDeliveries lists the deliveries of a subscription, newest first, with the total
+// match count
+func GetWebhookDeliveries(filter WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error) {
+	if _, err := GetWebhookSubscription(filter.SubscriptionID); err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	query := config.DB.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", filter.SubscriptionID)
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := query.Order("created_on DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+// GetWebhookDelivery returns a delivery of a subscription by its delivery ID, the
+// X-Webhook-Delivery header receivers see
+func GetWebhookDelivery(subscriptionID uint, deliveryID string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := config.DB.Where("subscription_id = ? AND delivery_id = ?", subscriptionID, deliveryID).
+		Limit(1).Find(&delivery).Error; err != nil {
+		return nil, err
+	}
+	if delivery.ID == 0 {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+// RedeliverWebhook sends a finished delivery again with a fresh set of attempts, e.g. once the
+// receiver is fixed. The payload and delivery ID stay those of the original.
+func RedeliverWebhook(ctx context.Context, subscriptionID uint, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := GetWebhookDelivery(subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	result := config.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status <> ?", delivery.ID, models.WebhookDeliveryPending).
+		UpdateColumns(map[string]interface{}{"status": models.WebhookDeliveryPending, "attempts": 0})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebhookDeliveryPending
+	}
+	if err := queueWebhookDelivery(ctx, delivery); err != nil {
+		config.DB.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).
+			UpdateColumn("status", models.WebhookDeliveryFailed)
+		return nil, err
+	}
+	return GetWebhookDelivery(subscriptionID, deliveryID)
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/metrics"
+	"github.com/followCode/djjs-event-reporting-backend/app/models"
+	"github.com/followCode/djjs-event-reporting-backend/config"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook request headers. The signature is hex(HMAC-SHA256(secret, timestamp + "." + body)),
+// so receivers can reject replayed deliveries with old timestamps.
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// Webhook event types, see webhookEventTypes
+const (
+	WebhookEventSubmitted     = "event.submitted"
+	WebhookEventApproved      = "event.approved"
+	WebhookEventRejected      = "event.rejected"
+	WebhookEventMediaUploaded = "media.uploaded"
+	WebhookEventBranchUpdated = "branch.updated"
+	WebhookEventUserCreated   = "user.created"
+)
+
+// webhookTestEventType is sent by the test endpoint when no event type is chosen
+const webhookTestEventType = "webhook.test"
+
+var webhookHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// ErrWebhookNotFound is returned for unknown subscription IDs
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	// ErrInvalidWebhook is returned for invalid subscription settings
+	ErrInvalidWebhook = errors.New("invalid webhook subscription")
+)
+
+// WebhookEventType describes one kind of webhook payload
+type WebhookEventType struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// BranchScoped events carry a branch and are filtered by branch_ids / region_ids
+	BranchScoped bool                   `json:"branch_scoped"`
+	Sample       map[string]interface{} `json:"sample"`
+}
+
+// webhookEventTypes is the catalog of events integrators can subscribe to
+var webhookEventTypes = []WebhookEventType{
+	{
+		Type:         WebhookEventSubmitted,
+		Description:  "An event report was submitted for review",
+		BranchScoped: true,
+		Sample:       map[string]interface{}{"event_id": 101, "report_number": "EVT-2026-000101", "branch_id": 7, "status": models.EventStatusSubmitted},
+	},
+	{
+		Type:         WebhookEventApproved,
+		Description:  "An event report was approved",
+		BranchScoped: true,
+		Sample:       map[string]interface{}{"event_id": 101, "report_number": "EVT-2026-000101", "branch_id": 7, "status": models.EventStatusApproved},
+	},
+	{
+		Type:         WebhookEventRejected,
+		Description:  "An event report was rejected",
+		BranchScoped: true,
+		Sample:       map[string]interface{}{"event_id": 101, "report_number": "EVT-2026-000101", "branch_id": 7, "status": models.EventStatusRejected, "comment": "Please add attendance figures"},
+	},
+	{
+		Type:         WebhookEventMediaUploaded,
+		Description:  "A file was uploaded to an event or branch",
+		BranchScoped: true,
+		Sample:       map[string]interface{}{"media_id": 55, "event_id": 101, "branch_id": 7, "file_type": "image", "original_filename": "satsang.jpg"},
+	},
+	{
+		Type:         WebhookEventBranchUpdated,
+		Description:  "A branch or child branch was created or changed",
+		BranchScoped: true,
+		Sample:       map[string]interface{}{"branch_id": 7, "name": "Delhi Branch", "parent_branch_id": nil},
+	},
+	{
+		Type:        WebhookEventUserCreated,
+		Description: "A user account was created",
+		Sample:      map[string]interface{}{"user_id": 12, "name": "Asha Verma", "email": "asha@example.org", "role_id": 2},
+	},
+}
+
+// WebhookEventTypes returns the event type catalog
+func WebhookEventTypes() []WebhookEventType {
+	return webhookEventTypes
+}
+
+func findWebhookEventType(eventType string) (WebhookEventType, bool) {
+	for _, t := range webhookEventTypes {
+		if t.Type == eventType {
+			return t, true
+		}
+	}
+	return WebhookEventType{}, false
+}
+
+// WebhookInput holds the settings of a subscription; nil fields are left unchanged on update
+type WebhookInput struct {
+	Name        *string
+	URL         *string
+	Secret      *string // generated on create when empty
+	Description *string
+	Active      *bool
+	EventTypes  []string
+	BranchIDs   []uint
+	RegionIDs   []uint
+}
+
+// WebhookScope is the branch an event belongs to, for subscription filtering
+type WebhookScope struct {
+	BranchID       *uint
+	ParentBranchID *uint
+	RegionID       *uint
+}
+
+// WebhookMatches reports whether a subscription wants an event of this type and scope.
+// Branch scopes include child branches; events without a branch only match unscoped subscriptions.
+func WebhookMatches(subscription *models.WebhookSubscription, eventType string, scope WebhookScope) bool {
+	if !subscription.Active {
+		return false
+	}
+	if len(subscription.EventTypes) > 0 && !containsString(subscription.EventTypes, eventType) {
+		return false
+	}
+	if len(subscription.BranchIDs) == 0 && len(subscription.RegionIDs) == 0 {
+		return true
+	}
+	for _, id := range []*uint{scope.BranchID, scope.ParentBranchID} {
+		if id != nil && containsUint(subscription.BranchIDs, *id) {
+			return true
+		}
+	}
+	return scope.RegionID != nil && containsUint(subscription.RegionIDs, *scope.RegionID)
+}
+
+// MatchingWebhookSubscriptions returns the active subscriptions that want the event
+func MatchingWebhookSubscriptions(eventType string, scope WebhookScope) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := config.DB.Where("active = ?", true).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	matching := subscriptions[:0]
+	for i := range subscriptions {
+		if WebhookMatches(&subscriptions[i], eventType, scope) {
+			matching = append(matching, subscriptions[i])
+		}
+	}
+	return matching, nil
+}
+
+// GetWebhookSubscriptions lists all subscriptions
+func GetWebhookSubscriptions() ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+	err := config.DB.Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetWebhookSubscription returns one subscription
+func GetWebhookSubscription(id uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := config.DB.First(&subscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// CreateWebhookSubscription registers an endpoint. The returned secret is only available here.
+func CreateWebhookSubscription(input WebhookInput, actor AuditActor) (*models.WebhookSubscription, string, error) {
+	subscription := models.WebhookSubscription{Active: true, CreatedBy: actor.UserID}
+	if err := applyWebhookInput(&subscription, input); err != nil {
+		return nil, "", err
+	}
+	if subscription.Name == "" || subscription.URL == "" {
+		return nil, "", fmt.Errorf("%w: name and url are required", ErrInvalidWebhook)
+	}
+	if subscription.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		subscription.Secret = secret
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&subscription).Error; err != nil {
+			return err
+		}
+		changes := DiffAuditSnapshots(nil, auditSnapshotOf(&subscription))
+		return tx.Create(actor.auditEntry(AuditEntityWebhook, subscription.ID, models.AuditActionCreate, changes, time.Now())).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &subscription, subscription.Secret, nil
+}
+
+// UpdateWebhookSubscription changes a subscription. Setting Secret rotates it.
+func UpdateWebhookSubscription(id uint, input WebhookInput, actor AuditActor) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&subscription, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWebhookNotFound
+			}
+			return err
+		}
+		before := auditSnapshotOf(&subscription)
+		if err := applyWebhookInput(&subscription, input); err != nil {
+			return err
+		}
+		if err := tx.Save(&subscription).Error; err != nil {
+			return err
+		}
+		changes := DiffAuditSnapshots(before, auditSnapshotOf(&subscription))
+		if input.Secret != nil {
+			changes["secret"] = map[string]interface{}{"old": "[rotated]", "new": "[rotated]"}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(actor.auditEntry(AuditEntityWebhook, subscription.ID, models.AuditActionUpdate, changes, time.Now())).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// DeleteWebhookSubscription removes a subscription
+func DeleteWebhookSubscription(id uint, actor AuditActor) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		before := loadAuditSnapshot(tx, AuditEntityWebhook, id)
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+		return tx.Create(actor.auditEntry(AuditEntityWebhook, id, models.AuditActionDelete, DiffAuditSnapshots(before, nil), time.Now())).Error
+	})
+}
+
+func applyWebhookInput(subscription *models.WebhookSubscription, input WebhookInput) error {
+	if input.Name != nil {
+		subscription.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.URL != nil {
+		target := strings.TrimSpace(*input.URL)
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+		}
+		subscription.URL = target
+	}
+	if input.Secret != nil {
+		if len(*input.Secret) < 16 {
+			return fmt.Errorf("%w: secret must be at least 16 characters", ErrInvalidWebhook)
+		}
+		subscription.Secret = *input.Secret
+	}
+	if input.Description != nil {
+		subscription.Description = *input.Description
+	}
+	if input.Active != nil {
+		subscription.Active = *input.Active
+	}
+	if input.EventTypes != nil {
+		for _, eventType := range input.EventTypes {
+			if _, ok := findWebhookEventType(eventType); !ok {
+				return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+			}
+		}
+		subscription.EventTypes = input.EventTypes
+	}
+	if input.BranchIDs != nil {
+		subscription.BranchIDs = input.BranchIDs
+	}
+	if input.RegionIDs != nil {
+		subscription.RegionIDs = input.RegionIDs
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	if subscription.BranchIDs == nil {
+		subscription.BranchIDs = []uint{}
+	}
+	if subscription.RegionIDs == nil {
+		subscription.RegionIDs = []uint{}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// WebhookPayload is the JSON body of every webhook request
+type WebhookPayload struct {
+	ID         string      `json:"id"` // delivery ID, also sent as X-Webhook-Delivery
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Test       bool        `json:"test,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDeliveryResult is the outcome of one delivery attempt
+type WebhookDeliveryResult struct {
+	DeliveryID   string `json:"delivery_id"`
+	EventType    string `json:"event_type"`
+	Success      bool   `json:"success"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+	ResponseBody string `json:"response_body,omitempty"` // first 1KB
+	Error        string `json:"error,omitempty"`
+}
+
+// SignWebhookPayload returns the X-Webhook-Signature value for a body sent at timestamp
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook POSTs a signed payload to the subscription. Any 2xx response is a success.
+func deliverWebhook(ctx context.Context, subscription *models.WebhookSubscription, payload WebhookPayload) WebhookDeliveryResult {
+	result := WebhookDeliveryResult{DeliveryID: payload.ID, EventType: payload.Type}
+	defer func() {
+		if !result.Success {
+			metrics.WebhookDeliveryFailures.WithLabelValues(payload.Type).Inc()
+		}
+	}()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "djjs-webhooks/1")
+	req.Header.Set(WebhookHeaderEvent, payload.Type)
+	req.Header.Set(WebhookHeaderDelivery, payload.ID)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(subscription.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := webhookHTTPClient.Do(req)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	result.StatusCode = resp.StatusCode
+	result.ResponseBody = string(responseBody)
+	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !result.Success {
+		result.Error = fmt.Sprintf("endpoint responded with HTTP %d", resp.StatusCode)
+	}
+	return result
+}
+
+// TestWebhookSubscription sends a signed sample payload of eventType (default "webhook.test")
+// to the subscription, even if it is inactive or filters that type out, and records the outcome.
+func TestWebhookSubscription(ctx context.Context, id uint, eventType string) (*WebhookDeliveryResult, error) {
+	subscription, err := GetWebhookSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{} = map[string]interface{}{"subscription_id": subscription.ID, "message": "Test delivery"}
+	if eventType == "" {
+		eventType = webhookTestEventType
+	} else {
+		catalogType, ok := findWebhookEventType(eventType)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+		data = catalogType.Sample
+	}
+
+	result := deliverWebhook(ctx, subscription, WebhookPayload{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Test:       true,
+		Data:       data,
+	})
+
+	if err := config.DB.Model(subscription).Updates(map[string]interface{}{
+		"last_tested_on":   time.Now(),
+		"last_test_status": result.StatusCode,
+		"last_test_error":  result.Error,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUint(values []uint, value uint) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RequestIDHeader carries the request ID on incoming requests and responses
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// logger is the process-wide structured logger, replaced at startup by SetLogger
+var logger = zap.Must(zap.NewProduction())
+
+// NewLogger builds the application logger: JSON lines on stdout.
+// level (debug, info, warn, error) sets the minimum level, default info;
+// format "console" switches to human-readable output for local development.
+// Both come from config.LogConfig (LOG_LEVEL, LOG_FORMAT).
+func NewLogger(level, format string) (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.OutputPaths = []string{"stdout"}
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	if level != "" {
+		parsed, err := zap.ParseAtomicLevel(strings.ToLower(level))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Level = parsed
+	}
+	if strings.EqualFold(format, "console") {
+		cfg.Encoding = "console"
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	return cfg.Build()
+}
+
+// SetLogger installs the process-wide logger. Output of the standard library log package
+// (startup messages, third-party code) is routed through it as well.
+func SetLogger(l *zap.Logger) {
+	logger = l
+	zap.RedirectStdLog(l)
+}
+
+// Logger returns the logger for a request: entries carry the request_id assigned by the
+// RequestID middleware when ctx descends from the request context
+func Logger(ctx context.Context) *zap.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// BaseLogger returns the logger for code that does not run on behalf of a request
+// (startup, background workers)
+func BaseLogger() *zap.Logger {
+	return logger
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,63 @@
+package utils
+
+import "strings"
+
+// SensitiveFieldMaskers maps JSON field names to the function used to mask them
+// for callers without permission to view personal data
+var SensitiveFieldMaskers = map[string]func(interface{}) interface{}{
+	// Contact numbers
+	"contact":               maskPhoneValue,
+	"contact_number":        maskPhoneValue,
+	"personal_number":       maskPhoneValue,
+	"contact_person_number": maskPhoneValue,
+	// Donor amounts
+	"amount": hideValue,
+	// Donation totals of events and branch statistics (the donation reports are not open to
+	// these roles either)
+	"donation_total":          hideValue,
+	"donations_total":         hideValue,
+	"declared_donation_total": hideValue,
+	"donation_amount":         hideValue,
+	// Member dates of birth
+	"date_of_birth": hideValue,
+}
+
+// MaskPhone keeps only the last four digits of a phone number ("9876543210" → "******3210")
+func MaskPhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+func maskPhoneValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok && s != "" {
+		return MaskPhone(s)
+	}
+	return v
+}
+
+func hideValue(v interface{}) interface{} {
+	return nil
+}
+
+// MaskSensitiveFields walks a decoded JSON value and masks every field listed in
+// SensitiveFieldMaskers, at any nesting depth. The value is modified in place.
+func MaskSensitiveFields(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if mask, ok := SensitiveFieldMaskers[key]; ok {
+				node[key] = mask(value)
+				continue
+			}
+			node[key] = MaskSensitiveFields(value)
+		}
+	case []interface{}:
+		for i, value := range node {
+			node[i] = MaskSensitiveFields(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NDJSONContentType is the media type for newline-delimited JSON streams
+const NDJSONContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery controls how many rows are buffered before flushing to the client
+const ndjsonFlushEvery = 100
+
+// WantsNDJSON reports whether the client asked for a streamed response,
+// either with ?format=ndjson or an Accept: application/x-ndjson header
+func WantsNDJSON(c *gin.Context) bool {
+	if strings.EqualFold(c.Query("format"), "ndjson") {
+		return true
+	}
+	return strings.Contains(c.GetHeader("Accept"), NDJSONContentType)
+}
+
+// NDJSONWriter streams one JSON document per line. Writes block while the client's
+// connection is full, so a producer iterating a DB cursor is naturally throttled
+// to the speed the client reads at (backpressure) instead of buffering the whole result.
+type NDJSONWriter struct {
+	c       *gin.Context
+	enc     *json.Encoder
+	mask    bool
+	pending int
+}
+
+// NewNDJSONWriter starts a 200 NDJSON response. When mask is set, every row is passed
+// through MaskSensitiveFields (streams bypass the buffering masking middleware).
+func NewNDJSONWriter(c *gin.Context, mask bool) *NDJSONWriter {
+	c.Header("Content-Type", NDJSONContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+
+	return &NDJSONWriter{c: c, enc: json.NewEncoder(c.Writer), mask: mask}
+}
+
+// Write encodes a single row. It returns the request context error once the client has gone away.
+func (w *NDJSONWriter) Write(row interface{}) error {
+	if err := w.c.Request.Context().Err(); err != nil {
+		return err
+	}
+
+	if w.mask {
+		raw, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return err
+		}
+		row = MaskSensitiveFields(decoded)
+	}
+
+	if err := w.enc.Encode(row); err != nil {
+		return err
+	}
+
+	w.pending++
+	if w.pending >= ndjsonFlushEvery {
+		w.Flush()
+	}
+	return nil
+}
+
+// WriteError appends a final {"error": ...} line; the status code has already been sent
+func (w *NDJSONWriter) WriteError(err error) {
+	_ = w.enc.Encode(gin.H{"error": err.Error()})
+	w.Flush()
+}
+
+// Flush pushes buffered rows to the client
+func (w *NDJSONWriter) Flush() {
+	w.pending = 0
+	w.c.Writer.Flush()
+}
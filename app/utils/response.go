@@ -6,12 +6,65 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Response represents a standard API response
+// Response represents a standard API response. Every JSON endpoint answers with it: on success
+// Data holds the payload, on failure Error holds a human-readable message and Code one of the
+// Code* constants below, which clients can switch on. Only the health check keeps a bare body,
+// for load balancer probes.
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Details interface{} `json:"details,omitempty"` // e.g. the violated password rules
+}
+
+// Error codes of failed responses
+const (
+	CodeBadRequest             = "bad_request"
+	CodeValidationFailed       = "validation_failed"
+	CodeUnauthorized           = "unauthorized"
+	CodeForbidden              = "forbidden"
+	CodePasswordChangeRequired = "password_change_required"
+	CodeTwoFactorSetupRequired = "two_factor_setup_required"
+	CodeNotFound               = "not_found"
+	CodeMethodNotAllowed       = "method_not_allowed"
+	CodeConflict               = "conflict"
+	CodeUnprocessableEntity    = "unprocessable_entity"
+	CodeLocked                 = "locked"
+	CodeTooManyRequests        = "too_many_requests"
+	CodeInternal               = "internal_error"
+	CodeServiceUnavailable     = "service_unavailable"
+)
+
+// ErrorCodeFor returns the default error code of an HTTP status
+func ErrorCodeFor(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessableEntity
+	case http.StatusLocked:
+		return CodeLocked
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	}
+	if statusCode >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
 }
 
 // SuccessResponse sends a successful JSON response
@@ -23,11 +76,18 @@ func SuccessResponse(c *gin.Context, statusCode int, message string, data interf
 	})
 }
 
-// ErrorResponse sends an error JSON response
+// ErrorResponse sends an error JSON response with the default code of the status
 func ErrorResponse(c *gin.Context, statusCode int, message string) {
+	ErrorCodeResponse(c, statusCode, ErrorCodeFor(statusCode), message, nil)
+}
+
+// ErrorCodeResponse sends an error JSON response with an explicit code and optional details
+func ErrorCodeResponse(c *gin.Context, statusCode int, code string, message string, details interface{}) {
 	c.JSON(statusCode, Response{
 		Success: false,
 		Error:   message,
+		Code:    code,
+		Details: details,
 	})
 }
 
@@ -41,11 +101,21 @@ func Unauthorized(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusUnauthorized, message)
 }
 
+// Forbidden sends a 403 Forbidden response
+func Forbidden(c *gin.Context, message string) {
+	ErrorResponse(c, http.StatusForbidden, message)
+}
+
 // NotFound sends a 404 Not Found response
 func NotFound(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusNotFound, message)
 }
 
+// Conflict sends a 409 Conflict response
+func Conflict(c *gin.Context, message string) {
+	ErrorResponse(c, http.StatusConflict, message)
+}
+
 // InternalServerError sends a 500 Internal Server Error response
 func InternalServerError(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusInternalServerError, message)
@@ -56,6 +126,11 @@ func Created(c *gin.Context, message string, data interface{}) {
 	SuccessResponse(c, http.StatusCreated, message, data)
 }
 
+// Accepted sends a 202 Accepted response, used for requests queued as background jobs
+func Accepted(c *gin.Context, message string, data interface{}) {
+	SuccessResponse(c, http.StatusAccepted, message, data)
+}
+
 // OK sends a 200 OK response
 func OK(c *gin.Context, message string, data interface{}) {
 	SuccessResponse(c, http.StatusOK, message, data)
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Devanagari → Latin tables (simplified ITRANS-like romanization)
+var devanagariVowels = map[rune]string{
+	'अ': "a", 'आ': "aa", 'इ': "i", 'ई': "ii", 'उ': "u", 'ऊ': "uu",
+	'ऋ': "ri", 'ए': "e", 'ऐ': "ai", 'ओ': "o", 'औ': "au", 'ऑ': "o", 'ऍ': "e",
+}
+
+var devanagariMatras = map[rune]string{
+	'ा': "aa", 'ि': "i", 'ी': "ii", 'ु': "u", 'ू': "uu", 'ृ': "ri",
+	'े': "e", 'ै': "ai", 'ो': "o", 'ौ': "au", 'ॉ': "o", 'ॅ': "e",
+}
+
+var devanagariConsonants = map[rune]string{
+	'क': "k", 'ख': "kh", 'ग': "g", 'घ': "gh", 'ङ': "n",
+	'च': "ch", 'छ': "chh", 'ज': "j", 'झ': "jh", 'ञ': "n",
+	'ट': "t", 'ठ': "th", 'ड': "d", 'ढ': "dh", 'ण': "n",
+	'त': "t", 'थ': "th", 'द': "d", 'ध': "dh", 'न': "n",
+	'प': "p", 'फ': "ph", 'ब': "b", 'भ': "bh", 'म': "m",
+	'य': "y", 'र': "r", 'ल': "l", 'व': "v", 'ळ': "l",
+	'श': "sh", 'ष': "sh", 'स': "s", 'ह': "h",
+	'\u0958': "q", '\u0959': "kh", '\u095A': "g", '\u095B': "z", '\u095C': "r", '\u095D': "rh", '\u095E': "f", '\u095F': "y", // precomposed nukta forms
+}
+
+const (
+	devanagariVirama      = '्'
+	devanagariNukta       = '़'
+	devanagariAnusvara    = 'ं'
+	devanagariCandrabindu = 'ँ'
+	devanagariVisarga     = 'ः'
+)
+
+// nukta forms of base consonants (when typed as consonant + U+093C)
+var devanagariNuktaForms = map[rune]string{
+	'क': "q", 'ख': "kh", 'ग': "g", 'ज': "z", 'ड': "r", 'ढ': "rh", 'फ': "f",
+}
+
+// syllable is a consonant (possibly empty) followed by a vowel (possibly empty)
+type syllable struct {
+	cons     string
+	vowel    string
+	inherent bool // vowel is the implicit schwa, eligible for deletion
+}
+
+// TransliterateDevanagari converts Devanagari text to Latin script.
+// Non-Devanagari characters are passed through unchanged. Hindi schwa deletion
+// is applied so "विकास" becomes "vikaas" (not "vikaasa") and "कमला" becomes "kamlaa".
+func TransliterateDevanagari(s string) string {
+	var out strings.Builder
+	var word []syllable
+	var lastConsonant rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		applySchwaDeletion(word)
+		for _, syl := range word {
+			out.WriteString(syl.cons)
+			out.WriteString(syl.vowel)
+		}
+		word = word[:0]
+	}
+
+	for _, r := range s {
+		last := len(word) - 1
+		switch {
+		case devanagariConsonants[r] != "":
+			word = append(word, syllable{cons: devanagariConsonants[r], vowel: "a", inherent: true})
+			lastConsonant = r
+		case devanagariMatras[r] != "":
+			if last >= 0 && word[last].inherent {
+				word[last].vowel = devanagariMatras[r]
+				word[last].inherent = false
+			} else {
+				word = append(word, syllable{vowel: devanagariMatras[r]})
+			}
+		case devanagariVowels[r] != "":
+			word = append(word, syllable{vowel: devanagariVowels[r]})
+		case r == devanagariVirama:
+			if last >= 0 && word[last].inherent {
+				word[last].vowel = ""
+				word[last].inherent = false
+			}
+		case r == devanagariNukta:
+			if latin, ok := devanagariNuktaForms[lastConsonant]; ok && last >= 0 {
+				word[last].cons = latin
+			}
+		case r == devanagariAnusvara || r == devanagariCandrabindu:
+			word = append(word, syllable{cons: "n"})
+		case r == devanagariVisarga:
+			word = append(word, syllable{cons: "h"})
+		case r >= '०' && r <= '९':
+			flush()
+			out.WriteRune('0' + (r - '०'))
+		default:
+			flush()
+			out.WriteRune(r)
+		}
+	}
+	flush()
+
+	return out.String()
+}
+
+// applySchwaDeletion drops the word-final schwa and schwas in a V C_C V context
+func applySchwaDeletion(word []syllable) {
+	n := len(word)
+	if n > 1 && word[n-1].inherent {
+		word[n-1].vowel = ""
+		word[n-1].inherent = false
+	}
+	for i := n - 2; i >= 1; i-- {
+		if !word[i].inherent {
+			continue
+		}
+		prevHasVowel := word[i-1].vowel != ""
+		nextIsCV := word[i+1].cons != "" && word[i+1].vowel != ""
+		if prevHasVowel && nextIsCV {
+			word[i].vowel = ""
+			word[i].inherent = false
+		}
+	}
+}
+
+// NameKey returns a script-independent phonetic key for a person's name, so that
+// spelling variants such as "Vikas", "Vikaas" and "विकास" all produce the same key.
+func NameKey(name string) string {
+	latin := strings.ToLower(TransliterateDevanagari(name))
+
+	var words []string
+	for _, w := range strings.FieldsFunc(latin, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !unicode.IsDigit(r)
+	}) {
+		if folded := foldLatinName(w); folded != "" {
+			words = append(words, folded)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+var latinNameReplacer = strings.NewReplacer(
+	"chh", "c", "ch", "c",
+	"ph", "f", "w", "v", "z", "j", "q", "k", "x", "ks", "ck", "k",
+	"ee", "i", "oo", "u",
+)
+
+// foldLatinName collapses common romanization variants of Indian names
+func foldLatinName(w string) string {
+	w = latinNameReplacer.Replace(w)
+
+	var b strings.Builder
+	var prev rune
+	for _, r := range w {
+		// Drop aspiration: "bh"→"b", "sh"→"s", "th"→"t"
+		if r == 'h' && prev != 0 && !isLatinVowel(prev) {
+			continue
+		}
+		// Collapse doubled letters: "aa"→"a", "ii"→"i", "pp"→"p"
+		if r == prev {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+
+	return b.String()
+}
+
+func isLatinVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestNameKey(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"Vikas", "Vikaas", "VIKAS", "विकास"}, "vikas"},
+		{[]string{"Deepak", "Dipak", "दीपक"}, "dipak"},
+		{[]string{"Shri Ram", "Sri Ram", "श्री राम"}, "sri ram"},
+		{[]string{"Lakshmi", "Laxmi", "लक्ष्मी"}, "laksmi"},
+		{[]string{"Bhushan", "भूषण"}, "busan"},
+		{[]string{"Zakir", "Jakir"}, "jakir"},
+		{[]string{"Mohit Sharma", "  mohit   SHARMA ", "मोहित शर्मा"}, "mohit sarma"},
+		{[]string{"Ram-Kumar 2", "Ram Kumar २"}, "ram kumar 2"},
+		{[]string{"", "   ", "-"}, ""},
+	}
+	for _, tt := range tests {
+		for _, name := range tt.names {
+			if got := NameKey(name); got != tt.want {
+				t.Errorf("NameKey(%q) = %q, want %q", name, got, tt.want)
+			}
+		}
+	}
+}
@@ -53,6 +53,8 @@ func ValidateBranchUpdateFields(updateData map[string]interface{}) error {
 		"id":         true,
 		"created_on": true,
 		"created_by": true,
+		"deleted_at": true, // changed only through delete/restore
+		"is_sandbox": true, // changed only through /admin/sandbox
 	}
 
 	for field := range updateData {
@@ -131,6 +133,10 @@ func ValidateBranchUpdateFields(updateData map[string]interface{}) error {
 		}
 	}
 
+	if err := ValidateCoordinateFields(updateData); err != nil {
+		return err
+	}
+
 	// Validate status if present
 	if status, ok := updateData["status"]; ok {
 		if _, ok := status.(bool); !ok {
@@ -442,6 +448,7 @@ func ValidateBranchMemberUpdateFields(updateData map[string]interface{}) error {
 		"created_on": true,
 		"created_by": true,
 		"branch_id":  true, // branch should not be changed via update
+		"membership_id": true, // allocated by the sequence service
 	}
 
 	for field := range updateData {
@@ -483,3 +490,47 @@ func ValidateTimeFormat(timeStr string) error {
 
 	return nil
 }
+
+// ValidateCoordinates checks an optional map location: both or neither of latitude and
+// longitude, within the valid ranges
+func ValidateCoordinates(latitude, longitude *float64) error {
+	if (latitude == nil) != (longitude == nil) {
+		return errors.New("latitude and longitude must be set together")
+	}
+	if latitude != nil && (*latitude < -90 || *latitude > 90) {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if longitude != nil && (*longitude < -180 || *longitude > 180) {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// ValidateCoordinateFields validates latitude/longitude in a partial update (null clears them)
+func ValidateCoordinateFields(updateData map[string]interface{}) error {
+	latRaw, hasLat := updateData["latitude"]
+	lngRaw, hasLng := updateData["longitude"]
+	if !hasLat && !hasLng {
+		return nil
+	}
+	if hasLat != hasLng {
+		return errors.New("latitude and longitude must be updated together")
+	}
+
+	var latitude, longitude *float64
+	for _, field := range []struct {
+		name  string
+		raw   interface{}
+		value **float64
+	}{{"latitude", latRaw, &latitude}, {"longitude", lngRaw, &longitude}} {
+		if field.raw == nil {
+			continue
+		}
+		v, ok := field.raw.(float64)
+		if !ok {
+			return errors.New(field.name + " must be a number")
+		}
+		*field.value = &v
+	}
+	return ValidateCoordinates(latitude, longitude)
+}
@@ -41,6 +41,14 @@ func ValidateDonationUpdateFields(updateData map[string]interface{}) error {
 		"created_by": true,
 		"event_id":   true,   // event should not be changed after creation
 		"branch_id":  true,   // branch should not be changed after creation
+		"receipt_s3_key": true, // set via receipt upload
+		"ocr_text":       true, // set by OCR worker
+		"donor_name_key": true, // derived from donor_name
+		"receipt_number": true, // allocated by the sequence service
+		"deleted_at":     true, // changed only through delete/restore
+		"voided_on":      true, // changed only through void
+		"voided_by":      true,
+		"void_reason":    true,
 	}
 
 	for field := range updateData {
@@ -71,5 +79,12 @@ func ValidateDonationUpdateFields(updateData map[string]interface{}) error {
 		}
 	}
 
+	if remarks, ok := updateData["remarks"]; ok {
+		remarksStr, _ := remarks.(string)
+		if len(remarksStr) > 2000 {
+			return errors.New("remarks must not exceed 2000 characters")
+		}
+	}
+
 	return nil
 }
@@ -44,6 +44,9 @@ func ValidateEventUpdateFields(updateData map[string]interface{}) error {
 		"id":         true,
 		"created_on": true,
 		"created_by": true,
+		"approval_status": true, // changed only through the approval workflow
+		"report_number":   true, // allocated by the sequence service
+		"deleted_at":      true, // changed only through delete/restore
 	}
 
 	for field := range updateData {
@@ -136,6 +139,14 @@ func ValidateEventUpdateFields(updateData map[string]interface{}) error {
 		}
 	}
 
+	// Declared donation total: a non-negative amount, or null to clear it
+	if declared, ok := updateData["declared_donation_total"]; ok && declared != nil {
+		val, isNumber := declared.(float64)
+		if !isNumber || val < 0 {
+			return errors.New("declared_donation_total must be a non-negative number or null")
+		}
+	}
+
 	// Validate status if present
 	if status, ok := updateData["status"]; ok {
 		statusStr := strings.TrimSpace(status.(string))
@@ -142,3 +142,38 @@ func ValidateSpecialGuestUpdateFields(updateData map[string]interface{}) error {
 
 	return nil
 }
+
+// guestUpdatableFields are the fields of a guest master record that can be updated
+var guestUpdatableFields = map[string]bool{
+	"prefix":       true,
+	"first_name":   true,
+	"middle_name":  true,
+	"last_name":    true,
+	"designation":  true,
+	"organization": true,
+	"email":        true,
+	"contact":      true,
+	"city":         true,
+	"state":        true,
+}
+
+// ValidateGuestUpdateFields validates an update of a guest master record
+func ValidateGuestUpdateFields(updateData map[string]interface{}) error {
+	for field, value := range updateData {
+		if !guestUpdatableFields[field] {
+			return errors.New("field '" + field + "' cannot be updated")
+		}
+		if _, ok := value.(string); !ok {
+			return errors.New(field + " must be a string")
+		}
+	}
+
+	if contact, ok := updateData["contact"]; ok {
+		numStr := strings.TrimSpace(contact.(string))
+		if numStr != "" && !isValidPhoneNumber(numStr) {
+			return errors.New("invalid contact format")
+		}
+	}
+
+	return ValidateSpecialGuestUpdateFields(updateData)
+}
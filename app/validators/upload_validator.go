@@ -0,0 +1,59 @@
+package validators
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Categories accepted by the media upload endpoints
+var (
+	EventMediaCategories  = []string{"Event Photos", "Video Coverage", "Testimonials", "Press Release"}
+	BranchMediaCategories = []string{"Branch Photos", "Video Coverage", "Documents", "Other"}
+)
+
+// MaxUploadFilenameLength caps the length of uploaded file names (original_filename column)
+const MaxUploadFilenameLength = 255
+
+// FieldError is one invalid field of a request; requests report all of them at once
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// ValidateUploadID parses a positive numeric ID form field
+func ValidateUploadID(value string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("must be a positive integer")
+	}
+	return uint(id), nil
+}
+
+// ValidateUploadCategory checks a category against the allowed ones (case-sensitive, as stored)
+func ValidateUploadCategory(category string, allowed []string) error {
+	for _, value := range allowed {
+		if category == value {
+			return nil
+		}
+	}
+	return errors.New("must be one of: " + strings.Join(allowed, ", "))
+}
+
+// ValidateUploadFilename checks the name of an uploaded file
+func ValidateUploadFilename(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("file name is required")
+	}
+	if utf8.RuneCountInString(name) > MaxUploadFilenameLength {
+		return errors.New("file name must not exceed 255 characters")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return errors.New("file name must not contain control characters")
+		}
+	}
+	return nil
+}
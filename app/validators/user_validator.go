@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/followCode/djjs-event-reporting-backend/app/services/auth"
 )
 
 // ValidateUserInput performs comprehensive validation on user data
@@ -63,21 +65,41 @@ func ValidateContactNumber(contactNumber string) error {
 	return nil
 }
 
-// ValidateUpdateFields validates update request fields
-func ValidateUpdateFields(updateData map[string]interface{}) error {
-	// List of fields that should not be updated
-	immutableFields := map[string]bool{
-		"id":         true,
-		"created_on": true,
-		"created_by": true,
-		"password":   true, // password should be updated via separate endpoint
-		"role_id":    true, // role should be updated via separate endpoint
+// ValidateNotificationChannel validates the channel of a user's critical alerts (empty keeps the
+// default, email)
+func ValidateNotificationChannel(channel string) error {
+	switch channel {
+	case "", "email", "sms", "whatsapp":
+		return nil
 	}
+	return errors.New("notification_channel must be email, sms or whatsapp")
+}
 
-	for field := range updateData {
-		if immutableFields[field] {
+// updatableUserFields are the fields PUT /users/:id may change. A GORM map update writes every
+// key as a column, so anything else (status, role, branch, two-factor settings) is refused
+// here and changed through its own admin endpoint.
+var updatableUserFields = map[string]bool{
+	"name":                 true,
+	"email":                true,
+	"contact_number":       true,
+	"notification_channel": true,
+}
+
+// ValidateUpdateFields validates update request fields
+func ValidateUpdateFields(updateData map[string]interface{}) error {
+	for field, value := range updateData {
+		if strings.HasPrefix(field, "totp_") {
+			return fmt.Errorf("field '%s' cannot be updated: two-factor authentication is managed through /api/v1/auth/2fa", field)
+		}
+		if field == "branch_id" || field == "region_id" {
+			return fmt.Errorf("field '%s' cannot be updated: branches are assigned by admins through /api/v1/admin/users/{id}/branch", field)
+		}
+		if !updatableUserFields[field] {
 			return fmt.Errorf("field '%s' cannot be updated", field)
 		}
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("field '%s' must be a string", field)
+		}
 	}
 
 	// Validate specific fields if present
@@ -103,6 +125,13 @@ func ValidateUpdateFields(updateData map[string]interface{}) error {
 		}
 	}
 
+	if channel, ok := updateData["notification_channel"]; ok {
+		channelStr, _ := channel.(string)
+		if err := ValidateNotificationChannel(channelStr); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -158,41 +187,14 @@ func ValidatePasswordChange(oldPassword, newPassword, confirmPassword string) er
 	return nil
 }
 
-// ValidatePasswordStrength validates password strength
+// ValidatePasswordStrength validates password strength against the configured password
+// policy (see auth.CurrentPasswordPolicy)
 func ValidatePasswordStrength(password string) error {
 	if strings.TrimSpace(password) == "" {
 		return errors.New("password is required and cannot be empty")
 	}
 
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-
-	if len(password) > 255 {
-		return errors.New("password must not exceed 255 characters")
-	}
-
-	// Check for at least one uppercase letter
-	if !regexp.MustCompile(`[A-Z]`).MatchString(password) {
-		return errors.New("password must contain at least one uppercase letter")
-	}
-
-	// Check for at least one lowercase letter
-	if !regexp.MustCompile(`[a-z]`).MatchString(password) {
-		return errors.New("password must contain at least one lowercase letter")
-	}
-
-	// Check for at least one digit
-	if !regexp.MustCompile(`\d`).MatchString(password) {
-		return errors.New("password must contain at least one digit")
-	}
-
-	// Check for at least one special character
-	if !regexp.MustCompile(`[!@#$%^&*()_+\-=\[\]{};':"\\|,.<>\/?]`).MatchString(password) {
-		return errors.New("password must contain at least one special character (!@#$%^&*)")
-	}
-
-	return nil
+	return auth.CurrentPasswordPolicy().Validate(password)
 }
 
 // Helper function to validate phone number format
@@ -50,6 +50,7 @@ func ValidateVolunteerUpdateFields(updateData map[string]interface{}) error {
 		"branch_id":  true,   // branch should not be changed after creation
 		"branch":     true,   // branch relation should not be changed
 		"event":      true,   // event relation should not be changed
+		"deleted_at": true,   // changed only through delete/restore
 	}
 
 	for field := range updateData {
@@ -0,0 +1,856 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config is the typed configuration of the API, loaded once at startup by Load and passed to
+// the dependencies that need it (see dependencies.InitializeDependencies). It covers the
+// settings of the server and of the startup dependencies, and the feature settings (media
+// processing, jobs and schedulers, reporting) the services read once LoadServiceConfig applied
+// them.
+//
+// Values come from the environment and, when CONFIG_FILE names one, from a file of KEY=VALUE
+// lines in .env format. The environment wins over the file, so a deployment can keep shared
+// settings in the file and inject secrets as variables.
+type Config struct {
+	Server    ServerConfig
+	Log       LogConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Redis     RedisConfig
+	Mail      MailConfig
+	Messaging MessagingConfig
+	Storage   StorageConfig
+	Startup   StartupConfig
+	Media     MediaConfig
+	Jobs      JobsConfig
+	Reporting ReportingConfig
+	Geocoding GeocodingConfig
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port           string   // PORT (default 8080)
+	GinMode        string   // GIN_MODE; unset runs in debug mode like gin itself
+	AllowedOrigins []string // ALLOWED_ORIGINS, comma separated; required outside debug mode
+	EnableSwagger  bool     // ENABLE_SWAGGER; always on in debug mode
+	APIServerURL   string   // API_SERVER_URL shown in the Swagger UI (default: from the request)
+	// DrainDelay (SHUTDOWN_DRAIN_DELAY, default 5s) is how long /ready fails before the
+	// server stops accepting connections, so load balancers stop routing to it first
+	DrainDelay time.Duration
+	// ShutdownTimeout (SHUTDOWN_TIMEOUT, default 30s) bounds draining in-flight requests and
+	// running jobs
+	ShutdownTimeout time.Duration
+	// MetricsToken (METRICS_TOKEN) is the Bearer token scrapers send to /metrics; without it
+	// the endpoint is open
+	MetricsToken string
+	// LegacyAPISunset (API_LEGACY_SUNSET, YYYY-MM-DD) is announced in the Sunset header of the
+	// unversioned /api routes; zero announces none
+	LegacyAPISunset  time.Time
+	SLOLogViolations bool // SLO_LOG_VIOLATIONS logs every latency budget violation
+	Shedding         SheddingConfig
+}
+
+// LogConfig configures the structured logger, see utils.NewLogger
+type LogConfig struct {
+	Level  string // LOG_LEVEL: debug, info, warn or error (default info)
+	Format string // LOG_FORMAT: json (default), or console for local development
+}
+
+// SheddingConfig configures brown-out mode, see middleware.LoadShedding
+type SheddingConfig struct {
+	MaxInFlight  int64         // SHED_MAX_IN_FLIGHT (default 200, 0 = no limit)
+	CPUThreshold float64       // SHED_CPU_THRESHOLD, 0-1 (default 0 = off)
+	Cooldown     time.Duration // SHED_COOLDOWN (default 30s)
+}
+
+// Debug reports whether the server runs in gin's debug mode
+func (s ServerConfig) Debug() bool {
+	return s.GinMode == "" || s.GinMode == "debug"
+}
+
+// DatabaseConfig configures the Postgres connections. POSTGRES_* build the connection URL;
+// DATABASE_URL is used instead when they are not all set (and by the auth pool even when they
+// are).
+type DatabaseConfig struct {
+	URL              string // DATABASE_URL
+	Host             string // POSTGRES_HOST
+	Port             string // PG_PORT (default 5432)
+	User             string // POSTGRES_USER
+	Password         string // POSTGRES_PASSWORD
+	Name             string // POSTGRES_DB
+	StatementTimeout time.Duration
+	// Migrate (MIGRATE_ON_STARTUP, default true) applies pending migrations before serving;
+	// turn it off to run them with "djjsctl db-migrate" during deploys instead
+	Migrate bool
+	// SchemaCheck (SCHEMA_CHECK) is enforce (default), read-only, warn or off, see
+	// services.VerifySchema
+	SchemaCheck string
+}
+
+func (d DatabaseConfig) hasComponents() bool {
+	return d.Host != "" && d.User != "" && d.Password != "" && d.Name != ""
+}
+
+// componentsURL builds the connection URL from POSTGRES_*
+func (d DatabaseConfig) componentsURL() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		url.QueryEscape(d.User), url.QueryEscape(d.Password), url.QueryEscape(d.Host), d.Port, url.QueryEscape(d.Name))
+}
+
+// GORMURL is the URL of the legacy GORM pool
+func (d DatabaseConfig) GORMURL() string {
+	if d.hasComponents() {
+		return d.componentsURL()
+	}
+	return d.URL
+}
+
+// AuthURL is the URL of the pgx pool of the auth system
+func (d DatabaseConfig) AuthURL() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	return d.componentsURL()
+}
+
+// AuthConfig configures tokens, cookies, rate limits and the password policy
+type AuthConfig struct {
+	JWTSecret            string
+	JWTPreviousSecret    string
+	JWTTTL               time.Duration
+	JWTIssuer            string
+	JWTAudience          string
+	TokenPepper          string
+	TOTPIssuer           string
+	CookieSecure         bool
+	CookieSameSite       string
+	CookiePath           string
+	RequireEmailVerified bool
+	FrontendOrigin       string
+	TrustProxy           bool
+
+	RateLimitLoginPerIP             int
+	RateLimitLoginPerEmail          int
+	RateLimitForgotPasswordPerIP    int
+	RateLimitForgotPasswordPerEmail int
+	RateLimitPublicPerMinute        int
+	RateLimitWindow                 time.Duration
+
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordHistorySize   int
+	PasswordMaxAge        time.Duration
+}
+
+// RedisConfig configures the optional Redis connection
+type RedisConfig struct {
+	URL string // REDIS_URL; empty disables rate limiting and the shared caches
+}
+
+// MailConfig configures outgoing email, see LoadMailConfig
+type MailConfig struct {
+	Driver       string // log (default), smtp or ses
+	From         string
+	LinkBaseURL  string // defaults to the frontend origin
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SESRegion    string // defaults to AWS_REGION
+	// SES credentials (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY); without them the AWS SDK's
+	// default credential chain is used
+	SESAccessKeyID     string
+	SESSecretAccessKey string
+}
+
+// MessagingConfig configures outgoing SMS and WhatsApp messages, see LoadMessagingConfig
+type MessagingConfig struct {
+	SMSDriver      string // log (default), twilio or msg91
+	WhatsAppDriver string // log (default), twilio or meta
+	CountryCode    string // MESSAGING_COUNTRY_CODE for numbers without one (default 91)
+
+	TwilioAccountSID   string
+	TwilioAuthToken    string
+	TwilioSMSFrom      string // sender number or messaging service SID
+	TwilioWhatsAppFrom string // WhatsApp-enabled sender number
+
+	MSG91AuthKey string
+	// MSG91TemplateIDs maps message templates to MSG91 flow IDs (MSG91_TEMPLATE_IDS, e.g.
+	// "event_approved=64f0...,event_rejected=64f1...")
+	MSG91TemplateIDs map[string]string
+
+	WhatsAppPhoneNumberID string // WhatsApp Business Cloud API sender
+	WhatsAppAccessToken   string
+	WhatsAppLanguage      string // language of the approved templates (default en)
+}
+
+// StorageConfig configures the file storage backend, see services.NewStorageFromConfig
+type StorageConfig struct {
+	Driver     string // s3 (default, also "minio"), local or memory
+	LocalDir   string // STORAGE_LOCAL_DIR (default ./storage)
+	SigningKey string // STORAGE_SIGNING_KEY (default JWT_SECRET)
+	PublicURL  string // STORAGE_PUBLIC_URL
+	S3         S3Config
+}
+
+// S3Config holds the static credentials and bucket of the S3 backend
+type S3Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Bucket          string
+	Region          string // defaults to us-east-1 with a custom endpoint
+	Endpoint        string // AWS_S3_ENDPOINT for MinIO and other S3-compatible servers
+	ForcePathStyle  bool   // default true with a custom endpoint
+}
+
+// StartupConfig tunes the ordered startup of the dependencies
+type StartupConfig struct {
+	// LazyDependencies (LAZY_DEPENDENCIES, comma separated, e.g. "storage,mail,redis") are
+	// initialized in the background instead of being waited for
+	LazyDependencies []string
+	// RequiredDependencies (REQUIRED_DEPENDENCIES, e.g. "storage") stop the startup when they
+	// fail instead of leaving the API degraded
+	RequiredDependencies []string
+	// InitTimeouts overrides the timeout of an initialization attempt by dependency name
+	// (<NAME>_INIT_TIMEOUT, e.g. STORAGE_INIT_TIMEOUT=1m)
+	InitTimeouts map[string]time.Duration
+}
+
+// MediaConfig configures uploads, downloads and media processing, see LoadServiceConfig
+type MediaConfig struct {
+	UploadChunkBytes   int64         // UPLOAD_CHUNK_BYTES (default 8 MiB, at least 5 MiB)
+	UploadSessionTTL   time.Duration // UPLOAD_SESSION_TTL (default 24h)
+	DownloadURLTTL     time.Duration // FILE_DOWNLOAD_URL_TTL, lifetime of download redirects (default 1m)
+	PublicAssetBaseURL string        // PUBLIC_ASSET_BASE_URL (default STORAGE_PUBLIC_URL)
+
+	Thumbnails     bool   // THUMBNAILS_ENABLED (default true)
+	ImageNormalize bool   // MEDIA_IMAGE_NORMALIZE (default true)
+	ImageMaxSide   int    // MEDIA_IMAGE_MAX_SIDE; 0 keeps the original dimensions
+	HEICConverter  string // MEDIA_HEIC_CONVERTER command; without it HEIC uploads are rejected
+	Transcoder     string // MEDIA_TRANSCODER command; without it videos are served as uploaded
+
+	ImageHasher       string // MEDIA_IMAGE_HASHER: phash (default) or off
+	DuplicateDistance int    // MEDIA_DUPLICATE_DISTANCE, 0-64 hash bits (default 10)
+	Scanner           string // MEDIA_SCANNER: clamav, or empty for none
+	ClamAVAddress     string // CLAMAV_ADDRESS
+	OCRProvider       string // OCR_PROVIDER: tesseract, or empty for none
+	TesseractPath     string // TESSERACT_PATH
+	OCRLanguages      string // OCR_LANGUAGES
+
+	ManifestBucket        string        // MEDIA_MANIFEST_BUCKET (default the storage bucket)
+	ManifestSigningKey    string        // MEDIA_MANIFEST_SIGNING_KEY; no manifests without it
+	ManifestEncryptionKey string        // MEDIA_MANIFEST_ENCRYPTION_KEY; unencrypted without it
+	ManifestInterval      time.Duration // MEDIA_MANIFEST_INTERVAL (default 24h, 0 = off)
+}
+
+// JobsConfig configures the job workers and the schedulers, see LoadServiceConfig
+type JobsConfig struct {
+	Workers                 int           // JOB_WORKERS (default 2, 0 runs none)
+	RetentionDays           int           // JOB_RETENTION_DAYS (default 30)
+	WarmUpOnStartup         bool          // WARMUP_ON_STARTUP (default true)
+	BusinessMetricsInterval time.Duration // BUSINESS_METRICS_INTERVAL (default 1m, 0 = off)
+	StorageUsageInterval    time.Duration // STORAGE_USAGE_INTERVAL (default 24h, 0 = off)
+	// Hours (0-23, server time) of the daily jobs, -1 when set to "off"
+	ApprovalDigestHour int // APPROVAL_DIGEST_HOUR (default 7)
+	StatsSnapshotHour  int // STATS_SNAPSHOT_HOUR (default 23)
+}
+
+// ReportingConfig configures event reports and donation receipts, see LoadServiceConfig
+type ReportingConfig struct {
+	DraftRetentionDays      int    // EVENT_DRAFT_RETENTION_DAYS (default 30)
+	ReportDueDays           int    // EVENT_REPORT_DUE_DAYS (default 7)
+	ReceiptOrganizationName string // RECEIPT_ORGANIZATION_NAME printed on donation receipts
+}
+
+// GeocodingConfig configures branch geocoding, see LoadServiceConfig
+type GeocodingConfig struct {
+	Provider     string // GEOCODER: nominatim, or empty for none
+	NominatimURL string // NOMINATIM_URL (default the public instance)
+	UserAgent    string // GEOCODER_USER_AGENT
+	CountryCodes string // GEOCODER_COUNTRY_CODES, e.g. "in,np"
+}
+
+// ConfigError lists every problem found in the configuration, so all of them can be fixed
+// before the next start
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems returns the error for a list of problems, nil when there are none
+func problems(list []string) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: list}
+}
+
+// Load reads the configuration from the environment and CONFIG_FILE and validates the settings
+// the API cannot start without (server, database, auth). Mail and storage are checked when their
+// dependency initializes, since the API runs degraded without them; see MailConfig.Validate and
+// StorageConfig.Validate.
+func Load() (*Config, error) {
+	cfg, list, err := read()
+	if err != nil {
+		return nil, err
+	}
+	var invalid *ConfigError
+	if errors.As(cfg.Validate(), &invalid) {
+		list = append(list, invalid.Problems...)
+	}
+	if err := problems(list); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read reads the configuration without validating it. Values that cannot be parsed are
+// reported together.
+func Read() (*Config, error) {
+	cfg, list, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if err := problems(list); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// read returns the configuration and the values it could not parse
+func read() (*Config, []string, error) {
+	r, err := newReader(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &Config{}
+	cfg.Server = ServerConfig{
+		Port:          r.str("PORT", "8080"),
+		GinMode:       r.str("GIN_MODE", ""),
+		EnableSwagger: r.str("ENABLE_SWAGGER", "") == "true",
+		APIServerURL:  r.str("API_SERVER_URL", ""),
+
+		DrainDelay:      r.duration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout: r.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		MetricsToken:     r.str("METRICS_TOKEN", ""),
+		SLOLogViolations: r.boolean("SLO_LOG_VIOLATIONS", false),
+		Shedding: SheddingConfig{
+			MaxInFlight:  int64(r.integer("SHED_MAX_IN_FLIGHT", 200, 0)),
+			CPUThreshold: r.float("SHED_CPU_THRESHOLD", 0, 0, 1),
+			Cooldown:     r.duration("SHED_COOLDOWN", 30*time.Second),
+		},
+	}
+	if value := r.str("API_LEGACY_SUNSET", ""); value != "" {
+		sunset, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			r.problems = append(r.problems, fmt.Sprintf("API_LEGACY_SUNSET must be a date such as 2026-12-31, got %q", value))
+		}
+		cfg.Server.LegacyAPISunset = sunset
+	}
+	for _, origin := range strings.Split(r.str("ALLOWED_ORIGINS", ""), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.Server.AllowedOrigins = append(cfg.Server.AllowedOrigins, origin)
+		}
+	}
+	if len(cfg.Server.AllowedOrigins) == 0 && cfg.Server.Debug() {
+		cfg.Server.AllowedOrigins = []string{"http://localhost:4200", "http://localhost:3000"}
+	}
+
+	cfg.Log = LogConfig{
+		Level:  strings.ToLower(r.str("LOG_LEVEL", "info")),
+		Format: strings.ToLower(r.str("LOG_FORMAT", "json")),
+	}
+
+	cfg.Database = DatabaseConfig{
+		URL:              r.str("DATABASE_URL", ""),
+		Host:             r.str("POSTGRES_HOST", ""),
+		Port:             r.str("PG_PORT", "5432"),
+		User:             r.str("POSTGRES_USER", ""),
+		Password:         r.str("POSTGRES_PASSWORD", ""),
+		Name:             r.str("POSTGRES_DB", ""),
+		StatementTimeout: r.duration("DB_STATEMENT_TIMEOUT", StatementTimeout),
+		Migrate:          r.boolean("MIGRATE_ON_STARTUP", true),
+		SchemaCheck:      strings.ToLower(r.str("SCHEMA_CHECK", "enforce")),
+	}
+	if cfg.Database.SchemaCheck == "readonly" || cfg.Database.SchemaCheck == "read_only" {
+		cfg.Database.SchemaCheck = "read-only"
+	}
+
+	cfg.Auth = AuthConfig{
+		JWTSecret:            r.str("JWT_SECRET", ""),
+		JWTPreviousSecret:    r.str("JWT_SECRET_PREVIOUS", ""),
+		JWTTTL:               r.duration("JWT_TTL", JWTTTL),
+		JWTIssuer:            r.str("JWT_ISSUER", "djjs-backend"),
+		JWTAudience:          r.str("JWT_AUDIENCE", "djjs-frontend"),
+		TokenPepper:          r.str("TOKEN_PEPPER", ""),
+		TOTPIssuer:           r.str("TOTP_ISSUER", TOTPIssuer),
+		CookieSecure:         r.str("COOKIE_SECURE", "") != "false",
+		CookieSameSite:       r.str("COOKIE_SAME_SITE", CookieSameSite),
+		CookiePath:           r.str("COOKIE_PATH", CookiePath),
+		RequireEmailVerified: r.str("REQUIRE_EMAIL_VERIFIED", "") == "true",
+		FrontendOrigin:       r.str("FRONTEND_ORIGIN", ""),
+		TrustProxy:           r.str("TRUST_PROXY", "") == "true",
+
+		RateLimitLoginPerIP:             r.integer("RATE_LIMIT_LOGIN_PER_IP", RateLimitLoginPerIP, 0),
+		RateLimitLoginPerEmail:          r.integer("RATE_LIMIT_LOGIN_PER_EMAIL", RateLimitLoginPerEmail, 0),
+		RateLimitForgotPasswordPerIP:    r.integer("RATE_LIMIT_FORGOT_PASSWORD_PER_IP", RateLimitForgotPasswordPerIP, 0),
+		RateLimitForgotPasswordPerEmail: r.integer("RATE_LIMIT_FORGOT_PASSWORD_PER_EMAIL", RateLimitForgotPasswordPerEmail, 0),
+		RateLimitPublicPerMinute:        r.integer("RATE_LIMIT_PUBLIC_PER_MINUTE", RateLimitPublicPerMinute, 0),
+		RateLimitWindow:                 r.duration("RATE_LIMIT_WINDOW", RateLimitWindow),
+
+		PasswordMinLength:     r.integer("PASSWORD_MIN_LENGTH", PasswordMinLength, 1),
+		PasswordRequireUpper:  r.str("PASSWORD_REQUIRE_UPPER", "") != "false",
+		PasswordRequireLower:  r.str("PASSWORD_REQUIRE_LOWER", "") != "false",
+		PasswordRequireDigit:  r.str("PASSWORD_REQUIRE_DIGIT", "") != "false",
+		PasswordRequireSymbol: r.str("PASSWORD_REQUIRE_SYMBOL", "") != "false",
+		PasswordHistorySize:   r.integer("PASSWORD_HISTORY", PasswordHistorySize, 0),
+		PasswordMaxAge:        time.Duration(r.integer("PASSWORD_MAX_AGE_DAYS", 0, 0)) * 24 * time.Hour,
+	}
+	// Only default to localhost when explicitly in debug mode; production sets FRONTEND_ORIGIN
+	if cfg.Auth.FrontendOrigin == "" && cfg.Server.GinMode == "debug" {
+		cfg.Auth.FrontendOrigin = "http://localhost:4200"
+	}
+
+	cfg.Redis = RedisConfig{URL: r.str("REDIS_URL", "")}
+
+	cfg.Mail = MailConfig{
+		Driver:       strings.ToLower(r.str("MAIL_DRIVER", "log")),
+		From:         r.str("MAIL_FROM", ""),
+		LinkBaseURL:  strings.TrimRight(r.str("MAIL_LINK_BASE_URL", cfg.Auth.FrontendOrigin), "/"),
+		SMTPHost:     r.str("SMTP_HOST", ""),
+		SMTPPort:     r.integer("SMTP_PORT", SMTPPort, 1),
+		SMTPUsername: r.str("SMTP_USERNAME", ""),
+		SMTPPassword: r.str("SMTP_PASSWORD", ""),
+		SESRegion:    r.str("SES_REGION", r.str("AWS_REGION", "")),
+
+		SESAccessKeyID:     r.str("AWS_ACCESS_KEY_ID", ""),
+		SESSecretAccessKey: r.str("AWS_SECRET_ACCESS_KEY", ""),
+	}
+
+	cfg.Messaging = MessagingConfig{
+		SMSDriver:             strings.ToLower(r.str("SMS_DRIVER", "log")),
+		WhatsAppDriver:        strings.ToLower(r.str("WHATSAPP_DRIVER", "log")),
+		CountryCode:           strings.TrimPrefix(r.str("MESSAGING_COUNTRY_CODE", "91"), "+"),
+		TwilioAccountSID:      r.str("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:       r.str("TWILIO_AUTH_TOKEN", ""),
+		TwilioSMSFrom:         r.str("TWILIO_SMS_FROM", ""),
+		TwilioWhatsAppFrom:    r.str("TWILIO_WHATSAPP_FROM", ""),
+		MSG91AuthKey:          r.str("MSG91_AUTH_KEY", ""),
+		MSG91TemplateIDs:      map[string]string{},
+		WhatsAppPhoneNumberID: r.str("WHATSAPP_PHONE_NUMBER_ID", ""),
+		WhatsAppAccessToken:   r.str("WHATSAPP_ACCESS_TOKEN", ""),
+		WhatsAppLanguage:      r.str("WHATSAPP_TEMPLATE_LANGUAGE", "en"),
+	}
+	for _, pair := range strings.Split(r.str("MSG91_TEMPLATE_IDS", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, id, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(id) == "" {
+			r.problems = append(r.problems, fmt.Sprintf("MSG91_TEMPLATE_IDS: expected template=flow_id, got %q", pair))
+			continue
+		}
+		cfg.Messaging.MSG91TemplateIDs[strings.TrimSpace(name)] = strings.TrimSpace(id)
+	}
+
+	endpoint := r.str("AWS_S3_ENDPOINT", "")
+	region := r.str("AWS_REGION", "")
+	if region == "" && endpoint != "" {
+		region = "us-east-1"
+	}
+	cfg.Storage = StorageConfig{
+		Driver:     strings.ToLower(r.str("STORAGE_DRIVER", "s3")),
+		LocalDir:   r.str("STORAGE_LOCAL_DIR", "./storage"),
+		SigningKey: r.str("STORAGE_SIGNING_KEY", cfg.Auth.JWTSecret),
+		PublicURL:  r.str("STORAGE_PUBLIC_URL", ""),
+		S3: S3Config{
+			AccessKeyID:     r.str("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: r.str("AWS_SECRET_ACCESS_KEY", ""),
+			Bucket:          r.str("AWS_S3_BUCKET_NAME", ""),
+			Region:          region,
+			Endpoint:        endpoint,
+			ForcePathStyle:  r.boolean("AWS_S3_FORCE_PATH_STYLE", endpoint != ""),
+		},
+	}
+
+	cfg.Startup = StartupConfig{
+		LazyDependencies:     r.list("LAZY_DEPENDENCIES"),
+		RequiredDependencies: r.list("REQUIRED_DEPENDENCIES"),
+		InitTimeouts:         map[string]time.Duration{},
+	}
+	for _, key := range r.keysWithSuffix("_INIT_TIMEOUT") {
+		switch timeout := r.duration(key, -1); {
+		case timeout > 0:
+			cfg.Startup.InitTimeouts[strings.ToLower(strings.TrimSuffix(key, "_INIT_TIMEOUT"))] = timeout
+		case timeout == 0:
+			r.problems = append(r.problems, key+" must be a positive duration")
+		}
+	}
+
+	cfg.Media = MediaConfig{
+		UploadChunkBytes:   int64(r.integer("UPLOAD_CHUNK_BYTES", int(Media.UploadChunkBytes), MinUploadChunkBytes)),
+		UploadSessionTTL:   r.duration("UPLOAD_SESSION_TTL", Media.UploadSessionTTL),
+		DownloadURLTTL:     r.duration("FILE_DOWNLOAD_URL_TTL", Media.DownloadURLTTL),
+		PublicAssetBaseURL: r.str("PUBLIC_ASSET_BASE_URL", cfg.Storage.PublicURL),
+
+		Thumbnails:     r.boolean("THUMBNAILS_ENABLED", Media.Thumbnails),
+		ImageNormalize: r.boolean("MEDIA_IMAGE_NORMALIZE", Media.ImageNormalize),
+		ImageMaxSide:   r.integer("MEDIA_IMAGE_MAX_SIDE", Media.ImageMaxSide, 0),
+		HEICConverter:  r.str("MEDIA_HEIC_CONVERTER", ""),
+		Transcoder:     r.str("MEDIA_TRANSCODER", ""),
+
+		ImageHasher:       strings.ToLower(r.str("MEDIA_IMAGE_HASHER", Media.ImageHasher)),
+		DuplicateDistance: r.integer("MEDIA_DUPLICATE_DISTANCE", Media.DuplicateDistance, 0),
+		Scanner:           strings.ToLower(r.str("MEDIA_SCANNER", "")),
+		ClamAVAddress:     r.str("CLAMAV_ADDRESS", ""),
+		OCRProvider:       strings.ToLower(r.str("OCR_PROVIDER", "")),
+		TesseractPath:     r.str("TESSERACT_PATH", ""),
+		OCRLanguages:      r.str("OCR_LANGUAGES", ""),
+
+		ManifestBucket:        r.str("MEDIA_MANIFEST_BUCKET", ""),
+		ManifestSigningKey:    r.str("MEDIA_MANIFEST_SIGNING_KEY", ""),
+		ManifestEncryptionKey: r.str("MEDIA_MANIFEST_ENCRYPTION_KEY", ""),
+		ManifestInterval:      r.duration("MEDIA_MANIFEST_INTERVAL", Media.ManifestInterval),
+	}
+
+	cfg.Jobs = JobsConfig{
+		Workers:                 r.integer("JOB_WORKERS", Jobs.Workers, 0),
+		RetentionDays:           r.integer("JOB_RETENTION_DAYS", Jobs.RetentionDays, 1),
+		WarmUpOnStartup:         r.boolean("WARMUP_ON_STARTUP", Jobs.WarmUpOnStartup),
+		BusinessMetricsInterval: r.duration("BUSINESS_METRICS_INTERVAL", Jobs.BusinessMetricsInterval),
+		StorageUsageInterval:    r.duration("STORAGE_USAGE_INTERVAL", Jobs.StorageUsageInterval),
+		ApprovalDigestHour:      r.hour("APPROVAL_DIGEST_HOUR", Jobs.ApprovalDigestHour),
+		StatsSnapshotHour:       r.hour("STATS_SNAPSHOT_HOUR", Jobs.StatsSnapshotHour),
+	}
+
+	cfg.Reporting = ReportingConfig{
+		DraftRetentionDays:      r.integer("EVENT_DRAFT_RETENTION_DAYS", Reporting.DraftRetentionDays, 1),
+		ReportDueDays:           r.integer("EVENT_REPORT_DUE_DAYS", Reporting.ReportDueDays, 1),
+		ReceiptOrganizationName: r.str("RECEIPT_ORGANIZATION_NAME", Reporting.ReceiptOrganizationName),
+	}
+
+	cfg.Geocoding = GeocodingConfig{
+		Provider:     strings.ToLower(r.str("GEOCODER", "")),
+		NominatimURL: r.str("NOMINATIM_URL", ""),
+		UserAgent:    r.str("GEOCODER_USER_AGENT", Geocoding.UserAgent),
+		CountryCodes: r.str("GEOCODER_COUNTRY_CODES", ""),
+	}
+
+	return cfg, r.problems, nil
+}
+
+// Validate checks the settings the API cannot start without and reports all problems at once
+func (c *Config) Validate() error {
+	var list []string
+	switch c.Server.GinMode {
+	case "", "debug", "release", "test":
+	default:
+		list = append(list, fmt.Sprintf("GIN_MODE must be debug, release or test, got %q", c.Server.GinMode))
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		list = append(list, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "console" {
+		list = append(list, fmt.Sprintf("LOG_FORMAT must be json or console, got %q", c.Log.Format))
+	}
+	if !c.Server.Debug() && len(c.Server.AllowedOrigins) == 0 {
+		list = append(list, "ALLOWED_ORIGINS is required outside debug mode (GIN_MODE="+c.Server.GinMode+")")
+	}
+	list = append(list, c.Database.validate()...)
+	if c.Auth.JWTSecret == "" {
+		list = append(list, "JWT_SECRET is required")
+	}
+	if c.Auth.TokenPepper == "" {
+		list = append(list, "TOKEN_PEPPER is required")
+	}
+	list = append(list, c.Media.validate()...)
+	if c.Geocoding.Provider != "" && c.Geocoding.Provider != "nominatim" {
+		list = append(list, fmt.Sprintf("unknown GEOCODER %q (use nominatim, or leave it empty)", c.Geocoding.Provider))
+	}
+	return problems(list)
+}
+
+// Validate is called before connecting to the database
+func (d DatabaseConfig) Validate() error {
+	return problems(d.validate())
+}
+
+func (d DatabaseConfig) validate() []string {
+	var missing []string
+	switch d.SchemaCheck {
+	case "enforce", "read-only", "warn", "off":
+	default:
+		missing = append(missing, fmt.Sprintf("SCHEMA_CHECK must be enforce, read-only, warn or off, got %q", d.SchemaCheck))
+	}
+	if d.URL != "" || d.hasComponents() {
+		return missing
+	}
+	for _, v := range []struct{ name, value string }{
+		{"POSTGRES_HOST", d.Host}, {"POSTGRES_USER", d.User}, {"POSTGRES_PASSWORD", d.Password}, {"POSTGRES_DB", d.Name},
+	} {
+		if v.value == "" {
+			missing = append(missing, v.name+" is required (or DATABASE_URL)")
+		}
+	}
+	return missing
+}
+
+// Validate checks the settings of the selected mail driver
+func (m MailConfig) Validate() error {
+	var list []string
+	switch m.Driver {
+	case "log":
+		return nil
+	case "smtp":
+		if m.SMTPHost == "" {
+			list = append(list, "SMTP_HOST is required when MAIL_DRIVER=smtp")
+		}
+	case "ses":
+		if m.SESRegion == "" {
+			list = append(list, "SES_REGION or AWS_REGION is required when MAIL_DRIVER=ses")
+		}
+	default:
+		return problems([]string{fmt.Sprintf("unknown MAIL_DRIVER %q (use log, smtp or ses)", m.Driver)})
+	}
+	if m.From == "" {
+		list = append(list, "MAIL_FROM is required when MAIL_DRIVER="+m.Driver)
+	}
+	return problems(list)
+}
+
+// Validate checks the settings of the selected SMS and WhatsApp drivers
+func (m MessagingConfig) Validate() error {
+	var list []string
+	required := func(driver string, settings ...[2]string) {
+		for _, setting := range settings {
+			if setting[1] == "" {
+				list = append(list, setting[0]+" is required when "+driver)
+			}
+		}
+	}
+	switch m.SMSDriver {
+	case "log":
+	case "twilio":
+		required("SMS_DRIVER=twilio", [2]string{"TWILIO_ACCOUNT_SID", m.TwilioAccountSID},
+			[2]string{"TWILIO_AUTH_TOKEN", m.TwilioAuthToken}, [2]string{"TWILIO_SMS_FROM", m.TwilioSMSFrom})
+	case "msg91":
+		required("SMS_DRIVER=msg91", [2]string{"MSG91_AUTH_KEY", m.MSG91AuthKey})
+	default:
+		list = append(list, fmt.Sprintf("unknown SMS_DRIVER %q (use log, twilio or msg91)", m.SMSDriver))
+	}
+	switch m.WhatsAppDriver {
+	case "log":
+	case "twilio":
+		required("WHATSAPP_DRIVER=twilio", [2]string{"TWILIO_ACCOUNT_SID", m.TwilioAccountSID},
+			[2]string{"TWILIO_AUTH_TOKEN", m.TwilioAuthToken}, [2]string{"TWILIO_WHATSAPP_FROM", m.TwilioWhatsAppFrom})
+	case "meta":
+		required("WHATSAPP_DRIVER=meta", [2]string{"WHATSAPP_PHONE_NUMBER_ID", m.WhatsAppPhoneNumberID},
+			[2]string{"WHATSAPP_ACCESS_TOKEN", m.WhatsAppAccessToken})
+	default:
+		list = append(list, fmt.Sprintf("unknown WHATSAPP_DRIVER %q (use log, twilio or meta)", m.WhatsAppDriver))
+	}
+	if m.CountryCode != "" && strings.Trim(m.CountryCode, "0123456789") != "" {
+		list = append(list, fmt.Sprintf("MESSAGING_COUNTRY_CODE must be digits, got %q", m.CountryCode))
+	}
+	return problems(list)
+}
+
+func (m MediaConfig) validate() []string {
+	var list []string
+	if m.ImageHasher != "phash" && m.ImageHasher != "off" {
+		list = append(list, fmt.Sprintf("MEDIA_IMAGE_HASHER must be phash or off, got %q", m.ImageHasher))
+	}
+	if m.DuplicateDistance > 64 {
+		list = append(list, fmt.Sprintf("MEDIA_DUPLICATE_DISTANCE must be between 0 and 64, got %d", m.DuplicateDistance))
+	}
+	if m.Scanner != "" && m.Scanner != "clamav" {
+		list = append(list, fmt.Sprintf("unknown MEDIA_SCANNER %q (use clamav, or leave it empty)", m.Scanner))
+	}
+	if m.OCRProvider != "" && m.OCRProvider != "tesseract" {
+		list = append(list, fmt.Sprintf("unknown OCR_PROVIDER %q (use tesseract, or leave it empty)", m.OCRProvider))
+	}
+	if m.UploadSessionTTL <= 0 || m.DownloadURLTTL <= 0 {
+		list = append(list, "UPLOAD_SESSION_TTL and FILE_DOWNLOAD_URL_TTL must be positive durations")
+	}
+	return list
+}
+
+// Validate checks the settings of the selected storage driver
+func (s StorageConfig) Validate() error {
+	switch s.Driver {
+	case "s3", "minio":
+		var list []string
+		for _, v := range []struct{ name, value string }{
+			{"AWS_ACCESS_KEY_ID", s.S3.AccessKeyID},
+			{"AWS_SECRET_ACCESS_KEY", s.S3.SecretAccessKey},
+			{"AWS_S3_BUCKET_NAME", s.S3.Bucket},
+			{"AWS_REGION", s.S3.Region},
+		} {
+			if v.value == "" {
+				list = append(list, v.name+" is required when STORAGE_DRIVER="+s.Driver)
+			}
+		}
+		return problems(list)
+	case "local":
+		if s.SigningKey == "" {
+			return problems([]string{"STORAGE_SIGNING_KEY or JWT_SECRET is required when STORAGE_DRIVER=local"})
+		}
+	case "memory":
+	default:
+		return problems([]string{fmt.Sprintf("unknown STORAGE_DRIVER %q (expected s3, local or memory)", s.Driver)})
+	}
+	return nil
+}
+
+// reader looks settings up in the environment, then the config file, and collects values it
+// cannot parse
+type reader struct {
+	file     map[string]string
+	problems []string
+}
+
+func newReader(path string) (*reader, error) {
+	r := &reader{file: map[string]string{}}
+	if path == "" {
+		return r, nil
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CONFIG_FILE %s: %w", path, err)
+	}
+	r.file = values
+	return r, nil
+}
+
+func (r *reader) str(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	if value := r.file[key]; value != "" {
+		return value
+	}
+	return def
+}
+
+func (r *reader) integer(key string, def, min int) int {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be an integer of at least %d, got %q", key, min, value))
+		return def
+	}
+	return n
+}
+
+func (r *reader) duration(key string, def time.Duration) time.Duration {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be a duration such as 60s or 15m, got %q", key, value))
+		return def
+	}
+	return d
+}
+
+// float reads a number between min and max
+func (r *reader) float(key string, def, min, max float64) float64 {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < min || f > max {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be a number between %g and %g, got %q", key, min, max, value))
+		return def
+	}
+	return f
+}
+
+// hour reads an hour of the day (0-23), or "off" which returns -1
+func (r *reader) hour(key string, def int) int {
+	value := r.str(key, "")
+	switch value {
+	case "":
+		return def
+	case "off":
+		return -1
+	}
+	h, err := strconv.Atoi(value)
+	if err != nil || h < 0 || h > 23 {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be an hour between 0 and 23 or off, got %q", key, value))
+		return def
+	}
+	return h
+}
+
+// list reads a comma separated list of lower case names
+func (r *reader) list(key string) []string {
+	var names []string
+	for _, name := range strings.Split(r.str(key, ""), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// keysWithSuffix returns the keys ending in suffix set in the environment or the config file
+func (r *reader) keysWithSuffix(suffix string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if strings.HasSuffix(key, suffix) && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		add(key)
+	}
+	for key := range r.file {
+		add(key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *reader) boolean(key string, def bool) bool {
+	value := r.str(key, "")
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s must be true or false, got %q", key, value))
+		return def
+	}
+	return b
+}
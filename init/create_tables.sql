@@ -1,3 +1,7 @@
+-- Run by docker-compose when the database volume is created, so seed_data.sql has tables to
+-- fill. The API then brings the schema up to date with the versioned migrations in
+-- migrations/, whose baseline includes this file.
+
 CREATE TABLE roles (
     id BIGSERIAL PRIMARY KEY,
     name VARCHAR(50) UNIQUE NOT NULL,
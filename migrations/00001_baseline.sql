@@ -0,0 +1,1903 @@
+-- Baseline: the schema of init/create_tables.sql and every migration that used to be applied by
+-- hand from init/migrations, in the order they were added. Each section is idempotent, so the
+-- baseline also runs on databases created before versioned migrations, whatever subset of
+-- init/migrations they had: it adds what is missing and leaves the rest alone. The backfills
+-- in it only touch rows that still need them, or recompute derived columns.
+
+-- +goose Up
+SET LOCAL statement_timeout = 0;
+
+-- ============================================================================================
+-- init/create_tables.sql
+-- ============================================================================================
+
+CREATE TABLE IF NOT EXISTS roles (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description VARCHAR(200),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS branches (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(150) UNIQUE,
+    coordinator_name VARCHAR(150),
+    contact_number VARCHAR(15) UNIQUE NOT NULL,
+    established_on DATE,
+    aashram_area NUMERIC,
+    country VARCHAR(100),
+    state VARCHAR(100),
+    district VARCHAR(100),
+    city VARCHAR(100),
+    address TEXT,
+    pincode VARCHAR(10),
+    post_office VARCHAR(100),
+    police_station VARCHAR(100),
+    open_days VARCHAR(100),         -- e.g., 'Mon-Sun' or 'Mon-Fri'
+    daily_start_time TIME,
+    daily_end_time TIME,
+    parent_branch_id BIGINT NULL,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_branches_parent') THEN
+        ALTER TABLE branches
+            ADD CONSTRAINT fk_branches_parent
+            FOREIGN KEY (parent_branch_id) REFERENCES branches(id)
+            ON DELETE SET NULL ON UPDATE CASCADE;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+CREATE INDEX IF NOT EXISTS idx_branches_parent ON branches(parent_branch_id);
+
+CREATE TABLE IF NOT EXISTS areas (
+    id BIGSERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,  -- FK to branches table
+    district_id UUID NOT NULL,
+    district_coverage FLOAT,
+    area_name VARCHAR(100),
+    area_coverage FLOAT,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(150) NOT NULL,
+    email VARCHAR(150) NOT NULL UNIQUE,
+    contact_number VARCHAR(20),
+    password TEXT NOT NULL,
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE RESTRICT,
+    token TEXT,
+    expired_on TIMESTAMPTZ,
+    last_login_on TIMESTAMPTZ,
+    first_login_on TIMESTAMPTZ,
+    is_deleted BOOLEAN DEFAULT FALSE,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS countries (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS states (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    country_id BIGINT NOT NULL REFERENCES countries(id) ON DELETE RESTRICT,
+    UNIQUE (name, country_id)
+);
+
+-- Create MediaCoverageType table
+CREATE TABLE IF NOT EXISTS media_coverage_type (
+    id SERIAL PRIMARY KEY,
+    media_type VARCHAR(50) NOT NULL
+);
+
+-- Create PromotionMaterial table
+CREATE TABLE IF NOT EXISTS promotion_material_type (
+    id SERIAL PRIMARY KEY,
+    material_type VARCHAR(50) NOT NULL
+);
+
+-- -- Need to delete later
+-- CREATE TABLE IF NOT EXISTS event_details (
+--     id SERIAL PRIMARY KEY,
+--     event_name VARCHAR(100) NOT NULL
+-- );
+
+CREATE TABLE IF NOT EXISTS event_types (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS event_categories (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(150) NOT NULL,
+    event_type_id BIGINT NOT NULL REFERENCES event_types(id)
+);
+
+CREATE TABLE IF NOT EXISTS event_details (
+    id BIGSERIAL PRIMARY KEY,
+
+    event_type_id BIGINT REFERENCES event_types(id),
+    event_category_id BIGINT REFERENCES event_categories(id),
+
+    scale VARCHAR(50),
+    theme TEXT,
+
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    daily_start_time TIME,
+    daily_end_time TIME,
+
+    spiritual_orator VARCHAR(200),
+
+    country VARCHAR(100),
+    state VARCHAR(100),
+    city VARCHAR(100),
+    district VARCHAR(100),
+    post_office VARCHAR(100),
+    pincode VARCHAR(20),
+    address TEXT,
+
+    beneficiary_men INT DEFAULT 0,
+    beneficiary_women INT DEFAULT 0,
+    beneficiary_child INT DEFAULT 0,
+
+    initiation_men INT DEFAULT 0,
+    initiation_women INT DEFAULT 0,
+    initiation_child INT DEFAULT 0,
+
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(100),
+    updated_by VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS event_media (
+    id SERIAL PRIMARY KEY,
+    media_coverage_type_id INT NOT NULL REFERENCES media_coverage_type(id),
+    event_id INT REFERENCES event_details(id),
+    company_name VARCHAR(100) NOT NULL,
+    company_email VARCHAR(100),
+    company_website VARCHAR(150),
+    gender VARCHAR(10),
+    prefix VARCHAR(10),
+    first_name VARCHAR(50) NOT NULL,
+    middle_name VARCHAR(50),
+    last_name VARCHAR(50) NOT NULL,
+    designation VARCHAR(100),
+    contact VARCHAR(20),
+    email VARCHAR(100),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS promotion_material_details (
+    id SERIAL PRIMARY KEY,
+    promotion_material_id INT NOT NULL REFERENCES promotion_material_type(id),
+    event_id INT REFERENCES event_details(id),
+    quantity INT NOT NULL,
+    size VARCHAR(50),
+    dimension_height NUMERIC(10,2),
+    dimension_width NUMERIC(10,2),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS branch_infrastructure (
+    id BIGSERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    type VARCHAR(100) NOT NULL,
+    count INT NOT NULL,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS branch_member (
+    id BIGSERIAL PRIMARY KEY,
+    member_type VARCHAR(100) NOT NULL,
+    name VARCHAR(150) NOT NULL,
+    branch_role VARCHAR(100),
+    responsibility TEXT,
+    age INT,
+    date_of_samarpan DATE,
+    qualification VARCHAR(150),
+    date_of_birth DATE,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(30),
+    updated_by VARCHAR(30)
+);
+
+CREATE TABLE IF NOT EXISTS branch_media (
+    id BIGSERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    is_child_branch BOOLEAN DEFAULT FALSE,
+    file_url TEXT,
+    file_type VARCHAR(50),
+    name VARCHAR(255),
+    category VARCHAR(100),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(100),
+    updated_by VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS special_guests (
+    id BIGSERIAL PRIMARY KEY,
+    gender VARCHAR(20),
+    prefix VARCHAR(10) NOT NULL,
+    first_name VARCHAR(100),
+    middle_name VARCHAR(100),
+    last_name VARCHAR(100),
+    event_id INT REFERENCES event_details(id),
+    designation VARCHAR(150),
+    organization VARCHAR(200),
+    email VARCHAR(150) UNIQUE,
+    city VARCHAR(100),
+    state VARCHAR(100),
+    personal_number VARCHAR(20),
+    contact_person VARCHAR(20),
+    contact_person_number VARCHAR(20),
+    reference_branch_id VARCHAR(100),
+    reference_volunteer_id VARCHAR(100),
+    reference_person_name VARCHAR(150),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(100),
+    updated_by VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS volunteers (
+    id BIGSERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    volunteer_name VARCHAR(150) NOT NULL,
+    number_of_days INTEGER,
+    seva_involved VARCHAR(100),
+    mention_seva VARCHAR(200),
+    event_id INT REFERENCES event_details(id),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(100),
+    updated_by VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS donations (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL,
+    branch_id INTEGER NOT NULL,
+    donation_type VARCHAR(255),
+    amount DOUBLE PRECISION,
+    kind_type VARCHAR(255),
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ,
+    created_by VARCHAR(100),
+    updated_by VARCHAR(100)
+);
+
+CREATE TABLE IF NOT EXISTS districts (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    state_id INTEGER NOT NULL REFERENCES states(id),
+    country_id INTEGER NOT NULL REFERENCES countries(id)
+);
+
+CREATE TABLE IF NOT EXISTS cities (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    state_id INTEGER NOT NULL REFERENCES states(id)
+);
+
+-- ============================================================================================
+-- init/migrations/001_create_auth_tables.sql
+-- ============================================================================================
+
+-- Migration: Create authentication tables
+-- Created for secure auth system with refresh tokens, email verification, and audit logging
+
+-- Users table (extended for new auth system)
+-- Note: This migration assumes users table exists. If it needs to be modified, do it separately.
+-- We'll add new columns if needed, or create a new auth_users table if separation is desired.
+-- For now, we assume users table exists and will add auth-specific columns.
+
+-- Add auth-specific columns to users table if they don't exist
+-- +goose StatementBegin
+DO $$ 
+BEGIN
+    -- Add email_verified_at if it doesn't exist
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name='users' AND column_name='email_verified_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ NULL;
+    END IF;
+
+    -- Add disabled_at if it doesn't exist
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name='users' AND column_name='disabled_at'
+    ) THEN
+        ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ NULL;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- Create sessions table for refresh token management
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY, -- UUID as text (matches uuid.New().String() format)
+    user_id BIGINT NOT NULL,
+    refresh_token_hash BYTEA NOT NULL,
+    user_agent TEXT,
+    ip TEXT, -- Store as TEXT for flexibility (handles IPv4, IPv6, and proxy headers)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    
+    -- Foreign key - matches users.id (BIGSERIAL/BIGINT)
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create indexes for sessions
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_refresh_token_hash ON sessions(refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id_revoked ON sessions(user_id, revoked_at) WHERE revoked_at IS NULL;
+
+-- Create verification_tokens table for email verification
+CREATE TABLE IF NOT EXISTS verification_tokens (
+    id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::TEXT, -- UUID as text (database generates)
+    user_id BIGINT NOT NULL,
+    token_hash BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    
+    CONSTRAINT fk_verification_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_token_hash ON verification_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON verification_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires_at ON verification_tokens(expires_at);
+
+-- Create password_reset_tokens table
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::TEXT, -- UUID as text (database generates)
+    user_id BIGINT NOT NULL,
+    token_hash BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+
+-- Create auth_audit_events table for security auditing
+CREATE TABLE IF NOT EXISTS auth_audit_events (
+    id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::TEXT, -- UUID as text (database generates)
+    user_id BIGINT NULL,
+    type TEXT NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    
+    CONSTRAINT fk_auth_audit_events_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_audit_events_user_id ON auth_audit_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_events_created_at ON auth_audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_events_type ON auth_audit_events(type);
+CREATE INDEX IF NOT EXISTS idx_auth_audit_events_user_id_created_at ON auth_audit_events(user_id, created_at);
+
+-- Note: user_id columns use BIGINT to match users.id (BIGSERIAL)
+
+-- ============================================================================================
+-- init/migrations/add_event_status.sql
+-- ============================================================================================
+
+-- Add status column to event_details table
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'incomplete' CHECK (status IN ('complete', 'incomplete'));
+
+-- Update existing events to have 'incomplete' status if they don't have one
+UPDATE event_details
+SET status = 'incomplete'
+WHERE status IS NULL;
+
+-- Add index on status for faster filtering
+CREATE INDEX IF NOT EXISTS idx_event_details_status ON event_details(status);
+
+-- ============================================================================================
+-- init/migrations/create_event_drafts_table.sql
+-- ============================================================================================
+
+-- Migration: Create event_drafts table for storing draft data
+-- Date: 2024
+-- Description: Separate table for event draft data that will be deleted after submission
+
+-- Create event_drafts table
+CREATE TABLE IF NOT EXISTS event_drafts (
+    id BIGSERIAL PRIMARY KEY,
+
+    -- Draft data for each step (JSONB)
+    general_details_draft JSONB DEFAULT '{}'::jsonb,
+    media_promotion_draft JSONB DEFAULT '{}'::jsonb,
+    special_guests_draft JSONB DEFAULT '{}'::jsonb,
+    volunteers_draft JSONB DEFAULT '{}'::jsonb,
+
+    -- Optional: Link to event if draft is associated with an existing event
+    event_id BIGINT REFERENCES event_details(id) ON DELETE CASCADE,
+
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ
+);
+
+-- Create indexes on JSONB fields for better query performance
+CREATE INDEX IF NOT EXISTS idx_event_drafts_general_details_draft ON event_drafts USING GIN (general_details_draft);
+CREATE INDEX IF NOT EXISTS idx_event_drafts_media_promotion_draft ON event_drafts USING GIN (media_promotion_draft);
+CREATE INDEX IF NOT EXISTS idx_event_drafts_special_guests_draft ON event_drafts USING GIN (special_guests_draft);
+CREATE INDEX IF NOT EXISTS idx_event_drafts_volunteers_draft ON event_drafts USING GIN (volunteers_draft);
+
+-- Create index on event_id for faster lookups
+CREATE INDEX IF NOT EXISTS idx_event_drafts_event_id ON event_drafts(event_id);
+
+-- Create index on created_on for cleanup of old drafts
+CREATE INDEX IF NOT EXISTS idx_event_drafts_created_on ON event_drafts(created_on);
+
+-- ============================================================================================
+-- init/migrations/add_draft_fields.sql
+-- ============================================================================================
+
+-- Migration: Add draft JSONB fields to event_details table
+-- Date: 2024
+-- Description: Adds JSONB fields for auto-saving draft data for each step
+
+-- Add JSONB columns for draft data
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS general_details_draft JSONB DEFAULT '{}'::jsonb,
+ADD COLUMN IF NOT EXISTS media_promotion_draft JSONB DEFAULT '{}'::jsonb,
+ADD COLUMN IF NOT EXISTS special_guests_draft JSONB DEFAULT '{}'::jsonb,
+ADD COLUMN IF NOT EXISTS volunteers_draft JSONB DEFAULT '{}'::jsonb;
+
+-- Create indexes on JSONB fields for better query performance (optional)
+CREATE INDEX IF NOT EXISTS idx_event_details_general_details_draft ON event_details USING GIN (general_details_draft);
+CREATE INDEX IF NOT EXISTS idx_event_details_media_promotion_draft ON event_details USING GIN (media_promotion_draft);
+CREATE INDEX IF NOT EXISTS idx_event_details_special_guests_draft ON event_details USING GIN (special_guests_draft);
+CREATE INDEX IF NOT EXISTS idx_event_details_volunteers_draft ON event_details USING GIN (volunteers_draft);
+
+-- ============================================================================================
+-- init/migrations/remove_draft_fields_from_event_details.sql
+-- ============================================================================================
+
+-- Migration: Remove draft JSONB fields from event_details table
+-- Date: 2024
+-- Description: Remove draft fields since we're now using separate event_drafts table
+
+-- Drop indexes if they exist
+DROP INDEX IF EXISTS idx_event_details_general_details_draft;
+DROP INDEX IF EXISTS idx_event_details_media_promotion_draft;
+DROP INDEX IF EXISTS idx_event_details_special_guests_draft;
+DROP INDEX IF EXISTS idx_event_details_volunteers_draft;
+
+-- Remove JSONB columns from event_details table
+ALTER TABLE event_details
+DROP COLUMN IF EXISTS general_details_draft,
+DROP COLUMN IF EXISTS media_promotion_draft,
+DROP COLUMN IF EXISTS special_guests_draft,
+DROP COLUMN IF EXISTS volunteers_draft;
+
+-- ============================================================================================
+-- init/migrations/add_ocr_text_columns.sql
+-- ============================================================================================
+
+-- Searchable donation remarks and OCR text for receipts / press clippings
+
+ALTER TABLE donations
+ADD COLUMN IF NOT EXISTS remarks TEXT,
+ADD COLUMN IF NOT EXISTS receipt_s3_key TEXT,
+ADD COLUMN IF NOT EXISTS ocr_text TEXT;
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS ocr_text TEXT;
+
+-- Full-text indexes (expressions must match the queries in donation_service.go / media_service.go)
+CREATE INDEX IF NOT EXISTS idx_donations_search_fts
+ON donations USING GIN (to_tsvector('simple', coalesce(remarks, '') || ' ' || coalesce(ocr_text, '')));
+
+CREATE INDEX IF NOT EXISTS idx_event_media_ocr_fts
+ON event_media USING GIN (to_tsvector('simple', coalesce(ocr_text, '')));
+
+-- ============================================================================================
+-- init/migrations/add_event_approval_workflow.sql
+-- ============================================================================================
+
+-- Event approval workflow: draft → submitted → under_review → approved/rejected → published
+-- The existing status column (complete/incomplete) keeps tracking form completeness.
+
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS approval_status VARCHAR(20) DEFAULT 'draft'
+CHECK (approval_status IN ('draft', 'submitted', 'under_review', 'approved', 'rejected', 'published'));
+
+UPDATE event_details
+SET approval_status = 'draft'
+WHERE approval_status IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_event_details_approval_status ON event_details(approval_status);
+
+-- Status history with reviewer comments
+CREATE TABLE IF NOT EXISTS event_status_history (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES event_details(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    comment TEXT,
+    changed_by INTEGER,
+    role_id INTEGER,
+    created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_status_history_event_id ON event_status_history(event_id);
+
+-- ============================================================================================
+-- init/migrations/add_name_keys.sql
+-- ============================================================================================
+
+-- Transliteration-aware name matching ("Vikas" / "विकास")
+-- name_key columns hold a script-independent phonetic key computed by utils.NameKey.
+-- Existing rows are backfilled on application startup (services.BackfillNameKeys).
+
+ALTER TABLE volunteers
+ADD COLUMN IF NOT EXISTS name_key TEXT;
+
+ALTER TABLE special_guests
+ADD COLUMN IF NOT EXISTS name_key TEXT;
+
+ALTER TABLE donations
+ADD COLUMN IF NOT EXISTS donor_name VARCHAR(255),
+ADD COLUMN IF NOT EXISTS donor_name_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_volunteers_name_key ON volunteers(name_key);
+CREATE INDEX IF NOT EXISTS idx_special_guests_name_key ON special_guests(name_key);
+CREATE INDEX IF NOT EXISTS idx_donations_donor_name_key ON donations(donor_name_key);
+
+-- ============================================================================================
+-- init/migrations/create_audit_logs_table.sql
+-- ============================================================================================
+
+-- Audit log for create/update/delete operations on domain entities
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    changes JSONB,
+    actor_id INTEGER,
+    actor_role_id INTEGER,
+    ip TEXT,
+    method VARCHAR(10),
+    path TEXT,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_on ON audit_logs(created_on);
+
+-- ============================================================================================
+-- init/migrations/create_sequence_counters.sql
+-- ============================================================================================
+
+-- Gap-free sequence counters per scope and financial year (see app/services/sequence)
+CREATE TABLE IF NOT EXISTS sequence_counters (
+    scope VARCHAR(100) NOT NULL,
+    financial_year VARCHAR(7) NOT NULL, -- e.g. 2025-26
+    last_value BIGINT NOT NULL DEFAULT 0,
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, financial_year)
+);
+
+-- Numbers allocated from the counters
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS receipt_number VARCHAR(50);
+ALTER TABLE event_details ADD COLUMN IF NOT EXISTS report_number VARCHAR(50);
+ALTER TABLE branch_member ADD COLUMN IF NOT EXISTS membership_id VARCHAR(50);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_donations_receipt_number ON donations(receipt_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_details_report_number ON event_details(report_number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_branch_member_membership_id ON branch_member(membership_id);
+
+-- ============================================================================================
+-- init/migrations/add_soft_delete_columns.sql
+-- ============================================================================================
+
+-- Soft delete for domain records
+-- Rows with deleted_at set are hidden from all GORM queries; admins can list them with
+-- ?include_deleted=true and bring them back via POST .../restore.
+
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE volunteers
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE donations
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_branches_deleted_at ON branches(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_event_details_deleted_at ON event_details(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_volunteers_deleted_at ON volunteers(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_donations_deleted_at ON donations(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_branch_media_deleted_at ON branch_media(deleted_at);
+
+-- ============================================================================================
+-- init/migrations/add_denormalized_counters.sql
+-- ============================================================================================
+
+-- Denormalized attachment counters and last-activity timestamps
+-- List endpoints read these columns instead of loading relations just to show badges.
+-- The counters are maintained by triggers on the child tables and recomputed with COUNT(*)
+-- on every change, so soft deletes, restores and re-parenting stay consistent.
+-- Depends on add_soft_delete_columns.sql (deleted_at columns).
+
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS media_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS member_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS event_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_activity_on TIMESTAMPTZ;
+
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS media_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS special_guest_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS volunteer_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS donation_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS promotion_material_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_activity_on TIMESTAMPTZ;
+
+-- Recompute counters for a single branch
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION refresh_branch_counters(p_branch_id BIGINT) RETURNS VOID AS $$
+BEGIN
+    IF p_branch_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    UPDATE branches SET
+        media_count = (SELECT COUNT(*) FROM branch_media WHERE branch_id = p_branch_id AND deleted_at IS NULL),
+        member_count = (SELECT COUNT(*) FROM branch_member WHERE branch_id = p_branch_id),
+        event_count = (SELECT COUNT(*) FROM event_details WHERE branch_id = p_branch_id AND deleted_at IS NULL),
+        last_activity_on = NOW()
+    WHERE id = p_branch_id;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Recompute counters for a single event
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION refresh_event_counters(p_event_id BIGINT) RETURNS VOID AS $$
+BEGIN
+    IF p_event_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    UPDATE event_details SET
+        media_count = (SELECT COUNT(*) FROM event_media WHERE event_id = p_event_id),
+        special_guest_count = (SELECT COUNT(*) FROM special_guests WHERE event_id = p_event_id),
+        volunteer_count = (SELECT COUNT(*) FROM volunteers WHERE event_id = p_event_id AND deleted_at IS NULL),
+        donation_count = (SELECT COUNT(*) FROM donations WHERE event_id = p_event_id AND deleted_at IS NULL),
+        promotion_material_count = (SELECT COUNT(*) FROM promotion_material_details WHERE event_id = p_event_id),
+        last_activity_on = NOW()
+    WHERE id = p_event_id;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Trigger functions: refresh the new parent on insert/update and the old parent on delete/re-parent
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION trg_refresh_branch_counters() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM refresh_branch_counters(NEW.branch_id);
+    END IF;
+    IF TG_OP = 'DELETE' OR (TG_OP = 'UPDATE' AND OLD.branch_id IS DISTINCT FROM NEW.branch_id) THEN
+        PERFORM refresh_branch_counters(OLD.branch_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION trg_refresh_event_counters() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM refresh_event_counters(NEW.event_id);
+    END IF;
+    IF TG_OP = 'DELETE' OR (TG_OP = 'UPDATE' AND OLD.event_id IS DISTINCT FROM NEW.event_id) THEN
+        PERFORM refresh_event_counters(OLD.event_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS branch_media_counters ON branch_media;
+CREATE TRIGGER branch_media_counters
+AFTER INSERT OR DELETE OR UPDATE OF branch_id, deleted_at ON branch_media
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_branch_counters();
+
+DROP TRIGGER IF EXISTS branch_member_counters ON branch_member;
+CREATE TRIGGER branch_member_counters
+AFTER INSERT OR DELETE OR UPDATE OF branch_id ON branch_member
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_branch_counters();
+
+-- Only branch changes and soft deletes matter here; counter updates on event_details must not recurse
+DROP TRIGGER IF EXISTS event_details_branch_counters ON event_details;
+CREATE TRIGGER event_details_branch_counters
+AFTER INSERT OR DELETE OR UPDATE OF branch_id, deleted_at ON event_details
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_branch_counters();
+
+DROP TRIGGER IF EXISTS event_media_counters ON event_media;
+CREATE TRIGGER event_media_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id ON event_media
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+DROP TRIGGER IF EXISTS special_guests_counters ON special_guests;
+CREATE TRIGGER special_guests_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id ON special_guests
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+DROP TRIGGER IF EXISTS volunteers_counters ON volunteers;
+CREATE TRIGGER volunteers_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id, deleted_at ON volunteers
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+DROP TRIGGER IF EXISTS donations_counters ON donations;
+CREATE TRIGGER donations_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id, deleted_at ON donations
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+DROP TRIGGER IF EXISTS promotion_material_details_counters ON promotion_material_details;
+CREATE TRIGGER promotion_material_details_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id ON promotion_material_details
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+-- Backfill existing rows
+SELECT refresh_branch_counters(id) FROM branches;
+SELECT refresh_event_counters(id) FROM event_details;
+
+-- Backfill stamps NOW(); use the row's own timestamps instead
+UPDATE branches SET last_activity_on = COALESCE(updated_on, created_on);
+UPDATE event_details SET last_activity_on = COALESCE(updated_on, created_on);
+
+-- ============================================================================================
+-- init/migrations/add_media_thumbnails.sql
+-- ============================================================================================
+
+-- Server-side thumbnails for uploaded images
+-- Small (320px) and medium (1024px) JPEG renditions are stored under the thumbnails/ S3 prefix.
+-- Gallery list endpoints return presigned thumbnail URLs instead of the originals.
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS thumbnail_s3_key VARCHAR(500),
+ADD COLUMN IF NOT EXISTS thumbnail_medium_s3_key VARCHAR(500);
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS thumbnail_s3_key VARCHAR(500),
+ADD COLUMN IF NOT EXISTS thumbnail_medium_s3_key VARCHAR(500);
+
+-- ============================================================================================
+-- init/migrations/create_feature_flags_table.sql
+-- ============================================================================================
+
+-- Runtime feature flags (also drive expand/contract schema migration phases)
+CREATE TABLE IF NOT EXISTS feature_flags (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(150) NOT NULL UNIQUE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    description TEXT,
+    updated_by INTEGER,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ============================================================================================
+-- init/migrations/create_media_manifests_table.sql
+-- ============================================================================================
+
+-- Signed manifests of uploaded documents (tamper evidence for media, branch media and donation receipts)
+CREATE TABLE IF NOT EXISTS media_manifests (
+    id SERIAL PRIMARY KEY,
+    bucket VARCHAR(255) NOT NULL,
+    s3_key TEXT NOT NULL,
+    entry_count INTEGER NOT NULL,
+    missing_count INTEGER NOT NULL DEFAULT 0,
+    payload_sha256 CHAR(64) NOT NULL,
+    previous_sha256 CHAR(64),
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('scheduled', 'manual')),
+    created_by INTEGER,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_manifests_created_on ON media_manifests(created_on);
+
+-- ============================================================================================
+-- init/migrations/add_password_policy.sql
+-- ============================================================================================
+
+-- Password policy: forced rotation and password history
+-- must_change_password is set for temporary passwords (admin created or reset accounts) and
+-- enforced by AuthMiddleware; password_changed_at drives expiry (PASSWORD_MAX_AGE_DAYS).
+-- password_history keeps recent hashes so they cannot be reused (PASSWORD_HISTORY).
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ;
+
+-- Existing passwords start their expiry clock now instead of expiring immediately
+UPDATE users SET password_changed_at = NOW() WHERE password_changed_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, created_at DESC);
+
+-- ============================================================================================
+-- init/migrations/create_persons_table.sql
+-- ============================================================================================
+
+-- Unified people model
+-- users, branch members, volunteers and donors reference the individual behind them through
+-- person_id. New rows are linked by model hooks (matching email, contact number or name with
+-- date of birth); existing rows are linked by the startup backfill (services.BackfillPersons).
+-- Duplicates found later are merged via POST /api/persons/:id/merge, which sets merged_into_id.
+
+CREATE TABLE IF NOT EXISTS persons (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    name_key TEXT,
+    email VARCHAR(255),
+    contact VARCHAR(20),
+    date_of_birth DATE,
+    merged_into_id INTEGER REFERENCES persons(id) ON DELETE SET NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_persons_name_key ON persons(name_key) WHERE merged_into_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_persons_email ON persons(email) WHERE merged_into_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_persons_contact ON persons(contact) WHERE merged_into_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_persons_merged_into_id ON persons(merged_into_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS person_id INTEGER REFERENCES persons(id) ON DELETE SET NULL;
+ALTER TABLE branch_member ADD COLUMN IF NOT EXISTS person_id INTEGER REFERENCES persons(id) ON DELETE SET NULL;
+ALTER TABLE volunteers ADD COLUMN IF NOT EXISTS person_id INTEGER REFERENCES persons(id) ON DELETE SET NULL;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS person_id INTEGER REFERENCES persons(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_person_id ON users(person_id);
+CREATE INDEX IF NOT EXISTS idx_branch_member_person_id ON branch_member(person_id);
+CREATE INDEX IF NOT EXISTS idx_volunteers_person_id ON volunteers(person_id);
+CREATE INDEX IF NOT EXISTS idx_donations_person_id ON donations(person_id);
+
+-- ============================================================================================
+-- init/migrations/create_notification_logs_table.sql
+-- ============================================================================================
+
+-- Outgoing notification log (emails sent by app/services/mail)
+-- Bodies are never stored: they can contain temporary passwords and reset links.
+
+CREATE TABLE IF NOT EXISTS notification_logs (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT,
+    status VARCHAR(20) NOT NULL,
+    provider VARCHAR(20),
+    provider_message_id TEXT,
+    error TEXT,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    entity_type VARCHAR(50),
+    entity_id INTEGER,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_logs_created_on ON notification_logs(created_on DESC);
+CREATE INDEX IF NOT EXISTS idx_notification_logs_recipient ON notification_logs(recipient);
+CREATE INDEX IF NOT EXISTS idx_notification_logs_template ON notification_logs(template);
+CREATE INDEX IF NOT EXISTS idx_notification_logs_user_id ON notification_logs(user_id);
+
+-- ============================================================================================
+-- init/migrations/add_branch_images.sql
+-- ============================================================================================
+
+-- Branch cover image and coordinator photo
+-- Both point at one of the branch's own branch_media rows (image files only, enforced by the API).
+-- Branch list/detail endpoints return them presigned as cover_image / coordinator_photo.
+
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS cover_media_id BIGINT REFERENCES branch_media(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS coordinator_photo_media_id BIGINT REFERENCES branch_media(id) ON DELETE SET NULL;
+
+-- ============================================================================================
+-- init/migrations/add_password_reset_rate_limit.sql
+-- ============================================================================================
+
+-- Self-service password recovery (POST /api/forgot-password, POST /api/reset-password/:token)
+-- Reset links keep using password_reset_tokens (SHA-256 token hashes, expires_at, single use).
+-- Forgot-password counts the links issued to an account per rate limit window.
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_created
+ON password_reset_tokens(user_id, created_at);
+
+-- ============================================================================================
+-- init/migrations/add_event_media_gallery_fields.sql
+-- ============================================================================================
+
+-- Event media gallery: category, caption and ordering
+-- Managed through /api/events/:event_id/media; listed by sort_order, then id.
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS category VARCHAR(100),
+ADD COLUMN IF NOT EXISTS caption TEXT,
+ADD COLUMN IF NOT EXISTS sort_order INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_event_media_event_sort ON event_media(event_id, sort_order, id);
+
+-- ============================================================================================
+-- init/migrations/add_branch_locations.sql
+-- ============================================================================================
+
+-- Branch map locations for the public branch locator (/public/api/branches/map)
+-- public_id is the identifier exposed publicly instead of the internal branch ID.
+
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS public_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_branches_public_id ON branches(public_id);
+CREATE INDEX IF NOT EXISTS idx_branches_location ON branches(latitude, longitude)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL;
+
+-- ============================================================================================
+-- init/migrations/create_api_usage_daily_table.sql
+-- ============================================================================================
+
+-- Daily API usage rollup per user, client and route (see middleware.APIUsage)
+-- user_id 0 is used for unauthenticated requests so it can be part of the primary key.
+
+CREATE TABLE IF NOT EXISTS api_usage_daily (
+    day DATE NOT NULL,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    client VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    request_count BIGINT NOT NULL DEFAULT 0,
+    error_count BIGINT NOT NULL DEFAULT 0,
+    total_duration_ms BIGINT NOT NULL DEFAULT 0,
+    max_duration_ms BIGINT NOT NULL DEFAULT 0,
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, user_id, client, method, route)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_usage_daily_user_id ON api_usage_daily(user_id, day);
+CREATE INDEX IF NOT EXISTS idx_api_usage_daily_client ON api_usage_daily(client, day);
+
+-- ============================================================================================
+-- init/migrations/create_webhook_subscriptions_table.sql
+-- ============================================================================================
+
+-- Outgoing webhook subscriptions (see app/services/webhook_service.go)
+-- event_types, branch_ids and region_ids are JSON arrays; an empty array means "all".
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    description TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    event_types JSONB NOT NULL DEFAULT '[]',
+    branch_ids JSONB NOT NULL DEFAULT '[]',
+    region_ids JSONB NOT NULL DEFAULT '[]',
+    last_tested_on TIMESTAMPTZ,
+    last_test_status INTEGER,
+    last_test_error TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_active ON webhook_subscriptions(active);
+
+-- ============================================================================================
+-- init/migrations/create_jobs_table.sql
+-- ============================================================================================
+
+-- Background job queue (see app/services/job_service.go)
+-- Workers claim due jobs with SELECT ... FOR UPDATE SKIP LOCKED; failed jobs are retried
+-- with backoff by moving run_at until max_attempts is reached.
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    payload JSONB,
+    result JSONB,
+    error TEXT,
+    progress INTEGER NOT NULL DEFAULT 0,
+    progress_message TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_by VARCHAR(255),
+    started_on TIMESTAMPTZ,
+    finished_on TIMESTAMPTZ,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Queue polling only looks at queued jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs(run_at, id) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_jobs_created_by ON jobs(created_by, created_on DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+
+-- ============================================================================================
+-- init/migrations/add_media_scan_status.sql
+-- ============================================================================================
+
+-- Antivirus/moderation scan results for event and branch media (see app/services/media_scan_service.go)
+-- scan_status is NULL until the file has been scanned; the legacy backlog is scanned by the
+-- media_scan_backfill job (POST /api/admin/media-scan/backfill).
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS scan_status VARCHAR(20),
+ADD COLUMN IF NOT EXISTS scan_detail TEXT,
+ADD COLUMN IF NOT EXISTS scanned_on TIMESTAMPTZ;
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS scan_status VARCHAR(20),
+ADD COLUMN IF NOT EXISTS scan_detail TEXT,
+ADD COLUMN IF NOT EXISTS scanned_on TIMESTAMPTZ;
+
+-- The backfill walks unscanned rows in id order
+CREATE INDEX IF NOT EXISTS idx_event_media_unscanned ON event_media(id) WHERE scan_status IS NULL;
+CREATE INDEX IF NOT EXISTS idx_branch_media_unscanned ON branch_media(id) WHERE scan_status IS NULL;
+
+-- ============================================================================================
+-- init/migrations/create_branch_change_requests_table.sql
+-- ============================================================================================
+
+-- Branch self-service edits (see app/services/branch_change_request_service.go)
+-- Coordinators propose changes to their branch profile; regional managers or admins approve
+-- or reject them. users.branch_id is the branch a user coordinates, users.region_id the region
+-- whose requests a manager reviews.
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS branch_id BIGINT REFERENCES branches(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS region_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_users_branch_id ON users(branch_id);
+
+CREATE TABLE IF NOT EXISTS branch_change_requests (
+    id SERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    changes JSONB NOT NULL,
+    original JSONB NOT NULL,
+    comment TEXT,
+    requested_by BIGINT NOT NULL REFERENCES users(id),
+    reviewed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_on TIMESTAMPTZ,
+    review_comment TEXT,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_branch_change_requests_branch ON branch_change_requests(branch_id, created_on DESC);
+CREATE INDEX IF NOT EXISTS idx_branch_change_requests_pending ON branch_change_requests(created_on) WHERE status = 'pending';
+
+-- ============================================================================================
+-- init/migrations/add_dashboard_indexes.sql
+-- ============================================================================================
+
+-- Indexes behind the dashboard aggregations (GET /api/dashboard): date-range and branch
+-- filters on live events, and the joins from donations and event media to their event
+CREATE INDEX IF NOT EXISTS idx_event_details_branch_start_date
+    ON event_details(branch_id, start_date) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_details_start_date
+    ON event_details(start_date) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_donations_event_id ON donations(event_id);
+CREATE INDEX IF NOT EXISTS idx_event_media_event_id ON event_media(event_id);
+
+-- ============================================================================================
+-- init/migrations/create_media_tombstones_and_legal_holds.sql
+-- ============================================================================================
+
+-- Tombstones of deleted event/branch media: who deleted what, when, and the original record
+CREATE TABLE IF NOT EXISTS media_tombstones (
+    id SERIAL PRIMARY KEY,
+    media_type VARCHAR(20) NOT NULL CHECK (media_type IN ('event_media', 'branch_media')),
+    media_id INTEGER NOT NULL,
+    event_id INTEGER,
+    branch_id INTEGER,
+    s3_key TEXT,
+    original_filename TEXT,
+    file_type VARCHAR(20),
+    category VARCHAR(100),
+    metadata JSONB,
+    deleted_by INTEGER,
+    deleted_by_role_id INTEGER,
+    ip TEXT,
+    path TEXT,
+    deleted_on TIMESTAMP NOT NULL DEFAULT NOW(),
+    restored_on TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_tombstones_media ON media_tombstones(media_type, media_id);
+CREATE INDEX IF NOT EXISTS idx_media_tombstones_event_id ON media_tombstones(event_id);
+CREATE INDEX IF NOT EXISTS idx_media_tombstones_branch_id ON media_tombstones(branch_id);
+CREATE INDEX IF NOT EXISTS idx_media_tombstones_deleted_on ON media_tombstones(deleted_on);
+
+-- Legal holds block deletion of an event (with its media) or of single media records
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id SERIAL PRIMARY KEY,
+    entity_type VARCHAR(20) NOT NULL CHECK (entity_type IN ('event', 'event_media', 'branch_media')),
+    entity_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    placed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    placed_on TIMESTAMP NOT NULL DEFAULT NOW(),
+    released_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    released_on TIMESTAMP
+);
+
+-- At most one active hold per entity
+CREATE UNIQUE INDEX IF NOT EXISTS idx_legal_holds_active
+    ON legal_holds(entity_type, entity_id) WHERE released_on IS NULL;
+
+-- ============================================================================================
+-- init/migrations/add_search_indexes.sql
+-- ============================================================================================
+
+-- Full-text indexes behind GET /api/search (expressions must match the documents in
+-- app/services/search_service.go)
+CREATE INDEX IF NOT EXISTS idx_event_details_search_fts
+ON event_details USING GIN (to_tsvector('simple', coalesce(theme, '') || ' ' || coalesce(spiritual_orator, '') || ' ' || coalesce(city, '')));
+
+CREATE INDEX IF NOT EXISTS idx_branches_search_fts
+ON branches USING GIN (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(coordinator_name, '')));
+
+CREATE INDEX IF NOT EXISTS idx_special_guests_search_fts
+ON special_guests USING GIN (to_tsvector('simple', coalesce(first_name, '') || ' ' || coalesce(middle_name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(organization, '') || ' ' || coalesce(designation, '')));
+
+CREATE INDEX IF NOT EXISTS idx_volunteers_search_fts
+ON volunteers USING GIN (to_tsvector('simple', coalesce(volunteer_name, '')));
+
+-- ============================================================================================
+-- init/migrations/add_media_keyset_indexes.sql
+-- ============================================================================================
+
+-- Keyset pagination of media galleries: (created_on, id) newest first, per event/branch and
+-- across all media (GET /api/event-media, /api/branch-media with ?limit= or ?cursor=)
+CREATE INDEX IF NOT EXISTS idx_event_media_event_created
+    ON event_media(event_id, created_on DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_event_media_created
+    ON event_media(created_on DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_branch_media_branch_created
+    ON branch_media(branch_id, created_on DESC, id DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_branch_media_created
+    ON branch_media(created_on DESC, id DESC);
+
+-- ============================================================================================
+-- init/migrations/add_user_digest_preferences.sql
+-- ============================================================================================
+
+-- Reviewer email digest of pending approvals, data-quality flags and overdue reports.
+-- digest_sent_on guards against sending twice when several instances run the digest job.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS digest_frequency VARCHAR(10) NOT NULL DEFAULT 'daily'
+CHECK (digest_frequency IN ('daily', 'weekly', 'off'));
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS digest_sent_on TIMESTAMPTZ;
+
+-- ============================================================================================
+-- init/migrations/add_sandbox_branches.sql
+-- ============================================================================================
+
+-- Sandbox branches for training sessions: practice data entered under them is excluded from
+-- stats, exports and the public site, and removed by POST /admin/sandbox/wipe.
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS is_sandbox BOOLEAN NOT NULL DEFAULT false;
+
+-- The exclusion subquery (SELECT id FROM branches WHERE is_sandbox) reads only this index
+CREATE INDEX IF NOT EXISTS idx_branches_sandbox ON branches(id) WHERE is_sandbox;
+
+-- Child branches created under a sandbox branch are sandbox branches too
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION trg_inherit_branch_sandbox() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.parent_branch_id IS NOT NULL THEN
+        NEW.is_sandbox := NEW.is_sandbox OR COALESCE(
+            (SELECT is_sandbox FROM branches WHERE id = NEW.parent_branch_id), false);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS branches_inherit_sandbox ON branches;
+CREATE TRIGGER branches_inherit_sandbox
+BEFORE INSERT ON branches
+FOR EACH ROW EXECUTE FUNCTION trg_inherit_branch_sandbox();
+
+-- ============================================================================================
+-- init/migrations/add_public_assets.sql
+-- ============================================================================================
+
+-- Public event media (website posters) are served from /public/assets/<sha256> with immutable
+-- cache headers instead of short-lived presigned URLs
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS is_public BOOLEAN NOT NULL DEFAULT false;
+
+-- One row per variant (original, small, medium) of each public media item
+CREATE TABLE IF NOT EXISTS public_assets (
+    id SERIAL PRIMARY KEY,
+    sha256 CHAR(64) NOT NULL,
+    event_media_id BIGINT NOT NULL REFERENCES event_media(id) ON DELETE CASCADE,
+    variant VARCHAR(20) NOT NULL,
+    s3_key TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (event_media_id, variant)
+);
+
+CREATE INDEX IF NOT EXISTS idx_public_assets_sha256 ON public_assets(sha256);
+
+-- ============================================================================================
+-- init/migrations/add_event_draft_versions.sql
+-- ============================================================================================
+
+-- Versioned drafts: every save bumps version, and saves carrying an older version are rejected
+-- instead of overwriting changes made in another tab or device
+ALTER TABLE event_drafts
+ADD COLUMN IF NOT EXISTS user_email VARCHAR(255),
+ADD COLUMN IF NOT EXISTS donations_draft JSONB DEFAULT '{}'::jsonb,
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Drafts are always read per user: the draft list, the latest draft and a user's draft of an event
+CREATE INDEX IF NOT EXISTS idx_event_drafts_user_updated ON event_drafts(user_email, updated_on DESC);
+CREATE INDEX IF NOT EXISTS idx_event_drafts_user_event ON event_drafts(user_email, event_id) WHERE event_id IS NOT NULL;
+
+-- Stale draft cleanup (EVENT_DRAFT_RETENTION_DAYS)
+CREATE INDEX IF NOT EXISTS idx_event_drafts_last_saved ON event_drafts((COALESCE(updated_on, created_on)));
+
+-- ============================================================================================
+-- init/migrations/create_coordinator_handovers_table.sql
+-- ============================================================================================
+
+-- Coordinator handovers (POST /branches/:id/handover): who handed a branch over to whom, and
+-- what moved with it. Also read when emailing review outcomes, so the new coordinator hears
+-- about events the previous one submitted.
+CREATE TABLE IF NOT EXISTS coordinator_handovers (
+    id SERIAL PRIMARY KEY,
+    branch_id BIGINT NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    from_user_ids JSONB NOT NULL DEFAULT '[]'::jsonb,
+    to_user_id BIGINT NOT NULL REFERENCES users(id),
+    from_name VARCHAR(255),
+    to_name VARCHAR(255),
+    note TEXT,
+    child_branch_ids JSONB NOT NULL DEFAULT '[]'::jsonb,
+    change_requests INTEGER NOT NULL DEFAULT 0,
+    pending_events INTEGER NOT NULL DEFAULT 0,
+    created_by BIGINT,
+    created_on TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_coordinator_handovers_branch ON coordinator_handovers(branch_id, created_on);
+
+-- ============================================================================================
+-- init/migrations/add_financial_year_archival.sql
+-- ============================================================================================
+
+-- Archived financial years (April-March, e.g. 2023-24): their events and everything attached
+-- to them stay queryable but become read-only. Writes are rejected by the triggers below with
+-- SQLSTATE DJ001 (services.ErrArchivedYear), and dashboard / branch stats for the year are
+-- served from the rollups computed when the year was archived.
+-- Depends on add_denormalized_counters.sql, add_search_indexes.sql and add_ocr_text_columns.sql.
+
+CREATE TABLE IF NOT EXISTS archived_financial_years (
+    financial_year VARCHAR(7) PRIMARY KEY,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    note TEXT,
+    archived_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    archived_on TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Event figures per branch and month (YYYY-MM of the start date) of an archived year
+CREATE TABLE IF NOT EXISTS archived_year_rollups (
+    id BIGSERIAL PRIMARY KEY,
+    financial_year VARCHAR(7) NOT NULL REFERENCES archived_financial_years(financial_year) ON DELETE CASCADE,
+    branch_id BIGINT,
+    month CHAR(7) NOT NULL,
+    events BIGINT NOT NULL DEFAULT 0,
+    events_complete BIGINT NOT NULL DEFAULT 0,
+    beneficiary_men BIGINT NOT NULL DEFAULT 0,
+    beneficiary_women BIGINT NOT NULL DEFAULT 0,
+    beneficiary_child BIGINT NOT NULL DEFAULT 0,
+    initiation_men BIGINT NOT NULL DEFAULT 0,
+    initiation_women BIGINT NOT NULL DEFAULT 0,
+    initiation_child BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_year_rollups_year ON archived_year_rollups(financial_year);
+CREATE INDEX IF NOT EXISTS idx_archived_year_rollups_branch_month ON archived_year_rollups(branch_id, month);
+
+-- Donations by donation type and event media by file type, per branch and month
+CREATE TABLE IF NOT EXISTS archived_year_breakdowns (
+    id BIGSERIAL PRIMARY KEY,
+    financial_year VARCHAR(7) NOT NULL REFERENCES archived_financial_years(financial_year) ON DELETE CASCADE,
+    branch_id BIGINT,
+    month CHAR(7) NOT NULL,
+    kind VARCHAR(20) NOT NULL, -- donation_type, media_file_type
+    key VARCHAR(100) NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    amount NUMERIC NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_year_breakdowns_year ON archived_year_breakdowns(financial_year);
+CREATE INDEX IF NOT EXISTS idx_archived_year_breakdowns_kind_branch ON archived_year_breakdowns(kind, branch_id, month);
+
+-- Rows of archived years, kept out of the hot indexes below
+ALTER TABLE event_details ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE event_media ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE special_guests ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE volunteers ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE donations ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+
+-- The archived financial year an event date falls into, NULL if the year is not archived
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION archived_financial_year(p_date DATE) RETURNS VARCHAR AS $$
+    SELECT financial_year FROM archived_financial_years
+    WHERE p_date BETWEEN starts_on AND ends_on
+    LIMIT 1;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION archived_event_year(p_event_id BIGINT) RETURNS VARCHAR AS $$
+    SELECT archived_financial_year(COALESCE(start_date, created_on)::date)
+    FROM event_details WHERE id = p_event_id;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- Events are checked before and after the change, so an event cannot be moved into or out of
+-- an archived year either
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION trg_reject_archived_event_write() RETURNS TRIGGER AS $$
+DECLARE
+    year VARCHAR;
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        year := archived_financial_year(COALESCE(OLD.start_date, OLD.created_on)::date);
+    END IF;
+    IF year IS NULL AND TG_OP IN ('INSERT', 'UPDATE') THEN
+        year := archived_financial_year(COALESCE(NEW.start_date, NEW.created_on, NOW())::date);
+    END IF;
+    IF year IS NOT NULL THEN
+        RAISE EXCEPTION 'financial year % is archived and read-only', year
+            USING ERRCODE = 'DJ001', DETAIL = year;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION trg_reject_archived_child_write() RETURNS TRIGGER AS $$
+DECLARE
+    year VARCHAR;
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        year := archived_event_year(OLD.event_id);
+    END IF;
+    IF year IS NULL AND TG_OP IN ('INSERT', 'UPDATE') THEN
+        year := archived_event_year(NEW.event_id);
+    END IF;
+    IF year IS NOT NULL THEN
+        RAISE EXCEPTION 'financial year % is archived and read-only', year
+            USING ERRCODE = 'DJ001', DETAIL = year;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS event_details_archived_year ON event_details;
+CREATE TRIGGER event_details_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON event_details
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_event_write();
+
+DROP TRIGGER IF EXISTS event_media_archived_year ON event_media;
+CREATE TRIGGER event_media_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON event_media
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+DROP TRIGGER IF EXISTS special_guests_archived_year ON special_guests;
+CREATE TRIGGER special_guests_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON special_guests
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+DROP TRIGGER IF EXISTS volunteers_archived_year ON volunteers;
+CREATE TRIGGER volunteers_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON volunteers
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+DROP TRIGGER IF EXISTS donations_archived_year ON donations;
+CREATE TRIGGER donations_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON donations
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+DROP TRIGGER IF EXISTS promotion_material_details_archived_year ON promotion_material_details;
+CREATE TRIGGER promotion_material_details_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON promotion_material_details
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+-- Hot full-text indexes cover the live years only. Searches add "NOT archived" to use them
+-- (app/services/search_service.go); archived rows stay reachable through the other indexes.
+DROP INDEX IF EXISTS idx_event_details_search_fts;
+CREATE INDEX IF NOT EXISTS idx_event_details_search_fts_live
+ON event_details USING GIN (to_tsvector('simple', coalesce(theme, '') || ' ' || coalesce(spiritual_orator, '') || ' ' || coalesce(city, '')))
+WHERE NOT archived;
+
+DROP INDEX IF EXISTS idx_special_guests_search_fts;
+CREATE INDEX IF NOT EXISTS idx_special_guests_search_fts_live
+ON special_guests USING GIN (to_tsvector('simple', coalesce(first_name, '') || ' ' || coalesce(middle_name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(organization, '') || ' ' || coalesce(designation, '')))
+WHERE NOT archived;
+
+DROP INDEX IF EXISTS idx_volunteers_search_fts;
+CREATE INDEX IF NOT EXISTS idx_volunteers_search_fts_live
+ON volunteers USING GIN (to_tsvector('simple', coalesce(volunteer_name, '')))
+WHERE NOT archived;
+
+DROP INDEX IF EXISTS idx_donations_search_fts;
+CREATE INDEX IF NOT EXISTS idx_donations_search_fts_live
+ON donations USING GIN (to_tsvector('simple', coalesce(remarks, '') || ' ' || coalesce(ocr_text, '')))
+WHERE NOT archived;
+
+DROP INDEX IF EXISTS idx_event_media_ocr_fts;
+CREATE INDEX IF NOT EXISTS idx_event_media_ocr_fts_live
+ON event_media USING GIN (to_tsvector('simple', coalesce(ocr_text, '')))
+WHERE NOT archived;
+
+-- ============================================================================================
+-- init/migrations/add_row_versions.sql
+-- ============================================================================================
+
+-- Optimistic locking of branch, child branch, event and user edits: every update through the
+-- PUT endpoints bumps version, and updates carrying an older version (If-Match or a "version"
+-- field) are rejected with 409 instead of overwriting another coordinator's changes
+ALTER TABLE branches ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE event_details ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- ============================================================================================
+-- init/migrations/add_city_districts.sql
+-- ============================================================================================
+
+-- Cities belong to a district as well as a state, for the cascading country > state > district >
+-- city lookups. Existing cities keep district_id NULL until a location import links them.
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS district_id INTEGER REFERENCES districts(id);
+
+CREATE INDEX IF NOT EXISTS idx_states_country ON states(country_id);
+CREATE INDEX IF NOT EXISTS idx_districts_state ON districts(state_id);
+CREATE INDEX IF NOT EXISTS idx_cities_state ON cities(state_id);
+CREATE INDEX IF NOT EXISTS idx_cities_district ON cities(district_id);
+
+-- ============================================================================================
+-- init/migrations/add_branch_nearby.sql
+-- ============================================================================================
+
+-- Radius search for GET /api/v1/branches/nearby (earthdistance) and the geocoding hook.
+-- location_source records where a branch's coordinates came from: 'manual' coordinates are
+-- never overwritten by the geocoder, 'geocoder' ones are refreshed when the address changes.
+
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE branches
+ADD COLUMN IF NOT EXISTS location_source VARCHAR(20);
+
+UPDATE branches SET location_source = 'manual'
+WHERE location_source IS NULL AND latitude IS NOT NULL AND longitude IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_branches_earth ON branches USING gist (ll_to_earth(latitude, longitude))
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL;
+
+-- ============================================================================================
+-- init/migrations/create_event_recurrences_table.sql
+-- ============================================================================================
+
+-- Recurring event series for the event calendar (see app/services/event_calendar_service.go)
+-- The event is the first occurrence; the others are expanded when the calendar is read.
+-- weekdays (0 = Sunday) and except_dates (YYYY-MM-DD) are JSON arrays.
+
+CREATE TABLE IF NOT EXISTS event_recurrences (
+    id SERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES event_details(id) ON DELETE CASCADE,
+    frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('weekly', 'monthly')),
+    "interval" INTEGER NOT NULL DEFAULT 1 CHECK ("interval" >= 1),
+    weekdays JSONB NOT NULL DEFAULT '[]',
+    until DATE,
+    count INTEGER CHECK (count >= 1),
+    except_dates JSONB NOT NULL DEFAULT '[]',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_recurrences_event_id ON event_recurrences(event_id);
+
+-- Calendar window lookups of one-off events
+CREATE INDEX IF NOT EXISTS idx_event_details_start_end ON event_details(start_date, end_date)
+WHERE deleted_at IS NULL;
+
+-- ============================================================================================
+-- init/migrations/add_donation_receipt_void.sql
+-- ============================================================================================
+
+-- Cancellation of donation receipts. A voided receipt keeps its number (the series stays
+-- gap-free for the auditors) and is printed with a VOID stamp; the donation can no longer be edited.
+ALTER TABLE donations
+    ADD COLUMN IF NOT EXISTS voided_on TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS voided_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS void_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_donations_voided_on ON donations(voided_on) WHERE voided_on IS NOT NULL;
+
+-- ============================================================================================
+-- init/migrations/add_declared_donation_total.sql
+-- ============================================================================================
+
+-- Donation total reported for an event as a whole (e.g. from the collection register), compared
+-- with the itemized donation records by the donation reconciliation report. NULL = not declared.
+ALTER TABLE event_details
+    ADD COLUMN IF NOT EXISTS declared_donation_total NUMERIC(14, 2)
+        CHECK (declared_donation_total IS NULL OR declared_donation_total >= 0);
+
+-- ============================================================================================
+-- init/migrations/create_guests_table.sql
+-- ============================================================================================
+
+-- Special guest master records
+-- special_guests rows are appearances of a guest at an event; guest_id links them to the
+-- master record of the guest. New appearances are linked by the model hook (matching email,
+-- contact number or name with organization); existing ones by the startup backfill
+-- (services.BackfillGuests). Follow-up of each appearance (thank-you letter, feedback) is
+-- tracked on the appearance and listed by GET /api/guests/follow-ups.
+
+CREATE TABLE IF NOT EXISTS guests (
+    id SERIAL PRIMARY KEY,
+    prefix VARCHAR(50),
+    first_name VARCHAR(255),
+    middle_name VARCHAR(255),
+    last_name VARCHAR(255),
+    name_key TEXT,
+    designation VARCHAR(255),
+    organization VARCHAR(255),
+    email VARCHAR(255),
+    contact VARCHAR(20),
+    city VARCHAR(100),
+    state VARCHAR(100),
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_guests_name_key ON guests(name_key);
+CREATE INDEX IF NOT EXISTS idx_guests_email ON guests(email);
+CREATE INDEX IF NOT EXISTS idx_guests_contact ON guests(contact);
+
+ALTER TABLE special_guests ADD COLUMN IF NOT EXISTS guest_id INTEGER REFERENCES guests(id) ON DELETE SET NULL;
+ALTER TABLE special_guests ADD COLUMN IF NOT EXISTS letter_sent_on DATE;
+ALTER TABLE special_guests ADD COLUMN IF NOT EXISTS feedback_received_on DATE;
+ALTER TABLE special_guests ADD COLUMN IF NOT EXISTS feedback TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_special_guests_guest_id ON special_guests(guest_id);
+
+-- ============================================================================================
+-- init/migrations/create_stats_snapshots_table.sql
+-- ============================================================================================
+
+-- Daily snapshots of each branch's own statistics (see services/stats_snapshot_service.go).
+-- GET /api/v1/branches/:id/stats?as_of=YYYY-MM-DD reads the latest snapshot on or before the
+-- date; parent_branch_id keeps the branch tree of the day so roll-ups are reproduced as reported.
+
+CREATE TABLE IF NOT EXISTS stats_snapshots (
+    id SERIAL PRIMARY KEY,
+    snapshot_date DATE NOT NULL,
+    branch_id INTEGER NOT NULL,
+    parent_branch_id INTEGER,
+    name TEXT,
+    events BIGINT NOT NULL DEFAULT 0,
+    events_complete BIGINT NOT NULL DEFAULT 0,
+    beneficiary_men BIGINT NOT NULL DEFAULT 0,
+    beneficiary_women BIGINT NOT NULL DEFAULT 0,
+    beneficiary_child BIGINT NOT NULL DEFAULT 0,
+    initiation_men BIGINT NOT NULL DEFAULT 0,
+    initiation_women BIGINT NOT NULL DEFAULT 0,
+    initiation_child BIGINT NOT NULL DEFAULT 0,
+    donations BIGINT NOT NULL DEFAULT 0,
+    donation_amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    members BIGINT NOT NULL DEFAULT 0,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stats_snapshots_date_branch ON stats_snapshots(snapshot_date, branch_id);
+CREATE INDEX IF NOT EXISTS idx_stats_snapshots_branch_id ON stats_snapshots(branch_id);
+
+-- ============================================================================================
+-- init/migrations/add_user_deactivation.sql
+-- ============================================================================================
+
+-- Admin user management: deactivated accounts (disabled_at, also added by
+-- 001_create_auth_tables.sql) and forced logout. Access tokens issued before
+-- tokens_revoked_at are rejected even though they have not expired yet.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS tokens_revoked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_role_id ON users(role_id);
+CREATE INDEX IF NOT EXISTS idx_users_branch_id ON users(branch_id);
+
+-- ============================================================================================
+-- init/migrations/add_media_phash.sql
+-- ============================================================================================
+
+-- Perceptual hashes of uploaded images (see app/services/media_duplicate_service.go), used to
+-- warn about near-duplicates of the same event or branch. NULL for files that are not images
+-- and for media uploaded before hashing was introduced.
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+-- Candidates are compared within one event or branch
+CREATE INDEX IF NOT EXISTS idx_event_media_event_phash ON event_media(event_id) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_branch_media_branch_phash ON branch_media(branch_id) WHERE phash IS NOT NULL;
+
+-- ============================================================================================
+-- init/migrations/add_two_factor_auth.sql
+-- ============================================================================================
+
+-- Two-factor authentication with authenticator apps (TOTP), see app/services/auth/totp.go.
+-- totp_secret is AES-GCM encrypted with a key derived from TOKEN_PEPPER; it is set when
+-- enrollment starts and only used for sign-in once totp_enabled_at is set. totp_last_step is
+-- the time step of the last accepted code, so a code cannot be replayed.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS totp_secret BYTEA,
+ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+-- Single-use recovery codes (hashed with the token pepper like other tokens)
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::TEXT,
+    user_id BIGINT NOT NULL,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_user_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+-- Sign-ins that passed the password check and wait for the second factor
+CREATE TABLE IF NOT EXISTS totp_challenges (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash BYTEA NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_totp_challenges_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_totp_challenges_token_hash ON totp_challenges(token_hash);
+CREATE INDEX IF NOT EXISTS idx_totp_challenges_expires_at ON totp_challenges(expires_at);
+
+-- ============================================================================================
+-- init/migrations/add_event_donation_total.sql
+-- ============================================================================================
+
+-- Donation total per event, next to the other denormalized counters, so the event list can
+-- show it without aggregating donations per row. Voided and soft-deleted donations are left
+-- out, as in the donation reports.
+-- Depends on add_denormalized_counters.sql, add_donation_receipt_void.sql and
+-- add_financial_year_archival.sql.
+
+ALTER TABLE event_details
+ADD COLUMN IF NOT EXISTS donation_total DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION refresh_event_counters(p_event_id BIGINT) RETURNS VOID AS $$
+BEGIN
+    IF p_event_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    UPDATE event_details SET
+        media_count = (SELECT COUNT(*) FROM event_media WHERE event_id = p_event_id),
+        special_guest_count = (SELECT COUNT(*) FROM special_guests WHERE event_id = p_event_id),
+        volunteer_count = (SELECT COUNT(*) FROM volunteers WHERE event_id = p_event_id AND deleted_at IS NULL),
+        donation_count = (SELECT COUNT(*) FROM donations WHERE event_id = p_event_id AND deleted_at IS NULL),
+        donation_total = (SELECT COALESCE(SUM(amount), 0) FROM donations WHERE event_id = p_event_id AND deleted_at IS NULL AND voided_on IS NULL),
+        promotion_material_count = (SELECT COUNT(*) FROM promotion_material_details WHERE event_id = p_event_id),
+        last_activity_on = NOW()
+    WHERE id = p_event_id;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Amount corrections and voided receipts change the total too
+DROP TRIGGER IF EXISTS donations_counters ON donations;
+CREATE TRIGGER donations_counters
+AFTER INSERT OR DELETE OR UPDATE OF event_id, deleted_at, amount, voided_on ON donations
+FOR EACH ROW EXECUTE FUNCTION trg_refresh_event_counters();
+
+-- Backfill, archived years included (their events are otherwise read-only)
+ALTER TABLE event_details DISABLE TRIGGER event_details_archived_year;
+UPDATE event_details e SET donation_total = COALESCE((
+    SELECT SUM(d.amount) FROM donations d
+    WHERE d.event_id = e.id AND d.deleted_at IS NULL AND d.voided_on IS NULL
+), 0);
+ALTER TABLE event_details ENABLE TRIGGER event_details_archived_year;
+
+-- ============================================================================================
+-- init/migrations/add_media_scan_pending.sql
+-- ============================================================================================
+
+-- Uploads are stored with scan_status 'pending' while a scanner is configured (MEDIA_SCANNER)
+-- and scanned by a media_upload_scan job; infected and flagged files are moved under the
+-- quarantine/ prefix. The backfill also picks up pending rows whose job was lost, so its
+-- partial indexes cover them.
+-- Depends on add_media_scan_status.sql.
+
+DROP INDEX IF EXISTS idx_event_media_unscanned;
+CREATE INDEX IF NOT EXISTS idx_event_media_unscanned ON event_media(id)
+WHERE scan_status IS NULL OR scan_status = 'pending';
+
+DROP INDEX IF EXISTS idx_branch_media_unscanned;
+CREATE INDEX IF NOT EXISTS idx_branch_media_unscanned ON branch_media(id)
+WHERE scan_status IS NULL OR scan_status = 'pending';
+
+-- ============================================================================================
+-- init/migrations/add_media_transcoding.sql
+-- ============================================================================================
+
+-- Browser-playable renditions of uploaded videos (see app/services/video_transcode_service.go)
+-- transcode_status is NULL for files served as uploaded (MP4/WebM, images, documents); MKV,
+-- MOV, AVI and the like are transcoded to MP4 by the transcode job, which stores the rendition
+-- under transcoded/ and its key in transcoded_s3_key.
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS transcode_status VARCHAR(20),
+ADD COLUMN IF NOT EXISTS transcode_detail TEXT,
+ADD COLUMN IF NOT EXISTS transcoded_s3_key TEXT;
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS transcode_status VARCHAR(20),
+ADD COLUMN IF NOT EXISTS transcode_detail TEXT,
+ADD COLUMN IF NOT EXISTS transcoded_s3_key TEXT;
+
+-- ============================================================================================
+-- init/migrations/create_upload_sessions_table.sql
+-- ============================================================================================
+
+-- Resumable uploads (see app/services/upload_session_service.go). A session collects the chunks
+-- of one file as the parts of a multipart upload until it is completed into an event_media or
+-- branch_media row. Sessions not completed before expires_at are aborted by the hourly cleanup
+-- and the garbage collection job, which also drops completed sessions once they expire.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    target VARCHAR(20) NOT NULL CHECK (target IN ('event_media', 'branch_media')),
+    owner_id INTEGER NOT NULL,
+    category VARCHAR(100),
+    filename TEXT NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    chunk_size BIGINT NOT NULL CHECK (chunk_size > 0),
+    received_bytes BIGINT NOT NULL DEFAULT 0,
+    s3_key TEXT NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    parts JSONB NOT NULL DEFAULT '{}'::jsonb,
+    media_id INTEGER,
+    completed_on TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_on TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id ON upload_sessions(user_id);
+
+-- ============================================================================================
+-- init/migrations/create_branch_storage_usage_tables.sql
+-- ============================================================================================
+
+-- Storage used per branch (see app/services/storage_usage_service.go). Rows are recomputed by
+-- the storage_usage job from the bucket listing: one row per branch and media category, covering
+-- the originals, thumbnails and video renditions of event media, branch media and donation
+-- receipts (category 'Donation Receipts'), including soft-deleted rows that still hold files.
+CREATE TABLE IF NOT EXISTS branch_storage_usage (
+    branch_id INTEGER NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    objects BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    computed_on TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (branch_id, category)
+);
+
+-- Optional storage quotas; uploads to a branch (and its events) are refused once its computed
+-- usage reaches the quota
+CREATE TABLE IF NOT EXISTS branch_storage_quotas (
+    branch_id INTEGER PRIMARY KEY REFERENCES branches(id) ON DELETE CASCADE,
+    quota_bytes BIGINT NOT NULL CHECK (quota_bytes > 0),
+    updated_by INTEGER,
+    updated_on TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,43 @@
+-- Media rows used to store only the presigned URL of their object in file_url. The services
+-- now read the object key from s3_key and fail on rows without one (see checkLegacyRecords in
+-- app/main/main.go), so the key is derived from the URL of rows that predate the column:
+--
+--   https://<bucket>.s3.<region>.amazonaws.com/<key>?X-Amz-...  (virtual-hosted style)
+--   https://s3.<region>.amazonaws.com/<bucket>/<key>?X-Amz-...  (path style)
+--
+-- URLs of other hosts, and keys with percent-escapes, are left alone and keep being reported
+-- at startup.
+
+-- +goose Up
+SET LOCAL statement_timeout = 0;
+
+ALTER TABLE event_media
+ADD COLUMN IF NOT EXISTS file_url TEXT,
+ADD COLUMN IF NOT EXISTS s3_key TEXT,
+ADD COLUMN IF NOT EXISTS original_filename TEXT,
+ADD COLUMN IF NOT EXISTS file_type VARCHAR(50);
+
+ALTER TABLE branch_media
+ADD COLUMN IF NOT EXISTS s3_key TEXT,
+ADD COLUMN IF NOT EXISTS original_filename TEXT;
+
+UPDATE event_media
+SET s3_key = CASE
+    WHEN split_part(file_url, '?', 1) ~ '^https?://s3[.-][^/]*amazonaws\.com/'
+        THEN regexp_replace(split_part(file_url, '?', 1), '^https?://[^/]+/[^/]+/', '')
+    ELSE regexp_replace(split_part(file_url, '?', 1), '^https?://[^/]+/', '')
+END
+WHERE (s3_key IS NULL OR s3_key = '')
+  AND split_part(file_url, '?', 1) ~ '^https?://[^/]+\.amazonaws\.com/[^%]+$';
+
+UPDATE branch_media
+SET s3_key = CASE
+    WHEN split_part(file_url, '?', 1) ~ '^https?://s3[.-][^/]*amazonaws\.com/'
+        THEN regexp_replace(split_part(file_url, '?', 1), '^https?://[^/]+/[^/]+/', '')
+    ELSE regexp_replace(split_part(file_url, '?', 1), '^https?://[^/]+/', '')
+END
+WHERE (s3_key IS NULL OR s3_key = '')
+  AND split_part(file_url, '?', 1) ~ '^https?://[^/]+\.amazonaws\.com/[^%]+$';
+
+-- +goose Down
+-- The columns are kept: dropping them would lose the keys of every upload since.
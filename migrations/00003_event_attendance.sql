@@ -0,0 +1,28 @@
+-- Daily attendance of events by age band, with first-time vs returning attendees (see
+-- app/services/event_attendance_service.go). The beneficiary figures of an event with
+-- attendance are recomputed from these rows by the service on every change.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS event_attendance (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES event_details(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    age_band VARCHAR(20) NOT NULL CHECK (age_band IN ('child', 'youth', 'adult', 'senior')),
+    male INTEGER NOT NULL DEFAULT 0 CHECK (male >= 0),
+    female INTEGER NOT NULL DEFAULT 0 CHECK (female >= 0),
+    new_attendees INTEGER NOT NULL DEFAULT 0 CHECK (new_attendees >= 0 AND new_attendees <= male + female),
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_on TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_attendance_day_band ON event_attendance(event_id, day, age_band);
+
+-- Read-only with the rest of the event once its financial year is archived
+DROP TRIGGER IF EXISTS event_attendance_archived_year ON event_attendance;
+CREATE TRIGGER event_attendance_archived_year
+BEFORE INSERT OR UPDATE OR DELETE ON event_attendance
+FOR EACH ROW EXECUTE FUNCTION trg_reject_archived_child_write();
+
+-- +goose Down
+DROP TABLE IF EXISTS event_attendance;
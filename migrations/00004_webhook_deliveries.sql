@@ -0,0 +1,28 @@
+-- Delivery log of outgoing webhooks (see app/services/webhook_service.go): one row per event
+-- sent to a subscription, updated by each attempt of its webhook_delivery job.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    delivery_id VARCHAR(36) NOT NULL UNIQUE,
+    event_type VARCHAR(100) NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    data JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_on TIMESTAMPTZ,
+    job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    last_attempt_on TIMESTAMPTZ,
+    status_code INTEGER,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    response_body TEXT,
+    error TEXT,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_on DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(status);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
@@ -0,0 +1,26 @@
+-- In-app notification center (see app/services/notification_center_service.go). Rows are
+-- created by the services; users list them and mark them read.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT,
+    link TEXT,
+    entity_type VARCHAR(50),
+    entity_id BIGINT,
+    dedup_key VARCHAR(100),
+    read_on TIMESTAMPTZ,
+    created_on TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_on DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_on IS NULL;
+-- NULL keys are distinct, so only keyed notifications are deduplicated
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedup ON notifications(user_id, dedup_key);
+CREATE INDEX IF NOT EXISTS idx_notifications_entity ON notifications(entity_type, entity_id) WHERE read_on IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS notifications;
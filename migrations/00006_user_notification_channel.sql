@@ -0,0 +1,14 @@
+-- Channel of users' critical alerts (credentials, event review results) besides email: email
+-- (only), sms or whatsapp to their contact number (see app/services/messaging).
+
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS notification_channel VARCHAR(20) NOT NULL DEFAULT 'email';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_notification_channel;
+ALTER TABLE users ADD CONSTRAINT chk_users_notification_channel
+    CHECK (notification_channel IN ('email', 'sms', 'whatsapp'));
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_notification_channel;
+ALTER TABLE users DROP COLUMN IF EXISTS notification_channel;
@@ -0,0 +1,61 @@
+// Package migrations holds the versioned SQL migrations of the database and runs them with
+// goose. Files are named <version>_<description>.sql, with a "-- +goose Up" section (and an
+// optional "-- +goose Down"); each runs in a transaction and is recorded in goose_db_version.
+//
+// 00001_baseline.sql captures the schema that used to be applied by hand from init/; it is
+// idempotent, so existing databases go through it like new ones. Add schema changes as new
+// files with the next version number, never by editing an applied one.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// TableName records the applied versions
+const TableName = "goose_db_version"
+
+// newProvider returns a goose provider for the embedded migrations. Instances starting at the
+// same time take turns through a Postgres advisory lock.
+func newProvider(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, files,
+		goose.WithTableName(TableName),
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// Up applies the pending migrations in order and returns the ones it ran. It stops at the first
+// failure, which is rolled back.
+func Up(ctx context.Context, db *sql.DB) ([]*goose.MigrationResult, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return nil, err
+	}
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return results, fmt.Errorf("applying migrations: %w", err)
+	}
+	return results, nil
+}
+
+// Status lists every migration and whether it was applied
+func Status(ctx context.Context, db *sql.DB) ([]*goose.MigrationStatus, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Status(ctx)
+}